@@ -3,16 +3,46 @@
 package armor
 
 import (
-	"bytes"
+	"encoding/base64"
 	"io"
 	"io/ioutil"
+	"strings"
 
-	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/gopenpgp/v2/constants"
 	"github.com/ProtonMail/gopenpgp/v2/internal"
 	"github.com/pkg/errors"
 )
 
+// Options customizes the headers of an armored block. A nil *Options writes
+// the default gopenpgp headers.
+type Options struct {
+	// Version and Comment replace the default headers, if not empty.
+	Version string
+	Comment string
+	// OmitVersion and OmitComment drop the headers. The Version header
+	// identifies the library that armored the data.
+	OmitVersion bool
+	OmitComment bool
+	// Headers are additional headers, e.g. Charset.
+	Headers map[string]string
+	// OmitChecksum drops the CRC24 checksum, which the crypto refresh of
+	// OpenPGP deprecates.
+	OmitChecksum bool
+	// LineLength is the length of the base64 lines, at most 76, or 64 if
+	// zero.
+	LineLength int
+	// CRLF ends the lines with CRLF instead of LF.
+	CRLF bool
+}
+
+// Block is an unarmored block, with the type and the headers of its armor.
+type Block struct {
+	// Type is the armor type, e.g. constants.PGPMessageHeader.
+	Type    string
+	Headers map[string]string
+	Data    []byte
+}
+
 // ArmorKey armors input as a public key.
 func ArmorKey(input []byte) (string, error) {
 	return ArmorWithType(input, constants.PublicKeyHeader)
@@ -21,7 +51,32 @@ func ArmorKey(input []byte) (string, error) {
 // ArmorWithTypeBuffered returns a io.WriteCloser which, when written to, writes
 // armored data to w with the given armorType.
 func ArmorWithTypeBuffered(w io.Writer, armorType string) (io.WriteCloser, error) {
-	return armor.Encode(w, armorType, nil)
+	return newEncoder(w, armorType, nil, defaultLineLength, "\n", true)
+}
+
+// ArmorWithTypeBufferedAndOptions returns a io.WriteCloser which, when written
+// to, writes armored data to w with the given armorType and options.
+func ArmorWithTypeBufferedAndOptions(w io.Writer, armorType string, options *Options) (io.WriteCloser, error) {
+	headers, err := options.headers()
+	if err != nil {
+		return nil, err
+	}
+	if options == nil {
+		return newEncoder(w, armorType, headers, defaultLineLength, "\n", true)
+	}
+
+	lineLength := options.LineLength
+	if lineLength == 0 {
+		lineLength = defaultLineLength
+	}
+	if lineLength < 0 || lineLength > maxLineLength {
+		return nil, errors.New("gopenpgp: invalid armor line length")
+	}
+	newline := "\n"
+	if options.CRLF {
+		newline = "\r\n"
+	}
+	return newEncoder(w, armorType, headers, lineLength, newline, !options.OmitChecksum)
 }
 
 // ArmorWithType armors input with the given armorType.
@@ -42,6 +97,23 @@ func ArmorWithTypeAndCustomHeaders(input []byte, armorType, version, comment str
 	return armorWithTypeAndHeaders(input, armorType, headers)
 }
 
+// ArmorWithTypeAndOptions armors input with the given armorType and options.
+func ArmorWithTypeAndOptions(input []byte, armorType string, options *Options) (string, error) {
+	var b strings.Builder
+	b.Grow(armoredSizeEstimate(len(input)))
+	w, err := ArmorWithTypeBufferedAndOptions(&b, armorType, options)
+	if err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to encode armoring")
+	}
+	if _, err = w.Write(input); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to write armored to buffer")
+	}
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to close armor buffer")
+	}
+	return b.String(), nil
+}
+
 // Unarmor unarmors an armored input into a byte array.
 func Unarmor(input string) ([]byte, error) {
 	b, err := internal.Unarmor(input)
@@ -51,10 +123,30 @@ func Unarmor(input string) ([]byte, error) {
 	return ioutil.ReadAll(b.Body)
 }
 
+// UnarmorBlock unarmors the first armored block of input, and returns it with
+// its armor type and headers, e.g. to tell a message from a key or a
+// signature, or to read the Charset header.
+func UnarmorBlock(input string) (*Block, error) {
+	b, err := internal.Unarmor(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to unarmor")
+	}
+	data, err := ioutil.ReadAll(b.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read armored data")
+	}
+	return &Block{
+		Type:    b.Type,
+		Headers: b.Header,
+		Data:    data,
+	}, nil
+}
+
 func armorWithTypeAndHeaders(input []byte, armorType string, headers map[string]string) (string, error) {
-	var b bytes.Buffer
+	var b strings.Builder
+	b.Grow(armoredSizeEstimate(len(input)))
 
-	w, err := armor.Encode(&b, armorType, headers)
+	w, err := newEncoder(&b, armorType, headers, defaultLineLength, "\n", true)
 
 	if err != nil {
 		return "", errors.Wrap(err, "gopengp: unable to encode armoring")
@@ -67,3 +159,45 @@ func armorWithTypeAndHeaders(input []byte, armorType string, headers map[string]
 	}
 	return b.String(), nil
 }
+
+// armoredSizeEstimate returns the size of inputSize bytes armored with the
+// default line length and headers, to allocate the armored string once.
+// Shorter lines and longer headers need a bit more.
+func armoredSizeEstimate(inputSize int) int {
+	encodedSize := base64.StdEncoding.EncodedLen(inputSize)
+	// Line breaks, headers, checksum and armor lines
+	return encodedSize + encodedSize/defaultLineLength + 256
+}
+
+func (options *Options) headers() (map[string]string, error) {
+	headers := make(map[string]string)
+	if options == nil {
+		for key, value := range internal.ArmorHeaders {
+			headers[key] = value
+		}
+		return headers, nil
+	}
+
+	for key, value := range options.Headers {
+		headers[key] = value
+	}
+	if !options.OmitVersion {
+		headers["Version"] = internal.ArmorHeaders["Version"]
+		if options.Version != "" {
+			headers["Version"] = options.Version
+		}
+	}
+	if !options.OmitComment {
+		headers["Comment"] = internal.ArmorHeaders["Comment"]
+		if options.Comment != "" {
+			headers["Comment"] = options.Comment
+		}
+	}
+
+	for key, value := range headers {
+		if key == "" || strings.ContainsAny(key, ": \r\n") || strings.ContainsAny(value, "\r\n") {
+			return nil, errors.New("gopenpgp: invalid armor header " + key)
+		}
+	}
+	return headers, nil
+}
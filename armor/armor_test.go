@@ -0,0 +1,149 @@
+package armor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+var testArmorData = []byte(strings.Repeat("armored test data ", 10))
+
+func TestArmorWithOptionsMatchesDefault(t *testing.T) {
+	armored, err := ArmorWithTypeAndOptions(testArmorData, constants.PGPMessageHeader, &Options{OmitComment: true})
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	expected, err := ArmorWithTypeAndCustomHeaders(testArmorData, constants.PGPMessageHeader, constants.ArmorHeaderVersion, "")
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	assert.Exactly(t, expected, armored)
+
+	unarmored, err := Unarmor(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor:", err)
+	}
+	assert.Exactly(t, testArmorData, unarmored)
+}
+
+func TestArmorOmitChecksum(t *testing.T) {
+	armored, err := ArmorWithTypeAndOptions(testArmorData, constants.PGPMessageHeader, &Options{OmitChecksum: true})
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	for _, line := range strings.Split(armored, "\n") {
+		assert.False(t, strings.HasPrefix(line, "="), "unexpected checksum line %q", line)
+	}
+
+	unarmored, err := Unarmor(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor:", err)
+	}
+	assert.Exactly(t, testArmorData, unarmored)
+}
+
+func TestUnarmorWithChecksumPolicy(t *testing.T) {
+	withChecksum, err := ArmorWithType(testArmorData, constants.PGPMessageHeader)
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	withoutChecksum, err := ArmorWithTypeAndOptions(testArmorData, constants.PGPMessageHeader, &Options{OmitChecksum: true})
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	lines := strings.Split(withChecksum, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "=") {
+			lines[i] = "=AAAA"
+		}
+	}
+	mismatched := strings.Join(lines, "\n")
+
+	for _, policy := range []ChecksumPolicy{ChecksumOptional, ChecksumRequired, ChecksumIgnored} {
+		data, warning, err := UnarmorWithChecksumPolicy(withChecksum, policy)
+		assert.Nil(t, err)
+		assert.Nil(t, warning)
+		assert.Exactly(t, testArmorData, data)
+	}
+
+	data, warning, err := UnarmorWithChecksumPolicy(withoutChecksum, ChecksumOptional)
+	assert.Nil(t, err)
+	assert.Exactly(t, ErrMissingChecksum, warning)
+	assert.Exactly(t, testArmorData, data)
+
+	_, _, err = UnarmorWithChecksumPolicy(withoutChecksum, ChecksumRequired)
+	assert.Exactly(t, ErrMissingChecksum, err)
+
+	_, _, err = UnarmorWithChecksumPolicy(mismatched, ChecksumOptional)
+	assert.Exactly(t, ErrChecksumMismatch, err)
+
+	data, warning, err = UnarmorWithChecksumPolicy(mismatched, ChecksumIgnored)
+	assert.Nil(t, err)
+	assert.Exactly(t, ErrChecksumMismatch, warning)
+	assert.Exactly(t, testArmorData, data)
+
+	crlf := strings.ReplaceAll(withChecksum, "\n", "\r\n")
+	data, _, err = UnarmorWithChecksumPolicy(crlf, ChecksumRequired)
+	assert.Nil(t, err)
+	assert.Exactly(t, testArmorData, data)
+}
+
+func TestArmorLineLengthAndCRLF(t *testing.T) {
+	armored, err := ArmorWithTypeAndOptions(testArmorData, constants.PGPMessageHeader, &Options{
+		LineLength: 76,
+		CRLF:       true,
+	})
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+	assert.NotContains(t, strings.ReplaceAll(armored, "\r\n", ""), "\n")
+
+	lines := strings.Split(armored, "\r\n")
+	assert.Len(t, lines[4], 76)
+
+	unarmored, err := Unarmor(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor:", err)
+	}
+	assert.Exactly(t, testArmorData, unarmored)
+
+	_, err = ArmorWithTypeAndOptions(testArmorData, constants.PGPMessageHeader, &Options{LineLength: 77})
+	assert.Error(t, err)
+}
+
+func TestUnarmorBlock(t *testing.T) {
+	armored, err := ArmorWithTypeAndOptions(testArmorData, constants.PGPSignatureHeader, &Options{
+		OmitVersion: true,
+		Headers:     map[string]string{"Charset": "utf-8"},
+	})
+	if err != nil {
+		t.Fatal("Cannot armor:", err)
+	}
+
+	block, err := UnarmorBlock("Some text before the block\n" + armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor:", err)
+	}
+	assert.Exactly(t, constants.PGPSignatureHeader, block.Type)
+	assert.Exactly(t, map[string]string{
+		"Comment": constants.ArmorHeaderComment,
+		"Charset": "utf-8",
+	}, block.Headers)
+	assert.Exactly(t, testArmorData, block.Data)
+
+	_, err = UnarmorBlock("not armored")
+	assert.Error(t, err)
+}
+
+func TestArmoredSizeEstimate(t *testing.T) {
+	for _, size := range []int{0, 1, 47, 48, 49, 1000, 100000} {
+		armored, err := ArmorWithType(make([]byte, size), constants.PGPMessageHeader)
+		if err != nil {
+			t.Fatal("Cannot armor:", err)
+		}
+		assert.LessOrEqual(t, len(armored), armoredSizeEstimate(size))
+	}
+}
@@ -0,0 +1,131 @@
+package armor
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+// ChecksumPolicy is how UnarmorWithChecksumPolicy handles the CRC24 checksum
+// of armored data, which the crypto refresh of OpenPGP makes optional.
+type ChecksumPolicy int
+
+const (
+	// ChecksumOptional accepts a missing checksum, with a warning, and fails
+	// on a mismatched one, as Unarmor does.
+	ChecksumOptional ChecksumPolicy = iota
+	// ChecksumRequired fails on a missing or mismatched checksum.
+	ChecksumRequired
+	// ChecksumIgnored accepts a missing or mismatched checksum, with a
+	// warning.
+	ChecksumIgnored
+)
+
+var (
+	// ErrMissingChecksum is returned, as error or warning, for armored data
+	// without checksum.
+	ErrMissingChecksum = errors.New("gopenpgp: armor checksum missing")
+	// ErrChecksumMismatch is returned, as error or warning, for armored data
+	// whose checksum doesn't match.
+	ErrChecksumMismatch = errors.New("gopenpgp: armor checksum mismatch")
+)
+
+// UnarmorWithChecksumPolicy unarmors the first armored block of input,
+// handling its checksum according to policy. A checksum problem that doesn't
+// fail is returned as warning, ErrMissingChecksum or ErrChecksumMismatch.
+func UnarmorWithChecksumPolicy(input string, policy ChecksumPolicy) (data []byte, warning error, err error) {
+	block, err := decode(input)
+	if err != nil {
+		return nil, nil, internal.WithErrorCode(constants.ErrorCodeInvalidArmor, err)
+	}
+
+	var checksumErr error
+	switch {
+	case !block.hasChecksum:
+		checksumErr = ErrMissingChecksum
+	case block.checksum != crc24(crc24Init, block.data)&crc24Mask:
+		checksumErr = ErrChecksumMismatch
+	}
+	if checksumErr == nil {
+		return block.data, nil, nil
+	}
+
+	fails := policy == ChecksumRequired || (policy == ChecksumOptional && checksumErr == ErrChecksumMismatch)
+	if fails {
+		return nil, nil, checksumErr
+	}
+	return block.data, checksumErr, nil
+}
+
+// decodedBlock is an armored block, decoded by decode.
+type decodedBlock struct {
+	armorType   string
+	headers     map[string]string
+	data        []byte
+	checksum    uint32
+	hasChecksum bool
+}
+
+// decode decodes the first armored block of input. Lines may end with LF or
+// CRLF, and text before the block is ignored.
+func decode(input string) (*decodedBlock, error) {
+	lines := strings.Split(strings.ReplaceAll(input, "\r\n", "\n"), "\n")
+
+	start := -1
+	block := &decodedBlock{headers: make(map[string]string)}
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-----BEGIN ") && strings.HasSuffix(line, "-----") && len(line) > 16 {
+			block.armorType = line[len("-----BEGIN ") : len(line)-len("-----")]
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("gopenpgp: no armored block found")
+	}
+
+	// Headers, up to the first empty line
+	i := start
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			i++
+			break
+		}
+		separator := strings.Index(line, ": ")
+		if separator < 0 {
+			return nil, errors.New("gopenpgp: invalid armor header")
+		}
+		block.headers[line[:separator]] = line[separator+2:]
+	}
+
+	var body strings.Builder
+	endLine := "-----END " + block.armorType + "-----"
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == endLine:
+			data, err := base64.StdEncoding.DecodeString(body.String())
+			if err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: invalid armored data")
+			}
+			block.data = data
+			return block, nil
+		case len(line) == 5 && line[0] == '=':
+			checksum, err := base64.StdEncoding.DecodeString(line[1:])
+			if err != nil || len(checksum) != 3 {
+				return nil, errors.New("gopenpgp: invalid armor checksum")
+			}
+			block.checksum = uint32(checksum[0])<<16 | uint32(checksum[1])<<8 | uint32(checksum[2])
+			block.hasChecksum = true
+		default:
+			body.WriteString(line)
+		}
+	}
+	return nil, errors.New("gopenpgp: armored block not terminated")
+}
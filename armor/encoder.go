@@ -0,0 +1,158 @@
+package armor
+
+import (
+	"encoding/base64"
+	"io"
+	"sort"
+)
+
+const (
+	crc24Init = 0xb704ce
+	crc24Poly = 0x1864cfb
+	crc24Mask = 0xffffff
+)
+
+// defaultLineLength is the default length of the base64 lines of armored
+// data, and maxLineLength the maximum one, see RFC 4880, section 6.3.
+const (
+	defaultLineLength = 64
+	maxLineLength     = 76
+)
+
+// crc24 updates the OpenPGP CRC24 checksum crc with data, see RFC 4880,
+// section 6.1.
+func crc24(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc
+}
+
+// encoder armors the data written to it, with the checksum if withChecksum.
+// The Version and Comment headers come first, then the other headers in
+// lexical order, so that the output is deterministic.
+type encoder struct {
+	out          io.Writer
+	armorType    string
+	newline      string
+	withChecksum bool
+	crc          uint32
+	lines        *lineBreaker
+	b64          io.WriteCloser
+}
+
+func newEncoder(
+	out io.Writer, armorType string, headers map[string]string, lineLength int, newline string, withChecksum bool,
+) (*encoder, error) {
+	e := &encoder{
+		out:          out,
+		armorType:    armorType,
+		newline:      newline,
+		withChecksum: withChecksum,
+		crc:          crc24Init,
+	}
+	if err := e.writeString("-----BEGIN " + armorType + "-----" + newline); err != nil {
+		return nil, err
+	}
+	for _, key := range sortedHeaderKeys(headers) {
+		if err := e.writeString(key + ": " + headers[key] + newline); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.writeString(newline); err != nil {
+		return nil, err
+	}
+	e.lines = &lineBreaker{out: out, lineLength: lineLength, newline: newline}
+	e.b64 = base64.NewEncoder(base64.StdEncoding, e.lines)
+	return e, nil
+}
+
+func (e *encoder) Write(data []byte) (int, error) {
+	e.crc = crc24(e.crc, data)
+	return e.b64.Write(data)
+}
+
+func (e *encoder) Close() error {
+	if err := e.b64.Close(); err != nil {
+		return err
+	}
+	if e.lines.used > 0 {
+		if err := e.writeString(e.newline); err != nil {
+			return err
+		}
+	}
+	if e.withChecksum {
+		checksum := []byte{byte(e.crc >> 16), byte(e.crc >> 8), byte(e.crc)}
+		if err := e.writeString("=" + base64.StdEncoding.EncodeToString(checksum) + e.newline); err != nil {
+			return err
+		}
+	}
+	return e.writeString("-----END " + e.armorType + "-----")
+}
+
+func (e *encoder) writeString(s string) error {
+	_, err := io.WriteString(e.out, s)
+	return err
+}
+
+// lineBreaker writes data in lines of lineLength bytes, each ended with
+// newline but the last one.
+type lineBreaker struct {
+	out        io.Writer
+	lineLength int
+	newline    string
+	used       int
+}
+
+func (l *lineBreaker) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		if l.used == l.lineLength {
+			if _, err := io.WriteString(l.out, l.newline); err != nil {
+				return written, err
+			}
+			l.used = 0
+		}
+		n := l.lineLength - l.used
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := l.out.Write(data[:n]); err != nil {
+			return written, err
+		}
+		l.used += n
+		written += n
+		data = data[n:]
+	}
+	return written, nil
+}
+
+func sortedHeaderKeys(headers map[string]string) []string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	rank := func(key string) int {
+		switch key {
+		case "Version":
+			return 0
+		case "Comment":
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank(keys[i]) != rank(keys[j]) {
+			return rank(keys[i]) < rank(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
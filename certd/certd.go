@@ -0,0 +1,246 @@
+// Package certd reads and writes certificates in the shared OpenPGP
+// certificate directory (pgp-cert-d) layout, so that the public keys can be
+// shared with other OpenPGP software on the same machine.
+//
+// Each certificate is stored unarmored, in a file named after its lowercase
+// hex fingerprint: the first two characters name a subdirectory, the
+// remaining characters name the file.
+package certd
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/pkg/errors"
+)
+
+// EnvVar is the environment variable overriding the default store location.
+const EnvVar = "PGP_CERT_D"
+
+const dirName = "pgp.cert.d"
+
+// ErrNotFound is returned when a certificate is not in the store.
+var ErrNotFound = errors.New("gopenpgp: certificate not found in store")
+
+// Store is a certificate directory.
+type Store struct {
+	path string
+}
+
+// NewStore returns the certificate directory at path.
+// The directory is created on the first insertion.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// NewDefaultStore returns the certificate directory shared by the OpenPGP
+// software of the current user: the one in the PGP_CERT_D environment
+// variable if set, or the platform default otherwise.
+func NewDefaultStore() (*Store, error) {
+	if path := os.Getenv(EnvVar); path != "" {
+		return NewStore(path), nil
+	}
+
+	path, err := defaultPath()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to locate the certificate directory")
+	}
+	return NewStore(path), nil
+}
+
+// Path returns the location of the certificate directory.
+func (store *Store) Path() string {
+	return store.path
+}
+
+// Get returns the certificate with the given hex fingerprint, or ErrNotFound.
+func (store *Store) Get(fingerprint string) (*crypto.Key, error) {
+	certPath, err := store.certPath(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(certPath) //nolint
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read certificate")
+	}
+
+	return crypto.NewKey(data)
+}
+
+// Insert stores the public part of key, merged with the certificate with the
+// same fingerprint if any.
+func (store *Store) Insert(key *crypto.Key) error {
+	certPath, err := store.certPath(key.GetFingerprint())
+	if err != nil {
+		return err
+	}
+
+	existing, err := store.Get(key.GetFingerprint())
+	switch {
+	case err == nil:
+		if key, err = existing.Merge(key); err != nil {
+			return err
+		}
+	case err != ErrNotFound:
+		return err
+	}
+
+	data, err := key.GetPublicKey()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(certPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to create certificate directory")
+	}
+
+	// Write to a temporary file then rename it, so that readers never see
+	// a partially written certificate.
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to create certificate")
+	}
+	defer os.Remove(tmp.Name()) //nolint
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "gopenpgp: unable to write certificate")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to write certificate")
+	}
+	if err := os.Rename(tmp.Name(), certPath); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to write certificate")
+	}
+
+	return nil
+}
+
+// Fingerprints returns the lowercase hex fingerprints of the certificates in
+// the store. Files that don't follow the layout are ignored.
+func (store *Store) Fingerprints() ([]string, error) {
+	dirs, err := ioutil.ReadDir(store.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to list certificates")
+	}
+
+	var fingerprints []string
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(store.path, dir.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to list certificates")
+		}
+
+		for _, file := range files {
+			fingerprint := dir.Name() + file.Name()
+			if file.Mode().IsRegular() && isFingerprint(fingerprint) {
+				fingerprints = append(fingerprints, fingerprint)
+			}
+		}
+	}
+
+	return fingerprints, nil
+}
+
+// ImportKeyRing stores the public part of every key of keyRing.
+func (store *Store) ImportKeyRing(keyRing *crypto.KeyRing) error {
+	for _, key := range keyRing.GetKeys() {
+		if err := store.Insert(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportKeyRing returns a keyring containing all the certificates in the store.
+func (store *Store) ExportKeyRing() (*crypto.KeyRing, error) {
+	fingerprints, err := store.Fingerprints()
+	if err != nil {
+		return nil, err
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fingerprint := range fingerprints {
+		key, err := store.Get(fingerprint)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read certificate "+fingerprint)
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return keyRing, nil
+}
+
+// --- Internal functions
+
+// certPath returns the location of the certificate with the given fingerprint.
+func (store *Store) certPath(fingerprint string) (string, error) {
+	fingerprint = strings.ToLower(fingerprint)
+	if !isFingerprint(fingerprint) {
+		return "", errors.New("gopenpgp: invalid fingerprint")
+	}
+	return filepath.Join(store.path, fingerprint[:2], fingerprint[2:]), nil
+}
+
+// isFingerprint returns true if s is a lowercase hex v4 or v5 fingerprint.
+func isFingerprint(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	if strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// defaultPath returns the platform default location of the certificate
+// directory.
+func defaultPath() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("gopenpgp: %APPDATA% is not defined")
+		}
+		return filepath.Join(appData, dirName), nil
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", dirName), nil
+	default:
+		if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+			return filepath.Join(dataHome, dirName), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "share", dirName), nil
+	}
+}
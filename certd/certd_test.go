@@ -0,0 +1,112 @@
+package certd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertAndGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	key, err := crypto.GenerateKey("certd", "certd@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+
+	_, err = store.Get(key.GetFingerprint())
+	assert.Equal(t, ErrNotFound, err)
+
+	if err := store.Insert(key); err != nil {
+		t.Fatal("Cannot insert key:", err)
+	}
+
+	fingerprint := key.GetFingerprint()
+	assert.FileExists(t, filepath.Join(store.Path(), fingerprint[:2], fingerprint[2:]))
+
+	stored, err := store.Get(strings.ToUpper(fingerprint))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	assert.False(t, stored.IsPrivate())
+	assert.Exactly(t, fingerprint, stored.GetFingerprint())
+
+	fingerprints, err := store.Fingerprints()
+	if err != nil {
+		t.Fatal("Cannot list keys:", err)
+	}
+	assert.Exactly(t, []string{fingerprint}, fingerprints)
+
+	updatedKey, err := key.AddUserID("certd", "", "other@example.com")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	if err := store.Insert(updatedKey); err != nil {
+		t.Fatal("Cannot insert key:", err)
+	}
+	if err := store.Insert(key); err != nil {
+		t.Fatal("Cannot insert key:", err)
+	}
+	stored, err = store.Get(fingerprint)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	assert.Len(t, stored.GetEntity().Identities, 2)
+}
+
+func TestImportExportKeyRing(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		key, err := crypto.GenerateKey(name, name+"@example.com", "x25519", 0)
+		if err != nil {
+			t.Fatal("Cannot generate key:", err)
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			t.Fatal("Cannot add key:", err)
+		}
+	}
+
+	if err := store.ImportKeyRing(keyRing); err != nil {
+		t.Fatal("Cannot import keyring:", err)
+	}
+
+	// Files outside of the layout are ignored
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(store.Path(), "writelock"), nil, 0600))
+
+	exported, err := store.ExportKeyRing()
+	if err != nil {
+		t.Fatal("Cannot export keyring:", err)
+	}
+	assert.Exactly(t, 2, exported.CountEntities())
+	assert.ElementsMatch(t, keyRing.GetKeyIDs(), exported.GetKeyIDs())
+	for _, key := range exported.GetKeys() {
+		assert.False(t, key.IsPrivate())
+	}
+}
+
+func TestInvalidFingerprint(t *testing.T) {
+	store := NewStore(t.TempDir())
+	_, err := store.Get("../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestNewDefaultStore(t *testing.T) {
+	defer os.Setenv(EnvVar, os.Getenv(EnvVar)) //nolint
+	assert.NoError(t, os.Setenv(EnvVar, "/tmp/certd-test"))
+
+	store, err := NewDefaultStore()
+	if err != nil {
+		t.Fatal("Cannot locate store:", err)
+	}
+	assert.Exactly(t, "/tmp/certd-test", store.Path())
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// runInspect describes the keys, message or signature of a file, or of the
+// standard input, without decrypting or verifying anything.
+func runInspect(args []string) error {
+	flags := newFlagSet("inspect")
+	_ = flags.Parse(args)
+
+	data, err := readInspected(flags)
+	if err != nil {
+		return err
+	}
+	if isArmored(data) {
+		if data, err = armor.Unarmor(string(data)); err != nil {
+			return err
+		}
+	}
+
+	p, err := packet.NewReader(bytes.NewReader(data)).Next()
+	if err != nil {
+		return fmt.Errorf("unable to read packet: %w", err)
+	}
+	switch p.(type) {
+	case *packet.PublicKey, *packet.PrivateKey:
+		return inspectKeys(data)
+	case *packet.Signature:
+		return inspectSignatures(data)
+	default:
+		return inspectMessage(data)
+	}
+}
+
+func readInspected(flags *flag.FlagSet) ([]byte, error) {
+	if flags.NArg() == 0 {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(flags.Arg(0))
+}
+
+func inspectKeys(data []byte) error {
+	entities, err := readEntities(data)
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		key, err := crypto.NewKeyFromEntity(entity)
+		if err != nil {
+			return err
+		}
+		info := key.GetKeyInfo()
+		kind := "public key"
+		if key.IsPrivate() {
+			kind = "private key"
+			if locked, err := key.IsLocked(); err == nil && locked {
+				kind = "locked private key"
+			}
+		}
+		fmt.Fprintf(stdout, "%s %s\n", kind, info.Fingerprint)
+		printKeyPacketInfo("  ", &info.KeyPacketInfo)
+		if info.IsExpired {
+			fmt.Fprintln(stdout, "  expired")
+		}
+		for _, userID := range info.UserIDs {
+			fmt.Fprintf(stdout, "  user ID: %s\n", userID)
+		}
+		for _, subkey := range info.Subkeys {
+			fmt.Fprintf(stdout, "  subkey %s\n", subkey.Fingerprint)
+			printKeyPacketInfo("    ", subkey)
+		}
+	}
+	return nil
+}
+
+func printKeyPacketInfo(indent string, info *crypto.KeyPacketInfo) {
+	algorithm := info.Algorithm
+	if info.Curve != "" {
+		algorithm += " " + info.Curve
+	} else if info.BitLength > 0 {
+		algorithm += fmt.Sprintf(" %d", info.BitLength)
+	}
+	fmt.Fprintf(stdout, "%skey ID: %s\n", indent, info.KeyID)
+	fmt.Fprintf(stdout, "%salgorithm: %s\n", indent, algorithm)
+	fmt.Fprintf(stdout, "%screated: %s\n", indent, formatTime(info.CreationTime))
+	if info.ExpirationTime != 0 {
+		fmt.Fprintf(stdout, "%sexpires: %s\n", indent, formatTime(info.ExpirationTime))
+	}
+
+	var usages []string
+	for _, usage := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"certify", info.CanCertify},
+		{"sign", info.CanSign},
+		{"encrypt", info.CanEncrypt},
+		{"authenticate", info.CanAuthenticate},
+	} {
+		if usage.enabled {
+			usages = append(usages, usage.name)
+		}
+	}
+	fmt.Fprintf(stdout, "%susage: %s\n", indent, strings.Join(usages, ", "))
+	if info.IsRevoked {
+		fmt.Fprintf(stdout, "%srevoked\n", indent)
+	}
+}
+
+func inspectSignatures(data []byte) error {
+	packets := packet.NewReader(bytes.NewReader(data))
+	for {
+		p, err := packets.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read signature: %w", err)
+		}
+		signature, ok := p.(*packet.Signature)
+		if !ok {
+			continue
+		}
+		kind := "binary"
+		if signature.SigType == packet.SigTypeText {
+			kind = "text"
+		}
+		fmt.Fprintf(stdout, "%s signature\n", kind)
+		if signature.IssuerKeyId != nil {
+			fmt.Fprintf(stdout, "  issuer key ID: %016x\n", *signature.IssuerKeyId)
+		}
+		if signature.IssuerFingerprint != nil {
+			fmt.Fprintf(stdout, "  issuer fingerprint: %x\n", signature.IssuerFingerprint)
+		}
+		fmt.Fprintf(stdout, "  created: %s\n", formatTime(signature.CreationTime.Unix()))
+		if signature.SigLifetimeSecs != nil && *signature.SigLifetimeSecs != 0 {
+			expiration := signature.CreationTime.Unix() + int64(*signature.SigLifetimeSecs)
+			fmt.Fprintf(stdout, "  expires: %s\n", formatTime(expiration))
+		}
+		fmt.Fprintf(stdout, "  hash: %s\n", signature.Hash)
+	}
+}
+
+func inspectMessage(data []byte) error {
+	annotation, err := crypto.NewMessageAnnotation(crypto.NewPGPMessage(data))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "encrypted message")
+	for i, keyID := range annotation.RecipientKeyIDs {
+		algorithm := ""
+		if i < len(annotation.PublicKeyAlgorithms) {
+			algorithm = " (" + annotation.PublicKeyAlgorithms[i] + ")"
+		}
+		fmt.Fprintf(stdout, "  recipient key ID: %s%s\n", keyID, algorithm)
+	}
+	if annotation.PasswordCount > 0 {
+		fmt.Fprintf(stdout, "  passwords: %d\n", annotation.PasswordCount)
+	}
+	protection := "none"
+	switch {
+	case annotation.AEAD:
+		protection = "AEAD"
+	case annotation.IntegrityProtected:
+		protection = "MDC"
+	}
+	fmt.Fprintf(stdout, "  integrity protection: %s\n", protection)
+	fmt.Fprintf(stdout, "  key packets: %d bytes\n", annotation.KeyPacketSize)
+	fmt.Fprintf(stdout, "  data packet: %d bytes\n", annotation.DataPacketSize)
+	return nil
+}
+
+// isTextSignature returns whether the first signature of signature is a text
+// signature.
+func isTextSignature(signature *crypto.PGPSignature) bool {
+	p, err := packet.NewReader(bytes.NewReader(signature.GetBinary())).Next()
+	if err != nil {
+		return false
+	}
+	sig, ok := p.(*packet.Signature)
+	return ok && sig.SigType == packet.SigTypeText
+}
+
+func formatTime(unixTime int64) string {
+	return time.Unix(unixTime, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,442 @@
+// Command gopenpgp exercises the library from the command line, going through
+// the same code paths as applications, e.g. to debug interoperability issues
+// without GnuPG.
+//
+//	gopenpgp keygen  -name NAME -email EMAIL [-type x25519|rsa] [-bits N] [-passphrase-file FILE]
+//	gopenpgp encrypt -recipient KEY... [-sign-key KEY [-passphrase-file FILE]] [-text] [-no-armor]
+//	gopenpgp decrypt -key KEY [-passphrase-file FILE] [-verify-key KEY...]
+//	gopenpgp sign    -key KEY [-passphrase-file FILE] [-text] [-no-armor]
+//	gopenpgp verify  -signature FILE -verify-key KEY...
+//	gopenpgp inspect [FILE]
+//
+// Data is read from the standard input and written to the standard output.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// Exit codes
+const (
+	exitError           = 1
+	exitUsage           = 2
+	exitBadSignature    = 3
+	exitMissingVerifier = 4
+)
+
+// Standard streams of the commands, replaced in tests
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+var commands = map[string]func(args []string) error{
+	"keygen":  runKeygen,
+	"encrypt": runEncrypt,
+	"decrypt": runDecrypt,
+	"sign":    runSign,
+	"verify":  runVerify,
+	"inspect": runInspect,
+}
+
+// codeError is an error with a specific exit code.
+type codeError struct {
+	code int
+	err  error
+}
+
+func (err *codeError) Error() string {
+	return err.err.Error()
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: gopenpgp keygen|encrypt|decrypt|sign|verify|inspect [options]")
+		os.Exit(exitUsage)
+	}
+	if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+		message := err.Error()
+		if !strings.HasPrefix(message, "gopenpgp: ") {
+			message = "gopenpgp: " + message
+		}
+		fmt.Fprintln(os.Stderr, message)
+		var codeErr *codeError
+		if errors.As(err, &codeErr) {
+			os.Exit(codeErr.code)
+		}
+		os.Exit(exitError)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("gopenpgp "+name, flag.ExitOnError)
+}
+
+func runKeygen(args []string) error {
+	flags := newFlagSet("keygen")
+	name := flags.String("name", "", "name of the user ID")
+	email := flags.String("email", "", "email of the user ID")
+	keyType := flags.String("type", "x25519", "key type, x25519 or rsa")
+	bits := flags.Int("bits", 3072, "size of RSA keys")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the passphrase to lock the key with")
+	_ = flags.Parse(args)
+
+	key, err := crypto.GenerateKey(*name, *email, *keyType, *bits)
+	if err != nil {
+		return err
+	}
+	if *passphraseFile != "" {
+		passphrase, err := readPassphrase(*passphraseFile)
+		if err != nil {
+			return err
+		}
+		if key, err = key.Lock(passphrase); err != nil {
+			return err
+		}
+	}
+	armored, err := key.Armor()
+	if err != nil {
+		return err
+	}
+	return writeString(armored)
+}
+
+func runEncrypt(args []string) error {
+	flags := newFlagSet("encrypt")
+	var recipients stringList
+	flags.Var(&recipients, "recipient", "file holding the public key of a recipient (repeatable)")
+	signKeyFile := flags.String("sign-key", "", "file holding the private key to sign with")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the passphrase of the signing key")
+	text := flags.Bool("text", false, "encrypt the data as text instead of binary, with canonical line endings")
+	noArmor := flags.Bool("no-armor", false, "output the binary message")
+	_ = flags.Parse(args)
+
+	if len(recipients) == 0 {
+		return &codeError{exitUsage, errors.New("no recipient given")}
+	}
+	encryptionKeyRing, err := readKeyRings(recipients)
+	if err != nil {
+		return err
+	}
+	var signKeyRing *crypto.KeyRing
+	if *signKeyFile != "" {
+		if signKeyRing, err = readPrivateKeyRing(*signKeyFile, *passphraseFile); err != nil {
+			return err
+		}
+	}
+
+	message, err := readPlainMessage(!*text)
+	if err != nil {
+		return err
+	}
+	pgpMessage, err := encryptionKeyRing.Encrypt(message, signKeyRing)
+	if err != nil {
+		return err
+	}
+	if *noArmor {
+		return writeBytes(pgpMessage.GetBinary())
+	}
+	armored, err := pgpMessage.GetArmored()
+	if err != nil {
+		return err
+	}
+	return writeString(armored)
+}
+
+func runDecrypt(args []string) error {
+	flags := newFlagSet("decrypt")
+	keyFile := flags.String("key", "", "file holding the private key to decrypt with")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the passphrase of the key")
+	var verifyKeys stringList
+	flags.Var(&verifyKeys, "verify-key", "file holding a public key to verify the signature with (repeatable)")
+	_ = flags.Parse(args)
+
+	if *keyFile == "" {
+		return &codeError{exitUsage, errors.New("no key given")}
+	}
+	keyRing, err := readPrivateKeyRing(*keyFile, *passphraseFile)
+	if err != nil {
+		return err
+	}
+	var verifyKeyRing *crypto.KeyRing
+	if len(verifyKeys) > 0 {
+		if verifyKeyRing, err = readKeyRings(verifyKeys); err != nil {
+			return err
+		}
+	}
+
+	pgpMessage, err := readPGPMessage()
+	if err != nil {
+		return err
+	}
+	message, err := keyRing.Decrypt(pgpMessage, verifyKeyRing, crypto.GetUnixTime())
+	var sigErr crypto.SignatureVerificationError
+	if errors.As(err, &sigErr) && message != nil {
+		if writeErr := writeBytes(message.GetBinary()); writeErr != nil {
+			return writeErr
+		}
+		return signatureError(sigErr)
+	}
+	if err != nil {
+		return err
+	}
+	if verifyKeyRing != nil {
+		fmt.Fprintln(stderr, "gopenpgp: signature OK")
+	}
+	return writeBytes(message.GetBinary())
+}
+
+func runSign(args []string) error {
+	flags := newFlagSet("sign")
+	keyFile := flags.String("key", "", "file holding the private key to sign with")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the passphrase of the key")
+	text := flags.Bool("text", false, "make a text signature, with canonical line endings")
+	noArmor := flags.Bool("no-armor", false, "output the binary signature")
+	_ = flags.Parse(args)
+
+	if *keyFile == "" {
+		return &codeError{exitUsage, errors.New("no key given")}
+	}
+	keyRing, err := readPrivateKeyRing(*keyFile, *passphraseFile)
+	if err != nil {
+		return err
+	}
+	message, err := readPlainMessage(!*text)
+	if err != nil {
+		return err
+	}
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		return err
+	}
+	if *noArmor {
+		return writeBytes(signature.GetBinary())
+	}
+	armored, err := signature.GetArmored()
+	if err != nil {
+		return err
+	}
+	return writeString(armored)
+}
+
+func runVerify(args []string) error {
+	flags := newFlagSet("verify")
+	signatureFile := flags.String("signature", "", "file holding the detached signature")
+	var verifyKeys stringList
+	flags.Var(&verifyKeys, "verify-key", "file holding a public key to verify the signature with (repeatable)")
+	_ = flags.Parse(args)
+
+	if *signatureFile == "" || len(verifyKeys) == 0 {
+		return &codeError{exitUsage, errors.New("a signature and a verification key are required")}
+	}
+	verifyKeyRing, err := readKeyRings(verifyKeys)
+	if err != nil {
+		return err
+	}
+	signatureData, err := ioutil.ReadFile(*signatureFile)
+	if err != nil {
+		return err
+	}
+	signature, err := readPGPSignature(signatureData)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+
+	// The signature type tells whether the data was signed as text
+	message := crypto.NewPlainMessage(data)
+	message.TextType = isTextSignature(signature)
+	err = verifyKeyRing.VerifyDetached(message, signature, crypto.GetUnixTime())
+	var sigErr crypto.SignatureVerificationError
+	if errors.As(err, &sigErr) {
+		return signatureError(sigErr)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stderr, "gopenpgp: signature OK")
+	return nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func signatureError(sigErr crypto.SignatureVerificationError) error {
+	if sigErr.Status == constants.SIGNATURE_NO_VERIFIER {
+		return &codeError{exitMissingVerifier, sigErr}
+	}
+	return &codeError{exitBadSignature, sigErr}
+}
+
+// readKeys reads all the keys of the files, armored or not.
+func readKeys(files []string) ([]*crypto.Key, error) {
+	var keys []*crypto.Key
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := readEntities(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read keys of %s: %w", file, err)
+		}
+		for _, entity := range entities {
+			key, err := crypto.NewKeyFromEntity(entity)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// readKeyRings reads the public keys of the files into a keyring. Private
+// keys are reduced to their public keys.
+func readKeyRings(files []string) (*crypto.KeyRing, error) {
+	keys, err := readKeys(files)
+	if err != nil {
+		return nil, err
+	}
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.IsPrivate() {
+			if key, err = key.ToPublic(); err != nil {
+				return nil, err
+			}
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
+}
+
+// readPrivateKeyRing reads the private keys of file, unlocking them with the
+// passphrase of passphraseFile if they are locked.
+func readPrivateKeyRing(file, passphraseFile string) (*crypto.KeyRing, error) {
+	keys, err := readKeys([]string{file})
+	if err != nil {
+		return nil, err
+	}
+	var passphrase []byte
+	if passphraseFile != "" {
+		if passphrase, err = readPassphrase(passphraseFile); err != nil {
+			return nil, err
+		}
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if !key.IsPrivate() {
+			return nil, fmt.Errorf("%s holds a public key", file)
+		}
+		locked, err := key.IsLocked()
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			if passphrase == nil {
+				return nil, fmt.Errorf("the key of %s is locked, a passphrase file is required", file)
+			}
+			if key, err = key.Unlock(passphrase); err != nil {
+				return nil, err
+			}
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
+}
+
+func readEntities(data []byte) (openpgp.EntityList, error) {
+	if isArmored(data) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// readPassphrase reads the passphrase of file, without the trailing line ending.
+func readPassphrase(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+func readPlainMessage(binary bool) (*crypto.PlainMessage, error) {
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		return crypto.NewPlainMessage(data), nil
+	}
+	return crypto.NewPlainMessageFromString(string(data)), nil
+}
+
+func readPGPMessage() (*crypto.PGPMessage, error) {
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if isArmored(data) {
+		return crypto.NewPGPMessageFromArmored(string(data))
+	}
+	return crypto.NewPGPMessage(data), nil
+}
+
+func readPGPSignature(data []byte) (*crypto.PGPSignature, error) {
+	if isArmored(data) {
+		return crypto.NewPGPSignatureFromArmored(string(data))
+	}
+	return crypto.NewPGPSignature(data), nil
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("-----BEGIN PGP "))
+}
+
+func writeString(s string) error {
+	return writeBytes([]byte(s + "\n"))
+}
+
+func writeBytes(data []byte) error {
+	_, err := stdout.Write(data)
+	return err
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run runs a command with input as its standard input, and returns its
+// standard output and error.
+func run(input []byte, args ...string) (output, errOutput []byte, err error) {
+	var outBuf, errBuf bytes.Buffer
+	stdin, stdout, stderr = bytes.NewReader(input), &outBuf, &errBuf
+	defer func() {
+		stdin, stdout, stderr = os.Stdin, os.Stdout, os.Stderr
+	}()
+
+	err = commands[args[0]](args[1:])
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// writeFile writes data to a file of dir, and returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal("Cannot write file:", err)
+	}
+	return path
+}
+
+// testKeys generates a locked private key with the keygen command, and writes
+// it to dir along with its passphrase. Private keys are reduced to their
+// public keys when given as recipients or verification keys.
+func testKeys(t *testing.T, dir, name string) (privateKey, passphrase string) {
+	passphrase = writeFile(t, dir, name+".pass", []byte("passphrase\n"))
+	key, _, err := run(nil, "keygen", "-name", name, "-email", name+"@example.com", "-passphrase-file", passphrase)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	return writeFile(t, dir, name+".key", key), passphrase
+}
+
+func assertExitCode(t *testing.T, code int, err error) {
+	var codeErr *codeError
+	if assert.True(t, errors.As(err, &codeErr), "unexpected error: %v", err) {
+		assert.Exactly(t, code, codeErr.code)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	aliceKey, alicePassphrase := testKeys(t, dir, "alice")
+	bobKey, bobPassphrase := testKeys(t, dir, "bob")
+
+	tests := []struct {
+		name        string
+		encryptArgs []string
+		decryptArgs []string
+		input       string
+		expected    string
+		exitCode    int
+		stderr      string
+	}{
+		{
+			name:     "binary by default",
+			input:    "line\r\nother line\n",
+			expected: "line\r\nother line\n",
+		},
+		{
+			name:        "text",
+			encryptArgs: []string{"-text"},
+			input:       "line\r\nother line\n",
+			expected:    "line\r\nother line\r\n",
+		},
+		{
+			name:        "unarmored",
+			encryptArgs: []string{"-no-armor"},
+			input:       "\x00\x01\x02",
+			expected:    "\x00\x01\x02",
+		},
+		{
+			name:        "signed",
+			encryptArgs: []string{"-sign-key", bobKey, "-passphrase-file", bobPassphrase},
+			decryptArgs: []string{"-verify-key", bobKey},
+			input:       "signed",
+			expected:    "signed",
+			stderr:      "gopenpgp: signature OK\n",
+		},
+		{
+			name:        "signed by another key",
+			encryptArgs: []string{"-sign-key", bobKey, "-passphrase-file", bobPassphrase},
+			decryptArgs: []string{"-verify-key", aliceKey},
+			input:       "signed",
+			expected:    "signed",
+			exitCode:    exitMissingVerifier,
+		},
+		{
+			name:        "not signed",
+			decryptArgs: []string{"-verify-key", bobKey},
+			input:       "not signed",
+			expected:    "not signed",
+			exitCode:    exitBadSignature,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := append([]string{"encrypt", "-recipient", aliceKey}, test.encryptArgs...)
+			encrypted, _, err := run([]byte(test.input), args...)
+			if err != nil {
+				t.Fatal("Cannot encrypt:", err)
+			}
+
+			args = append([]string{"decrypt", "-key", aliceKey, "-passphrase-file", alicePassphrase}, test.decryptArgs...)
+			decrypted, errOutput, err := run(encrypted, args...)
+			if test.exitCode != 0 {
+				assertExitCode(t, test.exitCode, err)
+			} else if err != nil {
+				t.Fatal("Cannot decrypt:", err)
+			}
+			assert.Exactly(t, test.expected, string(decrypted))
+			assert.Exactly(t, test.stderr, string(errOutput))
+		})
+	}
+
+	_, _, err := run([]byte("data"), "encrypt")
+	assertExitCode(t, exitUsage, err)
+
+	// The key is locked
+	encrypted, _, err := run([]byte("data"), "encrypt", "-recipient", aliceKey)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	_, _, err = run(encrypted, "decrypt", "-key", aliceKey)
+	assert.NotNil(t, err)
+	_, _, err = run(encrypted, "decrypt", "-key", bobKey, "-passphrase-file", bobPassphrase)
+	assert.NotNil(t, err)
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	key, passphrase := testKeys(t, dir, "alice")
+	otherKey, otherPassphrase := testKeys(t, dir, "bob")
+
+	tests := []struct {
+		name     string
+		signArgs []string
+		signKey  []string
+		data     string
+		verified string
+		exitCode int
+	}{
+		{
+			name:     "binary",
+			data:     "line\r\n",
+			verified: "line\r\n",
+		},
+		{
+			name:     "binary with other line endings",
+			data:     "line\r\n",
+			verified: "line\n",
+			exitCode: exitBadSignature,
+		},
+		{
+			name:     "text with other line endings",
+			signArgs: []string{"-text"},
+			data:     "line\r\n",
+			verified: "line\n",
+		},
+		{
+			name:     "unarmored",
+			signArgs: []string{"-no-armor"},
+			data:     "data",
+			verified: "data",
+		},
+		{
+			name:     "tampered",
+			data:     "data",
+			verified: "tampered",
+			exitCode: exitBadSignature,
+		},
+		{
+			name:     "other signer",
+			signKey:  []string{"-key", otherKey, "-passphrase-file", otherPassphrase},
+			data:     "data",
+			verified: "data",
+			exitCode: exitBadSignature,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signKey := test.signKey
+			if signKey == nil {
+				signKey = []string{"-key", key, "-passphrase-file", passphrase}
+			}
+			signature, _, err := run([]byte(test.data), append(append([]string{"sign"}, signKey...), test.signArgs...)...)
+			if err != nil {
+				t.Fatal("Cannot sign:", err)
+			}
+			signatureFile := writeFile(t, dir, "signature", signature)
+
+			_, errOutput, err := run([]byte(test.verified), "verify", "-signature", signatureFile, "-verify-key", key)
+			if test.exitCode != 0 {
+				assertExitCode(t, test.exitCode, err)
+				return
+			}
+			if err != nil {
+				t.Fatal("Cannot verify:", err)
+			}
+			assert.Exactly(t, "gopenpgp: signature OK\n", string(errOutput))
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := testKeys(t, dir, "alice")
+
+	output, _, err := run(nil, "inspect", key)
+	if err != nil {
+		t.Fatal("Cannot inspect key:", err)
+	}
+	assert.True(t, strings.HasPrefix(string(output), "locked private key "))
+	assert.Contains(t, string(output), "user ID: alice <alice@example.com>")
+
+	encrypted, _, err := run([]byte("data"), "encrypt", "-recipient", key)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	output, _, err = run(encrypted, "inspect")
+	if err != nil {
+		t.Fatal("Cannot inspect message:", err)
+	}
+	assert.True(t, strings.HasPrefix(string(output), "encrypted message\n"))
+}
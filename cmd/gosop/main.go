@@ -0,0 +1,499 @@
+// Command gosop is a Stateless OpenPGP (SOP) command line interface, backed by
+// gopenpgp, as used by the OpenPGP interoperability test suite.
+//
+//	gosop SUBCOMMAND [OPTIONS] [ARGS]
+//
+// Indirect inputs and outputs are file paths, or @ENV:NAME for environment
+// variables and @FD:N for file descriptors. The exit code is the one of the
+// specification for the error, if any.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/sop"
+)
+
+// options holds the parsed options of a subcommand, by name, and its
+// positional arguments.
+type options struct {
+	values map[string][]string
+	flags  map[string]bool
+	args   []string
+}
+
+type subcommand struct {
+	// valueOptions and flagOptions are the options the subcommand accepts.
+	valueOptions []string
+	flagOptions  []string
+	run          func(opts *options) error
+}
+
+var subcommands = map[string]*subcommand{
+	"version": {
+		flagOptions: []string{"backend", "extended"},
+		run:         runVersion,
+	},
+	"generate-key": {
+		valueOptions: []string{"with-key-password"},
+		flagOptions:  []string{"no-armor"},
+		run:          runGenerateKey,
+	},
+	"extract-cert": {
+		flagOptions: []string{"no-armor"},
+		run:         runExtractCert,
+	},
+	"sign": {
+		valueOptions: []string{"as", "with-key-password"},
+		flagOptions:  []string{"no-armor"},
+		run:          runSign,
+	},
+	"verify": {
+		valueOptions: []string{"not-before", "not-after"},
+		run:          runVerify,
+	},
+	"encrypt": {
+		valueOptions: []string{"as", "with-password", "sign-with", "with-key-password"},
+		flagOptions:  []string{"no-armor"},
+		run:          runEncrypt,
+	},
+	"decrypt": {
+		valueOptions: []string{
+			"session-key-out", "with-session-key", "with-password", "with-key-password",
+			"verify-out", "verify-with", "not-before", "not-after",
+		},
+		run: runDecrypt,
+	},
+	"armor": {
+		run: runArmor,
+	},
+	"dearmor": {
+		run: runDearmor,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		exit(usageError(sop.ExitUnsupportedSubcommand, "missing subcommand"))
+	}
+	command, ok := subcommands[os.Args[1]]
+	if !ok {
+		exit(usageError(sop.ExitUnsupportedSubcommand, "unsupported subcommand "+os.Args[1]))
+	}
+	opts, err := parseOptions(command, os.Args[2:])
+	if err != nil {
+		exit(err)
+	}
+	exit(command.run(opts))
+}
+
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+	fmt.Fprintln(os.Stderr, "gosop:", err)
+	var sopErr *sop.Error
+	if errors.As(err, &sopErr) {
+		os.Exit(sopErr.Code)
+	}
+	os.Exit(1)
+}
+
+func usageError(code int, message string) error {
+	return &sop.Error{Code: code, Err: errors.New(message)}
+}
+
+func parseOptions(command *subcommand, arguments []string) (*options, error) {
+	opts := &options{values: map[string][]string{}, flags: map[string]bool{}}
+	for i, argument := range arguments {
+		if argument == "--" {
+			opts.args = append(opts.args, arguments[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(argument, "--") {
+			opts.args = append(opts.args, argument)
+			continue
+		}
+		name, value := argument[2:], ""
+		hasValue := false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+		switch {
+		case hasValue && contains(command.valueOptions, name):
+			opts.values[name] = append(opts.values[name], value)
+		case !hasValue && contains(command.flagOptions, name):
+			opts.flags[name] = true
+		default:
+			return nil, usageError(sop.ExitUnsupportedOption, "unsupported option "+argument)
+		}
+	}
+	return opts, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts *options) value(name string) string {
+	values := opts.values[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// asText parses the --as option, binary by default.
+func (opts *options) asText() (bool, error) {
+	switch opts.value("as") {
+	case "", "binary":
+		return false, nil
+	case "text":
+		return true, nil
+	default:
+		return false, usageError(sop.ExitUnsupportedOption, "unsupported --as="+opts.value("as"))
+	}
+}
+
+// readInputs reads the indirect inputs of an option or of the arguments.
+func readInputs(names []string) ([][]byte, error) {
+	inputs := make([][]byte, 0, len(names))
+	for _, name := range names {
+		input, err := readInput(name)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+func readInput(name string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(name, "@ENV:"):
+		value, ok := os.LookupEnv(strings.TrimPrefix(name, "@ENV:"))
+		if !ok {
+			return nil, usageError(sop.ExitMissingInput, "missing environment variable "+name)
+		}
+		return []byte(value), nil
+	case strings.HasPrefix(name, "@FD:"):
+		file, err := openFD(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ioutil.ReadAll(file)
+	case strings.HasPrefix(name, "@"):
+		return nil, usageError(sop.ExitUnsupportedSpecialPrefix, "unsupported special prefix "+name)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, usageError(sop.ExitMissingInput, err.Error())
+	}
+	return data, nil
+}
+
+// writeOutput writes an indirect output, which must not exist if it's a file.
+func writeOutput(name string, data []byte) error {
+	if strings.HasPrefix(name, "@FD:") {
+		file, err := openFD(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = file.Write(data)
+		return err
+	}
+	if strings.HasPrefix(name, "@") {
+		return usageError(sop.ExitUnsupportedSpecialPrefix, "unsupported special prefix "+name)
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return usageError(sop.ExitOutputExists, "output "+name+" already exists")
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+func openFD(name string) (*os.File, error) {
+	fd, err := strconv.Atoi(strings.TrimPrefix(name, "@FD:"))
+	if err != nil || fd < 0 {
+		return nil, usageError(sop.ExitUnsupportedSpecialPrefix, "invalid file descriptor "+name)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// readDate parses a --not-before or --not-after date: "-" leaves the bound
+// open, "now" is the current time.
+func readDate(value string, defaultValue time.Time) (time.Time, error) {
+	switch value {
+	case "":
+		return defaultValue, nil
+	case "-":
+		return time.Time{}, nil
+	case "now":
+		return time.Now(), nil
+	}
+	for _, layout := range []string{time.RFC3339, "20060102T150405Z", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, usageError(sop.ExitUnsupportedOption, "invalid date "+value)
+}
+
+func readTimeWindow(opts *options) (notBefore, notAfter time.Time, err error) {
+	if notBefore, err = readDate(opts.value("not-before"), time.Time{}); err != nil {
+		return
+	}
+	notAfter, err = readDate(opts.value("not-after"), time.Now())
+	return
+}
+
+func readStdin() ([]byte, error) {
+	return ioutil.ReadAll(os.Stdin)
+}
+
+func writeStdout(data []byte) error {
+	_, err := os.Stdout.Write(data)
+	return err
+}
+
+func formatVerifications(verifications []*sop.Verification) []byte {
+	var builder strings.Builder
+	for _, verification := range verifications {
+		builder.WriteString(verification.String())
+		builder.WriteString("\n")
+	}
+	return []byte(builder.String())
+}
+
+func runVersion(opts *options) error {
+	return writeStdout([]byte(sop.Version() + "\n"))
+}
+
+func runGenerateKey(opts *options) error {
+	var password []byte
+	if name := opts.value("with-key-password"); name != "" {
+		var err error
+		if password, err = readInput(name); err != nil {
+			return err
+		}
+	}
+	key, err := sop.GenerateKey(opts.args, password, !opts.flags["no-armor"])
+	if err != nil {
+		return err
+	}
+	return writeStdout(key)
+}
+
+func runExtractCert(opts *options) error {
+	keys, err := readStdin()
+	if err != nil {
+		return err
+	}
+	cert, err := sop.ExtractCert(keys, !opts.flags["no-armor"])
+	if err != nil {
+		return err
+	}
+	return writeStdout(cert)
+}
+
+func runSign(opts *options) error {
+	asText, err := opts.asText()
+	if err != nil {
+		return err
+	}
+	keys, err := readInputs(opts.args)
+	if err != nil {
+		return err
+	}
+	keyPasswords, err := readInputs(opts.values["with-key-password"])
+	if err != nil {
+		return err
+	}
+	data, err := readStdin()
+	if err != nil {
+		return err
+	}
+	signatures, err := sop.Sign(keys, data, &sop.SignOptions{
+		AsText:       asText,
+		NoArmor:      opts.flags["no-armor"],
+		KeyPasswords: keyPasswords,
+	})
+	if err != nil {
+		return err
+	}
+	return writeStdout(signatures)
+}
+
+func runVerify(opts *options) error {
+	if len(opts.args) < 2 {
+		return usageError(sop.ExitMissingArg, "signatures and certificates are required")
+	}
+	signatures, err := readInput(opts.args[0])
+	if err != nil {
+		return err
+	}
+	certs, err := readInputs(opts.args[1:])
+	if err != nil {
+		return err
+	}
+	notBefore, notAfter, err := readTimeWindow(opts)
+	if err != nil {
+		return err
+	}
+	data, err := readStdin()
+	if err != nil {
+		return err
+	}
+	verifications, err := sop.Verify(signatures, certs, data, &sop.VerifyOptions{
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+	})
+	if err != nil {
+		return err
+	}
+	return writeStdout(formatVerifications(verifications))
+}
+
+func runEncrypt(opts *options) error {
+	asText, err := opts.asText()
+	if err != nil {
+		return err
+	}
+	certs, err := readInputs(opts.args)
+	if err != nil {
+		return err
+	}
+	passwords, err := readInputs(opts.values["with-password"])
+	if err != nil {
+		return err
+	}
+	signKeys, err := readInputs(opts.values["sign-with"])
+	if err != nil {
+		return err
+	}
+	keyPasswords, err := readInputs(opts.values["with-key-password"])
+	if err != nil {
+		return err
+	}
+	data, err := readStdin()
+	if err != nil {
+		return err
+	}
+	encrypted, err := sop.Encrypt(certs, data, &sop.EncryptOptions{
+		AsText:       asText,
+		NoArmor:      opts.flags["no-armor"],
+		Passwords:    passwords,
+		SignKeys:     signKeys,
+		KeyPasswords: keyPasswords,
+	})
+	if err != nil {
+		return err
+	}
+	return writeStdout(encrypted)
+}
+
+func runDecrypt(opts *options) error {
+	verifyOut := opts.value("verify-out")
+	verifyCertNames := opts.values["verify-with"]
+	if verifyOut != "" && len(verifyCertNames) == 0 {
+		return usageError(sop.ExitIncompatibleOptions, "--verify-out requires --verify-with")
+	}
+
+	keys, err := readInputs(opts.args)
+	if err != nil {
+		return err
+	}
+	sessionKeys, err := readInputs(opts.values["with-session-key"])
+	if err != nil {
+		return err
+	}
+	passwords, err := readInputs(opts.values["with-password"])
+	if err != nil {
+		return err
+	}
+	keyPasswords, err := readInputs(opts.values["with-key-password"])
+	if err != nil {
+		return err
+	}
+	verifyCerts, err := readInputs(verifyCertNames)
+	if err != nil {
+		return err
+	}
+	notBefore, notAfter, err := readTimeWindow(opts)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := readStdin()
+	if err != nil {
+		return err
+	}
+
+	decryptOptions := &sop.DecryptOptions{
+		Passwords:    passwords,
+		VerifyCerts:  verifyCerts,
+		KeyPasswords: keyPasswords,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	for _, sessionKey := range sessionKeys {
+		decryptOptions.SessionKeys = append(decryptOptions.SessionKeys, string(sessionKey))
+	}
+	result, err := sop.Decrypt(keys, ciphertext, decryptOptions)
+	if err != nil {
+		return err
+	}
+
+	if name := opts.value("session-key-out"); name != "" {
+		if err := writeOutput(name, []byte(result.SessionKey+"\n")); err != nil {
+			return err
+		}
+	}
+	if verifyOut != "" {
+		if err := writeOutput(verifyOut, formatVerifications(result.Verifications)); err != nil {
+			return err
+		}
+	}
+	return writeStdout(result.Data)
+}
+
+func runArmor(opts *options) error {
+	data, err := readStdin()
+	if err != nil {
+		return err
+	}
+	armored, err := sop.Armor(data)
+	if err != nil {
+		return err
+	}
+	return writeStdout(armored)
+}
+
+func runDearmor(opts *options) error {
+	data, err := readStdin()
+	if err != nil {
+		return err
+	}
+	dearmored, err := sop.Dearmor(data)
+	if err != nil {
+		return err
+	}
+	return writeStdout(dearmored)
+}
@@ -3,10 +3,11 @@ package constants
 
 // Constants for armored data.
 const (
-	ArmorHeaderVersion = "GopenPGP 2.4.8"
-	ArmorHeaderComment = "https://gopenpgp.org"
-	PGPMessageHeader   = "PGP MESSAGE"
-	PGPSignatureHeader = "PGP SIGNATURE"
-	PublicKeyHeader    = "PGP PUBLIC KEY BLOCK"
-	PrivateKeyHeader   = "PGP PRIVATE KEY BLOCK"
+	ArmorHeaderVersion     = "GopenPGP 2.4.8"
+	ArmorHeaderComment     = "https://gopenpgp.org"
+	PGPMessageHeader       = "PGP MESSAGE"
+	PGPSignedMessageHeader = "PGP SIGNED MESSAGE"
+	PGPSignatureHeader     = "PGP SIGNATURE"
+	PublicKeyHeader        = "PGP PUBLIC KEY BLOCK"
+	PrivateKeyHeader       = "PGP PRIVATE KEY BLOCK"
 )
@@ -0,0 +1,9 @@
+package constants
+
+// Certification levels, as defined in RFC 4880, section 5.2.1.
+const (
+	CertificationGeneric  int = 0x10
+	CertificationPersona  int = 0x11
+	CertificationCasual   int = 0x12
+	CertificationPositive int = 0x13
+)
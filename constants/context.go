@@ -0,0 +1,5 @@
+package constants
+
+// SignatureContextName is the name of the notation holding the context of a
+// signature, which binds the signature to the purpose it was made for.
+const SignatureContextName = "context@proton.ch"
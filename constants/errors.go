@@ -0,0 +1,20 @@
+package constants
+
+// Error codes returned by crypto.GetErrorCode, stable across versions, for
+// the mobile bindings where errors are flattened into strings.
+const (
+	ErrorCodeNone                int = 0
+	ErrorCodeUnknown             int = 1
+	ErrorCodeNoDecryptionKey     int = 2
+	ErrorCodeIncorrectPassphrase int = 3
+	ErrorCodeInvalidArmor        int = 4
+	ErrorCodeMessageModified     int = 5
+	ErrorCodeMessageTruncated    int = 6
+	ErrorCodeUnprotectedMessage  int = 7
+	ErrorCodeSignatureNotSigned  int = 8
+	ErrorCodeSignatureNoVerifier int = 9
+	ErrorCodeSignatureFailed     int = 10
+	ErrorCodeSignatureExpired    int = 11
+	ErrorCodeTooManyAttempts     int = 12
+	ErrorCodeInvalidUTF8         int = 13
+)
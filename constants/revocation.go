@@ -0,0 +1,10 @@
+package constants
+
+// Reasons for revocation, as defined in RFC 4880, section 5.2.3.23.
+const (
+	RevocationNoReason       int = 0
+	RevocationKeySuperseded  int = 1
+	RevocationKeyCompromised int = 2
+	RevocationKeyRetired     int = 3
+	RevocationUserIDNotValid int = 32
+)
@@ -0,0 +1,7 @@
+package constants
+
+// Types of signatures over a document, as defined in RFC 4880, section 5.2.1.
+const (
+	SignatureTypeBinary int = 0x00
+	SignatureTypeText   int = 0x01
+)
@@ -0,0 +1,5 @@
+package constants
+
+// TimestampDigestName is the name of the notation holding the hash algorithm
+// ID and the digest of the document timestamped by a timestamp signature.
+const TimestampDigestName = "timestamp-digest@proton.ch"
@@ -0,0 +1,13 @@
+package constants
+
+// Trust levels of the owner of a key, and validity levels of a key, with the
+// values used by GnuPG.
+const (
+	TrustUnknown   int = 0
+	TrustExpired   int = 1
+	TrustUndefined int = 2
+	TrustNever     int = 3
+	TrustMarginal  int = 4
+	TrustFull      int = 5
+	TrustUltimate  int = 6
+)
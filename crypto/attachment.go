@@ -3,10 +3,6 @@ package crypto
 import (
 	"bytes"
 	"io"
-	"io/ioutil"
-	"runtime"
-	"sync"
-	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
@@ -16,103 +12,110 @@ import (
 // AttachmentProcessor keeps track of the progress of encrypting an attachment
 // (optimized for encrypting large files).
 type AttachmentProcessor struct {
-	w                *io.WriteCloser
-	pipe             *io.PipeWriter
-	done             sync.WaitGroup
-	split            *PGPSplitMessage
-	garbageCollector int
-	err              error
+	processor  *StreamingAttachmentProcessor
+	dataPacket *spillBuffer
+	gc         *garbageCollector
 }
 
 // Process writes attachment data to be encrypted.
 func (ap *AttachmentProcessor) Process(plainData []byte) {
-	if _, err := (*ap.w).Write(plainData); err != nil {
+	if _, err := writeChunked(ap.processor, plainData); err != nil {
+		ap.Abort()
 		panic(err)
 	}
-	if ap.garbageCollector > 0 {
-		defer runtime.GC()
-	}
+	ap.gc.process(len(plainData))
 }
 
 // Finish closes the attachment and returns the encrypted data.
 func (ap *AttachmentProcessor) Finish() (*PGPSplitMessage, error) {
-	if ap.err != nil {
-		return nil, ap.err
+	var dataPacket []byte
+	keyPacket, err := ap.finish(func(buffer *spillBuffer) (err error) {
+		dataPacket, err = buffer.readAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &PGPSplitMessage{KeyPacket: keyPacket, DataPacket: dataPacket}, nil
+}
 
-	if err := (*ap.w).Close(); err != nil {
-		return nil, errors.Wrap(err, "gopengpp: unable to close writer")
-	}
+// FinishTo closes the attachment, writes the encrypted data packet to
+// dataPacketWriter and returns the key packet. In the memory-limited mode,
+// the data packet is copied from the temporary file, instead of being read
+// back in memory.
+func (ap *AttachmentProcessor) FinishTo(dataPacketWriter Writer) ([]byte, error) {
+	return ap.finish(func(buffer *spillBuffer) error {
+		dataPacketReader, err := buffer.Reader()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dataPacketWriter, dataPacketReader)
+		return err
+	})
+}
 
-	if ap.garbageCollector > 0 {
-		ap.w = nil
-		runtime.GC()
+// Abort stops the encryption of the attachment, and removes its temporary
+// file, if any. It does nothing once the processor is finished.
+func (ap *AttachmentProcessor) Abort() {
+	if ap.dataPacket != nil {
+		_ = ap.dataPacket.Close()
+		ap.dataPacket = nil
 	}
+	ap.processor = nil
+}
 
-	if err := (*ap.pipe).Close(); err != nil {
-		return nil, errors.Wrap(err, "gopengpp: unable to close pipe")
+// finish closes the attachment, passes the buffered data packet to
+// readDataPacket and returns the key packet. The temporary file of the data
+// packet, if any, is removed on every path.
+func (ap *AttachmentProcessor) finish(readDataPacket func(*spillBuffer) error) ([]byte, error) {
+	if ap.dataPacket == nil {
+		return nil, errors.New("gopenpgp: attachment processor already finished")
 	}
+	defer ap.Abort()
 
-	ap.done.Wait()
-	if ap.err != nil {
-		return nil, ap.err
+	if err := ap.processor.Finish(); err != nil {
+		return nil, err
 	}
-	splitMsg := ap.split
-
-	if ap.garbageCollector > 0 {
-		ap.pipe = nil
-		ap.split = nil
-		defer runtime.GC()
+	keyPacket, err := ap.processor.GetKeyPacket()
+	if err != nil {
+		return nil, err
+	}
+	if err = readDataPacket(ap.dataPacket); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read the data packet")
 	}
-	return splitMsg, nil
+
+	ap.gc.collect()
+	return keyPacket, nil
 }
 
 // newAttachmentProcessor creates an AttachmentProcessor which can be used to encrypt
 // a file. It takes an estimatedSize and fileName as hints about the file.
+// It collects garbage every lowMemoryInterval bytes, if not 0, or every
+// memory budget in the memory-limited mode.
 func (keyRing *KeyRing) newAttachmentProcessor(
-	estimatedSize int, filename string, isBinary bool, modTime uint32, garbageCollector int, //nolint:unparam
+	estimatedSize int, filename string, isBinary bool, modTime uint32, lowMemoryInterval int64,
 ) (*AttachmentProcessor, error) {
-	attachmentProc := &AttachmentProcessor{}
-	// You could also add these one at a time if needed.
-	attachmentProc.done.Add(1)
-	attachmentProc.garbageCollector = garbageCollector
-
-	hints := &openpgp.FileHints{
-		FileName: filename,
-		IsBinary: isBinary,
-		ModTime:  time.Unix(int64(modTime), 0),
-	}
-
-	config := &packet.Config{
-		DefaultCipher: packet.CipherAES256,
-		Time:          getTimeGenerator(),
+	sizeHint := 0
+	if estimatedSize > 0 {
+		sizeHint = estimatedSize + encryptionOverhead + len(filename)
 	}
+	dataPacket := newSpillBuffer(sizeHint)
 
-	reader, writer := io.Pipe()
-
-	go func() {
-		defer attachmentProc.done.Done()
-		ciphertext, _ := ioutil.ReadAll(reader)
-		message := &PGPMessage{
-			Data: ciphertext,
-		}
-		split, splitError := message.SplitMessage()
-		if attachmentProc.err == nil {
-			attachmentProc.err = splitError
-		}
-		attachmentProc.split = split
-	}()
-
-	var ew io.WriteCloser
-	var encryptErr error
-	ew, encryptErr = openpgp.Encrypt(writer, keyRing.entities, nil, hints, config)
-	if encryptErr != nil {
-		return nil, errors.Wrap(encryptErr, "gopengpp: unable to encrypt attachment")
+	processor, err := keyRing.NewStreamingAttachmentProcessor(
+		dataPacket,
+		NewPlainMessageMetadata(isBinary, filename, int64(modTime)),
+		0,
+	)
+	if err != nil {
+		_ = dataPacket.Close()
+		return nil, err
 	}
-	attachmentProc.w = &ew
-	attachmentProc.pipe = writer
 
-	return attachmentProc, nil
+	return &AttachmentProcessor{
+		processor:  processor,
+		dataPacket: dataPacket,
+		gc:         newGarbageCollector(lowMemoryInterval),
+	}, nil
 }
 
 // EncryptAttachment encrypts a file given a PlainMessage and a filename.
@@ -129,11 +132,12 @@ func (keyRing *KeyRing) EncryptAttachment(message *PlainMessage, filename string
 		filename,
 		message.IsBinary(),
 		message.Time,
-		-1,
+		0,
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer ap.Abort()
 	ap.Process(message.GetBinary())
 	split, err := ap.Finish()
 	if err != nil {
@@ -145,18 +149,33 @@ func (keyRing *KeyRing) EncryptAttachment(message *PlainMessage, filename string
 // NewLowMemoryAttachmentProcessor creates an AttachmentProcessor which can be used
 // to encrypt a file. It takes an estimatedSize and filename as hints about the
 // file. It is optimized for low-memory environments and collects garbage every
-// megabyte.
+// megabyte, or every memory budget set with SetMemoryBudget.
 func (keyRing *KeyRing) NewLowMemoryAttachmentProcessor(
 	estimatedSize int, filename string,
 ) (*AttachmentProcessor, error) {
-	return keyRing.newAttachmentProcessor(estimatedSize, filename, true, uint32(GetUnixTime()), 1<<20)
+	return keyRing.newAttachmentProcessor(estimatedSize, filename, true, uint32(GetUnixTime()), lowMemoryCollectionInterval)
 }
 
 // DecryptAttachment takes a PGPSplitMessage, containing a session key packet and symmetrically encrypted data
 // and returns a decrypted PlainMessage
 // Specifically designed for attachments rather than text messages.
 func (keyRing *KeyRing) DecryptAttachment(message *PGPSplitMessage) (*PlainMessage, error) {
-	privKeyEntries := keyRing.entities
+	defer keyRing.use()()
+	privKeyEntries := keyRing.getEntities()
+
+	// go-crypto can't parse packets without integrity protection, which are
+	// recognized by their tag
+	if dataPacket := message.GetBinaryDataPacket(); len(dataPacket) > 0 &&
+		readRawPacketTag(dataPacket[0]) == packetTagSymmetricallyEncrypted {
+		if !allowUnprotectedMessages() {
+			return nil, errUnprotectedMessage
+		}
+		sessionKey, err := keyRing.DecryptSessionKey(message.GetBinaryKeyPacket())
+		if err != nil {
+			return nil, err
+		}
+		return sessionKey.Decrypt(dataPacket)
+	}
 
 	keyReader := bytes.NewReader(message.GetBinaryKeyPacket())
 	dataReader := bytes.NewReader(message.GetBinaryDataPacket())
@@ -171,7 +190,7 @@ func (keyRing *KeyRing) DecryptAttachment(message *PGPSplitMessage) (*PlainMessa
 	}
 
 	decrypted := md.UnverifiedBody
-	b, err := ioutil.ReadAll(decrypted)
+	b, err := readAllWithSizeHint(decrypted, len(message.GetBinaryDataPacket()))
 	if err != nil {
 		return nil, errors.Wrap(err, "gopengpp: unable to read attachment body")
 	}
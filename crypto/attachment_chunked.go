@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ChunkedAttachmentEncryptor encrypts an attachment in chunks of chunkSize
+// bytes, each in its own data packet, with a session key shared by all chunks.
+// The chunks are independent: they can be encrypted in any order, in
+// parallel, and encrypted again to resume a failed upload.
+// The literal data of each chunk is named after its index and the number of
+// chunks, so that the chunks can't be reordered or truncated undetected.
+type ChunkedAttachmentEncryptor struct {
+	sessionKey     *SessionKey
+	keyPacket      []byte
+	attachmentSize int
+	chunkSize      int
+	modTime        uint32
+}
+
+// NewChunkedAttachmentEncryptor creates a ChunkedAttachmentEncryptor for an
+// attachment of attachmentSize bytes, with a new session key encrypted to
+// keyRing.
+func (keyRing *KeyRing) NewChunkedAttachmentEncryptor(
+	attachmentSize, chunkSize int,
+) (*ChunkedAttachmentEncryptor, error) {
+	sessionKey, err := GenerateSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	keyPacket, err := keyRing.EncryptSessionKey(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewChunkedAttachmentEncryptorWithSessionKey(sessionKey, keyPacket, attachmentSize, chunkSize)
+}
+
+// NewChunkedAttachmentEncryptorWithSessionKey creates a
+// ChunkedAttachmentEncryptor with an existing session key and its key packet,
+// e.g. to resume the upload of an attachment.
+func NewChunkedAttachmentEncryptorWithSessionKey(
+	sessionKey *SessionKey, keyPacket []byte, attachmentSize, chunkSize int,
+) (*ChunkedAttachmentEncryptor, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("gopenpgp: invalid attachment chunk size")
+	}
+	if attachmentSize < 0 {
+		return nil, errors.New("gopenpgp: invalid attachment size")
+	}
+	return &ChunkedAttachmentEncryptor{
+		sessionKey:     sessionKey,
+		keyPacket:      clone(keyPacket),
+		attachmentSize: attachmentSize,
+		chunkSize:      chunkSize,
+		modTime:        uint32(GetUnixTime()),
+	}, nil
+}
+
+// GetKeyPacket returns the key packet holding the session key of the chunks.
+func (e *ChunkedAttachmentEncryptor) GetKeyPacket() []byte {
+	return e.keyPacket
+}
+
+// GetSessionKey returns the session key of the chunks.
+func (e *ChunkedAttachmentEncryptor) GetSessionKey() *SessionKey {
+	return e.sessionKey
+}
+
+// CountChunks returns the number of chunks of the attachment.
+func (e *ChunkedAttachmentEncryptor) CountChunks() int {
+	return countChunks(e.attachmentSize, e.chunkSize)
+}
+
+// GetChunk returns the chunk with the given index of the attachment data.
+func (e *ChunkedAttachmentEncryptor) GetChunk(attachment []byte, index int) ([]byte, error) {
+	if len(attachment) != e.attachmentSize {
+		return nil, errors.New("gopenpgp: unexpected attachment size")
+	}
+	start, end, err := chunkBounds(index, e.attachmentSize, e.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return attachment[start:end], nil
+}
+
+// EncryptChunk encrypts the chunk with the given index, and returns its data
+// packet.
+func (e *ChunkedAttachmentEncryptor) EncryptChunk(index int, chunk []byte) ([]byte, error) {
+	start, end, err := chunkBounds(index, e.attachmentSize, e.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk) != end-start {
+		return nil, errors.New("gopenpgp: unexpected attachment chunk size")
+	}
+	return e.sessionKey.Encrypt(&PlainMessage{
+		Data:     chunk,
+		TextType: false,
+		Filename: chunkName(index, e.CountChunks()),
+		Time:     e.modTime,
+	})
+}
+
+// DecryptAttachmentChunk decrypts the data packet of the chunk with the given
+// index, out of count chunks.
+func (sk *SessionKey) DecryptAttachmentChunk(index, count int, dataPacket []byte) ([]byte, error) {
+	message, err := sk.Decrypt(dataPacket)
+	if err != nil {
+		return nil, err
+	}
+	if message.Filename != chunkName(index, count) {
+		return nil, errors.New("gopenpgp: attachment chunk out of place")
+	}
+	return message.GetBinary(), nil
+}
+
+// DecryptAttachmentChunks decrypts the session key in keyPacket, and the data
+// packets of all the chunks of an attachment, in order.
+func (keyRing *KeyRing) DecryptAttachmentChunks(keyPacket []byte, dataPackets [][]byte) ([]byte, error) {
+	sessionKey, err := keyRing.DecryptSessionKey(keyPacket)
+	if err != nil {
+		return nil, err
+	}
+	return sessionKey.decryptAttachmentChunks(dataPackets)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// decryptAttachmentChunks decrypts the data packets of all the chunks of an
+// attachment, in order.
+func (sk *SessionKey) decryptAttachmentChunks(dataPackets [][]byte) ([]byte, error) {
+	var attachment []byte
+	for i, dataPacket := range dataPackets {
+		chunk, err := sk.DecryptAttachmentChunk(i, len(dataPackets), dataPacket)
+		if err != nil {
+			return nil, err
+		}
+		attachment = append(attachment, chunk...)
+	}
+	return attachment, nil
+}
+
+// countChunks returns the number of chunks of chunkSize bytes of an
+// attachment of attachmentSize bytes, at least one.
+func countChunks(attachmentSize, chunkSize int) int {
+	if attachmentSize == 0 {
+		return 1
+	}
+	return (attachmentSize + chunkSize - 1) / chunkSize
+}
+
+// chunkBounds returns the bounds, in the attachment, of the chunk with the
+// given index.
+func chunkBounds(index, attachmentSize, chunkSize int) (start, end int, err error) {
+	if index < 0 || index >= countChunks(attachmentSize, chunkSize) {
+		return 0, 0, errors.New("gopenpgp: attachment chunk index out of range")
+	}
+	start = index * chunkSize
+	end = start + chunkSize
+	if end > attachmentSize {
+		end = attachmentSize
+	}
+	return start, end, nil
+}
+
+func chunkName(index, count int) string {
+	return strconv.Itoa(index) + "/" + strconv.Itoa(count)
+}
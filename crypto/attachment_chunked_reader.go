@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AttachmentChunkSource provides the data packets of the chunks of an
+// attachment encrypted by a ChunkedAttachmentEncryptor, e.g. by downloading
+// them.
+type AttachmentChunkSource interface {
+	GetChunk(index int) ([]byte, error)
+}
+
+// ChunkedAttachmentReader decrypts arbitrary ranges of an attachment encrypted
+// by a ChunkedAttachmentEncryptor, e.g. to seek in large media files: only the
+// chunks overlapping a range are fetched from the source and decrypted.
+// It implements io.ReaderAt, and is safe for concurrent use.
+type ChunkedAttachmentReader struct {
+	sessionKey     *SessionKey
+	source         AttachmentChunkSource
+	attachmentSize int
+	chunkSize      int
+
+	// The last decrypted chunk, for sequential reads
+	lock        sync.Mutex
+	cachedIndex int
+	cachedChunk []byte
+}
+
+// NewChunkedAttachmentReader decrypts the session key in keyPacket, and
+// creates a ChunkedAttachmentReader for an attachment of attachmentSize bytes
+// encrypted in chunks of chunkSize bytes, whose data packets are provided by
+// source.
+func (keyRing *KeyRing) NewChunkedAttachmentReader(
+	keyPacket []byte, source AttachmentChunkSource, attachmentSize, chunkSize int,
+) (*ChunkedAttachmentReader, error) {
+	sessionKey, err := keyRing.DecryptSessionKey(keyPacket)
+	if err != nil {
+		return nil, err
+	}
+	return NewChunkedAttachmentReaderWithSessionKey(sessionKey, source, attachmentSize, chunkSize)
+}
+
+// NewChunkedAttachmentReaderWithSessionKey creates a ChunkedAttachmentReader
+// with the session key of the chunks, as in NewChunkedAttachmentReader.
+func NewChunkedAttachmentReaderWithSessionKey(
+	sessionKey *SessionKey, source AttachmentChunkSource, attachmentSize, chunkSize int,
+) (*ChunkedAttachmentReader, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("gopenpgp: invalid attachment chunk size")
+	}
+	if attachmentSize < 0 {
+		return nil, errors.New("gopenpgp: invalid attachment size")
+	}
+	return &ChunkedAttachmentReader{
+		sessionKey:     sessionKey,
+		source:         source,
+		attachmentSize: attachmentSize,
+		chunkSize:      chunkSize,
+		cachedIndex:    -1,
+	}, nil
+}
+
+// GetSize returns the size of the attachment.
+func (r *ChunkedAttachmentReader) GetSize() int {
+	return r.attachmentSize
+}
+
+// CountChunks returns the number of chunks of the attachment.
+func (r *ChunkedAttachmentReader) CountChunks() int {
+	return countChunks(r.attachmentSize, r.chunkSize)
+}
+
+// DecryptChunk fetches and decrypts the chunk with the given index.
+func (r *ChunkedAttachmentReader) DecryptChunk(index int) ([]byte, error) {
+	start, end, err := chunkBounds(index, r.attachmentSize, r.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	dataPacket, err := r.source.GetChunk(index)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to get attachment chunk")
+	}
+	chunk, err := r.sessionKey.DecryptAttachmentChunk(index, r.CountChunks(), dataPacket)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk) != end-start {
+		return nil, errors.New("gopenpgp: unexpected attachment chunk size")
+	}
+	return chunk, nil
+}
+
+// ReadAt reads len(b) bytes of the attachment starting at offset off, like
+// io.ReaderAt, decrypting the chunks overlapping them.
+func (r *ChunkedAttachmentReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("gopenpgp: negative attachment offset")
+	}
+	n := 0
+	for n < len(b) {
+		position := off + int64(n)
+		if position >= int64(r.attachmentSize) {
+			return n, io.EOF
+		}
+		index := int(position / int64(r.chunkSize))
+		chunk, err := r.getChunk(index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(b[n:], chunk[int(position)-index*r.chunkSize:])
+	}
+	return n, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// getChunk returns the decrypted chunk with the given index, from the cache
+// if it was the last one decrypted.
+func (r *ChunkedAttachmentReader) getChunk(index int) ([]byte, error) {
+	r.lock.Lock()
+	if r.cachedIndex == index {
+		chunk := r.cachedChunk
+		r.lock.Unlock()
+		return chunk, nil
+	}
+	r.lock.Unlock()
+
+	chunk, err := r.DecryptChunk(index)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	r.cachedIndex, r.cachedChunk = index, chunk
+	r.lock.Unlock()
+	return chunk, nil
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkedAttachment(t *testing.T) {
+	attachment := make([]byte, 10000)
+	if _, err := rand.Read(attachment); err != nil {
+		t.Fatal("Cannot generate data:", err)
+	}
+
+	encryptor, err := keyRingTestPublic.NewChunkedAttachmentEncryptor(len(attachment), 3000)
+	if err != nil {
+		t.Fatal("Cannot create encryptor:", err)
+	}
+	assert.Exactly(t, 4, encryptor.CountChunks())
+
+	dataPackets := make([][]byte, encryptor.CountChunks())
+	errs := make([]error, encryptor.CountChunks())
+	var wg sync.WaitGroup
+	for i := range dataPackets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			chunk, err := encryptor.GetChunk(attachment, i)
+			if err == nil {
+				dataPackets[i], err = encryptor.EncryptChunk(i, chunk)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+
+	// Resume with the same session key
+	resumed, err := NewChunkedAttachmentEncryptorWithSessionKey(
+		encryptor.GetSessionKey(), encryptor.GetKeyPacket(), len(attachment), 3000,
+	)
+	if err != nil {
+		t.Fatal("Cannot resume encryptor:", err)
+	}
+	dataPackets[3], err = resumed.EncryptChunk(3, attachment[9000:])
+	if err != nil {
+		t.Fatal("Cannot encrypt chunk:", err)
+	}
+
+	decrypted, err := keyRingTestPrivate.DecryptAttachmentChunks(encryptor.GetKeyPacket(), dataPackets)
+	if err != nil {
+		t.Fatal("Cannot decrypt chunks:", err)
+	}
+	assert.Exactly(t, attachment, decrypted)
+
+	_, err = keyRingTestPrivate.DecryptAttachmentChunks(
+		encryptor.GetKeyPacket(), [][]byte{dataPackets[1], dataPackets[0], dataPackets[2], dataPackets[3]},
+	)
+	assert.NotNil(t, err)
+	_, err = keyRingTestPrivate.DecryptAttachmentChunks(encryptor.GetKeyPacket(), dataPackets[:3])
+	assert.NotNil(t, err)
+
+	_, err = encryptor.EncryptChunk(4, nil)
+	assert.NotNil(t, err)
+	_, err = encryptor.EncryptChunk(0, attachment[:10])
+	assert.NotNil(t, err)
+}
+
+type testChunkSource struct {
+	dataPackets [][]byte
+	fetched     []int
+}
+
+func (s *testChunkSource) GetChunk(index int) ([]byte, error) {
+	s.fetched = append(s.fetched, index)
+	return s.dataPackets[index], nil
+}
+
+func TestChunkedAttachmentReader(t *testing.T) {
+	attachment := make([]byte, 10000)
+	if _, err := rand.Read(attachment); err != nil {
+		t.Fatal("Cannot generate data:", err)
+	}
+	encryptor, err := keyRingTestPublic.NewChunkedAttachmentEncryptor(len(attachment), 3000)
+	if err != nil {
+		t.Fatal("Cannot create encryptor:", err)
+	}
+	source := &testChunkSource{dataPackets: make([][]byte, encryptor.CountChunks())}
+	for i := range source.dataPackets {
+		chunk, _ := encryptor.GetChunk(attachment, i)
+		if source.dataPackets[i], err = encryptor.EncryptChunk(i, chunk); err != nil {
+			t.Fatal("Cannot encrypt chunk:", err)
+		}
+	}
+
+	reader, err := keyRingTestPrivate.NewChunkedAttachmentReader(encryptor.GetKeyPacket(), source, len(attachment), 3000)
+	if err != nil {
+		t.Fatal("Cannot create reader:", err)
+	}
+	assert.Exactly(t, 4, reader.CountChunks())
+
+	// Only the chunks overlapping the range are fetched, and the last one is
+	// not fetched again
+	buffer := make([]byte, 1000)
+	n, err := reader.ReadAt(buffer, 6500)
+	assert.Nil(t, err)
+	assert.Exactly(t, 1000, n)
+	assert.Exactly(t, attachment[6500:7500], buffer)
+	n, err = reader.ReadAt(buffer, 5500)
+	assert.Nil(t, err)
+	assert.Exactly(t, 1000, n)
+	assert.Exactly(t, attachment[5500:6500], buffer)
+	assert.Exactly(t, []int{2, 1, 2}, source.fetched)
+
+	n, err = reader.ReadAt(buffer, 9500)
+	assert.Exactly(t, io.EOF, err)
+	assert.Exactly(t, 500, n)
+	assert.Exactly(t, attachment[9500:], buffer[:n])
+
+	all, err := ioutil.ReadAll(io.NewSectionReader(reader, 0, int64(reader.GetSize())))
+	assert.Nil(t, err)
+	assert.Exactly(t, attachment, all)
+
+	// Chunks out of place are rejected
+	source.dataPackets[0], source.dataPackets[1] = source.dataPackets[1], source.dataPackets[0]
+	_, err = reader.DecryptChunk(0)
+	assert.NotNil(t, err)
+	_, err = reader.DecryptChunk(4)
+	assert.NotNil(t, err)
+}
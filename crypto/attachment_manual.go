@@ -3,7 +3,6 @@ package crypto
 import (
 	"io"
 	"io/ioutil"
-	"runtime"
 	"runtime/debug"
 	"sync"
 	"time"
@@ -22,6 +21,7 @@ type ManualAttachmentProcessor struct {
 	dataLength       int
 	plaintextWriter  io.WriteCloser
 	ciphertextWriter *io.PipeWriter
+	gc               *garbageCollector
 	err              error
 	done             sync.WaitGroup
 }
@@ -40,14 +40,14 @@ func (ap *ManualAttachmentProcessor) GetDataLength() int {
 
 // Process writes attachment data to be encrypted.
 func (ap *ManualAttachmentProcessor) Process(plainData []byte) error {
-	defer runtime.GC()
 	_, err := ap.plaintextWriter.Write(plainData)
+	ap.gc.process(len(plainData))
 	return errors.Wrap(err, "gopenpgp: couldn't write attachment data")
 }
 
 // Finish tells the processor to finalize encryption.
 func (ap *ManualAttachmentProcessor) Finish() error {
-	defer runtime.GC()
+	defer ap.gc.collect()
 	if ap.err != nil {
 		return ap.err
 	}
@@ -67,7 +67,8 @@ func (ap *ManualAttachmentProcessor) Finish() error {
 // NewManualAttachmentProcessor creates an AttachmentProcessor which can be used
 // to encrypt a file. It takes an estimatedSize and filename as hints about the
 // file and a buffer to hold the DataPacket.
-// It is optimized for low-memory environments and collects garbage every megabyte.
+// It is optimized for low-memory environments and collects garbage every megabyte,
+// or every memory budget set with SetMemoryBudget.
 // The buffer for the data packet must be manually allocated by the caller.
 // Make sure that the dataBuffer is large enough to hold the whole data packet
 // otherwise Finish() will return an error.
@@ -77,11 +78,15 @@ func (keyRing *KeyRing) NewManualAttachmentProcessor(
 	if len(dataBuffer) == 0 {
 		return nil, errors.New("gopenpgp: can't give a nil or empty buffer to process the attachment")
 	}
+	recipients, err := keyRing.getEncryptionEntities(getNow())
+	if err != nil {
+		return nil, err
+	}
 
 	// forces the gc to be called often
 	debug.SetGCPercent(10)
 
-	attachmentProc := &ManualAttachmentProcessor{}
+	attachmentProc := &ManualAttachmentProcessor{gc: newGarbageCollector(lowMemoryCollectionInterval)}
 
 	// hints for the encrypted file
 	isBinary := true
@@ -128,7 +133,7 @@ func (keyRing *KeyRing) NewManualAttachmentProcessor(
 	// We generate the encrypting writer
 	var ew io.WriteCloser
 	var encryptErr error
-	ew, encryptErr = openpgp.EncryptSplit(keyWriter, dataWriter, keyRing.entities, nil, hints, config)
+	ew, encryptErr = encryptToRecipients(keyWriter, dataWriter, recipients, nil, hints, config, nil)
 	if encryptErr != nil {
 		return nil, errors.Wrap(encryptErr, "gopengpp: unable to encrypt attachment")
 	}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"github.com/pkg/errors"
+)
+
+// DefaultAttachmentBufferSize is the size of the buffer of a
+// StreamingAttachmentProcessor, if none is given.
+const DefaultAttachmentBufferSize = 1 << 16
+
+// AttachmentChunkHandler receives the chunks of the data packet of an
+// attachment encrypted by a StreamingAttachmentProcessor.
+// The chunk is only valid during the call, as its buffer is reused.
+type AttachmentChunkHandler interface {
+	OnChunk(chunk []byte) error
+}
+
+// StreamingAttachmentProcessor encrypts an attachment written in chunks.
+// The data packet is emitted in chunks, as it is produced, so that at most
+// bufferSize bytes of it are held in memory, whatever the size of the
+// attachment.
+type StreamingAttachmentProcessor struct {
+	result *EncryptSplitResult
+	output *chunkWriter
+}
+
+// NewStreamingAttachmentProcessor creates a StreamingAttachmentProcessor
+// writing the data packet to dataPacketWriter, in chunks of bufferSize bytes,
+// or DefaultAttachmentBufferSize if bufferSize isn't positive.
+// If plainMessageMetadata is nil, the attachment is binary and unnamed.
+func (keyRing *KeyRing) NewStreamingAttachmentProcessor(
+	dataPacketWriter Writer, plainMessageMetadata *PlainMessageMetadata, bufferSize int,
+) (*StreamingAttachmentProcessor, error) {
+	return keyRing.newStreamingAttachmentProcessor(
+		func(chunk []byte) error {
+			_, err := dataPacketWriter.Write(chunk)
+			return err
+		},
+		plainMessageMetadata,
+		bufferSize,
+	)
+}
+
+// NewStreamingAttachmentProcessorWithHandler creates a
+// StreamingAttachmentProcessor passing the chunks of the data packet to
+// handler, as in NewStreamingAttachmentProcessor.
+func (keyRing *KeyRing) NewStreamingAttachmentProcessorWithHandler(
+	handler AttachmentChunkHandler, plainMessageMetadata *PlainMessageMetadata, bufferSize int,
+) (*StreamingAttachmentProcessor, error) {
+	return keyRing.newStreamingAttachmentProcessor(handler.OnChunk, plainMessageMetadata, bufferSize)
+}
+
+// Write writes attachment data to be encrypted.
+func (ap *StreamingAttachmentProcessor) Write(plainData []byte) (int, error) {
+	n, err := ap.result.Write(plainData)
+	if err != nil {
+		return n, errors.Wrap(err, "gopenpgp: couldn't write attachment data")
+	}
+	return n, nil
+}
+
+// Finish finalizes the encryption, and emits the last chunk of the data
+// packet.
+func (ap *StreamingAttachmentProcessor) Finish() error {
+	if err := ap.result.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to close the plaintext writer")
+	}
+	return ap.output.flush()
+}
+
+// GetKeyPacket returns the key packet for the attachment.
+// This should be called only after Finish() has been called.
+func (ap *StreamingAttachmentProcessor) GetKeyPacket() ([]byte, error) {
+	return ap.result.GetKeyPacket()
+}
+
+// GetDataLength returns the number of bytes in the data packet emitted so far.
+func (ap *StreamingAttachmentProcessor) GetDataLength() int {
+	return ap.output.length
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func (keyRing *KeyRing) newStreamingAttachmentProcessor(
+	emit func([]byte) error, plainMessageMetadata *PlainMessageMetadata, bufferSize int,
+) (*StreamingAttachmentProcessor, error) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAttachmentBufferSize
+	}
+	output := &chunkWriter{
+		buffer: make([]byte, 0, bufferSize),
+		emit:   emit,
+	}
+	result, err := keyRing.EncryptSplitStream(output, plainMessageMetadata, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to encrypt attachment")
+	}
+	return &StreamingAttachmentProcessor{
+		result: result,
+		output: output,
+	}, nil
+}
+
+// chunkWriter buffers the data written to it, and emits it in chunks of the
+// capacity of its buffer.
+type chunkWriter struct {
+	buffer []byte
+	emit   func([]byte) error
+	length int
+	err    error
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(data) > 0 {
+		n := copy(w.buffer[len(w.buffer):cap(w.buffer)], data)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		data = data[n:]
+		written += n
+		if len(w.buffer) == cap(w.buffer) {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *chunkWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	if err := w.emit(w.buffer); err != nil {
+		w.err = errors.Wrap(err, "gopenpgp: unable to emit attachment chunk")
+		return w.err
+	}
+	w.length += len(w.buffer)
+	w.buffer = w.buffer[:0]
+	return nil
+}
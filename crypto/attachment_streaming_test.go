@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testChunkHandler struct {
+	data         bytes.Buffer
+	maxChunkSize int
+	err          error
+}
+
+func (h *testChunkHandler) OnChunk(chunk []byte) error {
+	if len(chunk) > h.maxChunkSize {
+		h.maxChunkSize = len(chunk)
+	}
+	h.data.Write(chunk)
+	return h.err
+}
+
+func TestStreamingAttachmentProcessor(t *testing.T) {
+	plainData := make([]byte, 300000)
+	if _, err := rand.Read(plainData); err != nil {
+		t.Fatal("Cannot generate data:", err)
+	}
+
+	handler := &testChunkHandler{}
+	processor, err := keyRingTestPublic.NewStreamingAttachmentProcessorWithHandler(
+		handler, NewPlainMessageMetadata(true, "file.bin", GetUnixTime()), 4096,
+	)
+	if err != nil {
+		t.Fatal("Cannot create processor:", err)
+	}
+	_, err = processor.GetKeyPacket()
+	assert.NotNil(t, err)
+
+	for i := 0; i < len(plainData); i += 10000 {
+		if _, err := processor.Write(plainData[i : i+10000]); err != nil {
+			t.Fatal("Cannot write attachment data:", err)
+		}
+	}
+	if err := processor.Finish(); err != nil {
+		t.Fatal("Cannot finish processor:", err)
+	}
+	assert.Exactly(t, 4096, handler.maxChunkSize)
+	assert.Exactly(t, handler.data.Len(), processor.GetDataLength())
+
+	keyPacket, err := processor.GetKeyPacket()
+	if err != nil {
+		t.Fatal("Cannot get key packet:", err)
+	}
+	decrypted, err := keyRingTestPrivate.DecryptAttachment(NewPGPSplitMessage(keyPacket, handler.data.Bytes()))
+	if err != nil {
+		t.Fatal("Cannot decrypt attachment:", err)
+	}
+	assert.Exactly(t, plainData, decrypted.GetBinary())
+	assert.Exactly(t, "file.bin", decrypted.Filename)
+
+	var output bytes.Buffer
+	processor, err = keyRingTestPublic.NewStreamingAttachmentProcessor(&output, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot create processor:", err)
+	}
+	_, _ = processor.Write(plainData)
+	assert.Nil(t, processor.Finish())
+	assert.Exactly(t, output.Len(), processor.GetDataLength())
+}
+
+func TestStreamingAttachmentProcessorHandlerError(t *testing.T) {
+	handler := &testChunkHandler{err: errors.New("full disk")}
+	processor, err := keyRingTestPublic.NewStreamingAttachmentProcessorWithHandler(handler, nil, 16)
+	if err != nil {
+		t.Fatal("Cannot create processor:", err)
+	}
+	_, err = processor.Write(make([]byte, 1000))
+	if err == nil {
+		err = processor.Finish()
+	}
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// CleartextOptions are the text handling options of cleartext message
+// signing and verification.
+type CleartextOptions struct {
+	// CanonicalizeLineEndings converts the line endings of the text to CRLF,
+	// as required by RFC 4880 for the text signatures (type 0x01) of
+	// cleartext messages. It must be set: cleartext messages can't be signed
+	// over raw line endings, use SignDetachedWithType with a binary signature
+	// type for that.
+	CanonicalizeLineEndings bool
+	// TrimTrailingWhitespace removes the trailing spaces and tabs of each line
+	// before signing or verifying, as required by RFC 4880, section 7.1, and
+	// done by GnuPG. Disable it to verify signatures of clients that sign the
+	// text with its trailing whitespace.
+	TrimTrailingWhitespace bool
+	// NormalizeNFC applies the Unicode NFC normalization to the text before
+	// signing or verifying, so that texts differing only by the composition
+	// of their characters have the same signature.
+	NormalizeNFC bool
+}
+
+// InvalidUTF8Error is returned when signing or verifying a cleartext message
+// whose text isn't valid UTF-8.
+type InvalidUTF8Error struct {
+	// Byte offset of the first invalid UTF-8 sequence in the text
+	Offset int
+}
+
+// Error is the base method for all errors.
+func (e InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("gopenpgp: invalid UTF-8 in cleartext message at offset %d", e.Offset)
+}
+
+// NewCleartextOptions returns the default cleartext options, as used by
+// helper.SignCleartextMessage: line endings are canonicalized and trailing
+// whitespace is trimmed, without NFC normalization.
+func NewCleartextOptions() *CleartextOptions {
+	return &CleartextOptions{
+		CanonicalizeLineEndings: true,
+		TrimTrailingWhitespace:  true,
+	}
+}
+
+// SignCleartext canonicalizes text according to options, or the default
+// options if nil, and signs it with a text signature. The returned message
+// holds the canonicalized text. Text that isn't valid UTF-8 is rejected with
+// an InvalidUTF8Error.
+func (keyRing *KeyRing) SignCleartext(text string, options *CleartextOptions) (*ClearTextMessage, error) {
+	if options == nil {
+		options = NewCleartextOptions()
+	}
+	message, err := newCleartextPlainMessage(text, options)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := keyRing.SignDetachedWithType(message, constants.SignatureTypeText)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in signing cleartext message")
+	}
+
+	return NewClearTextMessage(message.GetBinary(), signature.GetBinary()), nil
+}
+
+// VerifyCleartext verifies the signature of message over its text
+// canonicalized according to options, or the default options if nil, and
+// returns a SignatureVerificationError if fails, or an InvalidUTF8Error if
+// the text isn't valid UTF-8. The signature must be a text signature.
+func (keyRing *KeyRing) VerifyCleartext(message *ClearTextMessage, options *CleartextOptions, verifyTime int64) error {
+	if options == nil {
+		options = NewCleartextOptions()
+	}
+	plainMessage, err := newCleartextPlainMessage(message.GetString(), options)
+	if err != nil {
+		return err
+	}
+	signature := NewPGPSignature(message.GetBinarySignature())
+	return keyRing.VerifyDetachedWithType(plainMessage, signature, constants.SignatureTypeText, verifyTime)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func newCleartextPlainMessage(text string, options *CleartextOptions) (*PlainMessage, error) {
+	if !options.CanonicalizeLineEndings {
+		return nil, errors.New(
+			"gopenpgp: cleartext messages are signed over CRLF line endings, " +
+				"use SignDetachedWithType to sign raw line endings",
+		)
+	}
+	if err := checkUTF8(text); err != nil {
+		return nil, err
+	}
+	message := NewPlainMessage([]byte(options.canonicalize(text)))
+	message.TextType = true
+	return message, nil
+}
+
+// checkUTF8 returns an InvalidUTF8Error if text isn't valid UTF-8.
+func checkUTF8(text string) error {
+	for offset := 0; offset < len(text); {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == utf8.RuneError && size <= 1 {
+			return InvalidUTF8Error{Offset: offset}
+		}
+		offset += size
+	}
+	return nil
+}
+
+// canonicalize returns text with the normalization, the line endings and the
+// trailing whitespace of each line handled according to options.
+func (options *CleartextOptions) canonicalize(text string) string {
+	if options.NormalizeNFC {
+		text = norm.NFC.String(text)
+	}
+	lines := strings.Split(text, "\n")
+	var result strings.Builder
+	for i, line := range lines {
+		lineEnding := "\n"
+		if i < len(lines)-1 && strings.HasSuffix(line, "\r") {
+			line = line[:len(line)-1]
+			lineEnding = "\r\n"
+		}
+		if options.TrimTrailingWhitespace {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		if options.CanonicalizeLineEndings {
+			lineEnding = "\r\n"
+		}
+		result.WriteString(line)
+		if i < len(lines)-1 {
+			result.WriteString(lineEnding)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+func TestCleartextOptionsCanonicalize(t *testing.T) {
+	text := "line one  \r\nline two\t\nline three \n"
+
+	assert.Exactly(t, internal.CanonicalizeAndTrim(text), NewCleartextOptions().canonicalize(text))
+	assert.Exactly(t, "line one\r\nline two\nline three\n", (&CleartextOptions{TrimTrailingWhitespace: true}).canonicalize(text))
+	assert.Exactly(
+		t, "line one  \r\nline two\t\r\nline three \r\n", (&CleartextOptions{CanonicalizeLineEndings: true}).canonicalize(text),
+	)
+	assert.Exactly(t, text, (&CleartextOptions{}).canonicalize(text))
+}
+
+func TestSignCleartextWithOptions(t *testing.T) {
+	text := "Signed on Unix  \nverified on Windows\n"
+
+	message, err := keyRingTestPrivate.SignCleartext(text, nil)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	assert.Exactly(t, "Signed on Unix\r\nverified on Windows\r\n", message.GetString())
+
+	armored, err := message.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+	parsed, err := NewClearTextMessageFromArmored(armored)
+	if err != nil {
+		t.Fatal("Expected no error when parsing, got:", err)
+	}
+	if err = keyRingTestPublic.VerifyCleartext(parsed, nil, testTime); err != nil {
+		t.Fatal("Expected no error when verifying, got:", err)
+	}
+
+	// The text signature covers CRLF line endings
+	lfMessage := NewClearTextMessage([]byte("Signed on Unix\nverified on Windows\n"), message.GetBinarySignature())
+	if err = keyRingTestPublic.VerifyCleartext(lfMessage, nil, testTime); err != nil {
+		t.Fatal("Expected no error when verifying with LF line endings, got:", err)
+	}
+	sigType, ok := NewPGPSignature(message.GetBinarySignature()).GetSignatureType()
+	assert.True(t, ok)
+	assert.Exactly(t, constants.SignatureTypeText, sigType)
+
+	// Cleartext messages can't be signed over raw line endings
+	_, err = keyRingTestPrivate.SignCleartext(text, &CleartextOptions{})
+	assert.Error(t, err)
+	err = keyRingTestPublic.VerifyCleartext(lfMessage, &CleartextOptions{}, testTime)
+	assert.Error(t, err)
+
+	untrimmed := &CleartextOptions{CanonicalizeLineEndings: true}
+	message, err = keyRingTestPrivate.SignCleartext(text, untrimmed)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	if err = keyRingTestPublic.VerifyCleartext(message, untrimmed, testTime); err != nil {
+		t.Fatal("Expected no error when verifying, got:", err)
+	}
+	err = keyRingTestPublic.VerifyCleartext(message, nil, testTime)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestSignCleartextUTF8(t *testing.T) {
+	_, err := keyRingTestPrivate.SignCleartext("valid \xff invalid", nil)
+	utf8Err := &InvalidUTF8Error{}
+	if !errors.As(err, utf8Err) {
+		t.Fatal("Expected an invalid UTF-8 error, got:", err)
+	}
+	assert.Exactly(t, 6, utf8Err.Offset)
+
+	err = keyRingTestPublic.VerifyCleartext(NewClearTextMessage([]byte("\xc3"), nil), nil, testTime)
+	assert.True(t, errors.As(err, utf8Err))
+
+	composed := "caf\u00e9"
+	decomposed := "cafe\u0301"
+	nfc := &CleartextOptions{CanonicalizeLineEndings: true, TrimTrailingWhitespace: true, NormalizeNFC: true}
+
+	message, err := keyRingTestPrivate.SignCleartext(decomposed, nfc)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	assert.Exactly(t, composed, message.GetString())
+
+	decomposedMessage := NewClearTextMessage([]byte(decomposed), message.GetBinarySignature())
+	if err = keyRingTestPublic.VerifyCleartext(decomposedMessage, nfc, testTime); err != nil {
+		t.Fatal("Expected no error when verifying the decomposed text, got:", err)
+	}
+	err = keyRingTestPublic.VerifyCleartext(decomposedMessage, nil, testTime)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// ContentKind is the kind of OpenPGP data detected by DetectContent.
+type ContentKind int
+
+const (
+	// ContentUnknown is data that isn't recognized as OpenPGP data.
+	ContentUnknown ContentKind = iota
+	// ContentMessage is an encrypted, signed, compressed or literal message.
+	ContentMessage
+	// ContentCleartextMessage is an armored cleartext signed message.
+	ContentCleartextMessage
+	// ContentPublicKey is one or more public keys.
+	ContentPublicKey
+	// ContentPrivateKey is one or more private keys.
+	ContentPrivateKey
+	// ContentSignature is one or more detached signatures.
+	ContentSignature
+)
+
+var contentKindNames = map[ContentKind]string{
+	ContentUnknown:          "unknown",
+	ContentMessage:          "message",
+	ContentCleartextMessage: "cleartext message",
+	ContentPublicKey:        "public key",
+	ContentPrivateKey:       "private key",
+	ContentSignature:        "signature",
+}
+
+// String returns the name of the content kind.
+func (kind ContentKind) String() string {
+	if name, ok := contentKindNames[kind]; ok {
+		return name
+	}
+	return contentKindNames[ContentUnknown]
+}
+
+// DetectContent detects the kind of OpenPGP data, armored or binary.
+// The kind is given by the first packet, not by the armor type, so that a
+// mislabeled armor is detected for what it holds. Only the first packet is
+// parsed: data of a known kind may still fail to parse completely.
+func DetectContent(data []byte) ContentKind {
+	if isArmored(data) {
+		trimmed := bytes.TrimLeft(data, " \t\r\n")
+		if bytes.HasPrefix(trimmed, []byte("-----BEGIN "+constants.PGPSignedMessageHeader+"-----")) {
+			return ContentCleartextMessage
+		}
+		block, err := armor.UnarmorBlock(string(data))
+		if err != nil {
+			return ContentUnknown
+		}
+		data = block.Data
+	}
+
+	p, err := packet.NewReader(bytes.NewReader(data)).Next()
+	if err != nil {
+		return ContentUnknown
+	}
+	switch p.(type) {
+	case *packet.PublicKey:
+		return ContentPublicKey
+	case *packet.PrivateKey:
+		return ContentPrivateKey
+	case *packet.Signature:
+		return ContentSignature
+	case *packet.EncryptedKey,
+		*packet.SymmetricKeyEncrypted,
+		*packet.SymmetricallyEncrypted,
+		*packet.AEADEncrypted,
+		*packet.OnePassSignature,
+		*packet.Compressed,
+		*packet.LiteralData:
+		return ContentMessage
+	default:
+		return ContentUnknown
+	}
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// isArmored returns whether data starts with an armor header line, after
+// optional whitespace.
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("-----BEGIN PGP "))
+}
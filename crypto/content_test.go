@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectContent(t *testing.T) {
+	message := NewPlainMessageFromString("detected content")
+	pgpMessage, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	armoredMessage, err := pgpMessage.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+	signature, err := keyRingTestPrivate.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	armoredSignature, err := signature.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+	cleartext, err := NewClearTextMessage(message.GetBinary(), signature.GetBinary()).GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+	publicKey, err := NewKeyFromArmored(readTestFile("keyring_publicKey", false))
+	if err != nil {
+		t.Fatal("Expected no error when reading key, got:", err)
+	}
+	binaryPublicKey, err := publicKey.Serialize()
+	if err != nil {
+		t.Fatal("Expected no error when serializing key, got:", err)
+	}
+
+	assert.Exactly(t, ContentMessage, DetectContent(pgpMessage.GetBinary()))
+	assert.Exactly(t, ContentMessage, DetectContent([]byte("\n"+armoredMessage)))
+	assert.Exactly(t, ContentSignature, DetectContent(signature.GetBinary()))
+	assert.Exactly(t, ContentSignature, DetectContent([]byte(armoredSignature)))
+	assert.Exactly(t, ContentCleartextMessage, DetectContent([]byte(cleartext)))
+	assert.Exactly(t, ContentPublicKey, DetectContent(binaryPublicKey))
+	assert.Exactly(t, ContentPrivateKey, DetectContent([]byte(readTestFile("keyring_privateKey", false))))
+	assert.Exactly(t, ContentUnknown, DetectContent([]byte("plain text")))
+	assert.Exactly(t, ContentUnknown, DetectContent(nil))
+
+	// The kind is given by the packets, not by the armor type
+	mislabeled := strings.ReplaceAll(armoredMessage, "PGP MESSAGE", "PGP SIGNATURE")
+	assert.Exactly(t, ContentMessage, DetectContent([]byte(mislabeled)))
+
+	assert.Exactly(t, "private key", ContentPrivateKey.String())
+	assert.Exactly(t, "unknown", ContentKind(-1).String())
+}
@@ -0,0 +1,356 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	goerrors "errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// Packet tags used to split keys.
+const (
+	packetTagPrivateKey    = 5
+	packetTagPublicKey     = 6
+	packetTagPrivateSubkey = 7
+	packetTagPublicSubkey  = 14
+)
+
+// SetAllowLegacySigningSubkeys sets whether signing subkeys without a
+// cross-certification (an embedded primary key binding signature) are
+// accepted. They are rejected by default, both when reading keys and when
+// verifying signatures, as anybody could otherwise claim the signatures made
+// by someone else's signing subkey by binding it to their own primary key.
+// Only allow them to use keys generated before cross-certification existed.
+func SetAllowLegacySigningSubkeys(allow bool) {
+	pgp.lock.Lock()
+	defer pgp.lock.Unlock()
+
+	pgp.allowLegacySigningSubkeys = allow
+}
+
+// --- Internal functions
+
+func allowLegacySigningSubkeys() bool {
+	pgp.lock.RLock()
+	defer pgp.lock.RUnlock()
+
+	return pgp.allowLegacySigningSubkeys
+}
+
+// newSignatureMissingCrossCertification creates a new
+// SignatureVerificationError, type SignatureFailed, with a message describing
+// the signing subkey as not cross-certified.
+func newSignatureMissingCrossCertification() SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: "Invalid signature: signing subkey is missing its cross-certification",
+	}
+}
+
+// checkCrossCertification returns an error if key is a signing subkey without
+// a valid cross-certification, unless legacy signing subkeys are allowed.
+func checkCrossCertification(key openpgp.Key) error {
+	if key.Entity == nil || key.PublicKey == key.Entity.PrimaryKey || allowLegacySigningSubkeys() {
+		return nil
+	}
+	sig := key.SelfSignature
+	if sig == nil || !sig.FlagSign {
+		return nil
+	}
+	if sig.EmbeddedSignature == nil || key.Entity.PrimaryKey.VerifyKeySignature(key.PublicKey, sig) != nil {
+		return newSignatureMissingCrossCertification()
+	}
+	return nil
+}
+
+// verifyCrossCertification verifies the primary key binding signature
+// embedded in sig, the binding signature of the subkey signed by primaryKey,
+// which is made by the subkey over both keys.
+func verifyCrossCertification(primaryKey, subkey *packet.PublicKey, sig *packet.Signature) error {
+	crossCertification := sig.EmbeddedSignature
+	if crossCertification == nil {
+		return errors.New("gopenpgp: signing subkey is missing its cross-certification")
+	}
+	if crossCertification.SigType != packet.SigTypePrimaryKeyBinding {
+		return errors.New("gopenpgp: cross-certification is not a primary key binding signature")
+	}
+	if !crossCertification.Hash.Available() {
+		return errors.New("gopenpgp: unsupported cross-certification hash")
+	}
+	h := crossCertification.Hash.New()
+	if err := primaryKey.SerializeForHash(h); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to hash primary key")
+	}
+	if err := subkey.SerializeForHash(h); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to hash subkey")
+	}
+	return subkey.VerifySignature(h, crossCertification)
+}
+
+// checkSignerCrossCertification checks the cross-certification of the keys of
+// signer that may have issued the detached signature.
+func checkSignerCrossCertification(
+	pubKeyEntries openpgp.EntityList, signer *openpgp.Entity, signature []byte, knownNotations map[string]bool,
+) error {
+	sigs, err := readSignaturePackets(signature, knownNotations)
+	for _, sig := range sigs {
+		issuedBySigner := false
+		for _, key := range signatureIssuerKeys(pubKeyEntries, sig) {
+			if key.Entity != signer {
+				continue
+			}
+			if checkCrossCertification(key) == nil {
+				return nil
+			}
+			issuedBySigner = true
+		}
+		if issuedBySigner {
+			return newSignatureMissingCrossCertification()
+		}
+	}
+	if err != nil {
+		return newSignatureFailed()
+	}
+	return nil
+}
+
+// readKeyRing reads the armored or unarmored keys from r, including signing
+// subkeys without cross-certification if they are allowed.
+func readKeyRing(r io.Reader, armored bool) ([]*Key, error) {
+	if armored {
+		return readArmoredKeyRing(r)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entities openpgp.EntityList
+	if allowLegacySigningSubkeys() {
+		entities, err = readLegacyKeyRing(data)
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readUserAttributes(entities, data), nil
+}
+
+// readArmoredKeyRing reads the keys of all the armored blocks of r, public
+// and private blocks mixed, e.g. as exported by `gpg --armor --export` of
+// several keys.
+func readArmoredKeyRing(r io.Reader) ([]*Key, error) {
+	// armor.Decode keeps reading from a large enough bufio.Reader, which is
+	// positioned after the end of the block once its body is read.
+	in := bufio.NewReader(r)
+	var keys []*Key
+	for {
+		block, err := armor.Decode(in)
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if block.Type != openpgp.PublicKeyType && block.Type != openpgp.PrivateKeyType {
+			return nil, errors.New("gopenpgp: expected public or private key block, got: " + block.Type)
+		}
+
+		blockKeys, err := readKeyRing(block.Body, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.Copy(ioutil.Discard, block.Body); err != nil {
+			return nil, err
+		}
+		keys = append(keys, blockKeys...)
+	}
+	if keys == nil {
+		return nil, errors.New("gopenpgp: no armored key block found")
+	}
+	return keys, nil
+}
+
+// readKey reads a single unarmored key, including signing subkeys without
+// cross-certification if they are allowed.
+func readKey(data []byte) (*Key, error) {
+	if !allowLegacySigningSubkeys() {
+		entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return nil, err
+		}
+		return readUserAttributes(openpgp.EntityList{entity}, data)[0], nil
+	}
+
+	entities, err := readLegacyKeyRing(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) != 1 {
+		return nil, errors.New("gopenpgp: expected a single key")
+	}
+	return readUserAttributes(entities, data)[0], nil
+}
+
+// legacySubkey is a signing subkey without cross-certification, with its
+// binding and revocation signatures.
+type legacySubkey struct {
+	primaryFingerprint []byte
+	subkey             packet.Packet
+	signatures         []*packet.Signature
+}
+
+// readLegacyKeyRing reads unarmored keys. The signing subkeys without
+// cross-certification, rejected by the parser, are set aside before parsing
+// and added back to their key once their binding signature is verified.
+func readLegacyKeyRing(data []byte) (openpgp.EntityList, error) {
+	var parsed bytes.Buffer
+	var legacySubkeys []*legacySubkey
+	var primaryKey *packet.PublicKey
+	var subkeyPackets [][]byte
+
+	flushSubkey := func() {
+		if subkeyPackets == nil {
+			return
+		}
+		if legacy := parseLegacySubkey(primaryKey, subkeyPackets); legacy != nil {
+			legacySubkeys = append(legacySubkeys, legacy)
+		} else {
+			for _, raw := range subkeyPackets {
+				parsed.Write(raw)
+			}
+		}
+		subkeyPackets = nil
+	}
+
+	for rest := data; len(rest) > 0; {
+		tag, _, next, err := readRawPacket(rest)
+		if err != nil {
+			return nil, err
+		}
+		raw := rest[:len(rest)-len(next)]
+		rest = next
+
+		switch {
+		case tag == packetTagPublicKey || tag == packetTagPrivateKey:
+			flushSubkey()
+			primaryKey = parsePublicKey(raw)
+			parsed.Write(raw)
+		case tag == packetTagPublicSubkey || tag == packetTagPrivateSubkey:
+			flushSubkey()
+			subkeyPackets = [][]byte{raw}
+		case tag == signaturePacketTag && subkeyPackets != nil:
+			subkeyPackets = append(subkeyPackets, raw)
+		default:
+			flushSubkey()
+			parsed.Write(raw)
+		}
+	}
+	flushSubkey()
+
+	entities, err := openpgp.ReadKeyRing(&parsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, legacy := range legacySubkeys {
+		for _, entity := range entities {
+			if bytes.Equal(entity.PrimaryKey.Fingerprint, legacy.primaryFingerprint) {
+				addLegacySubkey(entity, legacy)
+			}
+		}
+	}
+	return entities, nil
+}
+
+// parsePublicKey returns the public part of a raw key packet, or nil.
+func parsePublicKey(raw []byte) *packet.PublicKey {
+	p, err := packet.Read(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	switch pk := p.(type) {
+	case *packet.PublicKey:
+		return pk
+	case *packet.PrivateKey:
+		return &pk.PublicKey
+	}
+	return nil
+}
+
+// parseLegacySubkey returns the subkey in the raw packets if one of its
+// binding signatures is a signing one without cross-certification, or nil.
+func parseLegacySubkey(primaryKey *packet.PublicKey, rawPackets [][]byte) *legacySubkey {
+	if primaryKey == nil {
+		return nil
+	}
+	subkey, err := packet.Read(bytes.NewReader(rawPackets[0]))
+	if err != nil {
+		return nil
+	}
+
+	legacy := &legacySubkey{primaryFingerprint: primaryKey.Fingerprint, subkey: subkey}
+	isLegacy := false
+	for _, raw := range rawPackets[1:] {
+		p, err := packet.Read(bytes.NewReader(raw))
+		if err != nil {
+			return nil
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			return nil
+		}
+		if sig.SigType == packet.SigTypeSubkeyBinding && sig.FlagSign && sig.EmbeddedSignature == nil {
+			isLegacy = true
+		}
+		legacy.signatures = append(legacy.signatures, sig)
+	}
+	if !isLegacy {
+		return nil
+	}
+	return legacy
+}
+
+// addLegacySubkey adds the subkey to the entity, if it has a valid binding
+// signature, ignoring the missing cross-certification.
+func addLegacySubkey(entity *openpgp.Entity, legacy *legacySubkey) {
+	subkey := openpgp.Subkey{}
+	switch pk := legacy.subkey.(type) {
+	case *packet.PublicKey:
+		subkey.PublicKey = pk
+	case *packet.PrivateKey:
+		subkey.PublicKey = &pk.PublicKey
+		subkey.PrivateKey = pk
+	default:
+		return
+	}
+
+	for _, sig := range legacy.signatures {
+		// The embedded signature is only checked for signing subkeys
+		withoutSignFlag := *sig
+		withoutSignFlag.FlagSign = false
+		if entity.PrimaryKey.VerifyKeySignature(subkey.PublicKey, &withoutSignFlag) != nil {
+			continue
+		}
+		switch sig.SigType {
+		case packet.SigTypeSubkeyRevocation:
+			subkey.Revocations = append(subkey.Revocations, sig)
+		case packet.SigTypeSubkeyBinding:
+			if subkey.Sig == nil || sig.CreationTime.After(subkey.Sig.CreationTime) {
+				subkey.Sig = sig
+			}
+		}
+	}
+
+	if subkey.Sig != nil {
+		entity.Subkeys = append(entity.Subkeys, subkey)
+	}
+}
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacySigningSubkey(t *testing.T) {
+	key, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	key, err = key.AddSigningSubkey("x25519", 0, 0)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+
+	// Replace the binding signature with one without cross-certification
+	entity := key.entity
+	subkey := &entity.Subkeys[len(entity.Subkeys)-1]
+	legacySig := &packet.Signature{
+		Version:      entity.PrimaryKey.Version,
+		CreationTime: subkey.Sig.CreationTime,
+		SigType:      packet.SigTypeSubkeyBinding,
+		PubKeyAlgo:   entity.PrimaryKey.PubKeyAlgo,
+		Hash:         subkey.Sig.Hash,
+		FlagsValid:   true,
+		FlagSign:     true,
+		IssuerKeyId:  &entity.PrimaryKey.KeyId,
+	}
+	if err = legacySig.SignKey(subkey.PublicKey, entity.PrivateKey, nil); err != nil {
+		t.Fatal("Cannot sign subkey:", err)
+	}
+	subkey.Sig = legacySig
+
+	serialized, err := key.Serialize()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	_, err = NewKey(serialized)
+	assert.NotNil(t, err)
+
+	keyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	message := NewPlainMessageFromString(signedPlainText)
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.EqualError(
+		t,
+		keyRing.VerifyDetached(message, signature, GetUnixTime()),
+		"Signature Verification Error: Invalid signature: signing subkey is missing its cross-certification",
+	)
+
+	// Signatures with known critical notations are checked the same way
+	contextSignature, err := keyRing.SignDetachedWithContext(message, NewSigningContext("test-context", true))
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.EqualError(
+		t,
+		keyRing.VerifyDetachedWithContext(message, contextSignature, GetUnixTime(), NewVerificationContext("test-context", true, 0)),
+		"Signature Verification Error: Invalid signature: signing subkey is missing its cross-certification",
+	)
+
+	SetAllowLegacySigningSubkeys(true)
+	defer SetAllowLegacySigningSubkeys(false)
+
+	legacyKey, err := NewKey(serialized)
+	if err != nil {
+		t.Fatal("Cannot read legacy key:", err)
+	}
+	assert.Len(t, legacyKey.entity.Subkeys, 2)
+
+	legacyKeyRing, err := NewKeyRing(legacyKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	assert.Nil(t, legacyKeyRing.VerifyDetached(message, signature, GetUnixTime()))
+
+	copied, err := legacyKeyRing.Copy()
+	if err != nil {
+		t.Fatal("Cannot copy key ring:", err)
+	}
+	assert.Nil(t, copied.VerifyDetached(message, signature, GetUnixTime()))
+}
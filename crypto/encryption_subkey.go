@@ -0,0 +1,204 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+)
+
+// EncryptionSubkeyPolicy selects the encryption subkeys of the keys of a
+// keyring that messages and session keys are encrypted to.
+type EncryptionSubkeyPolicy int
+
+const (
+	// EncryptToNewestSubkey encrypts to the newest valid encryption subkey of
+	// each key. It is the default policy.
+	EncryptToNewestSubkey EncryptionSubkeyPolicy = iota
+	// EncryptToAllSubkeys encrypts to every valid encryption subkey of each
+	// key, for recipients holding different subkeys on different devices.
+	EncryptToAllSubkeys
+)
+
+// SetEncryptionSubkeyPolicy sets the encryption subkeys of the keys of the
+// keyring that are used to encrypt, for the keys without subkeys selected
+// with SetEncryptionSubkeys.
+func (keyRing *KeyRing) SetEncryptionSubkeyPolicy(policy EncryptionSubkeyPolicy) {
+	keyRing.lock.Lock()
+	defer keyRing.lock.Unlock()
+
+	keyRing.encryptionPolicy = policy
+}
+
+// SetEncryptionSubkeys selects the subkeys, by fingerprint, that their keys
+// are encrypted to, in place of the subkeys of the policy, replacing the
+// previous selection. The fingerprints can be formatted as
+// FormatFingerprint does. Without fingerprints, the selection is cleared.
+// Encrypting fails if a selected subkey is not valid at the time of the
+// encryption.
+func (keyRing *KeyRing) SetEncryptionSubkeys(fingerprints ...string) error {
+	selection := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		fingerprint = strings.ToLower(normalizeFingerprint(fingerprint))
+		if _, found := findEncryptionSubkey(keyRing.getEntities(), fingerprint); !found {
+			return errors.New("gopenpgp: no encryption subkey with fingerprint " + fingerprint + " in the keyring")
+		}
+		selection[fingerprint] = true
+	}
+
+	keyRing.lock.Lock()
+	defer keyRing.lock.Unlock()
+
+	keyRing.encryptionSubkeys = selection
+	return nil
+}
+
+// GetEncryptionSubkeyFingerprints returns the fingerprints of the subkeys
+// that the keyring currently encrypts to, following its policy and selected
+// subkeys.
+func (keyRing *KeyRing) GetEncryptionSubkeyFingerprints() ([]string, error) {
+	recipients, err := keyRing.getEncryptionEntities(getNow())
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		encryptionKey, ok := encryptionKeyAt(recipient, getNow())
+		if !ok {
+			return nil, errors.New("gopenpgp: no valid encryption key for key id " + recipient.PrimaryKey.KeyIdString())
+		}
+		fingerprints = append(fingerprints, hex.EncodeToString(encryptionKey.PublicKey.Fingerprint))
+	}
+	return fingerprints, nil
+}
+
+// copyEncryptionSubkeys sets the encryption subkey policy and selected
+// subkeys of the keyring to target, a copy of the keyring.
+func (keyRing *KeyRing) copyEncryptionSubkeys(target *KeyRing) {
+	keyRing.lock.RLock()
+	policy := keyRing.encryptionPolicy
+	selection := keyRing.encryptionSubkeys
+	keyRing.lock.RUnlock()
+
+	target.lock.Lock()
+	defer target.lock.Unlock()
+
+	target.encryptionPolicy = policy
+	target.encryptionSubkeys = selection
+}
+
+// getEncryptionEntities returns the recipients to encrypt to at now, with a
+// copy of a key restricted to each of its subkeys to encrypt to, following
+// the policy and the selected subkeys of the keyring. The keys with no valid
+// subkey are returned as is, for the encryption to report them.
+func (keyRing *KeyRing) getEncryptionEntities(now time.Time) (openpgp.EntityList, error) {
+	keyRing.lock.RLock()
+	entities := keyRing.entities
+	policy := keyRing.encryptionPolicy
+	selection := keyRing.encryptionSubkeys
+	keyRing.lock.RUnlock()
+
+	if policy == EncryptToNewestSubkey && len(selection) == 0 {
+		return entities, nil
+	}
+
+	recipients := make(openpgp.EntityList, 0, len(entities))
+	for _, entity := range entities {
+		var selected openpgp.EntityList
+		for _, subkey := range encryptionCandidates(entity) {
+			fingerprint := candidateFingerprint(entity, subkey)
+			if !selection[fingerprint] {
+				continue
+			}
+			restricted := restrictToSubkey(entity, subkey)
+			if !canEncryptToSubkey(restricted, now) {
+				return nil, errors.New("gopenpgp: the selected encryption subkey " + fingerprint + " is not valid")
+			}
+			selected = append(selected, restricted)
+		}
+		if len(selected) > 0 {
+			recipients = append(recipients, selected...)
+			continue
+		}
+
+		if policy == EncryptToAllSubkeys {
+			for i := range entity.Subkeys {
+				restricted := restrictToSubkey(entity, &entity.Subkeys[i])
+				if canEncryptToSubkey(restricted, now) {
+					selected = append(selected, restricted)
+				}
+			}
+		}
+		if len(selected) == 0 {
+			selected = openpgp.EntityList{entity}
+		}
+		recipients = append(recipients, selected...)
+	}
+	return recipients, nil
+}
+
+// findEncryptionSubkey returns the subkey of the entities with the given
+// lower case hex fingerprint, or nil for a primary key. found is false if no
+// primary key or subkey able to encrypt has this fingerprint.
+func findEncryptionSubkey(entities openpgp.EntityList, fingerprint string) (subkey *openpgp.Subkey, found bool) {
+	for _, entity := range entities {
+		for _, candidate := range encryptionCandidates(entity) {
+			if candidateFingerprint(entity, candidate) != fingerprint {
+				continue
+			}
+			publicKey := entity.PrimaryKey
+			if candidate != nil {
+				publicKey = candidate.PublicKey
+			}
+			return candidate, publicKey.PubKeyAlgo.CanEncrypt()
+		}
+	}
+	return nil, false
+}
+
+// encryptionCandidates returns the keys of entity that can be selected to
+// encrypt to: nil for its primary key, followed by its subkeys.
+func encryptionCandidates(entity *openpgp.Entity) []*openpgp.Subkey {
+	candidates := make([]*openpgp.Subkey, 1, len(entity.Subkeys)+1)
+	for i := range entity.Subkeys {
+		candidates = append(candidates, &entity.Subkeys[i])
+	}
+	return candidates
+}
+
+// candidateFingerprint returns the lower case hex fingerprint of subkey, or
+// of the primary key of entity if subkey is nil.
+func candidateFingerprint(entity *openpgp.Entity, subkey *openpgp.Subkey) string {
+	if subkey == nil {
+		return hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+	}
+	return hex.EncodeToString(subkey.PublicKey.Fingerprint)
+}
+
+// restrictToSubkey returns a shallow copy of entity with subkey as its only
+// subkey, or without subkeys if subkey is nil, so that it is encrypted to.
+func restrictToSubkey(entity *openpgp.Entity, subkey *openpgp.Subkey) *openpgp.Entity {
+	restricted := *entity
+	restricted.Subkeys = nil
+	if subkey != nil {
+		restricted.Subkeys = []openpgp.Subkey{*subkey}
+	}
+	return &restricted
+}
+
+// canEncryptToSubkey returns true if the restricted entity is encrypted to
+// its only subkey at now, or to its primary key if it has no subkeys, rather
+// than falling back to its primary key.
+func canEncryptToSubkey(restricted *openpgp.Entity, now time.Time) bool {
+	encryptionKey, ok := encryptionKeyAt(restricted, now)
+	if !ok {
+		return false
+	}
+	if len(restricted.Subkeys) == 0 {
+		return true
+	}
+	return encryptionKey.PublicKey == restricted.Subkeys[0].PublicKey
+}
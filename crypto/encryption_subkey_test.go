@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptionSubkeyPolicy(t *testing.T) {
+	pgp.latestServerTime = testTime + 60
+	defer func() {
+		pgp.latestServerTime = testTime
+	}()
+
+	privateKey, err := keyTestEC.AddEncryptionSubkey("x25519", 256, 0)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	privateKeyRing, err := NewKeyRing(privateKey)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	keyRing, err := privateKeyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error when making keyring public, got:", err)
+	}
+	subkeys := privateKey.entity.Subkeys
+	oldFingerprint := hex.EncodeToString(subkeys[0].PublicKey.Fingerprint)
+	newFingerprint := hex.EncodeToString(subkeys[1].PublicKey.Fingerprint)
+
+	encryptTo := func(expected ...uint64) {
+		fingerprints, err := keyRing.GetEncryptionSubkeyFingerprints()
+		if err != nil {
+			t.Fatal("Expected no error when getting encryption subkeys, got:", err)
+		}
+		assert.Len(t, fingerprints, len(expected))
+
+		message, err := keyRing.Encrypt(NewPlainMessageFromString("hello"), keyRingTestPrivate)
+		if err != nil {
+			t.Fatal("Expected no error when encrypting, got:", err)
+		}
+		keyIDs, ok := message.GetEncryptionKeyIDs()
+		assert.True(t, ok)
+		assert.Exactly(t, expected, keyIDs)
+
+		decrypted, err := privateKeyRing.Decrypt(message, keyRingTestPublic, GetUnixTime())
+		if err != nil {
+			t.Fatal("Expected no error when decrypting, got:", err)
+		}
+		assert.Exactly(t, "hello", decrypted.GetString())
+
+		keyPackets, err := keyRing.EncryptSessionKey(&SessionKey{Key: make([]byte, 32), Algo: "aes256"})
+		if err != nil {
+			t.Fatal("Expected no error when encrypting session key, got:", err)
+		}
+		keyIDs, _ = NewPGPMessage(keyPackets).GetEncryptionKeyIDs()
+		assert.Exactly(t, expected, keyIDs)
+	}
+
+	encryptTo(subkeys[1].PublicKey.KeyId)
+
+	keyRing.SetEncryptionSubkeyPolicy(EncryptToAllSubkeys)
+	fingerprints, err := keyRing.GetEncryptionSubkeyFingerprints()
+	if err != nil {
+		t.Fatal("Expected no error when getting encryption subkeys, got:", err)
+	}
+	assert.Exactly(t, []string{oldFingerprint, newFingerprint}, fingerprints)
+	encryptTo(subkeys[0].PublicKey.KeyId, subkeys[1].PublicKey.KeyId)
+
+	if err = keyRing.SetEncryptionSubkeys(FormatFingerprint(oldFingerprint)); err != nil {
+		t.Fatal("Expected no error when selecting subkey, got:", err)
+	}
+	encryptTo(subkeys[0].PublicKey.KeyId)
+
+	keyRingCopy, err := keyRing.Copy()
+	if err != nil {
+		t.Fatal("Expected no error when copying keyring, got:", err)
+	}
+	fingerprints, err = keyRingCopy.GetEncryptionSubkeyFingerprints()
+	if err != nil {
+		t.Fatal("Expected no error when getting encryption subkeys, got:", err)
+	}
+	assert.Exactly(t, []string{oldFingerprint}, fingerprints)
+
+	assert.Error(t, keyRing.SetEncryptionSubkeys("deadbeef"))
+	assert.Error(t, keyRing.SetEncryptionSubkeys(privateKey.GetFingerprint()))
+
+	if err = keyRing.SetEncryptionSubkeys(); err != nil {
+		t.Fatal("Expected no error when clearing selected subkeys, got:", err)
+	}
+	encryptTo(subkeys[0].PublicKey.KeyId, subkeys[1].PublicKey.KeyId)
+}
+
+func TestExpiredSelectedEncryptionSubkey(t *testing.T) {
+	expiringKey, err := keyTestEC.AddEncryptionSubkey("x25519", 256, 3600)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	keyRing, err := NewKeyRing(expiringKey)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	subkeyFingerprint := hex.EncodeToString(expiringKey.entity.Subkeys[1].PublicKey.Fingerprint)
+	if err = keyRing.SetEncryptionSubkeys(subkeyFingerprint); err != nil {
+		t.Fatal("Expected no error when selecting subkey, got:", err)
+	}
+	if _, err = keyRing.Encrypt(NewPlainMessageFromString("hello"), nil); err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	pgp.latestServerTime = testTime + 7200
+	defer func() {
+		pgp.latestServerTime = testTime
+	}()
+
+	_, err = keyRing.Encrypt(NewPlainMessageFromString("hello"), nil)
+	assert.Error(t, err)
+
+	// Only the valid subkeys are encrypted to
+	keyRing.SetEncryptionSubkeyPolicy(EncryptToAllSubkeys)
+	if err = keyRing.SetEncryptionSubkeys(); err != nil {
+		t.Fatal("Expected no error when clearing selected subkeys, got:", err)
+	}
+	fingerprints, err := keyRing.GetEncryptionSubkeyFingerprints()
+	if err != nil {
+		t.Fatal("Expected no error when getting encryption subkeys, got:", err)
+	}
+	assert.Exactly(t, []string{hex.EncodeToString(expiringKey.entity.Subkeys[0].PublicKey.Fingerprint)}, fingerprints)
+}
@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"errors"
+
+	goArmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+var verificationStatusErrorCodes = map[int]int{
+	constants.SIGNATURE_NOT_SIGNED:  constants.ErrorCodeSignatureNotSigned,
+	constants.SIGNATURE_NO_VERIFIER: constants.ErrorCodeSignatureNoVerifier,
+	constants.SIGNATURE_FAILED:      constants.ErrorCodeSignatureFailed,
+	constants.SIGNATURE_EXPIRED:     constants.ErrorCodeSignatureExpired,
+}
+
+// GetErrorCode returns the stable code of an error returned by the library,
+// one of the constants.ErrorCode* values: constants.ErrorCodeNone if err is
+// nil, and constants.ErrorCodeUnknown if the error has no specific code.
+// The error types don't cross the mobile bindings: the errors of the mobile
+// package carry their code in their message instead, see mobile.GetErrorCode.
+func GetErrorCode(err error) int {
+	if err == nil {
+		return constants.ErrorCodeNone
+	}
+
+	var codedErr *internal.CodedError
+	var mdcErr ModificationDetectionError
+	var sigErr SignatureVerificationError
+	var attemptErr PassphraseAttemptError
+	var utf8Err InvalidUTF8Error
+	switch {
+	case errors.As(err, &codedErr):
+		return codedErr.Code
+	case errors.As(err, &mdcErr):
+		if mdcErr.Truncated {
+			return constants.ErrorCodeMessageTruncated
+		}
+		return constants.ErrorCodeMessageModified
+	case errors.Is(err, pgpErrors.ErrMDCHashMismatch):
+		return constants.ErrorCodeMessageModified
+	case errors.Is(err, errUnprotectedMessage):
+		return constants.ErrorCodeUnprotectedMessage
+	case errors.As(err, &sigErr):
+		if code, ok := verificationStatusErrorCodes[sigErr.Status]; ok {
+			return code
+		}
+	case errors.As(err, &attemptErr):
+		return constants.ErrorCodeTooManyAttempts
+	case errors.As(err, &utf8Err):
+		return constants.ErrorCodeInvalidUTF8
+	case errors.Is(err, pgpErrors.ErrKeyIncorrect):
+		return constants.ErrorCodeNoDecryptionKey
+	case errors.Is(err, goArmor.ArmorCorrupt),
+		errors.Is(err, armor.ErrChecksumMismatch),
+		errors.Is(err, armor.ErrMissingChecksum):
+		return constants.ErrorCodeInvalidArmor
+	}
+	return constants.ErrorCodeUnknown
+}
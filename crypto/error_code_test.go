@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestGetErrorCode(t *testing.T) {
+	assert.Exactly(t, constants.ErrorCodeNone, GetErrorCode(nil))
+	assert.Exactly(t, constants.ErrorCodeUnknown, GetErrorCode(errors.New("unknown")))
+
+	lockedKey, err := NewKeyFromArmored(keyTestArmoredEC)
+	if err != nil {
+		t.Fatal("Expected no error when parsing key, got:", err)
+	}
+	_, err = lockedKey.Unlock([]byte("wrong passphrase"))
+	assert.Exactly(t, constants.ErrorCodeIncorrectPassphrase, GetErrorCode(err))
+
+	_, err = NewPGPMessageFromArmored("not armored")
+	assert.Exactly(t, constants.ErrorCodeInvalidArmor, GetErrorCode(err))
+
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("error codes"), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	otherKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	_, err = otherKeyRing.Decrypt(ciphertext, nil, 0)
+	assert.Exactly(t, constants.ErrorCodeNoDecryptionKey, GetErrorCode(err))
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	_, err = otherKeyRing.DecryptSessionKey(split.GetBinaryKeyPacket())
+	assert.Exactly(t, constants.ErrorCodeNoDecryptionKey, GetErrorCode(err))
+
+	data := bytes.Repeat([]byte("error codes "), 1000)
+	ciphertext, err = keyRingTestPublic.Encrypt(NewPlainMessage(data), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	encrypted := ciphertext.GetBinary()
+	_, err = keyRingTestPrivate.Decrypt(NewPGPMessage(encrypted[:len(encrypted)-10]), nil, 0)
+	assert.Exactly(t, constants.ErrorCodeMessageTruncated, GetErrorCode(err))
+	tampered := NewPGPMessage(encrypted)
+	tampered.Data[len(encrypted)-1000] ^= 1
+	_, err = keyRingTestPrivate.Decrypt(tampered, nil, 0)
+	assert.Exactly(t, constants.ErrorCodeMessageModified, GetErrorCode(err))
+
+	_, err = keyRingTestPrivate.Decrypt(ciphertext, keyRingTestPublic, testTime)
+	assert.Exactly(t, constants.ErrorCodeSignatureNotSigned, GetErrorCode(err))
+
+	_, err = keyRingTestPrivate.SignCleartext("\xff", nil)
+	assert.Exactly(t, constants.ErrorCodeInvalidUTF8, GetErrorCode(errors.Wrap(err, "wrapped")))
+}
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pgpWordValues maps the lower case words of the PGP word list to their byte
+// value, for the even and odd positions respectively.
+var pgpWordValues = [2]map[string]byte{
+	pgpWordMap(&pgpWordsEven),
+	pgpWordMap(&pgpWordsOdd),
+}
+
+// FormatFingerprint returns the display form of a hex fingerprint: upper
+// case, in groups of 4 characters separated by spaces, e.g.
+// "C1E5 7C7B 09F6 ...". Spaces and a "0x" prefix in the input are ignored, so
+// an already formatted fingerprint is returned unchanged.
+func FormatFingerprint(fingerprint string) string {
+	fingerprint = normalizeFingerprint(fingerprint)
+
+	groups := make([]string, 0, (len(fingerprint)+3)/4)
+	for len(fingerprint) > 4 {
+		groups = append(groups, fingerprint[:4])
+		fingerprint = fingerprint[4:]
+	}
+	groups = append(groups, fingerprint)
+	return strings.Join(groups, " ")
+}
+
+// FingerprintToPGPWords encodes a hex fingerprint as words of the PGP word
+// list separated by spaces, one word per byte, so that it can be read aloud
+// for verification. Spaces and a "0x" prefix in the input are ignored.
+func FingerprintToPGPWords(fingerprint string) (string, error) {
+	data, err := hex.DecodeString(normalizeFingerprint(fingerprint))
+	if err != nil {
+		return "", errors.Wrap(err, "gopenpgp: error in decoding fingerprint")
+	}
+	return strings.Join(bytesToPGPWords(data), " "), nil
+}
+
+// PGPWordsToFingerprint decodes words of the PGP word list, as encoded by
+// FingerprintToPGPWords, into a lower case hex fingerprint. Words are case
+// insensitive. An error is returned for an unknown word, or a word at the
+// wrong position, which happens when a word was dropped or swapped.
+func PGPWordsToFingerprint(words string) (string, error) {
+	fields := strings.Fields(words)
+	if len(fields) == 0 {
+		return "", errors.New("gopenpgp: no words to decode")
+	}
+	data := make([]byte, len(fields))
+	for i, word := range fields {
+		value, ok := pgpWordValues[i%2][strings.ToLower(word)]
+		if !ok {
+			if _, otherParity := pgpWordValues[(i+1)%2][strings.ToLower(word)]; otherParity {
+				return "", fmt.Errorf("gopenpgp: word %d (%q) of the PGP word list is out of place", i+1, word)
+			}
+			return "", fmt.Errorf("gopenpgp: word %d (%q) is not in the PGP word list", i+1, word)
+		}
+		data[i] = value
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// GetFingerprintPGPWords returns the fingerprint of the primary key encoded
+// as words of the PGP word list, as by FingerprintToPGPWords.
+func (key *Key) GetFingerprintPGPWords() string {
+	return strings.Join(bytesToPGPWords(key.entity.PrimaryKey.Fingerprint), " ")
+}
+
+// GetFormattedFingerprint returns the fingerprint of the primary key in the
+// display form of FormatFingerprint.
+func (key *Key) GetFormattedFingerprint() string {
+	return FormatFingerprint(key.GetFingerprint())
+}
+
+// GetSubkeyFingerprints returns the hex fingerprints of the primary key and
+// the subkeys, in the order of the key.
+func (key *Key) GetSubkeyFingerprints() []string {
+	fingerprints := []string{key.GetFingerprint()}
+	for _, sub := range key.entity.Subkeys {
+		fingerprints = append(fingerprints, hex.EncodeToString(sub.PublicKey.Fingerprint))
+	}
+	return fingerprints
+}
+
+// GetShortHexKeyID returns the short key ID, the last 8 hex characters of the
+// key ID. Short key IDs are easily forged and must only be used for display.
+func (key *Key) GetShortHexKeyID() string {
+	return keyIDToShortHex(key.GetKeyID())
+}
+
+// GetFingerprints returns the hex fingerprints of the primary keys of the
+// keyring, in the order of the keys.
+func (keyRing *KeyRing) GetFingerprints() []string {
+	entities := keyRing.getEntities()
+	fingerprints := make([]string, len(entities))
+	for i, entity := range entities {
+		fingerprints[i] = hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+	}
+	return fingerprints
+}
+
+// GetSHA256Fingerprints returns the SHA256 fingerprints of the keys of the
+// keyring and their subkeys, as returned by Key.GetSHA256Fingerprints for each
+// key in order.
+func (keyRing *KeyRing) GetSHA256Fingerprints() (fingerprints []string) {
+	for _, key := range keyRing.GetKeys() {
+		fingerprints = append(fingerprints, key.GetSHA256Fingerprints()...)
+	}
+	return
+}
+
+// GetHexKeyIDs returns the long key IDs of the primary keys of the keyring,
+// hex encoded on 16 characters.
+func (keyRing *KeyRing) GetHexKeyIDs() []string {
+	keyIDs := keyRing.GetKeyIDs()
+	hexKeyIDs := make([]string, len(keyIDs))
+	for i, keyID := range keyIDs {
+		hexKeyIDs[i] = keyIDToHex(keyID)
+	}
+	return hexKeyIDs
+}
+
+// normalizeFingerprint returns the fingerprint in upper case, without spaces
+// and "0x" prefix.
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	return strings.TrimPrefix(fingerprint, "0X")
+}
+
+// pgpWordMap returns the byte values of the lower case words of a list.
+func pgpWordMap(words *[256]string) map[string]byte {
+	values := make(map[string]byte, len(words))
+	for i, word := range words {
+		values[strings.ToLower(word)] = byte(i)
+	}
+	return values
+}
+
+// keyIDToShortHex casts a keyID to its 8 characters short form.
+func keyIDToShortHex(keyID uint64) string {
+	return keyIDToHex(keyID)[8:]
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatFingerprint(t *testing.T) {
+	formatted := "6E8B A229 B0CC CAF6 962F 9795 3EB6 259E DF21 DF24"
+	assert.Exactly(t, formatted, FormatFingerprint("6e8ba229b0cccaf6962f97953eb6259edf21df24"))
+	assert.Exactly(t, formatted, FormatFingerprint("0x6E8BA229B0CCCAF6962F97953EB6259EDF21DF24"))
+	assert.Exactly(t, formatted, FormatFingerprint(formatted))
+	assert.Exactly(t, "ABCD EF", FormatFingerprint("abcdef"))
+	assert.Exactly(t, "", FormatFingerprint(""))
+}
+
+func TestKeyFingerprints(t *testing.T) {
+	publicKey, err := NewKeyFromArmored(readTestFile("keyring_publicKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+
+	assert.Exactly(t, "6E8B A229 B0CC CAF6 962F 9795 3EB6 259E DF21 DF24", publicKey.GetFormattedFingerprint())
+	assert.Exactly(t, []string{
+		"6e8ba229b0cccaf6962f97953eb6259edf21df24",
+		"37e4bcf09b36e34012d10c0247dc67b5cb8267f6",
+	}, publicKey.GetSubkeyFingerprints())
+	assert.Exactly(t, "3eb6259edf21df24", publicKey.GetHexKeyID())
+	assert.Exactly(t, "df21df24", publicKey.GetShortHexKeyID())
+	assert.Exactly(t, "0000cafe", keyIDToShortHex(0xcafe))
+}
+
+func TestKeyRingFingerprints(t *testing.T) {
+	publicKey, err := NewKeyFromArmored(readTestFile("keyring_publicKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	keyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	if err = keyRing.AddKey(keyTestEC); err != nil {
+		t.Fatal("Expected no error when adding key, got:", err)
+	}
+
+	assert.Exactly(t, []string{publicKey.GetFingerprint(), keyTestEC.GetFingerprint()}, keyRing.GetFingerprints())
+	assert.Exactly(t, []string{publicKey.GetHexKeyID(), keyTestEC.GetHexKeyID()}, keyRing.GetHexKeyIDs())
+	assert.Exactly(t, append(publicKey.GetSHA256Fingerprints(), keyTestEC.GetSHA256Fingerprints()...), keyRing.GetSHA256Fingerprints())
+}
+
+func TestFingerprintPGPWords(t *testing.T) {
+	fingerprint := "E582 94F2 E9A2 2748 6E8B 061B 31CC 528F D7FA 3F19"
+	words := "topmost Istanbul Pluto vagabond treadmill Pacific brackish dictator goldfish Medusa " +
+		"afflict bravado chatter revolver Dupont midsummer stopwatch whimsical cowbell bottomless"
+
+	encoded, err := FingerprintToPGPWords(fingerprint)
+	if err != nil {
+		t.Fatal("Expected no error when encoding fingerprint, got:", err)
+	}
+	assert.Exactly(t, words, encoded)
+
+	decoded, err := PGPWordsToFingerprint(strings.ToUpper(words))
+	if err != nil {
+		t.Fatal("Expected no error when decoding words, got:", err)
+	}
+	assert.Exactly(t, "e58294f2e9a227486e8b061b31cc528fd7fa3f19", decoded)
+
+	_, err = FingerprintToPGPWords("not hex")
+	assert.Error(t, err)
+	_, err = PGPWordsToFingerprint("")
+	assert.Error(t, err)
+	_, err = PGPWordsToFingerprint("topmost unknown")
+	assert.EqualError(t, err, `gopenpgp: word 2 ("unknown") is not in the PGP word list`)
+	_, err = PGPWordsToFingerprint("Istanbul topmost")
+	assert.EqualError(t, err, `gopenpgp: word 1 ("Istanbul") of the PGP word list is out of place`)
+
+	publicKey, err := NewKeyFromArmored(readTestFile("keyring_publicKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	decoded, err = PGPWordsToFingerprint(publicKey.GetFingerprintPGPWords())
+	if err != nil {
+		t.Fatal("Expected no error when decoding words, got:", err)
+	}
+	assert.Exactly(t, publicKey.GetFingerprint(), decoded)
+}
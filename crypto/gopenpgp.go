@@ -1,14 +1,34 @@
 // Package crypto provides a high-level API for common OpenPGP functionality.
 package crypto
 
-import "sync"
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
 
 // GopenPGP is used as a "namespace" for many of the functions in this package.
 // It is a struct that keeps track of time skew between server and client.
+// The time state is accessed atomically, as it is read by every operation:
+// the int64 fields are kept first to be 64-bit aligned on 32-bit platforms.
+// The other fields are guarded by lock.
 type GopenPGP struct {
 	latestServerTime int64
 	generationOffset int64
-	lock             *sync.RWMutex
+	// Tolerance, in seconds, of the key and signature expiration checks
+	clockSkewTolerance int64
+	// Clock replacing the latest server time, if set, as a clockValue
+	clock atomic.Value
+	// Whether signing subkeys without cross-certification are accepted
+	allowLegacySigningSubkeys bool
+	// Whether messages without integrity protection are decrypted
+	allowUnprotectedMessages bool
+	// Memory budget of the memory-limited mode, and directory of its
+	// temporary files
+	memoryBudget int64
+	tempDir      string
+	lock         *sync.RWMutex
 }
 
 var pgp = GopenPGP{
@@ -24,3 +44,55 @@ func clone(input []byte) []byte {
 	copy(data, input)
 	return data
 }
+
+// copyBufferSize is the size of the pooled buffers used to copy streams, the
+// size io.Copy allocates for each copy.
+const copyBufferSize = 32 * 1024
+
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, copyBufferSize)
+		return &buffer
+	},
+}
+
+// copyPooled copies src to dst like io.Copy, with a pooled buffer instead of a
+// new one for each copy.
+func copyPooled(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buffer)
+	return io.CopyBuffer(dst, src, *buffer)
+}
+
+// writeChunked writes data to w in chunks of copyBufferSize bytes: the
+// packet writers of go-crypto buffer each write whole, a large write would be
+// buffered in every packet layer.
+func writeChunked(w io.Writer, data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > copyBufferSize {
+			chunk = chunk[:copyBufferSize]
+		}
+		n, err := w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		data = data[n:]
+	}
+	return written, nil
+}
+
+// readAllWithSizeHint reads r until EOF like ioutil.ReadAll, in a buffer
+// allocated once for sizeHint bytes, so that reading up to sizeHint bytes
+// doesn't grow and copy the buffer.
+func readAllWithSizeHint(r io.Reader, sizeHint int) ([]byte, error) {
+	var buffer bytes.Buffer
+	if sizeHint > 0 {
+		// ReadFrom needs bytes.MinRead free bytes to read the EOF
+		buffer.Grow(sizeHint + bytes.MinRead)
+	}
+	_, err := buffer.ReadFrom(r)
+	return buffer.Bytes(), err
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"bytes"
+	goerrors "errors"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// EncryptWithHiddenRecipients encrypts a PlainMessage like Encrypt, but
+// replaces the key IDs of the recipients by the wildcard key ID 0, so that
+// the message doesn't reveal who it is encrypted to.
+// Recipients try all their decryption keys on such a message, and the
+// signature, if any, doesn't list their fingerprints either.
+// * message    : The plaintext input as a PlainMessage.
+// * privateKey : (optional) an unlocked private keyring to include signature in the message.
+func (keyRing *KeyRing) EncryptWithHiddenRecipients(message *PlainMessage, privateKey *KeyRing) (*PGPMessage, error) {
+	config := &packet.Config{DefaultCipher: packet.CipherAES256, Time: getTimeGenerator()}
+	encrypted, err := asymmetricEncrypt(message, keyRing, privateKey, config, false)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden, err := hideRecipients(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPMessage(hidden), nil
+}
+
+// EncryptSessionKeyWithHiddenRecipients encrypts the session key like
+// EncryptSessionKey, with the wildcard key ID 0 in place of the key IDs of
+// the recipients.
+func (keyRing *KeyRing) EncryptSessionKeyWithHiddenRecipients(sk *SessionKey) ([]byte, error) {
+	keyPacket, err := keyRing.EncryptSessionKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return hideRecipients(keyPacket)
+}
+
+// hideRecipients returns a copy of the binary message or key packets data,
+// with the key IDs of its public-key encrypted session key packets replaced
+// by the wildcard key ID. The packets following the session key packets are
+// copied as is.
+func hideRecipients(data []byte) ([]byte, error) {
+	var outBuf bytes.Buffer
+	bytesReader := bytes.NewReader(data)
+	packets := packet.NewReader(bytesReader)
+
+Loop:
+	for {
+		start := bytesReader.Size() - int64(bytesReader.Len())
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			return outBuf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in reading session key packets")
+		}
+		end := bytesReader.Size() - int64(bytesReader.Len())
+
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			p.KeyId = 0
+			if err := p.Serialize(&outBuf); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: error in serializing session key packet")
+			}
+		case *packet.SymmetricKeyEncrypted:
+			outBuf.Write(data[start:end])
+		default:
+			outBuf.Write(data[start:])
+			break Loop
+		}
+	}
+
+	return outBuf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptWithHiddenRecipients(t *testing.T) {
+	otherKey, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 256)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	recipientKey, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 256)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	recipientKeyRing, err := NewKeyRing(recipientKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	decryptionKeyRing, err := NewKeyRing(otherKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	if err = decryptionKeyRing.AddKey(recipientKey); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+
+	message := NewPlainMessageFromString("hidden recipient")
+	ciphertext, err := recipientKeyRing.EncryptWithHiddenRecipients(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	ids, ok := ciphertext.GetEncryptionKeyIDs()
+	assert.True(t, ok)
+	assert.Exactly(t, []uint64{0}, ids)
+
+	decrypted, err := decryptionKeyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	sessionKey, err := decryptionKeyRing.DecryptSessionKey(split.GetBinaryKeyPacket())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting session key, got:", err)
+	}
+
+	keyPacket, err := recipientKeyRing.EncryptSessionKeyWithHiddenRecipients(sessionKey)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	ids, ok = NewPGPMessage(keyPacket).GetEncryptionKeyIDs()
+	assert.True(t, ok)
+	assert.Exactly(t, []uint64{0}, ids)
+
+	decryptedSessionKey, err := decryptionKeyRing.DecryptSessionKey(keyPacket)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting session key, got:", err)
+	}
+	assert.Exactly(t, sessionKey.Key, decryptedSessionKey.Key)
+
+	_, err = keyRingTestPrivate.Decrypt(ciphertext, nil, 0)
+	assert.Error(t, err)
+
+	// The signature doesn't list the fingerprints of the recipients either
+	signingKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	ciphertext, err = recipientKeyRing.EncryptWithHiddenRecipients(message, signingKeyRing)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	keys := append(append(openpgp.EntityList{}, decryptionKeyRing.getEntities()...), signingKeyRing.getEntities()...)
+	md, err := openpgp.ReadMessage(ciphertext.NewReader(), keys, nil, nil)
+	if err != nil {
+		t.Fatal("Expected no error when reading message, got:", err)
+	}
+	if _, err = ioutil.ReadAll(md.UnverifiedBody); err != nil {
+		t.Fatal("Expected no error when reading body, got:", err)
+	}
+	if md.SignatureError != nil {
+		t.Fatal("Expected no signature error, got:", md.SignatureError)
+	}
+	assert.Empty(t, getHashedSubpackets(md.Signature, intendedRecipientSubpacket))
+}
@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// intendedRecipientSubpacket is the Intended Recipient Fingerprint signature
+// subpacket type, see draft-ietf-openpgp-crypto-refresh, section 5.2.3.36.
+const intendedRecipientSubpacket = 35
+
+// newSignatureWrongRecipient creates a new SignatureVerificationError, type
+// SignatureFailed, for a signature made for other recipients than the
+// decryption key, e.g. a signed message forwarded by one of its recipients.
+func newSignatureWrongRecipient() SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: "Invalid signature: the message was signed for a different recipient",
+	}
+}
+
+// checkIntendedRecipient checks that the decryption key of an encrypted and
+// signed message is one of the intended recipients of its signature, if the
+// signature lists them.
+func checkIntendedRecipient(md *openpgp.MessageDetails) error {
+	if md.Signature == nil || md.DecryptedWith.Entity == nil {
+		return nil
+	}
+	recipients := getHashedSubpackets(md.Signature, intendedRecipientSubpacket)
+	if len(recipients) == 0 {
+		return nil
+	}
+	fingerprint := md.DecryptedWith.Entity.PrimaryKey.Fingerprint
+	for _, recipient := range recipients {
+		// Version octet, then fingerprint
+		if len(recipient) > 1 && bytes.Equal(recipient[1:], fingerprint) {
+			return nil
+		}
+	}
+	return newSignatureWrongRecipient()
+}
+
+// intendedRecipientSubpackets returns the serialized Intended Recipient
+// Fingerprint subpackets of the primary keys of recipients.
+func intendedRecipientSubpackets(recipients openpgp.EntityList) []byte {
+	var subpackets []byte
+	// A key is listed once, even if several of its subkeys are encrypted to
+	listed := make(map[string]bool, len(recipients))
+	for _, recipient := range recipients {
+		primaryKey := recipient.PrimaryKey
+		if listed[string(primaryKey.Fingerprint)] {
+			continue
+		}
+		listed[string(primaryKey.Fingerprint)] = true
+		contents := append([]byte{byte(primaryKey.Version)}, primaryKey.Fingerprint...)
+		subpackets = append(subpackets, serializeSubpacket(intendedRecipientSubpacket, false, contents)...)
+	}
+	return subpackets
+}
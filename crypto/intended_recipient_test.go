@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestIntendedRecipientFingerprint(t *testing.T) {
+	signingKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	forwardedKey, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 256)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	forwardedKeyRing, err := NewKeyRing(forwardedKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+
+	// Line endings of text messages are canonicalized in the literal data
+	message := &PlainMessage{Data: []byte("first line\nsecond line\r\n"), TextType: true}
+	ciphertext, err := keyRingTestPublic.Encrypt(message, signingKeyRing)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	decrypted, err := keyRingTestPrivate.Decrypt(ciphertext, signingKeyRing, GetUnixTime())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, "first line\r\nsecond line\r\n", string(decrypted.GetBinary()))
+
+	// The recipient forwards the message, signed for them, to another key
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	sessionKey, err := keyRingTestPrivate.DecryptSessionKey(split.GetBinaryKeyPacket())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting session key, got:", err)
+	}
+	keyPacket, err := forwardedKeyRing.EncryptSessionKey(sessionKey)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	forwarded := NewPGPSplitMessage(keyPacket, split.GetBinaryDataPacket()).GetPGPMessage()
+
+	decrypted, err = forwardedKeyRing.Decrypt(forwarded, signingKeyRing, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+	assert.Exactly(t, newSignatureWrongRecipient(), err)
+	assert.Exactly(t, "first line\r\nsecond line\r\n", string(decrypted.GetBinary()))
+
+	binaryMessage := NewPlainMessage([]byte("binary\ndata"))
+	ciphertext, err = keyRingTestPublic.Encrypt(binaryMessage, signingKeyRing)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	decrypted, err = keyRingTestPrivate.Decrypt(ciphertext, signingKeyRing, GetUnixTime())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, binaryMessage.GetBinary(), decrypted.GetBinary())
+}
@@ -7,12 +7,16 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ProtonMail/gopenpgp/v2/armor"
 	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 	"github.com/pkg/errors"
 
 	openpgp "github.com/ProtonMail/go-crypto/openpgp"
@@ -23,6 +27,9 @@ import (
 type Key struct {
 	// PGP entities in this keyring.
 	entity *openpgp.Entity
+
+	// User attributes, e.g. photo IDs, which the entity can't hold
+	userAttributes []*userAttribute
 }
 
 // --- Create Key object
@@ -72,14 +79,24 @@ func GenerateRSAKeyWithPrimes(
 	bits int,
 	primeone, primetwo, primethree, primefour []byte,
 ) (*Key, error) {
-	return generateKey(name, email, "rsa", bits, primeone, primetwo, primethree, primefour)
+	return generateKey(name, email, "rsa", bits, 0, nil, primeone, primetwo, primethree, primefour)
 }
 
 // GenerateKey generates a key of the given keyType ("rsa" or "x25519").
 // If keyType is "rsa", bits is the RSA bitsize of the key.
 // If keyType is "x25519" bits is unused.
 func GenerateKey(name, email string, keyType string, bits int) (*Key, error) {
-	return generateKey(name, email, keyType, bits, nil, nil, nil, nil)
+	return generateKey(name, email, keyType, bits, 0, nil, nil, nil, nil, nil)
+}
+
+// GenerateKeyWithExpiration generates a key of the given keyType ("rsa" or "x25519")
+// that expires lifetimeSecs seconds after its creation.
+// If lifetimeSecs is 0, the key does not expire.
+func GenerateKeyWithExpiration(name, email string, keyType string, bits int, lifetimeSecs int64) (*Key, error) {
+	if lifetimeSecs < 0 || lifetimeSecs > math.MaxUint32 {
+		return nil, errors.New("gopenpgp: invalid key lifetime")
+	}
+	return generateKey(name, email, keyType, bits, uint32(lifetimeSecs), nil, nil, nil, nil, nil)
 }
 
 // --- Operate on key
@@ -90,8 +107,10 @@ func (key *Key) Copy() (*Key, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The serialization of unlocked keys holds their secret material
+	defer clearMem(serialized)
 
-	return NewKey(serialized)
+	return NewKeyFromReader(bytes.NewReader(serialized))
 }
 
 // Lock locks a copy of the key.
@@ -162,14 +181,18 @@ func (key *Key) Unlock(passphrase []byte) (*Key, error) {
 	if unlockedKey.entity.PrivateKey != nil && !unlockedKey.entity.PrivateKey.Dummy() {
 		err = unlockedKey.entity.PrivateKey.Decrypt(passphrase)
 		if err != nil {
-			return nil, errors.Wrap(err, "gopenpgp: error in unlocking key")
+			return nil, internal.WithErrorCode(
+				constants.ErrorCodeIncorrectPassphrase, errors.Wrap(err, "gopenpgp: error in unlocking key"),
+			)
 		}
 	}
 
 	for _, sub := range unlockedKey.entity.Subkeys {
 		if sub.PrivateKey != nil && !sub.PrivateKey.Dummy() {
 			if err := sub.PrivateKey.Decrypt(passphrase); err != nil {
-				return nil, errors.Wrap(err, "gopenpgp: error in unlocking sub key")
+				return nil, internal.WithErrorCode(
+					constants.ErrorCodeIncorrectPassphrase, errors.Wrap(err, "gopenpgp: error in unlocking sub key"),
+				)
 			}
 		}
 	}
@@ -185,6 +208,226 @@ func (key *Key) Unlock(passphrase []byte) (*Key, error) {
 	return unlockedKey, nil
 }
 
+// UpdateExpiration returns a copy of the key that expires lifetimeSecs seconds
+// from now, by re-issuing the self-signatures of the user IDs and the binding
+// signatures of the subkeys. If lifetimeSecs is 0, the key does not expire.
+// The key must be unlocked.
+func (key *Key) UpdateExpiration(lifetimeSecs int64) (*Key, error) {
+	if lifetimeSecs < 0 {
+		return nil, errors.New("gopenpgp: invalid key lifetime")
+	}
+
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	now := getNow()
+	entity := updatedKey.entity
+	config := &packet.Config{Time: getTimeGenerator()}
+
+	for _, identity := range entity.Identities {
+		if identity.SelfSignature == nil {
+			continue
+		}
+		selfSig, err := reissueSignature(identity.SelfSignature, entity.PrimaryKey, now, lifetimeSecs)
+		if err != nil {
+			return nil, err
+		}
+		if err = selfSig.SignUserId(identity.UserId.Id, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in signing user ID")
+		}
+		for i, sig := range identity.Signatures {
+			if sig == identity.SelfSignature {
+				identity.Signatures[i] = selfSig
+			}
+		}
+		identity.SelfSignature = selfSig
+	}
+
+	for i := range entity.Subkeys {
+		subkey := &entity.Subkeys[i]
+		if subkey.Sig == nil || subkey.Revoked(now) {
+			continue
+		}
+		bindingSig, err := reissueSignature(subkey.Sig, subkey.PublicKey, now, lifetimeSecs)
+		if err != nil {
+			return nil, err
+		}
+		if err = bindingSig.SignKey(subkey.PublicKey, entity.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in signing subkey")
+		}
+		subkey.Sig = bindingSig
+	}
+
+	return updatedKey, nil
+}
+
+// AddUserID returns a copy of the key with a new user ID, certified by a
+// self-signature with the same preferences as the primary user ID.
+// The key must be unlocked.
+func (key *Key) AddUserID(name, comment, email string) (*Key, error) {
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	uid := packet.NewUserId(name, comment, email)
+	if uid == nil {
+		return nil, errors.New("gopenpgp: invalid user ID")
+	}
+	if _, ok := key.entity.Identities[uid.Id]; ok {
+		return nil, errors.New("gopenpgp: user ID already exists")
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	entity := updatedKey.entity
+	primaryIdentity := entity.PrimaryIdentity()
+	if primaryIdentity == nil || primaryIdentity.SelfSignature == nil {
+		return nil, errors.New("gopenpgp: no self-signature found")
+	}
+
+	selfSig := *primaryIdentity.SelfSignature
+	selfSig.SigType = packet.SigTypePositiveCert
+	selfSig.CreationTime = getNow()
+	selfSig.IsPrimaryId = nil
+	selfSig.SigLifetimeSecs = nil
+	selfSig.RevocationReason = nil
+	selfSig.RevocationReasonText = ""
+	selfSig.IssuerKeyId = &entity.PrimaryKey.KeyId
+	selfSig.IssuerFingerprint = entity.PrimaryKey.Fingerprint
+
+	config := &packet.Config{Time: getTimeGenerator()}
+	if err = selfSig.SignUserId(uid.Id, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in signing user ID")
+	}
+
+	entity.Identities[uid.Id] = &openpgp.Identity{
+		Name:          uid.Id,
+		UserId:        uid,
+		SelfSignature: &selfSig,
+		Signatures:    []*packet.Signature{&selfSig},
+	}
+
+	return updatedKey, nil
+}
+
+// RevokeUserID returns a copy of the key where the given user ID,
+// e.g. "name <email>", is revoked with a certification revocation signature.
+// The last valid user ID of a key cannot be revoked.
+// The key must be unlocked.
+func (key *Key) RevokeUserID(userID, reasonText string) (*Key, error) {
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	entity := updatedKey.entity
+	now := getNow()
+	identity, ok := entity.Identities[userID]
+	if !ok {
+		return nil, errors.New("gopenpgp: user ID not found")
+	}
+	if identity.Revoked(now) {
+		return nil, errors.New("gopenpgp: user ID is already revoked")
+	}
+
+	valid := 0
+	for _, other := range entity.Identities {
+		if !other.Revoked(now) {
+			valid++
+		}
+	}
+	if valid < 2 {
+		return nil, errors.New("gopenpgp: cannot revoke the last valid user ID")
+	}
+
+	reason := packet.ReasonForRevocation(constants.RevocationUserIDNotValid)
+	revSig := &packet.Signature{
+		Version:              entity.PrimaryKey.Version,
+		SigType:              packet.SigTypeCertificationRevocation,
+		PubKeyAlgo:           entity.PrimaryKey.PubKeyAlgo,
+		Hash:                 crypto.SHA256,
+		CreationTime:         now,
+		RevocationReason:     &reason,
+		RevocationReasonText: reasonText,
+		IssuerKeyId:          &entity.PrimaryKey.KeyId,
+		IssuerFingerprint:    entity.PrimaryKey.Fingerprint,
+	}
+
+	config := &packet.Config{Time: getTimeGenerator()}
+	if err = revSig.SignUserId(userID, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in revoking user ID")
+	}
+
+	identity.Revocations = append(identity.Revocations, revSig)
+	identity.Signatures = append(identity.Signatures, revSig)
+
+	return updatedKey, nil
+}
+
+// AddEncryptionSubkey returns a copy of the key with a new encryption subkey
+// of the given keyType ("rsa" or "x25519"), that expires lifetimeSecs seconds
+// after its creation. If lifetimeSecs is 0, the subkey does not expire.
+// The key must be unlocked.
+func (key *Key) AddEncryptionSubkey(keyType string, bits int, lifetimeSecs int64) (*Key, error) {
+	return key.addSubkey(keyType, bits, lifetimeSecs, false)
+}
+
+// AddSigningSubkey returns a copy of the key with a new signing subkey
+// of the given keyType ("rsa" or "x25519"), that expires lifetimeSecs seconds
+// after its creation. If lifetimeSecs is 0, the subkey does not expire.
+// The key must be unlocked.
+func (key *Key) AddSigningSubkey(keyType string, bits int, lifetimeSecs int64) (*Key, error) {
+	return key.addSubkey(keyType, bits, lifetimeSecs, true)
+}
+
+// RevokeSubkey returns a copy of the key where the subkey with the given hex
+// key ID is revoked, with one of the constants.Revocation* reasons.
+// The key must be unlocked.
+func (key *Key) RevokeSubkey(hexKeyID string, reason int, reasonText string) (*Key, error) {
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	keyID, err := strconv.ParseUint(hexKeyID, 16, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid key ID")
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	entity := updatedKey.entity
+	for i := range entity.Subkeys {
+		subkey := &entity.Subkeys[i]
+		if subkey.PublicKey.KeyId != keyID {
+			continue
+		}
+		config := &packet.Config{Time: getTimeGenerator(), DefaultHash: crypto.SHA256}
+		err = entity.RevokeSubkey(subkey, packet.ReasonForRevocation(reason), reasonText, config)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in revoking subkey")
+		}
+		return updatedKey, nil
+	}
+
+	return nil, errors.New("gopenpgp: subkey not found")
+}
+
 // --- Export key
 
 func (key *Key) Serialize() ([]byte, error) {
@@ -201,7 +444,7 @@ func (key *Key) Serialize() ([]byte, error) {
 		return nil, errors.Wrap(err, "gopenpgp: error in serializing key")
 	}
 
-	return buffer.Bytes(), nil
+	return insertUserAttributes(key.userAttributes, buffer.Bytes(), false)
 }
 
 // Armor returns the armored key as a string with default gopenpgp headers.
@@ -229,6 +472,21 @@ func (key *Key) ArmorWithCustomHeaders(comment, version string) (string, error)
 	return armor.ArmorWithTypeAndCustomHeaders(serialized, constants.PrivateKeyHeader, version, comment)
 }
 
+// ArmorWithOptions returns the armored key as a string, with the headers of
+// options.
+func (key *Key) ArmorWithOptions(options *armor.Options) (string, error) {
+	serialized, err := key.Serialize()
+	if err != nil {
+		return "", err
+	}
+
+	if key.IsPrivate() {
+		return armor.ArmorWithTypeAndOptions(serialized, constants.PrivateKeyHeader, options)
+	}
+
+	return armor.ArmorWithTypeAndOptions(serialized, constants.PublicKeyHeader, options)
+}
+
 // GetArmoredPublicKey returns the armored public keys from this keyring.
 func (key *Key) GetArmoredPublicKey() (s string, err error) {
 	serialized, err := key.GetPublicKey()
@@ -250,35 +508,104 @@ func (key *Key) GetArmoredPublicKeyWithCustomHeaders(comment, version string) (s
 	return armor.ArmorWithTypeAndCustomHeaders(serialized, constants.PublicKeyHeader, version, comment)
 }
 
+// GetArmoredPublicKeyWithOptions returns the armored public key as a string,
+// with the headers of options.
+func (key *Key) GetArmoredPublicKeyWithOptions(options *armor.Options) (string, error) {
+	serialized, err := key.GetPublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	return armor.ArmorWithTypeAndOptions(serialized, constants.PublicKeyHeader, options)
+}
+
 // GetPublicKey returns the unarmored public keys from this keyring.
+// Local certifications are stripped.
 func (key *Key) GetPublicKey() (b []byte, err error) {
 	var outBuf bytes.Buffer
-	if err = key.entity.Serialize(&outBuf); err != nil {
+	if err = exportableEntity(key.entity).Serialize(&outBuf); err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in serializing public key")
 	}
 
+	return insertUserAttributes(key.userAttributes, outBuf.Bytes(), true)
+}
+
+// GetMinimalPublicKey returns the smallest valid unarmored public key, to be
+// embedded in email headers or QR codes: third-party certifications, secondary
+// user IDs, photo IDs, and revoked or expired subkeys are stripped.
+func (key *Key) GetMinimalPublicKey() ([]byte, error) {
+	now := getNow()
+	isUsable := func(subkey *openpgp.Subkey) bool {
+		return !subkey.Revoked(now) && !isKeyExpired(subkey.PublicKey, subkey.Sig, now)
+	}
+
+	var outBuf bytes.Buffer
+	if err := serializeMinimalPublicKey(&outBuf, key.entity, isUsable); err != nil {
+		return nil, err
+	}
+
 	return outBuf.Bytes(), nil
 }
 
+// GetArmoredMinimalPublicKey returns the armored minimal public key, as
+// returned by GetMinimalPublicKey.
+func (key *Key) GetArmoredMinimalPublicKey() (string, error) {
+	serialized, err := key.GetMinimalPublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	return armor.ArmorWithType(serialized, constants.PublicKeyHeader)
+}
+
 // --- Key object properties
 
 // CanVerify returns true if any of the subkeys can be used for verification.
 func (key *Key) CanVerify() bool {
-	_, canVerify := key.entity.SigningKey(getNow())
+	return key.CanVerifyAt(GetUnixTime())
+}
+
+// CanVerifyAt returns true if any of the subkeys can be used for verification
+// at the given unix time, according to their key flags, expiration and
+// revocation.
+func (key *Key) CanVerifyAt(unixTime int64) bool {
+	_, canVerify := signingKeyAt(key.entity, time.Unix(unixTime, 0), 0)
 	return canVerify
 }
 
 // CanEncrypt returns true if any of the subkeys can be used for encryption.
 func (key *Key) CanEncrypt() bool {
-	_, canEncrypt := key.entity.EncryptionKey(getNow())
+	return key.CanEncryptAt(GetUnixTime())
+}
+
+// CanEncryptAt returns true if any of the subkeys can be used for encryption
+// at the given unix time, according to their key flags, expiration and
+// revocation.
+func (key *Key) CanEncryptAt(unixTime int64) bool {
+	_, canEncrypt := encryptionKeyAt(key.entity, time.Unix(unixTime, 0))
 	return canEncrypt
 }
 
-// IsExpired checks whether the key is expired.
+// CanSign returns true if the key can be used for signing: one of its subkeys
+// can sign, and its secret material is available and unlocked.
+func (key *Key) CanSign() bool {
+	return key.CanSignAt(GetUnixTime())
+}
+
+// CanSignAt returns true if the key can be used for signing at the given unix
+// time, as CanVerifyAt, and the secret material of the signing subkey is
+// available and unlocked.
+func (key *Key) CanSignAt(unixTime int64) bool {
+	signingKey, ok := signingKeyAt(key.entity, time.Unix(unixTime, 0), 0)
+	return ok && signingKey.PrivateKey != nil && !signingKey.PrivateKey.Dummy() && !signingKey.PrivateKey.Encrypted
+}
+
+// IsExpired checks whether the key is expired, with the clock skew tolerance.
 func (key *Key) IsExpired() bool {
 	i := key.entity.PrimaryIdentity()
-	return key.entity.PrimaryKey.KeyExpired(i.SelfSignature, getNow()) || // primary key has expired
-		i.SelfSignature.SigExpired(getNow()) // user ID self-signature has expired
+	now := getNow()
+	return isKeyExpired(key.entity.PrimaryKey, i.SelfSignature, now) || // primary key has expired
+		isSignatureExpired(i.SelfSignature, now) // user ID self-signature has expired
 }
 
 // IsRevoked checks whether the key or the primary identity has a valid revocation signature.
@@ -291,6 +618,14 @@ func (key *Key) IsPrivate() bool {
 	return key.entity.PrivateKey != nil
 }
 
+// IsPrimaryKeyDummy checks if the secret primary key of a private key is
+// missing, replaced by a gnu-dummy stub, e.g. in the offline primary key
+// workflow of `gpg --export-secret-subkeys`. Such keys can still sign and
+// decrypt with their subkeys, but can't certify.
+func (key *Key) IsPrimaryKeyDummy() bool {
+	return key.entity.PrivateKey != nil && key.entity.PrivateKey.Dummy()
+}
+
 // IsLocked checks if a private key is locked.
 func (key *Key) IsLocked() (bool, error) {
 	if key.entity.PrivateKey == nil {
@@ -374,6 +709,37 @@ func (key *Key) GetSHA256Fingerprints() (fingerprints []string) {
 	return
 }
 
+// GetPrimaryIdentity returns the primary identity of the key, or nil if it
+// has none. It is, in order of preference, a non-revoked identity, marked as
+// primary by its self-signature, with the most recent self-signature.
+func (key *Key) GetPrimaryIdentity() *Identity {
+	primaryIdentity := key.entity.PrimaryIdentity()
+	if primaryIdentity == nil {
+		return nil
+	}
+	return newIdentity(primaryIdentity)
+}
+
+// GetIdentities returns the identities of the key, with the primary identity
+// of GetPrimaryIdentity first, then the others sorted by user ID.
+func (key *Key) GetIdentities() []*Identity {
+	primaryIdentity := key.entity.PrimaryIdentity()
+	var identities []*Identity
+	var otherUserIDs []string
+	for name, identity := range key.entity.Identities {
+		if identity == primaryIdentity {
+			identities = append(identities, newIdentity(identity))
+		} else {
+			otherUserIDs = append(otherUserIDs, name)
+		}
+	}
+	sort.Strings(otherUserIDs)
+	for _, name := range otherUserIDs {
+		identities = append(identities, newIdentity(key.entity.Identities[name]))
+	}
+	return identities
+}
+
 // GetEntity gets x/crypto Entity object.
 func (key *Key) GetEntity() *openpgp.Entity {
 	return key.entity
@@ -396,6 +762,14 @@ func (key *Key) ToPublic() (publicKey *Key, err error) {
 
 // --- Internal methods
 
+// newIdentity returns the name and email of an identity.
+func newIdentity(identity *openpgp.Identity) *Identity {
+	return &Identity{
+		Name:  identity.UserId.Name,
+		Email: identity.UserId.Email,
+	}
+}
+
 // getSHA256FingerprintBytes computes the SHA256 fingerprint of a public key
 // object.
 func getSHA256FingerprintBytes(pk *packet.PublicKey) []byte {
@@ -407,28 +781,122 @@ func getSHA256FingerprintBytes(pk *packet.PublicKey) []byte {
 	return fingerPrint.Sum(nil)
 }
 
-// readFrom reads unarmored and armored keys from r and adds them to the keyring.
-func (key *Key) readFrom(r io.Reader, armored bool) error {
-	var err error
-	var entities openpgp.EntityList
-	if armored {
-		entities, err = openpgp.ReadArmoredKeyRing(r)
+// addSubkey returns a copy of the key with a new signing or encryption subkey.
+func (key *Key) addSubkey(keyType string, bits int, lifetimeSecs int64, forSigning bool) (*Key, error) {
+	if lifetimeSecs < 0 || lifetimeSecs > math.MaxUint32 {
+		return nil, errors.New("gopenpgp: invalid key lifetime")
+	}
+
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newGenerationConfig(keyType, bits, uint32(lifetimeSecs))
+	if forSigning {
+		err = updatedKey.entity.AddSigningSubkey(cfg)
 	} else {
-		entities, err = openpgp.ReadKeyRing(r)
+		err = updatedKey.entity.AddEncryptionSubkey(cfg)
 	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in generating subkey")
+	}
+
+	return updatedKey, nil
+}
+
+// revocationSignature returns a key revocation signature of the primary key.
+func (key *Key) revocationSignature(reason int, reasonText string) (*packet.Signature, error) {
+	if reason < constants.RevocationNoReason || reason > constants.RevocationKeyRetired {
+		return nil, errors.New("gopenpgp: invalid reason for key revocation")
+	}
+
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	entity := key.entity
+	revocationReason := packet.ReasonForRevocation(reason)
+	revSig := &packet.Signature{
+		Version:              entity.PrimaryKey.Version,
+		SigType:              packet.SigTypeKeyRevocation,
+		PubKeyAlgo:           entity.PrimaryKey.PubKeyAlgo,
+		Hash:                 crypto.SHA256,
+		CreationTime:         getNow(),
+		RevocationReason:     &revocationReason,
+		RevocationReasonText: reasonText,
+		IssuerKeyId:          &entity.PrimaryKey.KeyId,
+		IssuerFingerprint:    entity.PrimaryKey.Fingerprint,
+	}
+
+	config := &packet.Config{Time: getTimeGenerator()}
+	if err := revSig.RevokeKey(entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in generating revocation signature")
+	}
+
+	return revSig, nil
+}
+
+// checkCanCertify checks that the primary key can issue self-signatures.
+func (key *Key) checkCanCertify() error {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return err
+	}
+	if key.entity.PrivateKey.Dummy() {
+		return errors.New("gopenpgp: the secret primary key is missing (gnu-dummy)")
+	}
+	if !unlocked {
+		return errors.New("gopenpgp: key must be unlocked to be modified")
+	}
+	return nil
+}
+
+// reissueSignature returns an unsigned copy of a self-signature, created at now,
+// stating that pk expires lifetimeSecs seconds after now.
+func reissueSignature(
+	sig *packet.Signature, pk *packet.PublicKey, now time.Time, lifetimeSecs int64,
+) (*packet.Signature, error) {
+	newSig := *sig
+	newSig.CreationTime = now
+	if !now.After(sig.CreationTime) {
+		// The most recent self-signature takes precedence
+		newSig.CreationTime = sig.CreationTime.Add(time.Second)
+	}
+
+	var keyLifetimeSecs uint32
+	if lifetimeSecs > 0 {
+		expiration := now.Unix() + lifetimeSecs - pk.CreationTime.Unix()
+		if expiration <= 0 || expiration > math.MaxUint32 {
+			return nil, errors.New("gopenpgp: invalid key lifetime")
+		}
+		keyLifetimeSecs = uint32(expiration)
+	}
+	newSig.KeyLifetimeSecs = &keyLifetimeSecs
+
+	return &newSig, nil
+}
+
+// readFrom reads unarmored and armored keys from r and adds them to the keyring.
+func (key *Key) readFrom(r io.Reader, armored bool) error {
+	keys, err := readKeyRing(r, armored)
 	if err != nil {
 		return errors.Wrap(err, "gopenpgp: error in reading key ring")
 	}
 
-	if len(entities) > 1 {
+	if len(keys) > 1 {
 		return errors.New("gopenpgp: the key contains too many entities")
 	}
 
-	if len(entities) == 0 {
+	if len(keys) == 0 {
 		return errors.New("gopenpgp: the key does not contain any entity")
 	}
 
-	key.entity = entities[0]
+	*key = *keys[0]
 	return nil
 }
 
@@ -436,6 +904,8 @@ func generateKey(
 	name, email string,
 	keyType string,
 	bits int,
+	keyLifetimeSecs uint32,
+	random io.Reader,
 	prime1, prime2, prime3, prime4 []byte,
 ) (*Key, error) {
 	if len(email) == 0 && len(name) == 0 {
@@ -444,18 +914,8 @@ func generateKey(
 
 	comments := ""
 
-	cfg := &packet.Config{
-		Algorithm:              packet.PubKeyAlgoRSA,
-		RSABits:                bits,
-		Time:                   getKeyGenerationTimeGenerator(),
-		DefaultHash:            crypto.SHA256,
-		DefaultCipher:          packet.CipherAES256,
-		DefaultCompressionAlgo: packet.CompressionZLIB,
-	}
-
-	if keyType == "x25519" {
-		cfg.Algorithm = packet.PubKeyAlgoEdDSA
-	}
+	cfg := newGenerationConfig(keyType, bits, keyLifetimeSecs)
+	cfg.Rand = random
 
 	if prime1 != nil && prime2 != nil && prime3 != nil && prime4 != nil {
 		var bigPrimes [4]*big.Int
@@ -483,6 +943,25 @@ func generateKey(
 	return NewKeyFromEntity(newEntity)
 }
 
+// newGenerationConfig returns the configuration to generate keys of the given keyType.
+func newGenerationConfig(keyType string, bits int, keyLifetimeSecs uint32) *packet.Config {
+	cfg := &packet.Config{
+		Algorithm:              packet.PubKeyAlgoRSA,
+		RSABits:                bits,
+		Time:                   getKeyGenerationTimeGenerator(),
+		DefaultHash:            crypto.SHA256,
+		DefaultCipher:          packet.CipherAES256,
+		DefaultCompressionAlgo: packet.CompressionZLIB,
+		KeyLifetimeSecs:        keyLifetimeSecs,
+	}
+
+	if keyType == "x25519" {
+		cfg.Algorithm = packet.PubKeyAlgoEdDSA
+	}
+
+	return cfg
+}
+
 // keyIDToHex casts a keyID to hex with the correct padding.
 func keyIDToHex(keyID uint64) string {
 	return fmt.Sprintf("%016v", strconv.FormatUint(keyID, 16))
@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// PublicKeyCache keeps parsed public keys, by hash of their serialization and
+// by fingerprint, so that encrypting to or verifying with the same keys many
+// times, given as armored strings, parses them and verifies their
+// self-signatures only once.
+// The ECDH key agreements of the session keys encrypted to the cached keys
+// are computed in advance, in the background, once a key has been encrypted
+// to. RSA encryption depends on the session key, and is performed for every
+// message.
+// The cached keys are shared by the callers and must not be modified.
+// It is safe for concurrent use.
+type PublicKeyCache struct {
+	lock          sync.Mutex
+	cache         *lruCache
+	byFingerprint map[string]*Key
+}
+
+// NewPublicKeyCache creates a cache keeping up to maxEntries keys, evicting
+// the least recently used ones. If maxEntries is 0 or less, the number of
+// entries is not limited.
+func NewPublicKeyCache(maxEntries int) *PublicKeyCache {
+	cache := &PublicKeyCache{byFingerprint: make(map[string]*Key)}
+	cache.cache = newLRUCache(maxEntries, func(value interface{}) {
+		key := value.(*Key)
+		clearECDHPrecomputation(key.entity)
+		fingerprint := key.GetFingerprint()
+		if cache.byFingerprint[fingerprint] == key {
+			delete(cache.byFingerprint, fingerprint)
+		}
+	})
+	return cache
+}
+
+// NewKeyFromArmored returns the public key in an armored string from the
+// cache, or parses it like NewKeyFromArmored and caches it. Private keys are
+// cached and returned as public keys.
+func (cache *PublicKeyCache) NewKeyFromArmored(armored string) (*Key, error) {
+	return cache.getOrParse([]byte(armored), func() (*Key, error) {
+		return NewKeyFromArmored(armored)
+	})
+}
+
+// NewKey returns the public key in binary data from the cache, or parses it
+// like NewKey and caches it. Private keys are cached and returned as public
+// keys.
+func (cache *PublicKeyCache) NewKey(binKeys []byte) (*Key, error) {
+	return cache.getOrParse(binKeys, func() (*Key, error) {
+		return NewKey(binKeys)
+	})
+}
+
+// GetKey returns the cached key with the given hex fingerprint, if any.
+func (cache *PublicKeyCache) GetKey(fingerprint string) (*Key, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	key, ok := cache.byFingerprint[fingerprint]
+	return key, ok
+}
+
+// Len returns the number of cached keys.
+func (cache *PublicKeyCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.cache.len()
+}
+
+// Clear removes all the cached keys.
+func (cache *PublicKeyCache) Clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.cache.clear()
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// getOrParse returns the cached key serialized as data, or parses it with
+// parse and caches its public part.
+func (cache *PublicKeyCache) getOrParse(data []byte, parse func() (*Key, error)) (*Key, error) {
+	hash := sha256.Sum256(data)
+	cache.lock.Lock()
+	cached, ok := cache.cache.get(hash)
+	cache.lock.Unlock()
+	if ok {
+		return cached.(*Key), nil
+	}
+
+	key, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate() {
+		if key, err = key.ToPublic(); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to extract public key from private key")
+		}
+	}
+	precomputeECDH(key.entity)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.cache.put(hash, key)
+	cache.byFingerprint[key.GetFingerprint()] = key
+	return key, nil
+}
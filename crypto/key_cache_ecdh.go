@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
+)
+
+// ecdhPrecomputedAgreements is the number of key agreements computed in
+// advance for each ECDH encryption key of a PublicKeyCache.
+const ecdhPrecomputedAgreements = 4
+
+// ecdhCurve has the methods of the curves of go-crypto ECDH keys.
+type ecdhCurve interface {
+	GetCurveName() string
+	MarshalBytePoint([]byte) []byte
+	UnmarshalBytePoint([]byte) []byte
+	MarshalByteSecret(d []byte) []byte
+	UnmarshalByteSecret(d []byte) []byte
+	GenerateECDH(rand io.Reader) (point []byte, secret []byte, err error)
+	Encaps(rand io.Reader, point []byte) (ephemeral, sharedSecret []byte, err error)
+	Decaps(ephemeral, secret []byte) (sharedSecret []byte, err error)
+	ValidateECDH(public []byte, secret []byte) error
+}
+
+// ecdhAgreement is an ephemeral ECDH public key, with the secret it shares
+// with an encryption key.
+type ecdhAgreement struct {
+	ephemeral    []byte
+	sharedSecret []byte
+}
+
+// ecdhPrecomputation wraps the curve of an ECDH encryption key, so that
+// encrypting session keys to it uses key agreements computed in advance, in
+// the background, once the key has been encrypted to. Each agreement is used
+// only once.
+// The ECDH key agreement doesn't depend on the session key, unlike the RSA
+// encryption of the session key, which can't be computed in advance.
+type ecdhPrecomputation struct {
+	ecdhCurve
+	point []byte
+
+	lock       sync.Mutex
+	agreements []ecdhAgreement
+	refilling  bool
+	cleared    bool
+}
+
+// precomputeECDH makes the ECDH encryption subkeys of entity, which must not
+// be shared yet, use key agreements computed in advance.
+func precomputeECDH(entity *openpgp.Entity) {
+	for _, subkey := range entity.Subkeys {
+		pub, ok := subkey.PublicKey.PublicKey.(*ecdh.PublicKey)
+		if !ok {
+			continue
+		}
+		precomputation := &ecdhPrecomputation{ecdhCurve: pub.GetCurve(), point: pub.Point}
+		precomputed := ecdh.NewPublicKey(precomputation, pub.KDF.Hash, pub.KDF.Cipher)
+		precomputed.Point = pub.Point
+		subkey.PublicKey.PublicKey = precomputed
+	}
+}
+
+// clearECDHPrecomputation zeroes the key agreements computed in advance for
+// the subkeys of entity, and stops computing them.
+func clearECDHPrecomputation(entity *openpgp.Entity) {
+	for _, subkey := range entity.Subkeys {
+		pub, ok := subkey.PublicKey.PublicKey.(*ecdh.PublicKey)
+		if !ok {
+			continue
+		}
+		if precomputation, ok := pub.GetCurve().(*ecdhPrecomputation); ok {
+			precomputation.clear()
+		}
+	}
+}
+
+// Encaps returns a key agreement with point computed in advance, if any, or
+// else computes it, and computes the next ones in the background.
+func (c *ecdhPrecomputation) Encaps(random io.Reader, point []byte) (ephemeral, sharedSecret []byte, err error) {
+	if !bytes.Equal(point, c.point) {
+		return c.ecdhCurve.Encaps(random, point)
+	}
+
+	c.lock.Lock()
+	if !c.cleared && !c.refilling {
+		c.refilling = true
+		go c.refill()
+	}
+	if n := len(c.agreements); n > 0 {
+		agreement := c.agreements[n-1]
+		c.agreements[n-1] = ecdhAgreement{}
+		c.agreements = c.agreements[:n-1]
+		c.lock.Unlock()
+		return agreement.ephemeral, agreement.sharedSecret, nil
+	}
+	c.lock.Unlock()
+
+	return c.ecdhCurve.Encaps(random, point)
+}
+
+// refill computes key agreements until there are ecdhPrecomputedAgreements,
+// or the precomputation is cleared.
+func (c *ecdhPrecomputation) refill() {
+	for {
+		c.lock.Lock()
+		if c.cleared || len(c.agreements) >= ecdhPrecomputedAgreements {
+			c.refilling = false
+			c.lock.Unlock()
+			return
+		}
+		c.lock.Unlock()
+
+		ephemeral, sharedSecret, err := c.ecdhCurve.Encaps(rand.Reader, c.point)
+
+		c.lock.Lock()
+		if err != nil || c.cleared {
+			clearMem(sharedSecret)
+			c.refilling = false
+			c.lock.Unlock()
+			return
+		}
+		c.agreements = append(c.agreements, ecdhAgreement{ephemeral: ephemeral, sharedSecret: sharedSecret})
+		c.lock.Unlock()
+	}
+}
+
+// clear zeroes the key agreements computed in advance, and stops computing
+// them. The key can still be encrypted to afterwards.
+func (c *ecdhPrecomputation) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cleared = true
+	for _, agreement := range c.agreements {
+		clearMem(agreement.sharedSecret)
+	}
+	c.agreements = nil
+}
+
+// len returns the number of key agreements computed in advance.
+func (c *ecdhPrecomputation) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.agreements)
+}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicKeyCache(t *testing.T) {
+	armoredPublicKey := readTestFile("keyring_publicKey", false)
+	cache := NewPublicKeyCache(2)
+
+	key, err := cache.NewKeyFromArmored(armoredPublicKey)
+	if err != nil {
+		t.Fatal("Cannot parse key:", err)
+	}
+	cached, err := cache.NewKeyFromArmored(armoredPublicKey)
+	assert.Nil(t, err)
+	assert.True(t, key == cached)
+
+	byFingerprint, ok := cache.GetKey(key.GetFingerprint())
+	assert.True(t, ok)
+	assert.True(t, key == byFingerprint)
+
+	// Private keys are cached as public keys
+	privateKey, err := cache.NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot parse key:", err)
+	}
+	assert.False(t, privateKey.IsPrivate())
+	assert.Exactly(t, key.GetFingerprint(), privateKey.GetFingerprint())
+	assert.Exactly(t, 2, cache.Len())
+
+	binaryKey, err := keyTestEC.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	ecKey, err := cache.NewKey(binaryKey)
+	if err != nil {
+		t.Fatal("Cannot parse key:", err)
+	}
+	assert.Exactly(t, 2, cache.Len())
+	_, ok = cache.GetKey(ecKey.GetFingerprint())
+	assert.True(t, ok)
+
+	// The evicted armored public key is parsed again
+	parsed, err := cache.NewKeyFromArmored(armoredPublicKey)
+	assert.Nil(t, err)
+	assert.False(t, key == parsed)
+
+	keyRing, err := NewKeyRing(parsed)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	ciphertext, err := keyRing.Encrypt(NewPlainMessageFromString("cached"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	decrypted, err := keyRingTestPrivate.Decrypt(ciphertext, nil, 0)
+	assert.Nil(t, err)
+	assert.Exactly(t, "cached", decrypted.GetString())
+
+	_, err = cache.NewKeyFromArmored("invalid")
+	assert.NotNil(t, err)
+
+	cache.Clear()
+	assert.Exactly(t, 0, cache.Len())
+	_, ok = cache.GetKey(ecKey.GetFingerprint())
+	assert.False(t, ok)
+}
+
+func TestPublicKeyCacheECDHPrecomputation(t *testing.T) {
+	armoredPublicKey, err := keyTestEC.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	cache := NewPublicKeyCache(0)
+	key, err := cache.NewKeyFromArmored(armoredPublicKey)
+	if err != nil {
+		t.Fatal("Cannot parse key:", err)
+	}
+	precomputation, ok := key.entity.Subkeys[0].PublicKey.PublicKey.(*ecdh.PublicKey).GetCurve().(*ecdhPrecomputation)
+	if !ok {
+		t.Fatal("Expected the ECDH subkey of a cached key to be precomputed")
+	}
+	assert.Exactly(t, "curve25519", precomputation.GetCurveName())
+	assert.Exactly(t, 0, precomputation.len())
+	rearmored, err := key.GetArmoredPublicKey()
+	assert.Nil(t, err)
+	assert.Exactly(t, armoredPublicKey, rearmored)
+
+	keyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	privateKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	encryptAndDecrypt := func() {
+		ciphertext, err := keyRing.Encrypt(NewPlainMessageFromString("precomputed"), nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		decrypted, err := privateKeyRing.Decrypt(ciphertext, nil, 0)
+		if assert.Nil(t, err) {
+			assert.Exactly(t, "precomputed", decrypted.GetString())
+		}
+	}
+
+	// The first encryption computes the key agreements in the background
+	encryptAndDecrypt()
+	assert.Eventually(t, func() bool {
+		return precomputation.len() == ecdhPrecomputedAgreements
+	}, 10*time.Second, time.Millisecond)
+
+	// Each key agreement is used once
+	precomputation.lock.Lock()
+	ephemeral := precomputation.agreements[ecdhPrecomputedAgreements-1].ephemeral
+	precomputation.lock.Unlock()
+	encryptAndDecrypt()
+	precomputation.lock.Lock()
+	for _, agreement := range precomputation.agreements {
+		assert.NotEqual(t, ephemeral, agreement.ephemeral)
+	}
+	precomputation.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*ecdhPrecomputedAgreements; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			encryptAndDecrypt()
+		}()
+	}
+	wg.Wait()
+
+	// Evicted keys can still be encrypted to, without precomputation
+	cache.Clear()
+	assert.Exactly(t, 0, precomputation.len())
+	encryptAndDecrypt()
+	assert.Exactly(t, 0, precomputation.len())
+}
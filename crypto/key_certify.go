@@ -0,0 +1,159 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"hash"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// exportableCertificationSubpacket is the type of the subpacket marking
+// local certifications (RFC 4880, section 5.2.3.11).
+const exportableCertificationSubpacket = 4
+
+// CertifyUserID returns a copy of the key other, with a certification of its
+// user ID userID by key, with one of the constants.Certification* levels.
+// Local certifications, which are not exportable, are stripped by
+// GetPublicKey and GetCertifications.
+// The key must be unlocked.
+func (key *Key) CertifyUserID(other *Key, userID string, level int, exportable bool) (*Key, error) {
+	if level < constants.CertificationGeneric || level > constants.CertificationPositive {
+		return nil, errors.New("gopenpgp: invalid certification level")
+	}
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+
+	certifiedKey, err := other.Copy()
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := certifiedKey.entity.Identities[userID]
+	if !ok {
+		return nil, errors.New("gopenpgp: user ID not found in key")
+	}
+
+	signer := key.entity
+	certification := &packet.Signature{
+		Version:      signer.PrimaryKey.Version,
+		SigType:      packet.SignatureType(level),
+		PubKeyAlgo:   signer.PrimaryKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: getNow(),
+		IssuerKeyId:  &signer.PrimaryKey.KeyId,
+	}
+	config := &packet.Config{Time: getTimeGenerator()}
+
+	if exportable {
+		err = certification.SignUserId(userID, certifiedKey.entity.PrimaryKey, signer.PrivateKey, config)
+	} else {
+		h := certification.Hash.New()
+		writeUserIDForHash(h, certifiedKey.entity.PrimaryKey, userID)
+		localOnly := serializeSubpacket(exportableCertificationSubpacket, false, []byte{0})
+		err = signWithSubpackets(certification, h, signer.PrivateKey, config, localOnly)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in certifying user ID")
+	}
+
+	identity.Signatures = append(identity.Signatures, certification)
+	return certifiedKey, nil
+}
+
+// GetCertifications returns the unarmored certifications of the key made by
+// certifier, to be sent back to the owner of the key: the primary key, the
+// certified user IDs with their self-signature and the exportable
+// certifications of certifier are kept.
+func (key *Key) GetCertifications(certifier *Key) ([]byte, error) {
+	entity := key.entity
+	var outBuf bytes.Buffer
+	if err := entity.PrimaryKey.Serialize(&outBuf); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing primary key")
+	}
+
+	found := false
+	for name, identity := range entity.Identities {
+		var certifications []*packet.Signature
+		for _, sig := range identity.Signatures {
+			if sig.CheckKeyIdOrFingerprint(certifier.entity.PrimaryKey) && isExportable(sig) {
+				certifications = append(certifications, sig)
+			}
+		}
+		if len(certifications) == 0 || identity.SelfSignature == nil {
+			continue
+		}
+		found = true
+
+		if err := identity.UserId.Serialize(&outBuf); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in serializing user ID")
+		}
+		if err := identity.SelfSignature.Serialize(&outBuf); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in serializing self-signature")
+		}
+		for _, sig := range certifications {
+			if err := sig.Serialize(&outBuf); err != nil {
+				return nil, errors.Wrapf(err, "gopenpgp: error in serializing certification of %q", name)
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("gopenpgp: no exportable certification by the given key")
+	}
+
+	return outBuf.Bytes(), nil
+}
+
+// GetArmoredCertifications returns the armored certifications of the key made
+// by certifier, as returned by GetCertifications.
+func (key *Key) GetArmoredCertifications(certifier *Key) (string, error) {
+	serialized, err := key.GetCertifications(certifier)
+	if err != nil {
+		return "", err
+	}
+
+	return armor.ArmorWithType(serialized, constants.PublicKeyHeader)
+}
+
+// --- Internal functions
+
+// writeUserIDForHash writes the data signed by a certification of the user ID
+// of pk, as described in RFC 4880, section 5.2.4.
+func writeUserIDForHash(h hash.Hash, pk *packet.PublicKey, userID string) {
+	_ = pk.SerializeForHash(h)
+	prefix := make([]byte, 5)
+	prefix[0] = 0xb4
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(userID)))
+	_, _ = h.Write(prefix)
+	_, _ = h.Write([]byte(userID))
+}
+
+// isExportable returns false for local certifications.
+func isExportable(sig *packet.Signature) bool {
+	exportable := getHashedSubpacket(sig, exportableCertificationSubpacket)
+	return len(exportable) == 0 || exportable[0] != 0
+}
+
+// exportableEntity returns a shallow copy of entity without the local
+// certifications of its user IDs.
+func exportableEntity(entity *openpgp.Entity) *openpgp.Entity {
+	exported := *entity
+	exported.Identities = make(map[string]*openpgp.Identity, len(entity.Identities))
+	for name, identity := range entity.Identities {
+		exportedIdentity := *identity
+		exportedIdentity.Signatures = nil
+		for _, sig := range identity.Signatures {
+			if isExportable(sig) {
+				exportedIdentity.Signatures = append(exportedIdentity.Signatures, sig)
+			}
+		}
+		exported.Identities[name] = &exportedIdentity
+	}
+	return &exported
+}
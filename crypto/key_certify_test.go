@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestCertifyUserID(t *testing.T) {
+	userID := keyTestName + " <" + keyTestDomain + ">"
+	serialized, err := keyTestRSA.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	publicKey, err := NewKey(serialized)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	getCertifications := func(key *Key) (certifications []*packet.Signature) {
+		for _, sig := range key.entity.Identities[userID].Signatures {
+			if sig.CheckKeyIdOrFingerprint(keyTestEC.entity.PrimaryKey) {
+				certifications = append(certifications, sig)
+			}
+		}
+		return certifications
+	}
+	checkCertification := func(key *Key, level int) {
+		certifications := getCertifications(key)
+		if assert.Len(t, certifications, 1) {
+			assert.Exactly(t, packet.SignatureType(level), certifications[0].SigType)
+			assert.Nil(t, keyTestEC.entity.PrimaryKey.VerifyUserIdSignature(
+				userID, key.entity.PrimaryKey, certifications[0],
+			))
+		}
+	}
+
+	certified, err := keyTestEC.CertifyUserID(publicKey, userID, constants.CertificationCasual, true)
+	if err != nil {
+		t.Fatal("Cannot certify user ID:", err)
+	}
+	assert.Len(t, getCertifications(publicKey), 0)
+	checkCertification(certified, constants.CertificationCasual)
+
+	exported, err := certified.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	exportedKey, err := NewKey(exported)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	checkCertification(exportedKey, constants.CertificationCasual)
+
+	certifications, err := certified.GetArmoredCertifications(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot export certifications:", err)
+	}
+	certificationsKey, err := NewKeyFromArmored(certifications)
+	if err != nil {
+		t.Fatal("Cannot read certifications:", err)
+	}
+	assert.Len(t, certificationsKey.entity.Subkeys, 0)
+	merged, err := publicKey.Merge(certificationsKey)
+	if err != nil {
+		t.Fatal("Cannot merge certifications:", err)
+	}
+	checkCertification(merged, constants.CertificationCasual)
+
+	local, err := keyTestEC.CertifyUserID(publicKey, userID, constants.CertificationPositive, false)
+	if err != nil {
+		t.Fatal("Cannot certify user ID:", err)
+	}
+	localCopy, err := local.Copy()
+	if err != nil {
+		t.Fatal("Cannot copy key:", err)
+	}
+	checkCertification(localCopy, constants.CertificationPositive)
+
+	exported, err = local.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	exportedKey, err = NewKey(exported)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	assert.Len(t, getCertifications(exportedKey), 0)
+	_, err = local.GetCertifications(keyTestEC)
+	assert.NotNil(t, err)
+
+	_, err = keyTestEC.CertifyUserID(publicKey, userID, 0x14, true)
+	assert.NotNil(t, err)
+	_, err = keyTestEC.CertifyUserID(publicKey, "unknown", constants.CertificationGeneric, true)
+	assert.NotNil(t, err)
+}
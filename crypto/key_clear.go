@@ -6,6 +6,7 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
 	"github.com/ProtonMail/go-crypto/openpgp/ecdsa"
 	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
@@ -17,6 +18,24 @@ func (sk *SessionKey) Clear() (ok bool) {
 	return true
 }
 
+// Wipe zeroes the key of the session key, and removes it: the session key
+// can't be used afterwards.
+func (sk *SessionKey) Wipe() {
+	clearMem(sk.Key)
+	sk.Key = nil
+}
+
+// Wipe zeroes the private parameters of the keys of the keyring, and removes
+// the keys: the keyring can't be used afterwards.
+// Wiping a keyring also wipes the keys shared with it, e.g. returned by
+// GetKeys or added with AddKey.
+func (keyRing *KeyRing) Wipe() {
+	keyRing.ClearPrivateParams()
+	keyRing.updateEntities(func(openpgp.EntityList) openpgp.EntityList {
+		return nil
+	})
+}
+
 func (key *Key) ClearPrivateParams() (ok bool) {
 	num := key.clearPrivateWithSubkeys()
 	key.entity.PrivateKey = nil
@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// externalPrivateKey is a private key whose operations are delegated to a
+// signer and a decrypter outside of the process, e.g. in an HSM, a KMS, a TPM
+// or a smartcard. Either can be nil, failing the corresponding operations.
+type externalPrivateKey struct {
+	publicKey crypto.PublicKey
+	signer    crypto.Signer
+	decrypter crypto.Decrypter
+}
+
+// NewKeyFromSigner creates a private key from a public key, whose signatures
+// are delegated to signer and whose decryptions are delegated to decrypter,
+// so that the secret material never has to be in the process.
+// The signer and the decrypter are matched with the primary key and the
+// subkeys of publicKey by their public keys, and either can be nil.
+// Only RSA keys are supported. Certifying fails if the primary key is not
+// matched, e.g. when only the subkeys are held by a smartcard.
+func NewKeyFromSigner(publicKey *Key, signer crypto.Signer, decrypter crypto.Decrypter) (*Key, error) {
+	if signer == nil && decrypter == nil {
+		return nil, errors.New("gopenpgp: no signer or decrypter provided")
+	}
+
+	var err error
+	if publicKey.IsPrivate() {
+		publicKey, err = publicKey.ToPublic()
+	} else {
+		publicKey, err = publicKey.Copy()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entity := publicKey.GetEntity()
+	signerFound, decrypterFound := false, false
+	primaryKey, err := newExternalPrivateKey(entity.PrimaryKey, signer, decrypter, &signerFound, &decrypterFound)
+	if err != nil {
+		return nil, err
+	}
+	if primaryKey == nil {
+		primaryKey = &packet.PrivateKey{
+			PublicKey:  *entity.PrimaryKey,
+			PrivateKey: &externalPrivateKey{publicKey: entity.PrimaryKey.PublicKey},
+		}
+	}
+	entity.PrivateKey = primaryKey
+
+	for i := range entity.Subkeys {
+		entity.Subkeys[i].PrivateKey, err = newExternalPrivateKey(
+			entity.Subkeys[i].PublicKey, signer, decrypter, &signerFound, &decrypterFound,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (signer != nil && !signerFound) || (decrypter != nil && !decrypterFound) {
+		return nil, errors.New("gopenpgp: the signer or decrypter does not match the key")
+	}
+	return publicKey, nil
+}
+
+// NewKeyRingFromSigner creates a new KeyRing with a private key created by
+// NewKeyFromSigner.
+func NewKeyRingFromSigner(publicKey *Key, signer crypto.Signer, decrypter crypto.Decrypter) (*KeyRing, error) {
+	key, err := NewKeyFromSigner(publicKey, signer, decrypter)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(key)
+}
+
+// Public returns the public key of the private key.
+func (key *externalPrivateKey) Public() crypto.PublicKey {
+	return key.publicKey
+}
+
+// Sign delegates signing to the signer of the private key.
+func (key *externalPrivateKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if key.signer == nil {
+		return nil, errors.New("gopenpgp: the secret signing key is not available")
+	}
+	return key.signer.Sign(rand, digest, opts)
+}
+
+// Decrypt delegates decryption to the decrypter of the private key.
+func (key *externalPrivateKey) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if key.decrypter == nil {
+		return nil, errors.New("gopenpgp: the secret decryption key is not available")
+	}
+	return key.decrypter.Decrypt(rand, msg, opts)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// newExternalPrivateKey returns a private key delegated to signer and
+// decrypter for publicKey, if either matches it, or nil. The found flags are
+// set for the matching ones.
+func newExternalPrivateKey(
+	publicKey *packet.PublicKey,
+	signer crypto.Signer,
+	decrypter crypto.Decrypter,
+	signerFound, decrypterFound *bool,
+) (*packet.PrivateKey, error) {
+	external := &externalPrivateKey{publicKey: publicKey.PublicKey}
+	if signer != nil && isSamePublicKey(publicKey, signer.Public()) {
+		external.signer = signer
+		*signerFound = true
+	}
+	if decrypter != nil && isSamePublicKey(publicKey, decrypter.Public()) {
+		external.decrypter = decrypter
+		*decrypterFound = true
+	}
+	if external.signer == nil && external.decrypter == nil {
+		return nil, nil
+	}
+
+	// go-crypto only delegates the operations of RSA keys
+	if _, ok := publicKey.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("gopenpgp: only RSA keys can be backed by a signer or decrypter")
+	}
+	return &packet.PrivateKey{PublicKey: *publicKey, PrivateKey: external}, nil
+}
+
+// isSamePublicKey returns whether the public key of an OpenPGP key packet is
+// the given Go public key.
+func isSamePublicKey(publicKey *packet.PublicKey, goPublicKey crypto.PublicKey) bool {
+	equal, ok := goPublicKey.(interface {
+		Equal(crypto.PublicKey) bool
+	})
+	return ok && equal.Equal(publicKey.PublicKey)
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingKey is an RSA private key held "outside" of the OpenPGP key, which
+// counts its operations.
+type countingKey struct {
+	*rsa.PrivateKey
+	signatures, decryptions int
+}
+
+func (key *countingKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	key.signatures++
+	return key.PrivateKey.Sign(rand, digest, opts)
+}
+
+func (key *countingKey) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	key.decryptions++
+	return key.PrivateKey.Decrypt(rand, msg, opts)
+}
+
+func TestNewKeyFromSigner(t *testing.T) {
+	entity := keyTestRSA.GetEntity()
+	signer := &countingKey{PrivateKey: entity.PrivateKey.PrivateKey.(*rsa.PrivateKey)}
+	decrypter := &countingKey{PrivateKey: entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)}
+
+	publicKey, err := keyTestRSA.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	publicKeyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	keyRing, err := NewKeyRingFromSigner(publicKey, signer, decrypter)
+	if err != nil {
+		t.Fatal("Cannot create key ring from signer:", err)
+	}
+
+	message := NewPlainMessageFromString("signed outside")
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot sign with signer:", err)
+	}
+	assert.Nil(t, publicKeyRing.VerifyDetached(message, signature, GetUnixTime()))
+	assert.Exactly(t, 1, signer.signatures)
+
+	ciphertext, err := publicKeyRing.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	decrypted, err := keyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot decrypt with decrypter:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	assert.Exactly(t, 1, decrypter.decryptions)
+	assert.Exactly(t, 0, decrypter.signatures)
+
+	// Decrypting only, with the subkey
+	keyRing, err = NewKeyRingFromSigner(publicKey, nil, decrypter)
+	if err != nil {
+		t.Fatal("Cannot create key ring from decrypter:", err)
+	}
+	decrypted, err = keyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot decrypt with decrypter:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	_, err = keyRing.SignDetached(message)
+	assert.NotNil(t, err)
+}
+
+func TestNewKeyFromSignerErrors(t *testing.T) {
+	signer := keyTestRSA.GetEntity().PrivateKey.PrivateKey.(*rsa.PrivateKey)
+
+	_, err := NewKeyFromSigner(keyTestRSA, nil, nil)
+	assert.NotNil(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal("Cannot generate RSA key:", err)
+	}
+	_, err = NewKeyFromSigner(keyTestRSA, otherKey, nil)
+	assert.NotNil(t, err)
+	_, err = NewKeyFromSigner(keyTestRSA, signer, otherKey)
+	assert.NotNil(t, err)
+
+	// Private keys are replaced by their public part
+	key, err := NewKeyFromSigner(keyTestRSA, signer, nil)
+	if err != nil {
+		t.Fatal("Cannot create key from signer:", err)
+	}
+	assert.True(t, key.IsPrivate())
+	_, err = key.Armor()
+	assert.NotNil(t, err)
+}
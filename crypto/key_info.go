@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
+	"github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// KeyPacketInfo describes a primary key or a subkey.
+type KeyPacketInfo struct {
+	KeyID       string
+	Fingerprint string
+	// Public key algorithm: rsa, dsa, elgamal, ecdh, ecdsa or eddsa
+	Algorithm string
+	// Size of the key in bits, for rsa, dsa and elgamal keys
+	BitLength int
+	// Name of the curve, for elliptic curve keys
+	Curve string
+	// Unix time of creation
+	CreationTime int64
+	// Unix time of expiration, or 0 if the key doesn't expire
+	ExpirationTime int64
+	// Key flags
+	CanCertify      bool
+	CanSign         bool
+	CanEncrypt      bool
+	CanAuthenticate bool
+	IsRevoked       bool
+}
+
+// KeyInfo describes the properties of a key, its subkeys and its user IDs.
+type KeyInfo struct {
+	// Properties of the primary key
+	KeyPacketInfo
+	IsExpired bool
+	// User IDs, with the primary one first
+	UserIDs []string
+	Subkeys []*KeyPacketInfo
+}
+
+// GetKeyInfo returns the properties of the key.
+func (key *Key) GetKeyInfo() *KeyInfo {
+	now := getNow()
+	entity := key.entity
+	primaryIdentity := entity.PrimaryIdentity()
+
+	info := &KeyInfo{IsExpired: key.IsExpired()}
+	var selfSignature *packet.Signature
+	if primaryIdentity != nil {
+		selfSignature = primaryIdentity.SelfSignature
+	}
+	info.KeyPacketInfo = *newKeyPacketInfo(entity.PrimaryKey, selfSignature)
+	info.IsRevoked = key.IsRevoked()
+	if selfSignature == nil || !selfSignature.FlagsValid {
+		// Without key flags, a primary key can certify and sign
+		info.CanCertify = true
+		info.CanSign = true
+	}
+
+	var otherUserIDs []string
+	for name, identity := range entity.Identities {
+		if identity == primaryIdentity {
+			info.UserIDs = append(info.UserIDs, name)
+		} else {
+			otherUserIDs = append(otherUserIDs, name)
+		}
+	}
+	sort.Strings(otherUserIDs)
+	info.UserIDs = append(info.UserIDs, otherUserIDs...)
+
+	for _, subkey := range entity.Subkeys {
+		subkeyInfo := newKeyPacketInfo(subkey.PublicKey, subkey.Sig)
+		subkeyInfo.IsRevoked = subkey.Revoked(now)
+		info.Subkeys = append(info.Subkeys, subkeyInfo)
+	}
+
+	return info
+}
+
+// newKeyPacketInfo describes a key packet, with the flags and expiration set
+// by its self-signature or binding signature sig.
+func newKeyPacketInfo(pk *packet.PublicKey, sig *packet.Signature) *KeyPacketInfo {
+	info := &KeyPacketInfo{
+		KeyID:        keyIDToHex(pk.KeyId),
+		Fingerprint:  hex.EncodeToString(pk.Fingerprint),
+		Algorithm:    pubKeyAlgoNames[pk.PubKeyAlgo],
+		CreationTime: pk.CreationTime.Unix(),
+	}
+
+	switch pub := pk.PublicKey.(type) {
+	case *ecdh.PublicKey:
+		info.Curve = pub.GetCurve().GetCurveName()
+	case *ecdsa.PublicKey:
+		info.Curve = pub.GetCurve().GetCurveName()
+	case *eddsa.PublicKey:
+		info.Curve = pub.GetCurve().GetCurveName()
+	default:
+		if bitLength, err := pk.BitLength(); err == nil {
+			info.BitLength = int(bitLength)
+		}
+	}
+
+	if sig == nil {
+		return info
+	}
+	if sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs != 0 {
+		lifetime := time.Duration(*sig.KeyLifetimeSecs) * time.Second
+		info.ExpirationTime = pk.CreationTime.Add(lifetime).Unix()
+	}
+	if sig.FlagsValid {
+		info.CanCertify = sig.FlagCertify
+		info.CanSign = sig.FlagSign
+		info.CanEncrypt = sig.FlagEncryptCommunications || sig.FlagEncryptStorage
+		info.CanAuthenticate = sig.FlagAuthenticate
+	}
+	return info
+}
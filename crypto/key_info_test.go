@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetKeyInfo(t *testing.T) {
+	rsaInfo := keyTestRSA.GetKeyInfo()
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), rsaInfo.Fingerprint)
+	assert.Exactly(t, keyTestRSA.GetHexKeyID(), rsaInfo.KeyID)
+	assert.Exactly(t, "rsa", rsaInfo.Algorithm)
+	assert.Exactly(t, 1024, rsaInfo.BitLength)
+	assert.Exactly(t, "", rsaInfo.Curve)
+	assert.Exactly(t, keyTestRSA.entity.PrimaryKey.CreationTime.Unix(), rsaInfo.CreationTime)
+	assert.True(t, rsaInfo.CanSign)
+	assert.True(t, rsaInfo.CanCertify)
+	assert.False(t, rsaInfo.IsRevoked)
+	assert.False(t, rsaInfo.IsExpired)
+	assert.Exactly(t, []string{keyTestName + " <" + keyTestDomain + ">"}, rsaInfo.UserIDs)
+	assert.Len(t, rsaInfo.Subkeys, 1)
+	assert.True(t, rsaInfo.Subkeys[0].CanEncrypt)
+	assert.False(t, rsaInfo.Subkeys[0].CanSign)
+
+	expiringKey, err := keyTestEC.AddUserID("Secondary", "", "secondary@example.com")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	expiringKey, err = expiringKey.UpdateExpiration(3600)
+	if err != nil {
+		t.Fatal("Cannot update expiration:", err)
+	}
+
+	ecInfo := expiringKey.GetKeyInfo()
+	assert.Exactly(t, "eddsa", ecInfo.Algorithm)
+	assert.Exactly(t, "ed25519", ecInfo.Curve)
+	assert.Exactly(t, 0, ecInfo.BitLength)
+	assert.Exactly(t, ecInfo.CreationTime+3600, ecInfo.ExpirationTime)
+	assert.Len(t, ecInfo.UserIDs, 2)
+	assert.Exactly(t, "Secondary <secondary@example.com>", ecInfo.UserIDs[1])
+	assert.Exactly(t, "ecdh", ecInfo.Subkeys[0].Algorithm)
+	assert.Exactly(t, "curve25519", ecInfo.Subkeys[0].Curve)
+	assert.Exactly(t, ecInfo.Subkeys[0].CreationTime+3600, ecInfo.Subkeys[0].ExpirationTime)
+}
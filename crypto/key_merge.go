@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// Merge returns a copy of the key updated with the user IDs, user attributes,
+// subkeys, self-signatures and revocations of other, which must have the same
+// fingerprint. Signatures present in both keys are only kept once.
+func (key *Key) Merge(other *Key) (*Key, error) {
+	if !bytes.Equal(key.entity.PrimaryKey.Fingerprint, other.entity.PrimaryKey.Fingerprint) {
+		return nil, errors.New("gopenpgp: cannot merge keys with different fingerprints")
+	}
+
+	merged, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+	otherCopy, err := other.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	mergeEntity(merged, otherCopy)
+	return merged, nil
+}
+
+// Merge adds the keys of other to the keyring. Keys whose fingerprint is
+// already in the keyring are merged with the existing key instead of being
+// added as duplicates.
+func (keyRing *KeyRing) Merge(other *KeyRing) error {
+	otherCopy, err := other.Copy()
+	if err != nil {
+		return err
+	}
+
+	// The keys of the keyring may be in use: they are replaced by merged
+	// copies instead of being updated in place
+	keys := keyRing.GetKeys()
+	merged := make(map[*openpgp.Entity]*Key)
+	var added []*Key
+	for _, key := range otherCopy.GetKeys() {
+		fingerprint := key.entity.PrimaryKey.Fingerprint
+		if existing := findKeyByFingerprint(added, fingerprint); existing != nil {
+			mergeEntity(existing, key)
+			continue
+		}
+		existing := findKeyByFingerprint(keys, fingerprint)
+		if existing == nil {
+			added = append(added, key)
+			continue
+		}
+		if mergedKey, ok := merged[existing.entity]; ok {
+			mergeEntity(mergedKey, key)
+			continue
+		}
+		mergedKey, err := existing.Merge(key)
+		if err != nil {
+			return err
+		}
+		merged[existing.entity] = mergedKey
+	}
+
+	updated := append([]*Key{}, added...)
+	for _, mergedKey := range merged {
+		updated = append(updated, mergedKey)
+	}
+	keyRing.updateEntities(func(entities openpgp.EntityList) openpgp.EntityList {
+		for i, entity := range entities {
+			if mergedKey, ok := merged[entity]; ok {
+				entities[i] = mergedKey.entity
+			}
+		}
+		for _, key := range added {
+			entities = append(entities, key.entity)
+		}
+		return entities
+	}, updated...)
+	return nil
+}
+
+// --- Internal functions
+
+// findKeyByFingerprint returns the key with the given primary key
+// fingerprint, or nil.
+func findKeyByFingerprint(keys []*Key, fingerprint []byte) *Key {
+	for _, key := range keys {
+		if bytes.Equal(key.entity.PrimaryKey.Fingerprint, fingerprint) {
+			return key
+		}
+	}
+	return nil
+}
+
+// mergeEntity adds the packets and user attributes of src missing from dst to
+// dst. Both keys must have the same primary key.
+func mergeEntity(dstKey, srcKey *Key) {
+	dstKey.userAttributes = mergeUserAttributes(dstKey.entity.PrimaryKey, dstKey.userAttributes, srcKey.userAttributes)
+
+	dst, src := dstKey.entity, srcKey.entity
+	if dst.PrivateKey == nil && src.PrivateKey != nil {
+		dst.PrivateKey = src.PrivateKey
+	}
+
+	dst.Revocations = mergeSignatures(dst.Revocations, src.Revocations)
+
+	for name, srcIdentity := range src.Identities {
+		dstIdentity, ok := dst.Identities[name]
+		if !ok {
+			dst.Identities[name] = srcIdentity
+			continue
+		}
+		dstIdentity.Signatures = mergeSignatures(dstIdentity.Signatures, srcIdentity.Signatures)
+		dstIdentity.Revocations = mergeSignatures(dstIdentity.Revocations, srcIdentity.Revocations)
+		dstIdentity.SelfSignature = newestSignature(dstIdentity.SelfSignature, srcIdentity.SelfSignature)
+	}
+
+	for _, srcSubkey := range src.Subkeys {
+		merged := false
+		for i := range dst.Subkeys {
+			dstSubkey := &dst.Subkeys[i]
+			if !bytes.Equal(dstSubkey.PublicKey.Fingerprint, srcSubkey.PublicKey.Fingerprint) {
+				continue
+			}
+			if dstSubkey.PrivateKey == nil && srcSubkey.PrivateKey != nil {
+				dstSubkey.PrivateKey = srcSubkey.PrivateKey
+			}
+			dstSubkey.Revocations = mergeSignatures(dstSubkey.Revocations, srcSubkey.Revocations)
+			dstSubkey.Sig = newestSignature(dstSubkey.Sig, srcSubkey.Sig)
+			merged = true
+			break
+		}
+		if !merged {
+			dst.Subkeys = append(dst.Subkeys, srcSubkey)
+		}
+	}
+}
+
+// mergeSignatures appends the signatures of src that are not in dst to dst.
+func mergeSignatures(dst, src []*packet.Signature) []*packet.Signature {
+	for _, srcSig := range src {
+		duplicate := false
+		for _, dstSig := range dst {
+			if sameSignature(dstSig, srcSig) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			dst = append(dst, srcSig)
+		}
+	}
+	return dst
+}
+
+// sameSignature returns true if both signatures serialize to the same packet.
+func sameSignature(sig1, sig2 *packet.Signature) bool {
+	var buf1, buf2 bytes.Buffer
+	if err := sig1.Serialize(&buf1); err != nil {
+		return false
+	}
+	if err := sig2.Serialize(&buf2); err != nil {
+		return false
+	}
+	return bytes.Equal(buf1.Bytes(), buf2.Bytes())
+}
+
+// newestSignature returns the most recent of the two signatures.
+func newestSignature(sig1, sig2 *packet.Signature) *packet.Signature {
+	if sig1 == nil || (sig2 != nil && sig2.CreationTime.After(sig1.CreationTime)) {
+		return sig2
+	}
+	return sig1
+}
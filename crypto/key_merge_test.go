@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyMerge(t *testing.T) {
+	baseKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+
+	updatedKey, err := keyTestEC.AddUserID("Secondary", "", "secondary@example.com")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	updatedKey, err = updatedKey.AddEncryptionSubkey("x25519", 256, 0)
+	if err != nil {
+		t.Fatal("Cannot add encryption subkey:", err)
+	}
+	updatedKey, err = updatedKey.RevokeSubkey(
+		keyIDToHex(updatedKey.entity.Subkeys[1].PublicKey.KeyId),
+		constants.RevocationKeyRetired,
+		"",
+	)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+	updatedKey, err = updatedKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+
+	mergedKey, err := baseKey.Merge(updatedKey)
+	if err != nil {
+		t.Fatal("Cannot merge keys:", err)
+	}
+	assert.Len(t, mergedKey.entity.Identities, 2)
+	assert.Len(t, mergedKey.entity.Subkeys, 2)
+	assert.True(t, mergedKey.entity.Subkeys[1].Revoked(getNow()))
+	assert.Len(t, baseKey.entity.Identities, 1)
+
+	// Merging is idempotent
+	remergedKey, err := mergedKey.Merge(updatedKey)
+	if err != nil {
+		t.Fatal("Cannot merge keys:", err)
+	}
+	assert.Len(t, remergedKey.entity.Identities, 2)
+	for name, identity := range remergedKey.entity.Identities {
+		assert.Len(t, identity.Signatures, len(mergedKey.entity.Identities[name].Signatures))
+	}
+	assert.Len(t, remergedKey.entity.Subkeys, 2)
+	assert.Len(t, remergedKey.entity.Subkeys[1].Revocations, 1)
+
+	_, err = baseKey.Merge(keyTestRSA)
+	assert.NotNil(t, err)
+}
+
+func TestKeyRingMerge(t *testing.T) {
+	baseKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	keyRing, err := NewKeyRing(baseKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+
+	updatedKey, err := keyTestEC.AddUserID("Secondary", "", "secondary@example.com")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	otherKeyRing, err := NewKeyRing(updatedKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	if err = otherKeyRing.AddKey(keyTestRSA); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+
+	if err = keyRing.Merge(otherKeyRing); err != nil {
+		t.Fatal("Cannot merge keyrings:", err)
+	}
+	assert.Exactly(t, 2, keyRing.CountEntities())
+	assert.Len(t, keyRing.entities[0].Identities, 2)
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), keyRing.GetKeys()[1].GetFingerprint())
+	assert.True(t, keyRing.GetKeys()[0].IsPrivate())
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"bytes"
+	goerrors "errors"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// EncryptedKeyInfo describes a session key packet of the key packet of a
+// message.
+type EncryptedKeyInfo struct {
+	// Hex key ID of the recipient key, or 0000000000000000 for a hidden
+	// recipient. Empty for a password encrypted session key
+	KeyID string
+	// Public key algorithm of the recipient key: rsa, elgamal or ecdh.
+	// Empty for a password encrypted session key
+	Algorithm string
+	// Whether the session key is encrypted with a password instead of a
+	// public key
+	IsPassword bool
+}
+
+// IsHiddenRecipient returns whether the recipient key ID is the wildcard key
+// ID, as written by EncryptWithHiddenRecipients.
+func (info *EncryptedKeyInfo) IsHiddenRecipient() bool {
+	return !info.IsPassword && info.KeyID == keyIDToHex(0)
+}
+
+// GetEncryptedKeyInfos describes the session key packets of the binary key
+// packet, without decrypting them. The packets following the session key
+// packets, if any, are ignored.
+func GetEncryptedKeyInfos(keyPacket []byte) ([]*EncryptedKeyInfo, error) {
+	var infos []*EncryptedKeyInfo
+	packets := packet.NewReader(bytes.NewReader(keyPacket))
+Loop:
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in reading session key packets")
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			infos = append(infos, &EncryptedKeyInfo{
+				KeyID:     keyIDToHex(p.KeyId),
+				Algorithm: pubKeyAlgoNames[p.Algo],
+			})
+		case *packet.SymmetricKeyEncrypted:
+			infos = append(infos, &EncryptedKeyInfo{IsPassword: true})
+		default:
+			break Loop
+		}
+	}
+	if len(infos) == 0 {
+		return nil, errors.New("gopenpgp: no session key packet found")
+	}
+	return infos, nil
+}
+
+// GetSessionKeyCipher decrypts the session key of the binary key packet, and
+// returns its symmetric cipher, e.g. constants.AES256.
+func (keyRing *KeyRing) GetSessionKeyCipher(keyPacket []byte) (string, error) {
+	sessionKey, err := keyRing.DecryptSessionKey(keyPacket)
+	if err != nil {
+		return "", err
+	}
+	return sessionKey.Algo, nil
+}
@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestGetEncryptedKeyInfos(t *testing.T) {
+	sk, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error when generating session key, got:", err)
+	}
+	keyPacket, err := keyRingTestPublic.EncryptSessionKey(sk)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	hiddenKeyPacket, err := keyRingTestPublic.EncryptSessionKeyWithHiddenRecipients(sk)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	passwordKeyPacket, err := EncryptSessionKeyWithPassword(sk, []byte("password"))
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key with password, got:", err)
+	}
+
+	infos, err := GetEncryptedKeyInfos(joinPackets(keyPacket, joinPackets(hiddenKeyPacket, passwordKeyPacket)))
+	if err != nil {
+		t.Fatal("Expected no error when inspecting key packet, got:", err)
+	}
+	assert.Len(t, infos, 3)
+
+	keyIDs, _ := NewPGPMessage(keyPacket).GetHexEncryptionKeyIDs()
+	assert.Exactly(t, keyIDs[0], infos[0].KeyID)
+	assert.Exactly(t, "rsa", infos[0].Algorithm)
+	assert.False(t, infos[0].IsHiddenRecipient())
+	assert.False(t, infos[0].IsPassword)
+
+	assert.Exactly(t, "0000000000000000", infos[1].KeyID)
+	assert.Exactly(t, "rsa", infos[1].Algorithm)
+	assert.True(t, infos[1].IsHiddenRecipient())
+
+	assert.True(t, infos[2].IsPassword)
+	assert.False(t, infos[2].IsHiddenRecipient())
+
+	cipher, err := keyRingTestPrivate.GetSessionKeyCipher(keyPacket)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting session key, got:", err)
+	}
+	assert.Exactly(t, constants.AES256, cipher)
+
+	_, err = GetEncryptedKeyInfos(nil)
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -221,6 +222,69 @@ func TestIsExpired(t *testing.T) {
 	assert.Exactly(t, true, futureKey.IsExpired())
 }
 
+func TestGenerateKeyWithExpiration(t *testing.T) {
+	expiringKey, err := GenerateKeyWithExpiration(keyTestName, keyTestDomain, "x25519", 256, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate expiring key:", err)
+	}
+	assert.False(t, expiringKey.IsExpired())
+
+	pgp.latestServerTime = testTime + 7200
+	defer func() { pgp.latestServerTime = testTime }()
+
+	assert.True(t, expiringKey.IsExpired())
+	assert.False(t, expiringKey.CanEncrypt())
+
+	_, err = GenerateKeyWithExpiration(keyTestName, keyTestDomain, "x25519", 256, -1)
+	assert.NotNil(t, err)
+}
+
+func TestUpdateExpiration(t *testing.T) {
+	expiringKey, err := GenerateKeyWithExpiration(keyTestName, keyTestDomain, "rsa", 1024, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate expiring key:", err)
+	}
+
+	pgp.latestServerTime = testTime + 1800
+	defer func() { pgp.latestServerTime = testTime }()
+
+	renewedKey, err := expiringKey.UpdateExpiration(7200)
+	if err != nil {
+		t.Fatal("Cannot update key expiration:", err)
+	}
+
+	armored, err := renewedKey.Armor()
+	if err != nil {
+		t.Fatal("Cannot armor renewed key:", err)
+	}
+	renewedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor renewed key:", err)
+	}
+
+	pgp.latestServerTime = testTime + 7200
+	assert.True(t, expiringKey.IsExpired())
+	assert.False(t, renewedKey.IsExpired())
+	assert.True(t, renewedKey.CanEncrypt())
+
+	pgp.latestServerTime = testTime + 9200
+	assert.True(t, renewedKey.IsExpired())
+
+	neverExpiringKey, err := renewedKey.UpdateExpiration(0)
+	if err != nil {
+		t.Fatal("Cannot remove key expiration:", err)
+	}
+	pgp.latestServerTime = testTime + 100000
+	assert.False(t, neverExpiringKey.IsExpired())
+
+	publicKey, err := renewedKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	_, err = publicKey.UpdateExpiration(3600)
+	assert.NotNil(t, err)
+}
+
 func TestGenerateKeyWithPrimes(t *testing.T) {
 	prime1, _ := base64.StdEncoding.DecodeString(
 		"/thF8zjjk6fFx/y9NId35NFx8JTA7jvHEl+gI0dp9dIl9trmeZb+ESZ8f7bNXUmTI8j271kyenlrVJiqwqk80Q==")
@@ -417,6 +481,44 @@ func TestKeyCapabilities(t *testing.T) {
 	assert.True(t, publicKey.CanEncrypt())
 }
 
+func TestKeyCapabilitiesAt(t *testing.T) {
+	expiringKey, err := GenerateKeyWithExpiration(keyTestName, keyTestDomain, "x25519", 256, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	creationTime := expiringKey.entity.PrimaryKey.CreationTime.Unix()
+
+	assert.True(t, expiringKey.CanVerifyAt(creationTime+60))
+	assert.True(t, expiringKey.CanEncryptAt(creationTime+60))
+	assert.True(t, expiringKey.CanSignAt(creationTime+60))
+	assert.False(t, expiringKey.CanVerifyAt(creationTime+7200))
+	assert.False(t, expiringKey.CanEncryptAt(creationTime+7200))
+	assert.False(t, expiringKey.CanSignAt(creationTime+7200))
+	assert.False(t, expiringKey.CanEncryptAt(creationTime-7200))
+
+	assert.True(t, keyTestEC.CanSign())
+	assert.True(t, keyTestRSA.CanSign())
+
+	publicKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot make key public:", err)
+	}
+	assert.False(t, publicKey.CanSign())
+	assert.True(t, publicKey.CanVerifyAt(GetUnixTime()))
+
+	lockedKey, err := keyTestEC.Lock(keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	assert.False(t, lockedKey.CanSign())
+	assert.True(t, lockedKey.CanVerify())
+
+	assert.True(t, keyRingTestPrivate.CanSign())
+	assert.False(t, keyRingTestPublic.CanSign())
+	assert.True(t, keyRingTestPublic.CanEncryptAt(GetUnixTime()))
+	assert.True(t, keyRingTestPublic.CanVerifyAt(GetUnixTime()))
+}
+
 func TestRevokedKeyCapabilities(t *testing.T) {
 	pgp.latestServerTime = 1632219895
 	defer func() {
@@ -430,6 +532,7 @@ func TestRevokedKeyCapabilities(t *testing.T) {
 
 	assert.False(t, revokedKey.CanVerify())
 	assert.False(t, revokedKey.CanEncrypt())
+	assert.True(t, revokedKey.CanEncryptAt(1622219900))
 	assert.False(t, revokedKey.IsExpired())
 	assert.True(t, revokedKey.IsRevoked())
 }
@@ -452,3 +555,181 @@ func TestKeyCompression(t *testing.T) {
 		keyTestEC.entity.PrimaryIdentity().SelfSignature.PreferredCompression,
 	)
 }
+
+func TestAddAndRevokeUserID(t *testing.T) {
+	updatedKey, err := keyTestEC.AddUserID("Erika Mustermann", "work", "erika.mustermann@proton.me")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+
+	armored, err := updatedKey.Armor()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+	updatedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+
+	newUserID := "Erika Mustermann (work) <erika.mustermann@proton.me>"
+	assert.Len(t, updatedKey.entity.Identities, 2)
+	assert.Contains(t, updatedKey.entity.Identities, newUserID)
+	assert.Exactly(t, keyTestEC.entity.PrimaryIdentity().Name, updatedKey.entity.PrimaryIdentity().Name)
+
+	_, err = updatedKey.AddUserID("Erika Mustermann", "work", "erika.mustermann@proton.me")
+	assert.NotNil(t, err)
+
+	unlockedKey, err := updatedKey.Unlock(nil)
+	if err != nil {
+		t.Fatal("Cannot unlock key:", err)
+	}
+	revokedKey, err := unlockedKey.RevokeUserID(newUserID, "Left the company")
+	if err != nil {
+		t.Fatal("Cannot revoke user ID:", err)
+	}
+
+	armored, err = revokedKey.Armor()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+	revokedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+
+	assert.True(t, revokedKey.entity.Identities[newUserID].Revoked(getNow()))
+	assert.False(t, revokedKey.IsRevoked())
+	assert.True(t, revokedKey.CanEncrypt())
+
+	_, err = revokedKey.RevokeUserID(newUserID, "")
+	assert.NotNil(t, err)
+	_, err = revokedKey.RevokeUserID(keyTestEC.entity.PrimaryIdentity().Name, "")
+	assert.NotNil(t, err)
+
+	publicKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	_, err = publicKey.AddUserID("Erika Mustermann", "", "erika.mustermann@proton.me")
+	assert.NotNil(t, err)
+}
+
+func TestAddAndRevokeSubkeys(t *testing.T) {
+	updatedKey, err := keyTestEC.AddEncryptionSubkey("rsa", 1024, 0)
+	if err != nil {
+		t.Fatal("Cannot add encryption subkey:", err)
+	}
+	updatedKey, err = updatedKey.AddSigningSubkey("x25519", 256, 3600)
+	if err != nil {
+		t.Fatal("Cannot add signing subkey:", err)
+	}
+
+	armored, err := updatedKey.Armor()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+	updatedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	assert.Len(t, updatedKey.entity.Subkeys, 3)
+
+	encryptionSubkey := updatedKey.entity.Subkeys[1]
+	signingSubkey := updatedKey.entity.Subkeys[2]
+	assert.Exactly(t, packet.PubKeyAlgoRSA, encryptionSubkey.PublicKey.PubKeyAlgo)
+	assert.True(t, signingSubkey.Sig.FlagSign)
+	assert.Exactly(t, uint32(3600), *signingSubkey.Sig.KeyLifetimeSecs)
+
+	keyRing, err := NewKeyRing(updatedKey)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	message := NewPlainMessageFromString("plain text")
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot sign with new subkey:", err)
+	}
+	signerIDs, _ := signature.GetSignatureKeyIDs()
+	assert.Exactly(t, []uint64{signingSubkey.PublicKey.KeyId}, signerIDs)
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+
+	revokedKey, err := updatedKey.RevokeSubkey(
+		keyIDToHex(encryptionSubkey.PublicKey.KeyId),
+		constants.RevocationKeyCompromised,
+		"Lost laptop",
+	)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+	armored, err = revokedKey.Armor()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+	revokedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	assert.True(t, revokedKey.entity.Subkeys[1].Revoked(getNow()))
+	assert.False(t, revokedKey.IsRevoked())
+	assert.True(t, revokedKey.CanEncrypt())
+
+	_, err = updatedKey.RevokeSubkey("0123456789abcdef", constants.RevocationNoReason, "")
+	assert.NotNil(t, err)
+
+	publicKey, err := updatedKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	_, err = publicKey.AddEncryptionSubkey("x25519", 256, 0)
+	assert.NotNil(t, err)
+}
+
+func TestGetMinimalPublicKey(t *testing.T) {
+	fullKey, err := keyTestEC.AddUserID("Secondary", "", "secondary@example.com")
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	fullKey, err = fullKey.AddEncryptionSubkey("x25519", 256, 0)
+	if err != nil {
+		t.Fatal("Cannot add encryption subkey:", err)
+	}
+	fullKey, err = fullKey.RevokeSubkey(
+		keyIDToHex(fullKey.entity.Subkeys[1].PublicKey.KeyId),
+		constants.RevocationKeyRetired,
+		"",
+	)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+	primaryName := fullKey.entity.PrimaryIdentity().Name
+	if err = fullKey.entity.SignIdentity(primaryName, keyTestRSA.entity, nil); err != nil {
+		t.Fatal("Cannot certify user ID:", err)
+	}
+
+	armored, err := fullKey.GetArmoredMinimalPublicKey()
+	if err != nil {
+		t.Fatal("Cannot export minimal key:", err)
+	}
+	minimalKey, err := NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot parse minimal key:", err)
+	}
+
+	assert.False(t, minimalKey.IsPrivate())
+	assert.Exactly(t, fullKey.GetFingerprint(), minimalKey.GetFingerprint())
+	assert.Len(t, minimalKey.entity.Identities, 1)
+	assert.Len(t, minimalKey.entity.Identities[primaryName].Signatures, 1)
+	assert.Len(t, minimalKey.entity.Subkeys, 1)
+	assert.Exactly(t, keyTestEC.entity.Subkeys[0].PublicKey.KeyId, minimalKey.entity.Subkeys[0].PublicKey.KeyId)
+	assert.True(t, minimalKey.CanEncrypt())
+
+	fullPublicKey, err := fullKey.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot export public key:", err)
+	}
+	minimalPublicKey, err := minimalKey.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot export public key:", err)
+	}
+	assert.Less(t, len(minimalPublicKey), len(fullPublicKey))
+}
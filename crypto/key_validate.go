@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"crypto"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Codes of the problems found by (*Key).Validate.
+const (
+	KeyProblemInvalidSelfSignature      = "invalid-self-signature"
+	KeyProblemInvalidBindingSignature   = "invalid-binding-signature"
+	KeyProblemMissingCrossCertification = "missing-cross-certification"
+	KeyProblemWeakHash                  = "weak-hash"
+	KeyProblemWeakAlgorithm             = "weak-algorithm"
+	KeyProblemExpired                   = "expired"
+	KeyProblemRevoked                   = "revoked"
+	KeyProblemSubkeyExpired             = "subkey-expired"
+	KeyProblemSubkeyOutlivesPrimary     = "subkey-outlives-primary"
+)
+
+// minimumBitLength is the size under which rsa, dsa and elgamal keys are
+// reported as weak.
+const minimumBitLength = 2048
+
+// KeyProblem is a problem found by (*Key).Validate.
+type KeyProblem struct {
+	// One of the KeyProblem* codes
+	Code string
+	// ID of the primary key or of the subkey affected
+	KeyID   string
+	Message string
+}
+
+// KeyValidationReport lists the problems found by (*Key).Validate.
+type KeyValidationReport struct {
+	Problems []*KeyProblem
+}
+
+// IsValid returns true if no problem was found.
+func (report *KeyValidationReport) IsValid() bool {
+	return len(report.Problems) == 0
+}
+
+// HasProblem returns true if a problem with the given code was found.
+func (report *KeyValidationReport) HasProblem(code string) bool {
+	for _, problem := range report.Problems {
+		if problem.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate verifies the self-signatures and binding signatures of the key,
+// the consistency of the expiration times, and the strength of the algorithms,
+// and returns the problems found.
+func (key *Key) Validate() *KeyValidationReport {
+	now := getNow()
+	entity := key.entity
+	primaryKey := entity.PrimaryKey
+	primaryKeyID := keyIDToHex(primaryKey.KeyId)
+	report := &KeyValidationReport{}
+
+	report.checkAlgorithm(primaryKey)
+	if entity.Revoked(now) {
+		report.add(KeyProblemRevoked, primaryKeyID, "primary key is revoked")
+	}
+
+	for name, identity := range entity.Identities {
+		for _, sig := range identity.Signatures {
+			if !sig.CheckKeyIdOrFingerprint(primaryKey) {
+				continue
+			}
+			if err := primaryKey.VerifyUserIdSignature(name, primaryKey, sig); err != nil {
+				report.add(
+					KeyProblemInvalidSelfSignature, primaryKeyID,
+					fmt.Sprintf("invalid self-signature on user ID %q: %v", name, err),
+				)
+				continue
+			}
+			report.checkHash(sig, primaryKeyID, fmt.Sprintf("self-signature on user ID %q", name))
+		}
+	}
+
+	primaryIdentity := entity.PrimaryIdentity()
+	var primaryExpiration time.Time
+	if primaryIdentity != nil {
+		if primaryKey.KeyExpired(primaryIdentity.SelfSignature, now) {
+			report.add(KeyProblemExpired, primaryKeyID, "primary key is expired")
+		}
+		primaryExpiration = expirationTime(primaryKey, primaryIdentity.SelfSignature)
+	}
+
+	for i := range entity.Subkeys {
+		subkey := &entity.Subkeys[i]
+		subkeyID := keyIDToHex(subkey.PublicKey.KeyId)
+		report.checkAlgorithm(subkey.PublicKey)
+
+		if subkey.Sig.FlagsValid && subkey.Sig.FlagSign {
+			if subkey.Sig.EmbeddedSignature == nil {
+				report.add(
+					KeyProblemMissingCrossCertification, subkeyID,
+					"signing subkey is missing its cross-certification",
+				)
+				continue
+			}
+			if err := verifyCrossCertification(primaryKey, subkey.PublicKey, subkey.Sig); err != nil {
+				report.add(
+					KeyProblemMissingCrossCertification, subkeyID,
+					fmt.Sprintf("invalid cross-certification: %v", err),
+				)
+				continue
+			}
+		}
+		if err := primaryKey.VerifyKeySignature(subkey.PublicKey, subkey.Sig); err != nil {
+			report.add(
+				KeyProblemInvalidBindingSignature, subkeyID,
+				fmt.Sprintf("invalid binding signature: %v", err),
+			)
+			continue
+		}
+		report.checkHash(subkey.Sig, subkeyID, "binding signature")
+
+		if subkey.Revoked(now) {
+			continue
+		}
+		if subkey.PublicKey.KeyExpired(subkey.Sig, now) {
+			report.add(KeyProblemSubkeyExpired, subkeyID, "subkey is expired")
+			continue
+		}
+		subkeyExpiration := expirationTime(subkey.PublicKey, subkey.Sig)
+		if !primaryExpiration.IsZero() && subkeyExpiration.After(primaryExpiration) {
+			report.add(
+				KeyProblemSubkeyOutlivesPrimary, subkeyID,
+				"subkey expires after the primary key",
+			)
+		}
+	}
+
+	return report
+}
+
+// --- Internal functions
+
+func (report *KeyValidationReport) add(code, keyID, message string) {
+	report.Problems = append(report.Problems, &KeyProblem{Code: code, KeyID: keyID, Message: message})
+}
+
+// checkAlgorithm reports rsa, dsa and elgamal keys smaller than
+// minimumBitLength.
+func (report *KeyValidationReport) checkAlgorithm(pk *packet.PublicKey) {
+	switch pk.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoRSASignOnly,
+		packet.PubKeyAlgoDSA, packet.PubKeyAlgoElGamal:
+	default:
+		return
+	}
+	bitLength, err := pk.BitLength()
+	if err != nil || bitLength >= minimumBitLength {
+		return
+	}
+	report.add(
+		KeyProblemWeakAlgorithm, keyIDToHex(pk.KeyId),
+		fmt.Sprintf("%d-bit %s key is too small", bitLength, pubKeyAlgoNames[pk.PubKeyAlgo]),
+	)
+}
+
+// checkHash reports signatures using a broken hash function.
+func (report *KeyValidationReport) checkHash(sig *packet.Signature, keyID, description string) {
+	switch sig.Hash {
+	case crypto.MD5, crypto.SHA1, crypto.RIPEMD160:
+		report.add(
+			KeyProblemWeakHash, keyID,
+			fmt.Sprintf("%s uses the weak hash function %v", description, sig.Hash),
+		)
+	}
+}
+
+// expirationTime returns the expiration time of pk set by sig, or the zero
+// time if it doesn't expire.
+func expirationTime(pk *packet.PublicKey, sig *packet.Signature) time.Time {
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return time.Time{}
+	}
+	return pk.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+}
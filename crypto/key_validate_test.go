@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyValidate(t *testing.T) {
+	assert.True(t, keyTestEC.Validate().IsValid())
+
+	report := keyTestRSA.Validate()
+	assert.False(t, report.IsValid())
+	assert.True(t, report.HasProblem(KeyProblemWeakAlgorithm))
+	assert.Equal(t, keyTestRSA.GetHexKeyID(), report.Problems[0].KeyID)
+
+	key, err := GenerateKeyWithExpiration(keyTestName, keyTestDomain, "x25519", 0, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	assert.True(t, key.Validate().IsValid())
+
+	withSubkey, err := key.AddEncryptionSubkey("x25519", 0, 7200)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	report = withSubkey.Validate()
+	assert.Len(t, report.Problems, 1)
+	assert.True(t, report.HasProblem(KeyProblemSubkeyOutlivesPrimary))
+
+	withSubkey, err = key.AddEncryptionSubkey("x25519", 0, 60)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	pgp.latestServerTime = testTime + 120
+	defer func() {
+		pgp.latestServerTime = testTime
+	}()
+	report = withSubkey.Validate()
+	assert.Len(t, report.Problems, 1)
+	assert.True(t, report.HasProblem(KeyProblemSubkeyExpired))
+	pgp.latestServerTime = testTime
+
+	withSubkey, err = key.AddSigningSubkey("x25519", 0, 60)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	assert.True(t, withSubkey.Validate().IsValid())
+	lastSubkey := &withSubkey.entity.Subkeys[len(withSubkey.entity.Subkeys)-1]
+
+	// A cross-certification made by another subkey is invalid
+	otherSubkey, err := key.AddSigningSubkey("x25519", 0, 60)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	crossCertification := lastSubkey.Sig.EmbeddedSignature
+	lastSubkey.Sig.EmbeddedSignature = otherSubkey.entity.Subkeys[len(otherSubkey.entity.Subkeys)-1].Sig.EmbeddedSignature
+	report = withSubkey.Validate()
+	assert.Len(t, report.Problems, 1)
+	assert.True(t, report.HasProblem(KeyProblemMissingCrossCertification))
+	lastSubkey.Sig.EmbeddedSignature = crossCertification
+	assert.True(t, withSubkey.Validate().IsValid())
+
+	lastSubkey.Sig.EmbeddedSignature = nil
+	report = withSubkey.Validate()
+	assert.True(t, report.HasProblem(KeyProblemMissingCrossCertification))
+
+	revoked, err := NewKeyFromArmored(readTestFile("key_revoked", false))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	pgp.latestServerTime = 1632219895
+	assert.True(t, revoked.Validate().HasProblem(KeyProblemRevoked))
+}
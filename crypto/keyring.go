@@ -2,20 +2,53 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/hex"
+	goerrors "errors"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
 	"github.com/pkg/errors"
 )
 
 // KeyRing contains multiple private and public keys.
+//
+// A KeyRing is safe for concurrent use: its keys can be used to encrypt,
+// decrypt, sign and verify by several goroutines, while others add keys, lock
+// or unlock it. Lock, and auto-locking, wait for the running operations
+// decrypting or signing with the keyring before zeroing its keys, but not for
+// the streams still open. ClearPrivateParams and Wipe must only be called
+// once the keyring is no longer in use. Clone gives a goroutine its own
+// keyring, that can be locked and unlocked independently.
 type KeyRing struct {
-	// PGP entities in this keyring.
+	// PGP entities in this keyring. The list is replaced, never modified in
+	// place, once the keyring is shared, see getEntities.
 	entities openpgp.EntityList
 
+	// User attributes of the keys, by primary key fingerprint, see
+	// Key.userAttributes. Like entities, the map is replaced, never modified
+	// in place.
+	userAttributes map[string][]*userAttribute
+
 	// FirstKeyID as obtained from API to match salt
 	FirstKeyID string
+
+	// Encryption subkey policy, and fingerprints of the selected encryption
+	// subkeys, see SetEncryptionSubkeyPolicy
+	encryptionPolicy  EncryptionSubkeyPolicy
+	encryptionSubkeys map[string]bool
+
+	// Guards entities, userAttributes, lockState and the encryption subkeys
+	lock sync.RWMutex
+
+	// Locked form of the keys, auto-lock timer and running operations, see
+	// Lock
+	lockState *keyRingLockState
 }
 
 // Identity contains the name and the email of a key holder.
@@ -36,6 +69,19 @@ func NewKeyRing(key *Key) (*KeyRing, error) {
 	return keyRing, err
 }
 
+// NewKeyRingFromReader creates a new KeyRing from all the unarmored binary
+// keys read from r. Private keys must be unlocked.
+func NewKeyRingFromReader(r io.Reader) (*KeyRing, error) {
+	return newKeyRingFromReader(r, false)
+}
+
+// NewKeyRingFromArmoredReader creates a new KeyRing from all the keys of the
+// armored blocks read from r, public and private blocks mixed. Private keys
+// must be unlocked.
+func NewKeyRingFromArmoredReader(r io.Reader) (*KeyRing, error) {
+	return newKeyRingFromReader(r, true)
+}
+
 // AddKey adds the given key to the keyring.
 func (keyRing *KeyRing) AddKey(key *Key) error {
 	if key.IsPrivate() {
@@ -53,34 +99,72 @@ func (keyRing *KeyRing) AddKey(key *Key) error {
 
 // GetKeys returns openpgp keys contained in this KeyRing.
 func (keyRing *KeyRing) GetKeys() []*Key {
-	keys := make([]*Key, keyRing.CountEntities())
-	for i, entity := range keyRing.entities {
-		keys[i] = &Key{entity}
+	entities := keyRing.getEntities()
+	keys := make([]*Key, len(entities))
+	for i, entity := range entities {
+		keys[i] = keyRing.newKey(entity)
 	}
 	return keys
 }
 
 // GetKey returns the n-th openpgp key contained in this KeyRing.
 func (keyRing *KeyRing) GetKey(n int) (*Key, error) {
-	if n >= keyRing.CountEntities() {
+	entities := keyRing.getEntities()
+	if n >= len(entities) {
 		return nil, errors.New("gopenpgp: out of bound when fetching key")
 	}
-	return &Key{keyRing.entities[n]}, nil
+	return keyRing.newKey(entities[n]), nil
+}
+
+// GetEntityByKeyID returns the key of the keyring whose primary key or one of
+// whose subkeys has the given key ID.
+func (keyRing *KeyRing) GetEntityByKeyID(keyID uint64) (*Key, error) {
+	keys := keyRing.getEntities().KeysById(keyID)
+	if len(keys) == 0 {
+		return nil, errors.New("gopenpgp: no key found with key ID " + keyIDToHex(keyID))
+	}
+	return keyRing.newKey(keys[0].Entity), nil
+}
+
+// GetEntityByFingerprint returns the key of the keyring whose primary key or
+// one of whose subkeys has the given hex fingerprint.
+func (keyRing *KeyRing) GetEntityByFingerprint(fingerprint string) (*Key, error) {
+	for _, entity := range keyRing.getEntities() {
+		if strings.EqualFold(hex.EncodeToString(entity.PrimaryKey.Fingerprint), fingerprint) {
+			return keyRing.newKey(entity), nil
+		}
+		for _, subkey := range entity.Subkeys {
+			if strings.EqualFold(hex.EncodeToString(subkey.PublicKey.Fingerprint), fingerprint) {
+				return keyRing.newKey(entity), nil
+			}
+		}
+	}
+	return nil, errors.New("gopenpgp: no key found with fingerprint " + fingerprint)
 }
 
 // getSigningEntity returns first private unlocked signing entity from keyring.
 func (keyRing *KeyRing) getSigningEntity() (*openpgp.Entity, error) {
 	var signEntity *openpgp.Entity
+	hasDummySigner := false
 
-	for _, e := range keyRing.entities {
+	for _, e := range keyRing.getEntities() {
 		// Entity.PrivateKey must be a signing key
 		if e.PrivateKey != nil {
+			// Keys whose signing key is a gnu-dummy stub, e.g. an offline
+			// primary key without signing subkey, can't sign
+			if hasDummySigningKey(e) {
+				hasDummySigner = true
+				continue
+			}
 			if !e.PrivateKey.Encrypted {
 				signEntity = e
 				break
 			}
 		}
 	}
+	if signEntity == nil && hasDummySigner {
+		return nil, errors.New("gopenpgp: cannot sign message, the secret signing key is missing (gnu-dummy)")
+	}
 	if signEntity == nil {
 		return nil, errors.New("gopenpgp: cannot sign message, unable to unlock signer key")
 	}
@@ -88,37 +172,56 @@ func (keyRing *KeyRing) getSigningEntity() (*openpgp.Entity, error) {
 	return signEntity, nil
 }
 
+// hasDummySigningKey returns whether the secret key material of the current
+// signing key of e is missing, e.g. replaced by a gnu-dummy stub.
+func hasDummySigningKey(e *openpgp.Entity) bool {
+	signingKey, ok := signingKeyAt(e, getNow(), 0)
+	return ok && (signingKey.PrivateKey == nil || signingKey.PrivateKey.Dummy())
+}
+
 // --- Extract info from key
 
 // CountEntities returns the number of entities in the keyring.
 func (keyRing *KeyRing) CountEntities() int {
-	return len(keyRing.entities)
+	return len(keyRing.getEntities())
 }
 
 // CountDecryptionEntities returns the number of entities in the keyring.
 func (keyRing *KeyRing) CountDecryptionEntities() int {
-	return len(keyRing.entities.DecryptionKeys())
+	return len(keyRing.getEntities().DecryptionKeys())
 }
 
-// GetIdentities returns the list of identities associated with this key ring.
+// GetIdentities returns the list of identities associated with this key ring,
+// as returned by Key.GetIdentities for each key in order.
 func (keyRing *KeyRing) GetIdentities() []*Identity {
 	var identities []*Identity
-	for _, e := range keyRing.entities {
-		for _, id := range e.Identities {
-			identities = append(identities, &Identity{
-				Name:  id.UserId.Name,
-				Email: id.UserId.Email,
-			})
-		}
+	for _, key := range keyRing.GetKeys() {
+		identities = append(identities, key.GetIdentities()...)
 	}
 	return identities
 }
 
+// GetPrimaryIdentity returns the primary identity of the first key of the
+// keyring, as returned by Key.GetPrimaryIdentity, or nil if it has none.
+func (keyRing *KeyRing) GetPrimaryIdentity() *Identity {
+	entities := keyRing.getEntities()
+	if len(entities) == 0 {
+		return nil
+	}
+	return (&Key{entity: entities[0]}).GetPrimaryIdentity()
+}
+
 // CanVerify returns true if any of the keys in the keyring can be used for verification.
 func (keyRing *KeyRing) CanVerify() bool {
+	return keyRing.CanVerifyAt(GetUnixTime())
+}
+
+// CanVerifyAt returns true if any of the keys in the keyring can be used for
+// verification at the given unix time, as Key.CanVerifyAt.
+func (keyRing *KeyRing) CanVerifyAt(unixTime int64) bool {
 	keys := keyRing.GetKeys()
 	for _, key := range keys {
-		if key.CanVerify() {
+		if key.CanVerifyAt(unixTime) {
 			return true
 		}
 	}
@@ -127,9 +230,32 @@ func (keyRing *KeyRing) CanVerify() bool {
 
 // CanEncrypt returns true if any of the keys in the keyring can be used for encryption.
 func (keyRing *KeyRing) CanEncrypt() bool {
+	return keyRing.CanEncryptAt(GetUnixTime())
+}
+
+// CanEncryptAt returns true if any of the keys in the keyring can be used for
+// encryption at the given unix time, as Key.CanEncryptAt.
+func (keyRing *KeyRing) CanEncryptAt(unixTime int64) bool {
+	keys := keyRing.GetKeys()
+	for _, key := range keys {
+		if key.CanEncryptAt(unixTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSign returns true if any of the keys in the keyring can be used for signing.
+func (keyRing *KeyRing) CanSign() bool {
+	return keyRing.CanSignAt(GetUnixTime())
+}
+
+// CanSignAt returns true if any of the keys in the keyring can be used for
+// signing at the given unix time, as Key.CanSignAt.
+func (keyRing *KeyRing) CanSignAt(unixTime int64) bool {
 	keys := keyRing.GetKeys()
 	for _, key := range keys {
-		if key.CanEncrypt() {
+		if key.CanSignAt(unixTime) {
 			return true
 		}
 	}
@@ -138,13 +264,110 @@ func (keyRing *KeyRing) CanEncrypt() bool {
 
 // GetKeyIDs returns array of IDs of keys in this KeyRing.
 func (keyRing *KeyRing) GetKeyIDs() []uint64 {
-	var res = make([]uint64, len(keyRing.entities))
-	for id, e := range keyRing.entities {
+	entities := keyRing.getEntities()
+	var res = make([]uint64, len(entities))
+	for id, e := range entities {
 		res[id] = e.PrimaryKey.KeyId
 	}
 	return res
 }
 
+// --- Revoke keys
+
+// GenerateRevocationCertificate returns an armored revocation certificate for
+// the keys in the keyring, with one of the constants.Revocation* reasons for
+// key revocations and a human-readable reasonText. It can be stored offline
+// and imported when the keys need to be revoked.
+// The keys must be unlocked.
+func (keyRing *KeyRing) GenerateRevocationCertificate(reason int, reasonText string) (string, error) {
+	if len(keyRing.getEntities()) == 0 {
+		return "", errors.New("gopenpgp: no keys in keyring")
+	}
+
+	var outBuf bytes.Buffer
+	for _, key := range keyRing.GetKeys() {
+		revSig, err := key.revocationSignature(reason, reasonText)
+		if err != nil {
+			return "", err
+		}
+		if err = revSig.Serialize(&outBuf); err != nil {
+			return "", errors.Wrap(err, "gopenpgp: error in serializing revocation signature")
+		}
+	}
+
+	return armor.ArmorWithType(outBuf.Bytes(), constants.PublicKeyHeader)
+}
+
+// ApplyRevocationCertificate imports an armored revocation certificate, and
+// merges its revocation signatures into the matching keys of the keyring.
+// The revoked keys can no longer be used to encrypt or verify.
+func (keyRing *KeyRing) ApplyRevocationCertificate(armoredCertificate string) error {
+	unarmored, err := armor.Unarmor(armoredCertificate)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in unarmoring revocation certificate")
+	}
+
+	var revocations []*packet.Signature
+	packets := packet.NewReader(bytes.NewReader(unarmored))
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: error in reading revocation certificate")
+		}
+		revSig, ok := p.(*packet.Signature)
+		if !ok || revSig.SigType != packet.SigTypeKeyRevocation {
+			return errors.New("gopenpgp: revocation certificate contains a non-revocation packet")
+		}
+		revocations = append(revocations, revSig)
+	}
+	if len(revocations) == 0 {
+		return errors.New("gopenpgp: empty revocation certificate")
+	}
+
+	// Check all signatures before modifying the keyring
+	revokedEntities := make([]*openpgp.Entity, len(revocations))
+	for i, revSig := range revocations {
+		for _, entity := range keyRing.getEntities() {
+			if revSig.CheckKeyIdOrFingerprint(entity.PrimaryKey) {
+				revokedEntities[i] = entity
+				break
+			}
+		}
+		if revokedEntities[i] == nil {
+			return errors.New("gopenpgp: no matching key found for revocation certificate")
+		}
+		if err := revokedEntities[i].PrimaryKey.VerifyRevocationSignature(revSig); err != nil {
+			return errors.Wrap(err, "gopenpgp: invalid revocation signature")
+		}
+	}
+
+	// The revoked keys are replaced by copies, as the keys may be in use
+	revoked := make(map[*openpgp.Entity]*openpgp.Entity)
+	for i, revSig := range revocations {
+		revokedEntity, ok := revoked[revokedEntities[i]]
+		if !ok {
+			entityCopy := *revokedEntities[i]
+			entityCopy.Revocations = append([]*packet.Signature{}, entityCopy.Revocations...)
+			revokedEntity = &entityCopy
+			revoked[revokedEntities[i]] = revokedEntity
+		}
+		revokedEntity.Revocations = append(revokedEntity.Revocations, revSig)
+	}
+	keyRing.updateEntities(func(entities openpgp.EntityList) openpgp.EntityList {
+		for i, entity := range entities {
+			if revokedEntity, ok := revoked[entity]; ok {
+				entities[i] = revokedEntity
+			}
+		}
+		return entities
+	})
+
+	return nil
+}
+
 // --- Filter keyrings
 
 // FilterExpiredKeys takes a given KeyRing list and it returns only those
@@ -158,11 +381,11 @@ func FilterExpiredKeys(contactKeys []*KeyRing) (filteredKeys []*KeyRing, err err
 	for _, contactKeyRing := range contactKeys {
 		keyRingHasUnexpiredEntity := false
 		keyRingHasTotallyExpiredEntity := false
-		for _, entity := range contactKeyRing.entities {
+		for _, entity := range contactKeyRing.getEntities() {
 			hasExpired := false
 			hasUnexpired := false
 			for _, subkey := range entity.Subkeys {
-				if subkey.PublicKey.KeyExpired(subkey.Sig, now) {
+				if isKeyExpired(subkey.PublicKey, subkey.Sig, now) {
 					hasExpired = true
 				} else {
 					hasUnexpired = true
@@ -193,13 +416,36 @@ func FilterExpiredKeys(contactKeys []*KeyRing) (filteredKeys []*KeyRing, err err
 	return filteredKeys, nil
 }
 
+// FilterExpired returns a copy of the keyring without the expired keys.
+func (keyRing *KeyRing) FilterExpired() (*KeyRing, error) {
+	return keyRing.filter(func(key *Key) bool { return !key.IsExpired() })
+}
+
+// FilterRevoked returns a copy of the keyring without the revoked keys.
+func (keyRing *KeyRing) FilterRevoked() (*KeyRing, error) {
+	return keyRing.filter(func(key *Key) bool { return !key.IsRevoked() })
+}
+
+// FilterEncryptionCapable returns a copy of the keyring with only the keys
+// that have a valid encryption key, to be used as recipients.
+func (keyRing *KeyRing) FilterEncryptionCapable() (*KeyRing, error) {
+	return keyRing.filter((*Key).CanEncrypt)
+}
+
+// FilterSigningCapable returns a copy of the keyring with only the keys that
+// have a valid signing key, which can sign if private, or verify.
+func (keyRing *KeyRing) FilterSigningCapable() (*KeyRing, error) {
+	return keyRing.filter((*Key).CanVerify)
+}
+
 // FirstKey returns a KeyRing with only the first key of the original one.
 func (keyRing *KeyRing) FirstKey() (*KeyRing, error) {
-	if len(keyRing.entities) == 0 {
+	entities := keyRing.getEntities()
+	if len(entities) == 0 {
 		return nil, errors.New("gopenpgp: No key available in this keyring")
 	}
 	newKeyRing := &KeyRing{}
-	newKeyRing.entities = keyRing.entities[:1]
+	newKeyRing.appendKey(keyRing.newKey(entities[0]))
 
 	return newKeyRing.Copy()
 }
@@ -208,8 +454,10 @@ func (keyRing *KeyRing) FirstKey() (*KeyRing, error) {
 func (keyRing *KeyRing) Copy() (*KeyRing, error) {
 	newKeyRing := &KeyRing{}
 
-	entities := make([]*openpgp.Entity, len(keyRing.entities))
-	for id, entity := range keyRing.entities {
+	keys := keyRing.GetKeys()
+	keyCopies := make([]*Key, len(keys))
+	for id, key := range keys {
+		entity := key.entity
 		var buffer bytes.Buffer
 		var err error
 
@@ -223,19 +471,153 @@ func (keyRing *KeyRing) Copy() (*KeyRing, error) {
 			return nil, errors.Wrap(err, "gopenpgp: unable to copy key: error in serializing entity")
 		}
 
-		bt := buffer.Bytes()
-		entities[id], err = openpgp.ReadEntity(packet.NewReader(bytes.NewReader(bt)))
+		bt, err := insertUserAttributes(key.userAttributes, buffer.Bytes(), false)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to copy key: error in serializing entity")
+		}
+		keyCopies[id], err = readKey(bt)
 
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: unable to copy key: error in reading entity")
 		}
 	}
-	newKeyRing.entities = entities
+	newKeyRing.updateEntities(func(openpgp.EntityList) openpgp.EntityList {
+		entities := make(openpgp.EntityList, len(keyCopies))
+		for i, keyCopy := range keyCopies {
+			entities[i] = keyCopy.entity
+		}
+		return entities
+	}, keyCopies...)
 	newKeyRing.FirstKeyID = keyRing.FirstKeyID
+	keyRing.copyEncryptionSubkeys(newKeyRing)
 
 	return newKeyRing, nil
 }
 
+// Clone returns a deep copy of the keyring, keeping the locked form of its
+// keys, that can be locked and unlocked independently of the keyring, e.g. by
+// another goroutine. The auto-locking is not copied.
+func (keyRing *KeyRing) Clone() (*KeyRing, error) {
+	keyRingClone, err := keyRing.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	cloneState := keyRingClone.getLockState()
+	for fingerprint, serialized := range state.lockedKeys {
+		cloneState.lockedKeys[fingerprint] = serialized
+	}
+	return keyRingClone, nil
+}
+
+// ToPublic returns a copy of the keyring with the secret key material of every
+// key stripped, which can be safely published.
+func (keyRing *KeyRing) ToPublic() (*KeyRing, error) {
+	publicKeyRing := &KeyRing{FirstKeyID: keyRing.FirstKeyID}
+
+	for _, key := range keyRing.GetKeys() {
+		var publicKey *Key
+		var err error
+
+		if key.IsPrivate() {
+			publicKey, err = key.ToPublic()
+		} else {
+			publicKey, err = key.Copy()
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to convert keyring to public")
+		}
+
+		publicKeyRing.appendKey(publicKey)
+	}
+	keyRing.copyEncryptionSubkeys(publicKeyRing)
+
+	return publicKeyRing, nil
+}
+
+// Serialize returns the keys of the keyring concatenated in the binary
+// transferable key format, as read by NewKeyRingFromReader and by
+// `gpg --import`. The secret key material of the unlocked private keys is
+// included, unprotected.
+func (keyRing *KeyRing) Serialize() ([]byte, error) {
+	var outBuf bytes.Buffer
+	for _, key := range keyRing.GetKeys() {
+		serialized, err := key.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		outBuf.Write(serialized)
+	}
+
+	return outBuf.Bytes(), nil
+}
+
+// GetPublicKeys returns the public keys of the keyring concatenated in the
+// binary transferable key format, without secret key material.
+func (keyRing *KeyRing) GetPublicKeys() ([]byte, error) {
+	var outBuf bytes.Buffer
+	for _, key := range keyRing.GetKeys() {
+		serialized, err := key.GetPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		outBuf.Write(serialized)
+	}
+
+	return outBuf.Bytes(), nil
+}
+
+// GetArmoredPublicKeys writes the public keys of the keyring to w, as one
+// armored public key block per key separated by a line break, e.g. for
+// publishing. Secret key material is never written.
+func (keyRing *KeyRing) GetArmoredPublicKeys(w io.Writer) error {
+	for _, key := range keyRing.GetKeys() {
+		armored, err := key.GetArmoredPublicKey()
+		if err != nil {
+			return err
+		}
+		if err = writeArmoredKey(w, armored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetArmoredPrivateKeys writes the private keys of the keyring to w, as one
+// armored private key block per key separated by a line break, e.g. for
+// backups. Private keys are never written unprotected: locked keys are
+// written as they are, partially unlocked keys in their locked form, and
+// unlocked keys locked with passphrase, which is only required if the keyring
+// has unlocked keys. Nothing is written if it fails, e.g. if the keyring holds
+// public keys.
+func (keyRing *KeyRing) GetArmoredPrivateKeys(w io.Writer, passphrase []byte) error {
+	keys := keyRing.GetKeys()
+	armoredKeys := make([]string, len(keys))
+	for i, key := range keys {
+		if !key.IsPrivate() {
+			return errors.New("gopenpgp: key " + key.GetFingerprint() + " is not a private key")
+		}
+		lockedKey, err := keyRing.getLockedKey(key, passphrase)
+		if err != nil {
+			return err
+		}
+		if armoredKeys[i], err = lockedKey.Armor(); err != nil {
+			return err
+		}
+	}
+	for _, armored := range armoredKeys {
+		if err := writeArmoredKey(w, armored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (keyRing *KeyRing) ClearPrivateParams() {
 	for _, key := range keyRing.GetKeys() {
 		key.ClearPrivateParams()
@@ -244,7 +626,130 @@ func (keyRing *KeyRing) ClearPrivateParams() {
 
 // INTERNAL FUNCTIONS
 
+// filter returns a copy of the keyring with only the keys selected by keep.
+func (keyRing *KeyRing) filter(keep func(*Key) bool) (*KeyRing, error) {
+	filteredKeyRing := &KeyRing{FirstKeyID: keyRing.FirstKeyID}
+	for _, key := range keyRing.GetKeys() {
+		if keep(key) {
+			filteredKeyRing.appendKey(key)
+		}
+	}
+	return filteredKeyRing.Copy()
+}
+
+// getLockedKey returns the private key of the keyring with all its parts
+// locked: the key itself if it is locked, the locked form kept by the keyring
+// if it is partially unlocked, or a copy locked with passphrase if it is
+// unlocked.
+func (keyRing *KeyRing) getLockedKey(key *Key, passphrase []byte) (*Key, error) {
+	if !hasUnlockedPart(key) {
+		return key, nil
+	}
+	if unlocked, err := key.IsUnlocked(); err == nil && unlocked {
+		if len(passphrase) == 0 {
+			return nil, errors.New("gopenpgp: a passphrase is required to export the unlocked key " + key.GetFingerprint())
+		}
+		return key.Lock(passphrase)
+	}
+
+	// Partially unlocked, e.g. by DecryptWithPassphraseCallback
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	serialized, ok := state.lockedKeys[key.GetFingerprint()]
+	state.lock.Unlock()
+	if !ok {
+		return nil, errors.New("gopenpgp: key " + key.GetFingerprint() + " is partially unlocked")
+	}
+	return NewKey(serialized)
+}
+
+// writeArmoredKey writes an armored key block to w, followed by a line break.
+func writeArmoredKey(w io.Writer, armored string) error {
+	if _, err := io.WriteString(w, armored+"\n"); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in writing armored key")
+	}
+	return nil
+}
+
 // appendKey appends a key to the keyring.
 func (keyRing *KeyRing) appendKey(key *Key) {
-	keyRing.entities = append(keyRing.entities, key.entity)
+	keyRing.updateEntities(func(entities openpgp.EntityList) openpgp.EntityList {
+		return append(entities, key.entity)
+	}, key)
+}
+
+// newKey returns the key of an entity of the keyring, with its user
+// attributes.
+func (keyRing *KeyRing) newKey(entity *openpgp.Entity) *Key {
+	keyRing.lock.RLock()
+	defer keyRing.lock.RUnlock()
+
+	return &Key{entity: entity, userAttributes: keyRing.userAttributes[string(entity.PrimaryKey.Fingerprint)]}
+}
+
+// getEntities returns the entities of the keyring. The returned list is never
+// modified, and can be read while the keyring changes. It must not be
+// appended to in place: build a new list to add other entities.
+func (keyRing *KeyRing) getEntities() openpgp.EntityList {
+	keyRing.lock.RLock()
+	defer keyRing.lock.RUnlock()
+
+	return keyRing.entities
+}
+
+// updateEntities replaces the entities of the keyring with the list returned
+// by update, given a copy of the current list that it can modify, and merges
+// the user attributes of keys, the keys of the new entities, into the ones of
+// the keyring. The replaced entities keep the user attributes of their
+// fingerprint.
+func (keyRing *KeyRing) updateEntities(update func(openpgp.EntityList) openpgp.EntityList, keys ...*Key) {
+	keyRing.lock.Lock()
+	defer keyRing.lock.Unlock()
+
+	entities := make(openpgp.EntityList, len(keyRing.entities), len(keyRing.entities)+1)
+	copy(entities, keyRing.entities)
+	entities = update(entities)
+	// The capacity is clipped so that appending to the shared list always
+	// copies it, instead of writing to its spare slots
+	keyRing.entities = entities[:len(entities):len(entities)]
+
+	var userAttributes map[string][]*userAttribute
+	for _, key := range keys {
+		if len(key.userAttributes) == 0 {
+			continue
+		}
+		if userAttributes == nil {
+			userAttributes = make(map[string][]*userAttribute, len(keyRing.userAttributes)+len(keys))
+			for fingerprint, attributes := range keyRing.userAttributes {
+				userAttributes[fingerprint] = attributes
+			}
+		}
+		fingerprint := string(key.entity.PrimaryKey.Fingerprint)
+		userAttributes[fingerprint] = mergeUserAttributes(
+			key.entity.PrimaryKey, userAttributes[fingerprint], key.userAttributes,
+		)
+	}
+	if userAttributes != nil {
+		keyRing.userAttributes = userAttributes
+	}
+}
+
+// newKeyRingFromReader reads the armored or unarmored keys from r into a new
+// keyring.
+func newKeyRingFromReader(r io.Reader, armored bool) (*KeyRing, error) {
+	keys, err := readKeyRing(r, armored)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key ring")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("gopenpgp: the key ring does not contain any entity")
+	}
+
+	keyRing := &KeyRing{}
+	for _, key := range keys {
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
 }
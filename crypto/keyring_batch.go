@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"github.com/pkg/errors"
+)
+
+// BatchEncryptor encrypts many messages to the same recipients with one
+// session key, encrypted once to the recipients, so that each message only
+// costs the symmetric encryption of its own data packet.
+// The messages stay independent, each with the key packet and its own data
+// packet, but anyone holding the session key of one of them can decrypt all of
+// them. It is safe for concurrent use.
+type BatchEncryptor struct {
+	sessionKey  *SessionKey
+	keyPacket   []byte
+	signKeyRing *KeyRing
+}
+
+// NewBatchEncryptor creates a BatchEncryptor encrypting to the keys of
+// keyRing with a new session key, and signing the messages with signKeyRing,
+// if not nil.
+func (keyRing *KeyRing) NewBatchEncryptor(signKeyRing *KeyRing) (*BatchEncryptor, error) {
+	sessionKey, err := GenerateSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	keyPacket, err := keyRing.EncryptSessionKey(sessionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create batch encryptor")
+	}
+	return &BatchEncryptor{
+		sessionKey:  sessionKey,
+		keyPacket:   keyPacket,
+		signKeyRing: signKeyRing,
+	}, nil
+}
+
+// GetKeyPacket returns the key packet shared by the messages.
+func (e *BatchEncryptor) GetKeyPacket() []byte {
+	return clone(e.keyPacket)
+}
+
+// GetSessionKey returns the session key shared by the messages.
+func (e *BatchEncryptor) GetSessionKey() *SessionKey {
+	return e.sessionKey
+}
+
+// Encrypt encrypts a message of the batch, returning the complete message
+// with the shared key packet.
+func (e *BatchEncryptor) Encrypt(message *PlainMessage) (*PGPMessage, error) {
+	split, err := e.EncryptSplit(message)
+	if err != nil {
+		return nil, err
+	}
+	return split.GetPGPMessage(), nil
+}
+
+// EncryptSplit encrypts a message of the batch, returning the shared key
+// packet and the data packet of the message separately.
+func (e *BatchEncryptor) EncryptSplit(message *PlainMessage) (*PGPSplitMessage, error) {
+	var dataPacket []byte
+	var err error
+	if e.signKeyRing != nil {
+		dataPacket, err = e.sessionKey.EncryptAndSign(message, e.signKeyRing)
+	} else {
+		dataPacket, err = e.sessionKey.Encrypt(message)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to encrypt message of the batch")
+	}
+	return NewPGPSplitMessage(e.GetKeyPacket(), dataPacket), nil
+}
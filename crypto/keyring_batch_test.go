@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchEncryptor(t *testing.T) {
+	encryptor, err := keyRingTestPublic.NewBatchEncryptor(nil)
+	if err != nil {
+		t.Fatal("Cannot create batch encryptor:", err)
+	}
+
+	var dataPackets [][]byte
+	for _, content := range []string{"first log line", "second log line"} {
+		split, err := encryptor.EncryptSplit(NewPlainMessageFromString(content))
+		if err != nil {
+			t.Fatal("Cannot encrypt message:", err)
+		}
+		assert.Exactly(t, encryptor.GetKeyPacket(), split.GetBinaryKeyPacket())
+		dataPackets = append(dataPackets, split.GetBinaryDataPacket())
+
+		decrypted, err := keyRingTestPrivate.Decrypt(split.GetPGPMessage(), nil, 0)
+		if err != nil {
+			t.Fatal("Cannot decrypt message:", err)
+		}
+		assert.Exactly(t, content, decrypted.GetString())
+	}
+	assert.NotEqual(t, dataPackets[0], dataPackets[1])
+
+	sessionKey, err := keyRingTestPrivate.DecryptSessionKey(encryptor.GetKeyPacket())
+	if err != nil {
+		t.Fatal("Cannot decrypt session key:", err)
+	}
+	assert.Exactly(t, encryptor.GetSessionKey(), sessionKey)
+}
+
+func TestBatchEncryptorSigned(t *testing.T) {
+	encryptor, err := keyRingTestPublic.NewBatchEncryptor(keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Cannot create batch encryptor:", err)
+	}
+	message := NewPlainMessageFromString("signed mail")
+	ciphertext, err := encryptor.Encrypt(message)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+	decrypted, err := keyRingTestPrivate.Decrypt(ciphertext, keyRingTestPublic, GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot decrypt and verify message:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	emptyKeyRing, err := NewKeyRing(nil)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	_, err = emptyKeyRing.NewBatchEncryptor(nil)
+	assert.NotNil(t, err)
+}
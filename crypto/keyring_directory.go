@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keyFileExtensions are the extensions of the files loaded by
+// NewKeyRingFromDirectory.
+var keyFileExtensions = map[string]bool{
+	".asc": true,
+	".gpg": true,
+}
+
+// NewKeyRingFromDirectory creates a new KeyRing from the keys of all the .asc
+// and .gpg files under the directory at path, including its subdirectories,
+// in lexical order of their paths. Each file can hold armored or binary keys,
+// detected from its content, and several keys. Other files are ignored, as
+// are hidden files and directories, e.g. the ..<timestamp> directories of
+// Kubernetes mounted secrets. A key found in several files is merged into a
+// single key. Private keys must be unlocked.
+func NewKeyRingFromDirectory(path string) (*KeyRing, error) {
+	keyRing := &KeyRing{}
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != path && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !keyFileExtensions[strings.ToLower(filepath.Ext(filePath))] {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		keys, err := readKeyRing(bytes.NewReader(data), isArmored(data))
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: error in reading key file "+filePath)
+		}
+		fileKeyRing := &KeyRing{}
+		for _, key := range keys {
+			if err = fileKeyRing.AddKey(key); err != nil {
+				return errors.Wrap(err, "gopenpgp: error in adding key of file "+filePath)
+			}
+		}
+		if err = keyRing.Merge(fileKeyRing); err != nil {
+			return errors.Wrap(err, "gopenpgp: error in merging keys of file "+filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key directory")
+	}
+	if keyRing.CountEntities() == 0 {
+		return nil, errors.New("gopenpgp: the key directory does not contain any key")
+	}
+	return keyRing, nil
+}
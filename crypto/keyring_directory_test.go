@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyRingFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sub", "..2022_08_19_08_21_39.123456789"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0700); err != nil {
+			t.Fatal("Expected no error when creating directory, got:", err)
+		}
+	}
+
+	armoredKey, err := keyTestRSA.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal("Expected no error when armoring key, got:", err)
+	}
+	binaryKeys, err := keyRingTestMultiple.GetPublicKeys()
+	if err != nil {
+		t.Fatal("Expected no error when serializing keys, got:", err)
+	}
+	files := map[string][]byte{
+		"a.asc":      []byte(armoredKey),
+		"sub/b.GPG":  binaryKeys,
+		"README.txt": []byte("not a key"),
+		// Hidden entries are skipped, e.g. the data directory of a Kubernetes
+		// secret volume, whose keys are also linked at the top level.
+		"..2022_08_19_08_21_39.123456789/a.asc": []byte(armoredKey),
+		".hidden.asc":                           []byte("not a key"),
+	}
+	for name, data := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal("Expected no error when writing file, got:", err)
+		}
+	}
+
+	keyRing, err := NewKeyRingFromDirectory(dir)
+	if err != nil {
+		t.Fatal("Expected no error when reading directory, got:", err)
+	}
+	// The RSA key of a.asc is also in sub/b.GPG, and is only loaded once
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), keyRingTestMultiple.GetFingerprints()[0])
+	assert.Exactly(t, keyRingTestMultiple.GetFingerprints(), keyRing.GetFingerprints())
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "c.asc"), []byte("not a key"), 0600); err != nil {
+		t.Fatal("Expected no error when writing file, got:", err)
+	}
+	_, err = NewKeyRingFromDirectory(dir)
+	assert.Error(t, err)
+
+	_, err = NewKeyRingFromDirectory(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	_, err = NewKeyRingFromDirectory(t.TempDir())
+	assert.Error(t, err)
+}
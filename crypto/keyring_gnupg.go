@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// keyboxFirstBlob is the type of the header blob of a GnuPG keybox.
+	keyboxFirstBlob = 1
+	// keyboxOpenPGPBlob is the type of the keybox blobs holding an OpenPGP
+	// keyblock.
+	keyboxOpenPGPBlob = 2
+	// keyboxMagic identifies GnuPG keybox files, in their header blob.
+	keyboxMagic = "KBXf"
+)
+
+// ReadGnuPGKeys reads the keys of a GnuPG keybox (pubring.kbx), or of a
+// classic GnuPG keyring (pubring.gpg or secring.gpg), detected from its
+// content. Private keys are returned as stored, usually locked.
+// Keys that can't be read, e.g. v3 keys, are skipped.
+func ReadGnuPGKeys(r io.Reader) ([]*Key, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading GnuPG keyring")
+	}
+
+	if isKeybox(data) {
+		data, err = readKeyboxKeyBlocks(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The trust packets of classic keyrings are skipped by the parser
+	keys, err := readKeyRing(bytes.NewReader(data), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading GnuPG keyring")
+	}
+	return keys, nil
+}
+
+// NewKeyRingFromGnuPG creates a new KeyRing from the keys of a GnuPG keybox or
+// classic keyring, as read by ReadGnuPGKeys. Private keys must be unlocked:
+// use ReadGnuPGKeys to unlock the keys of a secret keyring first.
+func NewKeyRingFromGnuPG(r io.Reader) (*KeyRing, error) {
+	keys, err := ReadGnuPGKeys(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("gopenpgp: the key ring does not contain any entity")
+	}
+
+	keyRing := &KeyRing{}
+	for _, key := range keys {
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// isKeybox returns whether data starts with the header blob of a GnuPG keybox.
+func isKeybox(data []byte) bool {
+	return len(data) >= 12 && data[4] == keyboxFirstBlob && string(data[8:12]) == keyboxMagic
+}
+
+// readKeyboxKeyBlocks returns the concatenated OpenPGP keyblocks of the blobs
+// of a GnuPG keybox. X.509 and empty blobs are skipped.
+// Each blob starts with its length, type, version and flags, followed by the
+// offset and length of its keyblock.
+func readKeyboxKeyBlocks(data []byte) ([]byte, error) {
+	var keyBlocks []byte
+	for len(data) > 0 {
+		if len(data) < 6 {
+			return nil, errors.New("gopenpgp: truncated keybox blob")
+		}
+		blobLen := int(binary.BigEndian.Uint32(data))
+		if blobLen < 6 || blobLen > len(data) {
+			return nil, errors.New("gopenpgp: invalid keybox blob length")
+		}
+		blob := data[:blobLen]
+		data = data[blobLen:]
+
+		if blob[4] != keyboxOpenPGPBlob {
+			continue
+		}
+		if len(blob) < 16 {
+			return nil, errors.New("gopenpgp: truncated keybox blob")
+		}
+		offset := int(binary.BigEndian.Uint32(blob[8:12]))
+		length := int(binary.BigEndian.Uint32(blob[12:16]))
+		if offset < 16 || length > blobLen-offset {
+			return nil, errors.New("gopenpgp: invalid keybox keyblock")
+		}
+		keyBlocks = append(keyBlocks, blob[offset:offset+length]...)
+	}
+	return keyBlocks, nil
+}
@@ -0,0 +1,63 @@
+package crypto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+func TestNewKeyRingFromGnuPG(t *testing.T) {
+	for _, name := range []string{"keyring_gnupg_pubring.kbx", "keyring_gnupg_pubring.gpg"} {
+		file, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatal("Cannot open keyring:", err)
+		}
+
+		keyRing, err := NewKeyRingFromGnuPG(file)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal("Cannot read GnuPG keyring "+name+":", err)
+		}
+
+		assert.Exactly(t, 1, keyRing.CountEntities())
+		assert.Exactly(t, keyRingTestPublic.GetKeys()[0].GetFingerprint(), keyRing.GetKeys()[0].GetFingerprint())
+	}
+
+	_, err := NewKeyRingFromGnuPG(bytes.NewReader([]byte("not a keyring")))
+	assert.NotNil(t, err)
+}
+
+func TestReadGnuPGSecretKeys(t *testing.T) {
+	secring, err := internal.Unarmor(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	data, err := ioutil.ReadAll(secring.Body)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	keys, err := ReadGnuPGKeys(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal("Cannot read GnuPG secret keyring:", err)
+	}
+	assert.Len(t, keys, 1)
+	assert.True(t, keys[0].IsPrivate())
+
+	isLocked, _ := keys[0].IsLocked()
+	assert.True(t, isLocked)
+	_, err = NewKeyRingFromGnuPG(bytes.NewReader(data))
+	assert.NotNil(t, err)
+
+	unlocked, err := keys[0].Unlock(testMailboxPassword)
+	if err != nil {
+		t.Fatal("Cannot unlock key:", err)
+	}
+	_, err = NewKeyRing(unlocked)
+	assert.Nil(t, err)
+}
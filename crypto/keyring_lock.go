@@ -0,0 +1,300 @@
+package crypto
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// keyRingLockState holds the locked form of the keys of a keyring, to lock
+// them again, its auto-lock timer, and the running operations using its
+// private keys.
+type keyRingLockState struct {
+	lock sync.Mutex
+	// Signaled when the last operation of some keyRingUsers is done
+	idle *sync.Cond
+	// Serialized locked keys, by hex fingerprint
+	lockedKeys map[string][]byte
+	idleDelay  time.Duration
+	timer      *time.Timer
+	// Operations started since the keyring was last locked
+	users *keyRingUsers
+}
+
+// keyRingUsers counts the running operations that started using the keys of
+// a keyring before it was locked, and may still use the keys locking zeroes.
+type keyRingUsers struct {
+	count int
+}
+
+// Lock locks the private keys of the keyring again, zeroing their decrypted
+// secret material. The keys added locked, with AddLockedKey, get back their
+// locked form, and can be unlocked again with Unlock or by
+// DecryptWithPassphraseCallback. The other private keys have no locked form
+// and become public.
+// Lock waits for the running operations decrypting or signing with the
+// keyring. The streams signing with it must be closed before it is locked.
+func (keyRing *KeyRing) Lock() {
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	keyRing.lockKeys(state)
+}
+
+// Unlock unlocks in place the locked private keys of the keyring that
+// passphrase unlocks, e.g. after Lock, and returns an error if none is.
+func (keyRing *KeyRing) Unlock(passphrase []byte) error {
+	_, err := keyRing.UnlockWithAny([][]byte{passphrase})
+	return err
+}
+
+// UnlockWithAny unlocks in place the locked private keys of the keyring, each
+// with the first of the passphrases that unlocks it, e.g. with the current and
+// the previous passphrases after a password change. It returns, by hex
+// fingerprint of each locked key, the index of the passphrase that unlocked it
+// or -1 if none did, and an error only if no key was unlocked.
+func (keyRing *KeyRing) UnlockWithAny(passphrases [][]byte) (map[string]int, error) {
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	unlockedWith := make(map[string]int)
+	unlocked := make(map[*openpgp.Entity]*openpgp.Entity)
+	for _, entity := range keyRing.getEntities() {
+		key := &Key{entity: entity}
+		if locked, err := key.IsLocked(); err != nil || !locked {
+			continue
+		}
+		fingerprint := key.GetFingerprint()
+		unlockedWith[fingerprint] = -1
+		for j, passphrase := range passphrases {
+			unlockedKey, err := key.Unlock(passphrase)
+			if err != nil {
+				continue
+			}
+			unlocked[entity] = unlockedKey.entity
+			unlockedWith[fingerprint] = j
+			break
+		}
+	}
+	if len(unlocked) == 0 {
+		return unlockedWith, errors.New("gopenpgp: no key of the keyring unlocked with the passphrases")
+	}
+
+	keyRing.replaceEntities(unlocked)
+	state.resetTimer()
+	return unlockedWith, nil
+}
+
+// IsLocked checks if some private key of the keyring is locked.
+func (keyRing *KeyRing) IsLocked() (bool, error) {
+	return keyRing.hasLockedKey()
+}
+
+// IsUnlocked checks if all the private keys of the keyring are unlocked.
+func (keyRing *KeyRing) IsUnlocked() (bool, error) {
+	locked, err := keyRing.hasLockedKey()
+	return !locked, err
+}
+
+// SetAutoLock locks the keyring, as Lock does, once it is not used to sign
+// or decrypt for the idle duration. Zero disables auto-locking.
+// The idle duration must be longer than the operations using the keyring,
+// including the streams signing or decrypting with it.
+func (keyRing *KeyRing) SetAutoLock(idle time.Duration) {
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	if state.timer != nil {
+		state.timer.Stop()
+		state.timer = nil
+	}
+	state.idleDelay = idle
+	if idle <= 0 {
+		return
+	}
+	state.timer = time.AfterFunc(idle, func() {
+		state.lock.Lock()
+		defer state.lock.Unlock()
+
+		// The keyring is locked once the running operations are done
+		if state.users.count > 0 {
+			state.resetTimer()
+			return
+		}
+		keyRing.lockKeys(state)
+	})
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// getLockState returns the lock state of the keyring, created on first use.
+func (keyRing *KeyRing) getLockState() *keyRingLockState {
+	keyRing.lock.RLock()
+	state := keyRing.lockState
+	keyRing.lock.RUnlock()
+	if state != nil {
+		return state
+	}
+
+	keyRing.lock.Lock()
+	defer keyRing.lock.Unlock()
+
+	if keyRing.lockState == nil {
+		state := &keyRingLockState{lockedKeys: make(map[string][]byte), users: &keyRingUsers{}}
+		state.idle = sync.NewCond(&state.lock)
+		keyRing.lockState = state
+	}
+	return keyRing.lockState
+}
+
+// saveLockedKey keeps the serialization of a locked key, to lock it again.
+func (keyRing *KeyRing) saveLockedKey(key *Key) error {
+	if unlocked, err := key.IsUnlocked(); err != nil || unlocked {
+		return err
+	}
+	serialized, err := key.Serialize()
+	if err != nil {
+		return err
+	}
+
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.lockedKeys[key.GetFingerprint()] = serialized
+	return nil
+}
+
+// use records an operation using the private keys of the keyring, whose
+// keys are not zeroed by locking until the returned function is called, and
+// postpones its auto-locking. The operation must read the keys of the
+// keyring after calling use.
+func (keyRing *KeyRing) use() (done func()) {
+	state := keyRing.getLockState()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	users := state.users
+	users.count++
+	state.resetTimer()
+	return func() {
+		state.lock.Lock()
+		defer state.lock.Unlock()
+
+		users.count--
+		if users.count == 0 {
+			state.idle.Broadcast()
+		}
+	}
+}
+
+// resetTimer restarts the auto-lock timer, if any. The caller must hold the
+// lock of the state.
+func (state *keyRingLockState) resetTimer() {
+	if state.timer != nil {
+		state.timer.Reset(state.idleDelay)
+	}
+}
+
+// lockKeys replaces the unlocked private keys of the keyring by their locked
+// form, or their public part, and zeroes them once the operations that may
+// use them are done. The operations starting meanwhile get the locked keys.
+// The caller must hold the lock of the state.
+func (keyRing *KeyRing) lockKeys(state *keyRingLockState) {
+	locked := make(map[*openpgp.Entity]*openpgp.Entity)
+	for _, entity := range keyRing.getEntities() {
+		key := &Key{entity: entity}
+		if !hasUnlockedPart(key) {
+			continue
+		}
+		if serialized, ok := state.lockedKeys[key.GetFingerprint()]; ok {
+			if lockedKey, err := NewKey(serialized); err == nil {
+				locked[entity] = lockedKey.entity
+				continue
+			}
+		}
+		locked[entity] = publicEntity(entity)
+	}
+
+	keyRing.replaceEntities(locked)
+	users := state.users
+	state.users = &keyRingUsers{}
+	for users.count > 0 {
+		state.idle.Wait()
+	}
+	for entity := range locked {
+		(&Key{entity: entity}).clearPrivateWithSubkeys()
+	}
+}
+
+// replaceEntities replaces the entities of the keyring that are keys of
+// replacements by their value.
+func (keyRing *KeyRing) replaceEntities(replacements map[*openpgp.Entity]*openpgp.Entity) {
+	keyRing.updateEntities(func(entities openpgp.EntityList) openpgp.EntityList {
+		for i, entity := range entities {
+			if replacement, ok := replacements[entity]; ok {
+				entities[i] = replacement
+			}
+		}
+		return entities
+	})
+}
+
+// publicEntity returns a copy of entity without its private keys, sharing its
+// other packets.
+func publicEntity(entity *openpgp.Entity) *openpgp.Entity {
+	public := *entity
+	public.PrivateKey = nil
+	public.Subkeys = make([]openpgp.Subkey, len(entity.Subkeys))
+	for i, subkey := range entity.Subkeys {
+		subkey.PrivateKey = nil
+		public.Subkeys[i] = subkey
+	}
+	return &public
+}
+
+// hasLockedKey returns whether some private key of the keyring is locked, or
+// an error if the keyring has no private key.
+func (keyRing *KeyRing) hasLockedKey() (bool, error) {
+	privateKeys := 0
+	for _, entity := range keyRing.getEntities() {
+		key := &Key{entity: entity}
+		if !key.IsPrivate() {
+			continue
+		}
+		privateKeys++
+		if locked, _ := key.IsLocked(); locked {
+			return true, nil
+		}
+	}
+	if privateKeys == 0 {
+		return true, errors.New("gopenpgp: a public keyring cannot be locked")
+	}
+	return false, nil
+}
+
+// hasUnlockedPart returns whether the primary key or some subkey of a private
+// key is unlocked, e.g. only the subkey unlocked by
+// DecryptWithPassphraseCallback.
+func hasUnlockedPart(key *Key) bool {
+	if isUnlockedPrivateKey(key.entity.PrivateKey) {
+		return true
+	}
+	for _, subkey := range key.entity.Subkeys {
+		if isUnlockedPrivateKey(subkey.PrivateKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnlockedPrivateKey returns whether a private key packet holds decrypted
+// secret material.
+func isUnlockedPrivateKey(privateKey *packet.PrivateKey) bool {
+	return privateKey != nil && !privateKey.Dummy() && !privateKey.Encrypted
+}
@@ -0,0 +1,277 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLockedKeyRing(t *testing.T) *KeyRing {
+	lockedKey, err := NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+	keyRing, err := NewLockedKeyRing(lockedKey)
+	if err != nil {
+		t.Fatal("Cannot create locked key ring:", err)
+	}
+	return keyRing
+}
+
+func TestKeyRingLockUnlock(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("relock"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+
+	locked, err := keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+
+	assert.NotNil(t, keyRing.Unlock([]byte("wrong")))
+	assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+	unlocked, err := keyRing.IsUnlocked()
+	assert.Nil(t, err)
+	assert.True(t, unlocked)
+
+	decrypted, err := keyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot decrypt with unlocked keyring:", err)
+	}
+	assert.Exactly(t, "relock", decrypted.GetString())
+
+	rsaKey := keyRing.GetKeys()[0].entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	keyRing.Lock()
+	assertRSACleared(t, rsaKey)
+	locked, err = keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+	_, err = keyRing.Decrypt(ciphertext, nil, 0)
+	assert.NotNil(t, err)
+
+	// The key can be unlocked again, also on demand
+	decrypted, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, func(string) ([]byte, error) {
+		return testMailboxPassword, nil
+	})
+	if err != nil {
+		t.Fatal("Cannot decrypt with callback:", err)
+	}
+	assert.Exactly(t, "relock", decrypted.GetString())
+	keyRing.Lock()
+	_, err = keyRing.Decrypt(ciphertext, nil, 0)
+	assert.NotNil(t, err)
+}
+
+func TestKeyRingLockWithoutLockedForm(t *testing.T) {
+	keyRing, err := keyRingTestMultiple.Copy()
+	if err != nil {
+		t.Fatal("Expected no error while copying keyring, got:", err)
+	}
+	unlocked, err := keyRing.IsUnlocked()
+	assert.Nil(t, err)
+	assert.True(t, unlocked)
+
+	// Keys added unlocked become public
+	keyRing.Lock()
+	_, err = keyRing.IsLocked()
+	assert.NotNil(t, err)
+	assert.Exactly(t, 3, keyRing.CountEntities())
+}
+
+func TestKeyRingAutoLock(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+
+	keyRing.SetAutoLock(time.Hour)
+	keyRing.SetAutoLock(0)
+	time.Sleep(50 * time.Millisecond)
+	locked, err := keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.False(t, locked)
+
+	keyRing.SetAutoLock(100 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		// Using the keyring postpones the auto-locking
+		_, err = keyRing.SignDetached(NewPlainMessageFromString("message"))
+		assert.Nil(t, err)
+	}
+	locked, err = keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.False(t, locked)
+
+	time.Sleep(300 * time.Millisecond)
+	locked, err = keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+	_, err = keyRing.SignDetached(NewPlainMessageFromString("message"))
+	assert.NotNil(t, err)
+}
+
+func TestKeyRingUnlockWithAny(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	changedKey, err := keyTestRSA.Lock([]byte("new password"))
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	otherKey, err := keyTestEC.Lock([]byte("other password"))
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	assert.Nil(t, keyRing.AddLockedKey(changedKey))
+	assert.Nil(t, keyRing.AddLockedKey(otherKey))
+
+	_, err = keyRing.UnlockWithAny([][]byte{[]byte("wrong")})
+	assert.NotNil(t, err)
+
+	unlockedWith, err := keyRing.UnlockWithAny([][]byte{[]byte("wrong"), []byte("new password"), testMailboxPassword})
+	if err != nil {
+		t.Fatal("Cannot unlock with any passphrase:", err)
+	}
+	assert.Exactly(t, map[string]int{
+		keyRing.GetKeys()[0].GetFingerprint(): 2,
+		changedKey.GetFingerprint():           1,
+		otherKey.GetFingerprint():             -1,
+	}, unlockedWith)
+
+	keys := keyRing.GetKeys()
+	for i, expected := range []bool{true, true, false} {
+		unlocked, err := keys[i].IsUnlocked()
+		assert.Nil(t, err)
+		assert.Exactly(t, expected, unlocked)
+	}
+
+	// Only the keys still locked are reported
+	unlockedWith, err = keyRing.UnlockWithAny([][]byte{[]byte("other password")})
+	assert.Nil(t, err)
+	assert.Exactly(t, map[string]int{otherKey.GetFingerprint(): 0}, unlockedWith)
+	unlocked, err := keyRing.IsUnlocked()
+	assert.Nil(t, err)
+	assert.True(t, unlocked)
+}
+
+func TestKeyRingConcurrentUse(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+	message := NewPlainMessageFromString("shared keyring")
+	ciphertext, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// The keyring may be locked in between: only successful
+				// operations are checked
+				if decrypted, err := keyRing.Decrypt(ciphertext, nil, 0); err == nil {
+					assert.Exactly(t, "shared keyring", decrypted.GetString())
+				}
+				if signature, err := keyRing.SignDetached(message); err == nil {
+					assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+				}
+				if encrypted, err := keyRing.Encrypt(message, keyRing); err == nil {
+					assert.NotEmpty(t, encrypted.GetBinary())
+				}
+				assert.Len(t, keyRing.GetKeys(), 1)
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		keyRing.Lock()
+		assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+		UpdateTime(testTime)
+	}
+	// The keyring is auto-locked once the operations are done
+	keyRing.SetAutoLock(time.Millisecond)
+	defer keyRing.SetAutoLock(0)
+	time.Sleep(20 * time.Millisecond)
+	close(done)
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	locked, err := keyRing.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+}
+
+func TestKeyRingConcurrentVerifyKeyRings(t *testing.T) {
+	// The entity list of an unlocked keyring is replaced, which used to
+	// leave a spare slot that decryptions with different verification
+	// keyrings wrote to concurrently
+	keyRing := newTestLockedKeyRing(t)
+	assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+
+	signingKeyRing, err := NewKeyRing(keyTestRSA)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("signed"), signingKeyRing)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	verifyKeyRing, err := signingKeyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public keyring:", err)
+	}
+	otherKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	otherKeyRing, err = otherKeyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public keyring:", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(verifies bool) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if verifies {
+					_, err := keyRing.Decrypt(ciphertext, verifyKeyRing, 0)
+					assert.Nil(t, err)
+				} else {
+					_, err := keyRing.Decrypt(ciphertext, otherKeyRing, 0)
+					assert.Error(t, err)
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestKeyRingClone(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	assert.Nil(t, keyRing.Unlock(testMailboxPassword))
+
+	keyRingClone, err := keyRing.Clone()
+	if err != nil {
+		t.Fatal("Cannot clone keyring:", err)
+	}
+
+	// The clone is locked independently, and can be unlocked again
+	keyRingClone.Lock()
+	locked, err := keyRingClone.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+	unlocked, err := keyRing.IsUnlocked()
+	assert.Nil(t, err)
+	assert.True(t, unlocked)
+	assert.Nil(t, keyRingClone.Unlock(testMailboxPassword))
+}
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"crypto"
 	"io"
-	"io/ioutil"
+	"strconv"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 	"github.com/pkg/errors"
 )
 
@@ -19,12 +20,12 @@ import (
 // * privateKey : (optional) an unlocked private keyring to include signature in the message.
 func (keyRing *KeyRing) Encrypt(message *PlainMessage, privateKey *KeyRing) (*PGPMessage, error) {
 	config := &packet.Config{DefaultCipher: packet.CipherAES256, Time: getTimeGenerator()}
-	encrypted, err := asymmetricEncrypt(message, keyRing, privateKey, config)
+	encrypted, err := asymmetricEncrypt(message, keyRing, privateKey, config, true)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewPGPMessage(encrypted), nil
+	return newPGPMessage(encrypted), nil
 }
 
 // EncryptWithCompression encrypts with compression support a PlainMessage to PGPMessage using public/private keys.
@@ -39,12 +40,12 @@ func (keyRing *KeyRing) EncryptWithCompression(message *PlainMessage, privateKey
 		CompressionConfig:      &packet.CompressionConfig{Level: constants.DefaultCompressionLevel},
 	}
 
-	encrypted, err := asymmetricEncrypt(message, keyRing, privateKey, config)
+	encrypted, err := asymmetricEncrypt(message, keyRing, privateKey, config, true)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewPGPMessage(encrypted), nil
+	return newPGPMessage(encrypted), nil
 }
 
 // Decrypt decrypts encrypted string using pgp keys, returning a PlainMessage
@@ -54,37 +55,87 @@ func (keyRing *KeyRing) EncryptWithCompression(message *PlainMessage, privateKey
 //
 // When verifyKey is not provided, then verifyTime should be zero, and
 // signature verification will be ignored.
+//
+// Messages without integrity protection are rejected, unless allowed by
+// SetAllowUnprotectedMessages.
 func (keyRing *KeyRing) Decrypt(
 	message *PGPMessage, verifyKey *KeyRing, verifyTime int64,
 ) (*PlainMessage, error) {
-	return asymmetricDecrypt(message.NewReader(), keyRing, verifyKey, verifyTime)
+	return asymmetricDecrypt(
+		message, keyRing, verifyKey, verifyTime, internal.CreationTimeOffset, nil,
+	)
 }
 
 // SignDetached generates and returns a PGPSignature for a given PlainMessage.
+// Text messages get a text signature (type 0x01), binary messages a binary
+// signature (type 0x00).
 func (keyRing *KeyRing) SignDetached(message *PlainMessage) (*PGPSignature, error) {
+	defer keyRing.use()()
 	signEntity, err := keyRing.getSigningEntity()
 	if err != nil {
 		return nil, err
 	}
 
-	config := &packet.Config{DefaultHash: crypto.SHA512, Time: getTimeGenerator()}
-	var outBuf bytes.Buffer
-	// sign bin
-	if err := openpgp.DetachSign(&outBuf, signEntity, message.NewReader(), config); err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: error in signing")
+	signature, err := signDetached(signEntity, message.NewReader(), message.IsText(), getTimeGenerator())
+	if err != nil {
+		return nil, err
 	}
 
-	return NewPGPSignature(outBuf.Bytes()), nil
+	return NewPGPSignature(signature), nil
+}
+
+// SignDetachedAtTime generates and returns a PGPSignature for a given
+// PlainMessage like SignDetached, with signTime as creation time instead of
+// the latest server time, e.g. for reproducible builds or server-assigned
+// times. The signing key must be valid at signTime.
+func (keyRing *KeyRing) SignDetachedAtTime(message *PlainMessage, signTime int64) (*PGPSignature, error) {
+	timeGenerator, err := getFixedTimeGenerator(signTime)
+	if err != nil {
+		return nil, err
+	}
+
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signDetached(signEntity, message.NewReader(), message.IsText(), timeGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
 }
 
 // VerifyDetached verifies a PlainMessage with a detached PGPSignature
 // and returns a SignatureVerificationError if fails.
+// If the signature only fails because its type doesn't match the content
+// type of the message, the error describes the mismatch.
 func (keyRing *KeyRing) VerifyDetached(message *PlainMessage, signature *PGPSignature, verifyTime int64) error {
-	return verifySignature(
-		keyRing.entities,
+	entities := keyRing.getEntities()
+	err := verifySignature(
+		entities,
+		message.NewReader(),
+		signature.GetBinary(),
+		verifyTime,
+	)
+	return checkSignatureContentType(err, entities, message, signature.GetBinary(), nil)
+}
+
+// VerifyDetachedWithReport verifies a PlainMessage with a detached PGPSignature,
+// and returns a VerificationReport listing the unknown subpackets of the signature,
+// along with a SignatureVerificationError if fails.
+func (keyRing *KeyRing) VerifyDetachedWithReport(
+	message *PlainMessage, signature *PGPSignature, verifyTime int64,
+) (*VerificationReport, error) {
+	return verifySignatureWithReport(
+		keyRing.getEntities(),
 		message.NewReader(),
 		signature.GetBinary(),
 		verifyTime,
+		internal.CreationTimeOffset,
+		nil,
 	)
 }
 
@@ -143,7 +194,7 @@ func (keyRing *KeyRing) GetVerifiedSignatureTimestamp(message *PlainMessage, sig
 			continue
 		}
 		err = verifySignature(
-			keyRing.entities,
+			keyRing.getEntities(),
 			message.NewReader(),
 			outBuf.Bytes(),
 			verifyTime,
@@ -158,28 +209,108 @@ func (keyRing *KeyRing) GetVerifiedSignatureTimestamp(message *PlainMessage, sig
 
 // ------ INTERNAL FUNCTIONS -------
 
+// signDetached signs message with a text signature (type 0x01) if isText,
+// where the line endings are canonicalized, or a binary signature
+// (type 0x00) otherwise, created at the time returned by timeGenerator.
+func signDetached(signEntity *openpgp.Entity, message io.Reader, isText bool, timeGenerator func() time.Time) ([]byte, error) {
+	var sigType packet.SignatureType = packet.SigTypeBinary
+	if isText {
+		sigType = packet.SigTypeText
+	}
+	return signWithType(signEntity, sigType, message, nil, timeGenerator)
+}
+
+// signDetachedWithSubpackets signs message like signDetached, adding the
+// serialized subpackets to the hashed area of the signature.
+func signDetachedWithSubpackets(signEntity *openpgp.Entity, message io.Reader, isText bool, subpackets []byte) ([]byte, error) {
+	var sigType packet.SignatureType = packet.SigTypeBinary
+	if isText {
+		sigType = packet.SigTypeText
+	}
+	return signWithType(signEntity, sigType, message, subpackets, getTimeGenerator())
+}
+
+// signWithType signs message, or only the signature itself if message is nil,
+// with a signature of type sigType created at the time returned by
+// timeGenerator, adding the serialized subpackets to the hashed area of the
+// signature.
+func signWithType(
+	signEntity *openpgp.Entity, sigType packet.SignatureType, message io.Reader, subpackets []byte,
+	timeGenerator func() time.Time,
+) ([]byte, error) {
+	config := &packet.Config{DefaultHash: crypto.SHA512, Time: timeGenerator}
+	signingKey, ok := signingKeyAt(signEntity, config.Now(), 0)
+	if !ok {
+		return nil, errors.New("gopenpgp: no valid signing keys")
+	}
+	signer := signingKey.PrivateKey
+	if signer == nil || signer.Encrypted {
+		return nil, errors.New("gopenpgp: signing key must be unlocked")
+	}
+
+	sig := &packet.Signature{
+		Version:      signer.Version,
+		SigType:      sigType,
+		PubKeyAlgo:   signer.PubKeyAlgo,
+		Hash:         config.Hash(),
+		CreationTime: config.Now(),
+		IssuerKeyId:  &signer.KeyId,
+	}
+
+	h := sig.Hash.New()
+	if message != nil {
+		wrappedHash := h
+		if sigType == packet.SigTypeText {
+			wrappedHash = openpgp.NewCanonicalTextHash(h)
+		}
+		if _, err := copyPooled(wrappedHash, message); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in reading message")
+		}
+	}
+
+	if err := signWithSubpackets(sig, h, signer, config, subpackets); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in signing")
+	}
+
+	var outBuf bytes.Buffer
+	if err := sig.Serialize(&outBuf); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing signature")
+	}
+	return outBuf.Bytes(), nil
+}
+
 // Core for encryption+signature (non-streaming) functions.
+// The fingerprints of the recipients are added to the signature if
+// listRecipients is true.
 func asymmetricEncrypt(
 	plainMessage *PlainMessage,
 	publicKey, privateKey *KeyRing,
 	config *packet.Config,
+	listRecipients bool,
 ) ([]byte, error) {
 	var outBuf bytes.Buffer
 	var encryptWriter io.WriteCloser
 	var err error
 
+	// The message is signed when the writer is closed
+	if privateKey != nil {
+		defer privateKey.use()()
+	}
+
+	outBuf.Grow(encryptedSizeEstimate(plainMessage, len(publicKey.getEntities())))
+
 	hints := &openpgp.FileHints{
 		IsBinary: plainMessage.IsBinary(),
 		FileName: plainMessage.Filename,
 		ModTime:  plainMessage.getFormattedTime(),
 	}
 
-	encryptWriter, err = asymmetricEncryptStream(hints, &outBuf, &outBuf, publicKey, privateKey, config)
+	encryptWriter, err = asymmetricEncryptStream(hints, &outBuf, &outBuf, publicKey, privateKey, config, listRecipients)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = encryptWriter.Write(plainMessage.GetBinary())
+	_, err = writeChunked(encryptWriter, plainMessage.GetBinary())
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in writing to message")
 	}
@@ -193,82 +324,198 @@ func asymmetricEncrypt(
 }
 
 // Core for encryption+signature (all) functions.
+// The fingerprints of the recipients are added to the signature if
+// listRecipients is true.
 func asymmetricEncryptStream(
 	hints *openpgp.FileHints,
 	keyPacketWriter io.Writer,
 	dataPacketWriter io.Writer,
 	publicKey, privateKey *KeyRing,
 	config *packet.Config,
+	listRecipients bool,
 ) (encryptWriter io.WriteCloser, err error) {
 	var signEntity *openpgp.Entity
 
-	if privateKey != nil && len(privateKey.entities) > 0 {
+	if privateKey != nil && len(privateKey.getEntities()) > 0 {
 		var err error
+		defer privateKey.use()()
 		signEntity, err = privateKey.getSigningEntity()
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if hints.IsBinary {
-		encryptWriter, err = openpgp.EncryptSplit(keyPacketWriter, dataPacketWriter, publicKey.entities, signEntity, hints, config)
-	} else {
-		encryptWriter, err = openpgp.EncryptTextSplit(keyPacketWriter, dataPacketWriter, publicKey.entities, signEntity, hints, config)
+	recipients, err := publicKey.getEncryptionEntities(config.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var subpackets []byte
+	if listRecipients {
+		subpackets = intendedRecipientSubpackets(recipients)
 	}
+	encryptWriter, err = encryptToRecipients(
+		keyPacketWriter, dataPacketWriter, recipients, signEntity, hints, config, subpackets,
+	)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in encrypting asymmetrically")
 	}
 	return encryptWriter, nil
 }
 
-// Core for decryption+verification (non streaming) functions.
+// encryptToRecipients encrypts and signs with signEntity, if not nil, like
+// openpgp.EncryptSplit and openpgp.EncryptTextSplit, adding the serialized
+// subpackets to the signature. The message is encrypted with a session key,
+// for the encryption keys of the recipients selected with the clock skew
+// tolerance, which go-crypto doesn't apply.
+func encryptToRecipients(
+	keyPacketWriter, dataPacketWriter io.Writer,
+	to openpgp.EntityList, signEntity *openpgp.Entity,
+	hints *openpgp.FileHints, config *packet.Config, subpackets []byte,
+) (io.WriteCloser, error) {
+	if len(to) == 0 {
+		return nil, errors.New("gopenpgp: no encryption recipient provided")
+	}
+
+	cipher := recipientsCipher(to, config.Cipher())
+	algo, err := getAlgo(cipher)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := GenerateSessionKeyAlgo(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recipient := range to {
+		encryptionKey, ok := encryptionKeyAt(recipient, config.Now())
+		if !ok {
+			return nil, errors.New(
+				"gopenpgp: cannot encrypt a message to key id " +
+					strconv.FormatUint(recipient.PrimaryKey.KeyId, 16) + " because it has no valid encryption keys",
+			)
+		}
+		if err := packet.SerializeEncryptedKey(keyPacketWriter, encryptionKey.PublicKey, cipher, sk.Key, config); err != nil {
+			return nil, err
+		}
+	}
+
+	var modTime uint32
+	if !hints.ModTime.IsZero() {
+		modTime = uint32(hints.ModTime.Unix())
+	}
+	sessionConfig := *config
+	sessionConfig.DefaultCipher = cipher
+	encryptWriter, signWriter, err := encryptStreamWithSessionKey(
+		hints.IsBinary,
+		hints.FileName,
+		modTime,
+		dataPacketWriter,
+		sk,
+		signEntity,
+		&sessionConfig,
+		subpackets,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if signWriter == nil {
+		return encryptWriter, nil
+	}
+	return &signAndEncryptWriteCloser{signWriter, encryptWriter}, nil
+}
+
+// recipientsCipher returns preferred if all the recipients support it, and
+// AES-128, which recipients without preferences are assumed to support,
+// otherwise.
+func recipientsCipher(to openpgp.EntityList, preferred packet.CipherFunction) packet.CipherFunction {
+	for _, recipient := range to {
+		supported := false
+		for _, cipher := range recipient.PrimaryIdentity().SelfSignature.PreferredSymmetric {
+			if packet.CipherFunction(cipher) == preferred {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return packet.CipherAES128
+		}
+	}
+	return preferred
+}
+
+// Core for decryption+verification (non streaming) functions, accepting
+// signatures created up to creationTimeOffset seconds after verifyTime.
+// The locked private keys are unlocked with prompt, if not nil.
 func asymmetricDecrypt(
-	encryptedIO io.Reader, privateKey *KeyRing, verifyKey *KeyRing, verifyTime int64,
+	encrypted *PGPMessage, privateKey *KeyRing, verifyKey *KeyRing, verifyTime, creationTimeOffset int64,
+	prompt openpgp.PromptFunction,
 ) (message *PlainMessage, err error) {
-	messageDetails, err := asymmetricDecryptStream(
-		encryptedIO,
+	// The plaintext is usually smaller than the message
+	sizeHint := len(encrypted.Data)
+	messageDetails, unprotected, err := asymmetricDecryptStream(
+		encrypted.NewReader(),
 		privateKey,
 		verifyKey,
 		verifyTime,
+		prompt,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := ioutil.ReadAll(messageDetails.UnverifiedBody)
+	body, err := readAllWithSizeHint(messageDetails.UnverifiedBody, sizeHint)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in reading message body")
 	}
 
-	if verifyKey != nil {
-		processSignatureExpiration(messageDetails, verifyTime)
-		err = verifyDetailsSignature(messageDetails, verifyKey)
-	}
-
-	return &PlainMessage{
+	plainMessage := &PlainMessage{
 		Data:     body,
 		TextType: !messageDetails.LiteralData.IsBinary,
 		Filename: messageDetails.LiteralData.FileName,
 		Time:     messageDetails.LiteralData.Time,
-	}, err
+
+		unprotected: unprotected,
+	}
+	if verifyKey != nil {
+		processSignatureExpiration(messageDetails, verifyTime, creationTimeOffset)
+		err = verifyDetailsSignature(messageDetails, verifyKey)
+		if err == nil {
+			plainMessage.setVerifiedSignature(messageDetails)
+		}
+		if err != nil && messageDetails.IsSigned && messageDetails.Signature == nil {
+			if droppedErr := findDroppedSignatureErrorInMessage(encrypted, privateKey); droppedErr != nil {
+				err = droppedErr
+			}
+		}
+	}
+
+	return plainMessage, err
 }
 
-// Core for decryption+verification (all) functions.
+// Core for decryption+verification (all) functions. It returns whether the
+// message was a Symmetrically Encrypted packet without integrity protection,
+// only decrypted if allowed.
 func asymmetricDecryptStream(
 	encryptedIO io.Reader,
 	privateKey *KeyRing,
 	verifyKey *KeyRing,
 	verifyTime int64,
-) (messageDetails *openpgp.MessageDetails, err error) {
-	privKeyEntries := privateKey.entities
+	prompt openpgp.PromptFunction,
+) (messageDetails *openpgp.MessageDetails, unprotected bool, err error) {
+	defer privateKey.use()()
+	privKeyEntries := privateKey.getEntities()
 	var additionalEntries openpgp.EntityList
 
 	if verifyKey != nil {
-		additionalEntries = verifyKey.entities
+		additionalEntries = verifyKey.getEntities()
 	}
 
 	if additionalEntries != nil {
-		privKeyEntries = append(privKeyEntries, additionalEntries...)
+		// The lists of the keyrings are shared, and must not be appended to
+		entries := make(openpgp.EntityList, 0, len(privKeyEntries)+len(additionalEntries))
+		entries = append(entries, privKeyEntries...)
+		privKeyEntries = append(entries, additionalEntries...)
 	}
 
 	config := &packet.Config{
@@ -285,9 +532,26 @@ func asymmetricDecryptStream(
 		},
 	}
 
-	messageDetails, err = openpgp.ReadMessage(encryptedIO, privKeyEntries, nil, config)
+	keyPackets, dataPacketTag, dataPacketReader, err := readKeyPackets(encryptedIO)
+	if err != nil {
+		return nil, false, err
+	}
+	if dataPacketTag == packetTagSymmetricallyEncrypted {
+		if !allowUnprotectedMessages() {
+			return nil, false, errUnprotectedMessage
+		}
+		var sessionKey *SessionKey
+		if sessionKey, err = privateKey.DecryptSessionKey(keyPackets); err != nil {
+			return nil, false, err
+		}
+		return decryptStreamWithSessionKey(sessionKey, dataPacketReader, verifyKey)
+	}
+
+	input := &endReader{reader: io.MultiReader(bytes.NewReader(keyPackets), dataPacketReader)}
+	messageDetails, err = openpgp.ReadMessage(input, privKeyEntries, prompt, config)
 	if err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: error in reading message")
+		return nil, false, errors.Wrap(err, "gopenpgp: error in reading message")
 	}
-	return messageDetails, err
+	messageDetails.UnverifiedBody = &modificationCheckReader{body: messageDetails.UnverifiedBody, input: input}
+	return messageDetails, false, nil
 }
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DecryptionResult is the result of the decryption of one of the messages
+// given to DecryptParallel or DecryptAttachmentsParallel.
+type DecryptionResult struct {
+	Message *PlainMessage
+	// Err is the decryption error, or the signature verification error, if
+	// any: in the latter case, Message is still set.
+	Err error
+}
+
+// EncryptionResult is the result of the encryption of one of the messages
+// given to EncryptParallel or EncryptAll.
+type EncryptionResult struct {
+	Message *PGPMessage
+	Err     error
+}
+
+// EncryptParallel encrypts messages to the keys of keyRing concurrently, with
+// at most workers goroutines, or one per CPU if workers isn't positive, and
+// signs them with signKeyRing, if not nil.
+// The results are returned in the order of the messages.
+func (keyRing *KeyRing) EncryptParallel(
+	messages []*PlainMessage, signKeyRing *KeyRing, workers int,
+) []*EncryptionResult {
+	results := make([]*EncryptionResult, len(messages))
+	runParallel(len(messages), workers, func(i int) {
+		message, err := keyRing.Encrypt(messages[i], signKeyRing)
+		results[i] = &EncryptionResult{Message: message, Err: err}
+	})
+	return results
+}
+
+// EncryptAll encrypts the messages received from messages as EncryptParallel,
+// until messages is closed, e.g. to re-encrypt a mailbox without holding it
+// in memory. The results are sent in the order of the messages to the
+// returned channel, which is closed after the last one. The results must be
+// received until the channel is closed.
+func (keyRing *KeyRing) EncryptAll(
+	messages <-chan *PlainMessage, signKeyRing *KeyRing, workers int,
+) <-chan *EncryptionResult {
+	results := make(chan *EncryptionResult)
+	go func() {
+		defer close(results)
+		runOrdered(
+			workers,
+			func() (func() interface{}, bool) {
+				message, ok := <-messages
+				if !ok {
+					return nil, false
+				}
+				return func() interface{} {
+					encrypted, err := keyRing.Encrypt(message, signKeyRing)
+					return &EncryptionResult{Message: encrypted, Err: err}
+				}, true
+			},
+			func(result interface{}) {
+				results <- result.(*EncryptionResult)
+			},
+		)
+	}()
+	return results
+}
+
+// DecryptAll decrypts the messages received from messages as DecryptParallel,
+// until messages is closed. The results are sent in the order of the messages
+// to the returned channel, which is closed after the last one. The results
+// must be received until the channel is closed.
+func (keyRing *KeyRing) DecryptAll(
+	messages <-chan *PGPMessage, verifyKey *KeyRing, verifyTime int64, workers int,
+) <-chan *DecryptionResult {
+	results := make(chan *DecryptionResult)
+	go func() {
+		defer close(results)
+		runOrdered(
+			workers,
+			func() (func() interface{}, bool) {
+				message, ok := <-messages
+				if !ok {
+					return nil, false
+				}
+				return func() interface{} {
+					decrypted, err := keyRing.Decrypt(message, verifyKey, verifyTime)
+					return &DecryptionResult{Message: decrypted, Err: err}
+				}, true
+			},
+			func(result interface{}) {
+				results <- result.(*DecryptionResult)
+			},
+		)
+	}()
+	return results
+}
+
+// DecryptParallel decrypts messages concurrently, with at most workers
+// goroutines, or one per CPU if workers isn't positive, and verifies their
+// embedded signatures with verifyKey, if not nil.
+// The results are returned in the order of the messages. The keyring must be
+// unlocked, so that it is only read by the workers.
+func (keyRing *KeyRing) DecryptParallel(
+	messages []*PGPMessage, verifyKey *KeyRing, verifyTime int64, workers int,
+) []*DecryptionResult {
+	results := make([]*DecryptionResult, len(messages))
+	runParallel(len(messages), workers, func(i int) {
+		message, err := keyRing.Decrypt(messages[i], verifyKey, verifyTime)
+		results[i] = &DecryptionResult{Message: message, Err: err}
+	})
+	return results
+}
+
+// DecryptAttachmentsParallel decrypts attachments concurrently, as
+// DecryptParallel.
+func (keyRing *KeyRing) DecryptAttachmentsParallel(attachments []*PGPSplitMessage, workers int) []*DecryptionResult {
+	results := make([]*DecryptionResult, len(attachments))
+	runParallel(len(attachments), workers, func(i int) {
+		message, err := keyRing.DecryptAttachment(attachments[i])
+		results[i] = &DecryptionResult{Message: message, Err: err}
+	})
+	return results
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// runParallel calls job with the indices from 0 to count-1, with at most
+// workers goroutines, and waits for the jobs to finish.
+func runParallel(count, workers int, job func(int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > count {
+		workers = count
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				job(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// runOrdered runs the jobs returned by next, until it returns false, with at
+// most workers goroutines, or one per CPU if workers isn't positive, and
+// passes their results to emit in the order of the jobs.
+func runOrdered(workers int, next func() (func() interface{}, bool), emit func(interface{})) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// The results of the running jobs, in order: a job only starts once its
+	// result has a place in the queue, so at most workers jobs run at once.
+	pending := make(chan chan interface{}, workers-1)
+	go func() {
+		defer close(pending)
+		for {
+			job, ok := next()
+			if !ok {
+				return
+			}
+			result := make(chan interface{}, 1)
+			pending <- result
+			go func() {
+				result <- job()
+			}()
+		}
+	}()
+	for result := range pending {
+		emit(<-result)
+	}
+}
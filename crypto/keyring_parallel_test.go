@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptParallel(t *testing.T) {
+	var messages []*PGPMessage
+	var attachments []*PGPSplitMessage
+	for i := 0; i < 10; i++ {
+		message, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("message "+strconv.Itoa(i)), keyRingTestPrivate)
+		if err != nil {
+			t.Fatal("Cannot encrypt message:", err)
+		}
+		messages = append(messages, message)
+
+		attachment, err := keyRingTestPublic.EncryptAttachment(NewPlainMessage([]byte{byte(i)}), "file")
+		if err != nil {
+			t.Fatal("Cannot encrypt attachment:", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	messages = append(messages, NewPGPMessage([]byte("invalid")))
+
+	results := keyRingTestPrivate.DecryptParallel(messages, keyRingTestPublic, GetUnixTime(), 3)
+	if assert.Len(t, results, 11) {
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, results[i].Err)
+			assert.Exactly(t, "message "+strconv.Itoa(i), results[i].Message.GetString())
+		}
+		assert.NotNil(t, results[10].Err)
+	}
+
+	results = keyRingTestPrivate.DecryptAttachmentsParallel(attachments, 0)
+	if assert.Len(t, results, 10) {
+		for i, result := range results {
+			assert.Nil(t, result.Err)
+			assert.Exactly(t, []byte{byte(i)}, result.Message.GetBinary())
+		}
+	}
+
+	assert.Empty(t, keyRingTestPrivate.DecryptAttachmentsParallel(nil, 2))
+}
+
+func TestEncryptParallel(t *testing.T) {
+	var messages []*PlainMessage
+	for i := 0; i < 10; i++ {
+		messages = append(messages, NewPlainMessageFromString("message "+strconv.Itoa(i)))
+	}
+	results := keyRingTestPublic.EncryptParallel(messages, keyRingTestPrivate, 3)
+	if assert.Len(t, results, 10) {
+		for i, result := range results {
+			assert.Nil(t, result.Err)
+			decrypted, err := keyRingTestPrivate.Decrypt(result.Message, keyRingTestPublic, GetUnixTime())
+			assert.Nil(t, err)
+			assert.Exactly(t, "message "+strconv.Itoa(i), decrypted.GetString())
+		}
+	}
+}
+
+func TestEncryptAndDecryptAll(t *testing.T) {
+	plaintexts := make(chan *PlainMessage)
+	go func() {
+		defer close(plaintexts)
+		for i := 0; i < 20; i++ {
+			plaintexts <- NewPlainMessageFromString("message " + strconv.Itoa(i))
+		}
+	}()
+
+	ciphertexts := make(chan *PGPMessage)
+	go func() {
+		defer close(ciphertexts)
+		for result := range keyRingTestPublic.EncryptAll(plaintexts, nil, 4) {
+			if result.Err != nil {
+				ciphertexts <- NewPGPMessage([]byte("invalid"))
+				continue
+			}
+			ciphertexts <- result.Message
+		}
+		ciphertexts <- NewPGPMessage([]byte("invalid"))
+	}()
+
+	var results []*DecryptionResult
+	for result := range keyRingTestPrivate.DecryptAll(ciphertexts, nil, 0, 0) {
+		results = append(results, result)
+	}
+	if assert.Len(t, results, 21) {
+		for i := 0; i < 20; i++ {
+			assert.Nil(t, results[i].Err)
+			assert.Exactly(t, "message "+strconv.Itoa(i), results[i].Message.GetString())
+		}
+		assert.NotNil(t, results[20].Err)
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	var running, maxRunning int32
+	next := 0
+	var emitted []int
+	runOrdered(
+		3,
+		func() (func() interface{}, bool) {
+			if next == 30 {
+				return nil, false
+			}
+			i := next
+			next++
+			return func() interface{} {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+				// Later jobs finish first
+				time.Sleep(time.Duration(30-i) * 100 * time.Microsecond)
+				atomic.AddInt32(&running, -1)
+				return i
+			}, true
+		},
+		func(result interface{}) {
+			emitted = append(emitted, result.(int))
+		},
+	)
+
+	if assert.Len(t, emitted, 30) {
+		for i, result := range emitted {
+			assert.Exactly(t, i, result)
+		}
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(3))
+	assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1))
+}
@@ -7,10 +7,14 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 )
 
 // DecryptSessionKey returns the decrypted session key from one or multiple binary encrypted session key packets.
 func (keyRing *KeyRing) DecryptSessionKey(keyPacket []byte) (*SessionKey, error) {
+	defer keyRing.use()()
 	var p packet.Packet
 	var ek *packet.EncryptedKey
 
@@ -32,7 +36,7 @@ Loop:
 			hasPacket = true
 			ek = p
 
-			for _, key := range keyRing.entities.DecryptionKeys() {
+			for _, key := range keyRing.getEntities().DecryptionKeys() {
 				priv := key.PrivateKey
 				if priv.Encrypted {
 					continue
@@ -59,11 +63,15 @@ Loop:
 	}
 
 	if decryptErr != nil {
-		return nil, errors.Wrap(decryptErr, "gopenpgp: error in decrypting")
+		return nil, internal.WithErrorCode(
+			constants.ErrorCodeNoDecryptionKey, errors.Wrap(decryptErr, "gopenpgp: error in decrypting"),
+		)
 	}
 
 	if ek == nil || ek.Key == nil {
-		return nil, errors.New("gopenpgp: unable to decrypt session key: no valid decryption key")
+		return nil, internal.WithErrorCode(
+			constants.ErrorCodeNoDecryptionKey, errors.New("gopenpgp: unable to decrypt session key: no valid decryption key"),
+		)
 	}
 
 	return newSessionKeyFromEncrypted(ek)
@@ -78,9 +86,13 @@ func (keyRing *KeyRing) EncryptSessionKey(sk *SessionKey) ([]byte, error) {
 		return nil, errors.Wrap(err, "gopenpgp: unable to encrypt session key")
 	}
 
-	pubKeys := make([]*packet.PublicKey, 0, len(keyRing.entities))
-	for _, e := range keyRing.entities {
-		encryptionKey, ok := e.EncryptionKey(getNow())
+	entities, err := keyRing.getEncryptionEntities(getNow())
+	if err != nil {
+		return nil, err
+	}
+	pubKeys := make([]*packet.PublicKey, 0, len(entities))
+	for _, e := range entities {
+		encryptionKey, ok := encryptionKeyAt(e, getNow())
 		if !ok {
 			return nil, errors.New("gopenpgp: encryption key is unavailable for key id " + strconv.FormatUint(e.PrimaryKey.KeyId, 16))
 		}
@@ -2,13 +2,14 @@ package crypto
 
 import (
 	"bytes"
-	"crypto"
 	"io"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 )
 
 type Reader interface {
@@ -59,7 +60,7 @@ func (keyRing *KeyRing) EncryptStream(
 		ModTime:  time.Unix(plainMessageMetadata.ModTime, 0),
 	}
 
-	plainMessageWriter, err = asymmetricEncryptStream(hints, pgpMessageWriter, pgpMessageWriter, keyRing, signKeyRing, config)
+	plainMessageWriter, err = asymmetricEncryptStream(hints, pgpMessageWriter, pgpMessageWriter, keyRing, signKeyRing, config, true)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +126,7 @@ func (keyRing *KeyRing) EncryptSplitStream(
 	}
 
 	var keyPacketBuf bytes.Buffer
-	plainMessageWriter, err := asymmetricEncryptStream(hints, &keyPacketBuf, dataPacketWriter, keyRing, signKeyRing, config)
+	plainMessageWriter, err := asymmetricEncryptStream(hints, &keyPacketBuf, dataPacketWriter, keyRing, signKeyRing, config, true)
 	if err != nil {
 		return nil, err
 	}
@@ -142,6 +143,8 @@ type PlainMessageReader struct {
 	verifyKeyRing *KeyRing
 	verifyTime    int64
 	readAll       bool
+	unprotected   bool
+	released      int64
 }
 
 // GetMetadata returns the metadata of the decrypted message.
@@ -153,13 +156,29 @@ func (msg *PlainMessageReader) GetMetadata() *PlainMessageMetadata {
 	}
 }
 
+// IsIntegrityProtected returns false if the message was decrypted from a
+// Symmetrically Encrypted packet without integrity protection, as allowed by
+// SetAllowUnprotectedMessages. Its plaintext may then have been modified.
+func (msg *PlainMessageReader) IsIntegrityProtected() bool {
+	return !msg.unprotected
+}
+
 // Read is used to access the message decrypted data.
 // Makes PlainMessageReader implement the Reader interface.
+// If the integrity check of the message fails, it returns a
+// ModificationDetectionError with the number of bytes read so far, which
+// must be discarded.
 func (msg *PlainMessageReader) Read(b []byte) (n int, err error) {
 	n, err = msg.details.UnverifiedBody.Read(b)
+	msg.released += int64(n)
 	if errors.Is(err, io.EOF) {
 		msg.readAll = true
 	}
+	var mdcErr ModificationDetectionError
+	if errors.As(err, &mdcErr) {
+		mdcErr.ReleasedBytes = msg.released
+		err = mdcErr
+	}
 	return
 }
 
@@ -172,7 +191,7 @@ func (msg *PlainMessageReader) VerifySignature() (err error) {
 		return errors.New("gopenpgp: can't verify the signature until the message reader has been read entirely")
 	}
 	if msg.verifyKeyRing != nil {
-		processSignatureExpiration(msg.details, msg.verifyTime)
+		processSignatureExpiration(msg.details, msg.verifyTime, internal.CreationTimeOffset)
 		err = verifyDetailsSignature(msg.details, msg.verifyKeyRing)
 	} else {
 		err = errors.New("gopenpgp: no verify keyring was provided before decryption")
@@ -190,21 +209,22 @@ func (keyRing *KeyRing) DecryptStream(
 	verifyKeyRing *KeyRing,
 	verifyTime int64,
 ) (plainMessage *PlainMessageReader, err error) {
-	messageDetails, err := asymmetricDecryptStream(
+	messageDetails, unprotected, err := asymmetricDecryptStream(
 		message,
 		keyRing,
 		verifyKeyRing,
 		verifyTime,
+		nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	return &PlainMessageReader{
-		messageDetails,
-		verifyKeyRing,
-		verifyTime,
-		false,
+		details:       messageDetails,
+		verifyKeyRing: verifyKeyRing,
+		verifyTime:    verifyTime,
+		unprotected:   unprotected,
 	}, err
 }
 
@@ -229,21 +249,41 @@ func (keyRing *KeyRing) DecryptSplitStream(
 	)
 }
 
-// SignDetachedStream generates and returns a PGPSignature for a given message Reader.
+// SignDetachedStream generates and returns a binary PGPSignature for a given message Reader.
 func (keyRing *KeyRing) SignDetachedStream(message Reader) (*PGPSignature, error) {
+	return keyRing.signDetachedStream(message, false, getTimeGenerator())
+}
+
+// SignDetachedStreamAtTime generates and returns a binary PGPSignature for a
+// given message Reader like SignDetachedStream, with signTime as creation time
+// instead of the latest server time.
+func (keyRing *KeyRing) SignDetachedStreamAtTime(message Reader, signTime int64) (*PGPSignature, error) {
+	timeGenerator, err := getFixedTimeGenerator(signTime)
+	if err != nil {
+		return nil, err
+	}
+	return keyRing.signDetachedStream(message, false, timeGenerator)
+}
+
+// SignDetachedTextStream generates and returns a text PGPSignature for a given
+// message Reader, where the line endings are canonicalized.
+func (keyRing *KeyRing) SignDetachedTextStream(message Reader) (*PGPSignature, error) {
+	return keyRing.signDetachedStream(message, true, getTimeGenerator())
+}
+
+func (keyRing *KeyRing) signDetachedStream(message Reader, isText bool, timeGenerator func() time.Time) (*PGPSignature, error) {
+	defer keyRing.use()()
 	signEntity, err := keyRing.getSigningEntity()
 	if err != nil {
 		return nil, err
 	}
 
-	config := &packet.Config{DefaultHash: crypto.SHA512, Time: getTimeGenerator()}
-	var outBuf bytes.Buffer
-	// sign bin
-	if err := openpgp.DetachSign(&outBuf, signEntity, message, config); err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: error in signing")
+	signature, err := signDetached(signEntity, message, isText, timeGenerator)
+	if err != nil {
+		return nil, err
 	}
 
-	return NewPGPSignature(outBuf.Bytes()), nil
+	return NewPGPSignature(signature), nil
 }
 
 // VerifyDetachedStream verifies a message reader with a detached PGPSignature
@@ -254,7 +294,7 @@ func (keyRing *KeyRing) VerifyDetachedStream(
 	verifyTime int64,
 ) error {
 	return verifySignature(
-		keyRing.entities,
+		keyRing.getEntities(),
 		message,
 		signature.GetBinary(),
 		verifyTime,
@@ -1,15 +1,20 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rsa"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
 	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 
+	"github.com/ProtonMail/gopenpgp/v2/armor"
 	"github.com/ProtonMail/gopenpgp/v2/constants"
 )
 
@@ -95,6 +100,47 @@ func TestIdentities(t *testing.T) {
 	assert.Exactly(t, identities[0], testIdentity)
 }
 
+func TestPrimaryIdentity(t *testing.T) {
+	primaryIdentity := &Identity{Name: keyTestName, Email: keyTestDomain}
+	otherIdentity := &Identity{Name: "Erika Mustermann", Email: "erika.mustermann@proton.me"}
+	lastIdentity := &Identity{Name: "Zoe Mustermann", Email: "zoe.mustermann@proton.me"}
+
+	key, err := keyTestEC.AddUserID(lastIdentity.Name, "", lastIdentity.Email)
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	// The newer self-signatures of the added user IDs are not marked as primary
+	pgp.latestServerTime = testTime + 1
+	defer func() { pgp.latestServerTime = testTime }()
+	key, err = key.AddUserID(otherIdentity.Name, "", otherIdentity.Email)
+	if err != nil {
+		t.Fatal("Cannot add user ID:", err)
+	}
+	assert.Exactly(t, primaryIdentity, key.GetPrimaryIdentity())
+	assert.Exactly(t, []*Identity{primaryIdentity, otherIdentity, lastIdentity}, key.GetIdentities())
+
+	keyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Expected no error while creating keyring, got:", err)
+	}
+	if err = keyRing.AddKey(keyTestRSA); err != nil {
+		t.Fatal("Expected no error while adding key, got:", err)
+	}
+	assert.Exactly(t, primaryIdentity, keyRing.GetPrimaryIdentity())
+	assert.Exactly(t, []*Identity{primaryIdentity, otherIdentity, lastIdentity, primaryIdentity}, keyRing.GetIdentities())
+
+	// Revoked identities are never primary, then the most recent self-signature
+	// is preferred
+	revokedKey, err := key.RevokeUserID(keyTestName+" <"+keyTestDomain+">", "")
+	if err != nil {
+		t.Fatal("Cannot revoke user ID:", err)
+	}
+	assert.Exactly(t, otherIdentity, revokedKey.GetPrimaryIdentity())
+	assert.Exactly(t, []*Identity{otherIdentity, primaryIdentity, lastIdentity}, revokedKey.GetIdentities())
+
+	assert.Nil(t, (&KeyRing{}).GetPrimaryIdentity())
+}
+
 func TestFilterExpiredKeys(t *testing.T) {
 	expiredKey, err := NewKeyFromArmored(readTestFile("key_expiredKey", false))
 	if err != nil {
@@ -117,6 +163,64 @@ func TestFilterExpiredKeys(t *testing.T) {
 	assert.Exactly(t, unexpired[0].GetKeyIDs(), keyRingTestPrivate.GetKeyIDs())
 }
 
+func TestKeyRingFilters(t *testing.T) {
+	pgp.latestServerTime = 1632219895
+	defer func() {
+		pgp.latestServerTime = testTime
+	}()
+
+	expiredKey, err := NewKeyFromArmored(readTestFile("key_expiredKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor expired key:", err)
+	}
+	revokedKey, err := NewKeyFromArmored(readTestFile("key_revoked", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor revoked key:", err)
+	}
+	signOnlyKey, err := keyTestEC.RevokeSubkey(
+		keyIDToHex(keyTestEC.entity.Subkeys[0].PublicKey.KeyId),
+		constants.RevocationKeyRetired,
+		"",
+	)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+
+	keyRing, err := NewKeyRing(keyTestRSA)
+	if err != nil {
+		t.Fatal("Cannot create keyring:", err)
+	}
+	for _, key := range []*Key{expiredKey, revokedKey, signOnlyKey} {
+		if err = keyRing.AddKey(key); err != nil {
+			t.Fatal("Cannot add key:", err)
+		}
+	}
+
+	assertKeyIDs := func(filtered *KeyRing, err error, keys ...*Key) {
+		if err != nil {
+			t.Fatal("Expected no error while filtering keyring, got:", err)
+		}
+		var keyIDs []uint64
+		for _, key := range keys {
+			keyIDs = append(keyIDs, key.GetKeyID())
+		}
+		assert.Exactly(t, keyIDs, filtered.GetKeyIDs())
+	}
+
+	filtered, err := keyRing.FilterExpired()
+	assertKeyIDs(filtered, err, keyTestRSA, revokedKey, signOnlyKey)
+
+	filtered, err = keyRing.FilterRevoked()
+	assertKeyIDs(filtered, err, keyTestRSA, expiredKey, signOnlyKey)
+
+	filtered, err = keyRing.FilterEncryptionCapable()
+	assertKeyIDs(filtered, err, keyTestRSA)
+
+	filtered, err = keyRing.FilterSigningCapable()
+	assertKeyIDs(filtered, err, keyTestRSA, signOnlyKey)
+	assert.Exactly(t, 4, keyRing.CountEntities())
+}
+
 func TestKeyIds(t *testing.T) {
 	keyIDs := keyRingTestPrivate.GetKeyIDs()
 	var assertKeyIDs = []uint64{4518840640391470884}
@@ -201,6 +305,254 @@ func TestClearPrivateParams(t *testing.T) {
 	}
 }
 
+func TestKeyRingWipe(t *testing.T) {
+	keyRingCopy, err := keyRingTestMultiple.Copy()
+	if err != nil {
+		t.Fatal("Expected no error while copying keyring, got:", err)
+	}
+	rsaKey := keyRingCopy.GetKeys()[2].entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)
+
+	keyRingCopy.Wipe()
+	assert.Exactly(t, 0, keyRingCopy.CountEntities())
+	assertRSACleared(t, rsaKey)
+	_, err = keyRingCopy.SignDetached(NewPlainMessageFromString("message"))
+	assert.NotNil(t, err)
+}
+
+func TestKeyRingToPublic(t *testing.T) {
+	publicKeyRing, err := keyRingTestMultiple.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while converting keyring to public, got:", err)
+	}
+
+	privateKeys := keyRingTestMultiple.GetKeys()
+	publicKeys := publicKeyRing.GetKeys()
+	assert.Len(t, publicKeys, len(privateKeys))
+	for i, key := range publicKeys {
+		assert.False(t, key.IsPrivate())
+		assert.True(t, privateKeys[i].IsPrivate())
+		assert.Exactly(t, privateKeys[i].GetFingerprint(), key.GetFingerprint())
+	}
+
+	publicCopy, err := publicKeyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while converting public keyring to public, got:", err)
+	}
+	assert.Exactly(t, publicKeyRing.CountEntities(), publicCopy.CountEntities())
+}
+
+func TestKeyRingSerialize(t *testing.T) {
+	serialized, err := keyRingTestMultiple.Serialize()
+	if err != nil {
+		t.Fatal("Expected no error while serializing keyring, got:", err)
+	}
+
+	keyRing, err := NewKeyRingFromReader(bytes.NewReader(serialized))
+	if err != nil {
+		t.Fatal("Expected no error while reading serialized keyring, got:", err)
+	}
+	privateKeys := keyRingTestMultiple.GetKeys()
+	keys := keyRing.GetKeys()
+	assert.Len(t, keys, len(privateKeys))
+	for i, key := range keys {
+		assert.True(t, key.IsPrivate())
+		assert.Exactly(t, privateKeys[i].GetFingerprint(), key.GetFingerprint())
+	}
+
+	signature, err := keyRing.SignDetached(NewPlainMessageFromString("serialized"))
+	if err != nil {
+		t.Fatal("Expected no error while signing with serialized keyring, got:", err)
+	}
+	assert.Nil(t, keyRingTestMultiple.VerifyDetached(NewPlainMessageFromString("serialized"), signature, GetUnixTime()))
+
+	publicKeys, err := keyRingTestMultiple.GetPublicKeys()
+	if err != nil {
+		t.Fatal("Expected no error while serializing public keys, got:", err)
+	}
+	publicKeyRing, err := NewKeyRingFromReader(bytes.NewReader(publicKeys))
+	if err != nil {
+		t.Fatal("Expected no error while reading public keys, got:", err)
+	}
+	assert.Exactly(t, keyRingTestMultiple.CountEntities(), publicKeyRing.CountEntities())
+	for _, key := range publicKeyRing.GetKeys() {
+		assert.False(t, key.IsPrivate())
+	}
+}
+
+func TestKeyRingArmoredKeys(t *testing.T) {
+	var publicKeys bytes.Buffer
+	if err := keyRingTestMultiple.GetArmoredPublicKeys(&publicKeys); err != nil {
+		t.Fatal("Expected no error while armoring public keys, got:", err)
+	}
+	var privateKeys bytes.Buffer
+	if err := keyRingTestMultiple.GetArmoredPrivateKeys(&privateKeys, testMailboxPassword); err != nil {
+		t.Fatal("Expected no error while armoring private keys, got:", err)
+	}
+
+	keys := keyRingTestMultiple.GetKeys()
+	for _, test := range []struct {
+		armored   string
+		header    string
+		isPrivate bool
+	}{
+		{publicKeys.String(), constants.PublicKeyHeader, false},
+		{privateKeys.String(), constants.PrivateKeyHeader, true},
+	} {
+		blocks := strings.SplitAfter(test.armored, "-----END "+test.header+"-----\n")
+		assert.Len(t, blocks, len(keys)+1)
+		assert.Exactly(t, "", blocks[len(keys)])
+		for i, block := range blocks[:len(keys)] {
+			key, err := NewKeyFromArmored(block)
+			if err != nil {
+				t.Fatal("Expected no error while reading armored key, got:", err)
+			}
+			assert.Exactly(t, test.isPrivate, key.IsPrivate())
+			assert.Exactly(t, keys[i].GetFingerprint(), key.GetFingerprint())
+			if !test.isPrivate {
+				continue
+			}
+			isLocked, err := key.IsLocked()
+			if err != nil {
+				t.Fatal("Expected no error while checking the key lock, got:", err)
+			}
+			assert.True(t, isLocked)
+			if _, err = key.Unlock(testMailboxPassword); err != nil {
+				t.Fatal("Expected no error while unlocking exported key, got:", err)
+			}
+		}
+	}
+
+	assert.Error(t, keyRingTestPublic.GetArmoredPrivateKeys(&bytes.Buffer{}, testMailboxPassword))
+	var unprotected bytes.Buffer
+	assert.Error(t, keyRingTestMultiple.GetArmoredPrivateKeys(&unprotected, nil))
+	assert.Exactly(t, 0, unprotected.Len())
+}
+
+func TestKeyRingArmoredLockedKeys(t *testing.T) {
+	keyRing := newTestLockedKeyRing(t)
+	var privateKeys bytes.Buffer
+	if err := keyRing.GetArmoredPrivateKeys(&privateKeys, nil); err != nil {
+		t.Fatal("Expected no error while armoring locked keys, got:", err)
+	}
+	checkArmoredLockedKeys(t, privateKeys.String(), keyRing, testMailboxPassword)
+
+	// Partially unlocked keys are written in their locked form
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("plain text"), nil)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	_, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, func(string) ([]byte, error) {
+		return testMailboxPassword, nil
+	})
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.True(t, hasUnlockedPart(keyRing.GetKeys()[0]))
+	privateKeys.Reset()
+	if err = keyRing.GetArmoredPrivateKeys(&privateKeys, nil); err != nil {
+		t.Fatal("Expected no error while armoring partially unlocked keys, got:", err)
+	}
+	checkArmoredLockedKeys(t, privateKeys.String(), keyRing, testMailboxPassword)
+
+	// Only the unlocked keys are locked with the passphrase
+	if err = keyRing.AddKey(keyTestRSA); err != nil {
+		t.Fatal("Expected no error while adding key, got:", err)
+	}
+	privateKeys.Reset()
+	assert.Error(t, keyRing.GetArmoredPrivateKeys(&privateKeys, nil))
+	assert.Exactly(t, 0, privateKeys.Len())
+	if err = keyRing.GetArmoredPrivateKeys(&privateKeys, keyTestPassphrase); err != nil {
+		t.Fatal("Expected no error while armoring keys, got:", err)
+	}
+	checkArmoredLockedKeys(t, privateKeys.String(), keyRing, testMailboxPassword, keyTestPassphrase)
+}
+
+// checkArmoredLockedKeys checks that armored holds the keys of keyRing, locked
+// with the given passphrases in order.
+func checkArmoredLockedKeys(t *testing.T, armored string, keyRing *KeyRing, passphrases ...[]byte) {
+	keys := keyRing.GetKeys()
+	blocks := strings.SplitAfter(armored, "-----END "+constants.PrivateKeyHeader+"-----\n")
+	if !assert.Len(t, blocks, len(keys)+1) {
+		return
+	}
+	for i, block := range blocks[:len(keys)] {
+		key, err := NewKeyFromArmored(block)
+		if err != nil {
+			t.Fatal("Expected no error while reading armored key, got:", err)
+		}
+		assert.Exactly(t, keys[i].GetFingerprint(), key.GetFingerprint())
+		assert.False(t, hasUnlockedPart(key))
+		if _, err = key.Unlock(passphrases[i]); err != nil {
+			t.Fatal("Expected no error while unlocking exported key, got:", err)
+		}
+	}
+}
+
+func TestKeyRingFromMultipleArmoredBlocks(t *testing.T) {
+	var publicKeys bytes.Buffer
+	if err := keyRingTestMultiple.GetArmoredPublicKeys(&publicKeys); err != nil {
+		t.Fatal("Expected no error while armoring public keys, got:", err)
+	}
+	privateKey, err := keyTestEC.Armor()
+	if err != nil {
+		t.Fatal("Expected no error while armoring private key, got:", err)
+	}
+	armored := publicKeys.String() + "\nSome text between the blocks\n\n" + privateKey
+
+	keyRing, err := NewKeyRingFromArmoredReader(strings.NewReader(armored))
+	if err != nil {
+		t.Fatal("Expected no error while reading armored blocks, got:", err)
+	}
+	keys := keyRing.GetKeys()
+	multipleKeys := keyRingTestMultiple.GetKeys()
+	assert.Len(t, keys, len(multipleKeys)+1)
+	for i, key := range multipleKeys {
+		assert.False(t, keys[i].IsPrivate())
+		assert.Exactly(t, key.GetFingerprint(), keys[i].GetFingerprint())
+	}
+	assert.True(t, keys[len(multipleKeys)].IsPrivate())
+	assert.Exactly(t, keyTestEC.GetFingerprint(), keys[len(multipleKeys)].GetFingerprint())
+
+	_, err = NewKeyRingFromArmoredReader(strings.NewReader("no armored block"))
+	assert.Error(t, err)
+	_, err = NewKeyRingFromArmoredReader(strings.NewReader(armored + "\n" + readTestFile("message_signed", false)))
+	assert.Error(t, err)
+}
+
+func TestGetEntityByKeyIDAndFingerprint(t *testing.T) {
+	ecKey := keyRingTestMultiple.GetKeys()[1]
+	subkey := ecKey.entity.Subkeys[0].PublicKey
+
+	key, err := keyRingTestMultiple.GetEntityByKeyID(ecKey.GetKeyID())
+	if err != nil {
+		t.Fatal("Expected no error while looking up key ID, got:", err)
+	}
+	assert.Exactly(t, ecKey.GetFingerprint(), key.GetFingerprint())
+
+	key, err = keyRingTestMultiple.GetEntityByKeyID(subkey.KeyId)
+	if err != nil {
+		t.Fatal("Expected no error while looking up subkey ID, got:", err)
+	}
+	assert.Exactly(t, ecKey.GetFingerprint(), key.GetFingerprint())
+
+	key, err = keyRingTestMultiple.GetEntityByFingerprint(strings.ToUpper(ecKey.GetFingerprint()))
+	if err != nil {
+		t.Fatal("Expected no error while looking up fingerprint, got:", err)
+	}
+	assert.Exactly(t, ecKey.GetFingerprint(), key.GetFingerprint())
+
+	key, err = keyRingTestMultiple.GetEntityByFingerprint(hex.EncodeToString(subkey.Fingerprint))
+	if err != nil {
+		t.Fatal("Expected no error while looking up subkey fingerprint, got:", err)
+	}
+	assert.Exactly(t, ecKey.GetFingerprint(), key.GetFingerprint())
+
+	_, err = keyRingTestMultiple.GetEntityByKeyID(0x0123456789abcdef)
+	assert.NotNil(t, err)
+	_, err = keyRingTestMultiple.GetEntityByFingerprint("0123456789abcdef")
+	assert.NotNil(t, err)
+}
+
 func TestEncryptedDetachedSignature(t *testing.T) {
 	keyRingPrivate, err := keyRingTestPrivate.Copy()
 	if err != nil {
@@ -274,3 +626,119 @@ func TestVerificationTime(t *testing.T) {
 		t.Fatalf("Got an error while decrypting %v", err)
 	}
 }
+
+func TestGenerateRevocationCertificate(t *testing.T) {
+	armored, err := keyRingTestPrivate.GenerateRevocationCertificate(constants.RevocationKeyCompromised, "Stolen laptop")
+	if err != nil {
+		t.Fatal("Expected no error while generating revocation certificate, got:", err)
+	}
+	assert.Contains(t, armored, "BEGIN PGP PUBLIC KEY BLOCK")
+
+	unarmored, err := armor.Unarmor(armored)
+	if err != nil {
+		t.Fatal("Expected no error while unarmoring revocation certificate, got:", err)
+	}
+
+	p, err := packet.Read(bytes.NewReader(unarmored))
+	if err != nil {
+		t.Fatal("Expected no error while reading revocation certificate, got:", err)
+	}
+	revSig, ok := p.(*packet.Signature)
+	assert.True(t, ok)
+	assert.Equal(t, packet.SignatureType(packet.SigTypeKeyRevocation), revSig.SigType)
+	assert.Exactly(t, packet.KeyCompromised, *revSig.RevocationReason)
+	assert.Exactly(t, "Stolen laptop", revSig.RevocationReasonText)
+
+	primaryKey := keyRingTestPublic.GetKeys()[0].entity.PrimaryKey
+	assert.Nil(t, primaryKey.VerifyRevocationSignature(revSig))
+
+	_, err = keyRingTestPublic.GenerateRevocationCertificate(constants.RevocationNoReason, "")
+	assert.NotNil(t, err)
+	_, err = keyRingTestPrivate.GenerateRevocationCertificate(constants.RevocationUserIDNotValid, "")
+	assert.NotNil(t, err)
+}
+
+func TestApplyRevocationCertificate(t *testing.T) {
+	key, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 256)
+	if err != nil {
+		t.Fatal("Expected no error while generating key, got:", err)
+	}
+	privateKeyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Expected no error while building keyring, got:", err)
+	}
+
+	certificate, err := privateKeyRing.GenerateRevocationCertificate(constants.RevocationKeyRetired, "")
+	if err != nil {
+		t.Fatal("Expected no error while generating revocation certificate, got:", err)
+	}
+
+	message := NewPlainMessageFromString("plain text")
+	signature, err := privateKeyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+
+	publicKey, err := key.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while extracting public key, got:", err)
+	}
+	publicKeyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Expected no error while building keyring, got:", err)
+	}
+	assert.Nil(t, publicKeyRing.VerifyDetached(message, signature, GetUnixTime()))
+
+	err = keyRingTestPublic.ApplyRevocationCertificate(certificate)
+	assert.NotNil(t, err)
+	assert.False(t, keyRingTestPublic.GetKeys()[0].IsRevoked())
+
+	err = publicKeyRing.ApplyRevocationCertificate(certificate)
+	if err != nil {
+		t.Fatal("Expected no error while applying revocation certificate, got:", err)
+	}
+
+	revokedKey := publicKeyRing.GetKeys()[0]
+	assert.True(t, revokedKey.IsRevoked())
+	assert.False(t, publicKeyRing.CanEncrypt())
+
+	armored, err := revokedKey.Armor()
+	if err != nil {
+		t.Fatal("Expected no error while armoring key, got:", err)
+	}
+	revokedKey, err = NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatal("Expected no error while unarmoring key, got:", err)
+	}
+	assert.True(t, revokedKey.IsRevoked())
+
+	_, err = publicKeyRing.Encrypt(message, nil)
+	assert.NotNil(t, err)
+
+	err = publicKeyRing.VerifyDetached(message, signature, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestNewKeyRingFromReader(t *testing.T) {
+	armored := readTestFile("keyring_publicKey", false)
+	keyRing, err := NewKeyRingFromArmoredReader(strings.NewReader(armored))
+	if err != nil {
+		t.Fatal("Expected no error when reading armored keyring, got:", err)
+	}
+	assert.Exactly(t, keyRingTestPublic.GetKeyIDs(), keyRing.GetKeyIDs())
+
+	binary, err := keyRing.GetKeys()[0].Serialize()
+	if err != nil {
+		t.Fatal("Expected no error when serializing key, got:", err)
+	}
+	keyRing, err = NewKeyRingFromReader(bytes.NewReader(binary))
+	if err != nil {
+		t.Fatal("Expected no error when reading binary keyring, got:", err)
+	}
+	assert.Exactly(t, keyRingTestPublic.GetKeyIDs(), keyRing.GetKeyIDs())
+
+	_, err = NewKeyRingFromArmoredReader(strings.NewReader(readTestFile("keyring_privateKey", false)))
+	assert.Error(t, err, "locked keys must be rejected")
+	_, err = NewKeyRingFromReader(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
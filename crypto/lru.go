@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"container/list"
+	"crypto/sha256"
+)
+
+// lruCache keeps values by hash, evicting the least recently used ones over
+// maxEntries, if positive. It is not safe for concurrent use.
+type lruCache struct {
+	maxEntries int
+	entries    map[[sha256.Size]byte]*list.Element
+	order      *list.List
+	// onEvict is called with the values removed from the cache, if not nil
+	onEvict func(value interface{})
+}
+
+type lruEntry struct {
+	hash  [sha256.Size]byte
+	value interface{}
+}
+
+func newLRUCache(maxEntries int, onEvict func(value interface{})) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]*list.Element),
+		order:      list.New(),
+		onEvict:    onEvict,
+	}
+}
+
+// get returns the value with the given hash, and marks it as recently used.
+func (cache *lruCache) get(hash [sha256.Size]byte) (interface{}, bool) {
+	element, ok := cache.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// put sets the value with the given hash, replacing the previous one, and
+// evicts the least recently used values over the limit.
+func (cache *lruCache) put(hash [sha256.Size]byte, value interface{}) {
+	if element, ok := cache.entries[hash]; ok {
+		cache.evicted(element.Value.(*lruEntry).value)
+		element.Value = &lruEntry{hash: hash, value: value}
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[hash] = cache.order.PushFront(&lruEntry{hash: hash, value: value})
+
+	for cache.maxEntries > 0 && cache.order.Len() > cache.maxEntries {
+		entry := cache.order.Remove(cache.order.Back()).(*lruEntry)
+		delete(cache.entries, entry.hash)
+		cache.evicted(entry.value)
+	}
+}
+
+func (cache *lruCache) len() int {
+	return cache.order.Len()
+}
+
+// clear removes all the values.
+func (cache *lruCache) clear() {
+	for _, element := range cache.entries {
+		cache.evicted(element.Value.(*lruEntry).value)
+	}
+	cache.entries = make(map[[sha256.Size]byte]*list.Element)
+	cache.order.Init()
+}
+
+func (cache *lruCache) evicted(value interface{}) {
+	if cache.onEvict != nil {
+		cache.onEvict(value)
+	}
+}
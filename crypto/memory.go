@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// lowMemoryCollectionInterval is the number of bytes processed between two
+// garbage collections by the low-memory attachment processors, when no memory
+// budget is set.
+const lowMemoryCollectionInterval = 1 << 20
+
+// SetMemoryBudget enables the memory-limited mode, e.g. on mobile, where large
+// operations keep at most about budget bytes of intermediate data in memory:
+// the attachment processors collect garbage every budget bytes processed, and
+// write the data packets larger than budget to a temporary file in tempDir,
+// or the default directory for temporary files if empty, until they are
+// finished. A budget of 0 disables the memory-limited mode.
+func SetMemoryBudget(budget int64, tempDir string) {
+	pgp.lock.Lock()
+	defer pgp.lock.Unlock()
+
+	if budget < 0 {
+		budget = 0
+	}
+	pgp.memoryBudget = budget
+	pgp.tempDir = tempDir
+}
+
+// GetMemoryBudget returns the memory budget set with SetMemoryBudget, or 0 if
+// the memory-limited mode is disabled.
+func GetMemoryBudget() int64 {
+	pgp.lock.RLock()
+	defer pgp.lock.RUnlock()
+
+	return pgp.memoryBudget
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func getMemoryBudget() (budget int64, tempDir string) {
+	pgp.lock.RLock()
+	defer pgp.lock.RUnlock()
+
+	return pgp.memoryBudget, pgp.tempDir
+}
+
+// garbageCollector collects garbage every interval bytes processed, or never
+// if interval is 0.
+type garbageCollector struct {
+	interval  int64
+	processed int64
+}
+
+// newGarbageCollector returns a garbageCollector collecting every memory
+// budget, or every lowMemoryInterval bytes if no budget is set.
+func newGarbageCollector(lowMemoryInterval int64) *garbageCollector {
+	interval, _ := getMemoryBudget()
+	if interval == 0 {
+		interval = lowMemoryInterval
+	}
+	return &garbageCollector{interval: interval}
+}
+
+// process records that n bytes were processed, and collects garbage if
+// the interval is reached.
+func (gc *garbageCollector) process(n int) {
+	if gc.interval == 0 {
+		return
+	}
+	gc.processed += int64(n)
+	if gc.processed >= gc.interval {
+		gc.processed = 0
+		runtime.GC()
+	}
+}
+
+// collect collects garbage, unless the collector never collects.
+func (gc *garbageCollector) collect() {
+	if gc.interval > 0 {
+		gc.processed = 0
+		runtime.GC()
+	}
+}
+
+// spillBuffer buffers data in memory, up to the memory budget, and in a
+// temporary file beyond it.
+type spillBuffer struct {
+	memory  bytes.Buffer
+	file    *os.File
+	limit   int64
+	tempDir string
+	size    int64
+}
+
+// newSpillBuffer returns a spillBuffer for about sizeHint bytes, using the
+// current memory budget.
+func newSpillBuffer(sizeHint int) *spillBuffer {
+	budget, tempDir := getMemoryBudget()
+	buffer := &spillBuffer{limit: budget, tempDir: tempDir}
+	if sizeHint > 0 && (budget == 0 || int64(sizeHint) <= budget) {
+		buffer.memory.Grow(sizeHint)
+	}
+	return buffer
+}
+
+func (b *spillBuffer) Write(data []byte) (int, error) {
+	if b.file == nil && b.limit > 0 && b.size+int64(len(data)) > b.limit {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(data)
+	} else {
+		n, err = b.memory.Write(data)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// Reader returns a reader of the buffered data, streamed from the temporary
+// file, if any. It is only valid until the buffer is closed.
+func (b *spillBuffer) Reader() (io.Reader, error) {
+	if b.file == nil {
+		return bytes.NewReader(b.memory.Bytes()), nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read temporary file")
+	}
+	return io.LimitReader(b.file, b.size), nil
+}
+
+// readAll returns the buffered data, without a copy if it is in memory, or
+// read from the temporary file in a slice allocated once. Reader avoids
+// reading the temporary file back in memory.
+func (b *spillBuffer) readAll() ([]byte, error) {
+	if b.file == nil {
+		return b.memory.Bytes(), nil
+	}
+	reader, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, b.size)
+	if _, err = io.ReadFull(reader, data); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read temporary file")
+	}
+	return data, nil
+}
+
+// Close releases the buffered data, and removes the temporary file, if any.
+// It can be called several times, and must be called on every path once the
+// buffer is no longer needed, including on errors. The slices returned by
+// readAll stay valid.
+func (b *spillBuffer) Close() error {
+	b.memory = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+	closeErr := file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to remove temporary file")
+	}
+	return closeErr
+}
+
+// spill moves the data buffered in memory to a new temporary file.
+func (b *spillBuffer) spill() error {
+	file, err := ioutil.TempFile(b.tempDir, "gopenpgp-")
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to create temporary file")
+	}
+	if _, err := b.memory.WriteTo(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return errors.Wrap(err, "gopenpgp: unable to write temporary file")
+	}
+	b.memory = bytes.Buffer{}
+	b.file = file
+	return nil
+}
@@ -0,0 +1,177 @@
+package crypto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// allocatedBytes returns the number of bytes allocated while running f.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+// marginalAllocatedBytes returns the number of bytes allocated by f(2*size)
+// beyond f(size), so that the fixed costs don't count. It keeps the smallest
+// of a few runs, as the runtime and other goroutines also allocate.
+func marginalAllocatedBytes(size int, f func(size int)) uint64 {
+	const runs = 3
+	var marginal uint64
+	for i := 0; i < runs; i++ {
+		small := allocatedBytes(func() { f(size) })
+		large := allocatedBytes(func() { f(2 * size) })
+		if large < small {
+			large = small
+		}
+		if i == 0 || large-small < marginal {
+			marginal = large - small
+		}
+	}
+	return marginal
+}
+
+func TestEncryptionAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("The race detector allocates more memory")
+	}
+	const size = 4 << 20
+	messages := map[int]*PlainMessage{}
+	ciphertexts := map[int]*PGPMessage{}
+	for _, n := range []int{size, 2 * size} {
+		messages[n] = NewPlainMessage(make([]byte, n))
+		ciphertext, err := keyRingTestPublic.Encrypt(messages[n], nil)
+		if err != nil {
+			t.Fatal("Cannot encrypt:", err)
+		}
+		ciphertexts[n] = ciphertext
+	}
+
+	// Each additional byte of the message costs at most a few bytes
+	var err error
+	allocated := marginalAllocatedBytes(size, func(n int) {
+		_, err = keyRingTestPublic.Encrypt(messages[n], nil)
+	})
+	assert.Nil(t, err)
+	assert.Less(t, allocated, uint64(3*size))
+
+	allocated = marginalAllocatedBytes(size, func(n int) {
+		_, err = keyRingTestPublic.EncryptAttachment(messages[n], "")
+	})
+	assert.Nil(t, err)
+	assert.Less(t, allocated, uint64(3*size))
+
+	allocated = marginalAllocatedBytes(size, func(n int) {
+		_, err = ciphertexts[n].GetArmored()
+	})
+	assert.Nil(t, err)
+	assert.Less(t, allocated, uint64(2*size))
+
+	allocated = marginalAllocatedBytes(size, func(n int) {
+		_, err = keyRingTestPrivate.Decrypt(ciphertexts[n], nil, 0)
+	})
+	assert.Nil(t, err)
+	assert.Less(t, allocated, uint64(2*size))
+}
+
+func TestPGPSplitMessageJoinDoesNotAlias(t *testing.T) {
+	keyPacket := make([]byte, 2, 16)
+	split := &PGPSplitMessage{KeyPacket: keyPacket, DataPacket: []byte{1, 2, 3}}
+
+	joined := split.GetBinary()
+	joined[2] = 9
+	assert.Exactly(t, []byte{0, 0, 0, 0}, keyPacket[:4])
+	assert.Exactly(t, []byte{0, 0, 1, 2, 3}, split.GetPGPMessage().GetBinary())
+}
+
+func TestMemoryBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	SetMemoryBudget(1<<16, tempDir)
+	defer SetMemoryBudget(0, "")
+	assert.Exactly(t, int64(1<<16), GetMemoryBudget())
+
+	attachment := make([]byte, 1<<20)
+	processor, err := keyRingTestPublic.NewLowMemoryAttachmentProcessor(len(attachment), "budget.bin")
+	if err != nil {
+		t.Fatal("Cannot create attachment processor:", err)
+	}
+	processor.Process(attachment[:1<<19])
+	processor.Process(attachment[1<<19:])
+	files, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal("Cannot list temporary files:", err)
+	}
+	assert.Len(t, files, 1)
+
+	split, err := processor.Finish()
+	if err != nil {
+		t.Fatal("Cannot finish attachment:", err)
+	}
+	files, err = ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal("Cannot list temporary files:", err)
+	}
+	assert.Len(t, files, 0)
+
+	decrypted, err := keyRingTestPrivate.DecryptAttachment(split)
+	if err != nil {
+		t.Fatal("Cannot decrypt attachment:", err)
+	}
+	assert.Exactly(t, attachment, decrypted.GetBinary())
+
+	// The data packet is streamed from the temporary file
+	processor, err = keyRingTestPublic.NewLowMemoryAttachmentProcessor(len(attachment), "budget.bin")
+	if err != nil {
+		t.Fatal("Cannot create attachment processor:", err)
+	}
+	processor.Process(attachment)
+	var dataPacket bytes.Buffer
+	keyPacket, err := processor.FinishTo(&dataPacket)
+	if err != nil {
+		t.Fatal("Expected no error when finishing the attachment, got:", err)
+	}
+	files, err = ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+	decrypted, err = keyRingTestPrivate.DecryptAttachment(NewPGPSplitMessage(keyPacket, dataPacket.Bytes()))
+	if err != nil {
+		t.Fatal("Cannot decrypt attachment:", err)
+	}
+	assert.Exactly(t, attachment, decrypted.GetBinary())
+	_, err = processor.Finish()
+	assert.NotNil(t, err)
+
+	// Aborting removes the temporary file
+	processor, err = keyRingTestPublic.NewLowMemoryAttachmentProcessor(len(attachment), "budget.bin")
+	if err != nil {
+		t.Fatal("Cannot create attachment processor:", err)
+	}
+	processor.Process(attachment)
+	files, err = ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	processor.Abort()
+	files, err = ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+
+	// Small attachments stay in memory
+	split, err = keyRingTestPublic.EncryptAttachment(NewPlainMessageFromString("small"), "")
+	assert.Nil(t, err)
+	files, err = ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+	decrypted, err = keyRingTestPrivate.DecryptAttachment(split)
+	assert.Nil(t, err)
+	assert.Exactly(t, "small", decrypted.GetString())
+
+	SetMemoryBudget(-1, "")
+	assert.Exactly(t, int64(0), GetMemoryBudget())
+}
@@ -3,6 +3,8 @@ package crypto
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	goerrors "errors"
 	"io"
 	"io/ioutil"
@@ -18,6 +20,18 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Tags of the packets of a message parsed without go-crypto. Marker and
+// padding packets carry no data and are ignored when reading.
+const (
+	packetTagEncryptedKey              = 1
+	packetTagSymmetricKeyEncrypted     = 3
+	packetTagSymmetricallyEncrypted    = 9
+	packetTagMarker                    = 10
+	packetTagSymmetricallyEncryptedMDC = 18
+	packetTagAEADEncrypted             = 20
+	packetTagPadding                   = 21
+)
+
 // ---- MODELS -----
 
 // PlainMessage stores a plain text / unencrypted message.
@@ -30,6 +44,12 @@ type PlainMessage struct {
 	Time uint32
 	// The encrypted message's filename
 	Filename string
+	// If the message was decrypted without integrity protection
+	unprotected bool
+	// Unix creation time of the verified signature, if any
+	signatureTime int64
+	// Fingerprint of the key that made the verified signature, if any
+	signerFingerprint []byte
 }
 
 // PGPMessage stores a PGP-encrypted message.
@@ -108,7 +128,26 @@ func NewPGPMessage(data []byte) *PGPMessage {
 
 // NewPGPMessageFromArmored generates a new PGPMessage from an armored string ready for decryption.
 func NewPGPMessageFromArmored(armored string) (*PGPMessage, error) {
-	encryptedIO, err := internal.Unarmor(armored)
+	return NewPGPMessageFromArmoredReader(strings.NewReader(armored))
+}
+
+// NewPGPMessageFromReader generates a new PGPMessage from the unarmored binary data
+// read from r.
+func NewPGPMessageFromReader(r io.Reader) (*PGPMessage, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading message")
+	}
+
+	return &PGPMessage{
+		Data: data,
+	}, nil
+}
+
+// NewPGPMessageFromArmoredReader generates a new PGPMessage from the armored data
+// read from r, ready for decryption.
+func NewPGPMessageFromArmoredReader(r io.Reader) (*PGPMessage, error) {
+	encryptedIO, err := internal.UnarmorReader(r)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in unarmoring message")
 	}
@@ -123,6 +162,33 @@ func NewPGPMessageFromArmored(armored string) (*PGPMessage, error) {
 	}, nil
 }
 
+// NewPGPMessageFromBase64 generates a new PGPMessage from the base-64 encoded
+// unarmored binary data.
+func NewPGPMessageFromBase64(encoded string) (*PGPMessage, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in decoding base64 message")
+	}
+
+	return &PGPMessage{
+		Data: data,
+	}, nil
+}
+
+// NewPGPMessageAuto generates a new PGPMessage from either armored or
+// unarmored binary data. Binary data is checked to start with a message packet.
+func NewPGPMessageAuto(data []byte) (*PGPMessage, error) {
+	if isArmored(data) {
+		return NewPGPMessageFromArmored(string(data))
+	}
+
+	if kind := DetectContent(data); kind != ContentMessage {
+		return nil, errors.New("gopenpgp: data is not a message, found " + kind.String())
+	}
+
+	return NewPGPMessage(data), nil
+}
+
 // NewPGPSplitMessage generates a new PGPSplitMessage from the binary unarmored keypacket,
 // datapacket, and encryption algorithm.
 func NewPGPSplitMessage(keyPacket []byte, dataPacket []byte) *PGPSplitMessage {
@@ -153,7 +219,26 @@ func NewPGPSignature(data []byte) *PGPSignature {
 // NewPGPSignatureFromArmored generates a new PGPSignature from the armored
 // string ready for verification.
 func NewPGPSignatureFromArmored(armored string) (*PGPSignature, error) {
-	encryptedIO, err := internal.Unarmor(armored)
+	return NewPGPSignatureFromArmoredReader(strings.NewReader(armored))
+}
+
+// NewPGPSignatureFromReader generates a new PGPSignature from the unarmored binary data
+// read from r.
+func NewPGPSignatureFromReader(r io.Reader) (*PGPSignature, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading signature")
+	}
+
+	return &PGPSignature{
+		Data: data,
+	}, nil
+}
+
+// NewPGPSignatureFromArmoredReader generates a new PGPSignature from the armored data
+// read from r, ready for verification.
+func NewPGPSignatureFromArmoredReader(r io.Reader) (*PGPSignature, error) {
+	encryptedIO, err := internal.UnarmorReader(r)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in unarmoring signature")
 	}
@@ -168,6 +253,19 @@ func NewPGPSignatureFromArmored(armored string) (*PGPSignature, error) {
 	}, nil
 }
 
+// NewPGPSignatureFromBase64 generates a new PGPSignature from the base-64
+// encoded unarmored binary data.
+func NewPGPSignatureFromBase64(encoded string) (*PGPSignature, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in decoding base64 signature")
+	}
+
+	return &PGPSignature{
+		Data: data,
+	}, nil
+}
+
 // NewClearTextMessage generates a new ClearTextMessage from data and
 // signature.
 func NewClearTextMessage(data []byte, signature []byte) *ClearTextMessage {
@@ -226,6 +324,30 @@ func (msg *PlainMessage) IsBinary() bool {
 	return !msg.TextType
 }
 
+// GetSignatureCreationTime returns the unix creation time of the signature
+// verified when decrypting the message, and false if no signature was
+// verified.
+func (msg *PlainMessage) GetSignatureCreationTime() (int64, bool) {
+	return msg.signatureTime, msg.signatureTime != 0
+}
+
+// GetHexSignerFingerprint returns the fingerprint of the key, primary key or
+// subkey, that made the signature verified when decrypting the message, as a
+// hex string, and false if no signature was verified.
+func (msg *PlainMessage) GetHexSignerFingerprint() (string, bool) {
+	if len(msg.signerFingerprint) == 0 {
+		return "", false
+	}
+	return hex.EncodeToString(msg.signerFingerprint), true
+}
+
+// IsIntegrityProtected returns false if the message was decrypted from a
+// Symmetrically Encrypted packet without integrity protection, as allowed by
+// SetAllowUnprotectedMessages. Its plaintext may then have been modified.
+func (msg *PlainMessage) IsIntegrityProtected() bool {
+	return !msg.unprotected
+}
+
 // getFormattedTime returns the message (latest modification) Time as time.Time.
 func (msg *PlainMessage) getFormattedTime() time.Time {
 	return time.Unix(int64(msg.Time), 0)
@@ -236,6 +358,12 @@ func (msg *PGPMessage) GetBinary() []byte {
 	return msg.Data
 }
 
+// GetBase64 returns the base-64 encoded unarmored binary content of the
+// message as a string.
+func (msg *PGPMessage) GetBase64() string {
+	return base64.StdEncoding.EncodeToString(msg.Data)
+}
+
 // NewReader returns a New io.Reader for the unarmored binary data of the
 // message.
 func (msg *PGPMessage) NewReader() io.Reader {
@@ -253,6 +381,63 @@ func (msg *PGPMessage) GetArmoredWithCustomHeaders(comment, version string) (str
 	return armor.ArmorWithTypeAndCustomHeaders(msg.Data, constants.PGPMessageHeader, version, comment)
 }
 
+// GetArmoredWithOptions returns the armored message as a string, with the
+// headers of options.
+func (msg *PGPMessage) GetArmoredWithOptions(options *armor.Options) (string, error) {
+	return armor.ArmorWithTypeAndOptions(msg.Data, constants.PGPMessageHeader, options)
+}
+
+// ArmorTo writes the armored message to w, without building the armored
+// message in memory.
+func (msg *PGPMessage) ArmorTo(w io.Writer) error {
+	return msg.ArmorToWithOptions(w, nil)
+}
+
+// ArmorToWithOptions writes the armored message to w, with the headers of
+// options.
+func (msg *PGPMessage) ArmorToWithOptions(w io.Writer, options *armor.Options) error {
+	return armorTo(w, constants.PGPMessageHeader, options, msg.Data)
+}
+
+// WriteTo writes the unarmored binary message to w, implementing io.WriterTo.
+func (msg *PGPMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(msg.Data)
+	return int64(n), err
+}
+
+// AddPadding appends a padding packet of paddingSize random bytes to the
+// message, to hide its size from traffic analysis. Padding packets are
+// ignored when the message is decrypted.
+func (msg *PGPMessage) AddPadding(paddingSize int) error {
+	padding, err := newPaddingPacket(paddingSize)
+	if err != nil {
+		return err
+	}
+	msg.Data = joinPackets(msg.Data, padding)
+	return nil
+}
+
+// GetPaddingSize returns the total size of the contents of the padding
+// packets outside of the encrypted data of the message.
+func (msg *PGPMessage) GetPaddingSize() (int, error) {
+	paddingSize := 0
+	for rest := msg.Data; len(rest) > 0; {
+		tag, next, err := skipRawPacket(rest)
+		if err != nil {
+			return 0, errors.Wrap(err, "gopenpgp: error in reading message")
+		}
+		if tag == packetTagPadding {
+			_, body, _, err := readRawPacket(rest)
+			if err != nil {
+				return 0, errors.Wrap(err, "gopenpgp: error in reading padding packet")
+			}
+			paddingSize += len(body)
+		}
+		rest = next
+	}
+	return paddingSize, nil
+}
+
 // GetEncryptionKeyIDs Returns the key IDs of the keys to which the session key is encrypted.
 func (msg *PGPMessage) GetEncryptionKeyIDs() ([]uint64, bool) {
 	packets := packet.NewReader(bytes.NewReader(msg.Data))
@@ -309,7 +494,7 @@ func (msg *PGPSplitMessage) GetBinaryKeyPacket() []byte {
 
 // GetBinary returns the unarmored binary joined packets as a []byte.
 func (msg *PGPSplitMessage) GetBinary() []byte {
-	return append(msg.KeyPacket, msg.DataPacket...)
+	return joinPackets(msg.KeyPacket, msg.DataPacket)
 }
 
 // GetArmored returns the armored message as a string, with joined data and key
@@ -318,33 +503,61 @@ func (msg *PGPSplitMessage) GetArmored() (string, error) {
 	return armor.ArmorWithType(msg.GetBinary(), constants.PGPMessageHeader)
 }
 
+// GetArmoredWithOptions returns the armored message as a string, with joined
+// data and key packets, and the headers of options.
+func (msg *PGPSplitMessage) GetArmoredWithOptions(options *armor.Options) (string, error) {
+	return armor.ArmorWithTypeAndOptions(msg.GetBinary(), constants.PGPMessageHeader, options)
+}
+
+// ArmorTo writes the armored message, with joined key and data packets, to w,
+// without joining the packets or building the armored message in memory.
+func (msg *PGPSplitMessage) ArmorTo(w io.Writer) error {
+	return msg.ArmorToWithOptions(w, nil)
+}
+
+// ArmorToWithOptions writes the armored message, with joined key and data
+// packets, to w, with the headers of options.
+func (msg *PGPSplitMessage) ArmorToWithOptions(w io.Writer, options *armor.Options) error {
+	return armorTo(w, constants.PGPMessageHeader, options, msg.KeyPacket, msg.DataPacket)
+}
+
+// WriteTo writes the unarmored binary joined packets to w, implementing
+// io.WriterTo.
+func (msg *PGPSplitMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(msg.KeyPacket)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(msg.DataPacket)
+	return int64(n + m), err
+}
+
 // GetPGPMessage joins asymmetric session key packet with the symmetric data
 // packet to obtain a PGP message.
 func (msg *PGPSplitMessage) GetPGPMessage() *PGPMessage {
-	return NewPGPMessage(append(msg.KeyPacket, msg.DataPacket...))
+	return newPGPMessage(joinPackets(msg.KeyPacket, msg.DataPacket))
 }
 
 // SplitMessage splits the message into key and data packet(s).
-// Parameters are for backwards compatibility and are unused.
+// The packets are split on their headers without being re-encoded, so data
+// packets with partial body lengths, e.g. produced by gpg, are kept intact.
 func (msg *PGPMessage) SplitMessage() (*PGPSplitMessage, error) {
-	bytesReader := bytes.NewReader(msg.Data)
-	packets := packet.NewReader(bytesReader)
-	splitPoint := int64(0)
+	splitPoint := 0
 Loop:
-	for {
-		p, err := packets.Next()
-		if goerrors.Is(err, io.EOF) {
-			break
+	for rest := msg.Data; len(rest) > 0; {
+		switch readRawPacketTag(rest[0]) {
+		case packetTagSymmetricallyEncrypted, packetTagSymmetricallyEncryptedMDC, packetTagAEADEncrypted:
+			// The data packet is split without being read
+			break Loop
 		}
+		tag, next, err := skipRawPacket(rest)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrap(err, "gopenpgp: error in splitting message")
 		}
-		switch p.(type) {
-		case *packet.SymmetricKeyEncrypted, *packet.EncryptedKey:
-			splitPoint = bytesReader.Size() - int64(bytesReader.Len())
-		case *packet.SymmetricallyEncrypted, *packet.AEADEncrypted:
-			break Loop
+		if tag == packetTagEncryptedKey || tag == packetTagSymmetricKeyEncrypted {
+			splitPoint = len(msg.Data) - len(next)
 		}
+		rest = next
 	}
 	return &PGPSplitMessage{
 		KeyPacket:  clone(msg.Data[:splitPoint]),
@@ -364,11 +577,41 @@ func (sig *PGPSignature) GetBinary() []byte {
 	return sig.Data
 }
 
+// GetBase64 returns the base-64 encoded unarmored binary content of the
+// signature as a string.
+func (sig *PGPSignature) GetBase64() string {
+	return base64.StdEncoding.EncodeToString(sig.Data)
+}
+
 // GetArmored returns the armored signature as a string.
 func (sig *PGPSignature) GetArmored() (string, error) {
 	return armor.ArmorWithType(sig.Data, constants.PGPSignatureHeader)
 }
 
+// GetArmoredWithOptions returns the armored signature as a string, with the
+// headers of options.
+func (sig *PGPSignature) GetArmoredWithOptions(options *armor.Options) (string, error) {
+	return armor.ArmorWithTypeAndOptions(sig.Data, constants.PGPSignatureHeader, options)
+}
+
+// ArmorTo writes the armored signature to w.
+func (sig *PGPSignature) ArmorTo(w io.Writer) error {
+	return sig.ArmorToWithOptions(w, nil)
+}
+
+// ArmorToWithOptions writes the armored signature to w, with the headers of
+// options.
+func (sig *PGPSignature) ArmorToWithOptions(w io.Writer, options *armor.Options) error {
+	return armorTo(w, constants.PGPSignatureHeader, options, sig.Data)
+}
+
+// WriteTo writes the unarmored binary signature to w, implementing
+// io.WriterTo.
+func (sig *PGPSignature) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(sig.Data)
+	return int64(n), err
+}
+
 // GetSignatureKeyIDs Returns the key IDs of the keys to which the (readable) signature packets are encrypted to.
 func (sig *PGPSignature) GetSignatureKeyIDs() ([]uint64, bool) {
 	return getSignatureKeyIDs(sig.Data)
@@ -379,6 +622,47 @@ func (sig *PGPSignature) GetHexSignatureKeyIDs() ([]string, bool) {
 	return getHexKeyIDs(sig.GetSignatureKeyIDs())
 }
 
+// GetSignerKeyID returns the issuer key ID of the first signature packet, and
+// whether the signature has one. It can be used to fetch the public key
+// before verifying the signature.
+func (sig *PGPSignature) GetSignerKeyID() (uint64, bool) {
+	signature, ok := getFirstSignaturePacket(sig.Data)
+	if !ok || signature.IssuerKeyId == nil {
+		return 0, false
+	}
+	return *signature.IssuerKeyId, true
+}
+
+// GetHexSignerKeyID returns the issuer key ID of the first signature packet
+// as a hex string, and whether the signature has one.
+func (sig *PGPSignature) GetHexSignerKeyID() (string, bool) {
+	keyID, ok := sig.GetSignerKeyID()
+	if !ok {
+		return "", false
+	}
+	return keyIDToHex(keyID), true
+}
+
+// GetSignerFingerprint returns the issuer fingerprint of the first signature
+// packet, and whether the signature has one.
+func (sig *PGPSignature) GetSignerFingerprint() ([]byte, bool) {
+	signature, ok := getFirstSignaturePacket(sig.Data)
+	if !ok || len(signature.IssuerFingerprint) == 0 {
+		return nil, false
+	}
+	return clone(signature.IssuerFingerprint), true
+}
+
+// GetHexSignerFingerprint returns the issuer fingerprint of the first
+// signature packet as a hex string, and whether the signature has one.
+func (sig *PGPSignature) GetHexSignerFingerprint() (string, bool) {
+	fingerprint, ok := sig.GetSignerFingerprint()
+	if !ok {
+		return "", false
+	}
+	return hex.EncodeToString(fingerprint), true
+}
+
 // GetBinary returns the unarmored signed data as a []byte.
 func (msg *ClearTextMessage) GetBinary() []byte {
 	return msg.Data
@@ -397,7 +681,13 @@ func (msg *ClearTextMessage) GetBinarySignature() []byte {
 // GetArmored armors plaintext and signature with the PGP SIGNED MESSAGE
 // armoring.
 func (msg *ClearTextMessage) GetArmored() (string, error) {
-	armSignature, err := armor.ArmorWithType(msg.GetBinarySignature(), constants.PGPSignatureHeader)
+	return msg.GetArmoredWithOptions(nil)
+}
+
+// GetArmoredWithOptions armors plaintext and signature with the PGP SIGNED
+// MESSAGE armoring, with the headers of options in the signature armor.
+func (msg *ClearTextMessage) GetArmoredWithOptions(options *armor.Options) (string, error) {
+	armSignature, err := armor.ArmorWithTypeAndOptions(msg.GetBinarySignature(), constants.PGPSignatureHeader, options)
 	if err != nil {
 		return "", errors.Wrap(err, "gopenpgp: error in armoring cleartext message")
 	}
@@ -413,6 +703,7 @@ func (msg *ClearTextMessage) GetArmored() (string, error) {
 // ---- UTILS -----
 
 // IsPGPMessage checks if data if has armored PGP message format.
+// DetectContent also recognizes binary messages, keys and signatures.
 func IsPGPMessage(data string) bool {
 	re := regexp.MustCompile("^-----BEGIN " + constants.PGPMessageHeader + "-----(?s:.+)-----END " +
 		constants.PGPMessageHeader + "-----")
@@ -454,6 +745,85 @@ Loop:
 	return ids, false
 }
 
+// getFirstSignaturePacket returns the first signature packet of data, if any.
+func getFirstSignaturePacket(data []byte) (*packet.Signature, bool) {
+	packets := packet.NewReader(bytes.NewReader(data))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return nil, false
+		}
+		if signature, ok := p.(*packet.Signature); ok {
+			return signature, true
+		}
+	}
+}
+
+// encryptionOverhead bounds the size added to a message by its encryption,
+// without compression: the headers of the packets, the literal data header
+// but the file name, the random prefix, the MDC packet and the signature of a
+// 4096-bit RSA key.
+// keyPacketSizeEstimate is the size of the key packet of a 4096-bit RSA key.
+const (
+	encryptionOverhead    = 1024
+	keyPacketSizeEstimate = 530
+)
+
+// encryptedSizeEstimate returns an estimate of the size of the message
+// encrypted to the given number of recipients, to allocate the buffer for it
+// once.
+func encryptedSizeEstimate(message *PlainMessage, recipients int) int {
+	return len(message.Data) + len(message.Filename) + encryptionOverhead + recipients*keyPacketSizeEstimate
+}
+
+// newPaddingPacket returns a padding packet with paddingSize random bytes.
+func newPaddingPacket(paddingSize int) ([]byte, error) {
+	if paddingSize < 0 {
+		return nil, errors.New("gopenpgp: invalid padding size")
+	}
+	contents, err := RandomToken(paddingSize)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 6)
+	header[0], header[1] = 0xc0|packetTagPadding, 0xff
+	binary.BigEndian.PutUint32(header[2:], uint32(paddingSize))
+	return joinPackets(header, contents), nil
+}
+
+// newPGPMessage creates a PGPMessage with data produced by the library,
+// without copying it.
+func newPGPMessage(data []byte) *PGPMessage {
+	return &PGPMessage{Data: data}
+}
+
+// joinPackets returns a new slice with the key packets followed by the data
+// packets, allocated once.
+func joinPackets(keyPacket, dataPacket []byte) []byte {
+	joined := make([]byte, len(keyPacket)+len(dataPacket))
+	copy(joined, keyPacket)
+	copy(joined[len(keyPacket):], dataPacket)
+	return joined
+}
+
+// armorTo writes the armored concatenation of data to w, with the given
+// armorType and the headers of options.
+func armorTo(w io.Writer, armorType string, options *armor.Options, data ...[]byte) error {
+	armorWriter, err := armor.ArmorWithTypeBufferedAndOptions(w, armorType, options)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to encode armoring")
+	}
+	for _, d := range data {
+		if _, err := armorWriter.Write(d); err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to write armored data")
+		}
+	}
+	if err := armorWriter.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to close armor writer")
+	}
+	return nil
+}
+
 func getHexKeyIDs(keyIDs []uint64, ok bool) ([]string, bool) {
 	hexIDs := make([]string, len(keyIDs))
 
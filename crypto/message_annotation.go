@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	goerrors "errors"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// MessageAnnotation is sidecar metadata describing an encrypted message.
+// It can be stored alongside the message, so that storage systems can index
+// encrypted messages without having to decrypt them.
+type MessageAnnotation struct {
+	// Hex key IDs of the keys to which the session key is encrypted
+	RecipientKeyIDs []string `json:"recipientKeyIds,omitempty"`
+	// Public key algorithms of the encrypted session keys
+	PublicKeyAlgorithms []string `json:"publicKeyAlgorithms,omitempty"`
+	// Number of password encrypted session keys
+	PasswordCount int `json:"passwordCount,omitempty"`
+	// If the data packet is integrity protected (MDC or AEAD)
+	IntegrityProtected bool `json:"integrityProtected"`
+	// If the data packet is AEAD encrypted
+	AEAD bool `json:"aead"`
+	// Size in bytes of the session key packets
+	KeyPacketSize int `json:"keyPacketSize"`
+	// Size in bytes of the data packet
+	DataPacketSize int `json:"dataPacketSize"`
+	// Hex key ID of the signing key, only known at encryption time
+	SignerKeyID string `json:"signerKeyId,omitempty"`
+	// Size in bytes of the plaintext, only known at encryption time
+	PlaintextSize int `json:"plaintextSize,omitempty"`
+	// Modification time of the plaintext, only known at encryption time
+	PlaintextTime int64 `json:"plaintextTime,omitempty"`
+	// Time of the encryption, only known at encryption time
+	EncryptionTime int64 `json:"encryptionTime,omitempty"`
+}
+
+var pubKeyAlgoNames = map[packet.PublicKeyAlgorithm]string{
+	packet.PubKeyAlgoRSA:            "rsa",
+	packet.PubKeyAlgoRSAEncryptOnly: "rsa",
+	packet.PubKeyAlgoElGamal:        "elgamal",
+	packet.PubKeyAlgoDSA:            "dsa",
+	packet.PubKeyAlgoECDH:           "ecdh",
+	packet.PubKeyAlgoECDSA:          "ecdsa",
+	packet.PubKeyAlgoEdDSA:          "eddsa",
+}
+
+// NewMessageAnnotation derives the annotation of an encrypted message from
+// its packets. The fields that are only known at encryption time are left empty.
+func NewMessageAnnotation(message *PGPMessage) (*MessageAnnotation, error) {
+	annotation := &MessageAnnotation{}
+	bytesReader := bytes.NewReader(message.Data)
+	packets := packet.NewReader(bytesReader)
+	splitPoint := 0
+	foundData := false
+Loop:
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in reading message packets")
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			annotation.RecipientKeyIDs = append(annotation.RecipientKeyIDs, keyIDToHex(p.KeyId))
+			annotation.PublicKeyAlgorithms = appendUnique(annotation.PublicKeyAlgorithms, pubKeyAlgoNames[p.Algo])
+		case *packet.SymmetricKeyEncrypted:
+			annotation.PasswordCount++
+		case *packet.SymmetricallyEncrypted:
+			annotation.IntegrityProtected = p.MDC
+			foundData = true
+			break Loop
+		case *packet.AEADEncrypted:
+			annotation.IntegrityProtected = true
+			annotation.AEAD = true
+			foundData = true
+			break Loop
+		}
+		splitPoint = len(message.Data) - bytesReader.Len()
+	}
+	if !foundData {
+		return nil, errors.New("gopenpgp: no encrypted data packet found in message")
+	}
+
+	annotation.KeyPacketSize = splitPoint
+	annotation.DataPacketSize = len(message.Data) - splitPoint
+
+	return annotation, nil
+}
+
+// NewEncryptionAnnotation computes the annotation of a message at encryption
+// time, from the plaintext, the resulting encrypted message, and the keyring
+// used to sign it. signKeyRing can be nil if the message is not signed.
+func NewEncryptionAnnotation(
+	plainMessage *PlainMessage, message *PGPMessage, signKeyRing *KeyRing,
+) (*MessageAnnotation, error) {
+	annotation, err := NewMessageAnnotation(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if signKeyRing != nil {
+		signEntity, err := signKeyRing.getSigningEntity()
+		if err != nil {
+			return nil, err
+		}
+		signingKey, ok := signingKeyAt(signEntity, getNow(), 0)
+		if !ok {
+			return nil, errors.New("gopenpgp: no valid signing key found")
+		}
+		annotation.SignerKeyID = keyIDToHex(signingKey.PublicKey.KeyId)
+	}
+
+	annotation.PlaintextSize = len(plainMessage.Data)
+	annotation.PlaintextTime = int64(plainMessage.Time)
+	annotation.EncryptionTime = getNow().Unix()
+
+	return annotation, nil
+}
+
+// NewMessageAnnotationFromJSON parses an annotation serialized with ToJSON.
+func NewMessageAnnotationFromJSON(data []byte) (*MessageAnnotation, error) {
+	annotation := &MessageAnnotation{}
+	if err := json.Unmarshal(data, annotation); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in parsing message annotation")
+	}
+	return annotation, nil
+}
+
+// ToJSON serializes the annotation as JSON.
+func (annotation *MessageAnnotation) ToJSON() ([]byte, error) {
+	data, err := json.Marshal(annotation)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing message annotation")
+	}
+	return data, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageAnnotation(t *testing.T) {
+	message := NewPlainMessageFromString("The secret code is... 1, 2, 3, 4, 5")
+
+	ciphertext, err := keyRingTestPublic.Encrypt(message, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	annotation, err := NewEncryptionAnnotation(message, ciphertext, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when annotating, got:", err)
+	}
+
+	recipientIDs, _ := ciphertext.GetHexEncryptionKeyIDs()
+	assert.Exactly(t, recipientIDs, annotation.RecipientKeyIDs)
+	assert.Exactly(t, []string{"rsa"}, annotation.PublicKeyAlgorithms)
+	assert.True(t, annotation.IntegrityProtected)
+	assert.Exactly(t, len(ciphertext.Data), annotation.KeyPacketSize+annotation.DataPacketSize)
+	assert.Exactly(t, len(message.Data), annotation.PlaintextSize)
+	assert.Exactly(t, int64(message.Time), annotation.PlaintextTime)
+	assert.Exactly(t, GetUnixTime(), annotation.EncryptionTime)
+	assert.NotEmpty(t, annotation.SignerKeyID)
+
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	assert.Exactly(t, len(split.KeyPacket), annotation.KeyPacketSize)
+
+	serialized, err := annotation.ToJSON()
+	if err != nil {
+		t.Fatal("Expected no error when serializing annotation, got:", err)
+	}
+	parsed, err := NewMessageAnnotationFromJSON(serialized)
+	if err != nil {
+		t.Fatal("Expected no error when parsing annotation, got:", err)
+	}
+	assert.Exactly(t, annotation, parsed)
+
+	derived, err := NewMessageAnnotation(ciphertext)
+	if err != nil {
+		t.Fatal("Expected no error when deriving annotation, got:", err)
+	}
+	assert.Exactly(t, annotation.RecipientKeyIDs, derived.RecipientKeyIDs)
+	assert.Exactly(t, annotation.DataPacketSize, derived.DataPacketSize)
+	assert.Empty(t, derived.SignerKeyID)
+	assert.Zero(t, derived.PlaintextSize)
+}
+
+func TestMessageAnnotationPassword(t *testing.T) {
+	ciphertext, err := EncryptMessageWithPassword(NewPlainMessageFromString("hello"), testSymmetricKey)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	annotation, err := NewMessageAnnotation(ciphertext)
+	if err != nil {
+		t.Fatal("Expected no error when annotating, got:", err)
+	}
+	assert.Exactly(t, 1, annotation.PasswordCount)
+	assert.Empty(t, annotation.RecipientKeyIDs)
+
+	_, err = NewMessageAnnotation(NewPGPMessage([]byte{}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// JSON representations of the models, where binary fields are base64 encoded
+// by encoding/json and statuses are strings.
+
+type pgpMessageJSON struct {
+	Data []byte `json:"data"`
+}
+
+type pgpSplitMessageJSON struct {
+	KeyPacket  []byte `json:"keyPacket"`
+	DataPacket []byte `json:"dataPacket"`
+}
+
+type notationJSON struct {
+	Name            string `json:"name"`
+	Value           []byte `json:"value"`
+	IsHumanReadable bool   `json:"isHumanReadable,omitempty"`
+	IsCritical      bool   `json:"isCritical,omitempty"`
+}
+
+type signatureVerificationErrorJSON struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type verificationReportJSON struct {
+	Status                       string      `json:"status"`
+	Message                      string      `json:"message,omitempty"`
+	UnknownCriticalSubpackets    []int       `json:"unknownCriticalSubpackets,omitempty"`
+	UnknownNonCriticalSubpackets []int       `json:"unknownNonCriticalSubpackets,omitempty"`
+	Notations                    []*Notation `json:"notations,omitempty"`
+	SignatureCreationTime        int64       `json:"signatureCreationTime,omitempty"`
+}
+
+var verificationStatusNames = map[int]string{
+	constants.SIGNATURE_OK:          "ok",
+	constants.SIGNATURE_NOT_SIGNED:  "not_signed",
+	constants.SIGNATURE_NO_VERIFIER: "no_verifier",
+	constants.SIGNATURE_FAILED:      "failed",
+	constants.SIGNATURE_EXPIRED:     "expired",
+}
+
+// MarshalJSON encodes the message as {"data": base64}.
+func (msg *PGPMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&pgpMessageJSON{Data: msg.Data})
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON.
+func (msg *PGPMessage) UnmarshalJSON(data []byte) error {
+	var decoded pgpMessageJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing message")
+	}
+	msg.Data = decoded.Data
+	return nil
+}
+
+// MarshalJSON encodes the split message as
+// {"keyPacket": base64, "dataPacket": base64}.
+func (msg *PGPSplitMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&pgpSplitMessageJSON{KeyPacket: msg.KeyPacket, DataPacket: msg.DataPacket})
+}
+
+// UnmarshalJSON decodes a split message encoded by MarshalJSON.
+func (msg *PGPSplitMessage) UnmarshalJSON(data []byte) error {
+	var decoded pgpSplitMessageJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing split message")
+	}
+	msg.KeyPacket = decoded.KeyPacket
+	msg.DataPacket = decoded.DataPacket
+	return nil
+}
+
+// MarshalJSON encodes the signature as {"data": base64}.
+func (sig *PGPSignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&pgpMessageJSON{Data: sig.Data})
+}
+
+// UnmarshalJSON decodes a signature encoded by MarshalJSON.
+func (sig *PGPSignature) UnmarshalJSON(data []byte) error {
+	var decoded pgpMessageJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing signature")
+	}
+	sig.Data = decoded.Data
+	return nil
+}
+
+// MarshalJSON encodes the notation with a base64 value.
+func (notation *Notation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&notationJSON{
+		Name:            notation.Name,
+		Value:           notation.Value,
+		IsHumanReadable: notation.IsHumanReadable,
+		IsCritical:      notation.IsCritical,
+	})
+}
+
+// UnmarshalJSON decodes a notation encoded by MarshalJSON.
+func (notation *Notation) UnmarshalJSON(data []byte) error {
+	var decoded notationJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing notation")
+	}
+	*notation = Notation{
+		Name:            decoded.Name,
+		Value:           decoded.Value,
+		IsHumanReadable: decoded.IsHumanReadable,
+		IsCritical:      decoded.IsCritical,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the error as {"status": "failed", "message": ...},
+// with the status name of one of the constants.SIGNATURE_* values.
+func (e SignatureVerificationError) MarshalJSON() ([]byte, error) {
+	status, err := getVerificationStatusName(e.Status)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&signatureVerificationErrorJSON{Status: status, Message: e.Message})
+}
+
+// UnmarshalJSON decodes an error encoded by MarshalJSON.
+func (e *SignatureVerificationError) UnmarshalJSON(data []byte) error {
+	var decoded signatureVerificationErrorJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing signature verification error")
+	}
+	status, err := getVerificationStatus(decoded.Status)
+	if err != nil {
+		return err
+	}
+	e.Status = status
+	e.Message = decoded.Message
+	return nil
+}
+
+// MarshalJSON encodes the report with the status name of its status,
+// e.g. "ok" for constants.SIGNATURE_OK.
+func (report *VerificationReport) MarshalJSON() ([]byte, error) {
+	status, err := getVerificationStatusName(report.Status)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&verificationReportJSON{
+		Status:                       status,
+		Message:                      report.Message,
+		UnknownCriticalSubpackets:    report.UnknownCriticalSubpackets,
+		UnknownNonCriticalSubpackets: report.UnknownNonCriticalSubpackets,
+		Notations:                    report.Notations,
+		SignatureCreationTime:        report.SignatureCreationTime,
+	})
+}
+
+// UnmarshalJSON decodes a report encoded by MarshalJSON.
+func (report *VerificationReport) UnmarshalJSON(data []byte) error {
+	var decoded verificationReportJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing verification report")
+	}
+	status, err := getVerificationStatus(decoded.Status)
+	if err != nil {
+		return err
+	}
+	*report = VerificationReport{
+		Status:                       status,
+		Message:                      decoded.Message,
+		UnknownCriticalSubpackets:    decoded.UnknownCriticalSubpackets,
+		UnknownNonCriticalSubpackets: decoded.UnknownNonCriticalSubpackets,
+		Notations:                    decoded.Notations,
+		SignatureCreationTime:        decoded.SignatureCreationTime,
+	}
+	return nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func getVerificationStatusName(status int) (string, error) {
+	name, ok := verificationStatusNames[status]
+	if !ok {
+		return "", fmt.Errorf("gopenpgp: unknown verification status: %d", status)
+	}
+	return name, nil
+}
+
+func getVerificationStatus(name string) (int, error) {
+	for status, statusName := range verificationStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("gopenpgp: unknown verification status: %q", name)
+}
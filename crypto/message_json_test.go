@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestMessageJSON(t *testing.T) {
+	message, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("json"), keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal("Expected no error when serializing message, got:", err)
+	}
+	assert.JSONEq(t, `{"data":"`+base64.StdEncoding.EncodeToString(message.Data)+`"}`, string(data))
+	decodedMessage := &PGPMessage{}
+	if err = json.Unmarshal(data, decodedMessage); err != nil {
+		t.Fatal("Expected no error when parsing message, got:", err)
+	}
+	assert.Exactly(t, message.Data, decodedMessage.Data)
+
+	split, err := message.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	data, err = json.Marshal(split)
+	if err != nil {
+		t.Fatal("Expected no error when serializing split message, got:", err)
+	}
+	decodedSplit := &PGPSplitMessage{}
+	if err = json.Unmarshal(data, decodedSplit); err != nil {
+		t.Fatal("Expected no error when parsing split message, got:", err)
+	}
+	assert.Exactly(t, split.KeyPacket, decodedSplit.KeyPacket)
+	assert.Exactly(t, split.DataPacket, decodedSplit.DataPacket)
+
+	signature, err := keyRingTestPrivate.SignDetached(NewPlainMessageFromString("json"))
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	data, err = json.Marshal(signature)
+	if err != nil {
+		t.Fatal("Expected no error when serializing signature, got:", err)
+	}
+	decodedSignature := &PGPSignature{}
+	if err = json.Unmarshal(data, decodedSignature); err != nil {
+		t.Fatal("Expected no error when parsing signature, got:", err)
+	}
+	assert.Exactly(t, signature.Data, decodedSignature.Data)
+}
+
+func TestVerificationResultJSON(t *testing.T) {
+	data, err := json.Marshal(newSignatureFailed())
+	if err != nil {
+		t.Fatal("Expected no error when serializing verification error, got:", err)
+	}
+	assert.JSONEq(t, `{"status":"failed","message":"Invalid signature"}`, string(data))
+	decodedError := &SignatureVerificationError{}
+	if err = json.Unmarshal(data, decodedError); err != nil {
+		t.Fatal("Expected no error when parsing verification error, got:", err)
+	}
+	assert.Exactly(t, newSignatureFailed(), *decodedError)
+
+	report := &VerificationReport{
+		Status:                       constants.SIGNATURE_OK,
+		UnknownNonCriticalSubpackets: []int{100},
+		SignatureCreationTime:        testTime,
+		Notations: []*Notation{
+			{Name: "salt@gopenpgp", Value: []byte{0, 1, 2}},
+			{Name: "policy@gopenpgp", Value: []byte("text"), IsHumanReadable: true},
+		},
+	}
+	data, err = json.Marshal(report)
+	if err != nil {
+		t.Fatal("Expected no error when serializing report, got:", err)
+	}
+	decodedReport := &VerificationReport{}
+	if err = json.Unmarshal(data, decodedReport); err != nil {
+		t.Fatal("Expected no error when parsing report, got:", err)
+	}
+	assert.Exactly(t, report, decodedReport)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"status":"maybe"}`), decodedReport))
+	_, err = json.Marshal(&VerificationReport{Status: 42})
+	assert.Error(t, err)
+}
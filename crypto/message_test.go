@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -266,6 +268,64 @@ func TestDummy(t *testing.T) {
 	assert.Exactly(t, message.GetString(), decrypted.GetString())
 }
 
+func TestDummySigningSubkey(t *testing.T) {
+	pgp.latestServerTime = 1636700000
+	defer func() { pgp.latestServerTime = testTime }()
+
+	dummyKey, err := NewKeyFromArmored(readTestFile("key_dummy_signing", false))
+	if err != nil {
+		t.Fatal("Expected no error while reading key, got:", err)
+	}
+	assert.True(t, dummyKey.IsPrimaryKeyDummy())
+
+	unlockedDummyKey, err := dummyKey.Unlock([]byte("golang"))
+	if err != nil {
+		t.Fatal("Expected no error while unlocking private key, got:", err)
+	}
+	dummyKeyRing, err := NewKeyRing(unlockedDummyKey)
+	if err != nil {
+		t.Fatal("Expected no error while building private keyring, got:", err)
+	}
+
+	message := NewPlainMessageFromString("signed with a subkey")
+	signature, err := dummyKeyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	assert.Nil(t, dummyKeyRing.VerifyDetached(message, signature, GetUnixTime()))
+
+	ciphertext, err := dummyKeyRing.Encrypt(message, dummyKeyRing)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	decrypted, err := dummyKeyRing.Decrypt(ciphertext, dummyKeyRing, GetUnixTime())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	// Certifying needs the secret primary key
+	_, err = unlockedDummyKey.AddUserID("Other", "", "other@example.com")
+	assert.EqualError(t, err, "gopenpgp: the secret primary key is missing (gnu-dummy)")
+
+	// Keys without signing subkey are skipped when signing
+	noSigningKey, _ := NewKeyFromArmored(readTestFile("key_dummy", false))
+	unlockedNoSigningKey, err := noSigningKey.Unlock([]byte("golang"))
+	if err != nil {
+		t.Fatal("Expected no error while unlocking private key, got:", err)
+	}
+	noSigningKeyRing, _ := NewKeyRing(unlockedNoSigningKey)
+	_, err = noSigningKeyRing.SignDetached(message)
+	assert.EqualError(t, err, "gopenpgp: cannot sign message, the secret signing key is missing (gnu-dummy)")
+
+	_ = noSigningKeyRing.AddKey(unlockedDummyKey)
+	signature, err = noSigningKeyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	assert.Nil(t, dummyKeyRing.VerifyDetached(message, signature, GetUnixTime()))
+}
+
 func TestSignedMessageDecryption(t *testing.T) {
 	pgpMessage, err := NewPGPMessageFromArmored(readTestFile("message_signed", false))
 	if err != nil {
@@ -390,6 +450,36 @@ func TestMessageGetSignatureKeyIDs(t *testing.T) {
 	assert.Exactly(t, signingKey.PublicKey.KeyId, ids[0])
 }
 
+func TestSignatureGetSignerKeyID(t *testing.T) {
+	signature, err := keyRingTestPrivate.SignDetached(NewPlainMessageFromString("plain text"))
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	signingKey, ok := keyRingTestPrivate.entities[0].SigningKey(time.Now())
+	assert.True(t, ok)
+
+	keyID, ok := signature.GetSignerKeyID()
+	assert.True(t, ok)
+	assert.Exactly(t, signingKey.PublicKey.KeyId, keyID)
+	hexKeyID, ok := signature.GetHexSignerKeyID()
+	assert.True(t, ok)
+	assert.Exactly(t, keyIDToHex(signingKey.PublicKey.KeyId), hexKeyID)
+
+	// Self-signatures of generated keys carry the issuer fingerprint
+	serialized, err := keyTestEC.Serialize()
+	if err != nil {
+		t.Fatal("Expected no error when serializing key, got:", err)
+	}
+	fingerprint, ok := NewPGPSignature(serialized).GetHexSignerFingerprint()
+	assert.True(t, ok)
+	assert.Exactly(t, keyTestEC.GetFingerprint(), fingerprint)
+
+	_, ok = NewPGPSignature([]byte("not a signature")).GetSignerKeyID()
+	assert.False(t, ok)
+	_, ok = NewPGPSignature(nil).GetSignerFingerprint()
+	assert.False(t, ok)
+}
+
 func TestMessageGetHexSignatureKeyIDs(t *testing.T) {
 	ciphertext, err := NewPGPMessageFromArmored(readTestFile("message_plainSignature", false))
 	if err != nil {
@@ -440,6 +530,93 @@ func TestMessageGetArmoredWithEmptyHeaders(t *testing.T) {
 	assert.NotContains(t, armored, "Comment")
 }
 
+func TestMessageGetArmoredWithOptions(t *testing.T) {
+	var message = NewPlainMessageFromString("plain text")
+
+	ciphertext, err := keyRingTestPublic.Encrypt(message, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	armored, err := ciphertext.GetArmoredWithOptions(&armor.Options{
+		OmitVersion: true,
+		Comment:     "User-defined comment",
+		Headers:     map[string]string{"Charset": "utf-8"},
+	})
+	if err != nil {
+		t.Fatal("Could not armor the ciphertext:", err)
+	}
+
+	assert.NotContains(t, armored, "Version")
+	assert.Contains(t, armored, "Comment: User-defined comment")
+	assert.Contains(t, armored, "Charset: utf-8")
+
+	decoded, err := NewPGPMessageFromArmored(armored)
+	if err != nil {
+		t.Fatal("Could not unarmor the ciphertext:", err)
+	}
+	assert.Exactly(t, ciphertext.GetBinary(), decoded.GetBinary())
+
+	defaultArmored, err := ciphertext.GetArmoredWithOptions(nil)
+	if err != nil {
+		t.Fatal("Could not armor the ciphertext:", err)
+	}
+	assert.Contains(t, defaultArmored, "Version: "+constants.ArmorHeaderVersion)
+
+	_, err = ciphertext.GetArmoredWithOptions(&armor.Options{Headers: map[string]string{"Bad": "a\nb"}})
+	assert.Error(t, err)
+}
+
+func TestMessageArmorTo(t *testing.T) {
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("plain text"), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	signature, err := keyRingTestPrivate.SignDetached(NewPlainMessageFromString("plain text"))
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, ciphertext.ArmorTo(&buf))
+	armored, err := ciphertext.GetArmored()
+	assert.Nil(t, err)
+	assert.Exactly(t, armored, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, split.ArmorTo(&buf))
+	assert.Exactly(t, armored, buf.String())
+
+	buf.Reset()
+	options := &armor.Options{OmitVersion: true, CRLF: true}
+	assert.Nil(t, signature.ArmorToWithOptions(&buf, options))
+	armored, err = signature.GetArmoredWithOptions(options)
+	assert.Nil(t, err)
+	assert.Exactly(t, armored, buf.String())
+
+	assert.Error(t, ciphertext.ArmorToWithOptions(&buf, &armor.Options{LineLength: 100}))
+
+	buf.Reset()
+	n, err := ciphertext.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Exactly(t, int64(len(ciphertext.GetBinary())), n)
+	assert.Exactly(t, ciphertext.GetBinary(), buf.Bytes())
+
+	buf.Reset()
+	n, err = split.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Exactly(t, int64(len(ciphertext.GetBinary())), n)
+	assert.Exactly(t, ciphertext.GetBinary(), buf.Bytes())
+
+	buf.Reset()
+	_, err = signature.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Exactly(t, signature.GetBinary(), buf.Bytes())
+}
+
 func TestPGPSplitMessageFromArmoredWithAEAD(t *testing.T) {
 	var message = `-----BEGIN PGP MESSAGE-----
 
@@ -462,3 +639,220 @@ vA==
 		t.Error("Data packet was nil")
 	}
 }
+
+func TestPGPSplitMessageWithPartialLengths(t *testing.T) {
+	var ciphertext bytes.Buffer
+	messageWriter, err := keyRingTestPublic.EncryptStream(&ciphertext, nil, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	data := bytes.Repeat([]byte("partial body length "), 10000)
+	if _, err = messageWriter.Write(data); err != nil {
+		t.Fatal("Expected no error when writing, got:", err)
+	}
+	if err = messageWriter.Close(); err != nil {
+		t.Fatal("Expected no error when closing, got:", err)
+	}
+
+	split, err := NewPGPMessage(ciphertext.Bytes()).SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	assert.Exactly(t, ciphertext.Bytes(), append(split.GetBinaryKeyPacket(), split.GetBinaryDataPacket()...))
+	assert.Exactly(t, byte(0xc0|packetTagSymmetricallyEncryptedMDC), split.GetBinaryDataPacket()[0])
+
+	sessionKey, err := keyRingTestPrivate.DecryptSessionKey(split.GetBinaryKeyPacket())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting the session key, got:", err)
+	}
+	decrypted, err := sessionKey.Decrypt(split.GetBinaryDataPacket())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, data, decrypted.GetBinary())
+}
+
+func TestSkipRawPacketWithPartialLengths(t *testing.T) {
+	// Partial lengths of 2 and 1 bytes, then a definite length of 3 bytes,
+	// followed by a marker packet
+	packets := []byte{0xd2, 0xe1, 1, 2, 0xe0, 3, 3, 4, 5, 6, 0xca, 3, 'P', 'G', 'P'}
+
+	tag, rest, err := skipRawPacket(packets)
+	if err != nil {
+		t.Fatal("Expected no error when skipping packet, got:", err)
+	}
+	assert.Exactly(t, packetTagSymmetricallyEncryptedMDC, tag)
+	assert.Exactly(t, packets[10:], rest)
+
+	_, _, err = skipRawPacket(packets[:5])
+	assert.Error(t, err)
+}
+
+func TestMessageWithMarkerAndPadding(t *testing.T) {
+	message := NewPlainMessageFromString("marker and padding")
+	ciphertext, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	marker := []byte{0xc0 | packetTagMarker, 3, 'P', 'G', 'P'}
+	padding, err := newPaddingPacket(32)
+	if err != nil {
+		t.Fatal("Expected no error when generating padding, got:", err)
+	}
+	padded := NewPGPMessage(joinPackets(marker, joinPackets(ciphertext.GetBinary(), padding)))
+
+	decrypted, err := keyRingTestPrivate.Decrypt(padded, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	ids, ok := padded.GetEncryptionKeyIDs()
+	assert.True(t, ok)
+	expectedIDs, _ := ciphertext.GetEncryptionKeyIDs()
+	assert.Exactly(t, expectedIDs, ids)
+	assert.Exactly(t, ContentMessage, DetectContent(padded.GetBinary()))
+
+	paddingSize, err := padded.GetPaddingSize()
+	if err != nil {
+		t.Fatal("Expected no error when reading padding, got:", err)
+	}
+	assert.Exactly(t, 32, paddingSize)
+}
+
+func TestMessageAddPadding(t *testing.T) {
+	message := NewPlainMessageFromString("padded")
+	ciphertext, err := keyRingTestPublic.Encrypt(message, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	size := len(ciphertext.GetBinary())
+
+	if err = ciphertext.AddPadding(1000); err != nil {
+		t.Fatal("Expected no error when adding padding, got:", err)
+	}
+	assert.Len(t, ciphertext.GetBinary(), size+6+1000)
+	assert.Error(t, ciphertext.AddPadding(-1))
+
+	paddingSize, err := ciphertext.GetPaddingSize()
+	if err != nil {
+		t.Fatal("Expected no error when reading padding, got:", err)
+	}
+	assert.Exactly(t, 1000, paddingSize)
+
+	decrypted, err := keyRingTestPrivate.Decrypt(ciphertext, keyRingTestPublic, GetUnixTime())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	split, err := ciphertext.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	decrypted, err = keyRingTestPrivate.Decrypt(split.GetPGPMessage(), nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting split message, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+}
+
+func TestNewPGPMessageAuto(t *testing.T) {
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("plain text"), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	armored, err := ciphertext.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+
+	fromBinary, err := NewPGPMessageAuto(ciphertext.GetBinary())
+	if err != nil {
+		t.Fatal("Expected no error when reading binary message, got:", err)
+	}
+	assert.Exactly(t, ciphertext.GetBinary(), fromBinary.GetBinary())
+
+	fromArmored, err := NewPGPMessageAuto([]byte(armored))
+	if err != nil {
+		t.Fatal("Expected no error when reading armored message, got:", err)
+	}
+	assert.Exactly(t, ciphertext.GetBinary(), fromArmored.GetBinary())
+
+	_, err = NewPGPMessageAuto([]byte("not a message"))
+	assert.Error(t, err)
+
+	signature, err := keyRingTestPrivate.SignDetached(NewPlainMessageFromString("plain text"))
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	_, err = NewPGPMessageAuto(signature.GetBinary())
+	assert.Error(t, err)
+}
+
+func TestPGPMessageAndSignatureBase64(t *testing.T) {
+	message := NewPlainMessageFromString("plain text")
+	ciphertext, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	signature, err := keyRingTestPrivate.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	decodedMessage, err := NewPGPMessageFromBase64(ciphertext.GetBase64())
+	if err != nil {
+		t.Fatal("Expected no error when decoding message, got:", err)
+	}
+	assert.Exactly(t, ciphertext.GetBinary(), decodedMessage.GetBinary())
+
+	decodedSignature, err := NewPGPSignatureFromBase64(signature.GetBase64())
+	if err != nil {
+		t.Fatal("Expected no error when decoding signature, got:", err)
+	}
+	assert.Exactly(t, signature.GetBinary(), decodedSignature.GetBinary())
+
+	_, err = NewPGPMessageFromBase64("not base64!")
+	assert.Error(t, err)
+	_, err = NewPGPSignatureFromBase64("not base64!")
+	assert.Error(t, err)
+}
+
+func TestPGPMessageAndSignatureFromReader(t *testing.T) {
+	message := NewPlainMessageFromString("plain text")
+	ciphertext, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	armoredMessage, err := ciphertext.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+	signature, err := keyRingTestPrivate.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	armoredSignature, err := signature.GetArmored()
+	if err != nil {
+		t.Fatal("Expected no error when armoring, got:", err)
+	}
+
+	fromReader, err := NewPGPMessageFromReader(ciphertext.NewReader())
+	assert.Nil(t, err)
+	assert.Exactly(t, ciphertext.GetBinary(), fromReader.GetBinary())
+	fromReader, err = NewPGPMessageFromArmoredReader(bytes.NewBufferString(armoredMessage))
+	assert.Nil(t, err)
+	assert.Exactly(t, ciphertext.GetBinary(), fromReader.GetBinary())
+
+	sigFromReader, err := NewPGPSignatureFromReader(bytes.NewReader(signature.GetBinary()))
+	assert.Nil(t, err)
+	assert.Exactly(t, signature.GetBinary(), sigFromReader.GetBinary())
+	sigFromReader, err = NewPGPSignatureFromArmoredReader(bytes.NewBufferString(armoredSignature))
+	assert.Nil(t, err)
+	assert.Exactly(t, signature.GetBinary(), sigFromReader.GetBinary())
+
+	_, err = NewPGPMessageFromArmoredReader(bytes.NewBufferString("not armored"))
+	assert.Error(t, err)
+}
@@ -100,7 +100,7 @@ func parseMIME(
 
 	var verifierEntities openpgp.KeyRing
 	if verifierKey != nil {
-		verifierEntities = verifierKey.entities
+		verifierEntities = verifierKey.getEntities()
 	}
 
 	signatureCollector := newSignatureCollector(mimeVisitor, verifierEntities, config)
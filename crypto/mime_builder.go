@@ -0,0 +1,246 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MIMEBuilder builds PGP/MIME messages (RFC 3156) from a body and attachments.
+type MIMEBuilder struct {
+	headers      textproto.MIMEHeader
+	body         string
+	bodyMIMEType string
+	attachments  []*mimeAttachment
+}
+
+type mimeAttachment struct {
+	fileName string
+	mimeType string
+	data     []byte
+}
+
+// NewMIMEBuilder creates a builder for a message with the given body, of type
+// mimeType, e.g. "text/plain" or "text/html".
+func NewMIMEBuilder(body, mimeType string) *MIMEBuilder {
+	return &MIMEBuilder{
+		headers:      make(textproto.MIMEHeader),
+		body:         body,
+		bodyMIMEType: mimeType,
+	}
+}
+
+// addressHeaders lists the headers whose values are address lists.
+var addressHeaders = map[string]bool{
+	"From":                        true,
+	"Sender":                      true,
+	"Reply-To":                    true,
+	"To":                          true,
+	"Cc":                          true,
+	"Bcc":                         true,
+	"Resent-From":                 true,
+	"Resent-Sender":               true,
+	"Resent-To":                   true,
+	"Resent-Cc":                   true,
+	"Resent-Bcc":                  true,
+	"Disposition-Notification-To": true,
+}
+
+// SetHeader sets a header of the message, e.g. "From", "To" or "Subject".
+// Values that aren't ASCII are encoded as described in RFC 2047: only the
+// display names of the addresses of address headers are encoded, so that the
+// addresses remain readable.
+func (builder *MIMEBuilder) SetHeader(name, value string) *MIMEBuilder {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	if addressHeaders[name] {
+		if addresses, err := mail.ParseAddressList(value); err == nil {
+			formatted := make([]string, len(addresses))
+			for i, address := range addresses {
+				formatted[i] = address.String()
+			}
+			builder.headers.Set(name, strings.Join(formatted, ", "))
+			return builder
+		}
+	}
+	builder.headers.Set(name, mime.QEncoding.Encode("utf-8", value))
+	return builder
+}
+
+// AddAttachment adds an attachment to the message.
+func (builder *MIMEBuilder) AddAttachment(fileName, mimeType string, data []byte) *MIMEBuilder {
+	builder.attachments = append(builder.attachments, &mimeAttachment{
+		fileName: fileName,
+		mimeType: mimeType,
+		data:     clone(data),
+	})
+	return builder
+}
+
+// BuildPlain returns the unencrypted MIME entity holding the body and the
+// attachments, which is encrypted by Build.
+func (builder *MIMEBuilder) BuildPlain() ([]byte, error) {
+	var buffer bytes.Buffer
+	if len(builder.attachments) == 0 {
+		header := builder.bodyHeader()
+		writeMIMEHeader(&buffer, header)
+		if err := writeQuotedPrintable(&buffer, builder.body); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&buffer)
+	writeMIMEHeader(&buffer, textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": writer.Boundary()})},
+	})
+
+	part, err := writer.CreatePart(builder.bodyHeader())
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME body")
+	}
+	if err := writeQuotedPrintable(part, builder.body); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range builder.attachments {
+		part, err := writer.CreatePart(attachment.header())
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in writing MIME attachment")
+		}
+		if err := writeBase64Lines(part, attachment.data); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in writing MIME attachment")
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+	return buffer.Bytes(), nil
+}
+
+// Build returns the complete multipart/encrypted MIME message, with its
+// headers, encrypted to encryptionKeyRing. If signKeyRing is not nil, the
+// message is also signed, with a signature embedded in the encrypted data
+// (RFC 3156, section 6.2).
+func (builder *MIMEBuilder) Build(encryptionKeyRing, signKeyRing *KeyRing) ([]byte, error) {
+	plain, err := builder.BuildPlain()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptionKeyRing.Encrypt(NewPlainMessage(plain), signKeyRing)
+	if err != nil {
+		return nil, err
+	}
+	armored, err := encrypted.GetArmored()
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	header := make(textproto.MIMEHeader)
+	for name, values := range builder.headers {
+		header[name] = values
+	}
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", mime.FormatMediaType("multipart/encrypted", map[string]string{
+		"protocol": "application/pgp-encrypted",
+		"boundary": writer.Boundary(),
+	}))
+	writeMIMEHeader(&buffer, header)
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/pgp-encrypted"},
+		"Content-Description": {"PGP/MIME version identification"},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+	if _, err := io.WriteString(part, "Version: 1\r\n"); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+
+	part, err = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {`application/octet-stream; name="encrypted.asc"`},
+		"Content-Description": {"OpenPGP encrypted message"},
+		"Content-Disposition": {`inline; filename="encrypted.asc"`},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+	if _, err := io.WriteString(part, armored+"\r\n"); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in writing MIME message")
+	}
+	return buffer.Bytes(), nil
+}
+
+// --- Internal functions
+
+func (builder *MIMEBuilder) bodyHeader() textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(builder.bodyMIMEType, map[string]string{"charset": "utf-8"})},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	}
+}
+
+func (attachment *mimeAttachment) header() textproto.MIMEHeader {
+	params := map[string]string{"name": attachment.fileName}
+	return textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(attachment.mimeType, params)},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition": {
+			mime.FormatMediaType("attachment", map[string]string{"filename": attachment.fileName}),
+		},
+	}
+}
+
+// writeMIMEHeader writes the header, sorted by name, followed by a blank line.
+func writeMIMEHeader(w io.Writer, header textproto.MIMEHeader) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			_, _ = io.WriteString(w, name+": "+value+"\r\n")
+		}
+	}
+	_, _ = io.WriteString(w, "\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(encoder, text); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in writing MIME body")
+	}
+	return errors.Wrap(encoder.Close(), "gopenpgp: error in writing MIME body")
+}
+
+// writeBase64Lines writes data in base64, in lines of 76 characters.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+type builderCallbacks struct {
+	body        string
+	mimeType    string
+	attachments [][]byte
+	verified    int
+	err         error
+}
+
+func (c *builderCallbacks) OnBody(body string, mimetype string) {
+	c.body, c.mimeType = body, mimetype
+}
+
+func (c *builderCallbacks) OnAttachment(headers string, data []byte) {
+	c.attachments = append(c.attachments, data)
+}
+
+func (c *builderCallbacks) OnEncryptedHeaders(headers string) {}
+
+func (c *builderCallbacks) OnVerified(verified int) {
+	c.verified = verified
+}
+
+func (c *builderCallbacks) OnError(err error) {
+	c.err = err
+}
+
+func TestMIMEBuilder(t *testing.T) {
+	attachment := []byte(strings.Repeat("attachment data ", 20))
+	built, err := NewMIMEBuilder("Hello, wörld!", "text/plain").
+		SetHeader("Subject", "Grüße").
+		SetHeader("To", "<"+keyTestDomain+">").
+		SetHeader("From", "Jürgen Müller <juergen@example.com>, bob@example.com").
+		AddAttachment("data.bin", "application/octet-stream", attachment).
+		Build(keyRingTestPublic, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Cannot build MIME message:", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(built)))
+	if err != nil {
+		t.Fatal("Cannot parse MIME message:", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.Nil(t, err)
+	assert.Exactly(t, "Grüße", subject)
+
+	// Only the display names of addresses are encoded
+	assert.Exactly(t, "<"+keyTestDomain+">", msg.Header.Get("To"))
+	assert.Exactly(t, "=?utf-8?q?J=C3=BCrgen_M=C3=BCller?= <juergen@example.com>, <bob@example.com>", msg.Header.Get("From"))
+	from, err := msg.Header.AddressList("From")
+	if err != nil {
+		t.Fatal("Cannot parse From header:", err)
+	}
+	assert.Exactly(t, "Jürgen Müller", from[0].Name)
+	assert.Exactly(t, "juergen@example.com", from[0].Address)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal("Cannot parse content type:", err)
+	}
+	assert.Exactly(t, "multipart/encrypted", mediaType)
+	assert.Exactly(t, "application/pgp-encrypted", params["protocol"])
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatal("Cannot read version part:", err)
+	}
+	assert.Exactly(t, "application/pgp-encrypted", part.Header.Get("Content-Type"))
+	version, _ := ioutil.ReadAll(part)
+	assert.Exactly(t, "Version: 1\r\n", string(version))
+
+	part, err = reader.NextPart()
+	if err != nil {
+		t.Fatal("Cannot read encrypted part:", err)
+	}
+	armored, _ := ioutil.ReadAll(part)
+	message, err := NewPGPMessageFromArmored(string(armored))
+	if err != nil {
+		t.Fatal("Cannot unarmor message:", err)
+	}
+
+	callbacks := &builderCallbacks{verified: -1}
+	keyRingTestPrivate.DecryptMIMEMessage(message, keyRingTestPublic, callbacks, GetUnixTime())
+	assert.Nil(t, callbacks.err)
+	assert.Exactly(t, "Hello, wörld!", callbacks.body)
+	assert.Exactly(t, "text/plain", callbacks.mimeType)
+	assert.Exactly(t, [][]byte{attachment}, callbacks.attachments)
+	assert.Exactly(t, constants.SIGNATURE_OK, callbacks.verified)
+}
@@ -0,0 +1,332 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	gomime "github.com/ProtonMail/go-mime"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+	"github.com/pkg/errors"
+)
+
+// MIMEMessage is a decrypted and verified MIME message.
+type MIMEMessage struct {
+	// Headers of the outer MIME entity
+	Headers         textproto.MIMEHeader
+	Body            string
+	BodyContentType string
+	// BodySignature is the verdict of the signature covering the body, or nil
+	// if the body isn't in a signed part.
+	BodySignature *MIMESignature
+	Attachments   []*MIMEAttachment
+	// Signatures holds the verdicts of all the signed parts of the message.
+	Signatures []*MIMESignature
+}
+
+// MIMEAttachment is an attachment of a MIME message.
+type MIMEAttachment struct {
+	FileName    string
+	ContentType string
+	Headers     textproto.MIMEHeader
+	Data        []byte
+	// Signature is the verdict of the signature covering the attachment, or
+	// nil if the attachment isn't in a signed part.
+	Signature *MIMESignature
+}
+
+// MIMESignature is the verdict of the signature of a signed MIME part.
+type MIMESignature struct {
+	// ContentType of the signed part: "multipart/encrypted" for a signature
+	// embedded in the encrypted data, or the type of the first part of a
+	// multipart/signed entity.
+	ContentType string
+	// Status is one of the constants.SIGNATURE_* values.
+	Status int
+	// Error is nil if the signature is valid.
+	Error *SignatureVerificationError
+}
+
+// GetReader returns a reader over the data of the attachment.
+func (attachment *MIMEAttachment) GetReader() io.Reader {
+	return bytes.NewReader(attachment.Data)
+}
+
+// DecryptMIME parses a MIME message, decrypting its multipart/encrypted parts
+// with keyRing and verifying its signatures, embedded in the encrypted data or
+// in multipart/signed parts, with verifyKey, if not nil.
+func (keyRing *KeyRing) DecryptMIME(message []byte, verifyKey *KeyRing, verifyTime int64) (*MIMEMessage, error) {
+	parser := &mimeParser{
+		decryptionKeyRing: keyRing,
+		verifyKey:         verifyKey,
+		verifyTime:        verifyTime,
+	}
+	return parser.parse(message)
+}
+
+// VerifyMIME parses a MIME message and verifies its multipart/signed parts
+// with verifyKey. Messages with encrypted parts can't be parsed: see
+// KeyRing.DecryptMIME.
+func VerifyMIME(message []byte, verifyKey *KeyRing, verifyTime int64) (*MIMEMessage, error) {
+	parser := &mimeParser{
+		verifyKey:  verifyKey,
+		verifyTime: verifyTime,
+	}
+	return parser.parse(message)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+type mimeParser struct {
+	decryptionKeyRing *KeyRing
+	verifyKey         *KeyRing
+	verifyTime        int64
+	message           *MIMEMessage
+	hasBody           bool
+}
+
+func (parser *mimeParser) parse(message []byte) (*MIMEMessage, error) {
+	header, body, err := readMIMEEntity(message)
+	if err != nil {
+		return nil, err
+	}
+	parser.message = &MIMEMessage{Headers: header}
+	if err := parser.visit(body, header, nil); err != nil {
+		return nil, err
+	}
+	return parser.message, nil
+}
+
+// visit adds the entity to the message, signature being the verdict of the
+// signed part holding it, if any.
+func (parser *mimeParser) visit(body []byte, header textproto.MIMEHeader, signature *MIMESignature) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	switch {
+	case mediaType == "multipart/encrypted":
+		return parser.visitEncrypted(body, params, signature)
+	case mediaType == "multipart/signed":
+		return parser.visitSigned(body, params, signature)
+	case strings.HasPrefix(mediaType, "multipart/"):
+		parts, headers, err := gomime.GetMultipartParts(bytes.NewReader(body), params)
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: error in parsing multipart entity")
+		}
+		if mediaType == "multipart/alternative" {
+			if i := pickAlternative(headers); i >= 0 {
+				parts, headers = parts[i:i+1], headers[i:i+1]
+			}
+		}
+		for i, part := range parts {
+			data, err := ioutil.ReadAll(part)
+			if err != nil {
+				return errors.Wrap(err, "gopenpgp: error in reading part data")
+			}
+			if err := parser.visit(data, headers[i], signature); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return parser.visitLeaf(body, header, mediaType, params, signature)
+	}
+}
+
+func (parser *mimeParser) visitEncrypted(body []byte, params map[string]string, signature *MIMESignature) error {
+	if parser.decryptionKeyRing == nil {
+		return errors.New("gopenpgp: no decryption key ring provided")
+	}
+	parts, headers, err := gomime.GetMultipartParts(bytes.NewReader(body), params)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing encrypted entity")
+	}
+	if len(parts) != 2 {
+		return errors.New("gopenpgp: invalid multipart/encrypted entity")
+	}
+	armored, err := decodePart(parts[1], headers[1])
+	if err != nil {
+		return err
+	}
+	message, err := NewPGPMessageFromArmored(string(armored))
+	if err != nil {
+		return err
+	}
+
+	decrypted, err := parser.decryptionKeyRing.Decrypt(message, parser.verifyKey, parser.verifyTime)
+	sigErr, err := separateSigError(err)
+	if err != nil {
+		return err
+	}
+	// Without a verification key, or without an embedded signature, the
+	// decrypted entity may still be signed with multipart/signed.
+	if parser.verifyKey != nil && (sigErr == nil || sigErr.Status != constants.SIGNATURE_NOT_SIGNED) {
+		signature = parser.addSignature("multipart/encrypted", sigErr)
+	}
+
+	header, decryptedBody, err := readMIMEEntity(decrypted.GetBinary())
+	if err != nil {
+		return err
+	}
+	return parser.visit(decryptedBody, header, signature)
+}
+
+func (parser *mimeParser) visitSigned(body []byte, params map[string]string, signature *MIMESignature) error {
+	newPart, rawBody := gomime.GetRawMimePart(bytes.NewReader(body), "--"+params["boundary"])
+	parts, headers, err := gomime.GetMultipartParts(newPart, params)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in parsing signed entity")
+	}
+	if len(parts) != 2 {
+		// Invalid multipart/signed format, parsed as multipart/mixed
+		for i, part := range parts {
+			data, err := ioutil.ReadAll(part)
+			if err != nil {
+				return errors.Wrap(err, "gopenpgp: error in reading part data")
+			}
+			if err := parser.visit(data, headers[i], signature); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	signedData, err := ioutil.ReadAll(rawBody)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in reading raw message body")
+	}
+	armoredSignature, err := decodePart(parts[1], headers[1])
+	if err != nil {
+		return err
+	}
+
+	var sigErr *SignatureVerificationError
+	if parser.verifyKey == nil {
+		noVerifier := newSignatureNoVerifier()
+		sigErr = &noVerifier
+	} else {
+		detached, err := NewPGPSignatureFromArmored(string(armoredSignature))
+		if err != nil {
+			failed := newSignatureFailed()
+			sigErr = &failed
+		} else {
+			canonicalized := internal.CanonicalizeAndTrim(string(signedData))
+			err = parser.verifyKey.VerifyDetached(NewPlainMessageFromString(canonicalized), detached, parser.verifyTime)
+			if sigErr, err = separateSigError(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	contentType, _, err := mime.ParseMediaType(headers[0].Get("Content-Type"))
+	if err != nil {
+		contentType = "text/plain"
+	}
+	signature = parser.addSignature(contentType, sigErr)
+
+	data, err := ioutil.ReadAll(parts[0])
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in reading part data")
+	}
+	return parser.visit(data, headers[0], signature)
+}
+
+func (parser *mimeParser) visitLeaf(
+	body []byte, header textproto.MIMEHeader, mediaType string, params map[string]string, signature *MIMESignature,
+) error {
+	data, err := decodePart(bytes.NewReader(body), header)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	fileName := dispositionParams["filename"]
+	if fileName == "" {
+		fileName = params["name"]
+	}
+	if decoded, err := gomime.DecodeHeader(fileName); err == nil {
+		fileName = decoded
+	}
+
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	if !parser.hasBody && isText && disposition != "attachment" && fileName == "" {
+		decoded, err := gomime.DecodeCharset(data, mediaType, params)
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: error in decoding charset")
+		}
+		parser.hasBody = true
+		parser.message.Body = string(decoded)
+		parser.message.BodyContentType = mediaType
+		parser.message.BodySignature = signature
+		return nil
+	}
+
+	parser.message.Attachments = append(parser.message.Attachments, &MIMEAttachment{
+		FileName:    fileName,
+		ContentType: mediaType,
+		Headers:     header,
+		Data:        data,
+		Signature:   signature,
+	})
+	return nil
+}
+
+func (parser *mimeParser) addSignature(contentType string, sigErr *SignatureVerificationError) *MIMESignature {
+	signature := &MIMESignature{
+		ContentType: contentType,
+		Status:      constants.SIGNATURE_OK,
+		Error:       sigErr,
+	}
+	if sigErr != nil {
+		signature.Status = sigErr.Status
+	}
+	parser.message.Signatures = append(parser.message.Signatures, signature)
+	return signature
+}
+
+// readMIMEEntity splits a MIME entity into its header and body.
+func readMIMEEntity(data []byte) (textproto.MIMEHeader, []byte, error) {
+	entity, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "gopenpgp: error in reading message")
+	}
+	body, err := ioutil.ReadAll(entity.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "gopenpgp: error in reading message body data")
+	}
+	return textproto.MIMEHeader(entity.Header), body, nil
+}
+
+// decodePart returns the data of a part, decoding its transfer encoding.
+func decodePart(part io.Reader, header textproto.MIMEHeader) ([]byte, error) {
+	decoder := gomime.DecodeContentEncoding(part, header.Get("Content-Transfer-Encoding"))
+	if decoder == nil {
+		return nil, errors.New("gopenpgp: unsupported content transfer encoding")
+	}
+	data, err := ioutil.ReadAll(decoder)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading decoded data")
+	}
+	return data, nil
+}
+
+// pickAlternative returns the index of the preferred part of a
+// multipart/alternative entity, or -1 if none is known.
+func pickAlternative(headers []textproto.MIMEHeader) int {
+	for _, preferred := range []string{"multipart/", "text/html", "text/plain"} {
+		for i, header := range headers {
+			mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+			if err == nil && strings.HasPrefix(mediaType, preferred) {
+				return i
+			}
+		}
+	}
+	return -1
+}
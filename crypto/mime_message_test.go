@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestDecryptMIME(t *testing.T) {
+	attachment := []byte("attachment data")
+	built, err := NewMIMEBuilder("Hello!", "text/plain").
+		AddAttachment("data.bin", "application/octet-stream", attachment).
+		Build(keyRingTestPublic, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Cannot build MIME message:", err)
+	}
+
+	message, err := keyRingTestPrivate.DecryptMIME(built, keyRingTestPublic, GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot decrypt MIME message:", err)
+	}
+	assert.Exactly(t, "Hello!", message.Body)
+	assert.Exactly(t, "text/plain", message.BodyContentType)
+	if assert.Len(t, message.Signatures, 1) {
+		assert.Exactly(t, "multipart/encrypted", message.Signatures[0].ContentType)
+		assert.Exactly(t, constants.SIGNATURE_OK, message.Signatures[0].Status)
+	}
+	assert.Exactly(t, message.Signatures[0], message.BodySignature)
+	if assert.Len(t, message.Attachments, 1) {
+		assert.Exactly(t, "data.bin", message.Attachments[0].FileName)
+		assert.Exactly(t, "application/octet-stream", message.Attachments[0].ContentType)
+		assert.Exactly(t, attachment, message.Attachments[0].Data)
+		assert.Exactly(t, message.Signatures[0], message.Attachments[0].Signature)
+	}
+
+	_, err = VerifyMIME(built, keyRingTestPublic, GetUnixTime())
+	assert.NotNil(t, err)
+}
+
+func TestVerifyMIME(t *testing.T) {
+	signedPart := "Content-Type: text/plain; charset=utf-8\r\n\r\nSigned text\r\n"
+	signature, err := keyRingTestPrivate.SignDetached(NewPlainMessageFromString(signedPart[:len(signedPart)-2]))
+	if err != nil {
+		t.Fatal("Cannot sign part:", err)
+	}
+	armoredSignature, err := signature.GetArmored()
+	if err != nil {
+		t.Fatal("Cannot armor signature:", err)
+	}
+
+	message := "MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/signed; micalg=pgp-sha256; protocol=\"application/pgp-signature\"; boundary=\"b\"\r\n" +
+		"\r\n" +
+		"--b\r\n" + signedPart +
+		"--b\r\n" +
+		"Content-Type: application/pgp-signature\r\n\r\n" + armoredSignature + "\r\n" +
+		"--b--\r\n"
+
+	verified, err := VerifyMIME([]byte(message), keyRingTestPublic, GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot verify MIME message:", err)
+	}
+	assert.Exactly(t, "Signed text", verified.Body)
+	if assert.NotNil(t, verified.BodySignature) {
+		assert.Exactly(t, "text/plain", verified.BodySignature.ContentType)
+		assert.Exactly(t, constants.SIGNATURE_OK, verified.BodySignature.Status)
+		assert.Nil(t, verified.BodySignature.Error)
+	}
+	assert.Empty(t, verified.Attachments)
+
+	tampered := strings.Replace(message, "Signed text", "Other text", 1)
+	verified, err = VerifyMIME([]byte(tampered), keyRingTestPublic, GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot verify MIME message:", err)
+	}
+	assert.Exactly(t, constants.SIGNATURE_FAILED, verified.BodySignature.Status)
+
+	verified, err = VerifyMIME([]byte(message), nil, GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot verify MIME message:", err)
+	}
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, verified.BodySignature.Status)
+}
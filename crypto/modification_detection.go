@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	goerrors "errors"
+	"fmt"
+	"io"
+
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// ModificationDetectionError is returned when reading the plaintext of a
+// message whose integrity check (MDC) failed: the message was either
+// truncated before its end, or modified. The plaintext read before the
+// failure must be discarded, as it may have been modified too.
+type ModificationDetectionError struct {
+	// Whether the message ended before its integrity check, instead of
+	// failing it
+	Truncated bool
+	// Number of plaintext bytes returned by the reader of a streamed
+	// decryption before the failure, 0 for the other decryption functions,
+	// which return no plaintext
+	ReleasedBytes int64
+	cause         error
+}
+
+// Error is the base method for all errors.
+func (e ModificationDetectionError) Error() string {
+	message := "gopenpgp: message modified, integrity check failed"
+	if e.Truncated {
+		message = "gopenpgp: message truncated before its integrity check"
+	}
+	if e.ReleasedBytes > 0 {
+		message += fmt.Sprintf(" (%d plaintext bytes released)", e.ReleasedBytes)
+	}
+	return message
+}
+
+// Unwrap returns the go-crypto error of the failure.
+func (e ModificationDetectionError) Unwrap() error {
+	return e.cause
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// endReader records whether the end of the encrypted message was reached.
+type endReader struct {
+	reader io.Reader
+	ended  bool
+}
+
+func (r *endReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	if goerrors.Is(err, io.EOF) {
+		r.ended = true
+	}
+	return n, err
+}
+
+// modificationCheckReader reads the plaintext of a message read from input,
+// returning the failures of its integrity check as
+// ModificationDetectionErrors. go-crypto reports the truncation of a message
+// as a parsing error, so the failures once input is read entirely are
+// truncations.
+type modificationCheckReader struct {
+	body  io.Reader
+	input *endReader
+}
+
+func (r *modificationCheckReader) Read(b []byte) (int, error) {
+	n, err := r.body.Read(b)
+	if err == nil || goerrors.Is(err, io.EOF) {
+		return n, err
+	}
+	switch {
+	case goerrors.Is(err, pgpErrors.ErrMDCHashMismatch):
+		err = ModificationDetectionError{cause: err}
+	case r.input.ended:
+		err = ModificationDetectionError{Truncated: true, cause: err}
+	}
+	return n, err
+}
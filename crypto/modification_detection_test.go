@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModificationDetectionError(t *testing.T) {
+	data := bytes.Repeat([]byte("modification detection "), 1000)
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessage(data), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	encrypted := ciphertext.GetBinary()
+
+	var mdcErr ModificationDetectionError
+	truncated := NewPGPMessage(encrypted[:len(encrypted)-10])
+	_, err = keyRingTestPrivate.Decrypt(truncated, nil, 0)
+	if !errors.As(err, &mdcErr) {
+		t.Fatal("Expected a modification detection error, got:", err)
+	}
+	assert.True(t, mdcErr.Truncated)
+	assert.Exactly(t, int64(0), mdcErr.ReleasedBytes)
+
+	tampered := NewPGPMessage(clone(encrypted))
+	tampered.Data[len(encrypted)-1000] ^= 1
+	_, err = keyRingTestPrivate.Decrypt(tampered, nil, 0)
+	if !errors.As(err, &mdcErr) {
+		t.Fatal("Expected a modification detection error, got:", err)
+	}
+	assert.False(t, mdcErr.Truncated)
+	assert.True(t, errors.Is(err, pgpErrors.ErrMDCHashMismatch))
+
+	split, err := truncated.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	sessionKey, err := keyRingTestPrivate.DecryptSessionKey(split.GetBinaryKeyPacket())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting session key, got:", err)
+	}
+	_, err = sessionKey.Decrypt(split.GetBinaryDataPacket())
+	if !errors.As(err, &mdcErr) {
+		t.Fatal("Expected a modification detection error, got:", err)
+	}
+	assert.True(t, mdcErr.Truncated)
+}
+
+func TestModificationDetectionErrorStream(t *testing.T) {
+	data := bytes.Repeat([]byte("modification detection "), 1000)
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessage(data), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	encrypted := ciphertext.GetBinary()
+
+	reader, err := keyRingTestPrivate.DecryptStream(bytes.NewReader(encrypted[:len(encrypted)-10]), nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when starting decryption, got:", err)
+	}
+	released, err := io.Copy(ioutil.Discard, reader)
+	var mdcErr ModificationDetectionError
+	if !errors.As(err, &mdcErr) {
+		t.Fatal("Expected a modification detection error, got:", err)
+	}
+	assert.True(t, mdcErr.Truncated)
+	assert.True(t, released > 0)
+	assert.Exactly(t, released, mdcErr.ReleasedBytes)
+}
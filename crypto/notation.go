@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// notationDataSubpacket is the Notation Data signature subpacket type, see
+// RFC 4880, section 5.2.3.16.
+const notationDataSubpacket = 20
+
+// notationHumanReadable is the flag of human-readable notation values, in
+// the first of the four flag octets.
+const notationHumanReadable = 0x80
+
+// Notation is a named notation of a signature, e.g. a policy URL or an
+// application-level tag. Names not defined by the IETF are of the form
+// "name@domain".
+type Notation struct {
+	Name  string
+	Value []byte
+	// IsHumanReadable flags the value as UTF-8 text
+	IsHumanReadable bool
+	// IsCritical makes the signature invalid for verifiers that don't know
+	// the notation. Signatures with critical notations can't be verified
+	// by this library.
+	IsCritical bool
+}
+
+// SignDetachedWithNotations generates and returns a PGPSignature for a given
+// PlainMessage like SignDetached, with the given notations in the hashed
+// area of the signature.
+func (keyRing *KeyRing) SignDetachedWithNotations(message *PlainMessage, notations []*Notation) (*PGPSignature, error) {
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	subpackets, err := serializeNotations(notations)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signDetachedWithSubpackets(signEntity, message.NewReader(), message.IsText(), subpackets)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// GetNotations returns the notations in the hashed area of the signature
+// packets, without verifying the signature.
+func (sig *PGPSignature) GetNotations() ([]*Notation, error) {
+	return getNotations(sig.Data)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// serializeNotations returns the notation data subpackets of notations.
+func serializeNotations(notations []*Notation) ([]byte, error) {
+	var subpackets []byte
+	for _, notation := range notations {
+		if notation.Name == "" {
+			return nil, errors.New("gopenpgp: empty notation name")
+		}
+		if len(notation.Name) > 0xffff || len(notation.Value) > 0xffff {
+			return nil, errors.New("gopenpgp: notation too long")
+		}
+		contents := make([]byte, 8, 8+len(notation.Name)+len(notation.Value))
+		if notation.IsHumanReadable {
+			contents[0] = notationHumanReadable
+		}
+		binary.BigEndian.PutUint16(contents[4:6], uint16(len(notation.Name)))
+		binary.BigEndian.PutUint16(contents[6:8], uint16(len(notation.Value)))
+		contents = append(contents, notation.Name...)
+		contents = append(contents, notation.Value...)
+		subpackets = append(subpackets, serializeSubpacket(notationDataSubpacket, notation.IsCritical, contents)...)
+	}
+	return subpackets, nil
+}
+
+// getNotations returns the notations in the hashed area of the signature
+// packets in data. The signatures aren't parsed by go-crypto, which rejects
+// signatures with critical notations.
+func getNotations(data []byte) ([]*Notation, error) {
+	var notations []*Notation
+	var parseErr error
+	err := forEachSignatureSubpacket(data, func(subpacket []byte, isHashed bool) {
+		if !isHashed || parseErr != nil || int(subpacket[0]&0x7f) != notationDataSubpacket {
+			return
+		}
+		var notation *Notation
+		notation, parseErr = parseNotation(subpacket[1:], subpacket[0]&0x80 != 0)
+		notations = append(notations, notation)
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading notations")
+	}
+	return notations, nil
+}
+
+// parseNotation parses the contents of a notation data subpacket.
+func parseNotation(contents []byte, isCritical bool) (*Notation, error) {
+	if len(contents) < 8 {
+		return nil, errors.New("gopenpgp: truncated notation")
+	}
+	nameLen := int(binary.BigEndian.Uint16(contents[4:6]))
+	valueLen := int(binary.BigEndian.Uint16(contents[6:8]))
+	if len(contents) != 8+nameLen+valueLen {
+		return nil, errors.New("gopenpgp: invalid notation length")
+	}
+	return &Notation{
+		Name:            string(contents[8 : 8+nameLen]),
+		Value:           clone(contents[8+nameLen:]),
+		IsHumanReadable: contents[0]&notationHumanReadable != 0,
+		IsCritical:      isCritical,
+	}, nil
+}
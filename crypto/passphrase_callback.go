@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+// PassphraseCallback returns the passphrase of the locked private key, or
+// subkey, with the given hex key ID. It is only called when the key is
+// needed, e.g. to prompt the user for it.
+type PassphraseCallback func(keyID string) ([]byte, error)
+
+// NewLockedKeyRing creates a new KeyRing with a copy of a private key, locked
+// or not, whose locked parts are unlocked on demand by
+// DecryptWithPassphraseCallback.
+func NewLockedKeyRing(key *Key) (*KeyRing, error) {
+	keyRing := &KeyRing{}
+	if err := keyRing.AddLockedKey(key); err != nil {
+		return nil, err
+	}
+	return keyRing, nil
+}
+
+// AddLockedKey adds a copy of a private key, locked or not, to the keyring.
+// Its locked parts are unlocked on demand by DecryptWithPassphraseCallback,
+// and stay unlocked in the keyring until it is locked again with Lock.
+func (keyRing *KeyRing) AddLockedKey(key *Key) error {
+	if !key.IsPrivate() {
+		return errors.New("gopenpgp: a public key cannot be locked")
+	}
+	keyCopy, err := key.Copy()
+	if err != nil {
+		return err
+	}
+	if err := keyRing.saveLockedKey(keyCopy); err != nil {
+		return err
+	}
+	keyRing.appendKey(keyCopy)
+	return nil
+}
+
+// DecryptWithPassphraseCallback decrypts a PGPMessage like Decrypt, calling
+// callback for the passphrase of the locked private key able to decrypt it,
+// if no unlocked private key of the keyring can.
+// The unlocked key stays unlocked in the keyring.
+func (keyRing *KeyRing) DecryptWithPassphraseCallback(
+	message *PGPMessage, verifyKey *KeyRing, verifyTime int64, callback PassphraseCallback,
+) (*PlainMessage, error) {
+	defer keyRing.use()()
+
+	// The keys are unlocked in copies, as the keyring may be in use, which
+	// replace the keys in the keyring once unlocked
+	entities := keyRing.getEntities()
+	candidates := &KeyRing{entities: make(openpgp.EntityList, len(entities))}
+	for i, entity := range entities {
+		candidates.entities[i] = copyLockedPackets(entity)
+	}
+	plainMessage, err := asymmetricDecrypt(
+		message, candidates, verifyKey, verifyTime, internal.CreationTimeOffset, newPassphrasePrompt(callback),
+	)
+
+	unlocked := make(map[*openpgp.Entity]*openpgp.Entity)
+	for i, entity := range entities {
+		if countUnlockedParts(candidates.entities[i]) > countUnlockedParts(entity) {
+			unlocked[entity] = candidates.entities[i]
+		}
+	}
+	if len(unlocked) > 0 {
+		keyRing.replaceEntities(unlocked)
+	}
+	return plainMessage, err
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// newPassphrasePrompt returns a prompt function unlocking the candidate keys
+// of a message with the passphrases returned by callback. A nil passphrase
+// skips a key.
+func newPassphrasePrompt(callback PassphraseCallback) openpgp.PromptFunction {
+	return func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		unlocked := 0
+		for _, key := range keys {
+			if key.PrivateKey == nil || !key.PrivateKey.Encrypted {
+				continue
+			}
+			passphrase, err := callback(keyIDToHex(key.PublicKey.KeyId))
+			if err != nil {
+				return nil, err
+			}
+			if passphrase == nil {
+				continue
+			}
+			if err := key.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: error in unlocking key")
+			}
+			unlocked++
+		}
+		// The prompt is called again as long as it returns no error
+		if unlocked == 0 {
+			return nil, errors.New("gopenpgp: error in reading message: no key unlocked")
+		}
+		return nil, nil
+	}
+}
+
+// copyLockedPackets returns a copy of entity with copies of its locked
+// private key packets, that can be unlocked in place, or entity itself if it
+// has none.
+func copyLockedPackets(entity *openpgp.Entity) *openpgp.Entity {
+	if countUnlockedParts(entity) == countPrivateParts(entity) {
+		return entity
+	}
+	entityCopy := *entity
+	entityCopy.PrivateKey = copyLockedPacket(entity.PrivateKey)
+	entityCopy.Subkeys = make([]openpgp.Subkey, len(entity.Subkeys))
+	for i, subkey := range entity.Subkeys {
+		subkey.PrivateKey = copyLockedPacket(subkey.PrivateKey)
+		entityCopy.Subkeys[i] = subkey
+	}
+	return &entityCopy
+}
+
+func copyLockedPacket(privateKey *packet.PrivateKey) *packet.PrivateKey {
+	if privateKey == nil || !privateKey.Encrypted {
+		return privateKey
+	}
+	privateKeyCopy := *privateKey
+	return &privateKeyCopy
+}
+
+// countPrivateParts returns the number of private keys, primary or subkeys,
+// of entity, locked or not.
+func countPrivateParts(entity *openpgp.Entity) int {
+	count := 0
+	if entity.PrivateKey != nil {
+		count++
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// countUnlockedParts returns the number of unlocked private keys, primary or
+// subkeys, of entity.
+func countUnlockedParts(entity *openpgp.Entity) int {
+	count := 0
+	if isUnlockedPrivateKey(entity.PrivateKey) {
+		count++
+	}
+	for _, subkey := range entity.Subkeys {
+		if isUnlockedPrivateKey(subkey.PrivateKey) {
+			count++
+		}
+	}
+	return count
+}
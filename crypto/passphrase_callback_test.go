@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptWithPassphraseCallback(t *testing.T) {
+	lockedKey, err := NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	ciphertext, err := keyRingTestPublic.Encrypt(NewPlainMessageFromString("lazy"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	keyIDs, ok := ciphertext.GetEncryptionKeyIDs()
+	assert.True(t, ok)
+
+	keyRing, err := NewLockedKeyRing(lockedKey)
+	if err != nil {
+		t.Fatal("Cannot create locked key ring:", err)
+	}
+
+	// Unlocked keys are required without callback
+	_, err = keyRing.Decrypt(ciphertext, nil, 0)
+	assert.NotNil(t, err)
+
+	errCanceled := errors.New("canceled")
+	_, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, func(string) ([]byte, error) {
+		return nil, errCanceled
+	})
+	assert.True(t, errors.Is(err, errCanceled))
+
+	_, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, func(string) ([]byte, error) {
+		return []byte("wrong"), nil
+	})
+	assert.NotNil(t, err)
+
+	_, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, func(string) ([]byte, error) {
+		return nil, nil
+	})
+	assert.NotNil(t, err)
+
+	var requested []string
+	callback := func(keyID string) ([]byte, error) {
+		requested = append(requested, keyID)
+		return testMailboxPassword, nil
+	}
+	decrypted, err := keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, callback)
+	if err != nil {
+		t.Fatal("Cannot decrypt with callback:", err)
+	}
+	assert.Exactly(t, "lazy", decrypted.GetString())
+	assert.Exactly(t, []string{keyIDToHex(keyIDs[0])}, requested)
+
+	// The key stays unlocked in the keyring, but not in the original key
+	_, err = keyRing.DecryptWithPassphraseCallback(ciphertext, nil, 0, callback)
+	assert.Nil(t, err)
+	assert.Len(t, requested, 1)
+	locked, err := lockedKey.IsLocked()
+	assert.Nil(t, err)
+	assert.True(t, locked)
+
+	_, err = NewLockedKeyRing(keyRingTestPublic.GetKeys()[0])
+	assert.NotNil(t, err)
+}
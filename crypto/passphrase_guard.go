@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PassphraseGuard throttles the attempts to unlock keys: after a number of
+// failed attempts on a key, further attempts are refused for a delay that
+// doubles with each failure. A successful attempt resets the count.
+// It is safe for concurrent use.
+type PassphraseGuard struct {
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+
+	lock     sync.Mutex
+	attempts map[string]*passphraseAttempts
+	now      func() time.Time
+}
+
+// PassphraseAttemptError is returned by the PassphraseGuard when an attempt is
+// refused because of previous failed attempts.
+type PassphraseAttemptError struct {
+	// Failed attempts since the last successful one
+	FailedAttempts int
+	// Time to wait before the next attempt is allowed
+	RetryAfter time.Duration
+}
+
+// Error is the base method for all errors.
+func (e PassphraseAttemptError) Error() string {
+	return fmt.Sprintf(
+		"gopenpgp: too many failed passphrase attempts (%d), retry after %v",
+		e.FailedAttempts, e.RetryAfter,
+	)
+}
+
+type passphraseAttempts struct {
+	failed    int
+	notBefore time.Time
+}
+
+// NewPassphraseGuard creates a guard that allows freeAttempts failed attempts
+// per key without delay, then waits baseDelay before the next attempt,
+// doubling the delay after each failure up to maxDelay.
+func NewPassphraseGuard(freeAttempts int, baseDelay, maxDelay time.Duration) *PassphraseGuard {
+	return &PassphraseGuard{
+		freeAttempts: freeAttempts,
+		baseDelay:    baseDelay,
+		maxDelay:     maxDelay,
+		attempts:     make(map[string]*passphraseAttempts),
+		now:          time.Now,
+	}
+}
+
+// Unlock unlocks a copy of the key like (*Key).Unlock, unless the attempt is
+// throttled, in which case a PassphraseAttemptError is returned.
+func (guard *PassphraseGuard) Unlock(key *Key, passphrase []byte) (*Key, error) {
+	fingerprint := key.GetFingerprint()
+	if err := guard.reserve(fingerprint); err != nil {
+		return nil, err
+	}
+
+	unlockedKey, err := key.Unlock(passphrase)
+	if err == nil {
+		guard.Reset(key)
+	}
+	return unlockedKey, err
+}
+
+// CheckPassphrase returns true if passphrase unlocks the key. Throttled
+// attempts return false, with a PassphraseAttemptError.
+func (guard *PassphraseGuard) CheckPassphrase(key *Key, passphrase []byte) (bool, error) {
+	unlockedKey, err := guard.Unlock(key, passphrase)
+	if err != nil {
+		var attemptErr PassphraseAttemptError
+		if errors.As(err, &attemptErr) {
+			return false, err
+		}
+		return false, nil
+	}
+
+	unlockedKey.ClearPrivateParams()
+	return true, nil
+}
+
+// RetryAfter returns the time to wait before the next attempt to unlock the
+// key is allowed, or 0 if it is allowed now.
+func (guard *PassphraseGuard) RetryAfter(key *Key) time.Duration {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	attempts, ok := guard.attempts[key.GetFingerprint()]
+	if !ok {
+		return 0
+	}
+	if wait := attempts.notBefore.Sub(guard.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Reset forgets the failed attempts to unlock the key.
+func (guard *PassphraseGuard) Reset(key *Key) {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	delete(guard.attempts, key.GetFingerprint())
+}
+
+// --- Internal functions
+
+// reserve returns a PassphraseAttemptError if an attempt to unlock the key
+// with the given fingerprint is not allowed now. Otherwise the attempt is
+// counted as failed until it succeeds, so that concurrent attempts are
+// throttled as if they had been made one after the other.
+func (guard *PassphraseGuard) reserve(fingerprint string) error {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	attempts, ok := guard.attempts[fingerprint]
+	if !ok {
+		attempts = &passphraseAttempts{}
+		guard.attempts[fingerprint] = attempts
+	}
+	if wait := attempts.notBefore.Sub(guard.now()); wait > 0 {
+		return PassphraseAttemptError{FailedAttempts: attempts.failed, RetryAfter: wait}
+	}
+	attempts.failed++
+
+	if excess := attempts.failed - guard.freeAttempts; excess > 0 {
+		delay := guard.baseDelay
+		for i := 1; i < excess && delay < guard.maxDelay; i++ {
+			delay *= 2
+		}
+		if delay > guard.maxDelay {
+			delay = guard.maxDelay
+		}
+		attempts.notBefore = guard.now().Add(delay)
+	}
+	return nil
+}
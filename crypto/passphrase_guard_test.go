@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassphraseGuard(t *testing.T) {
+	lockedKey, err := NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	now := time.Unix(testTime, 0)
+	guard := NewPassphraseGuard(2, time.Second, 3*time.Second)
+	guard.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, err := guard.CheckPassphrase(lockedKey, []byte("wrong"))
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	}
+	assert.Exactly(t, time.Duration(0), guard.RetryAfter(lockedKey))
+
+	// The third failure starts the backoff
+	_, err = guard.Unlock(lockedKey, []byte("wrong"))
+	assert.NotNil(t, err)
+	assert.Exactly(t, time.Second, guard.RetryAfter(lockedKey))
+
+	_, err = guard.Unlock(lockedKey, testMailboxPassword)
+	attemptErr, ok := err.(PassphraseAttemptError)
+	assert.True(t, ok)
+	assert.Exactly(t, 3, attemptErr.FailedAttempts)
+	assert.Exactly(t, time.Second, attemptErr.RetryAfter)
+
+	// The delay doubles, up to the maximum
+	now = now.Add(time.Second)
+	_, err = guard.Unlock(lockedKey, []byte("wrong"))
+	assert.NotNil(t, err)
+	assert.Exactly(t, 2*time.Second, guard.RetryAfter(lockedKey))
+
+	now = now.Add(2 * time.Second)
+	_, err = guard.Unlock(lockedKey, []byte("wrong"))
+	assert.NotNil(t, err)
+	assert.Exactly(t, 3*time.Second, guard.RetryAfter(lockedKey))
+
+	// A successful attempt resets the count
+	now = now.Add(3 * time.Second)
+	unlockedKey, err := guard.Unlock(lockedKey, testMailboxPassword)
+	if err != nil {
+		t.Fatal("Cannot unlock key:", err)
+	}
+	isUnlocked, _ := unlockedKey.IsUnlocked()
+	assert.True(t, isUnlocked)
+
+	_, err = guard.Unlock(lockedKey, []byte("wrong"))
+	assert.NotNil(t, err)
+	assert.Exactly(t, time.Duration(0), guard.RetryAfter(lockedKey))
+
+	guard.Reset(lockedKey)
+	ok, err = guard.CheckPassphrase(lockedKey, testMailboxPassword)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestPassphraseGuardConcurrentAttempts(t *testing.T) {
+	lockedKey, err := NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	now := time.Unix(testTime, 0)
+	guard := NewPassphraseGuard(2, time.Second, 3*time.Second)
+	guard.now = func() time.Time { return now }
+
+	// Only the attempts up to the first delayed one are made
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	refused := 0
+	for i := 0; i < 10; i++ {
+		// Keys are not safe for concurrent serialization
+		keyCopy, err := lockedKey.Copy()
+		if err != nil {
+			t.Fatal("Cannot copy key:", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := guard.Unlock(keyCopy, []byte("wrong"))
+			if _, ok := err.(PassphraseAttemptError); ok {
+				lock.Lock()
+				refused++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Exactly(t, 7, refused)
+	assert.Exactly(t, time.Second, guard.RetryAfter(lockedKey))
+}
@@ -3,6 +3,7 @@ package crypto
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
@@ -21,7 +22,7 @@ func EncryptMessageWithPassword(message *PlainMessage, password []byte) (*PGPMes
 		return nil, err
 	}
 
-	return NewPGPMessage(encrypted), nil
+	return newPGPMessage(encrypted), nil
 }
 
 // DecryptMessageWithPassword decrypts password protected pgp binary messages.
@@ -55,18 +56,23 @@ func DecryptSessionKeyWithPassword(keyPacket, password []byte) (*SessionKey, err
 	if len(symKeys) != 0 && password != nil {
 		for _, s := range symKeys {
 			key, cipherFunc, err := s.Decrypt(password)
-			if err == nil {
-				sk := &SessionKey{
-					Key:  key,
-					Algo: getAlgo(cipherFunc),
-				}
-
-				if err = sk.checkSize(); err != nil {
-					return nil, errors.Wrap(err, "gopenpgp: unable to decrypt session key with password")
-				}
+			if err != nil {
+				continue
+			}
+			algo, err := getAlgo(cipherFunc)
+			if err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: unable to decrypt session key with password")
+			}
+			sk := &SessionKey{
+				Key:  key,
+				Algo: algo,
+			}
 
-				return sk, nil
+			if err = sk.checkSize(); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: unable to decrypt session key with password")
 			}
+
+			return sk, nil
 		}
 	}
 
@@ -151,7 +157,16 @@ func passwordDecrypt(encryptedIO io.Reader, password []byte) (*PlainMessage, err
 		Time: getTimeGenerator(),
 	}
 
+	keyPackets, dataPacketTag, dataPacketReader, err := readKeyPackets(encryptedIO)
+	if err != nil {
+		return nil, err
+	}
+	if dataPacketTag == packetTagSymmetricallyEncrypted {
+		return passwordDecryptUnprotected(keyPackets, dataPacketReader, password)
+	}
+
 	var emptyKeyRing openpgp.EntityList
+	encryptedIO = io.MultiReader(bytes.NewReader(keyPackets), dataPacketReader)
 	md, err := openpgp.ReadMessage(encryptedIO, emptyKeyRing, prompt, config)
 	if err != nil {
 		// Parsing errors when reading the message are most likely caused by incorrect password, but we cannot know for sure
@@ -177,3 +192,20 @@ func passwordDecrypt(encryptedIO io.Reader, password []byte) (*PlainMessage, err
 		Time:     md.LiteralData.Time,
 	}, nil
 }
+
+// passwordDecryptUnprotected decrypts a message without integrity protection
+// with the session key decrypted with password, if allowed.
+func passwordDecryptUnprotected(keyPackets []byte, dataPacketReader io.Reader, password []byte) (*PlainMessage, error) {
+	if !allowUnprotectedMessages() {
+		return nil, errUnprotectedMessage
+	}
+	sessionKey, err := DecryptSessionKeyWithPassword(keyPackets, password)
+	if err != nil {
+		return nil, err
+	}
+	dataPacket, err := ioutil.ReadAll(dataPacketReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+	}
+	return sessionKey.Decrypt(dataPacket)
+}
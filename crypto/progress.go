@@ -0,0 +1,116 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// ProgressHandler receives the progress of a long operation, e.g. to show a
+// progress bar.
+type ProgressHandler interface {
+	// OnProgress is called with the number of bytes processed so far, and the
+	// total number of bytes to process, or -1 if unknown. Once the operation
+	// is finished, it is called with processed equal to total.
+	OnProgress(processed, total int64)
+}
+
+// NewProgressReader returns a Reader reading from reader, and reporting the
+// bytes read to handler, out of total, or -1 if unknown. The operation is
+// finished once total bytes are read, or the end of reader is reached.
+// Reading the encrypted message given to DecryptStream through it reports
+// the progress of the decryption, e.g. with the size of the file as total, as
+// the decryption may not read past the end of the message.
+func NewProgressReader(reader Reader, total int64, handler ProgressHandler) Reader {
+	return &progressReader{reader: reader, progress: progress{total: total, handler: handler}}
+}
+
+// NewProgressWriter returns a WriteCloser writing to writer, and reporting the
+// bytes written to handler, out of total, or -1 if unknown. The operation is
+// finished once total bytes are written, or the writer is closed.
+// Writing the plaintext to the writer returned by EncryptStream through it
+// reports the progress of the encryption.
+func NewProgressWriter(writer WriteCloser, total int64, handler ProgressHandler) WriteCloser {
+	return &progressWriter{writer: writer, progress: progress{total: total, handler: handler}}
+}
+
+// GenerateKeyWithProgress generates a key like GenerateKey, reporting the
+// progress of the generation to handler. As the duration of the generation
+// is random, the progress is the number of random bytes consumed, with an
+// unknown total, and the handler is called a last time with processed equal
+// to total once the key is generated.
+func GenerateKeyWithProgress(name, email string, keyType string, bits int, handler ProgressHandler) (*Key, error) {
+	random := &progressReader{reader: rand.Reader, progress: progress{total: -1, handler: handler}}
+	key, err := generateKey(name, email, keyType, bits, 0, random, nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	random.finish()
+	return key, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// progress counts the bytes processed by an operation, and reports them,
+// until total bytes are processed or finish is called.
+type progress struct {
+	processed int64
+	total     int64
+	handler   ProgressHandler
+	finished  bool
+}
+
+func (p *progress) add(n int) {
+	if n <= 0 || p.finished {
+		return
+	}
+	p.processed += int64(n)
+	if p.total >= 0 && p.processed >= p.total {
+		p.finish()
+		return
+	}
+	p.handler.OnProgress(p.processed, p.total)
+}
+
+// finish reports the end of the operation, once.
+func (p *progress) finish() {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.total = p.processed
+	p.handler.OnProgress(p.processed, p.total)
+}
+
+type progressReader struct {
+	reader Reader
+	progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.add(n)
+	if errors.Is(err, io.EOF) {
+		r.finish()
+	}
+	return n, err
+}
+
+type progressWriter struct {
+	writer WriteCloser
+	progress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.writer.Write(b)
+	w.add(n)
+	return n, err
+}
+
+func (w *progressWriter) Close() error {
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	w.finish()
+	return nil
+}
@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type progressRecorder struct {
+	processed []int64
+	totals    []int64
+}
+
+func (r *progressRecorder) OnProgress(processed, total int64) {
+	r.processed = append(r.processed, processed)
+	r.totals = append(r.totals, total)
+}
+
+func (r *progressRecorder) assertFinished(t *testing.T, total int64) {
+	if !assert.NotEmpty(t, r.processed) {
+		return
+	}
+	last := len(r.processed) - 1
+	for i := 1; i <= last; i++ {
+		assert.True(t, r.processed[i] >= r.processed[i-1])
+	}
+	assert.Exactly(t, total, r.processed[last])
+	assert.Exactly(t, total, r.totals[last])
+}
+
+func TestProgressStreams(t *testing.T) {
+	plaintext := make([]byte, 100000)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatal("Cannot generate data:", err)
+	}
+
+	var ciphertext bytes.Buffer
+	encryptionProgress := &progressRecorder{}
+	plainWriter, err := keyRingTestPublic.EncryptStream(&ciphertext, nil, nil)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting stream, got:", err)
+	}
+	writer := NewProgressWriter(plainWriter, int64(len(plaintext)), encryptionProgress)
+	for i := 0; i < len(plaintext); i += 30000 {
+		end := i + 30000
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+		_, err = writer.Write(plaintext[i:end])
+		assert.Nil(t, err)
+	}
+	assert.Exactly(t, []int64{30000, 60000, 90000, 100000}, encryptionProgress.processed)
+	assert.Nil(t, writer.Close())
+	assert.Len(t, encryptionProgress.processed, 4)
+	encryptionProgress.assertFinished(t, int64(len(plaintext)))
+
+	decryptionProgress := &progressRecorder{}
+	reader := NewProgressReader(bytes.NewReader(ciphertext.Bytes()), int64(ciphertext.Len()), decryptionProgress)
+	plainReader, err := keyRingTestPrivate.DecryptStream(reader, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting stream, got:", err)
+	}
+	decrypted, err := ioutil.ReadAll(plainReader)
+	assert.Nil(t, err)
+	assert.Exactly(t, plaintext, decrypted)
+	decryptionProgress.assertFinished(t, int64(ciphertext.Len()))
+
+	// The total is set once the end of the stream is reached, if unknown
+	readProgress := &progressRecorder{}
+	read, err := ioutil.ReadAll(NewProgressReader(bytes.NewReader(plaintext), -1, readProgress))
+	assert.Nil(t, err)
+	assert.Exactly(t, plaintext, read)
+	assert.Exactly(t, int64(-1), readProgress.totals[0])
+	readProgress.assertFinished(t, int64(len(plaintext)))
+}
+
+func TestGenerateKeyWithProgress(t *testing.T) {
+	progress := &progressRecorder{}
+	key, err := GenerateKeyWithProgress(keyTestName, keyTestDomain, "x25519", 0, progress)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	assert.True(t, key.IsPrivate())
+	assert.True(t, len(progress.processed) > 1)
+	assert.Exactly(t, int64(-1), progress.totals[0])
+	progress.assertFinished(t, progress.processed[len(progress.processed)-1])
+}
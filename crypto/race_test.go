@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package crypto
+
+// raceEnabled is true when the tests run with the race detector, which
+// allocates more memory.
+const raceEnabled = true
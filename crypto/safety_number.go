@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// safetyQRPayloadVersion is the first byte of the QR code payloads.
+const safetyQRPayloadVersion = 0
+
+// GetSafetyNumber returns a numeric representation of the fingerprints of two
+// keys, made of 12 groups of 5 digits, that both key holders can compare out of
+// band to verify each other's key. The result does not depend on the order of
+// the keys.
+func GetSafetyNumber(key1, key2 *Key) string {
+	digest := safetyDigest(key1, key2)
+
+	groups := make([]string, 12)
+	for i := range groups {
+		var chunk uint64
+		for _, b := range digest[5*i : 5*i+5] {
+			chunk = chunk<<8 | uint64(b)
+		}
+		groups[i] = fmt.Sprintf("%05d", chunk%100000)
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// GetSafetyWords returns the same comparison value as GetSafetyNumber
+// represented as 12 words of the PGP word list, separated by spaces.
+func GetSafetyWords(key1, key2 *Key) string {
+	digest := safetyDigest(key1, key2)
+	return strings.Join(bytesToPGPWords(digest[:12]), " ")
+}
+
+// GetSafetyQRPayload returns the payload of a QR code showing myKey and
+// theirKey, for theirKey's holder to scan and check with CheckSafetyQRPayload.
+// It holds a version byte followed by the fingerprints of myKey and theirKey,
+// each prefixed with its length.
+func GetSafetyQRPayload(myKey, theirKey *Key) []byte {
+	fingerprint1 := myKey.entity.PrimaryKey.Fingerprint
+	fingerprint2 := theirKey.entity.PrimaryKey.Fingerprint
+
+	payload := make([]byte, 0, 3+len(fingerprint1)+len(fingerprint2))
+	payload = append(payload, safetyQRPayloadVersion)
+	payload = append(payload, byte(len(fingerprint1)))
+	payload = append(payload, fingerprint1...)
+	payload = append(payload, byte(len(fingerprint2)))
+	payload = append(payload, fingerprint2...)
+	return payload
+}
+
+// CheckSafetyQRPayload checks a payload scanned from the QR code of
+// GetSafetyQRPayload shown by theirKey's holder. It returns true if the
+// payload shows theirKey and myKey, i.e. both holders have each other's key,
+// and an error if the payload is malformed.
+func CheckSafetyQRPayload(myKey, theirKey *Key, payload []byte) (bool, error) {
+	if len(payload) == 0 || payload[0] != safetyQRPayloadVersion {
+		return false, errors.New("gopenpgp: unsupported safety QR code payload version")
+	}
+	payload = payload[1:]
+
+	fingerprints := make([][]byte, 2)
+	for i := range fingerprints {
+		if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+			return false, errors.New("gopenpgp: truncated safety QR code payload")
+		}
+		fingerprints[i] = payload[1 : 1+int(payload[0])]
+		payload = payload[1+int(payload[0]):]
+	}
+	if len(payload) != 0 {
+		return false, errors.New("gopenpgp: trailing data in safety QR code payload")
+	}
+
+	return bytes.Equal(fingerprints[0], theirKey.entity.PrimaryKey.Fingerprint) &&
+		bytes.Equal(fingerprints[1], myKey.entity.PrimaryKey.Fingerprint), nil
+}
+
+// safetyDigest hashes the fingerprints of the two keys in a canonical order.
+func safetyDigest(key1, key2 *Key) []byte {
+	fingerprint1 := key1.entity.PrimaryKey.Fingerprint
+	fingerprint2 := key2.entity.PrimaryKey.Fingerprint
+	if bytes.Compare(fingerprint1, fingerprint2) > 0 {
+		fingerprint1, fingerprint2 = fingerprint2, fingerprint1
+	}
+
+	h := sha512.New()
+	_, _ = h.Write(fingerprint1)
+	_, _ = h.Write(fingerprint2)
+	return h.Sum(nil)
+}
+
+// bytesToPGPWords maps each byte to a word of the PGP word list, alternating
+// between the even and odd lists to detect swapped or dropped words.
+func bytesToPGPWords(data []byte) []string {
+	words := make([]string, len(data))
+	for i, b := range data {
+		if i%2 == 0 {
+			words[i] = pgpWordsEven[b]
+		} else {
+			words[i] = pgpWordsOdd[b]
+		}
+	}
+	return words
+}
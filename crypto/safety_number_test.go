@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	safetyNumber := GetSafetyNumber(keyTestRSA, keyTestEC)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9]{5}( [0-9]{5}){11}$`), safetyNumber)
+	assert.Exactly(t, safetyNumber, GetSafetyNumber(keyTestEC, keyTestRSA))
+
+	publicKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	assert.Exactly(t, safetyNumber, GetSafetyNumber(keyTestRSA, publicKey))
+
+	otherKey := keyRingTestPublic.GetKeys()[0]
+	assert.NotEqual(t, safetyNumber, GetSafetyNumber(keyTestRSA, otherKey))
+}
+
+func TestSafetyWords(t *testing.T) {
+	safetyWords := GetSafetyWords(keyTestRSA, keyTestEC)
+	assert.Len(t, strings.Split(safetyWords, " "), 12)
+	assert.Exactly(t, safetyWords, GetSafetyWords(keyTestEC, keyTestRSA))
+	assert.NotEqual(t, safetyWords, GetSafetyWords(keyTestRSA, keyRingTestPublic.GetKeys()[0]))
+}
+
+func TestBytesToPGPWords(t *testing.T) {
+	words := bytesToPGPWords([]byte{0xE5, 0x82, 0x94, 0xF2, 0xE9, 0xA2, 0x27, 0x48})
+	assert.Exactly(
+		t,
+		[]string{"topmost", "Istanbul", "Pluto", "vagabond", "treadmill", "Pacific", "brackish", "dictator"},
+		words,
+	)
+}
+
+func TestSafetyQRPayload(t *testing.T) {
+	otherKey := keyRingTestPublic.GetKeys()[0]
+	payload := GetSafetyQRPayload(keyTestRSA, keyTestEC)
+
+	ok, err := CheckSafetyQRPayload(keyTestEC, keyTestRSA, payload)
+	if err != nil {
+		t.Fatal("Expected no error when checking payload, got:", err)
+	}
+	assert.True(t, ok)
+
+	ok, err = CheckSafetyQRPayload(keyTestRSA, keyTestEC, payload)
+	if err != nil {
+		t.Fatal("Expected no error when checking payload, got:", err)
+	}
+	assert.False(t, ok)
+
+	ok, err = CheckSafetyQRPayload(otherKey, keyTestRSA, payload)
+	if err != nil {
+		t.Fatal("Expected no error when checking payload, got:", err)
+	}
+	assert.False(t, ok)
+
+	_, err = CheckSafetyQRPayload(keyTestEC, keyTestRSA, nil)
+	assert.Error(t, err)
+	_, err = CheckSafetyQRPayload(keyTestEC, keyTestRSA, append([]byte{1}, payload[1:]...))
+	assert.Error(t, err)
+	_, err = CheckSafetyQRPayload(keyTestEC, keyTestRSA, payload[:len(payload)-1])
+	assert.Error(t, err)
+	_, err = CheckSafetyQRPayload(keyTestEC, keyTestRSA, append(payload, 0))
+	assert.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 	"github.com/pkg/errors"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
@@ -32,6 +33,16 @@ var symKeyAlgos = map[string]packet.CipherFunction{
 	constants.AES256:    packet.CipherAES256,
 }
 
+// symKeyAlgoNames maps the supported cipher functions to the algorithm names
+// returned with decrypted session keys.
+var symKeyAlgoNames = map[packet.CipherFunction]string{
+	packet.Cipher3DES:   constants.ThreeDES,
+	packet.CipherCAST5:  constants.CAST5,
+	packet.CipherAES128: constants.AES128,
+	packet.CipherAES192: constants.AES192,
+	packet.CipherAES256: constants.AES256,
+}
+
 type checkReader struct {
 	decrypted io.ReadCloser
 	body      io.Reader
@@ -111,15 +122,9 @@ func NewSessionKeyFromToken(token []byte, algo string) *SessionKey {
 }
 
 func newSessionKeyFromEncrypted(ek *packet.EncryptedKey) (*SessionKey, error) {
-	var algo string
-	for k, v := range symKeyAlgos {
-		if v == ek.CipherFunc {
-			algo = k
-			break
-		}
-	}
-	if algo == "" {
-		return nil, fmt.Errorf("gopenpgp: unsupported cipher function: %v", ek.CipherFunc)
+	algo, err := getAlgo(ek.CipherFunc)
+	if err != nil {
+		return nil, err
 	}
 
 	sk := &SessionKey{
@@ -127,7 +132,7 @@ func newSessionKeyFromEncrypted(ek *packet.EncryptedKey) (*SessionKey, error) {
 		Algo: algo,
 	}
 
-	if err := sk.checkSize(); err != nil {
+	if err = sk.checkSize(); err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt session key")
 	}
 
@@ -166,6 +171,7 @@ func (sk *SessionKey) EncryptAndSign(message *PlainMessage, signKeyRing *KeyRing
 		DefaultCipher: dc,
 	}
 
+	defer signKeyRing.use()()
 	signEntity, err := signKeyRing.getSigningEntity()
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: unable to sign")
@@ -195,6 +201,7 @@ func (sk *SessionKey) EncryptWithCompression(message *PlainMessage) ([]byte, err
 
 func encryptWithSessionKey(message *PlainMessage, sk *SessionKey, signEntity *openpgp.Entity, config *packet.Config) ([]byte, error) {
 	var encBuf = new(bytes.Buffer)
+	encBuf.Grow(encryptedSizeEstimate(message, 0))
 
 	encryptWriter, signWriter, err := encryptStreamWithSessionKey(
 		message.IsBinary(),
@@ -204,12 +211,13 @@ func encryptWithSessionKey(message *PlainMessage, sk *SessionKey, signEntity *op
 		sk,
 		signEntity,
 		config,
+		nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 	if signEntity != nil {
-		_, err = signWriter.Write(message.GetBinary())
+		_, err = writeChunked(signWriter, message.GetBinary())
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: error in writing signed message")
 		}
@@ -218,7 +226,7 @@ func encryptWithSessionKey(message *PlainMessage, sk *SessionKey, signEntity *op
 			return nil, errors.Wrap(err, "gopenpgp: error in closing signing writer")
 		}
 	} else {
-		_, err = encryptWriter.Write(message.GetBinary())
+		_, err = writeChunked(encryptWriter, message.GetBinary())
 	}
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in writing message")
@@ -230,6 +238,9 @@ func encryptWithSessionKey(message *PlainMessage, sk *SessionKey, signEntity *op
 	return encBuf.Bytes(), nil
 }
 
+// encryptStreamWithSessionKey encrypts with sk, and signs with signEntity if
+// not nil, adding the serialized subpackets to the hashed area of the
+// signature.
 func encryptStreamWithSessionKey(
 	isBinary bool,
 	filename string,
@@ -238,6 +249,7 @@ func encryptStreamWithSessionKey(
 	sk *SessionKey,
 	signEntity *openpgp.Entity,
 	config *packet.Config,
+	subpackets []byte,
 ) (encryptWriter, signWriter io.WriteCloser, err error) {
 	encryptWriter, err = packet.SerializeSymmetricallyEncrypted(dataPacketWriter, config.Cipher(), sk.Key, config)
 	if err != nil {
@@ -258,7 +270,7 @@ func encryptStreamWithSessionKey(
 			ModTime:  time.Unix(int64(modTime), 0),
 		}
 
-		signWriter, err = openpgp.Sign(encryptWriter, signEntity, hints, config)
+		signWriter, err = signStreamWithSubpackets(encryptWriter, signEntity, hints, config, subpackets)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "gopenpgp: unable to sign")
 		}
@@ -291,38 +303,95 @@ func (sk *SessionKey) Decrypt(dataPacket []byte) (*PlainMessage, error) {
 func (sk *SessionKey) DecryptAndVerify(dataPacket []byte, verifyKeyRing *KeyRing, verifyTime int64) (*PlainMessage, error) {
 	var messageReader = bytes.NewReader(dataPacket)
 
-	md, err := decryptStreamWithSessionKey(sk, messageReader, verifyKeyRing)
+	md, unprotected, err := decryptStreamWithSessionKey(sk, messageReader, verifyKeyRing)
 	if err != nil {
 		return nil, err
 	}
-	messageBuf := new(bytes.Buffer)
-	_, err = messageBuf.ReadFrom(md.UnverifiedBody)
+	body, err := readAllWithSizeHint(md.UnverifiedBody, len(dataPacket))
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in reading message body")
 	}
 
+	plainMessage := &PlainMessage{
+		Data:        body,
+		TextType:    !md.LiteralData.IsBinary,
+		Filename:    md.LiteralData.FileName,
+		Time:        md.LiteralData.Time,
+		unprotected: unprotected,
+	}
 	if verifyKeyRing != nil {
-		processSignatureExpiration(md, verifyTime)
+		processSignatureExpiration(md, verifyTime, internal.CreationTimeOffset)
 		err = verifyDetailsSignature(md, verifyKeyRing)
+		if err == nil {
+			plainMessage.setVerifiedSignature(md)
+		}
+		if err != nil && md.IsSigned && md.Signature == nil {
+			if droppedErr := findDroppedSignatureError(sk, dataPacket); droppedErr != nil {
+				err = droppedErr
+			}
+		}
 	}
 
-	return &PlainMessage{
-		Data:     messageBuf.Bytes(),
-		TextType: !md.LiteralData.IsBinary,
-		Filename: md.LiteralData.FileName,
-		Time:     md.LiteralData.Time,
-	}, err
+	return plainMessage, err
 }
 
-func decryptStreamWithSessionKey(sk *SessionKey, messageReader io.Reader, verifyKeyRing *KeyRing) (*openpgp.MessageDetails, error) {
-	var decrypted io.ReadCloser
+// decryptStreamWithSessionKey decrypts the data packet in messageReader, and
+// returns whether it was a Symmetrically Encrypted packet without integrity
+// protection, only decrypted if allowed.
+func decryptStreamWithSessionKey(
+	sk *SessionKey, messageReader io.Reader, verifyKeyRing *KeyRing,
+) (md *openpgp.MessageDetails, unprotected bool, err error) {
 	var keyring openpgp.EntityList
+	input := &endReader{reader: messageReader}
+
+	decrypted, unprotected, err := decryptDataPacket(sk, input)
+	if err != nil {
+		return nil, false, err
+	}
+
+	config := &packet.Config{
+		Time: getTimeGenerator(),
+	}
+
+	// Push decrypted packet as literal packet and use openpgp's reader
+	if verifyKeyRing != nil {
+		keyring = verifyKeyRing.getEntities()
+	} else {
+		keyring = openpgp.EntityList{}
+	}
+
+	md, err = openpgp.ReadMessage(decrypted, keyring, nil, config)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "gopenpgp: unable to decode symmetric packet")
+	}
+
+	md.UnverifiedBody = &modificationCheckReader{body: checkReader{decrypted, md.UnverifiedBody}, input: input}
+	return md, unprotected, nil
+}
+
+// decryptDataPacket decrypts the data packet in messageReader with the
+// session key, and returns the decrypted packets and whether it was a
+// Symmetrically Encrypted packet without integrity protection.
+func decryptDataPacket(sk *SessionKey, messageReader io.Reader) (decrypted io.ReadCloser, unprotected bool, err error) {
+	// go-crypto can't parse packets without integrity protection, which are
+	// recognized by their tag
+	header := make([]byte, 1)
+	if _, err = io.ReadFull(messageReader, header); err != nil {
+		return nil, false, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+	}
+	messageReader = io.MultiReader(bytes.NewReader(header), messageReader)
+	unprotected = readRawPacketTag(header[0]) == packetTagSymmetricallyEncrypted
+
+	if unprotected {
+		decrypted, err = decryptUnprotectedPacket(sk, messageReader)
+		return decrypted, true, err
+	}
 
 	// Read symmetrically encrypted data packet
 	packets := packet.NewReader(messageReader)
 	p, err := packets.Next()
 	if err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+		return nil, false, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
 	}
 
 	// Decrypt data packet
@@ -330,38 +399,20 @@ func decryptStreamWithSessionKey(sk *SessionKey, messageReader io.Reader, verify
 	case *packet.SymmetricallyEncrypted, *packet.AEADEncrypted:
 		dc, err := sk.GetCipherFunc()
 		if err != nil {
-			return nil, errors.Wrap(err, "gopenpgp: unable to decrypt with session key")
+			return nil, false, errors.Wrap(err, "gopenpgp: unable to decrypt with session key")
 		}
 		encryptedDataPacket, isDataPacket := p.(packet.EncryptedDataPacket)
 		if !isDataPacket {
-			return nil, errors.Wrap(err, "gopenpgp: unknown data packet")
+			return nil, false, errors.Wrap(err, "gopenpgp: unknown data packet")
 		}
 		decrypted, err = encryptedDataPacket.Decrypt(dc, sk.Key)
 		if err != nil {
-			return nil, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
+			return nil, false, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
 		}
+		return decrypted, false, nil
 	default:
-		return nil, errors.New("gopenpgp: invalid packet type")
-	}
-
-	config := &packet.Config{
-		Time: getTimeGenerator(),
-	}
-
-	// Push decrypted packet as literal packet and use openpgp's reader
-	if verifyKeyRing != nil {
-		keyring = verifyKeyRing.entities
-	} else {
-		keyring = openpgp.EntityList{}
-	}
-
-	md, err := openpgp.ReadMessage(decrypted, keyring, nil, config)
-	if err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: unable to decode symmetric packet")
+		return nil, false, errors.New("gopenpgp: invalid packet type")
 	}
-
-	md.UnverifiedBody = checkReader{decrypted, md.UnverifiedBody}
-	return md, nil
 }
 
 func (sk *SessionKey) checkSize() error {
@@ -377,14 +428,10 @@ func (sk *SessionKey) checkSize() error {
 	return nil
 }
 
-func getAlgo(cipher packet.CipherFunction) string {
-	algo := constants.AES256
-	for k, v := range symKeyAlgos {
-		if v == cipher {
-			algo = k
-			break
-		}
+func getAlgo(cipher packet.CipherFunction) (string, error) {
+	algo, ok := symKeyAlgoNames[cipher]
+	if !ok {
+		return "", fmt.Errorf("gopenpgp: unsupported cipher function: %v", cipher)
 	}
-
-	return algo
+	return algo, nil
 }
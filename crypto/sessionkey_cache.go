@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// SessionKeyCache keeps the session keys decrypted from key packets, by hash
+// of the key packet, so that decrypting many attachments or chunks sharing a
+// key packet performs the asymmetric decryption only once.
+// The cached session keys are returned for a known key packet whatever the
+// keyring used: a cache must only be shared by the keyrings of one user.
+// It is safe for concurrent use.
+type SessionKeyCache struct {
+	lock  sync.Mutex
+	cache *lruCache
+}
+
+// NewSessionKeyCache creates a cache keeping up to maxEntries session keys,
+// evicting the least recently used ones. If maxEntries is 0 or less, the
+// number of entries is not limited.
+func NewSessionKeyCache(maxEntries int) *SessionKeyCache {
+	return &SessionKeyCache{
+		cache: newLRUCache(maxEntries, func(value interface{}) {
+			value.(*SessionKey).Wipe()
+		}),
+	}
+}
+
+// DecryptSessionKey returns the session key of keyPacket from the cache, or
+// decrypts it with keyRing like (*KeyRing).DecryptSessionKey and caches it.
+// The returned session key is a copy, that can be wiped.
+func (cache *SessionKeyCache) DecryptSessionKey(keyRing *KeyRing, keyPacket []byte) (*SessionKey, error) {
+	hash := sha256.Sum256(keyPacket)
+	if sessionKey := cache.get(hash); sessionKey != nil {
+		return sessionKey, nil
+	}
+
+	sessionKey, err := keyRing.DecryptSessionKey(keyPacket)
+	if err != nil {
+		return nil, err
+	}
+	cached := copySessionKey(sessionKey)
+	cache.put(hash, cached)
+	return sessionKey, nil
+}
+
+// DecryptAttachment decrypts an attachment like (*KeyRing).DecryptAttachment,
+// with the session key of its key packet taken from the cache if possible.
+func (cache *SessionKeyCache) DecryptAttachment(keyRing *KeyRing, message *PGPSplitMessage) (*PlainMessage, error) {
+	sessionKey, err := cache.DecryptSessionKey(keyRing, message.GetBinaryKeyPacket())
+	if err != nil {
+		return nil, err
+	}
+	defer sessionKey.Wipe()
+	return sessionKey.Decrypt(message.GetBinaryDataPacket())
+}
+
+// DecryptAttachmentChunks decrypts the chunks of an attachment like
+// (*KeyRing).DecryptAttachmentChunks, with the session key of keyPacket taken
+// from the cache if possible.
+func (cache *SessionKeyCache) DecryptAttachmentChunks(keyRing *KeyRing, keyPacket []byte, dataPackets [][]byte) ([]byte, error) {
+	sessionKey, err := cache.DecryptSessionKey(keyRing, keyPacket)
+	if err != nil {
+		return nil, err
+	}
+	defer sessionKey.Wipe()
+	return sessionKey.decryptAttachmentChunks(dataPackets)
+}
+
+// Len returns the number of cached session keys.
+func (cache *SessionKeyCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.cache.len()
+}
+
+// Clear wipes and removes all the cached session keys.
+func (cache *SessionKeyCache) Clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.cache.clear()
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// get returns a copy of the cached session key with the given hash, or nil.
+func (cache *SessionKeyCache) get(hash [sha256.Size]byte) *SessionKey {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	sessionKey, ok := cache.cache.get(hash)
+	if !ok {
+		return nil
+	}
+	return copySessionKey(sessionKey.(*SessionKey))
+}
+
+// put caches the session key with the given hash.
+func (cache *SessionKeyCache) put(hash [sha256.Size]byte, sessionKey *SessionKey) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.cache.put(hash, sessionKey)
+}
+
+func copySessionKey(sessionKey *SessionKey) *SessionKey {
+	return &SessionKey{
+		Key:  clone(sessionKey.Key),
+		Algo: sessionKey.Algo,
+	}
+}
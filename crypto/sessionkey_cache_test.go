@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionKeyCache(t *testing.T) {
+	entity := keyTestRSA.GetEntity()
+	decrypter := &countingKey{PrivateKey: entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)}
+	publicKey, err := keyTestRSA.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	publicKeyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	keyRing, err := NewKeyRingFromSigner(publicKey, nil, decrypter)
+	if err != nil {
+		t.Fatal("Cannot create key ring from decrypter:", err)
+	}
+
+	sessionKey, err := GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Cannot generate session key:", err)
+	}
+	keyPacket, err := publicKeyRing.EncryptSessionKey(sessionKey)
+	if err != nil {
+		t.Fatal("Cannot encrypt session key:", err)
+	}
+
+	cache := NewSessionKeyCache(1)
+	for _, content := range []string{"first", "second", "third"} {
+		dataPacket, err := sessionKey.Encrypt(NewPlainMessageFromString(content))
+		if err != nil {
+			t.Fatal("Cannot encrypt attachment:", err)
+		}
+		decrypted, err := cache.DecryptAttachment(keyRing, NewPGPSplitMessage(keyPacket, dataPacket))
+		if err != nil {
+			t.Fatal("Cannot decrypt attachment:", err)
+		}
+		assert.Exactly(t, content, decrypted.GetString())
+	}
+	assert.Exactly(t, 1, decrypter.decryptions)
+	assert.Exactly(t, 1, cache.Len())
+
+	// Wiping a returned session key doesn't wipe the cached one
+	cached, err := cache.DecryptSessionKey(keyRing, keyPacket)
+	if err != nil {
+		t.Fatal("Cannot decrypt session key:", err)
+	}
+	assert.Exactly(t, sessionKey, cached)
+	cached.Wipe()
+	cached, err = cache.DecryptSessionKey(keyRing, keyPacket)
+	assert.Nil(t, err)
+	assert.Exactly(t, sessionKey, cached)
+	assert.Exactly(t, 1, decrypter.decryptions)
+
+	// Chunks and other key packets, evicting the first one
+	encryptor, err := publicKeyRing.NewChunkedAttachmentEncryptor(100, 40)
+	if err != nil {
+		t.Fatal("Cannot create encryptor:", err)
+	}
+	attachment := make([]byte, 100)
+	dataPackets := make([][]byte, encryptor.CountChunks())
+	for i := range dataPackets {
+		chunk, err := encryptor.GetChunk(attachment, i)
+		if err != nil {
+			t.Fatal("Cannot get chunk:", err)
+		}
+		if dataPackets[i], err = encryptor.EncryptChunk(i, chunk); err != nil {
+			t.Fatal("Cannot encrypt chunk:", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		decrypted, err := cache.DecryptAttachmentChunks(keyRing, encryptor.GetKeyPacket(), dataPackets)
+		if err != nil {
+			t.Fatal("Cannot decrypt chunks:", err)
+		}
+		assert.Exactly(t, attachment, decrypted)
+	}
+	assert.Exactly(t, 2, decrypter.decryptions)
+	assert.Exactly(t, 1, cache.Len())
+
+	_, err = cache.DecryptSessionKey(keyRing, keyPacket)
+	assert.Nil(t, err)
+	assert.Exactly(t, 3, decrypter.decryptions)
+
+	_, err = cache.DecryptSessionKey(keyRingTestPrivate, keyPacket[:len(keyPacket)-1])
+	assert.NotNil(t, err)
+	assert.Exactly(t, 1, cache.Len())
+
+	cache.Clear()
+	assert.Exactly(t, 0, cache.Len())
+}
@@ -41,6 +41,7 @@ func (sk *SessionKey) EncryptStream(
 	}
 	var signEntity *openpgp.Entity
 	if signKeyRing != nil {
+		defer signKeyRing.use()()
 		signEntity, err = signKeyRing.getSigningEntity()
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: unable to sign")
@@ -64,6 +65,7 @@ func (sk *SessionKey) EncryptStream(
 		sk,
 		signEntity,
 		config,
+		nil,
 	)
 
 	if err != nil {
@@ -87,7 +89,7 @@ func (sk *SessionKey) DecryptStream(
 	verifyKeyRing *KeyRing,
 	verifyTime int64,
 ) (plainMessage *PlainMessageReader, err error) {
-	messageDetails, err := decryptStreamWithSessionKey(
+	messageDetails, unprotected, err := decryptStreamWithSessionKey(
 		sk,
 		dataPacketReader,
 		verifyKeyRing,
@@ -97,9 +99,9 @@ func (sk *SessionKey) DecryptStream(
 	}
 
 	return &PlainMessageReader{
-		messageDetails,
-		verifyKeyRing,
-		verifyTime,
-		false,
+		details:       messageDetails,
+		verifyKeyRing: verifyKeyRing,
+		verifyTime:    verifyTime,
+		unprotected:   unprotected,
 	}, err
 }
@@ -104,6 +104,46 @@ func TestSymmetricKeyPacketWrongSize(t *testing.T) {
 	}
 }
 
+func TestDecryptSessionKeyAlgorithm(t *testing.T) {
+	password := []byte("I like encryption")
+	algos := map[string]string{
+		constants.ThreeDES:  constants.ThreeDES,
+		constants.TripleDES: constants.ThreeDES,
+		constants.CAST5:     constants.CAST5,
+		constants.AES128:    constants.AES128,
+		constants.AES192:    constants.AES192,
+		constants.AES256:    constants.AES256,
+	}
+	for algo, expected := range algos {
+		sk, err := GenerateSessionKeyAlgo(algo)
+		if err != nil {
+			t.Fatal("Expected no error while generating session key, got:", err)
+		}
+
+		keyPacket, err := keyRingTestPublic.EncryptSessionKey(sk)
+		if err != nil {
+			t.Fatal("Expected no error while generating key packet, got:", err)
+		}
+		decrypted, err := keyRingTestPrivate.DecryptSessionKey(keyPacket)
+		if err != nil {
+			t.Fatal("Expected no error while decrypting key packet, got:", err)
+		}
+		assert.Exactly(t, expected, decrypted.Algo)
+		assert.Exactly(t, sk.Key, decrypted.Key)
+
+		keyPacket, err = EncryptSessionKeyWithPassword(sk, password)
+		if err != nil {
+			t.Fatal("Expected no error while generating key packet, got:", err)
+		}
+		decrypted, err = DecryptSessionKeyWithPassword(keyPacket, password)
+		if err != nil {
+			t.Fatal("Expected no error while decrypting key packet, got:", err)
+		}
+		assert.Exactly(t, expected, decrypted.Algo)
+		assert.Exactly(t, sk.Key, decrypted.Key)
+	}
+}
+
 func TestDataPacketEncryption(t *testing.T) {
 	var message = NewPlainMessageFromString(
 		"The secret code is... 1, 2, 3, 4, 5. I repeat: the secret code is... 1, 2, 3, 4, 5",
@@ -297,6 +337,20 @@ func TestSessionKeyClear(t *testing.T) {
 	assertMemCleared(t, testSessionKey.Key)
 }
 
+func TestSessionKeyWipe(t *testing.T) {
+	sessionKey, err := GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Cannot generate session key:", err)
+	}
+	key := sessionKey.Key
+
+	sessionKey.Wipe()
+	assertMemCleared(t, key)
+	assert.Nil(t, sessionKey.Key)
+	_, err = sessionKey.Encrypt(NewPlainMessageFromString("message"))
+	assert.NotNil(t, err)
+}
+
 func TestDataPacketEncryptionWithCompression(t *testing.T) {
 	var message = NewPlainMessageFromString(
 		"The secret code is... 1, 2, 3, 4, 5. I repeat: the secret code is... 1, 2, 3, 4, 5",
@@ -3,10 +3,11 @@ package crypto
 import (
 	"bytes"
 	"crypto"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"math"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
@@ -36,6 +37,25 @@ func (e SignatureVerificationError) Error() string {
 	return fmt.Sprintf("Signature Verification Error: %v", e.Message)
 }
 
+// GetVerificationStatus returns the signature verification status of the error
+// returned by a verifying function: constants.SIGNATURE_OK if err is nil,
+// the status of a SignatureVerificationError, or constants.SIGNATURE_FAILED
+// for any other error.
+func GetVerificationStatus(err error) int {
+	if err == nil {
+		return constants.SIGNATURE_OK
+	}
+	var sigErr SignatureVerificationError
+	if errors.As(err, &sigErr) {
+		return sigErr.Status
+	}
+	var sigErrPtr *SignatureVerificationError
+	if errors.As(err, &sigErrPtr) && sigErrPtr != nil {
+		return sigErrPtr.Status
+	}
+	return constants.SIGNATURE_FAILED
+}
+
 // ------------------
 // Internal functions
 // ------------------
@@ -49,6 +69,15 @@ func newSignatureFailed() SignatureVerificationError {
 	}
 }
 
+// newSignatureExpired creates a new SignatureVerificationError, type
+// SignatureExpired.
+func newSignatureExpired() SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_EXPIRED,
+		Message: "Signature expired",
+	}
+}
+
 // newSignatureInsecure creates a new SignatureVerificationError, type
 // SignatureFailed, with a message describing the signature as insecure.
 func newSignatureInsecure() SignatureVerificationError {
@@ -76,25 +105,115 @@ func newSignatureNoVerifier() SignatureVerificationError {
 	}
 }
 
+// newSignatureTooOld creates a new SignatureVerificationError, type
+// SignatureFailed, for a signature created before the minimum creation time
+// of the verification.
+func newSignatureTooOld() SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: "Invalid signature: created before the minimum creation time",
+	}
+}
+
+// newSignatureContentTypeMismatch creates a new SignatureVerificationError,
+// type SignatureFailed, with a message describing the mismatch between the
+// type of the signature and the content type of the message.
+func newSignatureContentTypeMismatch(isTextSignature bool) SignatureVerificationError {
+	message := "Invalid signature: binary signature over a text message"
+	if isTextSignature {
+		message = "Invalid signature: text signature over a binary message"
+	}
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: message,
+	}
+}
+
+// checkSignatureContentType replaces a failed verification error with one
+// describing the mismatch between the signature type and the content type of
+// the message, if the signature verifies over the message canonicalized for
+// the other signature type. Other failures, e.g. of a modified message, are
+// returned unchanged.
+func checkSignatureContentType(
+	err error, pubKeyEntries openpgp.EntityList, message *PlainMessage, signature []byte,
+	knownNotations map[string]bool,
+) error {
+	if GetVerificationStatus(err) != constants.SIGNATURE_FAILED {
+		return err
+	}
+	// The signatures read before an error can still be checked
+	sigs, _ := readSignaturePackets(signature, knownNotations)
+	for _, sig := range sigs {
+		isTextSignature := sig.SigType == packet.SigTypeText
+		if isTextSignature == message.IsText() {
+			continue
+		}
+		if verifiesWithOtherSignatureType(pubKeyEntries, sig, message.GetBinary()) {
+			return newSignatureContentTypeMismatch(isTextSignature)
+		}
+	}
+	return err
+}
+
+// verifiesWithOtherSignatureType returns whether sig verifies over data
+// hashed as required by the other signature type: as is for a text signature,
+// and with CRLF or LF line endings for a binary signature.
+func verifiesWithOtherSignatureType(pubKeyEntries openpgp.EntityList, sig *packet.Signature, data []byte) bool {
+	if !isAllowedHash(sig.Hash) || !sig.Hash.Available() {
+		return false
+	}
+	candidates := [][]byte{data}
+	if sig.SigType == packet.SigTypeBinary {
+		lf := bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+		candidates = [][]byte{bytes.ReplaceAll(lf, []byte("\n"), []byte("\r\n")), lf}
+	}
+	for _, candidate := range candidates {
+		for _, key := range signatureIssuerKeys(pubKeyEntries, sig) {
+			h := sig.Hash.New()
+			_, _ = h.Write(candidate)
+			if key.PublicKey.VerifySignature(h, sig) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // processSignatureExpiration handles signature time verification manually, so
-// we can add a margin to the creationTime check.
-func processSignatureExpiration(md *openpgp.MessageDetails, verifyTime int64) {
+// we can add a margin of creationTimeOffset to the creationTime check.
+// Signatures past their lifetime are reported as expired by verifyDetailsSignature.
+func processSignatureExpiration(md *openpgp.MessageDetails, verifyTime, creationTimeOffset int64) {
 	if !errors.Is(md.SignatureError, pgpErrors.ErrSignatureExpired) {
 		return
 	}
-	if verifyTime == 0 {
-		// verifyTime = 0: time check disabled, everything is okay
-		md.SignatureError = nil
+	if md.Signature == nil {
 		return
 	}
-	created := md.Signature.CreationTime.Unix()
-	expires := int64(math.MaxInt64)
-	if md.Signature.SigLifetimeSecs != nil {
-		expires = int64(*md.Signature.SigLifetimeSecs) + created
+	timeErr := checkSignatureTime(md.Signature, verifyTime, creationTimeOffset)
+	if timeErr == nil || GetVerificationStatus(timeErr) == constants.SIGNATURE_EXPIRED {
+		md.SignatureError = timeErr
+	}
+}
+
+// checkSignatureTime checks the validity period of sig at verifyTime, with a
+// margin of creationTimeOffset on its creation time.
+// It returns a SignatureExpired error if the lifetime of sig is over, and a
+// SignatureFailed error if sig was created after verifyTime and the margin,
+// both with the clock skew tolerance.
+// If verifyTime = 0, the time check is disabled.
+func checkSignatureTime(sig *packet.Signature, verifyTime, creationTimeOffset int64) error {
+	if verifyTime == 0 {
+		return nil
 	}
-	if created-internal.CreationTimeOffset <= verifyTime && verifyTime <= expires {
-		md.SignatureError = nil
+	created := sig.CreationTime.Unix()
+	if created-creationTimeOffset-GetClockSkewTolerance() > verifyTime {
+		return newSignatureFailed()
+	}
+	if sig.SigLifetimeSecs != nil && *sig.SigLifetimeSecs != 0 &&
+		verifyTime-GetClockSkewTolerance() > created+int64(*sig.SigLifetimeSecs) {
+		return newSignatureExpired()
 	}
+	return nil
 }
 
 // verifyDetailsSignature verifies signature from message details.
@@ -103,23 +222,114 @@ func verifyDetailsSignature(md *openpgp.MessageDetails, verifierKey *KeyRing) er
 		return newSignatureNotSigned()
 	}
 	if md.SignedBy == nil ||
-		len(verifierKey.entities) == 0 ||
-		len(verifierKey.entities.KeysById(md.SignedByKeyId)) == 0 {
+		len(verifierKey.getEntities()) == 0 ||
+		len(verifierKey.getEntities().KeysById(md.SignedByKeyId)) == 0 {
 		return newSignatureNoVerifier()
 	}
-	if md.SignatureError != nil {
+	isExpired := GetVerificationStatus(md.SignatureError) == constants.SIGNATURE_EXPIRED
+	if md.SignatureError != nil && !isExpired {
 		return newSignatureFailed()
 	}
+	if err := checkCrossCertification(*md.SignedBy); err != nil {
+		return err
+	}
 	if md.Signature == nil ||
 		md.Signature.Hash < allowedHashes[0] ||
 		md.Signature.Hash > allowedHashes[len(allowedHashes)-1] {
 		return newSignatureInsecure()
 	}
+	if err := checkIntendedRecipient(md); err != nil {
+		return err
+	}
+	// The signature is otherwise valid
+	if isExpired {
+		return newSignatureExpired()
+	}
 	return nil
 }
 
+// setVerifiedSignature records the creation time and the signing key of the
+// signature of md, once verified, in the decrypted message.
+func (msg *PlainMessage) setVerifiedSignature(md *openpgp.MessageDetails) {
+	if md.Signature == nil || md.SignedBy == nil {
+		return
+	}
+	msg.signatureTime = md.Signature.CreationTime.Unix()
+	msg.signerFingerprint = md.SignedBy.PublicKey.Fingerprint
+}
+
 // verifySignature verifies if a signature is valid with the entity list.
 func verifySignature(pubKeyEntries openpgp.EntityList, origText io.Reader, signature []byte, verifyTime int64) error {
+	_, err := verifySignatureWithReport(pubKeyEntries, origText, signature, verifyTime, internal.CreationTimeOffset, nil)
+	return err
+}
+
+// verifySignatureWithReport verifies if a signature is valid with the entity list,
+// accepting signatures created up to creationTimeOffset seconds after verifyTime,
+// and reports the unknown subpackets of the signature.
+// The critical notations named in knownNotations are accepted, other critical
+// notations are unknown critical subpackets.
+// Signatures with unknown critical subpackets are ignored by the parser,
+// so if no other signature could be verified, the verification fails with
+// an error naming the first such subpacket.
+func verifySignatureWithReport(
+	pubKeyEntries openpgp.EntityList, origText io.Reader, signature []byte, verifyTime, creationTimeOffset int64,
+	knownNotations map[string]bool,
+) (*VerificationReport, error) {
+	report := &VerificationReport{}
+	// Errors are reported by the parser below
+	report.UnknownCriticalSubpackets, report.UnknownNonCriticalSubpackets, _ = scanSignatureSubpackets(
+		signature, knownNotations,
+	)
+
+	err := checkDetachedSignature(pubKeyEntries, origText, signature, verifyTime, creationTimeOffset, knownNotations)
+	if err != nil && report.HasUnknownCriticalSubpackets() {
+		err = newSignatureUnknownCriticalSubpacket(report.UnknownCriticalSubpackets[0])
+	}
+
+	report.Status = GetVerificationStatus(err)
+	if err != nil {
+		report.Message = err.Error()
+	} else {
+		// The signature packets were parsed when verifying them
+		report.Notations, _ = getNotations(signature)
+		if sig, ok := getVerifiedSignaturePacket(pubKeyEntries, signature, knownNotations); ok {
+			report.SignatureCreationTime = sig.CreationTime.Unix()
+		}
+	}
+	return report, err
+}
+
+// checkDetachedSignature verifies if a signature is valid with the entity list,
+// accepting signatures created up to creationTimeOffset seconds after verifyTime.
+// The critical notations named in knownNotations, which go-crypto rejects as
+// unknown critical subpackets, are accepted.
+func checkDetachedSignature(
+	pubKeyEntries openpgp.EntityList, origText io.Reader, signature []byte, verifyTime, creationTimeOffset int64,
+	knownNotations map[string]bool,
+) error {
+	config := verificationConfig(verifyTime, creationTimeOffset)
+	var signer *openpgp.Entity
+	var err error
+	if hasOnlyKnownCriticalNotations(signature, knownNotations) {
+		var sigs []*packet.Signature
+		if sigs, err = readSignaturePackets(signature, knownNotations); err != nil {
+			return newSignatureFailed()
+		}
+		signer, err = checkSignaturePackets(pubKeyEntries, sigs, config, func(sig *packet.Signature) (hash.Hash, error) {
+			return hashSignedData(sig, origText)
+		})
+	} else {
+		signer, err = openpgp.CheckDetachedSignatureAndHash(
+			pubKeyEntries, origText, bytes.NewReader(signature), allowedHashes, config,
+		)
+	}
+	return checkSigner(pubKeyEntries, signer, err, signature, verifyTime, creationTimeOffset, knownNotations)
+}
+
+// verificationConfig returns the configuration to verify signatures at
+// verifyTime, created up to creationTimeOffset seconds after it.
+func verificationConfig(verifyTime, creationTimeOffset int64) *packet.Config {
 	config := &packet.Config{}
 	if verifyTime == 0 {
 		config.Time = func() time.Time {
@@ -127,35 +337,99 @@ func verifySignature(pubKeyEntries openpgp.EntityList, origText io.Reader, signa
 		}
 	} else {
 		config.Time = func() time.Time {
-			return time.Unix(verifyTime+internal.CreationTimeOffset, 0)
+			return time.Unix(verifyTime+creationTimeOffset, 0)
 		}
 	}
-	signatureReader := bytes.NewReader(signature)
-
-	signer, err := openpgp.CheckDetachedSignatureAndHash(pubKeyEntries, origText, signatureReader, allowedHashes, config)
-
-	if errors.Is(err, pgpErrors.ErrSignatureExpired) && signer != nil && verifyTime > 0 {
-		// if verifyTime = 0: time check disabled, everything is okay
-		// Maybe the creation time offset pushed it over the edge
-		// Retry with the actual verification time
-		config.Time = func() time.Time {
-			return time.Unix(verifyTime, 0)
-		}
+	return config
+}
 
-		_, err = signatureReader.Seek(0, io.SeekStart)
-		if err != nil {
+// checkSigner checks the result of the verification of signature by signer,
+// with the signature time, accepting signatures created up to
+// creationTimeOffset seconds after verifyTime, the revocation of the signing
+// key and its cross-certification.
+func checkSigner(
+	pubKeyEntries openpgp.EntityList, signer *openpgp.Entity, err error, signature []byte,
+	verifyTime, creationTimeOffset int64, knownNotations map[string]bool,
+) error {
+	if errors.Is(err, pgpErrors.ErrSignatureExpired) && signer != nil {
+		// go-crypto only checks the signature time once the signature
+		// is verified, so it can be checked again with the creation time
+		// margin without re-reading origText.
+		sig, ok := getVerifiedSignaturePacket(pubKeyEntries, signature, knownNotations)
+		if !ok {
 			return newSignatureFailed()
 		}
-
-		signer, err = openpgp.CheckDetachedSignatureAndHash(pubKeyEntries, origText, signatureReader, allowedHashes, config)
-		if err != nil {
-			return newSignatureFailed()
+		timeErr := checkSignatureTime(sig, verifyTime, creationTimeOffset)
+		if GetVerificationStatus(timeErr) == constants.SIGNATURE_FAILED {
+			return timeErr
 		}
+		if err := checkSignerCrossCertification(pubKeyEntries, signer, signature, knownNotations); err != nil {
+			return err
+		}
+		return timeErr
+	}
+
+	if errors.Is(err, pgpErrors.ErrKeyRevoked) {
+		return newSignatureFailed()
 	}
 
 	if signer == nil {
 		return newSignatureFailed()
 	}
 
-	return nil
+	return checkSignerCrossCertification(pubKeyEntries, signer, signature, knownNotations)
+}
+
+// signatureIssuerKeyID returns the key ID of the issuer of sig, from its
+// issuer subpacket or else from its issuer fingerprint subpacket.
+func signatureIssuerKeyID(sig *packet.Signature) (uint64, bool) {
+	if sig.IssuerKeyId != nil {
+		return *sig.IssuerKeyId, true
+	}
+	switch len(sig.IssuerFingerprint) {
+	case 20:
+		// v4 key IDs are the low 64 bits of the fingerprint
+		return binary.BigEndian.Uint64(sig.IssuerFingerprint[12:]), true
+	case 32:
+		// v5 key IDs are the high 64 bits of the fingerprint
+		return binary.BigEndian.Uint64(sig.IssuerFingerprint[:8]), true
+	}
+	return 0, false
+}
+
+// signatureIssuerKeys returns the signing keys of pubKeyEntries that may have
+// issued sig: the keys with its issuer fingerprint if it has one, or else the
+// keys with its issuer key ID.
+func signatureIssuerKeys(pubKeyEntries openpgp.EntityList, sig *packet.Signature) []openpgp.Key {
+	keyID, ok := signatureIssuerKeyID(sig)
+	if !ok {
+		return nil
+	}
+	keys := pubKeyEntries.KeysByIdUsage(keyID, packet.KeyFlagSign)
+	if len(sig.IssuerFingerprint) == 0 {
+		return keys
+	}
+	var issuerKeys []openpgp.Key
+	for _, key := range keys {
+		if bytes.Equal(key.PublicKey.Fingerprint, sig.IssuerFingerprint) {
+			issuerKeys = append(issuerKeys, key)
+		}
+	}
+	return issuerKeys
+}
+
+// getVerifiedSignaturePacket returns the signature packet that go-crypto
+// verifies in a detached signature: the first one issued by a signing key of
+// pubKeyEntries.
+func getVerifiedSignaturePacket(
+	pubKeyEntries openpgp.EntityList, signature []byte, knownNotations map[string]bool,
+) (*packet.Signature, bool) {
+	// The signatures read before an error are those go-crypto verifies
+	sigs, _ := readSignaturePackets(signature, knownNotations)
+	for _, sig := range sigs {
+		if len(signatureIssuerKeys(pubKeyEntries, sig)) > 0 {
+			return sig, true
+		}
+	}
+	return nil, false
 }
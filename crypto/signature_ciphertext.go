@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// SignatureTarget is the data covered by the detached signature of an
+// encrypted message.
+type SignatureTarget int
+
+const (
+	// SignPlaintext signs the plaintext before encryption (sign-then-encrypt).
+	// The signature can only be verified after decryption.
+	SignPlaintext SignatureTarget = iota
+	// SignCiphertext signs the encrypted data packet (encrypt-then-sign), as
+	// done for drafts. The signature can be verified without decrypting, and
+	// stays valid when the session key is encrypted to other recipients.
+	SignCiphertext
+)
+
+// EncryptWithDetachedSignature encrypts message to the keyring and signs it
+// with signKeyRing, returning the split message and a detached binary
+// signature over the data selected by target. Encrypt signs inside the
+// encryption instead. DecryptAndVerify verifies either arrangement.
+func (keyRing *KeyRing) EncryptWithDetachedSignature(
+	message *PlainMessage, signKeyRing *KeyRing, target SignatureTarget,
+) (*PGPSplitMessage, *PGPSignature, error) {
+	if signKeyRing == nil {
+		return nil, nil, errors.New("gopenpgp: no signing key ring provided")
+	}
+	if err := target.check(); err != nil {
+		return nil, nil, err
+	}
+
+	encrypted, err := keyRing.Encrypt(message, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	split, err := encrypted.SplitMessage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var signature *PGPSignature
+	if target == SignCiphertext {
+		signature, err = signKeyRing.SignDetachedCiphertext(split)
+	} else {
+		signature, err = signKeyRing.SignDetached(message)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return split, signature, nil
+}
+
+// DecryptWithDetachedSignature decrypts message with the keyring and verifies
+// the detached signature over the data selected by target with verifyKeyRing,
+// returning a SignatureVerificationError if the verification fails.
+// With SignCiphertext, the signature is verified before decrypting, and
+// message isn't decrypted if the verification fails.
+func (keyRing *KeyRing) DecryptWithDetachedSignature(
+	message *PGPSplitMessage, signature *PGPSignature, verifyKeyRing *KeyRing, verifyTime int64,
+	target SignatureTarget,
+) (*PlainMessage, error) {
+	if verifyKeyRing == nil {
+		return nil, errors.New("gopenpgp: no verification key ring provided")
+	}
+	if err := target.check(); err != nil {
+		return nil, err
+	}
+
+	if target == SignCiphertext {
+		if err := verifyKeyRing.VerifyDetachedCiphertext(message, signature, verifyTime); err != nil {
+			return nil, err
+		}
+	}
+
+	plainMessage, err := keyRing.Decrypt(message.GetPGPMessage(), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if target == SignPlaintext {
+		if err = verifyKeyRing.VerifyDetached(plainMessage, signature, verifyTime); err != nil {
+			return nil, err
+		}
+	}
+	return plainMessage, nil
+}
+
+// DecryptAndVerify decrypts message with the keyring and verifies its
+// signatures with verifyKeyRing, detecting how it was signed, and returns a
+// SignatureVerificationError if the verification fails.
+// Without externalSignature, the message must have a valid signature inside
+// the encryption, as made by Encrypt. Otherwise, externalSignature is
+// verified over the data packet (SignCiphertext), or else over the decrypted
+// plaintext (SignPlaintext), and the signature inside the encryption, if the
+// message has one, must also be valid.
+func (keyRing *KeyRing) DecryptAndVerify(
+	message *PGPSplitMessage, externalSignature *PGPSignature, verifyKeyRing *KeyRing, verifyTime int64,
+) (*PlainMessage, error) {
+	if verifyKeyRing == nil {
+		return nil, errors.New("gopenpgp: no verification key ring provided")
+	}
+	if externalSignature == nil {
+		return keyRing.Decrypt(message.GetPGPMessage(), verifyKeyRing, verifyTime)
+	}
+
+	ciphertextErr := verifyKeyRing.VerifyDetachedCiphertext(message, externalSignature, verifyTime)
+
+	plainMessage, err := keyRing.Decrypt(message.GetPGPMessage(), verifyKeyRing, verifyTime)
+	var sigErr SignatureVerificationError
+	if err != nil && !(errors.As(err, &sigErr) && sigErr.Status == constants.SIGNATURE_NOT_SIGNED) {
+		return nil, err
+	}
+
+	if ciphertextErr != nil {
+		if err = verifyKeyRing.VerifyDetached(plainMessage, externalSignature, verifyTime); err != nil {
+			return nil, err
+		}
+	}
+	return plainMessage, nil
+}
+
+// SignDetachedCiphertext generates a detached binary signature over the
+// binary data packet of message (encrypt-then-sign). The key packet isn't
+// signed, so that the session key can be encrypted to other recipients.
+func (keyRing *KeyRing) SignDetachedCiphertext(message *PGPSplitMessage) (*PGPSignature, error) {
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signDetached(signEntity, bytes.NewReader(message.GetBinaryDataPacket()), false, getTimeGenerator())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// VerifyDetachedCiphertext verifies a detached signature over the binary
+// data packet of message, as generated by SignDetachedCiphertext, without
+// decrypting it, and returns a SignatureVerificationError if fails.
+func (keyRing *KeyRing) VerifyDetachedCiphertext(message *PGPSplitMessage, signature *PGPSignature, verifyTime int64) error {
+	return verifySignature(
+		keyRing.getEntities(),
+		bytes.NewReader(message.GetBinaryDataPacket()),
+		signature.GetBinary(),
+		verifyTime,
+	)
+}
+
+func (target SignatureTarget) check() error {
+	if target != SignPlaintext && target != SignCiphertext {
+		return errors.New("gopenpgp: unknown signature target")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetachedSignatureOverCiphertext(t *testing.T) {
+	message := NewPlainMessageFromString("encrypt then sign")
+
+	split, signature, err := keyRingTestPublic.EncryptWithDetachedSignature(message, keyRingTestPrivate, SignCiphertext)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting and signing, got:", err)
+	}
+
+	if err = keyRingTestPublic.VerifyDetachedCiphertext(split, signature, testTime); err != nil {
+		t.Fatal("Expected no error when verifying the ciphertext signature, got:", err)
+	}
+	err = keyRingTestPublic.VerifyDetached(message, signature, testTime)
+	assert.True(t, errors.As(err, &SignatureVerificationError{}))
+
+	decrypted, err := keyRingTestPrivate.DecryptWithDetachedSignature(
+		split, signature, keyRingTestPublic, testTime, SignCiphertext,
+	)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting and verifying, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	_, err = keyRingTestPrivate.DecryptWithDetachedSignature(
+		split, signature, keyRingTestPublic, testTime, SignPlaintext,
+	)
+	assert.True(t, errors.As(err, &SignatureVerificationError{}))
+
+	tampered := NewPGPSplitMessage(split.GetBinaryKeyPacket(), clone(split.GetBinaryDataPacket()))
+	tampered.DataPacket[len(tampered.DataPacket)-1] ^= 1
+	_, err = keyRingTestPrivate.DecryptWithDetachedSignature(
+		tampered, signature, keyRingTestPublic, testTime, SignCiphertext,
+	)
+	assert.True(t, errors.As(err, &SignatureVerificationError{}))
+}
+
+func TestDetachedSignatureOverPlaintext(t *testing.T) {
+	message := NewPlainMessageFromString("sign then encrypt")
+
+	split, signature, err := keyRingTestPublic.EncryptWithDetachedSignature(message, keyRingTestPrivate, SignPlaintext)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting and signing, got:", err)
+	}
+
+	if err = keyRingTestPublic.VerifyDetached(message, signature, testTime); err != nil {
+		t.Fatal("Expected no error when verifying the plaintext signature, got:", err)
+	}
+	err = keyRingTestPublic.VerifyDetachedCiphertext(split, signature, testTime)
+	assert.True(t, errors.As(err, &SignatureVerificationError{}))
+
+	decrypted, err := keyRingTestPrivate.DecryptWithDetachedSignature(
+		split, signature, keyRingTestPublic, testTime, SignPlaintext,
+	)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting and verifying, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	_, _, err = keyRingTestPublic.EncryptWithDetachedSignature(message, keyRingTestPrivate, SignatureTarget(2))
+	assert.Error(t, err)
+}
+
+func TestDecryptAndVerify(t *testing.T) {
+	message := NewPlainMessageFromString("sign and encrypt arrangements")
+
+	// Signed inside the encryption
+	encrypted, err := keyRingTestPublic.Encrypt(message, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting and signing, got:", err)
+	}
+	inline, err := encrypted.SplitMessage()
+	if err != nil {
+		t.Fatal("Expected no error when splitting, got:", err)
+	}
+	decrypted, err := keyRingTestPrivate.DecryptAndVerify(inline, nil, keyRingTestPublic, testTime)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting and verifying, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	// Detached signatures over the ciphertext and the plaintext
+	for _, target := range []SignatureTarget{SignCiphertext, SignPlaintext} {
+		split, signature, err := keyRingTestPublic.EncryptWithDetachedSignature(message, keyRingTestPrivate, target)
+		if err != nil {
+			t.Fatal("Expected no error when encrypting and signing, got:", err)
+		}
+		decrypted, err = keyRingTestPrivate.DecryptAndVerify(split, signature, keyRingTestPublic, testTime)
+		if err != nil {
+			t.Fatal("Expected no error when decrypting and verifying, got:", err)
+		}
+		assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+		_, err = keyRingTestPrivate.DecryptAndVerify(split, nil, keyRingTestPublic, testTime)
+		assert.True(t, errors.As(err, &SignatureVerificationError{}))
+
+		otherSplit, otherSignature, err := keyRingTestPublic.EncryptWithDetachedSignature(
+			NewPlainMessageFromString("other"), keyRingTestPrivate, target,
+		)
+		if err != nil {
+			t.Fatal("Expected no error when encrypting and signing, got:", err)
+		}
+		_, err = keyRingTestPrivate.DecryptAndVerify(split, otherSignature, keyRingTestPublic, testTime)
+		assert.True(t, errors.As(err, &SignatureVerificationError{}))
+		_, err = keyRingTestPrivate.DecryptAndVerify(otherSplit, signature, keyRingTestPublic, testTime)
+		assert.True(t, errors.As(err, &SignatureVerificationError{}))
+	}
+
+	// Both an inline and an external signature
+	signature, err := keyRingTestPrivate.SignDetachedCiphertext(inline)
+	if err != nil {
+		t.Fatal("Expected no error when signing the ciphertext, got:", err)
+	}
+	decrypted, err = keyRingTestPrivate.DecryptAndVerify(inline, signature, keyRingTestPublic, testTime)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting and verifying, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	_, err = keyRingTestPrivate.DecryptAndVerify(inline, nil, nil, testTime)
+	assert.Error(t, err)
+}
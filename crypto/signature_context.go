@@ -0,0 +1,346 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	goerrors "errors"
+	"hash"
+	"io"
+	"strconv"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+// SigningContext is the context of a signature, written in the
+// constants.SignatureContextName notation, so that signatures made for one
+// purpose can't be used for another.
+type SigningContext struct {
+	Value string
+	// IsCritical makes the signature invalid for verifiers that don't know
+	// about contexts.
+	IsCritical bool
+}
+
+// NewSigningContext creates a new signing context.
+func NewSigningContext(value string, isCritical bool) *SigningContext {
+	return &SigningContext{Value: value, IsCritical: isCritical}
+}
+
+// VerificationContext is the context signatures are expected to have.
+type VerificationContext struct {
+	Value string
+	// IsRequired rejects signatures without a context
+	IsRequired bool
+	// RequiredAfter is the unix time from which signatures without a
+	// context are rejected, if IsRequired. Zero means always.
+	RequiredAfter int64
+}
+
+// NewVerificationContext creates a new verification context.
+func NewVerificationContext(value string, isRequired bool, requiredAfter int64) *VerificationContext {
+	return &VerificationContext{
+		Value:         value,
+		IsRequired:    isRequired,
+		RequiredAfter: requiredAfter,
+	}
+}
+
+// SignDetachedWithContext generates and returns a PGPSignature for a given
+// PlainMessage like SignDetached, with the signing context in the hashed area
+// of the signature.
+func (keyRing *KeyRing) SignDetachedWithContext(message *PlainMessage, context *SigningContext) (*PGPSignature, error) {
+	if context == nil {
+		return nil, errors.New("gopenpgp: no signing context provided")
+	}
+	return keyRing.SignDetachedWithNotations(message, []*Notation{{
+		Name:            constants.SignatureContextName,
+		Value:           []byte(context.Value),
+		IsHumanReadable: true,
+		IsCritical:      context.IsCritical,
+	}})
+}
+
+// VerifyDetachedWithContext verifies a PlainMessage with a detached
+// PGPSignature like VerifyDetached, and checks that the context of the
+// signature matches the verification context.
+// Signatures with a different context are rejected, and so are signatures
+// without a context if the context is required at their creation time.
+func (keyRing *KeyRing) VerifyDetachedWithContext(
+	message *PlainMessage, signature *PGPSignature, verifyTime int64, context *VerificationContext,
+) error {
+	if context == nil {
+		return errors.New("gopenpgp: no verification context provided")
+	}
+
+	knownNotations := map[string]bool{constants.SignatureContextName: true}
+	entities := keyRing.getEntities()
+	_, err := verifySignatureWithReport(
+		entities, message.NewReader(), signature.GetBinary(), verifyTime, internal.CreationTimeOffset,
+		knownNotations,
+	)
+	if err != nil {
+		return checkSignatureContentType(err, entities, message, signature.GetBinary(), knownNotations)
+	}
+
+	return checkSignatureContext(signature.GetBinary(), context)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// newSignatureContextMismatch creates a new SignatureVerificationError, type
+// SignatureFailed, for a signature with a missing or different context.
+func newSignatureContextMismatch(message string) SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: "Invalid signature: " + message,
+	}
+}
+
+// checkSignatureContext checks the context of the verified signature.
+func checkSignatureContext(signature []byte, context *VerificationContext) error {
+	notations, err := getNotations(signature)
+	if err != nil {
+		return newSignatureFailed()
+	}
+	found := false
+	for _, notation := range notations {
+		if notation.Name != constants.SignatureContextName {
+			continue
+		}
+		if string(notation.Value) != context.Value {
+			return newSignatureContextMismatch("signature context mismatch")
+		}
+		found = true
+	}
+	if found || !context.IsRequired {
+		return nil
+	}
+
+	if context.RequiredAfter != 0 {
+		creationTime, ok := getSignatureCreationTime(signature)
+		if ok && creationTime < context.RequiredAfter {
+			return nil
+		}
+	}
+	return newSignatureContextMismatch("missing signature context")
+}
+
+// getSignatureCreationTime returns the creation time of the first signature
+// packet in signature, read without parsing the signature.
+func getSignatureCreationTime(signature []byte) (int64, bool) {
+	var creationTime int64
+	found := false
+	_ = forEachSignatureSubpacket(signature, func(subpacket []byte, isHashed bool) {
+		// Signature creation time, RFC 4880, section 5.2.3.4
+		if !found && isHashed && subpacket[0]&0x7f == 2 && len(subpacket) == 5 {
+			creationTime = int64(binary.BigEndian.Uint32(subpacket[1:]))
+			found = true
+		}
+	})
+	return creationTime, found
+}
+
+// hasOnlyKnownCriticalNotations returns whether the signature packets in data
+// contain critical notations, all of them known, and no other unknown
+// critical subpackets.
+func hasOnlyKnownCriticalNotations(data []byte, knownNotations map[string]bool) bool {
+	hasKnown, hasUnknown := false, false
+	err := forEachSignatureSubpacket(data, func(subpacket []byte, isHashed bool) {
+		if subpacket[0]&0x80 == 0 {
+			return
+		}
+		subpacketType := int(subpacket[0] & 0x7f)
+		if subpacketType != notationDataSubpacket {
+			hasUnknown = hasUnknown || !knownSignatureSubpackets[subpacketType]
+			return
+		}
+		if isKnownNotation(subpacket, isHashed, knownNotations) {
+			hasKnown = true
+		} else {
+			hasUnknown = true
+		}
+	})
+	return err == nil && hasKnown && !hasUnknown
+}
+
+// checkSignaturePackets verifies the first of sigs issued by a signing key of
+// pubKeyEntries like openpgp.CheckDetachedSignatureAndHash, over the data
+// hashed by hashSigned, for the signatures go-crypto can't parse.
+func checkSignaturePackets(
+	pubKeyEntries openpgp.EntityList, sigs []*packet.Signature, config *packet.Config,
+	hashSigned func(sig *packet.Signature) (hash.Hash, error),
+) (*openpgp.Entity, error) {
+	var sig *packet.Signature
+	var keys []openpgp.Key
+	for _, candidate := range sigs {
+		if _, hasIssuer := signatureIssuerKeyID(candidate); !hasIssuer {
+			return nil, pgpErrors.StructuralError("signature doesn't have an issuer")
+		}
+		if !isAllowedHash(candidate.Hash) {
+			return nil, pgpErrors.StructuralError("hash algorithm not allowed")
+		}
+		if keys = signatureIssuerKeys(pubKeyEntries, candidate); len(keys) > 0 {
+			sig = candidate
+			break
+		}
+	}
+	if sig == nil {
+		return nil, pgpErrors.ErrUnknownIssuer
+	}
+
+	h, err := hashSigned(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	now := config.Now()
+	for _, key := range keys {
+		if err = key.PublicKey.VerifySignature(h, sig); err != nil {
+			continue
+		}
+		if key.Revoked(now) || key.Entity.Revoked(now) || key.Entity.PrimaryIdentity().Revoked(now) {
+			return key.Entity, pgpErrors.ErrKeyRevoked
+		}
+		if sig.SigExpired(now) {
+			return key.Entity, pgpErrors.ErrSignatureExpired
+		}
+		if key.PublicKey.KeyExpired(key.SelfSignature, now) || key.SelfSignature.SigExpired(now) {
+			return key.Entity, pgpErrors.ErrKeyExpired
+		}
+		return key.Entity, nil
+	}
+	return nil, err
+}
+
+// hashSignedData hashes the data signed by a binary or text signature.
+func hashSignedData(sig *packet.Signature, origText io.Reader) (hash.Hash, error) {
+	h := sig.Hash.New()
+	wrappedHash := h
+	switch sig.SigType {
+	case packet.SigTypeBinary:
+	case packet.SigTypeText:
+		wrappedHash = openpgp.NewCanonicalTextHash(h)
+	default:
+		return nil, pgpErrors.UnsupportedError("signature type " + strconv.Itoa(int(sig.SigType)))
+	}
+	if _, err := copyPooled(wrappedHash, origText); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// isAllowedHash returns whether signatures made with hash are accepted.
+func isAllowedHash(hash crypto.Hash) bool {
+	for _, allowed := range allowedHashes {
+		if hash == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// readSignaturePackets parses the signature packets in data like go-crypto,
+// skipping the other packets and the signatures it doesn't support, or with
+// readSignaturesWithKnownNotations if their only critical subpackets are
+// known notations. The signatures read before an error are returned with it.
+func readSignaturePackets(data []byte, knownNotations map[string]bool) ([]*packet.Signature, error) {
+	if hasOnlyKnownCriticalNotations(data, knownNotations) {
+		return readSignaturesWithKnownNotations(data, knownNotations)
+	}
+	var sigs []*packet.Signature
+	packets := packet.NewReader(bytes.NewReader(data))
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			return sigs, nil
+		}
+		if err != nil {
+			return sigs, err
+		}
+		if sig, ok := p.(*packet.Signature); ok {
+			sigs = append(sigs, sig)
+		}
+	}
+}
+
+// readSignaturesWithKnownNotations parses the signature packets in data, with
+// the critical flag of the known notations cleared so that go-crypto accepts
+// them. The hash suffix of the parsed v4 signatures is restored from the
+// original packets, for the signatures to verify.
+func readSignaturesWithKnownNotations(data []byte, knownNotations map[string]bool) ([]*packet.Signature, error) {
+	var sigs []*packet.Signature
+	for len(data) > 0 {
+		tag, body, rest, err := readRawPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+		if tag == packetTagMarker || tag == packetTagPadding {
+			continue
+		}
+		if tag != signaturePacketTag {
+			return nil, errors.New("gopenpgp: non signature packet found")
+		}
+
+		var hashSuffix []byte
+		if len(body) >= 6 && body[0] == 4 {
+			hashedLen := int(binary.BigEndian.Uint16(body[4:6]))
+			if 6+hashedLen > len(body) {
+				return nil, errors.New("gopenpgp: truncated signature packet")
+			}
+			hashSuffix = make([]byte, 0, 6+hashedLen+6)
+			hashSuffix = append(hashSuffix, body[:6+hashedLen]...)
+			hashSuffix = append(hashSuffix, 4, 0xff, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(hashSuffix[6+hashedLen+2:], uint32(6+hashedLen))
+
+			body = clone(body)
+			if err := clearKnownNotationsCriticalFlag(body[6:6+hashedLen], knownNotations); err != nil {
+				return nil, err
+			}
+		}
+
+		packetData := make([]byte, 6, 6+len(body))
+		packetData[0], packetData[1] = 0xc0|signaturePacketTag, 0xff
+		binary.BigEndian.PutUint32(packetData[2:], uint32(len(body)))
+		p, err := packet.Read(bytes.NewReader(append(packetData, body...)))
+		if err != nil {
+			return nil, err
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			return nil, errors.New("gopenpgp: non signature packet found")
+		}
+		if hashSuffix != nil {
+			sig.HashSuffix = hashSuffix
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// clearKnownNotationsCriticalFlag clears, in place, the critical flag of the
+// known notations in the subpacket area.
+func clearKnownNotationsCriticalFlag(area []byte, knownNotations map[string]bool) error {
+	for offset := 0; offset < len(area); {
+		subpacket, rest, err := readSubpacket(area[offset:])
+		if err != nil {
+			return err
+		}
+		typeOffset := len(area) - len(rest) - len(subpacket)
+		if int(subpacket[0]&0x7f) == notationDataSubpacket {
+			notation, err := parseNotation(subpacket[1:], true)
+			if err == nil && knownNotations[notation.Name] {
+				area[typeOffset] &^= 0x80
+			}
+		}
+		offset = len(area) - len(rest)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestSignatureContext(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed with a context")
+	verificationContext := NewVerificationContext("test-context", true, 0)
+
+	for _, isCritical := range []bool{false, true} {
+		signature, err := keyRing.SignDetachedWithContext(message, NewSigningContext("test-context", isCritical))
+		if err != nil {
+			t.Fatal("Expected no error when signing, got:", err)
+		}
+
+		assert.Nil(t, keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), verificationContext))
+		assert.Nil(t, keyRing.VerifyDetachedWithContext(message, signature, 0, verificationContext))
+
+		err = keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), NewVerificationContext("other-context", false, 0))
+		assert.Exactly(t, newSignatureContextMismatch("signature context mismatch"), err)
+
+		tampered := NewPlainMessageFromString("tampered")
+		err = keyRing.VerifyDetachedWithContext(tampered, signature, GetUnixTime(), verificationContext)
+		assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+		// Verifiers unaware of contexts reject critical contexts
+		err = keyRing.VerifyDetached(message, signature, GetUnixTime())
+		if isCritical {
+			assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	err = keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), verificationContext)
+	assert.Exactly(t, newSignatureContextMismatch("missing signature context"), err)
+
+	notRequired := NewVerificationContext("test-context", false, 0)
+	assert.Nil(t, keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), notRequired))
+
+	requiredLater := NewVerificationContext("test-context", true, GetUnixTime()+3600)
+	assert.Nil(t, keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), requiredLater))
+
+	requiredBefore := NewVerificationContext("test-context", true, GetUnixTime()-3600)
+	err = keyRing.VerifyDetachedWithContext(message, signature, GetUnixTime(), requiredBefore)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	publicKeyRing, err := keyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error when getting public keys, got:", err)
+	}
+	criticalSignature, _ := keyRing.SignDetachedWithContext(message, NewSigningContext("test-context", true))
+	assert.Nil(t, publicKeyRing.VerifyDetachedWithContext(message, criticalSignature, GetUnixTime(), verificationContext))
+	err = keyRingTestPublic.VerifyDetachedWithContext(message, criticalSignature, GetUnixTime(), verificationContext)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestSignatureContextWithMarkerAndPadding(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed with a context")
+	signature, err := keyRing.SignDetachedWithContext(message, NewSigningContext("test-context", true))
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	marker := []byte{0xc0 | packetTagMarker, 3, 'P', 'G', 'P'}
+	padding, err := newPaddingPacket(16)
+	if err != nil {
+		t.Fatal("Expected no error when generating padding, got:", err)
+	}
+	padded := NewPGPSignature(joinPackets(marker, joinPackets(signature.GetBinary(), padding)))
+	verificationContext := NewVerificationContext("test-context", true, 0)
+	assert.Nil(t, keyRing.VerifyDetachedWithContext(message, padded, GetUnixTime(), verificationContext))
+}
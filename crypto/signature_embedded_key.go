@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"bytes"
+	goerrors "errors"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/pkg/errors"
+)
+
+// SignDetachedWithSignerKey generates and returns a PGPSignature for a given
+// PlainMessage, followed by the minimized public key of the signer, so that
+// the signature is self-contained.
+// The embedded key is only used by VerifyDetachedWithEmbeddedSignerKey.
+func (keyRing *KeyRing) SignDetachedWithSignerKey(message *PlainMessage) (*PGPSignature, error) {
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signDetached(signEntity, message.NewReader(), message.IsText(), getTimeGenerator())
+	if err != nil {
+		return nil, err
+	}
+	outBuf := bytes.NewBuffer(signature)
+
+	signingKey, ok := signingKeyAt(signEntity, getNow(), 0)
+	if !ok {
+		return nil, errors.New("gopenpgp: no valid signing key found")
+	}
+	keepSigningKey := func(subkey *openpgp.Subkey) bool {
+		return subkey.PublicKey.KeyId == signingKey.PublicKey.KeyId
+	}
+	if err := serializeMinimalPublicKey(outBuf, signEntity, keepSigningKey); err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(outBuf.Bytes()), nil
+}
+
+// GetEmbeddedSignerKey returns the signer's public key embedded in the
+// signature by SignDetachedWithSignerKey.
+// The key is not authenticated in any way and must not be trusted as is.
+func (sig *PGPSignature) GetEmbeddedSignerKey() (*Key, error) {
+	_, keyData, err := sig.splitEmbeddedSignerKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyData) == 0 {
+		return nil, errors.New("gopenpgp: no embedded signer key found")
+	}
+	return NewKey(keyData)
+}
+
+// VerifyDetachedWithEmbeddedSignerKey verifies a PlainMessage with a detached
+// PGPSignature using the signer key embedded in the signature, and returns a
+// SignatureVerificationError if fails.
+// The embedded key is only accepted if its fingerprint matches pinnedFingerprint.
+func VerifyDetachedWithEmbeddedSignerKey(
+	message *PlainMessage, signature *PGPSignature, pinnedFingerprint string, verifyTime int64,
+) error {
+	if pinnedFingerprint == "" {
+		return errors.New("gopenpgp: a pinned fingerprint is required to use the embedded signer key")
+	}
+
+	sigData, keyData, err := signature.splitEmbeddedSignerKey()
+	if err != nil {
+		return err
+	}
+	if len(keyData) == 0 {
+		return newSignatureNoVerifier()
+	}
+
+	signerKey, err := NewKey(keyData)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(signerKey.GetFingerprint(), pinnedFingerprint) {
+		return SignatureVerificationError{
+			Status:  constants.SIGNATURE_NO_VERIFIER,
+			Message: "Embedded signer key does not match the pinned fingerprint",
+		}
+	}
+
+	return verifySignature(
+		openpgp.EntityList{signerKey.entity},
+		message.NewReader(),
+		sigData,
+		verifyTime,
+	)
+}
+
+// splitEmbeddedSignerKey separates the signature packets from the embedded
+// signer key packets that follow them.
+func (sig *PGPSignature) splitEmbeddedSignerKey() (sigData, keyData []byte, err error) {
+	bytesReader := bytes.NewReader(sig.Data)
+	packets := packet.NewReader(bytesReader)
+	splitPoint := 0
+Loop:
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "gopenpgp: error in reading signature packets")
+		}
+		if _, ok := p.(*packet.Signature); !ok {
+			break Loop
+		}
+		splitPoint = len(sig.Data) - bytesReader.Len()
+	}
+	return sig.Data[:splitPoint], sig.Data[splitPoint:], nil
+}
+
+// serializeMinimalPublicKey writes the primary key and its revocations, the
+// primary user ID and its self-signature, and the subkeys selected by keep, to w.
+func serializeMinimalPublicKey(w io.Writer, entity *openpgp.Entity, keep func(*openpgp.Subkey) bool) error {
+	if err := entity.PrimaryKey.Serialize(w); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in serializing primary key")
+	}
+	for _, revocation := range entity.Revocations {
+		if err := revocation.Serialize(w); err != nil {
+			return errors.Wrap(err, "gopenpgp: error in serializing key revocation")
+		}
+	}
+
+	identity := entity.PrimaryIdentity()
+	if identity == nil || identity.SelfSignature == nil {
+		return errors.New("gopenpgp: no self-signature found")
+	}
+	if err := identity.UserId.Serialize(w); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in serializing user ID")
+	}
+	if err := identity.SelfSignature.Serialize(w); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in serializing self-signature")
+	}
+
+	for i := range entity.Subkeys {
+		subkey := &entity.Subkeys[i]
+		if !keep(subkey) {
+			continue
+		}
+		if err := subkey.PublicKey.Serialize(w); err != nil {
+			return errors.Wrap(err, "gopenpgp: error in serializing subkey")
+		}
+		if err := subkey.Sig.Serialize(w); err != nil {
+			return errors.Wrap(err, "gopenpgp: error in serializing subkey binding signature")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignDetachedWithSignerKey(t *testing.T) {
+	message := NewPlainMessageFromString("Signed artifact")
+
+	signature, err := keyRingTestPrivate.SignDetachedWithSignerKey(message)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+
+	armored, err := signature.GetArmored()
+	if err != nil {
+		t.Fatal("Cannot armor signature:", err)
+	}
+	signature, err = NewPGPSignatureFromArmored(armored)
+	if err != nil {
+		t.Fatal("Cannot unarmor signature:", err)
+	}
+
+	signerKey, err := signature.GetEmbeddedSignerKey()
+	if err != nil {
+		t.Fatal("Cannot read embedded signer key:", err)
+	}
+	assert.False(t, signerKey.IsPrivate())
+	fingerprint := keyRingTestPublic.GetKeys()[0].GetFingerprint()
+	assert.Exactly(t, fingerprint, signerKey.GetFingerprint())
+
+	// The embedded key does not prevent regular verification
+	assert.Nil(t, keyRingTestPublic.VerifyDetached(message, signature, testTime))
+
+	assert.Nil(t, VerifyDetachedWithEmbeddedSignerKey(message, signature, fingerprint, testTime))
+
+	err = VerifyDetachedWithEmbeddedSignerKey(NewPlainMessageFromString("Tampered"), signature, fingerprint, testTime)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	err = VerifyDetachedWithEmbeddedSignerKey(message, signature, keyTestEC.GetFingerprint(), testTime)
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, GetVerificationStatus(err))
+
+	err = VerifyDetachedWithEmbeddedSignerKey(message, signature, "", testTime)
+	assert.NotNil(t, err)
+
+	plainSignature, err := keyRingTestPrivate.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	_, err = plainSignature.GetEmbeddedSignerKey()
+	assert.NotNil(t, err)
+	err = VerifyDetachedWithEmbeddedSignerKey(message, plainSignature, fingerprint, testTime)
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, GetVerificationStatus(err))
+}
@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// signatureExpirationSubpacket is the Signature Expiration Time signature
+// subpacket type, see RFC 4880, section 5.2.3.10.
+const signatureExpirationSubpacket = 3
+
+// SignDetachedWithLifetime generates and returns a PGPSignature for a given
+// PlainMessage like SignDetached, expiring lifetime seconds after its
+// creation.
+// Verifying the signature after it expired fails with a
+// SignatureVerificationError with status constants.SIGNATURE_EXPIRED, which
+// is only returned for signatures that are otherwise valid.
+func (keyRing *KeyRing) SignDetachedWithLifetime(message *PlainMessage, lifetime int64) (*PGPSignature, error) {
+	if lifetime <= 0 || lifetime > math.MaxUint32 {
+		return nil, errors.New("gopenpgp: invalid signature lifetime")
+	}
+
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([]byte, 4)
+	binary.BigEndian.PutUint32(contents, uint32(lifetime))
+	subpackets := serializeSubpacket(signatureExpirationSubpacket, true, contents)
+
+	signature, err := signDetachedWithSubpackets(signEntity, message.NewReader(), message.IsText(), subpackets)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// GetExpirationTime returns the unix time at which the first signature packet
+// expires, and false if it doesn't expire. The signature isn't verified.
+func (sig *PGPSignature) GetExpirationTime() (int64, bool) {
+	sigPacket, ok := getFirstSignaturePacket(sig.Data)
+	if !ok || sigPacket.SigLifetimeSecs == nil || *sigPacket.SigLifetimeSecs == 0 {
+		return 0, false
+	}
+	return sigPacket.CreationTime.Unix() + int64(*sigPacket.SigLifetimeSecs), true
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+func TestSignDetachedWithLifetime(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed for an hour")
+
+	_, err := keyRing.SignDetachedWithLifetime(message, 0)
+	assert.NotNil(t, err)
+
+	signature, err := keyRing.SignDetachedWithLifetime(message, 3600)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	expirationTime, ok := signature.GetExpirationTime()
+	assert.True(t, ok)
+	assert.Exactly(t, GetUnixTime()+3600, expirationTime)
+
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, expirationTime))
+
+	err = keyRing.VerifyDetached(message, signature, expirationTime+1)
+	assert.Exactly(t, newSignatureExpired(), err)
+
+	// Time checks are disabled with verifyTime = 0
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, 0))
+
+	// Only otherwise valid signatures are reported as expired
+	tampered := NewPlainMessageFromString("tampered")
+	err = keyRing.VerifyDetached(tampered, signature, expirationTime+1)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	// Signatures created after the verification time aren't expired
+	err = keyRing.VerifyDetached(message, signature, GetUnixTime()-internal.CreationTimeOffset-1)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	unsigned, _ := keyRing.SignDetached(message)
+	_, ok = unsigned.GetExpirationTime()
+	assert.False(t, ok)
+}
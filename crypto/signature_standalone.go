@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"hash"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-crypto/openpgp/s2k"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+const (
+	// sigTypeStandalone is the type of standalone signatures, over their own
+	// subpackets only, see RFC 4880, section 5.2.1.
+	sigTypeStandalone packet.SignatureType = 0x02
+	// sigTypeTimestamp is the type of timestamp signatures, only meaningful
+	// for their creation time, see RFC 4880, section 5.2.1.
+	sigTypeTimestamp packet.SignatureType = 0x40
+)
+
+// SignStandalone generates and returns a standalone PGPSignature (type 0x02),
+// signing only its own subpackets, e.g. the given notations.
+func (keyRing *KeyRing) SignStandalone(notations []*Notation) (*PGPSignature, error) {
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	subpackets, err := serializeNotations(notations)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signWithType(signEntity, sigTypeStandalone, nil, subpackets, getTimeGenerator())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// VerifyStandalone verifies a standalone PGPSignature, and returns a
+// SignatureVerificationError if fails.
+func (keyRing *KeyRing) VerifyStandalone(signature *PGPSignature, verifyTime int64) error {
+	_, err := keyRing.verifyWithoutData(signature, sigTypeStandalone, verifyTime)
+	return err
+}
+
+// SignTimestamp generates and returns a timestamp PGPSignature (type 0x40) of
+// a digest computed with hash, e.g. for a trusted timestamping service.
+// The hash algorithm and the digest are written in the
+// constants.TimestampDigestName notation of the signature.
+func (keyRing *KeyRing) SignTimestamp(hash crypto.Hash, digest []byte) (*PGPSignature, error) {
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+	if !isAllowedHash(hash) || len(digest) != hash.Size() {
+		return nil, errors.New("gopenpgp: invalid digest")
+	}
+
+	subpackets, err := serializeNotations([]*Notation{{
+		Name:  constants.TimestampDigestName,
+		Value: timestampDigest(hash, digest),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signWithType(signEntity, sigTypeTimestamp, nil, subpackets, getTimeGenerator())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// VerifyTimestamp verifies a timestamp PGPSignature of a digest computed with
+// hash, and returns the creation time of the signature if it succeeds, or a
+// SignatureVerificationError if fails.
+func (keyRing *KeyRing) VerifyTimestamp(
+	signature *PGPSignature, hash crypto.Hash, digest []byte, verifyTime int64,
+) (int64, error) {
+	if !isAllowedHash(hash) {
+		return 0, newSignatureFailed()
+	}
+	sig, err := keyRing.verifyWithoutData(signature, sigTypeTimestamp, verifyTime)
+	if err != nil {
+		return 0, err
+	}
+
+	expected := timestampDigest(hash, digest)
+	for _, contents := range getHashedSubpackets(sig, notationDataSubpacket) {
+		notation, err := parseNotation(contents, false)
+		if err == nil && notation.Name == constants.TimestampDigestName && bytes.Equal(notation.Value, expected) {
+			return sig.CreationTime.Unix(), nil
+		}
+	}
+	return 0, newSignatureFailed()
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// verifyWithoutData verifies a signature of type sigType, over no data, and
+// returns the verified signature packet.
+func (keyRing *KeyRing) verifyWithoutData(
+	signature *PGPSignature, sigType packet.SignatureType, verifyTime int64,
+) (*packet.Signature, error) {
+	entities := keyRing.getEntities()
+	sigs, err := readSignaturePackets(signature.GetBinary(), nil)
+	if err != nil {
+		return nil, newSignatureFailed()
+	}
+
+	config := verificationConfig(verifyTime, internal.CreationTimeOffset)
+	signer, err := checkSignaturePackets(entities, sigs, config, func(sig *packet.Signature) (hash.Hash, error) {
+		if sig.SigType != sigType {
+			return nil, errors.New("gopenpgp: unexpected signature type")
+		}
+		return sig.Hash.New(), nil
+	})
+	err = checkSigner(entities, signer, err, signature.GetBinary(), verifyTime, internal.CreationTimeOffset, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, ok := getVerifiedSignaturePacket(entities, signature.GetBinary(), nil)
+	if !ok {
+		return nil, newSignatureFailed()
+	}
+	return sig, nil
+}
+
+// timestampDigest returns the value of the constants.TimestampDigestName
+// notation: the OpenPGP ID of hash, then digest.
+func timestampDigest(hash crypto.Hash, digest []byte) []byte {
+	return append([]byte{hashToHashID(hash)}, digest...)
+}
+
+func hashToHashID(h crypto.Hash) uint8 {
+	id, ok := s2k.HashToHashId(h)
+	if !ok {
+		panic("unsupported hash function")
+	}
+	return id
+}
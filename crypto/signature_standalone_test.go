@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestSignTimestamp(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestEC)
+	digest := sha256.Sum256([]byte("timestamped document"))
+
+	_, err := keyRing.SignTimestamp(crypto.SHA256, digest[:16])
+	assert.NotNil(t, err)
+
+	signature, err := keyRing.SignTimestamp(crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal("Cannot generate timestamp signature:", err)
+	}
+
+	timestamp, err := keyRing.VerifyTimestamp(signature, crypto.SHA256, digest[:], GetUnixTime())
+	assert.Nil(t, err)
+	assert.Exactly(t, GetUnixTime(), timestamp)
+
+	notations, err := signature.GetNotations()
+	assert.Nil(t, err)
+	assert.Exactly(t, []*Notation{{
+		Name:  constants.TimestampDigestName,
+		Value: append([]byte{8}, digest[:]...), // SHA-256
+	}}, notations)
+
+	otherDigest := sha256.Sum256([]byte("other document"))
+	_, err = keyRing.VerifyTimestamp(signature, crypto.SHA256, otherDigest[:], GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+	_, err = keyRing.VerifyTimestamp(signature, crypto.SHA512, digest[:], GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	// Timestamp signatures aren't signatures of the digest as data
+	err = keyRing.VerifyDetached(NewPlainMessage(digest[:]), signature, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+	err = keyRing.VerifyStandalone(signature, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	otherKeyRing, _ := NewKeyRing(keyTestRSA)
+	_, err = otherKeyRing.VerifyTimestamp(signature, crypto.SHA256, digest[:], GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestSignStandalone(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	notations := []*Notation{{Name: "statement@example.com", Value: []byte("all good"), IsHumanReadable: true}}
+
+	signature, err := keyRing.SignStandalone(notations)
+	if err != nil {
+		t.Fatal("Cannot generate standalone signature:", err)
+	}
+	assert.Nil(t, keyRing.VerifyStandalone(signature, GetUnixTime()))
+
+	signedNotations, err := signature.GetNotations()
+	assert.Nil(t, err)
+	assert.Exactly(t, notations, signedNotations)
+
+	err = keyRing.VerifyDetached(NewPlainMessage(nil), signature, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
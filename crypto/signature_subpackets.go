@@ -0,0 +1,673 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/pkg/errors"
+)
+
+const (
+	signaturePacketTag  = 2
+	compressedPacketTag = 8
+)
+
+// knownSignatureSubpackets lists the signature subpacket types that are
+// interpreted when verifying signatures (RFC 4880, section 5.2.3.1).
+var knownSignatureSubpackets = map[int]bool{
+	2:  true, // Signature creation time
+	3:  true, // Signature expiration time
+	9:  true, // Key expiration time
+	11: true, // Preferred symmetric algorithms
+	16: true, // Issuer
+	20: true, // Notation data
+	21: true, // Preferred hash algorithms
+	22: true, // Preferred compression algorithms
+	25: true, // Primary user ID
+	26: true, // Policy URI
+	27: true, // Key flags
+	28: true, // Signer's user ID
+	29: true, // Reason for revocation
+	30: true, // Features
+	31: true, // Signature target
+	32: true, // Embedded signature
+	33: true, // Issuer fingerprint
+	34: true, // Preferred AEAD algorithms
+	35: true, // Intended recipient fingerprint
+}
+
+// VerificationReport describes the result of a signature verification.
+type VerificationReport struct {
+	// One of the constants.SIGNATURE_* values
+	Status int
+	// Description of the verification failure, if any
+	Message string
+	// Types of the unknown subpackets flagged as critical, which make
+	// the signatures containing them invalid
+	UnknownCriticalSubpackets []int
+	// Types of the unknown subpackets not flagged as critical,
+	// which are ignored
+	UnknownNonCriticalSubpackets []int
+	// Notations of the signature, set if it was verified
+	Notations []*Notation
+	// Unix creation time of the signature, set if it was verified
+	SignatureCreationTime int64
+}
+
+// HasUnknownCriticalSubpackets returns true if a signature contained an
+// unknown subpacket flagged as critical.
+func (report *VerificationReport) HasUnknownCriticalSubpackets() bool {
+	return len(report.UnknownCriticalSubpackets) > 0
+}
+
+// newSignatureUnknownCriticalSubpacket creates a new SignatureVerificationError,
+// type SignatureFailed, naming the unknown critical subpacket.
+func newSignatureUnknownCriticalSubpacket(subpacketType int) SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: fmt.Sprintf("Unknown critical signature subpacket: type %d", subpacketType),
+	}
+}
+
+// scanSignatureSubpackets lists the unknown subpackets of the signature packets
+// in data, without otherwise parsing the signatures. The critical notations
+// named in knownNotations are known.
+func scanSignatureSubpackets(
+	data []byte, knownNotations map[string]bool,
+) (unknownCritical, unknownNonCritical []int, err error) {
+	err = forEachSignatureSubpacket(data, func(subpacket []byte, isHashed bool) {
+		subpacketType := int(subpacket[0] & 0x7f)
+		isCritical := subpacket[0]&0x80 != 0
+		// Critical notations are rejected by the parser like unknown
+		// subpackets, unless they are known
+		if knownSignatureSubpackets[subpacketType] &&
+			!(isCritical && subpacketType == notationDataSubpacket && !isKnownNotation(subpacket, isHashed, knownNotations)) {
+			return
+		}
+		if isCritical {
+			unknownCritical = append(unknownCritical, subpacketType)
+		} else {
+			unknownNonCritical = append(unknownNonCritical, subpacketType)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return unknownCritical, unknownNonCritical, nil
+}
+
+// findDroppedSignatureError returns the error naming the first unknown
+// critical subpacket of the signatures in the data packet decrypted with sk,
+// or nil if there is none. The parser skips such signatures, so that the
+// messages signed with them would otherwise fail without a reason.
+func findDroppedSignatureError(sk *SessionKey, dataPacket []byte) error {
+	decrypted, _, err := decryptDataPacket(sk, bytes.NewReader(dataPacket))
+	if err != nil {
+		return nil
+	}
+	packets, err := ioutil.ReadAll(decrypted)
+	if err != nil {
+		return nil
+	}
+
+	for len(packets) > 0 {
+		var tag int
+		var rest []byte
+		if tag, rest, err = skipRawPacket(packets); err != nil {
+			return nil
+		}
+		rawPacket := packets[:len(packets)-len(rest)]
+		packets = rest
+
+		switch tag {
+		case compressedPacketTag:
+			var p packet.Packet
+			if p, err = packet.Read(bytes.NewReader(rawPacket)); err != nil {
+				return nil
+			}
+			compressed, ok := p.(*packet.Compressed)
+			if !ok {
+				return nil
+			}
+			if packets, err = ioutil.ReadAll(compressed.Body); err != nil {
+				return nil
+			}
+		case signaturePacketTag:
+			unknownCritical, _, _ := scanSignatureSubpackets(rawPacket, nil)
+			if len(unknownCritical) > 0 {
+				return newSignatureUnknownCriticalSubpacket(unknownCritical[0])
+			}
+		}
+	}
+	return nil
+}
+
+// findDroppedSignatureErrorInMessage is findDroppedSignatureError for the
+// data packet of message, with the session key decrypted with privateKey.
+func findDroppedSignatureErrorInMessage(message *PGPMessage, privateKey *KeyRing) error {
+	split, err := message.SplitMessage()
+	if err != nil {
+		return nil
+	}
+	sk, err := privateKey.DecryptSessionKey(split.GetBinaryKeyPacket())
+	if err != nil {
+		return nil
+	}
+	return findDroppedSignatureError(sk, split.GetBinaryDataPacket())
+}
+
+// forEachSignatureSubpacket calls fn with each subpacket of the signature
+// packets in data, i.e. its type octet followed by its contents, without
+// otherwise parsing the signatures.
+func forEachSignatureSubpacket(data []byte, fn func(subpacket []byte, isHashed bool)) error {
+	for len(data) > 0 {
+		tag, body, rest, err := readRawPacket(data)
+		if err != nil {
+			return err
+		}
+		data = rest
+		if tag != signaturePacketTag || len(body) == 0 {
+			continue
+		}
+
+		var hashedLenSize int
+		switch body[0] {
+		case 4, 5:
+			hashedLenSize = 2
+		case 6:
+			hashedLenSize = 4
+		default:
+			// v3 signatures have no subpackets
+			continue
+		}
+
+		if len(body) < 4 {
+			return errors.New("gopenpgp: truncated signature packet")
+		}
+		areas := body[4:]
+		for i := 0; i < 2; i++ {
+			// Hashed, then unhashed subpacket area
+			if len(areas) < hashedLenSize {
+				return errors.New("gopenpgp: truncated signature packet")
+			}
+			areaLen := 0
+			for _, b := range areas[:hashedLenSize] {
+				areaLen = areaLen<<8 | int(b)
+			}
+			areas = areas[hashedLenSize:]
+			if areaLen > len(areas) {
+				return errors.New("gopenpgp: truncated signature subpackets")
+			}
+			area := areas[:areaLen]
+			areas = areas[areaLen:]
+			for len(area) > 0 {
+				var subpacket []byte
+				subpacket, area, err = readSubpacket(area)
+				if err != nil {
+					return err
+				}
+				fn(subpacket, i == 0)
+			}
+		}
+	}
+	return nil
+}
+
+// readRawPacket reads the tag and body of the first packet in data.
+// Partial body lengths are not supported.
+func readRawPacket(data []byte) (tag int, body, rest []byte, err error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, nil, errors.New("gopenpgp: invalid packet header")
+	}
+
+	var length, offset int
+	if data[0]&0x40 != 0 {
+		// New format
+		tag = int(data[0] & 0x3f)
+		switch l0 := int(data[1]); {
+		case l0 < 192:
+			length, offset = l0, 2
+		case l0 < 224:
+			if len(data) < 3 {
+				return 0, nil, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = (l0-192)<<8+int(data[2])+192, 3
+		case l0 == 255:
+			if len(data) < 6 {
+				return 0, nil, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[2:6])), 6
+		default:
+			return 0, nil, nil, errors.New("gopenpgp: partial body lengths are not supported")
+		}
+	} else {
+		// Old format
+		tag = int(data[0]&0x3f) >> 2
+		switch data[0] & 3 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint16(data[1:3])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+		default:
+			length, offset = len(data)-1, 1
+		}
+	}
+
+	if length < 0 || offset+length > len(data) {
+		return 0, nil, nil, errors.New("gopenpgp: truncated packet")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// readRawPacketTag returns the tag of the packet with the given first header
+// byte, or -1 if it isn't a packet header.
+func readRawPacketTag(header byte) int {
+	switch {
+	case header&0x80 == 0:
+		return -1
+	case header&0x40 != 0:
+		// New format
+		return int(header & 0x3f)
+	default:
+		return int(header&0x3f) >> 2
+	}
+}
+
+// skipRawPacket reads the tag of the first packet in data, and returns the
+// packets following it. Unlike readRawPacket, partial body lengths are
+// supported.
+func skipRawPacket(data []byte) (tag int, rest []byte, err error) {
+	return walkRawPacket(data, nil)
+}
+
+// readRawPacketBody reads the tag and body of the first packet in data like
+// readRawPacket, joining the chunks of a body with partial lengths.
+func readRawPacketBody(data []byte) (tag int, body, rest []byte, err error) {
+	tag, rest, err = walkRawPacket(data, func(chunk []byte) {
+		body = append(body, chunk...)
+	})
+	return tag, body, rest, err
+}
+
+// walkRawPacket reads the tag of the first packet in data, calls chunk, if
+// not nil, with each chunk of its body, and returns the packets following it.
+func walkRawPacket(data []byte, chunk func([]byte)) (tag int, rest []byte, err error) {
+	if len(data) < 2 || data[0]&0x80 == 0 || data[0]&0x40 == 0 || data[1] < 224 || data[1] == 255 {
+		var body []byte
+		tag, body, rest, err = readRawPacket(data)
+		if err == nil && chunk != nil {
+			chunk(body)
+		}
+		return tag, rest, err
+	}
+
+	// New format with partial body lengths: each partial length is followed
+	// by another length, until a definite one
+	tag = int(data[0] & 0x3f)
+	rest = data[1:]
+	for {
+		var length, offset int
+		switch l0 := int(rest[0]); {
+		case l0 < 192:
+			length, offset = l0, 1
+		case l0 < 224:
+			if len(rest) < 2 {
+				return 0, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = (l0-192)<<8+int(rest[1])+192, 2
+		case l0 == 255:
+			if len(rest) < 5 {
+				return 0, nil, errors.New("gopenpgp: truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(rest[1:5])), 5
+		default:
+			length, offset = 1<<(l0&0x1f), 1
+		}
+		if length < 0 || offset+length > len(rest) {
+			return 0, nil, errors.New("gopenpgp: truncated packet")
+		}
+		if chunk != nil {
+			chunk(rest[offset : offset+length])
+		}
+		isPartial := rest[0] >= 224 && rest[0] < 255
+		rest = rest[offset+length:]
+		if !isPartial {
+			return tag, rest, nil
+		}
+		if len(rest) == 0 {
+			return 0, nil, errors.New("gopenpgp: truncated packet")
+		}
+	}
+}
+
+// readSubpacket reads the first signature subpacket in data, and returns its
+// type octet followed by its contents.
+func readSubpacket(data []byte) (subpacket, rest []byte, err error) {
+	var length, offset int
+	switch l0 := int(data[0]); {
+	case l0 < 192:
+		length, offset = l0, 1
+	case l0 < 255:
+		if len(data) < 2 {
+			return nil, nil, errors.New("gopenpgp: truncated signature subpacket")
+		}
+		length, offset = (l0-192)<<8+int(data[1])+192, 2
+	default:
+		if len(data) < 5 {
+			return nil, nil, errors.New("gopenpgp: truncated signature subpacket")
+		}
+		length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+	}
+	if length <= 0 || offset+length > len(data) {
+		return nil, nil, errors.New("gopenpgp: invalid signature subpacket length")
+	}
+	return data[offset : offset+length], data[offset+length:], nil
+}
+
+// serializeSubpacket returns a signature subpacket of the given type.
+func serializeSubpacket(subpacketType int, isCritical bool, contents []byte) []byte {
+	length := len(contents) + 1
+	var subpacket []byte
+	switch {
+	case length < 192:
+		subpacket = []byte{byte(length)}
+	case length < 8384:
+		length -= 192
+		subpacket = []byte{byte(length>>8) + 192, byte(length)}
+	default:
+		subpacket = make([]byte, 5)
+		subpacket[0] = 255
+		binary.BigEndian.PutUint32(subpacket[1:], uint32(length))
+	}
+
+	typeOctet := byte(subpacketType)
+	if isCritical {
+		typeOctet |= 0x80
+	}
+	subpacket = append(subpacket, typeOctet)
+	return append(subpacket, contents...)
+}
+
+// getHashedSubpacket returns the contents of the first subpacket of the given
+// type in the hashed area of the v4 signature sig, or nil.
+func getHashedSubpacket(sig *packet.Signature, subpacketType int) []byte {
+	suffix := sig.HashSuffix
+	if len(suffix) < 6 || suffix[0] != 4 {
+		return nil
+	}
+	areaLen := int(binary.BigEndian.Uint16(suffix[4:6]))
+	if 6+areaLen > len(suffix) {
+		return nil
+	}
+	for area := suffix[6 : 6+areaLen]; len(area) > 0; {
+		subpacket, rest, err := readSubpacket(area)
+		if err != nil {
+			return nil
+		}
+		if int(subpacket[0]&0x7f) == subpacketType {
+			return subpacket[1:]
+		}
+		area = rest
+	}
+	return nil
+}
+
+// getHashedSubpackets returns the contents of all the subpackets of the given
+// type in the hashed area of the v4 signature sig.
+func getHashedSubpackets(sig *packet.Signature, subpacketType int) [][]byte {
+	suffix := sig.HashSuffix
+	if len(suffix) < 6 || suffix[0] != 4 {
+		return nil
+	}
+	areaLen := int(binary.BigEndian.Uint16(suffix[4:6]))
+	if 6+areaLen > len(suffix) {
+		return nil
+	}
+	var contents [][]byte
+	for area := suffix[6 : 6+areaLen]; len(area) > 0; {
+		subpacket, rest, err := readSubpacket(area)
+		if err != nil {
+			return contents
+		}
+		if int(subpacket[0]&0x7f) == subpacketType {
+			contents = append(contents, subpacket[1:])
+		}
+		area = rest
+	}
+	return contents
+}
+
+// isKnownNotation returns whether the notation subpacket is hashed and named
+// in knownNotations.
+func isKnownNotation(subpacket []byte, isHashed bool, knownNotations map[string]bool) bool {
+	if !isHashed {
+		return false
+	}
+	notation, err := parseNotation(subpacket[1:], true)
+	return err == nil && knownNotations[notation.Name]
+}
+
+// signWithSubpackets signs the data hashed in h with priv like sig.Sign,
+// adding the serialized subpackets to the hashed area of the signature, as
+// go-crypto only writes the subpackets it knows. Only v4 signatures are
+// supported with subpackets.
+func signWithSubpackets(
+	sig *packet.Signature, h hash.Hash, priv *packet.PrivateKey, config *packet.Config, subpackets []byte,
+) error {
+	if len(subpackets) == 0 {
+		return sig.Sign(h, priv, config)
+	}
+	suffixWriter := &hashSuffixWriter{Hash: h, subpackets: subpackets}
+	if err := sig.Sign(suffixWriter, priv, config); err != nil {
+		return err
+	}
+	if suffixWriter.err != nil {
+		return suffixWriter.err
+	}
+	if suffixWriter.suffix == nil {
+		return errors.New("gopenpgp: signature hash suffix not written")
+	}
+	// The hashed area is serialized from the hash suffix
+	sig.HashSuffix = suffixWriter.suffix
+	return nil
+}
+
+// hashSuffixWriter adds subpackets to the hash suffix of a signature, which
+// is the only data go-crypto writes to the hash when signing.
+type hashSuffixWriter struct {
+	hash.Hash
+	subpackets []byte
+	suffix     []byte
+	err        error
+}
+
+func (w *hashSuffixWriter) Write(p []byte) (int, error) {
+	w.suffix, w.err = addHashedSubpackets(p, w.subpackets)
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Hash.Write(w.suffix)
+}
+
+// addHashedSubpackets returns the v4 signature hash suffix with subpackets
+// appended to its hashed area.
+func addHashedSubpackets(suffix, subpackets []byte) ([]byte, error) {
+	if len(suffix) < 6 || suffix[0] != 4 {
+		return nil, errors.New("gopenpgp: only v4 signatures are supported")
+	}
+	areaLen := int(binary.BigEndian.Uint16(suffix[4:6]))
+	if 6+areaLen > len(suffix) {
+		return nil, errors.New("gopenpgp: invalid signature hash suffix")
+	}
+	newAreaLen := areaLen + len(subpackets)
+	if newAreaLen > 0xffff {
+		return nil, errors.New("gopenpgp: signature subpackets are too long")
+	}
+
+	newSuffix := make([]byte, 0, 6+newAreaLen+6)
+	newSuffix = append(newSuffix, suffix[:4]...)
+	newSuffix = append(newSuffix, byte(newAreaLen>>8), byte(newAreaLen))
+	newSuffix = append(newSuffix, suffix[6:6+areaLen]...)
+	newSuffix = append(newSuffix, subpackets...)
+	// Trailer: version, 0xff and the length of the hashed fields
+	hashedLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(hashedLen, uint32(6+newAreaLen))
+	newSuffix = append(newSuffix, 4, 0xff)
+	return append(newSuffix, hashedLen...), nil
+}
+
+// signStreamWithSubpackets signs like openpgp.Sign, adding the serialized
+// subpackets to the hashed area of the signature, with a signing key selected
+// with the clock skew tolerance. The one-pass signature and
+// the literal data are written to output, and the signature when the
+// returned writer is closed; output is not closed.
+func signStreamWithSubpackets(
+	output io.Writer, signEntity *openpgp.Entity, hints *openpgp.FileHints, config *packet.Config, subpackets []byte,
+) (io.WriteCloser, error) {
+	signKey, ok := signingKeyAt(signEntity, config.Now(), config.SigningKey())
+	if !ok {
+		return nil, errors.New("gopenpgp: no valid signing keys")
+	}
+	signer := signKey.PrivateKey
+	if signer == nil || signer.Encrypted {
+		return nil, errors.New("gopenpgp: signing key must be unlocked")
+	}
+
+	var sigType packet.SignatureType = packet.SigTypeText
+	if hints.IsBinary {
+		sigType = packet.SigTypeBinary
+	}
+	ops := &packet.OnePassSignature{
+		SigType:    sigType,
+		Hash:       config.Hash(),
+		PubKeyAlgo: signer.PubKeyAlgo,
+		KeyId:      signer.KeyId,
+		IsLast:     true,
+	}
+	if err := ops.Serialize(output); err != nil {
+		return nil, err
+	}
+
+	var modTime uint32
+	if !hints.ModTime.IsZero() {
+		modTime = uint32(hints.ModTime.Unix())
+	}
+	literalData, err := packet.SerializeLiteral(noOpCloser{output}, hints.IsBinary, hints.FileName, modTime)
+	if err != nil {
+		return nil, err
+	}
+
+	h := config.Hash().New()
+	writer := &subpacketsSignatureWriter{
+		output:      output,
+		literalData: literalData,
+		literal:     literalData,
+		h:           h,
+		wrappedHash: h,
+		signature: &packet.Signature{
+			Version:      signer.Version,
+			SigType:      sigType,
+			PubKeyAlgo:   signer.PubKeyAlgo,
+			Hash:         config.Hash(),
+			CreationTime: config.Now(),
+			IssuerKeyId:  &signer.KeyId,
+		},
+		signer:     signer,
+		config:     config,
+		subpackets: subpackets,
+	}
+	if sigType == packet.SigTypeText {
+		writer.literal = &canonicalWriter{w: literalData}
+		writer.wrappedHash = openpgp.NewCanonicalTextHash(h)
+	}
+	return writer, nil
+}
+
+// subpacketsSignatureWriter writes the literal data of a signed message, and
+// the signature with the added subpackets when closed.
+type subpacketsSignatureWriter struct {
+	output      io.Writer
+	literalData io.WriteCloser
+	literal     io.Writer
+	h           hash.Hash
+	wrappedHash hash.Hash
+	signature   *packet.Signature
+	signer      *packet.PrivateKey
+	config      *packet.Config
+	subpackets  []byte
+}
+
+func (w *subpacketsSignatureWriter) Write(data []byte) (int, error) {
+	if _, err := w.wrappedHash.Write(data); err != nil {
+		return 0, err
+	}
+	return w.literal.Write(data)
+}
+
+func (w *subpacketsSignatureWriter) Close() error {
+	if err := signWithSubpackets(w.signature, w.h, w.signer, w.config, w.subpackets); err != nil {
+		return err
+	}
+	if err := w.literalData.Close(); err != nil {
+		return err
+	}
+	return w.signature.Serialize(w.output)
+}
+
+// canonicalWriter writes text to w with canonical line endings like
+// openpgp.NewCanonicalTextHash, so that the literal data matches the hashed
+// data: a line feed is written as CRLF unless it directly follows a carriage
+// return.
+type canonicalWriter struct {
+	w      io.Writer
+	afterR bool
+}
+
+func (c *canonicalWriter) Write(data []byte) (int, error) {
+	start := 0
+	for i, b := range data {
+		switch {
+		case c.afterR:
+			c.afterR = false
+		case b == '\r':
+			c.afterR = true
+		case b == '\n':
+			if _, err := c.w.Write(data[start:i]); err != nil {
+				return 0, err
+			}
+			if _, err := c.w.Write([]byte("\r\n")); err != nil {
+				return 0, err
+			}
+			start = i + 1
+		}
+	}
+	if _, err := c.w.Write(data[start:]); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// noOpCloser is a writer whose Close method does nothing, so that closing
+// the literal data packet doesn't close the packets containing it.
+type noOpCloser struct {
+	io.Writer
+}
+
+func (noOpCloser) Close() error {
+	return nil
+}
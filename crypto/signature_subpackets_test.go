@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+const testUnknownSubpacketType = 99
+
+// signWithExtraSubpacket creates a binary signature of data with keyTestRSA,
+// containing an additional hashed subpacket of the given type.
+func signWithExtraSubpacket(t *testing.T, data []byte, subpacketType byte, critical bool) []byte {
+	entity := keyTestRSA.entity
+	if critical {
+		subpacketType |= 0x80
+	}
+
+	hashed := []byte{5, 2, 0, 0, 0, 0, 2, subpacketType, 0x01}
+	binary.BigEndian.PutUint32(hashed[2:6], uint32(getNow().Unix()))
+
+	// v4, binary, RSA, SHA256
+	prefix := []byte{4, 0x00, byte(packet.PubKeyAlgoRSA), 8, 0, byte(len(hashed))}
+	prefix = append(prefix, hashed...)
+
+	h := sha256.New()
+	_, _ = h.Write(data)
+	_, _ = h.Write(prefix)
+	_, _ = h.Write([]byte{4, 0xff})
+	_ = binary.Write(h, binary.BigEndian, uint32(len(prefix)))
+	digest := h.Sum(nil)
+
+	rsaKey, ok := entity.PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA key")
+	}
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest)
+	if err != nil {
+		t.Fatal("Cannot sign:", err)
+	}
+
+	unhashed := []byte{9, 16, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(unhashed[2:], entity.PrimaryKey.KeyId)
+
+	sig = bytes.TrimLeft(sig, "\x00")
+	bitLength := (len(sig)-1)*8 + bits.Len8(sig[0])
+
+	body := append([]byte{}, prefix...)
+	body = append(body, 0, byte(len(unhashed)))
+	body = append(body, unhashed...)
+	body = append(body, digest[:2]...)
+	body = append(body, byte(bitLength>>8), byte(bitLength))
+	body = append(body, sig...)
+
+	out := []byte{0xC2, 0xFF, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[2:], uint32(len(body)))
+	return append(out, body...)
+}
+
+func TestVerifyUnknownNonCriticalSubpacket(t *testing.T) {
+	message := NewPlainMessageFromString("signed with an unknown subpacket")
+	signature := NewPGPSignature(signWithExtraSubpacket(t, message.Data, testUnknownSubpacketType, false))
+
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	report, err := keyRing.VerifyDetachedWithReport(message, signature, GetUnixTime())
+	assert.Nil(t, err)
+	assert.Exactly(t, constants.SIGNATURE_OK, report.Status)
+	assert.Exactly(t, []int{testUnknownSubpacketType}, report.UnknownNonCriticalSubpackets)
+	assert.False(t, report.HasUnknownCriticalSubpackets())
+
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+}
+
+func TestVerifyUnknownCriticalSubpacket(t *testing.T) {
+	message := NewPlainMessageFromString("signed with an unknown subpacket")
+	signature := NewPGPSignature(signWithExtraSubpacket(t, message.Data, testUnknownSubpacketType, true))
+
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	report, err := keyRing.VerifyDetachedWithReport(message, signature, GetUnixTime())
+	assert.EqualError(t, err, "Signature Verification Error: Unknown critical signature subpacket: type 99")
+	assert.Exactly(t, constants.SIGNATURE_FAILED, report.Status)
+	assert.Exactly(t, []int{testUnknownSubpacketType}, report.UnknownCriticalSubpackets)
+	assert.Empty(t, report.UnknownNonCriticalSubpackets)
+
+	err = keyRing.VerifyDetached(message, signature, GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestDecryptUnknownSubpackets(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	data := []byte("encrypted and signed with an unknown subpacket")
+
+	for _, critical := range []bool{false, true} {
+		var plaintext bytes.Buffer
+		ops := &packet.OnePassSignature{
+			SigType:    packet.SigTypeBinary,
+			Hash:       crypto.SHA256,
+			PubKeyAlgo: packet.PubKeyAlgoRSA,
+			KeyId:      keyTestRSA.GetKeyID(),
+			IsLast:     true,
+		}
+		if err := ops.Serialize(&plaintext); err != nil {
+			t.Fatal("Cannot serialize one-pass signature:", err)
+		}
+		literal, err := packet.SerializeLiteral(nopCloser{&plaintext}, true, "", uint32(time.Now().Unix()))
+		if err != nil {
+			t.Fatal("Cannot serialize literal data:", err)
+		}
+		_, _ = literal.Write(data)
+		_ = literal.Close()
+		plaintext.Write(signWithExtraSubpacket(t, data, testUnknownSubpacketType, critical))
+
+		sessionKey, err := GenerateSessionKey()
+		if err != nil {
+			t.Fatal("Cannot generate session key:", err)
+		}
+		keyPacket, err := keyRing.EncryptSessionKey(sessionKey)
+		if err != nil {
+			t.Fatal("Cannot encrypt session key:", err)
+		}
+		var dataPacket bytes.Buffer
+		encrypted, err := packet.SerializeSymmetricallyEncrypted(&dataPacket, packet.CipherAES256, sessionKey.Key, nil)
+		if err != nil {
+			t.Fatal("Cannot encrypt data:", err)
+		}
+		_, _ = encrypted.Write(plaintext.Bytes())
+		_ = encrypted.Close()
+
+		ciphertext := NewPGPSplitMessage(keyPacket, dataPacket.Bytes()).GetPGPMessage()
+		decrypted, err := keyRing.Decrypt(ciphertext, keyRing, GetUnixTime())
+		if critical {
+			assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+			assert.EqualError(t, err, "Signature Verification Error: Unknown critical signature subpacket: type 99")
+		} else {
+			assert.Nil(t, err)
+		}
+		assert.Exactly(t, data, decrypted.GetBinary())
+
+		decrypted, err = sessionKey.DecryptAndVerify(dataPacket.Bytes(), keyRing, GetUnixTime())
+		if critical {
+			assert.EqualError(t, err, "Signature Verification Error: Unknown critical signature subpacket: type 99")
+		} else {
+			assert.Nil(t, err)
+		}
+		assert.Exactly(t, data, decrypted.GetBinary())
+	}
+}
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestSignDetachedWithNotations(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed with notations\n")
+	notations := []*Notation{
+		{Name: "context@example.org", Value: []byte("mail"), IsHumanReadable: true},
+		{Name: "binary@example.org", Value: []byte{0x00, 0xff}},
+	}
+
+	signature, err := keyRing.SignDetachedWithNotations(message, notations)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	read, err := signature.GetNotations()
+	assert.Nil(t, err)
+	assert.Exactly(t, notations, read)
+
+	report, err := keyRing.VerifyDetachedWithReport(message, signature, GetUnixTime())
+	assert.Nil(t, err)
+	assert.Exactly(t, notations, report.Notations)
+	assert.Empty(t, report.UnknownNonCriticalSubpackets)
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+
+	criticalNotations := []*Notation{{Name: "critical@example.org", Value: []byte("value"), IsCritical: true}}
+	signature, err = keyRing.SignDetachedWithNotations(message, criticalNotations)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	read, err = signature.GetNotations()
+	assert.Nil(t, err)
+	assert.Exactly(t, criticalNotations, read)
+
+	report, err = keyRing.VerifyDetachedWithReport(message, signature, GetUnixTime())
+	assert.EqualError(t, err, "Signature Verification Error: Unknown critical signature subpacket: type 20")
+	assert.Nil(t, report.Notations)
+
+	_, err = keyRing.SignDetachedWithNotations(message, []*Notation{{Value: []byte("value")}})
+	assert.Error(t, err)
+}
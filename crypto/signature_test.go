@@ -78,6 +78,54 @@ func TestVerifyBinDetachedSig(t *testing.T) {
 	}
 }
 
+func TestDetachedSignatureContentType(t *testing.T) {
+	getSigType := func(signature *PGPSignature) packet.SignatureType {
+		p, err := packet.Read(bytes.NewReader(signature.GetBinary()))
+		if err != nil {
+			t.Fatal("Cannot parse signature:", err)
+		}
+		return p.(*packet.Signature).SigType
+	}
+
+	textMessage := NewPlainMessageFromString(signedPlainText)
+	textSig, err := keyRingTestPrivate.SignDetached(textMessage)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.Exactly(t, packet.SignatureType(packet.SigTypeText), getSigType(textSig))
+
+	// Text signatures verify regardless of the line endings, like with GnuPG
+	lfMessage := NewPlainMessage([]byte(signedPlainText))
+	assert.Nil(t, keyRingTestPublic.VerifyDetached(lfMessage, textSig, testTime))
+
+	streamSig, err := keyRingTestPrivate.SignDetachedTextStream(bytes.NewReader([]byte(signedPlainText)))
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.Exactly(t, packet.SignatureType(packet.SigTypeText), getSigType(streamSig))
+	assert.Nil(t, keyRingTestPublic.VerifyDetached(textMessage, streamSig, testTime))
+
+	binSig, err := keyRingTestPrivate.SignDetached(lfMessage)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.Exactly(t, packet.SignatureType(packet.SigTypeBinary), getSigType(binSig))
+
+	verificationError := keyRingTestPublic.VerifyDetached(textMessage, binSig, testTime)
+	assert.EqualError(
+		t,
+		verificationError,
+		"Signature Verification Error: Invalid signature: binary signature over a text message",
+	)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(verificationError))
+
+	// The mismatch isn't blamed for the failures of modified messages
+	modifiedMessage := NewPlainMessageFromString(signedPlainText + "modified")
+	verificationError = keyRingTestPublic.VerifyDetached(modifiedMessage, binSig, testTime)
+	assert.EqualError(t, verificationError, "Signature Verification Error: Invalid signature")
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(verificationError))
+}
+
 func Test_KeyRing_GetVerifiedSignatureTimestampSuccess(t *testing.T) {
 	message := NewPlainMessageFromString("Hello world!")
 	var time int64 = 1600000000
@@ -98,6 +146,45 @@ func Test_KeyRing_GetVerifiedSignatureTimestampSuccess(t *testing.T) {
 	}
 }
 
+func Test_KeyRing_SignDetachedAtTime(t *testing.T) {
+	message := NewPlainMessageFromString("Hello world!")
+	var signTime int64 = 1600000000
+	serverTime := GetUnixTime()
+
+	signature, err := keyRingTestPrivate.SignDetachedAtTime(message, signTime)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	actualTime, err := keyRingTestPublic.GetVerifiedSignatureTimestamp(message, signature, 0)
+	if err != nil {
+		t.Fatal("Cannot verify signature:", err)
+	}
+	assert.Exactly(t, signTime, actualTime)
+
+	// The global time is unchanged
+	assert.Exactly(t, serverTime, GetUnixTime())
+
+	// RSA signatures are deterministic
+	again, err := keyRingTestPrivate.SignDetachedAtTime(message, signTime)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	assert.Exactly(t, signature.GetBinary(), again.GetBinary())
+
+	streamSignature, err := keyRingTestPrivate.SignDetachedStreamAtTime(bytes.NewReader(message.GetBinary()), signTime)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	actualTime, err = keyRingTestPublic.GetVerifiedSignatureTimestamp(NewPlainMessage(message.GetBinary()), streamSignature, 0)
+	if err != nil {
+		t.Fatal("Cannot verify signature:", err)
+	}
+	assert.Exactly(t, signTime, actualTime)
+
+	_, err = keyRingTestPrivate.SignDetachedAtTime(message, 0)
+	assert.NotNil(t, err)
+}
+
 func Test_KeyRing_GetVerifiedSignatureWithTwoKeysTimestampSuccess(t *testing.T) {
 	publicKey1Armored, err := ioutil.ReadFile("testdata/signature/publicKey1")
 	if err != nil {
@@ -194,3 +281,22 @@ func Test_KeyRing_GetVerifiedSignatureTimestampError(t *testing.T) {
 		t.Errorf("Expected an error while parsing the creation time of a wrong signature, got nil")
 	}
 }
+
+func TestSignatureIssuerKeys(t *testing.T) {
+	entities := keyRingTestPublic.getEntities()
+	primaryKey := entities[0].PrimaryKey
+
+	// Signatures with only an issuer fingerprint are resolved
+	sig := &packet.Signature{IssuerFingerprint: primaryKey.Fingerprint}
+	keys := signatureIssuerKeys(entities, sig)
+	assert.Len(t, keys, 1)
+	assert.Exactly(t, primaryKey, keys[0].PublicKey)
+
+	// The issuer fingerprint takes precedence over the issuer key ID
+	otherFingerprint := append([]byte{}, primaryKey.Fingerprint...)
+	otherFingerprint[0] ^= 0xff
+	sig = &packet.Signature{IssuerKeyId: &primaryKey.KeyId, IssuerFingerprint: otherFingerprint}
+	assert.Empty(t, signatureIssuerKeys(entities, sig))
+
+	assert.Empty(t, signatureIssuerKeys(entities, &packet.Signature{}))
+}
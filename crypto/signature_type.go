@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// SignDetachedWithType generates a detached signature of the given type for
+// message, regardless of its content type: constants.SignatureTypeText signs
+// the text with canonicalized line endings, constants.SignatureTypeBinary
+// signs the raw bytes. Use it when the verifier expects a given type.
+func (keyRing *KeyRing) SignDetachedWithType(message *PlainMessage, sigType int) (*PGPSignature, error) {
+	if err := checkDocumentSignatureType(sigType); err != nil {
+		return nil, err
+	}
+
+	defer keyRing.use()()
+	signEntity, err := keyRing.getSigningEntity()
+	if err != nil {
+		return nil, err
+	}
+
+	isText := sigType == constants.SignatureTypeText
+	signature, err := signDetached(signEntity, message.NewReader(), isText, getTimeGenerator())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPGPSignature(signature), nil
+}
+
+// VerifyDetachedWithType verifies a PlainMessage with the signature packets
+// of the given type of a detached PGPSignature, regardless of the content type
+// of the message, and returns a SignatureVerificationError if fails, or if no
+// signature packet has the given type.
+func (keyRing *KeyRing) VerifyDetachedWithType(
+	message *PlainMessage, signature *PGPSignature, sigType int, verifyTime int64,
+) error {
+	if err := checkDocumentSignatureType(sigType); err != nil {
+		return err
+	}
+
+	var typed bytes.Buffer
+	packets := packet.NewReader(bytes.NewReader(signature.GetBinary()))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			break
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok || int(sig.SigType) != sigType {
+			continue
+		}
+		if err = sig.Serialize(&typed); err != nil {
+			return errors.Wrap(err, "gopenpgp: error in serializing signature")
+		}
+	}
+	if typed.Len() == 0 {
+		return newSignatureTypeMismatch(sigType)
+	}
+
+	return verifySignature(
+		keyRing.getEntities(),
+		message.NewReader(),
+		typed.Bytes(),
+		verifyTime,
+	)
+}
+
+// GetSignatureType returns the type of the first signature packet,
+// e.g. constants.SignatureTypeText, and false if there is no signature
+// packet. The signature isn't verified.
+func (sig *PGPSignature) GetSignatureType() (int, bool) {
+	sigPacket, ok := getFirstSignaturePacket(sig.Data)
+	if !ok {
+		return 0, false
+	}
+	return int(sigPacket.SigType), true
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func checkDocumentSignatureType(sigType int) error {
+	if sigType != constants.SignatureTypeBinary && sigType != constants.SignatureTypeText {
+		return fmt.Errorf("gopenpgp: unsupported signature type: %#02x", sigType)
+	}
+	return nil
+}
+
+// newSignatureTypeMismatch creates a new SignatureVerificationError, type
+// SignatureFailed, for a signature without any packet of the expected type.
+func newSignatureTypeMismatch(sigType int) SignatureVerificationError {
+	message := "Invalid signature: expected a binary signature"
+	if sigType == constants.SignatureTypeText {
+		message = "Invalid signature: expected a text signature"
+	}
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_FAILED,
+		Message: message,
+	}
+}
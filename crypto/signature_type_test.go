@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestSignDetachedWithType(t *testing.T) {
+	crlfMessage := NewPlainMessage([]byte("line one\r\nline two\r\n"))
+	lfMessage := NewPlainMessage([]byte("line one\nline two\n"))
+
+	textSignature, err := keyRingTestPrivate.SignDetachedWithType(crlfMessage, constants.SignatureTypeText)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	sigType, ok := textSignature.GetSignatureType()
+	assert.True(t, ok)
+	assert.Exactly(t, constants.SignatureTypeText, sigType)
+
+	// Text signatures canonicalize the line endings
+	if err = keyRingTestPublic.VerifyDetachedWithType(lfMessage, textSignature, constants.SignatureTypeText, testTime); err != nil {
+		t.Fatal("Expected no error when verifying text signature, got:", err)
+	}
+	err = keyRingTestPublic.VerifyDetachedWithType(crlfMessage, textSignature, constants.SignatureTypeBinary, testTime)
+	castedErr := &SignatureVerificationError{}
+	if !errors.As(err, castedErr) {
+		t.Fatal("Expected a signature verification error, got:", err)
+	}
+	assert.Exactly(t, constants.SIGNATURE_FAILED, castedErr.Status)
+	assert.Exactly(t, "Invalid signature: expected a binary signature", castedErr.Message)
+
+	binarySignature, err := keyRingTestPrivate.SignDetachedWithType(lfMessage, constants.SignatureTypeBinary)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	sigType, ok = binarySignature.GetSignatureType()
+	assert.True(t, ok)
+	assert.Exactly(t, constants.SignatureTypeBinary, sigType)
+
+	if err = keyRingTestPublic.VerifyDetachedWithType(lfMessage, binarySignature, constants.SignatureTypeBinary, testTime); err != nil {
+		t.Fatal("Expected no error when verifying binary signature, got:", err)
+	}
+	err = keyRingTestPublic.VerifyDetachedWithType(crlfMessage, binarySignature, constants.SignatureTypeBinary, testTime)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	_, err = keyRingTestPrivate.SignDetachedWithType(lfMessage, 0x13)
+	assert.Error(t, err)
+}
@@ -1,25 +1,75 @@
 package crypto
 
 import (
+	"math"
+	"sync/atomic"
 	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
 )
 
-// UpdateTime updates cached time.
-func UpdateTime(newTime int64) {
-	pgp.lock.Lock()
-	defer pgp.lock.Unlock()
+// Clock provides the current time used to encrypt, sign and check keys.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a function used as a Clock.
+type ClockFunc func() time.Time
+
+// Now returns the current time given by f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
 
-	if newTime > pgp.latestServerTime {
-		pgp.latestServerTime = newTime
+// clockValue wraps the clock stored in pgp.clock, possibly nil, as an
+// atomic.Value only stores values of the same concrete type.
+type clockValue struct {
+	clock Clock
+}
+
+// SetClock sets the clock giving the current time, e.g. a clock synchronized
+// with a server. A nil clock restores the default clock, which returns the
+// latest time set with UpdateTime, or the local time if none was set.
+func SetClock(clock Clock) {
+	pgp.clock.Store(clockValue{clock})
+}
+
+// SetClockSkewTolerance sets the tolerance, in seconds, of the key and
+// signature validity checks: keys and signatures expired less than tolerance
+// seconds ago, or created less than tolerance seconds in the future, are
+// considered valid, so that devices with wrong clocks don't spuriously reject
+// them. The tolerance applies when verifying signatures, when selecting the
+// keys to encrypt to and sign with, and to Key.IsExpired and the Key.Can*
+// checks. The default tolerance is 0.
+func SetClockSkewTolerance(tolerance int64) {
+	if tolerance < 0 {
+		tolerance = 0
+	}
+	atomic.StoreInt64(&pgp.clockSkewTolerance, tolerance)
+}
+
+// GetClockSkewTolerance returns the tolerance, in seconds, of the key and
+// signature expiration checks.
+func GetClockSkewTolerance() int64 {
+	return atomic.LoadInt64(&pgp.clockSkewTolerance)
+}
+
+// UpdateTime updates cached time, used by the default clock.
+func UpdateTime(newTime int64) {
+	for {
+		latestServerTime := atomic.LoadInt64(&pgp.latestServerTime)
+		if newTime <= latestServerTime ||
+			atomic.CompareAndSwapInt64(&pgp.latestServerTime, latestServerTime, newTime) {
+			return
+		}
 	}
 }
 
 // SetKeyGenerationOffset updates the offset when generating keys.
 func SetKeyGenerationOffset(offset int64) {
-	pgp.lock.Lock()
-	defer pgp.lock.Unlock()
-
-	pgp.generationOffset = offset
+	atomic.StoreInt64(&pgp.generationOffset, offset)
 }
 
 // GetUnixTime gets latest cached time.
@@ -34,16 +84,18 @@ func GetTime() time.Time {
 
 // ----- INTERNAL FUNCTIONS -----
 
-// getNow returns the latest server time.
+// getNow returns the time of the clock, or the latest server time.
 func getNow() time.Time {
-	pgp.lock.RLock()
-	defer pgp.lock.RUnlock()
+	if clock, ok := pgp.clock.Load().(clockValue); ok && clock.clock != nil {
+		return clock.clock.Now()
+	}
 
-	if pgp.latestServerTime == 0 {
+	latestServerTime := atomic.LoadInt64(&pgp.latestServerTime)
+	if latestServerTime == 0 {
 		return time.Now()
 	}
 
-	return time.Unix(pgp.latestServerTime, 0)
+	return time.Unix(latestServerTime, 0)
 }
 
 // getTimeGenerator Returns a time generator function.
@@ -51,19 +103,89 @@ func getTimeGenerator() func() time.Time {
 	return getNow
 }
 
-// getNowKeyGenerationOffset returns the current time with the key generation offset.
-func getNowKeyGenerationOffset() time.Time {
-	pgp.lock.RLock()
-	defer pgp.lock.RUnlock()
-
-	if pgp.latestServerTime == 0 {
-		return time.Unix(time.Now().Unix()+pgp.generationOffset, 0)
+// getFixedTimeGenerator returns a time generator function always returning
+// the given unix time, which must be a valid OpenPGP timestamp.
+func getFixedTimeGenerator(unixTime int64) (func() time.Time, error) {
+	if unixTime <= 0 || unixTime > math.MaxUint32 {
+		return nil, errors.New("gopenpgp: invalid signature time")
 	}
+	fixedTime := time.Unix(unixTime, 0)
+	return func() time.Time {
+		return fixedTime
+	}, nil
+}
 
-	return time.Unix(pgp.latestServerTime+pgp.generationOffset, 0)
+// getNowKeyGenerationOffset returns the current time with the key generation offset.
+func getNowKeyGenerationOffset() time.Time {
+	generationOffset := atomic.LoadInt64(&pgp.generationOffset)
+	return time.Unix(getNow().Unix()+generationOffset, 0)
 }
 
 // getKeyGenerationTimeGenerator Returns a time generator function with the key generation offset.
 func getKeyGenerationTimeGenerator() func() time.Time {
 	return getNowKeyGenerationOffset
 }
+
+// isKeyExpired returns whether pk, with self-signature sig, is expired at now,
+// like pk.KeyExpired, with the clock skew tolerance.
+func isKeyExpired(pk *packet.PublicKey, sig *packet.Signature, now time.Time) bool {
+	tolerance := time.Duration(GetClockSkewTolerance()) * time.Second
+	if pk.CreationTime.After(now.Add(tolerance)) {
+		return true
+	}
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return false
+	}
+	expiry := pk.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+	return now.Add(-tolerance).After(expiry)
+}
+
+// isSignatureExpired returns whether sig is expired at now, like
+// sig.SigExpired, with the clock skew tolerance.
+func isSignatureExpired(sig *packet.Signature, now time.Time) bool {
+	tolerance := time.Duration(GetClockSkewTolerance()) * time.Second
+	if sig.CreationTime.After(now.Add(tolerance)) {
+		return true
+	}
+	if sig.SigLifetimeSecs == nil || *sig.SigLifetimeSecs == 0 {
+		return false
+	}
+	expiry := sig.CreationTime.Add(time.Duration(*sig.SigLifetimeSecs) * time.Second)
+	return now.Add(-tolerance).After(expiry)
+}
+
+// encryptionKeyAt returns the encryption key of entity at now like
+// entity.EncryptionKey, with the clock skew tolerance.
+func encryptionKeyAt(entity *openpgp.Entity, now time.Time) (openpgp.Key, bool) {
+	return selectKeyWithTolerance(entity.EncryptionKey, now)
+}
+
+// signingKeyAt returns the signing key of entity with the given key ID, or
+// any signing key if id is 0, at now like entity.SigningKeyById, with the
+// clock skew tolerance.
+func signingKeyAt(entity *openpgp.Entity, now time.Time, id uint64) (openpgp.Key, bool) {
+	return selectKeyWithTolerance(func(t time.Time) (openpgp.Key, bool) {
+		return entity.SigningKeyById(t, id)
+	}, now)
+}
+
+// selectKeyWithTolerance returns the key selected at now, or else at the
+// times within the clock skew tolerance of now, so that keys created less
+// than tolerance seconds in the future, or expired less than tolerance
+// seconds ago, are selected. Keys revoked at now are never selected.
+func selectKeyWithTolerance(selectKey func(time.Time) (openpgp.Key, bool), now time.Time) (openpgp.Key, bool) {
+	if key, ok := selectKey(now); ok {
+		return key, true
+	}
+	tolerance := time.Duration(GetClockSkewTolerance()) * time.Second
+	if tolerance == 0 {
+		return openpgp.Key{}, false
+	}
+	for _, t := range []time.Time{now.Add(tolerance), now.Add(-tolerance)} {
+		key, ok := selectKey(t)
+		if ok && !key.Revoked(now) && !key.Entity.Revoked(now) {
+			return key, true
+		}
+	}
+	return openpgp.Key{}, false
+}
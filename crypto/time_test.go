@@ -1,10 +1,14 @@
 package crypto
 
 import (
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/internal"
 )
 
 func TestTime(t *testing.T) {
@@ -15,3 +19,116 @@ func TestTime(t *testing.T) {
 	assert.Exactly(t, int64(1571072494), now) // Use latest server time
 	UpdateTime(testTime)
 }
+
+func TestClock(t *testing.T) {
+	serverTime := GetUnixTime()
+	SetClock(ClockFunc(func() time.Time {
+		return time.Unix(1600000000, 0)
+	}))
+	assert.Exactly(t, int64(1600000000), GetUnixTime())
+
+	SetClock(nil)
+	assert.Exactly(t, serverTime, GetUnixTime())
+}
+
+func TestClockSkewTolerance(t *testing.T) {
+	defer SetClockSkewTolerance(0)
+	now := GetUnixTime()
+
+	key, err := GenerateKeyWithExpiration(keyTestName, keyTestDomain, "x25519", 0, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	keyRing, _ := NewKeyRing(key)
+	message := NewPlainMessageFromString("signed for an hour")
+	signature, err := keyRing.SignDetachedWithLifetime(message, 3600)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+
+	SetClock(ClockFunc(func() time.Time {
+		return time.Unix(now+3600+60, 0)
+	}))
+	defer SetClock(nil)
+
+	assert.True(t, key.IsExpired())
+	assert.False(t, key.CanEncrypt())
+	err = keyRing.VerifyDetached(message, signature, GetUnixTime())
+	assert.Exactly(t, newSignatureExpired(), err)
+	_, err = keyRing.Encrypt(message, nil)
+	assert.Error(t, err)
+
+	SetClockSkewTolerance(120)
+	assert.Exactly(t, int64(120), GetClockSkewTolerance())
+	assert.False(t, key.IsExpired())
+	assert.True(t, key.CanEncrypt())
+	assert.True(t, key.CanSign())
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, GetUnixTime()))
+	if _, err = keyRing.Encrypt(message, keyRing); err != nil {
+		t.Fatal("Expected no error when encrypting with the tolerance, got:", err)
+	}
+	if _, err = keyRing.EncryptSessionKey(&SessionKey{Key: make([]byte, 32), Algo: constants.AES256}); err != nil {
+		t.Fatal("Expected no error when encrypting a session key with the tolerance, got:", err)
+	}
+}
+
+func TestClockSkewToleranceFutureCreation(t *testing.T) {
+	defer SetClockSkewTolerance(0)
+	now := GetUnixTime()
+
+	// The key and signature are created after the verification time and
+	// its margin
+	created := now + internal.CreationTimeOffset + 60
+	SetClock(ClockFunc(func() time.Time {
+		return time.Unix(created, 0)
+	}))
+	defer SetClock(nil)
+	key, err := GenerateKey(keyTestName, keyTestDomain, "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	keyRing, _ := NewKeyRing(key)
+	message := NewPlainMessageFromString("signed in the future")
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+
+	SetClock(ClockFunc(func() time.Time {
+		return time.Unix(created-60, 0)
+	}))
+	assert.False(t, key.CanSign())
+	err = keyRing.VerifyDetached(message, signature, now)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	SetClockSkewTolerance(120)
+	assert.True(t, key.CanSign())
+	assert.True(t, key.CanEncrypt())
+	assert.Nil(t, keyRing.VerifyDetached(message, signature, now))
+}
+
+func TestTimeConcurrentAccess(t *testing.T) {
+	defer SetClock(nil)
+	now := GetUnixTime()
+	fixedClock := ClockFunc(func() time.Time {
+		return time.Unix(now, 0)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				UpdateTime(now - int64(i*100+j))
+				if j%10 == 0 {
+					SetClock(fixedClock)
+					SetClock(nil)
+				}
+				assert.Exactly(t, now, GetUnixTime())
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Exactly(t, now, GetUnixTime())
+}
@@ -0,0 +1,312 @@
+package crypto
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+// TrustDB holds the trust in the owners of keys, by fingerprint, that is how
+// much they are trusted to certify other keys.
+type TrustDB struct {
+	ownerTrust map[string]int
+}
+
+// NewTrustDB creates an empty trust database.
+func NewTrustDB() *TrustDB {
+	return &TrustDB{ownerTrust: make(map[string]int)}
+}
+
+// ImportOwnerTrust reads owner trust in the format of gpg --export-ownertrust,
+// one "fingerprint:level:" line per key, and adds it to the database.
+func (db *TrustDB) ImportOwnerTrust(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 {
+			return fmt.Errorf("gopenpgp: invalid owner trust on line %d", lineNumber)
+		}
+		level, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return errors.Wrapf(err, "gopenpgp: invalid owner trust on line %d", lineNumber)
+		}
+		if err := db.SetOwnerTrust(fields[0], level); err != nil {
+			return errors.Wrapf(err, "gopenpgp: invalid owner trust on line %d", lineNumber)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "gopenpgp: error in reading owner trust")
+}
+
+// ExportOwnerTrust returns the owner trust in the format of
+// gpg --export-ownertrust.
+func (db *TrustDB) ExportOwnerTrust() string {
+	fingerprints := make([]string, 0, len(db.ownerTrust))
+	for fingerprint := range db.ownerTrust {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	var builder strings.Builder
+	builder.WriteString("# List of assigned trustvalues\n")
+	builder.WriteString("# (Use \"gpg --import-ownertrust\" to restore them)\n")
+	for _, fingerprint := range fingerprints {
+		fmt.Fprintf(&builder, "%s:%d:\n", strings.ToUpper(fingerprint), db.ownerTrust[fingerprint])
+	}
+	return builder.String()
+}
+
+// SetOwnerTrust sets the trust in the owner of the key with the given hex
+// fingerprint, one of the constants.Trust* levels.
+func (db *TrustDB) SetOwnerTrust(fingerprint string, level int) error {
+	if level < constants.TrustUnknown || level > constants.TrustUltimate {
+		return errors.New("gopenpgp: invalid trust level")
+	}
+	fingerprint = strings.ToLower(fingerprint)
+	if !isHexFingerprint(fingerprint) {
+		return errors.New("gopenpgp: invalid fingerprint")
+	}
+	db.ownerTrust[fingerprint] = level
+	return nil
+}
+
+// GetOwnerTrust returns the trust in the owner of the key with the given hex
+// fingerprint, or constants.TrustUnknown.
+func (db *TrustDB) GetOwnerTrust(fingerprint string) int {
+	return db.ownerTrust[strings.ToLower(fingerprint)]
+}
+
+// TrustModel computes the validity of the user IDs of keys from the owner
+// trust and the certifications between the keys of a key ring.
+type TrustModel struct {
+	// If Direct is true, the validity of a key is the trust in its owner and
+	// certifications are ignored, like GnuPG's direct trust model. Otherwise,
+	// validity is computed from the web of trust, like GnuPG's pgp model.
+	Direct bool
+	// Number of certifications by fully trusted owners needed for a user ID
+	// to be fully valid
+	CompletesNeeded int
+	// Number of certifications by marginally trusted owners needed for a user
+	// ID to be fully valid
+	MarginalsNeeded int
+	// Maximum length of the certification chains from an ultimately trusted key
+	MaxCertDepth int
+}
+
+// NewTrustModel returns a web of trust model with the defaults of GnuPG.
+func NewTrustModel() *TrustModel {
+	return &TrustModel{
+		CompletesNeeded: 1,
+		MarginalsNeeded: 3,
+		MaxCertDepth:    5,
+	}
+}
+
+// Validity holds the validity of the user IDs of the keys of a key ring.
+type Validity struct {
+	// User IDs by fingerprint
+	userIDs map[string][]*userIDValidity
+}
+
+type userIDValidity struct {
+	userID   *packet.UserId
+	validity int
+}
+
+// ComputeValidity computes the validity of the user IDs of the keys of
+// keyRing, from the owner trust in db.
+func (model *TrustModel) ComputeValidity(keyRing *KeyRing, db *TrustDB) *Validity {
+	now := getNow()
+	validity := &Validity{userIDs: make(map[string][]*userIDValidity)}
+
+	// Keys whose owners can be trusted to certify other keys, by fingerprint
+	introducers := make(map[string]*openpgp.Entity)
+	var pending []*openpgp.Entity
+	for _, entity := range keyRing.getEntities() {
+		fingerprint := fingerprintOf(entity)
+		ownerTrust := db.GetOwnerTrust(fingerprint)
+
+		keyValidity := constants.TrustUnknown
+		switch {
+		case entity.Revoked(now):
+			keyValidity = constants.TrustNever
+		case (&Key{entity: entity}).IsExpired():
+			keyValidity = constants.TrustExpired
+		case model.Direct:
+			keyValidity = directValidity(ownerTrust)
+		case ownerTrust == constants.TrustUltimate:
+			keyValidity = constants.TrustUltimate
+			introducers[fingerprint] = entity
+		default:
+			pending = append(pending, entity)
+		}
+
+		for _, identity := range entity.Identities {
+			userIDValidity := &userIDValidity{userID: identity.UserId, validity: keyValidity}
+			if identity.Revoked(now) {
+				userIDValidity.validity = constants.TrustNever
+			}
+			validity.userIDs[fingerprint] = append(validity.userIDs[fingerprint], userIDValidity)
+		}
+	}
+
+	// Each pass validates the keys one certification further from the
+	// ultimately trusted keys
+	for depth := 0; depth < model.MaxCertDepth && len(pending) > 0; depth++ {
+		newIntroducers := make(map[string]*openpgp.Entity)
+		var stillPending []*openpgp.Entity
+		for _, entity := range pending {
+			fingerprint := fingerprintOf(entity)
+			isValid := false
+			for _, userIDValidity := range validity.userIDs[fingerprint] {
+				if userIDValidity.validity == constants.TrustNever {
+					continue
+				}
+				identity := entity.Identities[userIDValidity.userID.Id]
+				userIDValidity.validity = model.userIDValidity(entity, identity, introducers, db, now)
+				isValid = isValid || userIDValidity.validity == constants.TrustFull
+			}
+			if isValid {
+				newIntroducers[fingerprint] = entity
+			} else {
+				stillPending = append(stillPending, entity)
+			}
+		}
+		if len(newIntroducers) == 0 {
+			break
+		}
+		for fingerprint, entity := range newIntroducers {
+			introducers[fingerprint] = entity
+		}
+		pending = stillPending
+	}
+
+	return validity
+}
+
+// GetUserIDValidity returns the validity of the user ID of the key with the
+// given hex fingerprint, one of the constants.Trust* levels.
+func (validity *Validity) GetUserIDValidity(fingerprint, userID string) int {
+	for _, userIDValidity := range validity.userIDs[strings.ToLower(fingerprint)] {
+		if userIDValidity.userID.Id == userID {
+			return userIDValidity.validity
+		}
+	}
+	return constants.TrustUnknown
+}
+
+// GetKeyValidity returns the highest validity of the user IDs of the key with
+// the given hex fingerprint.
+func (validity *Validity) GetKeyValidity(fingerprint string) int {
+	keyValidity := constants.TrustUnknown
+	for _, userIDValidity := range validity.userIDs[strings.ToLower(fingerprint)] {
+		if userIDValidity.validity > keyValidity {
+			keyValidity = userIDValidity.validity
+		}
+	}
+	return keyValidity
+}
+
+// IsTrustedForEmail returns true if the key with the given hex fingerprint
+// has a fully or ultimately valid user ID with the given email address.
+func (validity *Validity) IsTrustedForEmail(fingerprint, email string) bool {
+	for _, userIDValidity := range validity.userIDs[strings.ToLower(fingerprint)] {
+		if strings.EqualFold(userIDValidity.userID.Email, email) &&
+			userIDValidity.validity >= constants.TrustFull {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Internal functions
+
+// userIDValidity counts the valid certifications of the identity of entity by
+// the introducers, weighted by the trust in their owners.
+func (model *TrustModel) userIDValidity(
+	entity *openpgp.Entity, identity *openpgp.Identity,
+	introducers map[string]*openpgp.Entity, db *TrustDB, now time.Time,
+) int {
+	completes, marginals := 0, 0
+	for fingerprint, introducer := range introducers {
+		if !isCertifiedBy(entity, identity, introducer, now) {
+			continue
+		}
+		switch db.GetOwnerTrust(fingerprint) {
+		case constants.TrustFull, constants.TrustUltimate:
+			completes++
+		case constants.TrustMarginal:
+			marginals++
+		}
+	}
+
+	switch {
+	case completes >= model.CompletesNeeded || marginals >= model.MarginalsNeeded:
+		return constants.TrustFull
+	case completes > 0 || marginals > 0:
+		return constants.TrustMarginal
+	}
+	return constants.TrustUnknown
+}
+
+// isCertifiedBy returns true if the identity of entity has a valid
+// certification by certifier, which isn't revoked by a later revocation.
+func isCertifiedBy(entity *openpgp.Entity, identity *openpgp.Identity, certifier *openpgp.Entity, now time.Time) bool {
+	if entity == certifier {
+		return false
+	}
+
+	var certification, revocation *packet.Signature
+	for _, sig := range identity.Signatures {
+		if !sig.CheckKeyIdOrFingerprint(certifier.PrimaryKey) || isSignatureExpired(sig, now) {
+			continue
+		}
+		if certifier.PrimaryKey.VerifyUserIdSignature(identity.Name, entity.PrimaryKey, sig) != nil {
+			continue
+		}
+		if sig.SigType == packet.SigTypeCertificationRevocation {
+			revocation = newestSignature(revocation, sig)
+		} else {
+			certification = newestSignature(certification, sig)
+		}
+	}
+	return certification != nil &&
+		(revocation == nil || certification.CreationTime.After(revocation.CreationTime))
+}
+
+// directValidity returns the validity of a key in the direct trust model.
+func directValidity(ownerTrust int) int {
+	switch ownerTrust {
+	case constants.TrustMarginal, constants.TrustFull, constants.TrustUltimate, constants.TrustNever:
+		return ownerTrust
+	}
+	return constants.TrustUnknown
+}
+
+// fingerprintOf returns the lowercase hex fingerprint of the primary key.
+func fingerprintOf(entity *openpgp.Entity) string {
+	return hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+}
+
+// isHexFingerprint returns true for a v4 or v5 lowercase hex fingerprint.
+func isHexFingerprint(fingerprint string) bool {
+	if len(fingerprint) != 40 && len(fingerprint) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(fingerprint)
+	return err == nil
+}
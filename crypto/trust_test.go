@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestOwnerTrustImportExport(t *testing.T) {
+	ownerTrust := "# List of assigned trustvalues\n" +
+		"ABCDEF0123456789ABCDEF0123456789ABCDEF01:6:\n" +
+		"\n" +
+		"0123456789abcdef0123456789abcdef01234567:4:\n"
+
+	db := NewTrustDB()
+	if err := db.ImportOwnerTrust(strings.NewReader(ownerTrust)); err != nil {
+		t.Fatal("Cannot import owner trust:", err)
+	}
+	assert.Exactly(t, constants.TrustUltimate, db.GetOwnerTrust("abcdef0123456789abcdef0123456789abcdef01"))
+	assert.Exactly(t, constants.TrustMarginal, db.GetOwnerTrust("0123456789ABCDEF0123456789ABCDEF01234567"))
+	assert.Exactly(t, constants.TrustUnknown, db.GetOwnerTrust(keyTestEC.GetFingerprint()))
+
+	exported := db.ExportOwnerTrust()
+	assert.Contains(t, exported, "0123456789ABCDEF0123456789ABCDEF01234567:4:\nABCDEF0123456789ABCDEF0123456789ABCDEF01:6:\n")
+
+	imported := NewTrustDB()
+	if err := imported.ImportOwnerTrust(strings.NewReader(exported)); err != nil {
+		t.Fatal("Cannot import owner trust:", err)
+	}
+	assert.Equal(t, db, imported)
+
+	assert.NotNil(t, db.ImportOwnerTrust(strings.NewReader("ABCDEF:6:\n")))
+	assert.NotNil(t, db.ImportOwnerTrust(strings.NewReader("ABCDEF0123456789ABCDEF0123456789ABCDEF01:9:\n")))
+	assert.NotNil(t, db.ImportOwnerTrust(strings.NewReader("ABCDEF0123456789ABCDEF0123456789ABCDEF01\n")))
+}
+
+func TestTrustModel(t *testing.T) {
+	generate := func(name string) (*Key, string) {
+		key, err := GenerateKey(name, name+"@example.com", "x25519", 0)
+		if err != nil {
+			t.Fatal("Cannot generate key:", err)
+		}
+		return key, name + " <" + name + "@example.com>"
+	}
+	certify := func(certifier, key *Key, userID string) *Key {
+		certified, err := certifier.CertifyUserID(key, userID, constants.CertificationGeneric, true)
+		if err != nil {
+			t.Fatal("Cannot certify key:", err)
+		}
+		return certified
+	}
+
+	alice, _ := generate("alice")
+	bob, bobUserID := generate("bob")
+	carol, carolUserID := generate("carol")
+	dave, daveUserID := generate("dave")
+
+	// alice -> bob -> carol
+	bob = certify(alice, bob, bobUserID)
+	carol = certify(bob, carol, carolUserID)
+
+	keyRing, err := NewKeyRing(alice)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	for _, key := range []*Key{bob, carol, dave} {
+		if err = keyRing.AddKey(key); err != nil {
+			t.Fatal("Cannot add key:", err)
+		}
+	}
+
+	db := NewTrustDB()
+	assert.Nil(t, db.SetOwnerTrust(alice.GetFingerprint(), constants.TrustUltimate))
+	assert.Nil(t, db.SetOwnerTrust(bob.GetFingerprint(), constants.TrustMarginal))
+	assert.Nil(t, db.SetOwnerTrust(dave.GetFingerprint(), constants.TrustFull))
+
+	validity := NewTrustModel().ComputeValidity(keyRing, db)
+	assert.Exactly(t, constants.TrustUltimate, validity.GetKeyValidity(alice.GetFingerprint()))
+	assert.Exactly(t, constants.TrustFull, validity.GetUserIDValidity(bob.GetFingerprint(), bobUserID))
+	assert.Exactly(t, constants.TrustMarginal, validity.GetKeyValidity(carol.GetFingerprint()))
+	assert.Exactly(t, constants.TrustUnknown, validity.GetKeyValidity(dave.GetFingerprint()))
+	assert.True(t, validity.IsTrustedForEmail(bob.GetFingerprint(), "BOB@example.com"))
+	assert.False(t, validity.IsTrustedForEmail(bob.GetFingerprint(), "carol@example.com"))
+	assert.False(t, validity.IsTrustedForEmail(carol.GetFingerprint(), "carol@example.com"))
+
+	assert.Nil(t, db.SetOwnerTrust(bob.GetFingerprint(), constants.TrustFull))
+	validity = NewTrustModel().ComputeValidity(keyRing, db)
+	assert.True(t, validity.IsTrustedForEmail(carol.GetFingerprint(), "carol@example.com"))
+
+	model := NewTrustModel()
+	model.MaxCertDepth = 1
+	validity = model.ComputeValidity(keyRing, db)
+	assert.Exactly(t, constants.TrustFull, validity.GetKeyValidity(bob.GetFingerprint()))
+	assert.Exactly(t, constants.TrustUnknown, validity.GetKeyValidity(carol.GetFingerprint()))
+
+	validity = (&TrustModel{Direct: true}).ComputeValidity(keyRing, db)
+	assert.Exactly(t, constants.TrustFull, validity.GetUserIDValidity(dave.GetFingerprint(), daveUserID))
+	assert.Exactly(t, constants.TrustUnknown, validity.GetKeyValidity(carol.GetFingerprint()))
+}
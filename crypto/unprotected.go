@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des" //nolint:gosec
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/cast5" //nolint:staticcheck
+)
+
+// errUnprotectedMessage is returned when decrypting a message without
+// integrity protection, unless they are allowed.
+var errUnprotectedMessage = errors.New(
+	"gopenpgp: message is not integrity protected (symmetrically encrypted packet without MDC), " +
+		"see SetAllowUnprotectedMessages",
+)
+
+// maxKeyPacketLength is the maximum length of the key packets read before
+// the data packet of a message.
+const maxKeyPacketLength = 1 << 16
+
+// SetAllowUnprotectedMessages sets whether messages encrypted in a
+// Symmetrically Encrypted packet, without integrity protection (MDC), are
+// decrypted. They are rejected by default, as their ciphertext can be
+// modified without the decryption failing. Only allow them to read messages
+// encrypted by legacy implementations: the decryption functions with keys,
+// passwords and session keys, streamed or not, then decrypt them, and
+// IsIntegrityProtected of the result returns false.
+func SetAllowUnprotectedMessages(allow bool) {
+	pgp.lock.Lock()
+	defer pgp.lock.Unlock()
+
+	pgp.allowUnprotectedMessages = allow
+}
+
+// --- Internal functions
+
+func allowUnprotectedMessages() bool {
+	pgp.lock.RLock()
+	defer pgp.lock.RUnlock()
+
+	return pgp.allowUnprotectedMessages
+}
+
+// readKeyPackets reads the key and marker packets at the start of a message,
+// and returns them with the tag of the following data packet, and a reader
+// of the data packet and the rest of the message. go-crypto can't parse
+// packets without integrity protection, which are recognized by their tag,
+// like decryptDataPacket does.
+func readKeyPackets(messageReader io.Reader) (keyPackets []byte, dataPacketTag int, dataPacketReader io.Reader, err error) {
+	var buffer bytes.Buffer
+	header := make([]byte, 1)
+	for {
+		if _, err = io.ReadFull(messageReader, header); err != nil {
+			return nil, 0, nil, errors.Wrap(err, "gopenpgp: unable to read message")
+		}
+		tag := readRawPacketTag(header[0])
+		if tag != packetTagEncryptedKey && tag != packetTagSymmetricKeyEncrypted && tag != packetTagMarker {
+			dataPacketReader = io.MultiReader(bytes.NewReader(header), messageReader)
+			return buffer.Bytes(), tag, dataPacketReader, nil
+		}
+		buffer.Write(header)
+		var length int64
+		if length, err = readRawPacketLength(messageReader, header[0], &buffer); err != nil {
+			return nil, 0, nil, err
+		}
+		if _, err = io.CopyN(&buffer, messageReader, length); err != nil {
+			return nil, 0, nil, errors.Wrap(err, "gopenpgp: unable to read key packet")
+		}
+	}
+}
+
+// readRawPacketLength reads the definite length of a packet with the given
+// first header byte from r, copying the length bytes to header.
+func readRawPacketLength(r io.Reader, first byte, header io.Writer) (int64, error) {
+	readBytes := func(n int) ([]byte, error) {
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read key packet")
+		}
+		_, _ = header.Write(data)
+		return data, nil
+	}
+
+	var data []byte
+	var err error
+	var length int64
+	if first&0x40 != 0 {
+		// New format
+		if data, err = readBytes(1); err != nil {
+			return 0, err
+		}
+		switch l0 := int64(data[0]); {
+		case l0 < 192:
+			length = l0
+		case l0 < 224:
+			if data, err = readBytes(1); err != nil {
+				return 0, err
+			}
+			length = (l0-192)<<8 + int64(data[0]) + 192
+		case l0 == 255:
+			if data, err = readBytes(4); err != nil {
+				return 0, err
+			}
+			length = int64(binary.BigEndian.Uint32(data))
+		default:
+			return 0, errors.New("gopenpgp: partial body lengths are not supported for key packets")
+		}
+	} else {
+		// Old format
+		switch first & 3 {
+		case 0:
+			data, err = readBytes(1)
+		case 1:
+			data, err = readBytes(2)
+		case 2:
+			data, err = readBytes(4)
+		default:
+			return 0, errors.New("gopenpgp: indeterminate lengths are not supported for key packets")
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range data {
+			length = length<<8 | int64(b)
+		}
+	}
+
+	if length > maxKeyPacketLength {
+		return 0, errors.New("gopenpgp: key packet too long")
+	}
+	return length, nil
+}
+
+// decryptUnprotectedPacket reads the Symmetrically Encrypted packet in
+// dataPacketReader, and returns a reader for its decrypted contents, in the
+// resynchronized OCFB mode of RFC 4880, section 13.9.
+func decryptUnprotectedPacket(sk *SessionKey, dataPacketReader io.Reader) (io.ReadCloser, error) {
+	if !allowUnprotectedMessages() {
+		return nil, errUnprotectedMessage
+	}
+	data, err := ioutil.ReadAll(dataPacketReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+	}
+	_, contents, _, err := readRawPacketBody(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+	}
+
+	block, err := newBlockCipher(sk)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := block.BlockSize()
+	if len(contents) < blockSize+2 {
+		return nil, errors.New("gopenpgp: truncated symmetric packet")
+	}
+	// The last two bytes of the random prefix are repeated, to detect
+	// a wrong session key
+	prefix := clone(contents[:blockSize+2])
+	stream := packet.NewOCFBDecrypter(block, prefix, packet.OCFBResync)
+	if prefix[blockSize-2] != prefix[blockSize] || prefix[blockSize-1] != prefix[blockSize+1] {
+		return nil, errors.New("gopenpgp: unable to decrypt symmetric packet: wrong session key")
+	}
+	plaintext := cipher.StreamReader{S: stream, R: bytes.NewReader(contents[blockSize+2:])}
+	return ioutil.NopCloser(plaintext), nil
+}
+
+// newBlockCipher returns the block cipher of the session key.
+func newBlockCipher(sk *SessionKey) (cipher.Block, error) {
+	cipherFunc, err := sk.GetCipherFunc()
+	if err != nil {
+		return nil, err
+	}
+	var block cipher.Block
+	switch cipherFunc {
+	case packet.Cipher3DES:
+		block, err = des.NewTripleDESCipher(sk.Key)
+	case packet.CipherCAST5:
+		block, err = cast5.NewCipher(sk.Key)
+	case packet.CipherAES128, packet.CipherAES192, packet.CipherAES256:
+		block, err = aes.NewCipher(sk.Key)
+	default:
+		return nil, errors.New("gopenpgp: unsupported cipher for symmetric packet")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
+	}
+	return block, nil
+}
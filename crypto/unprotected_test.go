@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptUnprotected encrypts message with sk in a Symmetrically Encrypted
+// packet without MDC, like legacy implementations.
+func encryptUnprotected(t *testing.T, sk *SessionKey, message *PlainMessage) []byte {
+	var literal bytes.Buffer
+	literalWriter, err := packet.SerializeLiteral(noOpCloser{&literal}, message.IsBinary(), message.Filename, message.Time)
+	if err != nil {
+		t.Fatal("Expected no error when serializing literal data, got:", err)
+	}
+	if _, err = literalWriter.Write(message.GetBinary()); err != nil {
+		t.Fatal("Expected no error when writing literal data, got:", err)
+	}
+	if err = literalWriter.Close(); err != nil {
+		t.Fatal("Expected no error when closing literal data, got:", err)
+	}
+
+	block, err := newBlockCipher(sk)
+	if err != nil {
+		t.Fatal("Expected no error when creating cipher, got:", err)
+	}
+	randData, err := RandomToken(block.BlockSize())
+	if err != nil {
+		t.Fatal("Expected no error when generating prefix, got:", err)
+	}
+	stream, prefix := packet.NewOCFBEncrypter(block, randData, packet.OCFBResync)
+	contents := make([]byte, literal.Len())
+	stream.XORKeyStream(contents, literal.Bytes())
+	contents = append(prefix, contents...)
+
+	header := make([]byte, 6)
+	header[0], header[1] = 0xc0|packetTagSymmetricallyEncrypted, 0xff
+	binary.BigEndian.PutUint32(header[2:], uint32(len(contents)))
+	return append(header, contents...)
+}
+
+func TestUnprotectedMessageRejected(t *testing.T) {
+	sk, err := GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Expected no error when generating session key, got:", err)
+	}
+	message := NewPlainMessageFromString("no integrity protection")
+	dataPacket := encryptUnprotected(t, sk, message)
+	keyPacket, err := keyRingTestPublic.EncryptSessionKey(sk)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	ciphertext := NewPGPSplitMessage(keyPacket, dataPacket).GetPGPMessage()
+
+	_, err = keyRingTestPrivate.Decrypt(ciphertext, nil, 0)
+	assert.True(t, errors.Is(err, errUnprotectedMessage))
+
+	_, err = sk.Decrypt(dataPacket)
+	assert.True(t, errors.Is(err, errUnprotectedMessage))
+
+	_, err = keyRingTestPrivate.DecryptStream(ciphertext.NewReader(), nil, 0)
+	assert.True(t, errors.Is(err, errUnprotectedMessage))
+
+	_, err = keyRingTestPrivate.DecryptAttachment(NewPGPSplitMessage(keyPacket, dataPacket))
+	assert.True(t, errors.Is(err, errUnprotectedMessage))
+
+	passwordKeyPacket, err := EncryptSessionKeyWithPassword(sk, testSymmetricKey)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key with password, got:", err)
+	}
+	passwordCiphertext := NewPGPSplitMessage(passwordKeyPacket, dataPacket).GetPGPMessage()
+	_, err = DecryptMessageWithPassword(passwordCiphertext, testSymmetricKey)
+	assert.True(t, errors.Is(err, errUnprotectedMessage))
+
+	protected, err := keyRingTestPublic.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	decrypted, err := keyRingTestPrivate.Decrypt(protected, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.True(t, decrypted.IsIntegrityProtected())
+}
+
+func TestUnprotectedMessageAllowed(t *testing.T) {
+	SetAllowUnprotectedMessages(true)
+	defer SetAllowUnprotectedMessages(false)
+
+	sk, err := GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Expected no error when generating session key, got:", err)
+	}
+	message := NewPlainMessageFromString("no integrity protection")
+	dataPacket := encryptUnprotected(t, sk, message)
+	keyPacket, err := keyRingTestPublic.EncryptSessionKey(sk)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key, got:", err)
+	}
+	ciphertext := NewPGPSplitMessage(keyPacket, dataPacket).GetPGPMessage()
+
+	decrypted, err := keyRingTestPrivate.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	assert.False(t, decrypted.IsIntegrityProtected())
+
+	decrypted, err = sk.Decrypt(dataPacket)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting with session key, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	assert.False(t, decrypted.IsIntegrityProtected())
+
+	reader, err := sk.DecryptStream(bytes.NewReader(dataPacket), nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting stream, got:", err)
+	}
+	assert.False(t, reader.IsIntegrityProtected())
+
+	reader, err = keyRingTestPrivate.DecryptStream(ciphertext.NewReader(), nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting stream with keyring, got:", err)
+	}
+	plaintext, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Exactly(t, message.GetString(), string(plaintext))
+	assert.False(t, reader.IsIntegrityProtected())
+
+	decrypted, err = keyRingTestPrivate.DecryptAttachment(NewPGPSplitMessage(keyPacket, dataPacket))
+	if err != nil {
+		t.Fatal("Expected no error when decrypting attachment, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	assert.False(t, decrypted.IsIntegrityProtected())
+
+	passwordKeyPacket, err := EncryptSessionKeyWithPassword(sk, testSymmetricKey)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting session key with password, got:", err)
+	}
+	passwordCiphertext := NewPGPSplitMessage(passwordKeyPacket, dataPacket).GetPGPMessage()
+	decrypted, err = DecryptMessageWithPassword(passwordCiphertext, testSymmetricKey)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting with password, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+	assert.False(t, decrypted.IsIntegrityProtected())
+
+	wrongKey, err := GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Expected no error when generating session key, got:", err)
+	}
+	_, err = wrongKey.Decrypt(dataPacket)
+	assert.Error(t, err)
+}
@@ -0,0 +1,353 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"hash"
+	"image/jpeg"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+const (
+	// userAttributePacketTag is the tag of user attribute packets, holding
+	// e.g. photo IDs (RFC 4880, section 5.12).
+	userAttributePacketTag = 17
+	// imageHeaderLength is the length of the header of the image subpackets
+	// of user attributes, version 1 (RFC 4880, section 5.12.1).
+	imageHeaderLength = 16
+	// imageEncodingJPEG is the only image encoding of image subpackets.
+	imageEncodingJPEG = 1
+)
+
+// userAttribute is a user attribute of a key, with its certifications. The
+// user attributes are dropped by the parser of go-crypto: they are held by the
+// Key and KeyRing wrappers instead of the entities. A userAttribute is shared
+// between keys, and never modified once read.
+type userAttribute struct {
+	attribute *packet.UserAttribute
+	// All the certifications and revocations, including third-party ones
+	signatures    []*packet.Signature
+	selfSignature *packet.Signature
+	revoked       bool
+}
+
+// CountPhotoIDs returns the number of photo IDs of the key, the images of its
+// owner in its non-revoked user attributes.
+func (key *Key) CountPhotoIDs() int {
+	return len(key.getPhotoIDs())
+}
+
+// GetPhotoID returns the JPEG image of the n-th photo ID of the key.
+func (key *Key) GetPhotoID(n int) ([]byte, error) {
+	photoIDs := key.getPhotoIDs()
+	if n < 0 || n >= len(photoIDs) {
+		return nil, errors.New("gopenpgp: out of bound photo ID index")
+	}
+	return photoIDs[n], nil
+}
+
+// AddPhotoID returns a copy of the key with a new user attribute holding the
+// JPEG image jpegImage as a photo ID, certified by a self-signature with the
+// same preferences as the primary user ID. Images should be small, e.g. 240x288
+// pixels as generated by GnuPG, since they are part of the public key.
+// The key must be unlocked.
+func (key *Key) AddPhotoID(jpegImage []byte) (*Key, error) {
+	if err := key.checkCanCertify(); err != nil {
+		return nil, err
+	}
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(jpegImage)); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid JPEG image")
+	}
+
+	updatedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	entity := updatedKey.entity
+	primaryIdentity := entity.PrimaryIdentity()
+	if primaryIdentity == nil || primaryIdentity.SelfSignature == nil {
+		return nil, errors.New("gopenpgp: no self-signature found")
+	}
+
+	header := make([]byte, imageHeaderLength)
+	binary.LittleEndian.PutUint16(header, imageHeaderLength)
+	header[2] = 1 // Header version
+	header[3] = imageEncodingJPEG
+	attribute := packet.NewUserAttribute(&packet.OpaqueSubpacket{
+		SubType:  packet.UserAttrImageSubpacket,
+		Contents: append(header, jpegImage...),
+	})
+
+	selfSig := *primaryIdentity.SelfSignature
+	selfSig.SigType = packet.SigTypePositiveCert
+	selfSig.CreationTime = getNow()
+	selfSig.IsPrimaryId = nil
+	selfSig.SigLifetimeSecs = nil
+	selfSig.RevocationReason = nil
+	selfSig.RevocationReasonText = ""
+	selfSig.IssuerKeyId = &entity.PrimaryKey.KeyId
+	selfSig.IssuerFingerprint = entity.PrimaryKey.Fingerprint
+
+	h, err := userAttributeSignatureHash(attribute, entity.PrimaryKey, selfSig.Hash)
+	if err != nil {
+		return nil, err
+	}
+	config := &packet.Config{Time: getTimeGenerator()}
+	if err = selfSig.Sign(h, entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in signing photo ID")
+	}
+
+	// The attributes can be shared with other keys
+	updatedKey.userAttributes = append(append([]*userAttribute{}, updatedKey.userAttributes...), &userAttribute{
+		attribute:     attribute,
+		signatures:    []*packet.Signature{&selfSig},
+		selfSignature: &selfSig,
+	})
+
+	return updatedKey, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// getPhotoIDs returns the JPEG images of the non-revoked user attributes.
+func (key *Key) getPhotoIDs() (photoIDs [][]byte) {
+	for _, attribute := range key.userAttributes {
+		if attribute.revoked {
+			continue
+		}
+		for _, subpacket := range attribute.attribute.Contents {
+			if image := getJPEGImage(subpacket); image != nil {
+				photoIDs = append(photoIDs, image)
+			}
+		}
+	}
+	return
+}
+
+// getJPEGImage returns the JPEG image of an image subpacket with a version 1
+// header, or nil.
+func getJPEGImage(subpacket *packet.OpaqueSubpacket) []byte {
+	contents := subpacket.Contents
+	if subpacket.SubType != packet.UserAttrImageSubpacket || len(contents) < 4 {
+		return nil
+	}
+	headerLength := int(binary.LittleEndian.Uint16(contents))
+	if headerLength > len(contents) || contents[2] != 1 || contents[3] != imageEncodingJPEG {
+		return nil
+	}
+	return contents[headerLength:]
+}
+
+// readUserAttributes returns the keys of the entities parsed from the
+// unarmored keys data, with the user attributes of data that have a valid
+// self-signature.
+func readUserAttributes(entities openpgp.EntityList, data []byte) []*Key {
+	var primaryKey []byte
+	var current *userAttribute
+	byFingerprint := make(map[string][]*userAttribute)
+	for rest := data; len(rest) > 0; {
+		tag, _, next, err := readRawPacket(rest)
+		if err != nil {
+			break
+		}
+		raw := rest[:len(rest)-len(next)]
+		rest = next
+
+		switch tag {
+		case packetTagPublicKey, packetTagPrivateKey:
+			// Only parsed for the rare keys with user attributes
+			primaryKey = raw
+			current = nil
+		case userAttributePacketTag:
+			current = nil
+			pk := parsePublicKey(primaryKey)
+			attribute, ok := readPacket(raw).(*packet.UserAttribute)
+			if pk == nil || !ok {
+				continue
+			}
+			current = &userAttribute{attribute: attribute}
+			fingerprint := string(pk.Fingerprint)
+			byFingerprint[fingerprint] = append(byFingerprint[fingerprint], current)
+		case signaturePacketTag:
+			if sig, ok := readPacket(raw).(*packet.Signature); ok && current != nil {
+				current.signatures = append(current.signatures, sig)
+			}
+		default:
+			current = nil
+		}
+	}
+
+	keys := make([]*Key, len(entities))
+	for i, entity := range entities {
+		keys[i] = &Key{entity: entity}
+		for _, attribute := range byFingerprint[string(entity.PrimaryKey.Fingerprint)] {
+			if attribute.verify(entity.PrimaryKey) {
+				keys[i].userAttributes = append(keys[i].userAttributes, attribute)
+			}
+		}
+	}
+	return keys
+}
+
+// mergeUserAttributes returns the user attributes of dst with the user
+// attributes and certifications of src missing from dst, of the key with
+// the primary key primaryKey. dst and src are not modified.
+func mergeUserAttributes(primaryKey *packet.PublicKey, dst, src []*userAttribute) []*userAttribute {
+	if len(src) == 0 {
+		return dst
+	}
+	merged := append([]*userAttribute{}, dst...)
+	for _, srcAttribute := range src {
+		i := findUserAttribute(merged, srcAttribute.attribute)
+		if i < 0 {
+			merged = append(merged, srcAttribute)
+			continue
+		}
+		signatures := append([]*packet.Signature{}, merged[i].signatures...)
+		mergedAttribute := &userAttribute{
+			attribute:  merged[i].attribute,
+			signatures: mergeSignatures(signatures, srcAttribute.signatures),
+		}
+		mergedAttribute.verify(primaryKey)
+		merged[i] = mergedAttribute
+	}
+	return merged
+}
+
+// findUserAttribute returns the index of the user attribute with the same
+// subpackets as attribute, or -1.
+func findUserAttribute(attributes []*userAttribute, attribute *packet.UserAttribute) int {
+	for i, candidate := range attributes {
+		if len(candidate.attribute.Contents) != len(attribute.Contents) {
+			continue
+		}
+		same := true
+		for j, subpacket := range candidate.attribute.Contents {
+			other := attribute.Contents[j]
+			if subpacket.SubType != other.SubType || !bytes.Equal(subpacket.Contents, other.Contents) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return i
+		}
+	}
+	return -1
+}
+
+// readPacket parses a raw packet, or returns nil if it can't be parsed.
+func readPacket(raw []byte) packet.Packet {
+	p, err := packet.Read(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// verify checks the self-signatures of the user attribute, and returns
+// whether one of its certifications is valid.
+func (attribute *userAttribute) verify(primaryKey *packet.PublicKey) bool {
+	for _, sig := range attribute.signatures {
+		if sig.IssuerKeyId != nil && *sig.IssuerKeyId != primaryKey.KeyId {
+			continue
+		}
+		h, err := userAttributeSignatureHash(attribute.attribute, primaryKey, sig.Hash)
+		if err != nil || primaryKey.VerifySignature(h, sig) != nil {
+			continue
+		}
+		switch sig.SigType {
+		case packet.SigTypeCertificationRevocation:
+			attribute.revoked = true
+		case packet.SigTypeGenericCert, packet.SigTypePersonaCert, packet.SigTypeCasualCert, packet.SigTypePositiveCert:
+			if attribute.selfSignature == nil || sig.CreationTime.After(attribute.selfSignature.CreationTime) {
+				attribute.selfSignature = sig
+			}
+		}
+	}
+	return attribute.selfSignature != nil
+}
+
+// userAttributeSignatureHash returns the hash of a user attribute
+// certification by pk (RFC 4880, section 5.2.4).
+func userAttributeSignatureHash(attribute *packet.UserAttribute, pk *packet.PublicKey, hashFunc crypto.Hash) (hash.Hash, error) {
+	if !hashFunc.Available() {
+		return nil, errors.New("gopenpgp: unsupported hash function")
+	}
+
+	var serializedKey, serializedAttribute bytes.Buffer
+	if err := pk.Serialize(&serializedKey); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing public key")
+	}
+	if err := attribute.Serialize(&serializedAttribute); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing user attribute")
+	}
+	_, keyBody, _, err := readRawPacket(serializedKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	_, attributeBody, _, err := readRawPacket(serializedAttribute.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	h := hashFunc.New()
+	pk.SerializeSignaturePrefix(h)
+	_, _ = h.Write(keyBody)
+	attributeHeader := make([]byte, 5)
+	attributeHeader[0] = 0xd1
+	binary.BigEndian.PutUint32(attributeHeader[1:], uint32(len(attributeBody)))
+	_, _ = h.Write(attributeHeader)
+	_, _ = h.Write(attributeBody)
+	return h, nil
+}
+
+// insertUserAttributes returns the serialized entity with the packets of the
+// user attributes of its key inserted before its subkeys. Only the exportable
+// certifications are kept if exportable is set. serialized is cleared if a
+// new slice is returned, as it may hold secret key material.
+func insertUserAttributes(attributes []*userAttribute, serialized []byte, exportable bool) ([]byte, error) {
+	if attributes == nil {
+		return serialized, nil
+	}
+
+	var packets bytes.Buffer
+	for _, attribute := range attributes {
+		if err := attribute.attribute.Serialize(&packets); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in serializing user attribute")
+		}
+		for _, sig := range attribute.signatures {
+			if exportable && !isExportable(sig) {
+				continue
+			}
+			if err := sig.Serialize(&packets); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: error in serializing user attribute signature")
+			}
+		}
+	}
+
+	offset := 0
+	for rest := serialized; len(rest) > 0; {
+		tag, _, next, err := readRawPacket(rest)
+		if err != nil {
+			return nil, err
+		}
+		if tag == packetTagPublicSubkey || tag == packetTagPrivateSubkey {
+			break
+		}
+		offset += len(rest) - len(next)
+		rest = next
+	}
+
+	result := make([]byte, 0, len(serialized)+packets.Len())
+	result = append(result, serialized[:offset]...)
+	result = append(result, packets.Bytes()...)
+	result = append(result, serialized[offset:]...)
+	clearMem(serialized)
+	return result, nil
+}
@@ -0,0 +1,176 @@
+package crypto
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal("Expected no error when encoding image, got:", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadPhotoID(t *testing.T) {
+	key, err := NewKeyFromArmored(readTestFile("key_photoID", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	assert.Exactly(t, 1, key.CountPhotoIDs())
+	photoID, err := key.GetPhotoID(0)
+	if err != nil {
+		t.Fatal("Expected no error when getting photo ID, got:", err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(photoID))
+	if err != nil {
+		t.Fatal("Expected a JPEG photo ID, got:", err)
+	}
+	assert.Exactly(t, 8, config.Width)
+	_, err = key.GetPhotoID(1)
+	assert.Error(t, err)
+
+	// The photo ID is kept when serializing the key
+	serialized, err := key.Serialize()
+	if err != nil {
+		t.Fatal("Expected no error when serializing key, got:", err)
+	}
+	parsedKey, err := NewKeyFromReader(bytes.NewReader(serialized))
+	if err != nil {
+		t.Fatal("Expected no error when parsing key, got:", err)
+	}
+	assert.Exactly(t, 1, parsedKey.CountPhotoIDs())
+	assert.Len(t, parsedKey.entity.Subkeys, 1)
+
+	keyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	keyRingCopy, err := keyRing.Copy()
+	if err != nil {
+		t.Fatal("Expected no error when copying keyring, got:", err)
+	}
+	assert.Exactly(t, 1, keyRingCopy.GetKeys()[0].CountPhotoIDs())
+
+	// Photo IDs without a valid self-signature are dropped
+	tampered := bytes.Replace(serialized, photoID[len(photoID)-16:], make([]byte, 16), 1)
+	tamperedKey, err := NewKeyFromReader(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatal("Expected no error when parsing tampered key, got:", err)
+	}
+	assert.Exactly(t, 0, tamperedKey.CountPhotoIDs())
+
+	assert.Exactly(t, 0, keyTestEC.CountPhotoIDs())
+}
+
+func TestAddPhotoID(t *testing.T) {
+	jpegImage := newTestJPEG(t)
+	updatedKey, err := keyTestEC.AddPhotoID(jpegImage)
+	if err != nil {
+		t.Fatal("Cannot add photo ID:", err)
+	}
+	assert.Exactly(t, 0, keyTestEC.CountPhotoIDs())
+	assert.Exactly(t, 1, updatedKey.CountPhotoIDs())
+
+	publicKey, err := updatedKey.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal("Cannot armor public key:", err)
+	}
+	parsedKey, err := NewKeyFromArmored(publicKey)
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	assert.Exactly(t, 1, parsedKey.CountPhotoIDs())
+	photoID, err := parsedKey.GetPhotoID(0)
+	if err != nil {
+		t.Fatal("Expected no error when getting photo ID, got:", err)
+	}
+	assert.Exactly(t, jpegImage, photoID)
+
+	lockedKey, err := updatedKey.Lock(keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	unlockedKey, err := lockedKey.Unlock(keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot unlock key:", err)
+	}
+	assert.Exactly(t, 1, unlockedKey.CountPhotoIDs())
+
+	secondKey, err := unlockedKey.AddPhotoID(jpegImage)
+	if err != nil {
+		t.Fatal("Cannot add photo ID:", err)
+	}
+	assert.Exactly(t, 2, secondKey.CountPhotoIDs())
+	assert.Exactly(t, 1, unlockedKey.CountPhotoIDs())
+
+	_, err = keyTestEC.AddPhotoID([]byte("not a JPEG image"))
+	assert.Error(t, err)
+	_, err = parsedKey.AddPhotoID(jpegImage)
+	assert.Error(t, err)
+}
+
+func TestMergePhotoIDs(t *testing.T) {
+	jpegImage := newTestJPEG(t)
+	photoKey, err := keyTestEC.AddPhotoID(jpegImage)
+	if err != nil {
+		t.Fatal("Cannot add photo ID:", err)
+	}
+	publicPhotoKey, err := photoKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+
+	mergedKey, err := keyTestEC.Merge(publicPhotoKey)
+	if err != nil {
+		t.Fatal("Expected no error when merging keys, got:", err)
+	}
+	assert.Exactly(t, 1, mergedKey.CountPhotoIDs())
+	mergedKey, err = mergedKey.Merge(photoKey)
+	if err != nil {
+		t.Fatal("Expected no error when merging keys, got:", err)
+	}
+	assert.Exactly(t, 1, mergedKey.CountPhotoIDs())
+
+	keyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	photoKeyRing, err := NewKeyRing(publicPhotoKey)
+	if err != nil {
+		t.Fatal("Expected no error when creating keyring, got:", err)
+	}
+	if err = keyRing.Merge(photoKeyRing); err != nil {
+		t.Fatal("Expected no error when merging keyrings, got:", err)
+	}
+	assert.Exactly(t, 1, keyRing.CountEntities())
+	assert.Exactly(t, 1, keyRing.GetKeys()[0].CountPhotoIDs())
+
+	// The photo IDs are kept when the keys of the keyring are replaced
+	keyRing.Lock()
+	assert.Exactly(t, 1, keyRing.GetKeys()[0].CountPhotoIDs())
+	publicKeyRing, err := keyRing.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error when getting public keyring, got:", err)
+	}
+	var armored bytes.Buffer
+	if err = publicKeyRing.GetArmoredPublicKeys(&armored); err != nil {
+		t.Fatal("Expected no error when armoring keyring, got:", err)
+	}
+	parsedKeyRing, err := NewKeyRingFromArmoredReader(&armored)
+	if err != nil {
+		t.Fatal("Expected no error when reading keyring, got:", err)
+	}
+	assert.Exactly(t, 1, parsedKeyRing.GetKeys()[0].CountPhotoIDs())
+
+	firstKey, err := parsedKeyRing.FirstKey()
+	if err != nil {
+		t.Fatal("Expected no error when getting first key, got:", err)
+	}
+	assert.Exactly(t, 1, firstKey.GetKeys()[0].CountPhotoIDs())
+}
@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/internal"
+)
+
+// VerificationTimePolicy is the time policy of a single signature
+// verification, replacing the verifyTime argument of the verifying functions.
+type VerificationTimePolicy struct {
+	// DisableTimeChecks accepts signatures regardless of their creation and
+	// expiration times, and of the revocation times of the keys.
+	DisableTimeChecks bool
+	// VerifyTime is the unix time at which the signature is verified.
+	// Zero means the current time, as returned by GetUnixTime.
+	VerifyTime int64
+	// FutureTolerance is the number of seconds a signature may be created
+	// after the verification time, e.g. by a device with a clock ahead.
+	FutureTolerance int64
+	// NotBefore is the unix time before which signatures are rejected, e.g.
+	// the compromise date of the signing key. Zero accepts any creation time.
+	// It is enforced even if DisableTimeChecks is set.
+	NotBefore int64
+}
+
+// NewVerificationTimePolicy returns the default policy of the verifying
+// functions: signatures are verified at verifyTime, or at the current time if
+// zero, and may be created up to two days later.
+func NewVerificationTimePolicy(verifyTime int64) *VerificationTimePolicy {
+	return &VerificationTimePolicy{
+		VerifyTime:      verifyTime,
+		FutureTolerance: internal.CreationTimeOffset,
+	}
+}
+
+// VerifyDetachedWithTimePolicy verifies a PlainMessage with a detached
+// PGPSignature like VerifyDetached, with the given time policy.
+func (keyRing *KeyRing) VerifyDetachedWithTimePolicy(
+	message *PlainMessage, signature *PGPSignature, policy *VerificationTimePolicy,
+) error {
+	verifyTime, creationTimeOffset := policy.resolve()
+	entities := keyRing.getEntities()
+	report, err := verifySignatureWithReport(
+		entities,
+		message.NewReader(),
+		signature.GetBinary(),
+		verifyTime,
+		creationTimeOffset,
+		nil,
+	)
+	if err == nil {
+		return policy.checkNotBefore(report.SignatureCreationTime)
+	}
+	return checkSignatureContentType(err, entities, message, signature.GetBinary(), nil)
+}
+
+// DecryptWithTimePolicy decrypts a PGPMessage like Decrypt, verifying the
+// signature with verifyKey with the given time policy.
+func (keyRing *KeyRing) DecryptWithTimePolicy(
+	message *PGPMessage, verifyKey *KeyRing, policy *VerificationTimePolicy,
+) (*PlainMessage, error) {
+	verifyTime, creationTimeOffset := policy.resolve()
+	plainMessage, err := asymmetricDecrypt(message, keyRing, verifyKey, verifyTime, creationTimeOffset, nil)
+	if err == nil && verifyKey != nil {
+		err = policy.checkNotBefore(plainMessage.signatureTime)
+	}
+	return plainMessage, err
+}
+
+// resolve returns the verification time, 0 if time checks are disabled, and
+// the creation time offset of the policy. A nil policy is the default one.
+func (policy *VerificationTimePolicy) resolve() (verifyTime, creationTimeOffset int64) {
+	if policy == nil {
+		policy = NewVerificationTimePolicy(0)
+	}
+	if policy.DisableTimeChecks {
+		return 0, 0
+	}
+	verifyTime = policy.VerifyTime
+	if verifyTime == 0 {
+		verifyTime = GetUnixTime()
+	}
+	creationTimeOffset = policy.FutureTolerance
+	if creationTimeOffset < 0 {
+		creationTimeOffset = 0
+	}
+	return verifyTime, creationTimeOffset
+}
+
+// checkNotBefore returns a SignatureVerificationError if a signature created
+// at creationTime is older than allowed by the policy.
+func (policy *VerificationTimePolicy) checkNotBefore(creationTime int64) error {
+	if policy != nil && creationTime < policy.NotBefore {
+		return newSignatureTooOld()
+	}
+	return nil
+}
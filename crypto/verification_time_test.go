@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+)
+
+func TestVerificationTimePolicy(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed in the future")
+	now := GetUnixTime()
+
+	// Signed by a device with a clock 100 seconds ahead
+	SetClock(ClockFunc(func() time.Time {
+		return time.Unix(now+100, 0)
+	}))
+	signature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	encrypted, err := keyRing.Encrypt(message, keyRing)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+	SetClock(nil)
+
+	strict := &VerificationTimePolicy{VerifyTime: now}
+	err = keyRing.VerifyDetachedWithTimePolicy(message, signature, strict)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+	_, err = keyRing.DecryptWithTimePolicy(encrypted, keyRing, strict)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	tolerant := &VerificationTimePolicy{VerifyTime: now, FutureTolerance: 200}
+	assert.Nil(t, keyRing.VerifyDetachedWithTimePolicy(message, signature, tolerant))
+	decrypted, err := keyRing.DecryptWithTimePolicy(encrypted, keyRing, tolerant)
+	assert.Nil(t, err)
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	disabled := &VerificationTimePolicy{DisableTimeChecks: true}
+	assert.Nil(t, keyRing.VerifyDetachedWithTimePolicy(message, signature, disabled))
+	_, err = keyRing.DecryptWithTimePolicy(encrypted, keyRing, disabled)
+	assert.Nil(t, err)
+
+	// The default policy allows a two days margin
+	assert.Nil(t, keyRing.VerifyDetachedWithTimePolicy(message, signature, NewVerificationTimePolicy(now)))
+	assert.Nil(t, keyRing.VerifyDetachedWithTimePolicy(message, signature, nil))
+
+	tampered := NewPlainMessageFromString("tampered")
+	err = keyRing.VerifyDetachedWithTimePolicy(tampered, signature, disabled)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+}
+
+func TestVerificationTimePolicyNotBefore(t *testing.T) {
+	keyRing, _ := NewKeyRing(keyTestRSA)
+	message := NewPlainMessageFromString("signed before the compromise")
+	now := GetUnixTime()
+
+	signature, err := keyRing.SignDetachedAtTime(message, now)
+	if err != nil {
+		t.Fatal("Cannot generate signature:", err)
+	}
+	report, err := keyRing.VerifyDetachedWithReport(message, signature, now)
+	if err != nil {
+		t.Fatal("Expected no error when verifying, got:", err)
+	}
+	assert.Exactly(t, now, report.SignatureCreationTime)
+
+	compromised := &VerificationTimePolicy{VerifyTime: now, NotBefore: now + 50}
+	err = keyRing.VerifyDetachedWithTimePolicy(message, signature, compromised)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+	assert.Nil(t, keyRing.VerifyDetachedWithTimePolicy(message, signature, &VerificationTimePolicy{NotBefore: now}))
+
+	// NotBefore is enforced even without time checks
+	compromised.DisableTimeChecks = true
+	err = keyRing.VerifyDetachedWithTimePolicy(message, signature, compromised)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	encrypted, err := keyRing.Encrypt(message, keyRing)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+
+	decrypted, err := keyRing.DecryptWithTimePolicy(encrypted, keyRing, nil)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	signatureTime, ok := decrypted.GetSignatureCreationTime()
+	assert.True(t, ok)
+	assert.True(t, signatureTime >= now)
+	signerFingerprint, ok := decrypted.GetHexSignerFingerprint()
+	assert.True(t, ok)
+	assert.Exactly(t, keyRing.GetKeys()[0].GetFingerprint(), signerFingerprint)
+
+	_, err = keyRing.DecryptWithTimePolicy(encrypted, keyRing, &VerificationTimePolicy{NotBefore: signatureTime + 1})
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(err))
+
+	decrypted, err = keyRing.Decrypt(encrypted, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	_, ok = decrypted.GetSignatureCreationTime()
+	assert.False(t, ok)
+	_, ok = decrypted.GetHexSignerFingerprint()
+	assert.False(t, ok)
+}
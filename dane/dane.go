@@ -0,0 +1,255 @@
+// Package dane discovers the public keys of email addresses in the DNS, with
+// the OPENPGPKEY records of RFC 7929.
+//
+// The records are only as trustworthy as the DNS: the resolver should
+// validate them with DNSSEC, which it reports in the results. Queries are sent
+// over UDP, and retried over TCP when the response is truncated.
+package dane
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// ErrNotFound is returned when no key is published for an email address.
+var ErrNotFound = errors.New("gopenpgp: no OPENPGPKEY record found")
+
+// ErrNotAuthenticated is returned when DNSSEC is required, but the resolver
+// didn't validate the records.
+var ErrNotAuthenticated = errors.New("gopenpgp: OPENPGPKEY records not validated with DNSSEC")
+
+// defaultTimeout limits the duration of a query, if the context has no deadline.
+const defaultTimeout = 5 * time.Second
+
+// Resolver looks up OPENPGPKEY records with a recursive DNS resolver.
+type Resolver struct {
+	server string
+	// If RequireDNSSEC is true, lookups fail with ErrNotAuthenticated unless
+	// the resolver validated the records with DNSSEC.
+	RequireDNSSEC bool
+}
+
+// Result is the result of a lookup.
+type Result struct {
+	KeyRing *crypto.KeyRing
+	// Whether the resolver validated the records with DNSSEC
+	Authenticated bool
+}
+
+// NewResolver returns a resolver sending its queries to the recursive
+// resolver at server, as "host:port".
+func NewResolver(server string) *Resolver {
+	return &Resolver{server: server}
+}
+
+// NewDefaultResolver returns a resolver using the first name server of
+// /etc/resolv.conf.
+func NewDefaultResolver() (*Resolver, error) {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read the resolver configuration")
+	}
+	defer file.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return NewResolver(net.JoinHostPort(fields[1], "53")), nil
+		}
+	}
+	return nil, errors.New("gopenpgp: no name server configured")
+}
+
+// GetDomainName returns the domain name of the OPENPGPKEY records of the
+// email address.
+func GetDomainName(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", errors.New("gopenpgp: invalid email address")
+	}
+	digest := sha256.Sum256([]byte(email[:at]))
+	return hex.EncodeToString(digest[:28]) + "._openpgpkey." + strings.ToLower(email[at+1:]), nil
+}
+
+// Lookup returns the keys published for the email address, or ErrNotFound.
+// Only the keys with a user ID matching the address are returned.
+// As the local part of the address is case-sensitive, its lowercase version
+// is only looked up if the address itself has no records.
+func (resolver *Resolver) Lookup(ctx context.Context, email string) (*Result, error) {
+	records, authenticated, err := resolver.lookupRecords(ctx, email)
+	if err == ErrNotFound && strings.ToLower(email) != email {
+		records, authenticated, err = resolver.lookupRecords(ctx, strings.ToLower(email))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resolver.RequireDNSSEC && !authenticated {
+		return nil, ErrNotAuthenticated
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		entities, err := openpgp.ReadKeyRing(bytes.NewReader(record))
+		if err != nil {
+			continue
+		}
+		for _, entity := range entities {
+			if !hasEmail(entity, email) {
+				continue
+			}
+			key, err := crypto.NewKeyFromEntity(entity)
+			if err != nil {
+				return nil, err
+			}
+			if err := keyRing.AddKey(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if keyRing.CountEntities() == 0 {
+		return nil, ErrNotFound
+	}
+	return &Result{KeyRing: keyRing, Authenticated: authenticated}, nil
+}
+
+// --- Internal functions
+
+// lookupRecords returns the OPENPGPKEY records of the email address.
+func (resolver *Resolver) lookupRecords(ctx context.Context, email string) ([][]byte, bool, error) {
+	name, err := GetDomainName(email)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, false, errors.Wrap(err, "gopenpgp: unable to generate query ID")
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+	query, err := buildQuery(id, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	resp, err := resolver.exchange(ctx, "udp", query, id, name)
+	if err == nil && resp.truncated {
+		resp, err = resolver.exchange(ctx, "tcp", query, id, name)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case resp.rcode == rcodeNameError:
+		return nil, false, ErrNotFound
+	case resp.rcode != 0:
+		return nil, false, errors.Errorf("gopenpgp: DNS query failed with code %d", resp.rcode)
+	case len(resp.records) == 0:
+		return nil, false, ErrNotFound
+	}
+	return resp.records, resp.authenticated, nil
+}
+
+// exchange sends the query with the given ID for the records of name to the
+// resolver over the network, "udp" or "tcp", and returns its response.
+// Over UDP, replies that don't answer the query are discarded, as anybody
+// could send them, until the response arrives or the context is done.
+func (resolver *Resolver) exchange(
+	ctx context.Context, network string, query []byte, id uint16, name string,
+) (*response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, resolver.server)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to reach the DNS resolver")
+	}
+	defer conn.Close() //nolint:errcheck
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		// Messages are prefixed with their length over TCP
+		message := make([]byte, 2, 2+len(query))
+		binary.BigEndian.PutUint16(message, uint16(len(query)))
+		if _, err = conn.Write(append(message, query...)); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to send DNS query")
+		}
+		var length [2]byte
+		if _, err = io.ReadFull(conn, length[:]); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read DNS response")
+		}
+		data := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err = io.ReadFull(conn, data); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read DNS response")
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		resp, err := parseResponse(data, name)
+		if err != nil {
+			return nil, err
+		}
+		if resp.id != id {
+			return nil, errors.New("gopenpgp: mismatched DNS response")
+		}
+		return resp, nil
+	}
+
+	if _, err = conn.Write(query); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to send DNS query")
+	}
+	data := make([]byte, ednsUDPSize)
+	for {
+		n, err := conn.Read(data)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read DNS response")
+		}
+		if n < 2 || binary.BigEndian.Uint16(data) != id {
+			continue
+		}
+		resp, err := parseResponse(data[:n], name)
+		if err != nil {
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// hasEmail returns true if entity has a user ID with the email address.
+func hasEmail(entity *openpgp.Entity, email string) bool {
+	for _, identity := range entity.Identities {
+		if strings.EqualFold(identity.UserId.Email, email) {
+			return true
+		}
+	}
+	return false
+}
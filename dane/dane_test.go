@@ -0,0 +1,207 @@
+package dane
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+func TestGetDomainName(t *testing.T) {
+	// Example of RFC 7929, section 3
+	name, err := GetDomainName("hugh@example.com")
+	if err != nil {
+		t.Fatal("Cannot get domain name:", err)
+	}
+	assert.Equal(t, "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._openpgpkey.example.com", name)
+
+	_, err = GetDomainName("example.com")
+	assert.NotNil(t, err)
+}
+
+// testServer answers the queries for name with record, over UDP and TCP.
+type testServer struct {
+	name          string
+	record        []byte
+	authenticated bool
+	// Whether UDP responses are truncated
+	truncate bool
+	// Whether a reply with another ID is sent before each UDP response
+	spoof bool
+}
+
+func (server *testServer) answer(query []byte, overUDP bool) []byte {
+	_, questionEnd, _ := readName(query, headerSize)
+	questionEnd += 4
+
+	flags := uint16(flagResponse | flagRecursionDesired)
+	if server.authenticated {
+		flags |= flagAuthenticatedData
+	}
+	name, _ := GetDomainName(server.name)
+	expected, _ := buildQuery(0, name)
+	matches := string(expected[headerSize:questionEnd]) == string(query[headerSize:questionEnd])
+
+	resp := append([]byte{}, query[:questionEnd]...)
+	binary.BigEndian.PutUint16(resp[6:], 0)  // Answers
+	binary.BigEndian.PutUint16(resp[10:], 0) // Additional records
+	switch {
+	case !matches:
+		flags |= rcodeNameError
+	case overUDP && server.truncate:
+		flags |= flagTruncated
+	default:
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		resp = append(resp, 0xc0, headerSize)
+		resp = appendUint16(resp, typeOPENPGPKEY)
+		resp = appendUint16(resp, classINET)
+		resp = append(resp, 0, 0, 0x0e, 0x10)
+		resp = appendUint16(resp, uint16(len(server.record)))
+		resp = append(resp, server.record...)
+	}
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	return resp
+}
+
+func (server *testServer) start(t *testing.T) (address string, stop func()) {
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Cannot listen:", err)
+	}
+	tcpListener, err := net.Listen("tcp", udpConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal("Cannot listen:", err)
+	}
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := udpConn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			resp := server.answer(buffer[:n], true)
+			if server.spoof {
+				spoofed := append([]byte{}, resp...)
+				spoofed[0] ^= 0xff
+				_, _ = udpConn.WriteTo(spoofed, addr)
+			}
+			_, _ = udpConn.WriteTo(resp, addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err = io.ReadFull(conn, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err = io.ReadFull(conn, query); err == nil {
+					resp := server.answer(query, false)
+					_, _ = conn.Write(append(appendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	return udpConn.LocalAddr().String(), func() {
+		_ = udpConn.Close()
+		_ = tcpListener.Close()
+	}
+}
+
+func TestLookup(t *testing.T) {
+	key, err := crypto.GenerateKey("Hugh", "hugh@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	record, err := key.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+
+	server := &testServer{name: "hugh@example.com", record: record, authenticated: true}
+	address, stop := server.start(t)
+	defer stop()
+	resolver := NewResolver(address)
+	ctx := context.Background()
+
+	result, err := resolver.Lookup(ctx, "hugh@example.com")
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.True(t, result.Authenticated)
+	assert.Exactly(t, 1, result.KeyRing.CountEntities())
+	assert.Equal(t, key.GetFingerprint(), result.KeyRing.GetKeys()[0].GetFingerprint())
+
+	// The lowercase local part is looked up as a fallback
+	_, err = resolver.Lookup(ctx, "Hugh@example.com")
+	assert.Nil(t, err)
+
+	_, err = resolver.Lookup(ctx, "someone.else@example.com")
+	assert.Exactly(t, ErrNotFound, err)
+
+	// Replies with another ID are ignored
+	server.spoof = true
+	_, err = resolver.Lookup(ctx, "hugh@example.com")
+	assert.Nil(t, err)
+	server.spoof = false
+
+	server.truncate = true
+	server.authenticated = false
+	result, err = resolver.Lookup(ctx, "hugh@example.com")
+	if err != nil {
+		t.Fatal("Cannot look up key over TCP:", err)
+	}
+	assert.False(t, result.Authenticated)
+
+	resolver.RequireDNSSEC = true
+	_, err = resolver.Lookup(ctx, "hugh@example.com")
+	assert.Exactly(t, ErrNotAuthenticated, err)
+}
+
+func TestParseResponse(t *testing.T) {
+	name, _ := GetDomainName("hugh@example.com")
+	otherName, _ := GetDomainName("someone.else@example.com")
+	query, err := buildQuery(1, name)
+	if err != nil {
+		t.Fatal("Cannot build query:", err)
+	}
+	server := &testServer{name: "hugh@example.com", record: []byte{0x01}}
+	resp := server.answer(query, false)
+
+	parsed, err := parseResponse(resp, name)
+	if err != nil {
+		t.Fatal("Cannot parse response:", err)
+	}
+	assert.Exactly(t, [][]byte{{0x01}}, parsed.records)
+
+	// The question must be the query
+	_, err = parseResponse(resp, otherName)
+	assert.NotNil(t, err)
+
+	// Records of other names are ignored
+	_, questionEnd, _ := readName(resp, headerSize)
+	questionEnd += 4
+	other, _ := buildQuery(1, otherName)
+	_, otherEnd, _ := readName(other, headerSize)
+	withOtherOwner := append(append([]byte{}, resp[:questionEnd]...), other[headerSize:otherEnd]...)
+	withOtherOwner = append(withOtherOwner, resp[questionEnd+2:]...)
+	parsed, err = parseResponse(withOtherOwner, name)
+	if err != nil {
+		t.Fatal("Cannot parse response:", err)
+	}
+	assert.Empty(t, parsed.records)
+
+	// Truncated questions are rejected
+	_, err = parseResponse(resp[:questionEnd-2], name)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,183 @@
+package dane
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DNS constants, from RFC 1035, RFC 4035 and RFC 6891.
+const (
+	typeCNAME      = 5
+	typeOPT        = 41
+	typeOPENPGPKEY = 61
+	classINET      = 1
+
+	flagResponse          = 1 << 15
+	flagTruncated         = 1 << 9
+	flagRecursionDesired  = 1 << 8
+	flagAuthenticatedData = 1 << 5
+	rcodeMask             = 0xf
+	rcodeNameError        = 3
+	headerSize            = 12
+	ednsUDPSize           = 4096
+	ednsFlagDNSSECOK      = 1 << 15
+)
+
+// response is a parsed DNS response.
+type response struct {
+	id            uint16
+	truncated     bool
+	authenticated bool
+	rcode         int
+	// Data of the OPENPGPKEY records of the answer section
+	records [][]byte
+}
+
+// buildQuery returns a query for the OPENPGPKEY records of name, asking the
+// resolver to validate them with DNSSEC.
+func buildQuery(id uint16, name string) ([]byte, error) {
+	query := make([]byte, headerSize, 512)
+	binary.BigEndian.PutUint16(query[0:], id)
+	binary.BigEndian.PutUint16(query[2:], flagRecursionDesired|flagAuthenticatedData)
+	binary.BigEndian.PutUint16(query[4:], 1)  // Questions
+	binary.BigEndian.PutUint16(query[10:], 1) // Additional records
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errors.New("gopenpgp: invalid domain name")
+		}
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0)
+	query = appendUint16(query, typeOPENPGPKEY)
+	query = appendUint16(query, classINET)
+
+	// EDNS0 OPT record, to allow large responses and set the DNSSEC OK bit
+	query = append(query, 0)
+	query = appendUint16(query, typeOPT)
+	query = appendUint16(query, ednsUDPSize)
+	query = append(query, 0, 0) // Extended rcode and version
+	query = appendUint16(query, ednsFlagDNSSECOK)
+	query = appendUint16(query, 0) // No options
+	return query, nil
+}
+
+// parseResponse parses a DNS response to the query for the OPENPGPKEY
+// records of name, keeping the records of name, or of its aliases.
+func parseResponse(data []byte, name string) (*response, error) {
+	if len(data) < headerSize {
+		return nil, errors.New("gopenpgp: truncated DNS response")
+	}
+	flags := binary.BigEndian.Uint16(data[2:])
+	if flags&flagResponse == 0 {
+		return nil, errors.New("gopenpgp: invalid DNS response")
+	}
+	resp := &response{
+		id:            binary.BigEndian.Uint16(data[0:]),
+		truncated:     flags&flagTruncated != 0,
+		authenticated: flags&flagAuthenticatedData != 0,
+		rcode:         int(flags & rcodeMask),
+	}
+	questions := int(binary.BigEndian.Uint16(data[4:]))
+	answers := int(binary.BigEndian.Uint16(data[6:]))
+	if questions != 1 {
+		return nil, errors.New("gopenpgp: mismatched DNS response")
+	}
+
+	name = strings.TrimSuffix(name, ".")
+	questionName, offset, err := readName(data, headerSize)
+	if err != nil {
+		return nil, err
+	}
+	if offset+4 > len(data) {
+		return nil, errors.New("gopenpgp: truncated DNS response")
+	}
+	if !strings.EqualFold(questionName, name) ||
+		binary.BigEndian.Uint16(data[offset:]) != typeOPENPGPKEY ||
+		binary.BigEndian.Uint16(data[offset+2:]) != classINET {
+		return nil, errors.New("gopenpgp: mismatched DNS response")
+	}
+	offset += 4 // Type and class
+
+	// The answer may go through aliases of the name
+	owners := map[string]bool{strings.ToLower(name): true}
+	for i := 0; i < answers; i++ {
+		var owner string
+		if owner, offset, err = readName(data, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(data) {
+			return nil, errors.New("gopenpgp: truncated DNS response")
+		}
+		recordType := binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+8:]))
+		offset += 10
+		if offset+length > len(data) {
+			return nil, errors.New("gopenpgp: truncated DNS response")
+		}
+		if owners[strings.ToLower(owner)] {
+			switch recordType {
+			case typeOPENPGPKEY:
+				resp.records = append(resp.records, data[offset:offset+length])
+			case typeCNAME:
+				alias, _, err := readName(data, offset)
+				if err != nil {
+					return nil, err
+				}
+				owners[strings.ToLower(alias)] = true
+			}
+		}
+		offset += length
+	}
+
+	return resp, nil
+}
+
+// readName returns the possibly compressed domain name at offset, without
+// trailing dot, and the offset following it.
+func readName(data []byte, offset int) (name string, next int, err error) {
+	var labels []string
+	next = -1
+	// Each pointer must go backwards, so that they can't loop
+	limit := len(data)
+	for {
+		if offset >= len(data) {
+			return "", 0, errors.New("gopenpgp: truncated DNS response")
+		}
+		length := int(data[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+2 > len(data) {
+				return "", 0, errors.New("gopenpgp: truncated DNS response")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			pointer := int(binary.BigEndian.Uint16(data[offset:]) & 0x3fff)
+			if pointer >= limit {
+				return "", 0, errors.New("gopenpgp: invalid DNS name compression")
+			}
+			offset, limit = pointer, pointer
+		case length&0xc0 != 0:
+			return "", 0, errors.New("gopenpgp: invalid DNS name")
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, errors.New("gopenpgp: truncated DNS response")
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+func appendUint16(data []byte, value uint16) []byte {
+	return append(data, byte(value>>8), byte(value))
+}
@@ -0,0 +1,223 @@
+package gpgagent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxLineLength is the maximum length of an Assuan line, without its newline.
+const maxLineLength = 1000
+
+// newClient returns a client for an Assuan connection, once the agent greeted.
+func newClient(conn io.ReadWriteCloser) (*Client, error) {
+	client := &Client{conn: conn, reader: bufio.NewReader(conn)}
+	if _, _, err := client.readResponse(nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// transact sends an Assuan command, answers the inquiries of the agent with
+// the data of inquiries, and returns the data and the status lines sent back.
+// The caller must hold the lock of the client.
+func (client *Client) transact(command string, inquiries map[string][]byte) ([]byte, map[string]string, error) {
+	if err := client.writeLine(command); err != nil {
+		return nil, nil, err
+	}
+	return client.readResponse(inquiries)
+}
+
+// readResponse reads the lines sent by the agent until the final OK or ERR.
+func (client *Client) readResponse(inquiries map[string][]byte) ([]byte, map[string]string, error) {
+	var data []byte
+	status := make(map[string]string)
+	for {
+		line, err := client.reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "gopenpgp: error in reading from gpg-agent")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		keyword, args := splitLine(line)
+
+		switch keyword {
+		case "OK":
+			return data, status, nil
+		case "ERR":
+			code, description := splitLine(args)
+			codeValue, _ := strconv.ParseUint(code, 10, 32)
+			return nil, nil, &Error{Code: uint32(codeValue), Description: description}
+		case "D":
+			chunk, err := unescape(args)
+			if err != nil {
+				return nil, nil, err
+			}
+			data = append(data, chunk...)
+		case "S":
+			name, value := splitLine(args)
+			status[name] = value
+		case "INQUIRE":
+			name, _ := splitLine(args)
+			// Unknown inquiries, e.g. PINENTRY_LAUNCHED, are answered with no data
+			if err := client.writeData(inquiries[name]); err != nil {
+				return nil, nil, err
+			}
+			if err := client.writeLine("END"); err != nil {
+				return nil, nil, err
+			}
+		case "#", "":
+		default:
+			return nil, nil, errors.New("gopenpgp: unexpected response from gpg-agent")
+		}
+	}
+}
+
+// writeLine sends a line to the agent.
+func (client *Client) writeLine(line string) error {
+	if len(line) > maxLineLength || strings.ContainsAny(line, "\r\n") {
+		return errors.New("gopenpgp: invalid command for gpg-agent")
+	}
+	if _, err := io.WriteString(client.conn, line+"\n"); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in writing to gpg-agent")
+	}
+	return nil
+}
+
+// writeData sends data to the agent in escaped D lines.
+func (client *Client) writeData(data []byte) error {
+	var line strings.Builder
+	for _, b := range data {
+		if line.Len() == 0 {
+			line.WriteString("D ")
+		}
+		if b == '%' || b == '\r' || b == '\n' {
+			fmt.Fprintf(&line, "%%%02X", b)
+		} else {
+			line.WriteByte(b)
+		}
+		if line.Len() > maxLineLength-3 {
+			if err := client.writeLine(line.String()); err != nil {
+				return err
+			}
+			line.Reset()
+		}
+	}
+	if line.Len() > 0 {
+		return client.writeLine(line.String())
+	}
+	return nil
+}
+
+// splitLine splits a line into its first word and the remaining arguments.
+func splitLine(line string) (keyword, args string) {
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		return line[:i], line[i+1:]
+	}
+	return line, ""
+}
+
+// unescape decodes the percent-escaped bytes of an Assuan data line.
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", errors.New("gopenpgp: invalid escaping in gpg-agent response")
+		}
+		value, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", errors.New("gopenpgp: invalid escaping in gpg-agent response")
+		}
+		b.WriteByte(byte(value))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// sexp is a canonical S-expression, as exchanged with the agent: either an
+// atom, or a list.
+type sexp struct {
+	atom []byte
+	list []*sexp
+}
+
+// parseSexp parses a canonical S-expression, possibly terminated by a zero
+// byte as the agent sends them.
+func parseSexp(data []byte) (*sexp, error) {
+	expr, rest, err := parseSexpItem(bytes.TrimSuffix(data, []byte{0}))
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("gopenpgp: invalid S-expression from gpg-agent")
+	}
+	return expr, nil
+}
+
+// parseSexpItem parses the first atom or list of data, and returns the rest.
+func parseSexpItem(data []byte) (*sexp, []byte, error) {
+	if len(data) > 0 && data[0] == '(' {
+		data = data[1:]
+		expr := &sexp{list: []*sexp{}}
+		for len(data) > 0 && data[0] != ')' {
+			child, rest, err := parseSexpItem(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			expr.list = append(expr.list, child)
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, errors.New("gopenpgp: invalid S-expression from gpg-agent")
+		}
+		return expr, data[1:], nil
+	}
+
+	colon := bytes.IndexByte(data, ':')
+	if colon < 1 {
+		return nil, nil, errors.New("gopenpgp: invalid S-expression from gpg-agent")
+	}
+	length, err := strconv.Atoi(string(data[:colon]))
+	if err != nil || length < 0 || length > len(data)-colon-1 {
+		return nil, nil, errors.New("gopenpgp: invalid S-expression from gpg-agent")
+	}
+	data = data[colon+1:]
+	return &sexp{atom: data[:length]}, data[length:], nil
+}
+
+// find returns the first list named name, i.e. starting with the atom name,
+// searched depth-first, or nil.
+func (expr *sexp) find(name string) *sexp {
+	if expr == nil || expr.list == nil {
+		return nil
+	}
+	if len(expr.list) > 0 && string(expr.list[0].atom) == name && expr.list[0].list == nil {
+		return expr
+	}
+	for _, child := range expr.list {
+		if found := child.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// value returns the atom following the name of a list, or nil.
+func (expr *sexp) value() []byte {
+	if expr == nil || len(expr.list) < 2 || expr.list[1].list != nil {
+		return nil
+	}
+	return expr.list[1].atom
+}
@@ -0,0 +1,331 @@
+// Package gpgagent delegates the private key operations of keys held by
+// GnuPG to a running gpg-agent, over its Assuan socket, so that they can be
+// used without exporting their secret material, e.g. when they are stored on
+// a smartcard.
+//
+// Only RSA keys are supported, as go-crypto only delegates the operations of
+// RSA keys: EdDSA, ECDSA and ECDH keys are rejected with
+// ErrUnsupportedAlgorithm. The agent is reached through a Unix socket: the
+// socket emulation of GnuPG on Windows is not supported.
+package gpgagent
+
+import (
+	"bufio"
+	"bytes"
+	gocrypto "crypto"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// errNoSecretKey is the GPG_ERR_NO_SECKEY error code.
+const errNoSecretKey = 17
+
+// hashAlgos are the libgcrypt identifiers of the hashes accepted by SETHASH.
+var hashAlgos = map[gocrypto.Hash]int{
+	gocrypto.MD5:       1,
+	gocrypto.SHA1:      2,
+	gocrypto.RIPEMD160: 3,
+	gocrypto.SHA256:    8,
+	gocrypto.SHA384:    9,
+	gocrypto.SHA512:    10,
+	gocrypto.SHA224:    11,
+}
+
+// ErrUnsupportedAlgorithm is returned for the keys whose secret keys can't be
+// delegated to gpg-agent, i.e. the keys other than RSA keys.
+var ErrUnsupportedAlgorithm = errors.New("gopenpgp: only RSA keys are supported by gpg-agent")
+
+// Error is an error returned by gpg-agent.
+type Error struct {
+	// Code is the GnuPG error code, with its error source in the high bits.
+	Code uint32
+	// Description is the error description sent by the agent.
+	Description string
+}
+
+func (err *Error) Error() string {
+	return "gopenpgp: gpg-agent error: " + err.Description
+}
+
+// Client is a connection to gpg-agent. It is safe for concurrent use.
+type Client struct {
+	conn   io.ReadWriteCloser
+	reader *bufio.Reader
+	lock   sync.Mutex
+}
+
+// DefaultSocketPath returns the socket of the gpg-agent of the current user,
+// as listed by gpgconf, or in the GnuPG home directory if gpgconf is missing.
+func DefaultSocketPath() (string, error) {
+	if out, err := exec.Command("gpgconf", "--list-dirs", "agent-socket").Output(); err == nil {
+		if path := strings.TrimSpace(string(out)); path != "" {
+			return unescape(path)
+		}
+	}
+
+	home := os.Getenv("GNUPGHOME")
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "gopenpgp: unable to locate the gpg-agent socket")
+		}
+		home = filepath.Join(userHome, ".gnupg")
+	}
+	return filepath.Join(home, "S.gpg-agent"), nil
+}
+
+// Dial connects to the gpg-agent listening on socketPath.
+func Dial(socketPath string) (*Client, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to connect to gpg-agent")
+	}
+	return newClient(conn)
+}
+
+// DialDefault connects to the gpg-agent of the current user, at
+// DefaultSocketPath.
+func DialDefault() (*Client, error) {
+	socketPath, err := DefaultSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	return Dial(socketPath)
+}
+
+// Close closes the connection to the agent.
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+// HasKey returns whether the agent holds the secret key with the given
+// keygrip, possibly on a smartcard.
+func (client *Client) HasKey(keygrip string) (bool, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	_, _, err := client.transact("HAVEKEY "+keygrip, nil)
+	var agentErr *Error
+	if errors.As(err, &agentErr) && agentErr.Code&0xffff == errNoSecretKey {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NewKey returns a private key, created with crypto.NewKeyFromSigner from the
+// public key or the public part of the private key, whose current signing
+// and encryption keys are delegated to the agent if it holds them.
+// Certifying fails if the primary key is not the signing key, or is not held,
+// e.g. when only the subkeys are on a smartcard. ErrUnsupportedAlgorithm is
+// returned if the signing or encryption key is not an RSA key.
+func (client *Client) NewKey(key *crypto.Key) (*crypto.Key, error) {
+	entity := key.GetEntity()
+	now := crypto.GetTime()
+
+	var signer gocrypto.Signer
+	if signingKey, ok := entity.SigningKey(now); ok {
+		privateKey, err := client.heldPrivateKey(signingKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if privateKey != nil {
+			signer = privateKey
+		}
+	}
+	var decrypter gocrypto.Decrypter
+	if encryptionKey, ok := entity.EncryptionKey(now); ok {
+		privateKey, err := client.heldPrivateKey(encryptionKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if privateKey != nil {
+			decrypter = privateKey
+		}
+	}
+	if signer == nil && decrypter == nil {
+		return nil, errors.New("gopenpgp: gpg-agent does not hold any secret key of the key")
+	}
+
+	return crypto.NewKeyFromSigner(key, signer, decrypter)
+}
+
+// Keygrip returns the keygrip identifying the secret key of publicKey in
+// gpg-agent, in hex.
+func Keygrip(publicKey *packet.PublicKey) (string, error) {
+	rsaKey, ok := publicKey.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", ErrUnsupportedAlgorithm
+	}
+
+	// The grip of RSA keys is the hash of their modulus, as a signed integer
+	grip := sha1.Sum(signedBytes(rsaKey.N)) //nolint:gosec
+	return strings.ToUpper(hex.EncodeToString(grip[:])), nil
+}
+
+// PrivateKey is an RSA private key held by gpg-agent. It implements
+// crypto.Signer and crypto.Decrypter.
+type PrivateKey struct {
+	client    *Client
+	keygrip   string
+	publicKey *rsa.PublicKey
+}
+
+// NewPrivateKey returns the private key with the given keygrip and public key.
+// The agent is only asked for the key when it is used.
+func (client *Client) NewPrivateKey(keygrip string, publicKey *rsa.PublicKey) *PrivateKey {
+	return &PrivateKey{client: client, keygrip: keygrip, publicKey: publicKey}
+}
+
+// Public returns the *rsa.PublicKey of the private key.
+func (key *PrivateKey) Public() gocrypto.PublicKey {
+	return key.publicKey
+}
+
+// Sign signs a digest with PKCS #1 v1.5, computed with the hash of opts.
+// The agent may ask the user for the passphrase or smartcard PIN.
+func (key *PrivateKey) Sign(_ io.Reader, digest []byte, opts gocrypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("gopenpgp: RSA-PSS signatures are not supported by gpg-agent")
+	}
+	algo, ok := hashAlgos[opts.HashFunc()]
+	if !ok || len(digest) != opts.HashFunc().Size() {
+		return nil, errors.New("gopenpgp: unsupported hash for gpg-agent")
+	}
+
+	key.client.lock.Lock()
+	defer key.client.lock.Unlock()
+
+	if _, _, err := key.client.transact("SIGKEY "+key.keygrip, nil); err != nil {
+		return nil, err
+	}
+	if _, _, err := key.client.transact(fmt.Sprintf("SETHASH %d %X", algo, digest), nil); err != nil {
+		return nil, err
+	}
+	data, _, err := key.client.transact("PKSIGN", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sigVal, err := parseSexp(data)
+	if err != nil {
+		return nil, err
+	}
+	s := sigVal.find("s").value()
+	if s == nil {
+		return nil, errors.New("gopenpgp: invalid signature from gpg-agent")
+	}
+	return leftPad(s, (key.publicKey.N.BitLen()+7)/8)
+}
+
+// Decrypt decrypts a ciphertext encrypted with PKCS #1 v1.5, and returns the
+// unpadded plaintext. The agent may ask the user for the passphrase or
+// smartcard PIN.
+func (key *PrivateKey) Decrypt(_ io.Reader, ciphertext []byte, opts gocrypto.DecrypterOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.OAEPOptions); ok {
+		return nil, errors.New("gopenpgp: RSA-OAEP decryption is not supported by gpg-agent")
+	}
+
+	key.client.lock.Lock()
+	defer key.client.lock.Unlock()
+
+	if _, _, err := key.client.transact("SETKEY "+key.keygrip, nil); err != nil {
+		return nil, err
+	}
+	a := signedBytes(new(big.Int).SetBytes(ciphertext))
+	encVal := []byte(fmt.Sprintf("(7:enc-val(3:rsa(1:a%d:%s)))", len(a), a))
+	data, status, err := key.client.transact("PKDECRYPT", map[string][]byte{"CIPHERTEXT": encVal})
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseSexp(data)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := value.find("value").value()
+	if plaintext == nil {
+		return nil, errors.New("gopenpgp: invalid plaintext from gpg-agent")
+	}
+	// The agent removes the padding itself if it says so, e.g. for smartcards
+	if status["PADDING"] == "0" {
+		return plaintext, nil
+	}
+	return unpadPKCS1(plaintext)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// heldPrivateKey returns the private key of publicKey delegated to the
+// agent, or nil if the agent does not hold it.
+func (client *Client) heldPrivateKey(publicKey *packet.PublicKey) (*PrivateKey, error) {
+	rsaKey, ok := publicKey.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	keygrip, err := Keygrip(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	hasKey, err := client.HasKey(keygrip)
+	if err != nil || !hasKey {
+		return nil, err
+	}
+	return client.NewPrivateKey(keygrip, rsaKey), nil
+}
+
+// signedBytes returns the big-endian bytes of a positive integer, with a
+// leading zero if its high bit is set, as libgcrypt formats integers.
+func signedBytes(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// leftPad returns the big-endian integer b on exactly size bytes.
+func leftPad(b []byte, size int) ([]byte, error) {
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > size {
+		return nil, errors.New("gopenpgp: invalid signature from gpg-agent")
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded, nil
+}
+
+// unpadPKCS1 removes the PKCS #1 v1.5 encryption padding of a decrypted
+// block, whose leading zero may be missing.
+func unpadPKCS1(block []byte) ([]byte, error) {
+	if len(block) > 0 && block[0] == 0 {
+		block = block[1:]
+	}
+	if len(block) < 10 || block[0] != 2 {
+		return nil, errors.New("gopenpgp: invalid padding of the plaintext from gpg-agent")
+	}
+	end := bytes.IndexByte(block[1:], 0)
+	if end < 8 {
+		return nil, errors.New("gopenpgp: invalid padding of the plaintext from gpg-agent")
+	}
+	return block[end+2:], nil
+}
@@ -0,0 +1,262 @@
+package gpgagent
+
+import (
+	"bufio"
+	gocrypto "crypto"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// agentTestKey is an RSA key generated by GnuPG, whose keygrips are listed
+// by `gpg --with-keygrip`.
+const agentTestKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mI0EY2BhgAEEAMmjtKcV9iq+cno2uPBPFyHWKXgP7mqtHAWphV+pEskynC+l+xyY
+If0UWhINNxyptpI2w/RGAn8LZTaYUGIxO2LkLYk4tFwvav4P2pM/i+dksCZdgbce
+sMFX8VLLbbnfr8uhwjgP7nYDY5Sg+KBv1u/PYPqX9IwU9McH9eNnnZXFABEBAAG0
+HkFnZW50IFRlc3QgPGFnZW50QGV4YW1wbGUub3JnPojOBBMBCgA4FiEETe0qba3X
+f99sOh/N3hJTBcvhzjMFAmNgYYACGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AA
+CgkQ3hJTBcvhzjNr7QQAptXtRdcBpr7Dr+SzX0v9oe4c4IB8jyyxK/bD0He7294B
++DGij+y0Ihk4ULQOwwDStkREhxlnzdi2dWe5cvSf7W5FmM0Rlf7mRSz8Tnr5DMAP
+3uHxDYF+T2ykHUE7eGfVyr7eA1I/tTD1Au0J3R5GxIjMy/UwsL77eo09qxSAwe64
+jQRjYGGAAQQA+lu793ZeebBfoKxCyQefQjsVGbFJz19032DL4HLTh+h1N+6Nmyel
+65y6sdi7aDzgfcx03MjqcHkzujIgwiqeNxVx07IoS2mAAlezudq2RSwnFv1DYDGw
+RZBx8dVBMBWWR9omjAbTrM2QYzWip3aDnleXos6yz8YI5lZXsXmuYJcAEQEAAYi2
+BBgBCgAgFiEETe0qba3Xf99sOh/N3hJTBcvhzjMFAmNgYYACGwwACgkQ3hJTBcvh
+zjPRcgP+L4KNAyW6ZOo36YlKiuh7RuH9xLVG0D/EygJ+R65jD6ECIkAEIi8aHqry
+x5fIP44v4IznTVJipELY6txZbjykxY0UecIKzwa1D6+nrEYRjYVdz67YAHsCuErI
+9BBkt6TTlVKIvrlFBT1+GN7OjSKjOpBkUWopLFSGDJW5sKveZCw=
+=ovrd
+-----END PGP PUBLIC KEY BLOCK-----`
+
+// serveFakeAgent answers the commands of a client like gpg-agent would, with
+// the given keys indexed by keygrip.
+func serveFakeAgent(conn net.Conn, keys map[string]*rsa.PrivateKey) {
+	defer conn.Close()
+	agent := &Client{conn: conn, reader: bufio.NewReader(conn)}
+
+	var key *rsa.PrivateKey
+	var hash gocrypto.Hash
+	var digest []byte
+	_ = agent.writeLine("OK Pleased to meet you")
+	for {
+		line, err := agent.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, args := splitLine(strings.TrimSuffix(line, "\n"))
+
+		var data []byte
+		switch command {
+		case "HAVEKEY", "SIGKEY", "SETKEY":
+			key = keys[args]
+			if key == nil {
+				_ = agent.writeLine("ERR 67108881 No secret key <GPG Agent>")
+				continue
+			}
+		case "SETHASH":
+			algo, hexDigest := splitLine(args)
+			for h, id := range hashAlgos {
+				if fmt.Sprint(id) == algo {
+					hash = h
+				}
+			}
+			digest, _ = hex.DecodeString(hexDigest)
+		case "PKSIGN":
+			s, _ := rsa.SignPKCS1v15(nil, key, hash, digest)
+			data = []byte(fmt.Sprintf("(7:sig-val(3:rsa(1:s%d:%s)))", len(s), s))
+		case "PKDECRYPT":
+			_ = agent.writeLine("INQUIRE CIPHERTEXT")
+			var encVal string
+			for {
+				line, _ = agent.reader.ReadString('\n')
+				keyword, chunk := splitLine(strings.TrimSuffix(line, "\n"))
+				if keyword != "D" {
+					break
+				}
+				chunk, _ = unescape(chunk)
+				encVal += chunk
+			}
+			expr, _ := parseSexp([]byte(encVal))
+			c := new(big.Int).SetBytes(expr.find("a").value())
+			m := new(big.Int).Exp(c, key.D, key.N).Bytes()
+			data = []byte(fmt.Sprintf("(5:value%d:%s)\x00", len(m), m))
+		}
+		_ = agent.writeData(data)
+		_ = agent.writeLine("OK")
+	}
+}
+
+// newFakeAgentClient returns a client connected to a fake agent holding the
+// secret keys of the given parts of key.
+func newFakeAgentClient(t *testing.T, key *crypto.Key, primary, subkey bool) *Client {
+	entity := key.GetEntity()
+	keys := make(map[string]*rsa.PrivateKey)
+	if primary {
+		keygrip, err := Keygrip(entity.PrimaryKey)
+		assert.Nil(t, err)
+		keys[keygrip] = entity.PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	}
+	if subkey {
+		keygrip, err := Keygrip(entity.Subkeys[0].PublicKey)
+		assert.Nil(t, err)
+		keys[keygrip] = entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	}
+
+	clientConn, agentConn := net.Pipe()
+	go serveFakeAgent(agentConn, keys)
+	client, err := newClient(clientConn)
+	if err != nil {
+		t.Fatal("Cannot connect to agent:", err)
+	}
+	return client
+}
+
+func TestKeygrip(t *testing.T) {
+	key, err := crypto.NewKeyFromArmored(agentTestKey)
+	if err != nil {
+		t.Fatal("Cannot read key:", err)
+	}
+
+	keygrip, err := Keygrip(key.GetEntity().PrimaryKey)
+	assert.Nil(t, err)
+	assert.Exactly(t, "81B74FE8A628DD4229549916499117D4FE22897F", keygrip)
+
+	keygrip, err = Keygrip(key.GetEntity().Subkeys[0].PublicKey)
+	assert.Nil(t, err)
+	assert.Exactly(t, "51E876C08317ECC0A334E8C144E899297B6F2B9C", keygrip)
+
+	ecKey, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	_, err = Keygrip(ecKey.GetEntity().PrimaryKey)
+	assert.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+}
+
+func TestNewKeyUnsupportedAlgorithm(t *testing.T) {
+	ecKey, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	client := newFakeAgentClient(t, ecKey, false, false)
+	defer client.Close()
+
+	_, err = client.NewKey(ecKey)
+	assert.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+}
+
+func TestSignAndDecrypt(t *testing.T) {
+	privateKey, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "rsa", 1024)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	publicKey, err := privateKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot extract public key:", err)
+	}
+	publicKeyRing, err := crypto.NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	client := newFakeAgentClient(t, privateKey, true, true)
+	defer client.Close()
+
+	agentKey, err := client.NewKey(publicKey)
+	if err != nil {
+		t.Fatal("Cannot delegate key:", err)
+	}
+	assert.True(t, agentKey.IsPrivate())
+	agentKeyRing, err := crypto.NewKeyRing(agentKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	message := crypto.NewPlainMessageFromString("Signed % by\r\nthe agent")
+	signature, err := agentKeyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Cannot sign with agent:", err)
+	}
+	assert.Nil(t, publicKeyRing.VerifyDetached(message, signature, crypto.GetUnixTime()))
+
+	ciphertext, err := publicKeyRing.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	decrypted, err := agentKeyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot decrypt with agent:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+}
+
+func TestNewKeyWithSubkeysOnly(t *testing.T) {
+	privateKey, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "rsa", 1024)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+
+	client := newFakeAgentClient(t, privateKey, false, false)
+	_, err = client.NewKey(privateKey)
+	assert.NotNil(t, err)
+	_ = client.Close()
+
+	client = newFakeAgentClient(t, privateKey, false, true)
+	defer client.Close()
+
+	agentKey, err := client.NewKey(privateKey)
+	if err != nil {
+		t.Fatal("Cannot delegate key:", err)
+	}
+	agentKeyRing, err := crypto.NewKeyRing(agentKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	// The generated keys sign with their primary key, not held by the agent
+	_, err = agentKeyRing.SignDetached(crypto.NewPlainMessageFromString("message"))
+	assert.NotNil(t, err)
+
+	publicKeyRing, err := crypto.NewKeyRing(privateKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	ciphertext, err := publicKeyRing.Encrypt(crypto.NewPlainMessageFromString("message"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	decrypted, err := agentKeyRing.Decrypt(ciphertext, nil, 0)
+	if err != nil {
+		t.Fatal("Cannot decrypt with agent:", err)
+	}
+	assert.Exactly(t, "message", decrypted.GetString())
+}
+
+func TestAssuanEncoding(t *testing.T) {
+	decoded, err := unescape("100%25%0D%0Adone")
+	assert.Nil(t, err)
+	assert.Exactly(t, "100%\r\ndone", decoded)
+
+	_, err = unescape("100%2")
+	assert.NotNil(t, err)
+
+	expr, err := parseSexp([]byte("(7:sig-val(3:rsa(1:s3:a()))(5:flags))\x00"))
+	assert.Nil(t, err)
+	assert.Exactly(t, []byte("a()"), expr.find("s").value())
+	assert.Nil(t, expr.find("flags").value())
+	assert.Nil(t, expr.find("r"))
+
+	_, err = parseSexp([]byte("(1:s9:abc)"))
+	assert.NotNil(t, err)
+}
@@ -32,7 +32,7 @@ func SignCleartextMessageArmored(privateKey string, passphrase []byte, text stri
 // text given the public key and returns the text or err if the verification
 // fails.
 func VerifyCleartextMessageArmored(publicKey, armored string, verifyTime int64) (string, error) {
-	signingKey, err := crypto.NewKeyFromArmored(publicKey)
+	signingKey, err := parsePublicKey(publicKey)
 	if err != nil {
 		return "", errors.Wrap(err, "gopenpgp: error in creating key object")
 	}
@@ -48,14 +48,12 @@ func VerifyCleartextMessageArmored(publicKey, armored string, verifyTime int64)
 // SignCleartextMessage signs text given a private keyring, canonicalizes and
 // trims the newlines, and returns the PGP-compliant special armoring.
 func SignCleartextMessage(keyRing *crypto.KeyRing, text string) (string, error) {
-	message := crypto.NewPlainMessageFromString(text)
-
-	signature, err := keyRing.SignDetached(message)
+	message, err := keyRing.SignCleartext(text, crypto.NewCleartextOptions())
 	if err != nil {
-		return "", errors.Wrap(err, "gopenpgp: error in signing cleartext message")
+		return "", err
 	}
 
-	return crypto.NewClearTextMessage(message.GetBinary(), signature.GetBinary()).GetArmored()
+	return message.GetArmored()
 }
 
 // VerifyCleartextMessage verifies PGP-compliant armored signed plain text
@@ -67,12 +65,10 @@ func VerifyCleartextMessage(keyRing *crypto.KeyRing, armored string, verifyTime
 		return "", errors.Wrap(err, "gopengpp: unable to unarmor cleartext message")
 	}
 
-	message := crypto.NewPlainMessageFromString(clearTextMessage.GetString())
-	signature := crypto.NewPGPSignature(clearTextMessage.GetBinarySignature())
-	err = keyRing.VerifyDetached(message, signature, verifyTime)
+	err = keyRing.VerifyCleartext(clearTextMessage, crypto.NewCleartextOptions(), verifyTime)
 	if err != nil {
 		return "", errors.Wrap(err, "gopengpp: unable to verify cleartext message")
 	}
 
-	return message.GetString(), nil
+	return crypto.NewPlainMessageFromString(clearTextMessage.GetString()).GetString(), nil
 }
@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// This file contains compatibility shims that preserve the signatures of
+// older APIs on top of the current result types, so that integrators can
+// migrate incrementally.
+
+// GetVerification returns the signature verification status of the message,
+// one of the constants.SIGNATURE_* values.
+func (msg *ExplicitVerifyMessage) GetVerification() int {
+	if msg.SignatureVerificationError == nil {
+		return constants.SIGNATURE_OK
+	}
+	return msg.SignatureVerificationError.Status
+}
+
+// GetMessage returns the decrypted message.
+func (msg *ExplicitVerifyMessage) GetMessage() *crypto.PlainMessage {
+	return msg.Message
+}
+
+// GetVerificationStatus returns the signature verification status of an error
+// returned by a verifying function, one of the constants.SIGNATURE_* values.
+func GetVerificationStatus(err error) int {
+	return crypto.GetVerificationStatus(err)
+}
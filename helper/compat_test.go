@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompatGetVerification(t *testing.T) {
+	privateKey, _ := crypto.NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	privateKey, err := privateKey.Unlock(testMailboxPassword)
+	if err != nil {
+		t.Fatal("Expected no error unlocking privateKey, got:", err)
+	}
+	testPrivateKeyRing, _ := crypto.NewKeyRing(privateKey)
+
+	publicKey, _ := crypto.NewKeyFromArmored(readTestFile("mime_publicKey", false))
+	testPublicKeyRing, _ := crypto.NewKeyRing(publicKey)
+
+	pgpMessage, err := crypto.NewPGPMessageFromArmored(readTestFile("message_signed", false))
+	if err != nil {
+		t.Fatal("Expected no error when unarmoring, got:", err)
+	}
+
+	decrypted, err := DecryptExplicitVerify(pgpMessage, testPrivateKeyRing, testPublicKeyRing, crypto.GetUnixTime())
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, decrypted.GetVerification())
+	assert.Exactly(t, readTestFile("message_plaintext", true), decrypted.GetMessage().GetString())
+
+	_, err = testPrivateKeyRing.Decrypt(pgpMessage, testPublicKeyRing, crypto.GetUnixTime())
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, GetVerificationStatus(err))
+
+	_, err = testPrivateKeyRing.Decrypt(pgpMessage, nil, 0)
+	assert.Exactly(t, constants.SIGNATURE_OK, GetVerificationStatus(err))
+
+	assert.Exactly(t, constants.SIGNATURE_FAILED, GetVerificationStatus(errors.New("other error")))
+}
@@ -0,0 +1,93 @@
+package helper
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/pkg/errors"
+)
+
+// ExpiringMessage contains a message encrypted to an ephemeral key, and the
+// ephemeral private key encrypted to the recipient. The message can't be
+// decrypted with DecryptExpiringMessage once the ephemeral key has expired.
+type ExpiringMessage struct {
+	Message      *crypto.PGPMessage
+	EphemeralKey *crypto.PGPMessage
+}
+
+// EncryptExpiringMessage encrypts a message to a freshly generated ephemeral
+// key that expires lifetimeSecs seconds from now, and encrypts the ephemeral
+// private key to the recipient keyring.
+func EncryptExpiringMessage(
+	message *crypto.PlainMessage,
+	recipientKeyRing *crypto.KeyRing,
+	lifetimeSecs int64,
+) (*ExpiringMessage, error) {
+	if lifetimeSecs <= 0 {
+		return nil, errors.New("gopenpgp: expiring messages need a positive lifetime")
+	}
+
+	ephemeralKey, err := crypto.GenerateKeyWithExpiration("Ephemeral key", "", "x25519", 0, lifetimeSecs)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to generate ephemeral key")
+	}
+	defer ephemeralKey.ClearPrivateParams()
+
+	ephemeralKeyRing, err := crypto.NewKeyRing(ephemeralKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create new keyring")
+	}
+
+	pgpMessage, err := ephemeralKeyRing.Encrypt(message, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to encrypt message")
+	}
+
+	serializedKey, err := ephemeralKey.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to serialize ephemeral key")
+	}
+
+	encryptedKey, err := recipientKeyRing.Encrypt(crypto.NewPlainMessage(serializedKey), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to encrypt ephemeral key")
+	}
+
+	return &ExpiringMessage{
+		Message:      pgpMessage,
+		EphemeralKey: encryptedKey,
+	}, nil
+}
+
+// DecryptExpiringMessage decrypts the ephemeral key of an expiring message
+// with the recipient private keyring, and uses it to decrypt the message.
+// Returns an error if the ephemeral key has expired.
+func DecryptExpiringMessage(
+	expiringMessage *ExpiringMessage,
+	privateKeyRing *crypto.KeyRing,
+) (*crypto.PlainMessage, error) {
+	serializedKey, err := privateKeyRing.Decrypt(expiringMessage.EphemeralKey, nil, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt ephemeral key")
+	}
+
+	ephemeralKey, err := crypto.NewKey(serializedKey.GetBinary())
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read ephemeral key")
+	}
+	defer ephemeralKey.ClearPrivateParams()
+
+	if ephemeralKey.IsExpired() {
+		return nil, errors.New("gopenpgp: message has expired")
+	}
+
+	ephemeralKeyRing, err := crypto.NewKeyRing(ephemeralKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create new keyring")
+	}
+
+	message, err := ephemeralKeyRing.Decrypt(expiringMessage.Message, nil, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt message")
+	}
+
+	return message, nil
+}
@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiringMessage(t *testing.T) {
+	privateKey, _ := crypto.NewKeyFromArmored(readTestFile("keyring_privateKey", false))
+	privateKey, err := privateKey.Unlock(testMailboxPassword)
+	if err != nil {
+		t.Fatal("Expected no error unlocking privateKey, got:", err)
+	}
+	privateKeyRing, _ := crypto.NewKeyRing(privateKey)
+
+	publicKey, _ := crypto.NewKeyFromArmored(readTestFile("keyring_publicKey", false))
+	publicKeyRing, _ := crypto.NewKeyRing(publicKey)
+
+	message := crypto.NewPlainMessageFromString("This message will self-destruct")
+
+	expiringMessage, err := EncryptExpiringMessage(message, publicKeyRing, 3600)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	decrypted, err := DecryptExpiringMessage(expiringMessage, privateKeyRing)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+
+	_, err = EncryptExpiringMessage(message, publicKeyRing, 0)
+	assert.NotNil(t, err)
+
+	// Generate an ephemeral key that expired an hour ago
+	crypto.SetKeyGenerationOffset(-7200)
+	expiredKey, err := crypto.GenerateKeyWithExpiration("Ephemeral key", "", "x25519", 0, 3600)
+	crypto.SetKeyGenerationOffset(0)
+	if err != nil {
+		t.Fatal("Expected no error when generating key, got:", err)
+	}
+	serializedKey, err := expiredKey.Serialize()
+	if err != nil {
+		t.Fatal("Expected no error when serializing key, got:", err)
+	}
+	expiringMessage.EphemeralKey, err = publicKeyRing.Encrypt(crypto.NewPlainMessage(serializedKey), nil)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	_, err = DecryptExpiringMessage(expiringMessage, privateKeyRing)
+	assert.EqualError(t, err, "gopenpgp: message has expired")
+}
@@ -51,38 +51,16 @@ func EncryptMessageArmored(key, plaintext string) (string, error) {
 func EncryptSignMessageArmored(
 	publicKey, privateKey string, passphrase []byte, plaintext string,
 ) (ciphertext string, err error) {
-	var privateKeyObj, unlockedKeyObj *crypto.Key
-	var publicKeyRing, privateKeyRing *crypto.KeyRing
-	var pgpMessage *crypto.PGPMessage
-
-	var message = crypto.NewPlainMessageFromString(plaintext)
-
-	if publicKeyRing, err = createPublicKeyRing(publicKey); err != nil {
-		return "", err
-	}
-
-	if privateKeyObj, err = crypto.NewKeyFromArmored(privateKey); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to read key")
-	}
-
-	if unlockedKeyObj, err = privateKeyObj.Unlock(passphrase); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to unlock key")
-	}
-	defer unlockedKeyObj.ClearPrivateParams()
-
-	if privateKeyRing, err = crypto.NewKeyRing(unlockedKeyObj); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to create new keyring")
-	}
-
-	if pgpMessage, err = publicKeyRing.Encrypt(message, privateKeyRing); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to encrypt message")
-	}
-
-	if ciphertext, err = pgpMessage.GetArmored(); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to armor ciphertext")
-	}
+	return encryptSignMessageArmored(publicKey, privateKey, passphrase, crypto.NewPlainMessageFromString(plaintext))
+}
 
-	return ciphertext, nil
+// EncryptSignBinaryMessageArmored generates an armored signed PGP message
+// given binary data and an armored public key a private key and its
+// passphrase.
+func EncryptSignBinaryMessageArmored(
+	publicKey, privateKey string, passphrase, data []byte,
+) (ciphertext string, err error) {
+	return encryptSignMessageArmored(publicKey, privateKey, passphrase, crypto.NewPlainMessage(data))
 }
 
 // DecryptMessageArmored decrypts an armored PGP message given a private key
@@ -104,37 +82,24 @@ func DecryptMessageArmored(
 func DecryptVerifyMessageArmored(
 	publicKey, privateKey string, passphrase []byte, ciphertext string,
 ) (plaintext string, err error) {
-	var privateKeyObj, unlockedKeyObj *crypto.Key
-	var publicKeyRing, privateKeyRing *crypto.KeyRing
-	var pgpMessage *crypto.PGPMessage
-	var message *crypto.PlainMessage
-
-	if publicKeyRing, err = createPublicKeyRing(publicKey); err != nil {
+	message, err := decryptVerifyMessageArmored(publicKey, privateKey, passphrase, ciphertext)
+	if err != nil {
 		return "", err
 	}
+	return message.GetString(), nil
+}
 
-	if privateKeyObj, err = crypto.NewKeyFromArmored(privateKey); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to unarmor private key")
-	}
-
-	if unlockedKeyObj, err = privateKeyObj.Unlock(passphrase); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to unlock private key")
-	}
-	defer unlockedKeyObj.ClearPrivateParams()
-
-	if privateKeyRing, err = crypto.NewKeyRing(unlockedKeyObj); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to create new keyring")
-	}
-
-	if pgpMessage, err = crypto.NewPGPMessageFromArmored(ciphertext); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to unarmor ciphertext")
-	}
-
-	if message, err = privateKeyRing.Decrypt(pgpMessage, publicKeyRing, crypto.GetUnixTime()); err != nil {
-		return "", errors.Wrap(err, "gopenpgp: unable to decrypt message")
+// DecryptVerifyBinaryMessageArmored decrypts an armored PGP message given a
+// private key and its passphrase and verifies the embedded signature.
+// Returns the binary data or an error on signature verification failure.
+func DecryptVerifyBinaryMessageArmored(
+	publicKey, privateKey string, passphrase []byte, ciphertext string,
+) (data []byte, err error) {
+	message, err := decryptVerifyMessageArmored(publicKey, privateKey, passphrase, ciphertext)
+	if err != nil {
+		return nil, err
 	}
-
-	return message.GetString(), nil
+	return message.GetBinary(), nil
 }
 
 // DecryptVerifyAttachment decrypts and verifies an attachment split into the
@@ -352,6 +317,22 @@ func DecryptSessionKey(
 	return sessionKey, nil
 }
 
+// SignDetachedArmored signs data given a private key and its passphrase, and
+// returns an armored detached signature.
+func SignDetachedArmored(privateKey string, passphrase, data []byte) (armoredSignature string, err error) {
+	signature, err := signDetached(privateKey, passphrase, crypto.NewPlainMessage(data))
+	if err != nil {
+		return "", err
+	}
+	return signature.GetArmored()
+}
+
+// VerifyDetachedArmored verifies an armored detached signature of data given
+// a public key. Returns false if the signature is invalid.
+func VerifyDetachedArmored(publicKey string, data []byte, armoredSignature string) (bool, error) {
+	return verifyDetachedArmored(publicKey, crypto.NewPlainMessage(data), armoredSignature)
+}
+
 func encryptMessageArmored(key string, message *crypto.PlainMessage) (string, error) {
 	ciphertext, err := encryptMessage(key, message)
 	if err != nil {
@@ -366,6 +347,76 @@ func encryptMessageArmored(key string, message *crypto.PlainMessage) (string, er
 	return ciphertextArmored, nil
 }
 
+func encryptSignMessageArmored(
+	publicKey, privateKey string, passphrase []byte, message *crypto.PlainMessage,
+) (ciphertext string, err error) {
+	var privateKeyObj, unlockedKeyObj *crypto.Key
+	var publicKeyRing, privateKeyRing *crypto.KeyRing
+	var pgpMessage *crypto.PGPMessage
+
+	if publicKeyRing, err = createPublicKeyRing(publicKey); err != nil {
+		return "", err
+	}
+
+	if privateKeyObj, err = crypto.NewKeyFromArmored(privateKey); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to read key")
+	}
+
+	if unlockedKeyObj, err = privateKeyObj.Unlock(passphrase); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to unlock key")
+	}
+	defer unlockedKeyObj.ClearPrivateParams()
+
+	if privateKeyRing, err = crypto.NewKeyRing(unlockedKeyObj); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to create new keyring")
+	}
+
+	if pgpMessage, err = publicKeyRing.Encrypt(message, privateKeyRing); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to encrypt message")
+	}
+
+	if ciphertext, err = pgpMessage.GetArmored(); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to armor ciphertext")
+	}
+
+	return ciphertext, nil
+}
+
+func decryptVerifyMessageArmored(
+	publicKey, privateKey string, passphrase []byte, ciphertext string,
+) (message *crypto.PlainMessage, err error) {
+	var privateKeyObj, unlockedKeyObj *crypto.Key
+	var publicKeyRing, privateKeyRing *crypto.KeyRing
+	var pgpMessage *crypto.PGPMessage
+
+	if publicKeyRing, err = createPublicKeyRing(publicKey); err != nil {
+		return nil, err
+	}
+
+	if privateKeyObj, err = crypto.NewKeyFromArmored(privateKey); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to unarmor private key")
+	}
+
+	if unlockedKeyObj, err = privateKeyObj.Unlock(passphrase); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to unlock private key")
+	}
+	defer unlockedKeyObj.ClearPrivateParams()
+
+	if privateKeyRing, err = crypto.NewKeyRing(unlockedKeyObj); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create new keyring")
+	}
+
+	if pgpMessage, err = crypto.NewPGPMessageFromArmored(ciphertext); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to unarmor ciphertext")
+	}
+
+	if message, err = privateKeyRing.Decrypt(pgpMessage, publicKeyRing, crypto.GetUnixTime()); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt message")
+	}
+
+	return message, nil
+}
+
 func decryptMessageArmored(privateKey string, passphrase []byte, ciphertextArmored string) (*crypto.PlainMessage, error) {
 	ciphertext, err := crypto.NewPGPMessageFromArmored(ciphertextArmored)
 	if err != nil {
@@ -498,7 +549,7 @@ func decryptAttachment(
 }
 
 func createPublicKeyRing(publicKey string) (*crypto.KeyRing, error) {
-	publicKeyObj, err := crypto.NewKeyFromArmored(publicKey)
+	publicKeyObj, err := parsePublicKey(publicKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: unable to parse public key")
 	}
@@ -156,6 +156,53 @@ func TestArmoredBinaryMessageEncryption(t *testing.T) {
 	assert.Exactly(t, plainData, decrypted)
 }
 
+func TestArmoredBinaryMessageEncryptionVerification(t *testing.T) {
+	plainData := []byte("Signed secret message")
+
+	armored, err := EncryptSignBinaryMessageArmored(
+		readTestFile("keyring_publicKey", false),
+		readTestFile("keyring_privateKey", false),
+		testMailboxPassword, // Password defined in base_test
+		plainData,
+	)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+
+	decrypted, err := DecryptVerifyBinaryMessageArmored(
+		readTestFile("keyring_publicKey", false),
+		readTestFile("keyring_privateKey", false),
+		testMailboxPassword, // Password defined in base_test
+		armored,
+	)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+
+	assert.Exactly(t, plainData, decrypted)
+}
+
+func TestSignVerifyDetachedArmored(t *testing.T) {
+	plainData := []byte("Signed data")
+
+	armoredSignature, err := SignDetachedArmored(
+		readTestFile("keyring_privateKey", false),
+		testMailboxPassword, // Password defined in base_test
+		plainData,
+	)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+
+	check, err := VerifyDetachedArmored(readTestFile("keyring_publicKey", false), plainData, armoredSignature)
+	assert.Nil(t, err)
+	assert.True(t, check)
+
+	check, err = VerifyDetachedArmored(readTestFile("keyring_publicKey", false), []byte("Other data"), armoredSignature)
+	assert.Nil(t, err)
+	assert.False(t, check)
+}
+
 func TestEncryptSignArmoredDetached(t *testing.T) {
 	plainData := []byte("Secret message")
 	privateKeyString := readTestFile("keyring_privateKey", false)
@@ -0,0 +1,170 @@
+package helper
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/pkg/errors"
+)
+
+// Types of the armored blocks found by FindInlineBlocks.
+const (
+	InlineMessage       = "PGP MESSAGE"
+	InlineSignedMessage = "PGP SIGNED MESSAGE"
+	InlineSignature     = "PGP SIGNATURE"
+	InlinePublicKey     = "PGP PUBLIC KEY BLOCK"
+	InlinePrivateKey    = "PGP PRIVATE KEY BLOCK"
+)
+
+var inlineBeginRegexp = regexp.MustCompile(
+	`(?m)^-----BEGIN (PGP MESSAGE|PGP SIGNED MESSAGE|PGP SIGNATURE|PGP PUBLIC KEY BLOCK|PGP PRIVATE KEY BLOCK)-----[ \t]*\r?$`,
+)
+
+// InlineBlock is an armored OpenPGP block found in a text body, e.g. in an
+// inline-PGP email.
+type InlineBlock struct {
+	// Type is one of the Inline* constants.
+	Type string
+	// Start and End are the offsets of the block in the body.
+	Start, End int
+	Armored    string
+}
+
+// FindInlineBlocks returns the armored blocks of body, in order. Blocks must
+// start at the beginning of a line, so that quoted blocks are ignored.
+func FindInlineBlocks(body string) []*InlineBlock {
+	var blocks []*InlineBlock
+	offset := 0
+	for {
+		match := inlineBeginRegexp.FindStringSubmatchIndex(body[offset:])
+		if match == nil {
+			return blocks
+		}
+		blockType := body[offset+match[2] : offset+match[3]]
+		start := offset + match[0]
+
+		endType := blockType
+		if blockType == InlineSignedMessage {
+			endType = InlineSignature
+		}
+		endMarker := "-----END " + endType + "-----"
+		endIndex := strings.Index(body[start:], endMarker)
+		if endIndex < 0 {
+			return blocks
+		}
+		end := start + endIndex + len(endMarker)
+
+		blocks = append(blocks, &InlineBlock{
+			Type:    blockType,
+			Start:   start,
+			End:     end,
+			Armored: strings.ReplaceAll(body[start:end], "\r\n", "\n"),
+		})
+		offset = end
+	}
+}
+
+// DecryptVerifyInline decrypts the PGP MESSAGE blocks and verifies the PGP
+// SIGNED MESSAGE blocks of an inline-PGP body with the given keyrings, and
+// returns the body with the blocks replaced by their text. It also returns
+// the verification status of each replaced block, one of the
+// constants.SIGNATURE_* values, in order.
+// If verifyKey is nil, the status of each block is SIGNATURE_NO_VERIFIER.
+func DecryptVerifyInline(
+	keyRing, verifyKey *crypto.KeyRing, body string, verifyTime int64,
+) (text string, statuses []int, err error) {
+	var builder strings.Builder
+	offset := 0
+	for _, block := range FindInlineBlocks(body) {
+		var blockText string
+		var status int
+		switch block.Type {
+		case InlineMessage:
+			blockText, status, err = decryptInlineBlock(keyRing, verifyKey, block, verifyTime)
+		case InlineSignedMessage:
+			blockText, status, err = verifyInlineBlock(verifyKey, block, verifyTime)
+		default:
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		builder.WriteString(body[offset:block.Start])
+		builder.WriteString(blockText)
+		statuses = append(statuses, status)
+		offset = block.End
+	}
+	builder.WriteString(body[offset:])
+	return builder.String(), statuses, nil
+}
+
+// EncryptSignInline encrypts text to encryptionKeyRing, signing it with
+// signKeyRing if not nil, and returns an inline-PGP body.
+func EncryptSignInline(encryptionKeyRing, signKeyRing *crypto.KeyRing, text string) (string, error) {
+	message := crypto.NewPlainMessageFromString(text)
+	pgpMessage, err := encryptionKeyRing.Encrypt(message, signKeyRing)
+	if err != nil {
+		return "", errors.Wrap(err, "gopenpgp: unable to encrypt inline message")
+	}
+	return pgpMessage.GetArmored()
+}
+
+// SignInline signs text with keyRing and returns an inline-PGP body, with a
+// cleartext signature.
+func SignInline(keyRing *crypto.KeyRing, text string) (string, error) {
+	return SignCleartextMessage(keyRing, text)
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func decryptInlineBlock(
+	keyRing, verifyKey *crypto.KeyRing, block *InlineBlock, verifyTime int64,
+) (string, int, error) {
+	pgpMessage, err := crypto.NewPGPMessageFromArmored(block.Armored)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "gopenpgp: unable to unarmor inline message")
+	}
+	message, err := keyRing.Decrypt(pgpMessage, verifyKey, verifyTime)
+	status, err := inlineSignatureStatus(verifyKey, err)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "gopenpgp: unable to decrypt inline message")
+	}
+	return message.GetString(), status, nil
+}
+
+func verifyInlineBlock(verifyKey *crypto.KeyRing, block *InlineBlock, verifyTime int64) (string, int, error) {
+	clearTextMessage, err := crypto.NewClearTextMessageFromArmored(block.Armored)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "gopenpgp: unable to unarmor inline signed message")
+	}
+	if verifyKey == nil {
+		return clearTextMessage.GetString(), constants.SIGNATURE_NO_VERIFIER, nil
+	}
+	message := crypto.NewPlainMessageFromString(clearTextMessage.GetString())
+	signature := crypto.NewPGPSignature(clearTextMessage.GetBinarySignature())
+	err = verifyKey.VerifyDetached(message, signature, verifyTime)
+	status, err := inlineSignatureStatus(verifyKey, err)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "gopenpgp: unable to verify inline signed message")
+	}
+	return clearTextMessage.GetString(), status, nil
+}
+
+// inlineSignatureStatus separates signature verification errors, returned as
+// a status, from other errors.
+func inlineSignatureStatus(verifyKey *crypto.KeyRing, err error) (int, error) {
+	var sigErr crypto.SignatureVerificationError
+	switch {
+	case errors.As(err, &sigErr):
+		return sigErr.Status, nil
+	case err != nil:
+		return 0, err
+	case verifyKey == nil:
+		return constants.SIGNATURE_NO_VERIFIER, nil
+	default:
+		return constants.SIGNATURE_OK, nil
+	}
+}
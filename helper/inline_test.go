@@ -0,0 +1,57 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInline(t *testing.T) {
+	key, err := crypto.GenerateKey("Inline", "inline@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	encrypted, err := EncryptSignInline(keyRing, keyRing, "Secret text")
+	if err != nil {
+		t.Fatal("Cannot encrypt inline message:", err)
+	}
+	signed, err := SignInline(keyRing, "Signed text")
+	if err != nil {
+		t.Fatal("Cannot sign inline message:", err)
+	}
+
+	body := "Hi,\r\n\r\n" + strings.ReplaceAll(encrypted, "\n", "\r\n") + "\r\n\r\n" +
+		signed + "\n> -----BEGIN PGP MESSAGE-----\n> quoted\n"
+
+	blocks := FindInlineBlocks(body)
+	if assert.Len(t, blocks, 2) {
+		assert.Exactly(t, InlineMessage, blocks[0].Type)
+		assert.Exactly(t, encrypted, blocks[0].Armored)
+		assert.Exactly(t, InlineSignedMessage, blocks[1].Type)
+		assert.Exactly(t, signed, body[blocks[1].Start:blocks[1].End])
+	}
+
+	text, statuses, err := DecryptVerifyInline(keyRing, keyRing, body, crypto.GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot decrypt inline message:", err)
+	}
+	assert.Exactly(t, "Hi,\r\n\r\nSecret text\r\n\r\nSigned text\n> -----BEGIN PGP MESSAGE-----\n> quoted\n", text)
+	assert.Exactly(t, []int{constants.SIGNATURE_OK, constants.SIGNATURE_OK}, statuses)
+
+	tampered := strings.Replace(body, "Signed text", "Other text", 1)
+	_, statuses, err = DecryptVerifyInline(keyRing, nil, tampered, crypto.GetUnixTime())
+	assert.Nil(t, err)
+	assert.Exactly(t, []int{constants.SIGNATURE_NO_VERIFIER, constants.SIGNATURE_NO_VERIFIER}, statuses)
+
+	_, statuses, err = DecryptVerifyInline(keyRing, keyRing, tampered, crypto.GetUnixTime())
+	assert.Nil(t, err)
+	assert.Exactly(t, []int{constants.SIGNATURE_OK, constants.SIGNATURE_FAILED}, statuses)
+}
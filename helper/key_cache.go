@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+var publicKeyCache struct {
+	sync.RWMutex
+	cache *crypto.PublicKeyCache
+}
+
+// SetPublicKeyCache makes the helper functions taking armored public keys get
+// the parsed keys from cache, so that repeated calls with the same keys parse
+// them only once. A nil cache restores parsing the keys on every call.
+func SetPublicKeyCache(cache *crypto.PublicKeyCache) {
+	publicKeyCache.Lock()
+	defer publicKeyCache.Unlock()
+
+	publicKeyCache.cache = cache
+}
+
+// parsePublicKey parses an armored public key, or returns it from the public
+// key cache, if set. The key may be private without a cache.
+func parsePublicKey(publicKey string) (*crypto.Key, error) {
+	publicKeyCache.RLock()
+	cache := publicKeyCache.cache
+	publicKeyCache.RUnlock()
+
+	if cache != nil {
+		return cache.NewKeyFromArmored(publicKey)
+	}
+	return crypto.NewKeyFromArmored(publicKey)
+}
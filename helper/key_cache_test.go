@@ -0,0 +1,33 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicKeyCache(t *testing.T) {
+	cache := crypto.NewPublicKeyCache(0)
+	SetPublicKeyCache(cache)
+	defer SetPublicKeyCache(nil)
+
+	publicKey := readTestFile("keyring_publicKey", false)
+	for i := 0; i < 2; i++ {
+		armored, err := EncryptMessageArmored(publicKey, "cached key")
+		if err != nil {
+			t.Fatal("Expected no error when encrypting, got:", err)
+		}
+		decrypted, err := DecryptMessageArmored(readTestFile("keyring_privateKey", false), testMailboxPassword, armored)
+		if err != nil {
+			t.Fatal("Expected no error when decrypting, got:", err)
+		}
+		assert.Exactly(t, "cached key", decrypted)
+	}
+	assert.Exactly(t, 1, cache.Len())
+
+	// Private keys given as public keys are only cached as public keys
+	_, err := EncryptMessageArmored(readTestFile("keyring_privateKey", false), "cached key")
+	assert.Nil(t, err)
+	assert.Exactly(t, 2, cache.Len())
+}
@@ -0,0 +1,283 @@
+// Package hkp looks up and submits public keys on keyservers with the
+// HTTP Keyserver Protocol (HKP), as supported by keys.openpgp.org and the
+// SKS-compatible keyservers.
+package hkp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// DefaultServer is the keyserver used by NewDefaultClient.
+const DefaultServer = "hkps://keys.openpgp.org"
+
+// maxResponseSize limits the size of the keys read from a keyserver.
+const maxResponseSize = 1 << 20
+
+// ErrNotFound is returned when the keyserver has no matching key.
+var ErrNotFound = errors.New("gopenpgp: no key found on the keyserver")
+
+// RateLimitError is returned when the keyserver refuses a request because
+// too many were sent.
+type RateLimitError struct {
+	// Time to wait before sending another request, if sent by the server
+	RetryAfter time.Duration
+}
+
+// Error is the base method for all errors.
+func (e RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("gopenpgp: keyserver rate limit exceeded, retry after %v", e.RetryAfter)
+	}
+	return "gopenpgp: keyserver rate limit exceeded"
+}
+
+// ServerError is returned when the keyserver answers with an unexpected
+// status code.
+type ServerError struct {
+	StatusCode int
+	Status     string
+}
+
+// Error is the base method for all errors.
+func (e ServerError) Error() string {
+	return "gopenpgp: unexpected keyserver response: " + e.Status
+}
+
+// Client sends requests to a keyserver.
+type Client struct {
+	serverURL  *url.URL
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the keyserver at serverURL, with the hkp,
+// hkps, http or https scheme, using http.DefaultClient.
+func NewClient(serverURL string) (*Client, error) {
+	return NewClientWithHTTPClient(serverURL, http.DefaultClient)
+}
+
+// NewDefaultClient returns a client for DefaultServer.
+func NewDefaultClient() *Client {
+	client, _ := NewClient(DefaultServer)
+	return client
+}
+
+// NewClientWithProxy returns a client for the keyserver at serverURL, sending
+// its requests through the HTTP or SOCKS5 proxy at proxyURL.
+func NewClientWithProxy(serverURL, proxyURL string) (*Client, error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid proxy URL")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	return NewClientWithHTTPClient(serverURL, &http.Client{Transport: transport})
+}
+
+// NewClientWithHTTPClient returns a client for the keyserver at serverURL,
+// sending its requests with httpClient.
+func NewClientWithHTTPClient(serverURL string, httpClient *http.Client) (*Client, error) {
+	parsedURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid keyserver URL")
+	}
+
+	switch parsedURL.Scheme {
+	case "hkps":
+		parsedURL.Scheme = "https"
+	case "hkp":
+		parsedURL.Scheme = "http"
+		if parsedURL.Port() == "" {
+			parsedURL.Host += ":11371"
+		}
+	case "http", "https":
+	default:
+		return nil, errors.New("gopenpgp: unsupported keyserver URL scheme: " + parsedURL.Scheme)
+	}
+	if parsedURL.Host == "" {
+		return nil, errors.New("gopenpgp: invalid keyserver URL")
+	}
+	parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/")
+
+	return &Client{serverURL: parsedURL, httpClient: httpClient}, nil
+}
+
+// GetByFingerprint returns the key with the given hex fingerprint,
+// or ErrNotFound.
+func (client *Client) GetByFingerprint(ctx context.Context, fingerprint string) (*crypto.Key, error) {
+	fingerprint = strings.ToLower(strings.TrimPrefix(fingerprint, "0x"))
+	if _, err := hex.DecodeString(fingerprint); err != nil || (len(fingerprint) != 40 && len(fingerprint) != 64) {
+		return nil, errors.New("gopenpgp: invalid fingerprint")
+	}
+
+	keyRing, err := client.lookup(ctx, "0x"+fingerprint, func(key *crypto.Key) bool {
+		return key.GetFingerprint() == fingerprint
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyRing.GetKey(0)
+}
+
+// GetByEmail returns the keys with a user ID matching the email address,
+// or ErrNotFound. Some keyservers only return keys whose address was verified.
+func (client *Client) GetByEmail(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	if !strings.Contains(email, "@") {
+		return nil, errors.New("gopenpgp: invalid email address")
+	}
+
+	return client.lookup(ctx, email, func(key *crypto.Key) bool {
+		return hasEmail(key, email)
+	})
+}
+
+// Submit uploads the public part of the key to the keyserver.
+// keys.openpgp.org only publishes the user IDs whose address was verified,
+// by sending a confirmation email.
+func (client *Client) Submit(ctx context.Context, key *crypto.Key) error {
+	armored, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"keytext": {armored}}
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, client.endpoint("/pks/add", nil), strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to create request")
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err = client.do(request)
+	return err
+}
+
+// --- Internal functions
+
+// lookup returns the keys matching the search, filtered with keep.
+func (client *Client) lookup(
+	ctx context.Context, search string, keep func(*crypto.Key) bool,
+) (*crypto.KeyRing, error) {
+	query := url.Values{"op": {"get"}, "options": {"mr"}, "search": {search}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.endpoint("/pks/lookup", query), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create request")
+	}
+
+	data, err := client.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read key")
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		key, err := crypto.NewKeyFromEntity(entity)
+		if err != nil {
+			return nil, err
+		}
+		if !keep(key) {
+			continue
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if keyRing.CountEntities() == 0 {
+		return nil, ErrNotFound
+	}
+	return keyRing, nil
+}
+
+// do sends the request and returns the body of the response, or a typed
+// error for the failure status codes.
+func (client *Client) do(request *http.Request) ([]byte, error) {
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to reach keyserver")
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusTooManyRequests:
+		return nil, RateLimitError{RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"))}
+	default:
+		return nil, ServerError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read keyserver response")
+	}
+	if len(data) > maxResponseSize {
+		return nil, errors.New("gopenpgp: keyserver response is too large")
+	}
+	return data, nil
+}
+
+// endpoint returns the URL of the keyserver path, with the query.
+func (client *Client) endpoint(path string, query url.Values) string {
+	endpointURL := *client.serverURL
+	endpointURL.Path += path
+	endpointURL.RawQuery = query.Encode()
+	return endpointURL.String()
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// hasEmail returns true if the key has a user ID with the email address.
+func hasEmail(key *crypto.Key, email string) bool {
+	for _, userID := range key.GetKeyInfo().UserIDs {
+		if strings.EqualFold(userIDEmail(userID), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// userIDEmail returns the address of a "Name <email>" user ID, or the user
+// ID itself if it is a bare address.
+func userIDEmail(userID string) string {
+	start, end := strings.LastIndex(userID, "<"), strings.LastIndex(userID, ">")
+	if start >= 0 && end > start {
+		return userID[start+1 : end]
+	}
+	return strings.TrimSpace(userID)
+}
@@ -0,0 +1,101 @@
+package hkp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("hkp://keyserver.example.org")
+	if err != nil {
+		t.Fatal("Cannot create client:", err)
+	}
+	assert.Equal(t, "http://keyserver.example.org:11371/pks/lookup", client.endpoint("/pks/lookup", nil))
+
+	client = NewDefaultClient()
+	assert.Equal(t, "https://keys.openpgp.org/pks/add", client.endpoint("/pks/add", nil))
+
+	_, err = NewClientWithProxy("hkps://keys.openpgp.org/", "socks5://127.0.0.1:9050")
+	assert.Nil(t, err)
+
+	_, err = NewClient("ftp://keyserver.example.org")
+	assert.NotNil(t, err)
+}
+
+func TestLookupAndSubmit(t *testing.T) {
+	key, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	armoredKey, err := key.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+
+	var submitted string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(status)
+			return
+		}
+		switch r.URL.Path {
+		case "/pks/lookup":
+			search := r.URL.Query().Get("search")
+			if search != "0x"+key.GetFingerprint() && !strings.EqualFold(search, "joe.doe@example.org") {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(armoredKey))
+		case "/pks/add":
+			submitted = r.PostFormValue("keytext")
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal("Cannot create client:", err)
+	}
+	ctx := context.Background()
+
+	found, err := client.GetByFingerprint(ctx, strings.ToUpper(key.GetFingerprint()))
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Equal(t, key.GetFingerprint(), found.GetFingerprint())
+
+	keyRing, err := client.GetByEmail(ctx, "Joe.Doe@example.org")
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Exactly(t, 1, keyRing.CountEntities())
+
+	_, err = client.GetByEmail(ctx, "someone.else@example.org")
+	assert.Exactly(t, ErrNotFound, err)
+	_, err = client.GetByFingerprint(ctx, "0123")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, client.Submit(ctx, key))
+	assert.Equal(t, armoredKey, submitted)
+
+	status = http.StatusTooManyRequests
+	_, err = client.GetByEmail(ctx, "joe.doe@example.org")
+	assert.Exactly(t, RateLimitError{RetryAfter: 30 * time.Second}, err)
+
+	status = http.StatusInternalServerError
+	err = client.Submit(ctx, key)
+	serverErr, ok := err.(ServerError)
+	if assert.True(t, ok) {
+		assert.Exactly(t, http.StatusInternalServerError, serverErr.StatusCode)
+	}
+}
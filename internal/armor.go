@@ -1,18 +1,25 @@
 package internal
 
 import (
+	"io"
 	"strings"
 
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
 )
 
 // Unarmor unarmors an armored string.
 func Unarmor(input string) (*armor.Block, error) {
-	io := strings.NewReader(input)
-	b, err := armor.Decode(io)
+	return UnarmorReader(strings.NewReader(input))
+}
+
+// UnarmorReader unarmors the armored data read from r.
+func UnarmorReader(r io.Reader) (*armor.Block, error) {
+	b, err := armor.Decode(r)
 	if err != nil {
-		return nil, errors.Wrap(err, "gopenpgp: unable to unarmor")
+		return nil, WithErrorCode(constants.ErrorCodeInvalidArmor, errors.Wrap(err, "gopenpgp: unable to unarmor"))
 	}
 	return b, nil
 }
@@ -0,0 +1,26 @@
+package internal
+
+// CodedError attaches one of the constants.ErrorCode* values to an error
+// that has no distinctive type, without changing its message.
+type CodedError struct {
+	Code int
+	Err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorCode returns err with the error code attached, or nil if err is nil.
+func WithErrorCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
@@ -0,0 +1,108 @@
+package mobile
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// GetKeys returns the keys of the keyring.
+func GetKeys(keyRing *crypto.KeyRing) *KeyList {
+	return &KeyList{keys: keyRing.GetKeys()}
+}
+
+// GetEmails returns the email addresses of the user IDs of the keyring.
+func GetEmails(keyRing *crypto.KeyRing) *StringList {
+	list := NewStringList()
+	for _, identity := range keyRing.GetIdentities() {
+		list.Add(identity.Email)
+	}
+	return list
+}
+
+// GetSHA256Fingerprints returns the SHA256 fingerprints of the key and its
+// subkeys.
+func GetSHA256Fingerprints(key *crypto.Key) *StringList {
+	return &StringList{values: key.GetSHA256Fingerprints()}
+}
+
+// FilterExpiredKeys returns the keyrings of the list with at least one
+// unexpired key, as crypto.FilterExpiredKeys.
+func FilterExpiredKeys(keyRings *KeyRingList) (*KeyRingList, error) {
+	filtered, err := crypto.FilterExpiredKeys(keyRings.keyRings)
+	if err != nil {
+		return nil, withErrorCode(err)
+	}
+	return &KeyRingList{keyRings: filtered}, nil
+}
+
+// KeyInfo describes the properties of a key, as crypto.KeyInfo.
+type KeyInfo struct {
+	info *crypto.KeyInfo
+	// IsExpired is true if the primary key is expired.
+	IsExpired bool
+}
+
+// GetKeyInfo returns the properties of the key.
+func GetKeyInfo(key *crypto.Key) *KeyInfo {
+	info := key.GetKeyInfo()
+	return &KeyInfo{info: info, IsExpired: info.IsExpired}
+}
+
+// GetPrimaryKey returns the properties of the primary key.
+func (info *KeyInfo) GetPrimaryKey() *crypto.KeyPacketInfo {
+	primary := info.info.KeyPacketInfo
+	return &primary
+}
+
+// GetUserIDs returns the user IDs of the key, with the primary one first.
+func (info *KeyInfo) GetUserIDs() *StringList {
+	return &StringList{values: info.info.UserIDs}
+}
+
+// GetSubkeyCount returns the number of subkeys of the key.
+func (info *KeyInfo) GetSubkeyCount() int {
+	return len(info.info.Subkeys)
+}
+
+// GetSubkey returns the properties of the subkey at index i, or nil if i is
+// out of range.
+func (info *KeyInfo) GetSubkey(i int) *crypto.KeyPacketInfo {
+	if i < 0 || i >= len(info.info.Subkeys) {
+		return nil
+	}
+	return info.info.Subkeys[i]
+}
+
+// KeyValidationReport lists the problems of a key, as
+// crypto.KeyValidationReport.
+type KeyValidationReport struct {
+	report *crypto.KeyValidationReport
+}
+
+// ValidateKey checks the signatures, algorithms and expiration of the key.
+func ValidateKey(key *crypto.Key) *KeyValidationReport {
+	return &KeyValidationReport{report: key.Validate()}
+}
+
+// IsValid returns true if no problem was found.
+func (report *KeyValidationReport) IsValid() bool {
+	return report.report.IsValid()
+}
+
+// HasProblem returns true if a problem with the code, one of the
+// crypto.KeyProblem* constants, was found.
+func (report *KeyValidationReport) HasProblem(code string) bool {
+	return report.report.HasProblem(code)
+}
+
+// GetProblemCount returns the number of problems found.
+func (report *KeyValidationReport) GetProblemCount() int {
+	return len(report.report.Problems)
+}
+
+// GetProblem returns the problem at index i, or nil if i is out of range.
+func (report *KeyValidationReport) GetProblem(i int) *crypto.KeyProblem {
+	if i < 0 || i >= len(report.report.Problems) {
+		return nil
+	}
+	return report.report.Problems[i]
+}
@@ -0,0 +1,235 @@
+package mobile
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/pkg/errors"
+)
+
+// GetHexEncryptionKeyIDs returns the key IDs of the keys to which the message
+// is encrypted, as hexadecimal strings.
+func GetHexEncryptionKeyIDs(message *crypto.PGPMessage) *StringList {
+	keyIDs, _ := message.GetHexEncryptionKeyIDs()
+	return &StringList{values: keyIDs}
+}
+
+// GetHexSignatureKeyIDs returns the key IDs of the keys which signed the
+// message, as hexadecimal strings.
+func GetHexSignatureKeyIDs(message *crypto.PGPMessage) *StringList {
+	keyIDs, _ := message.GetHexSignatureKeyIDs()
+	return &StringList{values: keyIDs}
+}
+
+// PGPMessageList is a list of PGP messages.
+type PGPMessageList struct {
+	messages []*crypto.PGPMessage
+}
+
+// NewPGPMessageList creates an empty list.
+func NewPGPMessageList() *PGPMessageList {
+	return &PGPMessageList{}
+}
+
+// Add appends a message to the list.
+func (list *PGPMessageList) Add(message *crypto.PGPMessage) {
+	list.messages = append(list.messages, message)
+}
+
+// Len returns the number of messages of the list.
+func (list *PGPMessageList) Len() int {
+	return len(list.messages)
+}
+
+// PGPSplitMessageList is a list of split PGP messages, e.g. attachments.
+type PGPSplitMessageList struct {
+	messages []*crypto.PGPSplitMessage
+}
+
+// NewPGPSplitMessageList creates an empty list.
+func NewPGPSplitMessageList() *PGPSplitMessageList {
+	return &PGPSplitMessageList{}
+}
+
+// Add appends a message to the list.
+func (list *PGPSplitMessageList) Add(message *crypto.PGPSplitMessage) {
+	list.messages = append(list.messages, message)
+}
+
+// Len returns the number of messages of the list.
+func (list *PGPSplitMessageList) Len() int {
+	return len(list.messages)
+}
+
+// DecryptionResult is the result of the decryption of one message.
+type DecryptionResult struct {
+	// Message is nil if the decryption failed.
+	Message *crypto.PlainMessage
+	// SignatureStatus is one of the constants.SIGNATURE_* values.
+	SignatureStatus int
+	// Error describes the decryption or signature verification error, if any.
+	Error string
+	// ErrorCode is the code of the error, one of the constants.ErrorCode*
+	// values, or constants.ErrorCodeNone.
+	ErrorCode int
+}
+
+// DecryptionResultList is the list of results of DecryptParallel and
+// DecryptAttachmentsParallel, in the order of the messages.
+type DecryptionResultList struct {
+	results []*DecryptionResult
+}
+
+// Len returns the number of results of the list.
+func (list *DecryptionResultList) Len() int {
+	return len(list.results)
+}
+
+// Get returns the result at index i, or nil if i is out of range.
+func (list *DecryptionResultList) Get(i int) *DecryptionResult {
+	if i < 0 || i >= len(list.results) {
+		return nil
+	}
+	return list.results[i]
+}
+
+// DecryptParallel decrypts the messages concurrently, as
+// crypto.KeyRing.DecryptParallel.
+func DecryptParallel(
+	keyRing *crypto.KeyRing, messages *PGPMessageList, verifyKey *crypto.KeyRing, verifyTime int64, workers int,
+) *DecryptionResultList {
+	return newDecryptionResultList(
+		keyRing.DecryptParallel(messages.messages, verifyKey, verifyTime, workers),
+		verifyKey != nil,
+	)
+}
+
+// DecryptAttachmentsParallel decrypts the attachments concurrently, as
+// crypto.KeyRing.DecryptAttachmentsParallel.
+func DecryptAttachmentsParallel(
+	keyRing *crypto.KeyRing, attachments *PGPSplitMessageList, workers int,
+) *DecryptionResultList {
+	return newDecryptionResultList(keyRing.DecryptAttachmentsParallel(attachments.messages, workers), false)
+}
+
+// MIMEMessage is a decrypted and verified MIME message, as
+// crypto.MIMEMessage.
+type MIMEMessage struct {
+	message         *crypto.MIMEMessage
+	Body            string
+	BodyContentType string
+}
+
+// DecryptMIME parses, decrypts and verifies a MIME message, as
+// crypto.KeyRing.DecryptMIME.
+func DecryptMIME(keyRing *crypto.KeyRing, message []byte, verifyKey *crypto.KeyRing, verifyTime int64) (*MIMEMessage, error) {
+	mimeMessage, err := keyRing.DecryptMIME(message, verifyKey, verifyTime)
+	if err != nil {
+		return nil, withErrorCode(err)
+	}
+	return newMIMEMessage(mimeMessage), nil
+}
+
+// VerifyMIME parses and verifies a MIME message, as crypto.VerifyMIME.
+func VerifyMIME(message []byte, verifyKey *crypto.KeyRing, verifyTime int64) (*MIMEMessage, error) {
+	mimeMessage, err := crypto.VerifyMIME(message, verifyKey, verifyTime)
+	if err != nil {
+		return nil, withErrorCode(err)
+	}
+	return newMIMEMessage(mimeMessage), nil
+}
+
+// GetHeader returns the first value of a header of the outer MIME entity.
+func (message *MIMEMessage) GetHeader(name string) string {
+	return message.message.Headers.Get(name)
+}
+
+// GetBodySignatureStatus returns the status of the signature covering the
+// body, one of the constants.SIGNATURE_* values.
+func (message *MIMEMessage) GetBodySignatureStatus() int {
+	if message.message.BodySignature == nil {
+		return constants.SIGNATURE_NOT_SIGNED
+	}
+	return message.message.BodySignature.Status
+}
+
+// GetAttachmentCount returns the number of attachments of the message.
+func (message *MIMEMessage) GetAttachmentCount() int {
+	return len(message.message.Attachments)
+}
+
+// GetAttachment returns the attachment at index i, or nil if i is out of
+// range.
+func (message *MIMEMessage) GetAttachment(i int) *crypto.MIMEAttachment {
+	if i < 0 || i >= len(message.message.Attachments) {
+		return nil
+	}
+	return message.message.Attachments[i]
+}
+
+// GetSignatureCount returns the number of signed parts of the message.
+func (message *MIMEMessage) GetSignatureCount() int {
+	return len(message.message.Signatures)
+}
+
+// GetSignature returns the verdict of the signed part at index i, or nil if i
+// is out of range.
+func (message *MIMEMessage) GetSignature(i int) *crypto.MIMESignature {
+	if i < 0 || i >= len(message.message.Signatures) {
+		return nil
+	}
+	return message.message.Signatures[i]
+}
+
+// InlineResult is the result of DecryptVerifyInline.
+type InlineResult struct {
+	// Text is the body with the armored blocks replaced by their text.
+	Text string
+	// Statuses are the verification statuses of the replaced blocks.
+	Statuses *IntList
+}
+
+// DecryptVerifyInline decrypts and verifies the armored blocks of an
+// inline-PGP body, as helper.DecryptVerifyInline.
+func DecryptVerifyInline(
+	keyRing, verifyKey *crypto.KeyRing, body string, verifyTime int64,
+) (*InlineResult, error) {
+	text, statuses, err := helper.DecryptVerifyInline(keyRing, verifyKey, body, verifyTime)
+	if err != nil {
+		return nil, withErrorCode(err)
+	}
+	return &InlineResult{Text: text, Statuses: &IntList{values: statuses}}, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func newDecryptionResultList(results []*crypto.DecryptionResult, verified bool) *DecryptionResultList {
+	list := &DecryptionResultList{}
+	for _, result := range results {
+		mobileResult := &DecryptionResult{
+			Message:         result.Message,
+			SignatureStatus: constants.SIGNATURE_NOT_SIGNED,
+			ErrorCode:       crypto.GetErrorCode(result.Err),
+		}
+		var sigErr crypto.SignatureVerificationError
+		switch {
+		case errors.As(result.Err, &sigErr):
+			mobileResult.SignatureStatus = sigErr.Status
+		case result.Err == nil && verified:
+			mobileResult.SignatureStatus = constants.SIGNATURE_OK
+		}
+		if result.Err != nil {
+			mobileResult.Error = result.Err.Error()
+		}
+		list.results = append(list.results, mobileResult)
+	}
+	return list
+}
+
+func newMIMEMessage(message *crypto.MIMEMessage) *MIMEMessage {
+	return &MIMEMessage{
+		message:         message,
+		Body:            message.Body,
+		BodyContentType: message.BodyContentType,
+	}
+}
@@ -0,0 +1,153 @@
+// Package mobile wraps the crypto and helper packages with types that can be
+// bound by gomobile for iOS and Android: functions return at most one value
+// and an error, and lists are exposed through types with a length and an
+// indexed getter instead of slices.
+package mobile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// errorCodePattern matches the error code at the end of the message of the
+// errors returned by the package, see codedError.
+var errorCodePattern = regexp.MustCompile(` \[gopenpgp error (\d+)\]$`)
+
+// StringList is a list of strings.
+type StringList struct {
+	values []string
+}
+
+// NewStringList creates an empty list.
+func NewStringList() *StringList {
+	return &StringList{}
+}
+
+// Add appends a string to the list.
+func (list *StringList) Add(value string) {
+	list.values = append(list.values, value)
+}
+
+// Len returns the number of strings of the list.
+func (list *StringList) Len() int {
+	return len(list.values)
+}
+
+// Get returns the string at index i, or "" if i is out of range.
+func (list *StringList) Get(i int) string {
+	if i < 0 || i >= len(list.values) {
+		return ""
+	}
+	return list.values[i]
+}
+
+// IntList is a list of integers.
+type IntList struct {
+	values []int
+}
+
+// Len returns the number of integers of the list.
+func (list *IntList) Len() int {
+	return len(list.values)
+}
+
+// Get returns the integer at index i, or 0 if i is out of range.
+func (list *IntList) Get(i int) int {
+	if i < 0 || i >= len(list.values) {
+		return 0
+	}
+	return list.values[i]
+}
+
+// KeyList is a list of keys.
+type KeyList struct {
+	keys []*crypto.Key
+}
+
+// Len returns the number of keys of the list.
+func (list *KeyList) Len() int {
+	return len(list.keys)
+}
+
+// Get returns the key at index i, or nil if i is out of range.
+func (list *KeyList) Get(i int) *crypto.Key {
+	if i < 0 || i >= len(list.keys) {
+		return nil
+	}
+	return list.keys[i]
+}
+
+// KeyRingList is a list of keyrings.
+type KeyRingList struct {
+	keyRings []*crypto.KeyRing
+}
+
+// NewKeyRingList creates an empty list.
+func NewKeyRingList() *KeyRingList {
+	return &KeyRingList{}
+}
+
+// Add appends a keyring to the list.
+func (list *KeyRingList) Add(keyRing *crypto.KeyRing) {
+	list.keyRings = append(list.keyRings, keyRing)
+}
+
+// Len returns the number of keyrings of the list.
+func (list *KeyRingList) Len() int {
+	return len(list.keyRings)
+}
+
+// Get returns the keyring at index i, or nil if i is out of range.
+func (list *KeyRingList) Get(i int) *crypto.KeyRing {
+	if i < 0 || i >= len(list.keyRings) {
+		return nil
+	}
+	return list.keyRings[i]
+}
+
+// GetErrorCode returns the stable code of an error thrown by the functions of
+// this package, one of the constants.ErrorCode* values, from its message, as
+// the error type doesn't cross the bindings: constants.ErrorCodeNone for an
+// empty message, and constants.ErrorCodeUnknown if it has no code.
+func GetErrorCode(message string) int {
+	if message == "" {
+		return constants.ErrorCodeNone
+	}
+	match := errorCodePattern.FindStringSubmatch(message)
+	if match == nil {
+		return constants.ErrorCodeUnknown
+	}
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return constants.ErrorCodeUnknown
+	}
+	return code
+}
+
+// codedError is an error returned through the bindings, whose message ends
+// with the code of crypto.GetErrorCode, e.g. " [gopenpgp error 5]".
+type codedError struct {
+	err  error
+	code int
+}
+
+func (e *codedError) Error() string {
+	return fmt.Sprintf("%s [gopenpgp error %d]", e.err.Error(), e.code)
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withErrorCode returns err with its code appended to its message, or nil if
+// err is nil.
+func withErrorCode(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err: err, code: crypto.GetErrorCode(err)}
+}
@@ -0,0 +1,131 @@
+package mobile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+)
+
+func newTestKeyRing(t *testing.T) *crypto.KeyRing {
+	key, err := crypto.GenerateKey("Mobile", "mobile@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	return keyRing
+}
+
+func TestKeyAccessors(t *testing.T) {
+	keyRing := newTestKeyRing(t)
+	key := GetKeys(keyRing).Get(0)
+	assert.NotNil(t, key)
+	assert.Nil(t, GetKeys(keyRing).Get(1))
+	assert.Exactly(t, "mobile@example.org", GetEmails(keyRing).Get(0))
+	assert.Exactly(t, 2, GetSHA256Fingerprints(key).Len())
+
+	info := GetKeyInfo(key)
+	assert.Exactly(t, key.GetFingerprint(), info.GetPrimaryKey().Fingerprint)
+	assert.Exactly(t, "Mobile <mobile@example.org>", info.GetUserIDs().Get(0))
+	assert.Exactly(t, 1, info.GetSubkeyCount())
+	assert.True(t, info.GetSubkey(0).CanEncrypt)
+
+	report := ValidateKey(key)
+	assert.True(t, report.IsValid())
+	assert.Exactly(t, 0, report.GetProblemCount())
+
+	keyRings := NewKeyRingList()
+	keyRings.Add(keyRing)
+	filtered, err := FilterExpiredKeys(keyRings)
+	assert.Nil(t, err)
+	assert.Exactly(t, 1, filtered.Len())
+}
+
+func TestDecryptParallel(t *testing.T) {
+	keyRing := newTestKeyRing(t)
+	messages := NewPGPMessageList()
+	for _, text := range []string{"first", "second"} {
+		message, err := keyRing.Encrypt(crypto.NewPlainMessageFromString(text), keyRing)
+		if err != nil {
+			t.Fatal("Cannot encrypt message:", err)
+		}
+		messages.Add(message)
+	}
+	unsigned, err := keyRing.Encrypt(crypto.NewPlainMessageFromString("unsigned"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+	messages.Add(unsigned)
+	assert.Exactly(t, 1, GetHexEncryptionKeyIDs(unsigned).Len())
+
+	results := DecryptParallel(keyRing, messages, keyRing, crypto.GetUnixTime(), 2)
+	assert.Exactly(t, 3, results.Len())
+	assert.Exactly(t, "second", results.Get(1).Message.GetString())
+	assert.Exactly(t, constants.SIGNATURE_OK, results.Get(1).SignatureStatus)
+	assert.Empty(t, results.Get(1).Error)
+	assert.Exactly(t, constants.ErrorCodeNone, results.Get(1).ErrorCode)
+	assert.Exactly(t, constants.SIGNATURE_NOT_SIGNED, results.Get(2).SignatureStatus)
+	assert.NotEmpty(t, results.Get(2).Error)
+	assert.Exactly(t, constants.ErrorCodeSignatureNotSigned, results.Get(2).ErrorCode)
+}
+
+func TestGetErrorCode(t *testing.T) {
+	keyRing := newTestKeyRing(t)
+	message, err := keyRing.Encrypt(crypto.NewPlainMessageFromString("Encrypted"), nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+	_, err = newTestKeyRing(t).Decrypt(message, nil, 0)
+	if err == nil {
+		t.Fatal("Expected an error when decrypting with another key")
+	}
+
+	// The message of the error crossing the bindings holds its code
+	err = withErrorCode(err)
+	assert.Exactly(t, constants.ErrorCodeNoDecryptionKey, GetErrorCode(err.Error()))
+	assert.Exactly(t, constants.ErrorCodeNoDecryptionKey, crypto.GetErrorCode(err))
+
+	assert.Nil(t, withErrorCode(nil))
+	assert.Exactly(t, constants.ErrorCodeNone, GetErrorCode(""))
+	assert.Exactly(t, constants.ErrorCodeUnknown, GetErrorCode("gopenpgp: error"))
+}
+
+func TestMIMEAndInline(t *testing.T) {
+	keyRing := newTestKeyRing(t)
+	built, err := crypto.NewMIMEBuilder("Hello", "text/plain").
+		SetHeader("Subject", "Test").
+		AddAttachment("a.txt", "text/plain", []byte("attached")).
+		Build(keyRing, keyRing)
+	if err != nil {
+		t.Fatal("Cannot build MIME message:", err)
+	}
+
+	message, err := DecryptMIME(keyRing, built, keyRing, crypto.GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot decrypt MIME message:", err)
+	}
+	assert.Exactly(t, "Hello", message.Body)
+	assert.Exactly(t, "Test", message.GetHeader("Subject"))
+	assert.Exactly(t, constants.SIGNATURE_OK, message.GetBodySignatureStatus())
+	assert.Exactly(t, 1, message.GetAttachmentCount())
+	assert.Exactly(t, "a.txt", message.GetAttachment(0).FileName)
+	assert.Exactly(t, 1, message.GetSignatureCount())
+
+	signed, err := helper.SignInline(keyRing, "Signed")
+	if err != nil {
+		t.Fatal("Cannot sign inline message:", err)
+	}
+	result, err := DecryptVerifyInline(keyRing, keyRing, signed, crypto.GetUnixTime())
+	if err != nil {
+		t.Fatal("Cannot verify inline message:", err)
+	}
+	assert.Exactly(t, "Signed", result.Text)
+	assert.Exactly(t, 1, result.Statuses.Len())
+	assert.Exactly(t, constants.SIGNATURE_OK, result.Statuses.Get(0))
+}
@@ -0,0 +1,10 @@
+module github.com/ProtonMail/gopenpgp/v2/pkcs11
+
+go 1.15
+
+require (
+	github.com/ProtonMail/gopenpgp/v2 v2.4.9-0.20261017151225-387fd2d634f6
+	github.com/miekg/pkcs11 v1.1.1
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.4.0
+)
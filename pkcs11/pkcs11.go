@@ -0,0 +1,307 @@
+//go:build cgo
+// +build cgo
+
+// Package pkcs11 uses the RSA private keys of a PKCS#11 token, e.g. an HSM or
+// a smartcard, as OpenPGP signing and decryption keys, so that the secret
+// material never leaves the token.
+//
+// The PKCS#11 module is loaded at runtime, which requires cgo.
+//
+// It is a separate Go module, so that the dependency on the PKCS#11 bindings
+// isn't imposed on every user of gopenpgp. It requires a version of
+// gopenpgp with the APIs it uses. To develop it against the gopenpgp of the
+// same checkout, use a workspace, as the CI does:
+//
+//	go work init ./pkcs11
+//	go work edit -replace github.com/ProtonMail/gopenpgp/v2=./
+package pkcs11
+
+import (
+	gocrypto "crypto"
+	"crypto/rsa"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// ErrNotFound is returned when a token or a key is not found.
+var ErrNotFound = errors.New("gopenpgp: not found on the PKCS#11 module")
+
+// digestInfoPrefixes are the DER prefixes of the DigestInfo of each hash,
+// signed with the digest by CKM_RSA_PKCS, see RFC 8017, section 9.2.
+var digestInfoPrefixes = map[gocrypto.Hash][]byte{
+	gocrypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	gocrypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	gocrypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	gocrypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	gocrypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Token is a session on a PKCS#11 token, logged in as user. It is safe for
+// concurrent use.
+type Token struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	lock    sync.Mutex
+}
+
+// Open loads the PKCS#11 module at modulePath, e.g. the SoftHSM or OpenSC
+// library, and logs in the token with the given label with pin.
+// An empty label selects the first token.
+func Open(modulePath, tokenLabel, pin string) (*Token, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, errors.New("gopenpgp: unable to load PKCS#11 module")
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, errors.Wrap(err, "gopenpgp: unable to initialize PKCS#11 module")
+	}
+
+	token, err := openToken(ctx, tokenLabel, pin)
+	if err != nil {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return token, nil
+}
+
+// Close logs out of the token, and unloads the module.
+func (token *Token) Close() error {
+	token.lock.Lock()
+	defer token.lock.Unlock()
+
+	_ = token.ctx.Logout(token.session)
+	err := token.ctx.CloseSession(token.session)
+	_ = token.ctx.Finalize()
+	token.ctx.Destroy()
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to close PKCS#11 session")
+	}
+	return nil
+}
+
+// FindPrivateKey returns the private key of the token for publicKey, or
+// ErrNotFound.
+func (token *Token) FindPrivateKey(publicKey *rsa.PublicKey) (*PrivateKey, error) {
+	token.lock.Lock()
+	defer token.lock.Unlock()
+
+	modulus := publicKey.N.Bytes()
+	handle, err := token.findObject(
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, modulus),
+	)
+	if errors.Is(err, ErrNotFound) {
+		// Some tokens only expose the modulus of the public key objects
+		handle, err = token.findPrivateKeyByPublicKey(modulus)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKey{token: token, handle: handle, publicKey: publicKey}, nil
+}
+
+// NewKey returns a private key created from the public key, or from the
+// public part of the private key, whose signatures and decryptions are
+// delegated to the token, with crypto.NewKeyFromSigner.
+// The signing key and the encryption key are the first key parts, primary
+// key or subkeys, with the matching flags whose private key is on the token.
+func (token *Token) NewKey(key *crypto.Key) (*crypto.Key, error) {
+	entity := key.GetEntity()
+
+	var signer gocrypto.Signer
+	var decrypter gocrypto.Decrypter
+	findKey := func(publicKey gocrypto.PublicKey, canSign, canEncrypt bool) error {
+		rsaKey, ok := publicKey.(*rsa.PublicKey)
+		if !ok || !(canSign && signer == nil || canEncrypt && decrypter == nil) {
+			return nil
+		}
+		privateKey, err := token.FindPrivateKey(rsaKey)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if canSign && signer == nil {
+			signer = privateKey
+		}
+		if canEncrypt && decrypter == nil {
+			decrypter = privateKey
+		}
+		return nil
+	}
+
+	if identity := entity.PrimaryIdentity(); identity != nil && identity.SelfSignature != nil {
+		sig := identity.SelfSignature
+		canEncrypt := sig.FlagEncryptCommunications || sig.FlagEncryptStorage
+		if err := findKey(entity.PrimaryKey.PublicKey, sig.FlagSign, canEncrypt); err != nil {
+			return nil, err
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		canEncrypt := subkey.Sig.FlagEncryptCommunications || subkey.Sig.FlagEncryptStorage
+		if err := findKey(subkey.PublicKey.PublicKey, subkey.Sig.FlagSign, canEncrypt); err != nil {
+			return nil, err
+		}
+	}
+	if signer == nil && decrypter == nil {
+		return nil, ErrNotFound
+	}
+
+	return crypto.NewKeyFromSigner(key, signer, decrypter)
+}
+
+// PrivateKey is an RSA private key on a PKCS#11 token. It implements
+// crypto.Signer and crypto.Decrypter.
+type PrivateKey struct {
+	token     *Token
+	handle    pkcs11.ObjectHandle
+	publicKey *rsa.PublicKey
+}
+
+// Public returns the *rsa.PublicKey of the private key.
+func (key *PrivateKey) Public() gocrypto.PublicKey {
+	return key.publicKey
+}
+
+// Sign signs a digest with PKCS #1 v1.5, computed with the hash of opts.
+func (key *PrivateKey) Sign(_ io.Reader, digest []byte, opts gocrypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("gopenpgp: RSA-PSS signatures are not supported")
+	}
+	digestInfo, err := encodeDigestInfo(opts.HashFunc(), digest)
+	if err != nil {
+		return nil, err
+	}
+
+	key.token.lock.Lock()
+	defer key.token.lock.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}
+	if err := key.token.ctx.SignInit(key.token.session, mechanism, key.handle); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to sign with PKCS#11 token")
+	}
+	signature, err := key.token.ctx.Sign(key.token.session, digestInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to sign with PKCS#11 token")
+	}
+	return signature, nil
+}
+
+// Decrypt decrypts a ciphertext encrypted with PKCS #1 v1.5, and returns the
+// unpadded plaintext.
+func (key *PrivateKey) Decrypt(_ io.Reader, ciphertext []byte, opts gocrypto.DecrypterOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.OAEPOptions); ok {
+		return nil, errors.New("gopenpgp: RSA-OAEP decryption is not supported")
+	}
+
+	key.token.lock.Lock()
+	defer key.token.lock.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}
+	if err := key.token.ctx.DecryptInit(key.token.session, mechanism, key.handle); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt with PKCS#11 token")
+	}
+	plaintext, err := key.token.ctx.Decrypt(key.token.session, ciphertext)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to decrypt with PKCS#11 token")
+	}
+	return plaintext, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// openToken opens a session on the token with the given label, or the first
+// token if empty, and logs in as user.
+func openToken(ctx *pkcs11.Ctx, tokenLabel, pin string) (*Token, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to list PKCS#11 slots")
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read PKCS#11 token")
+		}
+		// Token labels are padded with spaces
+		if tokenLabel != "" && strings.TrimRight(info.Label, " \x00") != tokenLabel {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to open PKCS#11 session")
+		}
+		err = ctx.Login(session, pkcs11.CKU_USER, pin)
+		if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			_ = ctx.CloseSession(session)
+			return nil, errors.Wrap(err, "gopenpgp: unable to log in PKCS#11 token")
+		}
+		return &Token{ctx: ctx, session: session}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// findObject returns the first object matching template, or ErrNotFound.
+// The caller must hold the lock of the token.
+func (token *Token) findObject(template ...*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	if err := token.ctx.FindObjectsInit(token.session, template); err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to search PKCS#11 token")
+	}
+	handles, _, err := token.ctx.FindObjects(token.session, 1)
+	finalErr := token.ctx.FindObjectsFinal(token.session)
+	if err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to search PKCS#11 token")
+	}
+	if len(handles) == 0 {
+		return 0, ErrNotFound
+	}
+	return handles[0], nil
+}
+
+// findPrivateKeyByPublicKey returns the private key with the same CKA_ID as
+// the public key object with the given modulus, or ErrNotFound.
+// The caller must hold the lock of the token.
+func (token *Token) findPrivateKeyByPublicKey(modulus []byte) (pkcs11.ObjectHandle, error) {
+	publicHandle, err := token.findObject(
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, modulus),
+	)
+	if err != nil {
+		return 0, err
+	}
+	attributes, err := token.ctx.GetAttributeValue(
+		token.session, publicHandle, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)},
+	)
+	if err != nil || len(attributes) == 0 || len(attributes[0].Value) == 0 {
+		return 0, ErrNotFound
+	}
+	return token.findObject(
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, attributes[0].Value),
+	)
+}
+
+// encodeDigestInfo returns the DER encoded DigestInfo of a digest, signed
+// with CKM_RSA_PKCS.
+func encodeDigestInfo(hash gocrypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := digestInfoPrefixes[hash]
+	if !ok || len(digest) != hash.Size() {
+		return nil, errors.New("gopenpgp: unsupported hash for PKCS#11 signatures")
+	}
+	return append(append([]byte{}, prefix...), digest...), nil
+}
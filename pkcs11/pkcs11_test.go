@@ -0,0 +1,290 @@
+//go:build cgo
+// +build cgo
+
+package pkcs11
+
+import (
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+func TestEncodeDigestInfo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal("Cannot generate RSA key:", err)
+	}
+
+	for hash := range digestInfoPrefixes {
+		h := hash.New()
+		_, _ = h.Write([]byte("message"))
+		digest := h.Sum(nil)
+
+		digestInfo, err := encodeDigestInfo(hash, digest)
+		if err != nil {
+			t.Fatal("Cannot encode digest:", err)
+		}
+
+		// CKM_RSA_PKCS signs the DigestInfo like Go signs the digest
+		expected, err := rsa.SignPKCS1v15(nil, key, hash, digest)
+		assert.Nil(t, err)
+		signature, err := rsa.SignPKCS1v15(nil, key, gocrypto.Hash(0), digestInfo)
+		assert.Nil(t, err)
+		assert.Exactly(t, expected, signature)
+	}
+
+	_, err = encodeDigestInfo(gocrypto.SHA256, []byte("too short"))
+	assert.NotNil(t, err)
+	_, err = encodeDigestInfo(gocrypto.MD5, make([]byte, gocrypto.MD5.Size()))
+	assert.NotNil(t, err)
+}
+
+func TestOpenMissingModule(t *testing.T) {
+	_, err := Open("/nonexistent/libpkcs11.so", "", "1234")
+	assert.NotNil(t, err)
+}
+
+// softHSMModules are the usual paths of the SoftHSM module, used when
+// SOFTHSM2_MODULE is not set.
+var softHSMModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+const (
+	testTokenLabel = "gopenpgp"
+	testSOPin      = "5678"
+	testPin        = "1234"
+)
+
+func TestSoftHSM(t *testing.T) {
+	modulePath := setupSoftHSM(t)
+
+	key, err := crypto.GenerateKey("pkcs11", "pkcs11@proton.me", "rsa", 2048)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	entity := key.GetEntity()
+	primaryKey, ok := entity.PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA primary key")
+	}
+	subkey, ok := entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA subkey")
+	}
+	importSoftHSMKeys(t, modulePath, primaryKey, subkey)
+
+	_, err = Open(modulePath, "missing", testPin)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	token, err := Open(modulePath, testTokenLabel, testPin)
+	if err != nil {
+		t.Fatal("Expected no error when opening the token, got:", err)
+	}
+	defer func() { assert.Nil(t, token.Close()) }()
+
+	// Sign
+	signer, err := token.FindPrivateKey(&primaryKey.PublicKey)
+	if err != nil {
+		t.Fatal("Expected no error when finding the signing key, got:", err)
+	}
+	digest := sha256.Sum256([]byte("message"))
+	signature, err := signer.Sign(rand.Reader, digest[:], gocrypto.SHA256)
+	if err != nil {
+		t.Fatal("Expected no error when signing, got:", err)
+	}
+	assert.Nil(t, rsa.VerifyPKCS1v15(&primaryKey.PublicKey, gocrypto.SHA256, digest[:], signature))
+
+	// Decrypt
+	decrypter, err := token.FindPrivateKey(&subkey.PublicKey)
+	if err != nil {
+		t.Fatal("Expected no error when finding the decryption key, got:", err)
+	}
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &subkey.PublicKey, []byte("session key"))
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	plaintext, err := decrypter.Decrypt(rand.Reader, ciphertext, nil)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	assert.Exactly(t, []byte("session key"), plaintext)
+
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal("Cannot generate RSA key:", err)
+	}
+	_, err = token.FindPrivateKey(&other.PublicKey)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// OpenPGP messages, with the public key only
+	publicKey, err := key.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+	tokenKey, err := token.NewKey(publicKey)
+	if err != nil {
+		t.Fatal("Expected no error when creating the token key, got:", err)
+	}
+	keyRing, err := crypto.NewKeyRing(tokenKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	message := crypto.NewPlainMessageFromString("hello from the token")
+	pgpSignature, err := keyRing.SignDetached(message)
+	if err != nil {
+		t.Fatal("Expected no error when signing the message, got:", err)
+	}
+	assert.Nil(t, keyRing.VerifyDetached(message, pgpSignature, crypto.GetUnixTime()))
+
+	encrypted, err := keyRing.Encrypt(message, nil)
+	if err != nil {
+		t.Fatal("Cannot encrypt message:", err)
+	}
+	decrypted, err := keyRing.Decrypt(encrypted, nil, 0)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting the message, got:", err)
+	}
+	assert.Exactly(t, message.GetString(), decrypted.GetString())
+}
+
+// setupSoftHSM returns the path of the SoftHSM module, configured with a
+// token directory in a temporary directory, or skips the test if SoftHSM is
+// not installed.
+func setupSoftHSM(t *testing.T) string {
+	modulePath := os.Getenv("SOFTHSM2_MODULE")
+	if modulePath == "" {
+		for _, path := range softHSMModules {
+			if _, err := os.Stat(path); err == nil {
+				modulePath = path
+				break
+			}
+		}
+	}
+	if modulePath == "" {
+		t.Skip("SoftHSM is not installed")
+	}
+
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); err != nil {
+		t.Fatal("Cannot create token directory:", err)
+	}
+	configPath := filepath.Join(dir, "softhsm2.conf")
+	config := "directories.tokendir = " + tokenDir + "\nobjectstore.backend = file\n"
+	if err := ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal("Cannot write SoftHSM configuration:", err)
+	}
+
+	previous, set := os.LookupEnv("SOFTHSM2_CONF")
+	if err := os.Setenv("SOFTHSM2_CONF", configPath); err != nil {
+		t.Fatal("Cannot set SOFTHSM2_CONF:", err)
+	}
+	t.Cleanup(func() {
+		if set {
+			_ = os.Setenv("SOFTHSM2_CONF", previous)
+		} else {
+			_ = os.Unsetenv("SOFTHSM2_CONF")
+		}
+	})
+	return modulePath
+}
+
+// importSoftHSMKeys initializes the test token, and imports the RSA keys.
+func importSoftHSMKeys(t *testing.T, modulePath string, keys ...*rsa.PrivateKey) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		t.Fatal("Cannot load SoftHSM module")
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal("Cannot initialize SoftHSM:", err)
+	}
+	defer func() { _ = ctx.Finalize() }()
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil || len(slots) == 0 {
+		t.Fatal("Cannot list SoftHSM slots:", err)
+	}
+	if err = ctx.InitToken(slots[0], testSOPin, testTokenLabel); err != nil {
+		t.Fatal("Cannot initialize token:", err)
+	}
+
+	// SoftHSM moves the initialized token to a new slot
+	slots, err = ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatal("Cannot list SoftHSM slots:", err)
+	}
+	var session pkcs11.SessionHandle
+	found := false
+	for _, slot := range slots {
+		info, infoErr := ctx.GetTokenInfo(slot)
+		if infoErr != nil || strings.TrimRight(info.Label, " \x00") != testTokenLabel {
+			continue
+		}
+		if session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION); err != nil {
+			t.Fatal("Cannot open session:", err)
+		}
+		found = true
+		break
+	}
+	if !found {
+		t.Fatal("Cannot find initialized token")
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+
+	if err = ctx.Login(session, pkcs11.CKU_SO, testSOPin); err != nil {
+		t.Fatal("Cannot log in as SO:", err)
+	}
+	if err = ctx.InitPIN(session, testPin); err != nil {
+		t.Fatal("Cannot set user PIN:", err)
+	}
+	if err = ctx.Logout(session); err != nil {
+		t.Fatal("Cannot log out:", err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, testPin); err != nil {
+		t.Fatal("Cannot log in:", err)
+	}
+	defer func() { _ = ctx.Logout(session) }()
+
+	for _, key := range keys {
+		key.Precompute()
+		_, err = ctx.CreateObject(session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE_EXPONENT, key.D.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_1, key.Primes[0].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_2, key.Primes[1].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_1, key.Precomputed.Dp.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_2, key.Precomputed.Dq.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_COEFFICIENT, key.Precomputed.Qinv.Bytes()),
+		})
+		if err != nil {
+			t.Fatal("Cannot import key:", err)
+		}
+	}
+}
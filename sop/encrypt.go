@@ -0,0 +1,303 @@
+package sop
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// EncryptOptions are the options of Encrypt.
+type EncryptOptions struct {
+	// AsText marks the literal data as text.
+	AsText  bool
+	NoArmor bool
+	// Passwords the message is encrypted with, in addition to the
+	// certificates.
+	Passwords [][]byte
+	// SignKeys sign the message, if any. Only the first key able to sign
+	// is used.
+	SignKeys [][]byte
+	// KeyPasswords are tried to unlock the locked signing keys.
+	KeyPasswords [][]byte
+}
+
+// DecryptOptions are the options of Decrypt.
+type DecryptOptions struct {
+	// SessionKeys are tried to decrypt the message, before the keys and the
+	// passwords, in the "ALGO:HEX" format of the specification.
+	SessionKeys []string
+	Passwords   [][]byte
+	// VerifyCerts verify the signature of the message, if any.
+	VerifyCerts [][]byte
+	// KeyPasswords are tried to unlock the locked keys.
+	KeyPasswords [][]byte
+	// Signatures made outside of [NotBefore, NotAfter] are ignored.
+	NotBefore, NotAfter time.Time
+}
+
+// DecryptResult is the result of Decrypt.
+type DecryptResult struct {
+	Data []byte
+	// SessionKey is the session key of the message, in the "ALGO:HEX" format
+	// of the specification.
+	SessionKey    string
+	Verifications []*Verification
+}
+
+// Encrypt encrypts data to the certificates and the passwords.
+func Encrypt(certs [][]byte, data []byte, options *EncryptOptions) ([]byte, error) {
+	if options == nil {
+		options = &EncryptOptions{}
+	}
+	if len(certs) == 0 && len(options.Passwords) == 0 {
+		return nil, newError(ExitMissingArg, errors.New("gopenpgp: no certificate or password given"))
+	}
+	if options.AsText && !utf8.Valid(data) {
+		return nil, newError(ExitExpectedText, errors.New("gopenpgp: data to encrypt as text isn't valid UTF-8"))
+	}
+
+	recipients, err := readCertKeyRing(certs)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range recipients.GetKeys() {
+		if !key.CanEncrypt() {
+			return nil, newError(
+				ExitCertCannotEncrypt,
+				errors.New("gopenpgp: certificate "+key.GetFingerprint()+" can't encrypt"),
+			)
+		}
+	}
+
+	var signKeyRing *crypto.KeyRing
+	if len(options.SignKeys) > 0 {
+		if signKeyRing, err = readSigningKeyRing(options.SignKeys, options.KeyPasswords); err != nil {
+			return nil, err
+		}
+	}
+
+	sessionKey, err := crypto.GenerateSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	var encrypted bytes.Buffer
+	if recipients.CountEntities() > 0 {
+		keyPacket, err := recipients.EncryptSessionKey(sessionKey)
+		if err != nil {
+			return nil, newError(ExitCertCannotEncrypt, err)
+		}
+		encrypted.Write(keyPacket)
+	}
+	for _, password := range options.Passwords {
+		keyPacket, err := crypto.EncryptSessionKeyWithPassword(sessionKey, password)
+		if err != nil {
+			return nil, err
+		}
+		encrypted.Write(keyPacket)
+	}
+
+	message := crypto.NewPlainMessage(data)
+	message.TextType = options.AsText
+	var dataPacket []byte
+	if signKeyRing != nil {
+		dataPacket, err = sessionKey.EncryptAndSign(message, signKeyRing)
+	} else {
+		dataPacket, err = sessionKey.Encrypt(message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	encrypted.Write(dataPacket)
+
+	return output(encrypted.Bytes(), constants.PGPMessageHeader, !options.NoArmor)
+}
+
+// Decrypt decrypts a message with the session keys, the keys or the
+// passwords, and verifies its signature with the certificates, if any.
+// A signature that can't be verified doesn't fail the decryption, but isn't
+// part of the verifications.
+func Decrypt(keys [][]byte, ciphertext []byte, options *DecryptOptions) (*DecryptResult, error) {
+	if options == nil {
+		options = &DecryptOptions{}
+	}
+	if len(keys) == 0 && len(options.Passwords) == 0 && len(options.SessionKeys) == 0 {
+		return nil, newError(ExitMissingArg, errors.New("gopenpgp: no key, password or session key given"))
+	}
+
+	ciphertext, err := Dearmor(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	split, err := crypto.NewPGPMessage(ciphertext).SplitMessage()
+	if err != nil {
+		return nil, newError(ExitBadData, err)
+	}
+	verifyKeyRing, err := readCertKeyRing(options.VerifyCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, encodedSessionKey := range options.SessionKeys {
+		sessionKey, err := parseSessionKey(encodedSessionKey)
+		if err != nil {
+			return nil, err
+		}
+		if result, err := decryptData(sessionKey, split.GetBinaryDataPacket(), verifyKeyRing, options); err == nil {
+			return result, nil
+		}
+	}
+
+	sessionKey, err := decryptSessionKey(keys, split.GetBinaryKeyPacket(), options)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decryptData(sessionKey, split.GetBinaryDataPacket(), verifyKeyRing, options)
+	if err != nil {
+		return nil, newError(ExitCannotDecrypt, err)
+	}
+	return result, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+var cipherNames = map[packet.CipherFunction]string{
+	packet.Cipher3DES:   constants.ThreeDES,
+	packet.CipherCAST5:  constants.CAST5,
+	packet.CipherAES128: constants.AES128,
+	packet.CipherAES192: constants.AES192,
+	packet.CipherAES256: constants.AES256,
+}
+
+// readSigningKeyRing reads the signing keys into a keyring.
+func readSigningKeyRing(keys [][]byte, passwords [][]byte) (*crypto.KeyRing, error) {
+	unlockedKeys, err := readUnlockedKeys(keys, passwords)
+	if err != nil {
+		return nil, err
+	}
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range unlockedKeys {
+		if !key.CanVerify() {
+			return nil, newError(ExitKeyCannotSign, errors.New("gopenpgp: key "+key.GetFingerprint()+" can't sign"))
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
+}
+
+// decryptSessionKey decrypts the session key in keyPacket with the keys, or
+// the passwords.
+func decryptSessionKey(keys [][]byte, keyPacket []byte, options *DecryptOptions) (*crypto.SessionKey, error) {
+	if len(keys) > 0 {
+		unlockedKeys, err := readUnlockedKeys(keys, options.KeyPasswords)
+		if err != nil {
+			return nil, err
+		}
+		keyRing, err := crypto.NewKeyRing(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range unlockedKeys {
+			if err := keyRing.AddKey(key); err != nil {
+				return nil, err
+			}
+		}
+		if sessionKey, err := keyRing.DecryptSessionKey(keyPacket); err == nil {
+			return sessionKey, nil
+		}
+	}
+
+	for _, password := range options.Passwords {
+		for _, candidate := range passwordCandidates(password) {
+			if sessionKey, err := crypto.DecryptSessionKeyWithPassword(keyPacket, candidate); err == nil {
+				return sessionKey, nil
+			}
+		}
+	}
+	return nil, newError(ExitCannotDecrypt, errors.New("gopenpgp: unable to decrypt the session key"))
+}
+
+// decryptData decrypts a data packet with sessionKey, and verifies its
+// signature with verifyKeyRing, at the current time.
+func decryptData(
+	sessionKey *crypto.SessionKey, dataPacket []byte, verifyKeyRing *crypto.KeyRing, options *DecryptOptions,
+) (*DecryptResult, error) {
+	cipher, err := sessionKey.GetCipherFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	var message *crypto.PlainMessage
+	if verifyKeyRing.CountEntities() > 0 {
+		message, err = sessionKey.DecryptAndVerify(dataPacket, verifyKeyRing, crypto.GetUnixTime())
+		var verificationErr crypto.SignatureVerificationError
+		if errors.As(err, &verificationErr) {
+			// The message is decrypted, but not verified
+			err = nil
+		}
+	} else {
+		message, err = sessionKey.Decrypt(dataPacket)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DecryptResult{
+		Data:       message.GetBinary(),
+		SessionKey: formatSessionKey(cipher, sessionKey.Key),
+	}
+	signerFingerprint, verified := message.GetHexSignerFingerprint()
+	if !verified {
+		return result, nil
+	}
+	signatureTime, _ := message.GetSignatureCreationTime()
+	creationTime := time.Unix(signatureTime, 0)
+	if !inTimeWindow(creationTime, options.NotBefore, options.NotAfter) {
+		return result, nil
+	}
+	entity, signingKey := findKey(verifyKeyRing, func(publicKey *packet.PublicKey) bool {
+		return hex.EncodeToString(publicKey.Fingerprint) == signerFingerprint
+	})
+	if signingKey != nil {
+		result.Verifications = []*Verification{newVerification(entity, signingKey, creationTime)}
+	}
+	return result, nil
+}
+
+func formatSessionKey(cipher packet.CipherFunction, key []byte) string {
+	return fmt.Sprintf("%d:%s", cipher, strings.ToUpper(hex.EncodeToString(key)))
+}
+
+func parseSessionKey(encoded string) (*crypto.SessionKey, error) {
+	parts := strings.SplitN(strings.TrimSpace(encoded), ":", 2)
+	if len(parts) != 2 {
+		return nil, newError(ExitBadData, errors.New("gopenpgp: invalid session key format"))
+	}
+	algo, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, newError(ExitBadData, errors.Wrap(err, "gopenpgp: invalid session key algorithm"))
+	}
+	name, ok := cipherNames[packet.CipherFunction(algo)]
+	if !ok {
+		return nil, newError(ExitBadData, errors.New("gopenpgp: unsupported session key algorithm"))
+	}
+	key, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, newError(ExitBadData, errors.Wrap(err, "gopenpgp: invalid session key"))
+	}
+	return crypto.NewSessionKeyFromToken(key, name), nil
+}
@@ -0,0 +1,185 @@
+package sop
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// SignOptions are the options of Sign.
+type SignOptions struct {
+	// AsText makes text signatures, over the data with canonical line
+	// endings, instead of binary signatures.
+	AsText  bool
+	NoArmor bool
+	// KeyPasswords are tried to unlock the locked keys.
+	KeyPasswords [][]byte
+}
+
+// VerifyOptions are the options of Verify.
+type VerifyOptions struct {
+	// Signatures made outside of [NotBefore, NotAfter] are ignored. The zero
+	// time leaves the bound open.
+	NotBefore, NotAfter time.Time
+}
+
+// Sign makes detached signatures of data, one per key.
+func Sign(keys [][]byte, data []byte, options *SignOptions) ([]byte, error) {
+	if options == nil {
+		options = &SignOptions{}
+	}
+	if len(keys) == 0 {
+		return nil, newError(ExitMissingArg, errors.New("gopenpgp: no signing key given"))
+	}
+	if options.AsText && !utf8.Valid(data) {
+		return nil, newError(ExitExpectedText, errors.New("gopenpgp: data to sign as text isn't valid UTF-8"))
+	}
+
+	unlockedKeys, err := readUnlockedKeys(keys, options.KeyPasswords)
+	if err != nil {
+		return nil, err
+	}
+
+	message := crypto.NewPlainMessage(data)
+	message.TextType = options.AsText
+	var signatures bytes.Buffer
+	for _, key := range unlockedKeys {
+		if !key.CanVerify() {
+			return nil, newError(ExitKeyCannotSign, errors.New("gopenpgp: key "+key.GetFingerprint()+" can't sign"))
+		}
+		keyRing, err := crypto.NewKeyRing(key)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := keyRing.SignDetached(message)
+		if err != nil {
+			return nil, newError(ExitKeyCannotSign, err)
+		}
+		signatures.Write(signature.GetBinary())
+	}
+	return output(signatures.Bytes(), constants.PGPSignatureHeader, !options.NoArmor)
+}
+
+// Verify verifies the detached signatures of data with the certificates, and
+// returns the valid signatures. It fails with ExitNoSignature if none is valid.
+func Verify(signatures []byte, certs [][]byte, data []byte, options *VerifyOptions) ([]*Verification, error) {
+	if options == nil {
+		options = &VerifyOptions{}
+	}
+	if len(certs) == 0 {
+		return nil, newError(ExitMissingArg, errors.New("gopenpgp: no certificate given"))
+	}
+	signatures, err := Dearmor(signatures)
+	if err != nil {
+		return nil, err
+	}
+	keyRing, err := readCertKeyRing(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	message := crypto.NewPlainMessage(data)
+	var verifications []*Verification
+	reader := bytes.NewReader(signatures)
+	for reader.Len() > 0 {
+		start := len(signatures) - reader.Len()
+		p, err := packet.Read(reader)
+		var unsupported pgpErrors.UnsupportedError
+		if errors.As(err, &unsupported) {
+			// Such signatures can't be valid
+			continue
+		}
+		if err != nil {
+			return nil, newError(ExitBadData, errors.Wrap(err, "gopenpgp: unable to read signatures"))
+		}
+		signature, ok := p.(*packet.Signature)
+		if !ok {
+			return nil, newError(ExitBadData, errors.New("gopenpgp: unexpected packet in signatures"))
+		}
+		rawSignature := signatures[start : len(signatures)-reader.Len()]
+		if verification := verifySignature(keyRing, signature, rawSignature, message, options); verification != nil {
+			verifications = append(verifications, verification)
+		}
+	}
+
+	if len(verifications) == 0 {
+		return nil, newError(ExitNoSignature, errors.New("gopenpgp: no valid signature"))
+	}
+	return verifications, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+// verifySignature returns the verification of the detached signature of the
+// message, or nil if it isn't valid. The signature is verified by the keyring,
+// at the current time.
+func verifySignature(
+	keyRing *crypto.KeyRing, signature *packet.Signature, rawSignature []byte,
+	message *crypto.PlainMessage, options *VerifyOptions,
+) *Verification {
+	if signature.SigType != packet.SigTypeBinary && signature.SigType != packet.SigTypeText {
+		return nil
+	}
+	if !inTimeWindow(signature.CreationTime, options.NotBefore, options.NotAfter) {
+		return nil
+	}
+	if keyRing.VerifyDetached(message, crypto.NewPGPSignature(rawSignature), crypto.GetUnixTime()) != nil {
+		return nil
+	}
+
+	entity, signingKey := findKey(keyRing, func(publicKey *packet.PublicKey) bool {
+		if len(signature.IssuerFingerprint) > 0 {
+			return bytes.Equal(publicKey.Fingerprint, signature.IssuerFingerprint)
+		}
+		return signature.IssuerKeyId != nil && publicKey.KeyId == *signature.IssuerKeyId
+	})
+	if signingKey == nil {
+		return nil
+	}
+	return newVerification(entity, signingKey, signature.CreationTime)
+}
+
+// findKey returns the first key of the keyring, primary key or subkey, that
+// matches, and its entity.
+func findKey(keyRing *crypto.KeyRing, matches func(*packet.PublicKey) bool) (*openpgp.Entity, *packet.PublicKey) {
+	for _, key := range keyRing.GetKeys() {
+		entity := key.GetEntity()
+		if matches(entity.PrimaryKey) {
+			return entity, entity.PrimaryKey
+		}
+		for _, subkey := range entity.Subkeys {
+			if matches(subkey.PublicKey) {
+				return entity, subkey.PublicKey
+			}
+		}
+	}
+	return nil, nil
+}
+
+func inTimeWindow(t, notBefore, notAfter time.Time) bool {
+	if !notBefore.IsZero() && t.Before(notBefore) {
+		return false
+	}
+	if !notAfter.IsZero() && t.After(notAfter) {
+		return false
+	}
+	return true
+}
+
+func newVerification(entity *openpgp.Entity, signingKey *packet.PublicKey, creationTime time.Time) *Verification {
+	return &Verification{
+		CreationTime:       creationTime,
+		SigningFingerprint: strings.ToUpper(hex.EncodeToString(signingKey.Fingerprint)),
+		PrimaryFingerprint: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+	}
+}
@@ -0,0 +1,300 @@
+// Package sop implements the operations of the Stateless OpenPGP Command
+// Line Interface (draft-dkg-openpgp-stateless-cli): generate-key,
+// extract-cert, sign, verify, encrypt, decrypt, armor and dearmor.
+//
+// Keys, certificates, signatures and messages are passed as byte slices,
+// armored or not. Errors are of type *Error, carrying the exit code defined by
+// the specification.
+package sop
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// Exit codes of the specification.
+const (
+	ExitNoSignature               = 3
+	ExitUnsupportedAsymmetricAlgo = 13
+	ExitCertCannotEncrypt         = 17
+	ExitMissingArg                = 19
+	ExitIncompleteVerification    = 23
+	ExitCannotDecrypt             = 29
+	ExitPasswordNotHumanReadable  = 31
+	ExitUnsupportedOption         = 37
+	ExitBadData                   = 41
+	ExitExpectedText              = 53
+	ExitOutputExists              = 59
+	ExitMissingInput              = 61
+	ExitKeyIsProtected            = 67
+	ExitUnsupportedSubcommand     = 69
+	ExitUnsupportedSpecialPrefix  = 71
+	ExitAmbiguousInput            = 73
+	ExitKeyCannotSign             = 79
+	ExitIncompatibleOptions       = 83
+)
+
+// Error is the error of an operation, with its exit code.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (err *Error) Error() string {
+	return err.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Verification is a valid signature, as printed in the VERIFICATIONS output.
+type Verification struct {
+	CreationTime time.Time
+	// Fingerprints of the signing key and of its primary key
+	SigningFingerprint string
+	PrimaryFingerprint string
+}
+
+// String formats the verification as a line of the VERIFICATIONS output,
+// without the line ending.
+func (verification *Verification) String() string {
+	return fmt.Sprintf(
+		"%s %s %s",
+		verification.CreationTime.UTC().Format("2006-01-02T15:04:05Z"),
+		verification.SigningFingerprint,
+		verification.PrimaryFingerprint,
+	)
+}
+
+// Version returns the name and version of the implementation.
+func Version() string {
+	return "gopenpgp " + constants.Version
+}
+
+// GenerateKey generates a key with the given user IDs, the first one being
+// primary, locked with password if it isn't empty.
+func GenerateKey(userIDs []string, password []byte, armored bool) ([]byte, error) {
+	if len(userIDs) == 0 {
+		return nil, newError(ExitMissingArg, errors.New("gopenpgp: no user ID given"))
+	}
+
+	name, comment, email := splitUserID(userIDs[0])
+	if comment != "" {
+		return nil, newError(ExitBadData, errors.New("gopenpgp: user ID comments are not supported"))
+	}
+	key, err := crypto.GenerateKey(name, email, "x25519", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range userIDs[1:] {
+		name, comment, email := splitUserID(userID)
+		if key, err = key.AddUserID(name, comment, email); err != nil {
+			return nil, newError(ExitBadData, err)
+		}
+	}
+
+	if len(password) > 0 {
+		if key, err = key.Lock(password); err != nil {
+			return nil, err
+		}
+	}
+
+	serialized, err := key.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return output(serialized, constants.PrivateKeyHeader, armored)
+}
+
+// ExtractCert returns the certificates of the keys.
+func ExtractCert(keys []byte, armored bool) ([]byte, error) {
+	entities, err := readEntities(keys)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	for _, entity := range entities {
+		if err := entity.Serialize(&buffer); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to serialize certificate")
+		}
+	}
+	return output(buffer.Bytes(), constants.PublicKeyHeader, armored)
+}
+
+// Armor armors data, with the label matching its first packet. Armored data
+// is returned unchanged.
+func Armor(data []byte) ([]byte, error) {
+	if isArmored(data) {
+		return data, nil
+	}
+	packets := packet.NewReader(bytes.NewReader(data))
+	p, err := packets.Next()
+	if err != nil {
+		return nil, newError(ExitBadData, errors.Wrap(err, "gopenpgp: unable to read packet"))
+	}
+
+	label := constants.PGPMessageHeader
+	switch p.(type) {
+	case *packet.PrivateKey:
+		label = constants.PrivateKeyHeader
+	case *packet.PublicKey:
+		label = constants.PublicKeyHeader
+	case *packet.Signature:
+		label = constants.PGPSignatureHeader
+	}
+	return output(data, label, true)
+}
+
+// Dearmor removes the armor of data. Unarmored data is returned unchanged.
+func Dearmor(data []byte) ([]byte, error) {
+	if !isArmored(data) {
+		return data, nil
+	}
+	unarmored, err := armor.Unarmor(string(data))
+	if err != nil {
+		return nil, newError(ExitBadData, err)
+	}
+	return unarmored, nil
+}
+
+// ----- INTERNAL FUNCTIONS -----
+
+func newError(code int, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("-----BEGIN PGP "))
+}
+
+// output armors data with label if armored is true.
+func output(data []byte, label string, armored bool) ([]byte, error) {
+	if !armored {
+		return data, nil
+	}
+	armoredData, err := armor.ArmorWithType(data, label)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(armoredData + "\n"), nil
+}
+
+// readEntities reads the keys or certificates of data, armored or not.
+func readEntities(data []byte) (openpgp.EntityList, error) {
+	var entities openpgp.EntityList
+	var err error
+	if isArmored(data) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, newError(ExitBadData, errors.Wrap(err, "gopenpgp: unable to read keys"))
+	}
+	return entities, nil
+}
+
+// readCertKeyRing reads the certificates of all the inputs into a keyring.
+func readCertKeyRing(certs [][]byte) (*crypto.KeyRing, error) {
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		entities, err := readEntities(cert)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range entities {
+			key, err := crypto.NewKeyFromEntity(entity)
+			if err != nil {
+				return nil, newError(ExitBadData, err)
+			}
+			if err := keyRing.AddKey(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return keyRing, nil
+}
+
+// readUnlockedKeys reads the keys of all the inputs, unlocking them with one
+// of the passwords if needed.
+func readUnlockedKeys(keys [][]byte, passwords [][]byte) ([]*crypto.Key, error) {
+	var unlockedKeys []*crypto.Key
+	for _, data := range keys {
+		entities, err := readEntities(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range entities {
+			if entity.PrivateKey == nil {
+				return nil, newError(ExitBadData, errors.New("gopenpgp: a certificate was given instead of a key"))
+			}
+			key, err := crypto.NewKeyFromEntity(entity)
+			if err != nil {
+				return nil, newError(ExitBadData, err)
+			}
+			if key, err = unlockKey(key, passwords); err != nil {
+				return nil, err
+			}
+			unlockedKeys = append(unlockedKeys, key)
+		}
+	}
+	return unlockedKeys, nil
+}
+
+func unlockKey(key *crypto.Key, passwords [][]byte) (*crypto.Key, error) {
+	locked, err := key.IsLocked()
+	if err != nil {
+		return nil, newError(ExitBadData, err)
+	}
+	if !locked {
+		return key, nil
+	}
+	for _, password := range passwords {
+		for _, candidate := range passwordCandidates(password) {
+			if unlocked, err := key.Unlock(candidate); err == nil {
+				return unlocked, nil
+			}
+		}
+	}
+	return nil, newError(ExitKeyIsProtected, errors.New("gopenpgp: unable to unlock key"))
+}
+
+// passwordCandidates returns the password, and the password without its
+// trailing whitespace if different, as the specification requires when
+// passwords are used for decryption.
+func passwordCandidates(password []byte) [][]byte {
+	trimmed := bytes.TrimRight(password, " \t\r\n")
+	if len(trimmed) == len(password) {
+		return [][]byte{password}
+	}
+	return [][]byte{password, trimmed}
+}
+
+// splitUserID splits a user ID of the form "Name (Comment) <email>".
+func splitUserID(userID string) (name, comment, email string) {
+	name = userID
+	if start, end := strings.LastIndex(name, "<"), strings.LastIndex(name, ">"); start >= 0 && end > start {
+		email = name[start+1 : end]
+		name = strings.TrimSpace(name[:start])
+	}
+	if start, end := strings.Index(name, "("), strings.LastIndex(name, ")"); start >= 0 && end > start {
+		comment = name[start+1 : end]
+		name = strings.TrimSpace(name[:start])
+	}
+	return name, comment, email
+}
@@ -33,3 +33,9 @@ func DecryptWithoutIntegrity(key, input, iv []byte) ([]byte, error) {
 func DeriveKey(password string, salt []byte, n int) ([]byte, error) {
 	return scrypt.Key([]byte(password), salt, n, 8, 1, 32)
 }
+
+// DeriveKeyFromBytes derives a key from a password like DeriveKey, with the
+// password as a byte slice, that the caller can zero after use.
+func DeriveKeyFromBytes(password, salt []byte, n int) ([]byte, error) {
+	return scrypt.Key(password, salt, n, 8, 1, 32)
+}
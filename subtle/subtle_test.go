@@ -29,4 +29,7 @@ func TestSubtle_DeriveKey(t *testing.T) {
 	salt, _ := hex.DecodeString("c828f258a76aad7b")
 	dk, _ := DeriveKey("some password", salt, 32768)
 	assert.Exactly(t, "9469cccfc8a8d005247f39fa3e5b35a97db456cecf18deac6d84364d0818d763", hex.EncodeToString(dk))
+
+	dk, _ = DeriveKeyFromBytes([]byte("some password"), salt, 32768)
+	assert.Exactly(t, "9469cccfc8a8d005247f39fa3e5b35a97db456cecf18deac6d84364d0818d763", hex.EncodeToString(dk))
 }
@@ -0,0 +1,194 @@
+// Package wkd discovers the public keys of email addresses with the OpenPGP
+// Web Key Directory (WKD), which serves them over HTTPS from the domain of the
+// address.
+//
+// The advanced method is tried first, from the openpgpkey subdomain, then the
+// direct method, from the domain itself.
+package wkd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// ErrNotFound is returned when no key is published for an email address.
+var ErrNotFound = errors.New("gopenpgp: no key found in the web key directory")
+
+// maxResponseSize limits the size of the keys read from a directory.
+const maxResponseSize = 1 << 20
+
+// zBase32Alphabet is the alphabet of the z-base-32 encoding of the hashed
+// local part of addresses.
+const zBase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// Client fetches keys from web key directories.
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient returns a client using http.DefaultClient.
+func NewClient() *Client {
+	return NewClientWithHTTPClient(http.DefaultClient)
+}
+
+// NewClientWithHTTPClient returns a client sending its requests with
+// httpClient, e.g. to set timeouts or a proxy.
+func NewClientWithHTTPClient(httpClient *http.Client) *Client {
+	return &Client{httpClient: httpClient}
+}
+
+// GetURLs returns the URLs of the key of the email address, with the advanced
+// and the direct methods.
+func GetURLs(email string) (advanced, direct string, err error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", "", errors.New("gopenpgp: invalid email address")
+	}
+	localPart, domain := email[:at], strings.ToLower(email[at+1:])
+
+	digest := sha1.Sum([]byte(strings.ToLower(localPart))) //nolint:gosec
+	hashedLocalPart := zBase32Encode(digest[:])
+	query := "?l=" + url.QueryEscape(localPart)
+
+	advanced = fmt.Sprintf(
+		"https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s%s", domain, domain, hashedLocalPart, query,
+	)
+	direct = fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s%s", domain, hashedLocalPart, query)
+	return advanced, direct, nil
+}
+
+// Lookup returns the keys published for the email address, or ErrNotFound.
+// Only the keys with a user ID matching the address are returned.
+func (client *Client) Lookup(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	advanced, direct, err := GetURLs(email)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.fetch(ctx, advanced)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var directErr error
+		data, directErr = client.fetch(ctx, direct)
+		if directErr != nil {
+			if err == ErrNotFound || directErr != ErrNotFound {
+				err = directErr
+			}
+			return nil, err
+		}
+	}
+
+	return parseKeys(data, email)
+}
+
+// --- Internal functions
+
+// fetch returns the body of the response to a GET request to keyURL, or
+// ErrNotFound if the server has no key there.
+func (client *Client) fetch(ctx context.Context, keyURL string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create request")
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to fetch key")
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gopenpgp: unable to fetch key: %s", response.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to fetch key")
+	}
+	if len(data) > maxResponseSize {
+		return nil, errors.New("gopenpgp: key is too large")
+	}
+	return data, nil
+}
+
+// parseKeys returns the keys in data with a user ID matching the email
+// address. Keys should be served unarmored, but armored ones are accepted.
+func parseKeys(data []byte, email string) (*crypto.KeyRing, error) {
+	var entities openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read key")
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if !hasEmail(entity, email) {
+			continue
+		}
+		key, err := crypto.NewKeyFromEntity(entity)
+		if err != nil {
+			return nil, err
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if keyRing.CountEntities() == 0 {
+		return nil, ErrNotFound
+	}
+	return keyRing, nil
+}
+
+// hasEmail returns true if entity has a user ID with the email address.
+func hasEmail(entity *openpgp.Entity, email string) bool {
+	for _, identity := range entity.Identities {
+		if strings.EqualFold(identity.UserId.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// zBase32Encode encodes data with the z-base-32 alphabet.
+func zBase32Encode(data []byte) string {
+	var builder strings.Builder
+	var buffer, bits uint
+	for _, b := range data {
+		buffer = buffer<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			builder.WriteByte(zBase32Alphabet[(buffer>>bits)&31])
+		}
+	}
+	if bits > 0 {
+		builder.WriteByte(zBase32Alphabet[(buffer<<(5-bits))&31])
+	}
+	return builder.String()
+}
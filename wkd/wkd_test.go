@@ -0,0 +1,99 @@
+package wkd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+const testEmail = "Joe.Doe@Example.ORG"
+
+func TestGetURLs(t *testing.T) {
+	advanced, direct, err := GetURLs(testEmail)
+	if err != nil {
+		t.Fatal("Cannot get URLs:", err)
+	}
+	assert.Equal(
+		t,
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		advanced,
+	)
+	assert.Equal(t, "https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe", direct)
+
+	_, _, err = GetURLs("example.org")
+	assert.NotNil(t, err)
+}
+
+// redirectTransport sends all the requests to a test server.
+type redirectTransport struct {
+	server *httptest.Server
+}
+
+func (transport *redirectTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	serverURL, _ := url.Parse(transport.server.URL)
+	redirected := request.Clone(request.Context())
+	redirected.URL.Scheme = serverURL.Scheme
+	redirected.URL.Host = serverURL.Host
+	redirected.Header.Set("X-Original-Host", request.URL.Host)
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
+func TestLookup(t *testing.T) {
+	key, err := crypto.GenerateKey("Joe Doe", "joe.doe@example.org", "x25519", 0)
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	publicKey, err := key.GetPublicKey()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	armoredKey, err := key.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal("Cannot armor key:", err)
+	}
+
+	keys := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := keys[r.Header.Get("X-Original-Host")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	client := NewClientWithHTTPClient(&http.Client{Transport: &redirectTransport{server: server}})
+
+	_, err = client.Lookup(context.Background(), testEmail)
+	assert.Exactly(t, ErrNotFound, err)
+
+	keys["example.org"] = publicKey
+	keyRing, err := client.Lookup(context.Background(), testEmail)
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Exactly(t, 1, keyRing.CountEntities())
+	assert.Equal(t, key.GetFingerprint(), keyRing.GetKeys()[0].GetFingerprint())
+
+	keys["openpgpkey.example.org"] = []byte(armoredKey)
+	delete(keys, "example.org")
+	keyRing, err = client.Lookup(context.Background(), testEmail)
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Exactly(t, 1, keyRing.CountEntities())
+
+	_, err = client.Lookup(context.Background(), "someone.else@example.org")
+	assert.Exactly(t, ErrNotFound, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Lookup(ctx, testEmail)
+	assert.Exactly(t, context.Canceled, err)
+}